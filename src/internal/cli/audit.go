@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/howmanysmall/relay/src/internal/core"
+	"github.com/howmanysmall/relay/src/internal/display"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var verifyAuditCmd = &cobra.Command{
+	Use:   "verify-audit <audit-log>",
+	Short: "Check that an audit log has not been tampered with",
+	Long: `Verify the hash chain of an audit log written with --audit-log. Each
+entry carries the hash of the one before it, so an entry that was edited,
+removed or reordered is reported along with where the chain breaks.
+
+Examples:
+  relay mirror ./records /mnt/archive --audit-log audit.jsonl # Record changes
+  relay verify-audit audit.jsonl                              # Check the log`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		colorEnabled := term.IsTerminal(int(os.Stdout.Fd()))
+		statusRenderer := display.NewStatusRenderer(colorEnabled, false)
+
+		file, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to open audit log: %w", err)
+		}
+
+		defer func() {
+			_ = file.Close()
+		}()
+
+		last, err := core.VerifyAuditLog(file)
+		if err != nil {
+			return err
+		}
+
+		if last.Seq == 0 {
+			statusRenderer.PrintSuccess("Audit log is empty")
+			return nil
+		}
+
+		statusRenderer.PrintSuccess(fmt.Sprintf("Audit log intact: %d entries", last.Seq),
+			fmt.Sprintf("last change %s, hash %s", last.Time.Local().Format(time.DateTime), last.Hash))
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyAuditCmd)
+}
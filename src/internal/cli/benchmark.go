@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/howmanysmall/relay/src/internal/config"
+	"github.com/howmanysmall/relay/src/internal/core"
+	"github.com/howmanysmall/relay/src/internal/display"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// benchmarkBufferSizes are the copy buffer sizes relay benchmark compares.
+var benchmarkBufferSizes = []int64{4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20}
+
+var (
+	benchmarkSize string
+	benchmarkJSON bool
+)
+
+var benchmarkCmd = &cobra.Command{
+	Use:   "benchmark [directory]",
+	Short: "Measure disk and checksum throughput and suggest performance settings",
+	Long: `Measure how fast this machine moves data, then suggest the settings that
+suit it:
+
+  - writing and reading a file through buffers from 4KB to 4MB
+  - copying a file through a buffer, and by reflink or zero-copy where the
+    file system offers one
+  - hashing with each checksum algorithm, in memory
+
+The files are written to the given directory, or the system's temporary
+directory, and removed afterwards; benchmark the disk you sync to by naming
+a directory on it. Reads are often served from memory, so read speeds can
+exceed what the disk delivers. --json prints the measurements and the
+suggested settings for scripts.
+
+Examples:
+  relay benchmark                          # Benchmark the temporary directory
+  relay benchmark /mnt/backup              # Benchmark the backup disk
+  relay benchmark --size 1GB               # Larger files, steadier results`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeOneDir,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		colorEnabled := term.IsTerminal(int(os.Stdout.Fd()))
+
+		size, err := config.ParseSize(benchmarkSize)
+		if err != nil {
+			return fmt.Errorf("invalid --size: %w", err)
+		}
+
+		if size <= 0 {
+			return fmt.Errorf("invalid --size %q: must be positive", benchmarkSize)
+		}
+
+		dir := os.TempDir()
+		if len(args) == 1 {
+			dir = args[0]
+		}
+
+		cmd.SilenceUsage = true
+
+		ctx, cancel := runContext(cmd)
+		defer cancel()
+
+		report := &core.BenchmarkReport{Dir: dir, FileSize: size}
+
+		if !benchmarkJSON {
+			fmt.Printf("⏱  Relay Benchmark in %s, %s per file\n\n", dir, display.FormatBytes(size))
+		}
+
+		report.Buffers, err = core.BenchmarkBuffers(ctx, dir, size, benchmarkBufferSizes)
+		if err != nil {
+			return runTimeoutError(ctx, err)
+		}
+
+		bufferSize := report.Recommend().BufferSize
+
+		if !benchmarkJSON {
+			fmt.Println("Reading and writing:")
+			fmt.Println(display.RenderBufferBenchmarks(report.Buffers, bufferSize, colorEnabled))
+			fmt.Println()
+		}
+
+		report.Copies, err = core.BenchmarkCopy(ctx, dir, size, bufferSize)
+		if err != nil {
+			return runTimeoutError(ctx, err)
+		}
+
+		if !benchmarkJSON {
+			fmt.Println("Copying:")
+			fmt.Println(display.RenderCopyBenchmarks(report.Copies))
+			fmt.Println()
+		}
+
+		report.Checksums = core.BenchmarkChecksums(doctorHashSize)
+		advice := report.Recommend()
+
+		if benchmarkJSON {
+			data, err := json.MarshalIndent(struct {
+				*core.BenchmarkReport
+				Recommended core.BenchmarkAdvice `json:"recommended"`
+			}{report, advice}, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to encode benchmark: %w", err)
+			}
+
+			fmt.Println(string(data))
+
+			return nil
+		}
+
+		fmt.Println("Hashing in memory:")
+		fmt.Println(display.RenderChecksumBenchmarks(report.Checksums, advice.ChecksumAlgo, colorEnabled))
+		fmt.Println()
+
+		fmt.Println("Suggested settings for profiles on this disk:")
+		fmt.Printf("  \"bufferSize\": %q,\n", sizeSetting(advice.BufferSize))
+		fmt.Printf("  \"performance\": {\"useZeroCopy\": %t, \"checksumAlgo\": %q}\n", advice.UseZeroCopy, advice.ChecksumAlgo)
+
+		return nil
+	},
+}
+
+// sizeSetting formats size the way config files write sizes, e.g. "256KB".
+func sizeSetting(size int64) string {
+	switch {
+	case size >= 1<<20 && size%(1<<20) == 0:
+		return fmt.Sprintf("%dMB", size>>20)
+	case size >= 1<<10 && size%(1<<10) == 0:
+		return fmt.Sprintf("%dKB", size>>10)
+	default:
+		return fmt.Sprintf("%d", size)
+	}
+}
+
+func init() {
+	benchmarkCmd.Flags().StringVar(&benchmarkSize, "size", "64MB", "size of each file written and copied (e.g., '256MB', '1GB')")
+	benchmarkCmd.Flags().BoolVar(&benchmarkJSON, "json", false, "print the measurements and suggested settings as JSON")
+
+	rootCmd.AddCommand(benchmarkCmd)
+}
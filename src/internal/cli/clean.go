@@ -0,0 +1,240 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/howmanysmall/relay/src/internal/config"
+	"github.com/howmanysmall/relay/src/internal/core"
+	"github.com/howmanysmall/relay/src/internal/display"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// tempFileGrace is how long a temporary file must have gone unmodified
+// before relay clean removes it, so that copies still in progress keep
+// theirs.
+const tempFileGrace = time.Hour
+
+var (
+	olderThan       string
+	cleanBackupDir  string
+	cleanSkipBackup bool
+)
+
+var cleanCmd = &cobra.Command{
+	Use:   "clean [directory...]",
+	Short: "Prune old conflict backups and leftover temporary files",
+	Long: `List the backups conflicts have saved in the backup directory, and with
+--older-than delete those older than the given age. Then remove the
+temporary files that interrupted copies left in the given directories, or
+in the profile's destination, and its source too for sync profiles.
+
+The backup directory is --backup-dir, or the profile's conflict.backupDir,
+or .relay-backups. Temporary files modified within the last hour are kept,
+since a copy may still be writing them. --dry-run lists what would be
+deleted.
+
+Examples:
+  relay clean                              # List backups, remove temp files
+  relay clean --older-than 30d             # Also delete backups over 30 days old
+  relay clean --profile nas --dry-run      # Preview for a profile
+  relay clean /mnt/backup                  # Remove temp files under a directory`,
+	ValidArgsFunction: completeDirs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		colorEnabled := term.IsTerminal(int(os.Stdout.Fd()))
+		statusRenderer := display.NewStatusRenderer(colorEnabled, false)
+
+		var maxAge time.Duration
+
+		if olderThan != "" {
+			var err error
+
+			maxAge, err = config.ParseAge(olderThan)
+			if err != nil {
+				return fmt.Errorf("invalid --older-than: %w", err)
+			}
+		}
+
+		prof, err := loadProfile()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		cmd.SilenceUsage = true
+
+		ctx, cancel := runContext(cmd)
+		defer cancel()
+
+		now := time.Now()
+		failed := 0
+
+		if !cleanSkipBackup {
+			failed += cleanBackups(backupDirOf(prof, cleanBackupDir), olderThan, maxAge, now, statusRenderer, colorEnabled)
+		}
+
+		roots := args
+		if len(roots) == 0 {
+			roots = cleanRoots(prof)
+		}
+
+		for _, root := range roots {
+			found, err := core.FindTempFiles(ctx, root, now.Add(-tempFileGrace))
+
+			switch {
+			case errors.Is(err, fs.ErrNotExist):
+				continue
+			case err != nil:
+				return runTimeoutError(ctx, err)
+			}
+
+			failed += removeFiles(found, "temporary file", "temporary files", root, statusRenderer)
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("failed to delete %d files", failed)
+		}
+
+		return nil
+	},
+}
+
+// backupDirOf returns the directory conflict backups of prof are kept in,
+// which dir given on the command line overrides.
+func backupDirOf(prof *config.Profile, dir string) string {
+	switch {
+	case dir != "":
+		return dir
+	case prof.Conflict != nil && prof.Conflict.BackupDir != "":
+		return prof.Conflict.BackupDir
+	default:
+		return core.DefaultBackupDir
+	}
+}
+
+// cleanRoots returns the directories relay clean searches for temporary
+// files by default: the destination of prof, and its source when it syncs
+// both ways.
+func cleanRoots(prof *config.Profile) []string {
+	var roots []string
+
+	if prof.Destination != "" {
+		roots = append(roots, prof.Destination)
+	}
+
+	if prof.Mode == string(config.ModeSync) && prof.Source != "" {
+		roots = append(roots, prof.Source)
+	}
+
+	return roots
+}
+
+// cleanBackups lists the backups in dir and deletes those older than maxAge
+// when age, as given on the command line, is set. It returns the number of
+// backups that could not be deleted.
+func cleanBackups(dir, age string, maxAge time.Duration, now time.Time, statusRenderer *display.StatusRenderer, colorEnabled bool) int {
+	backups, err := core.ListBackups(dir)
+	if err != nil {
+		statusRenderer.PrintError("Cannot list backups", err.Error())
+		return 1
+	}
+
+	if len(backups) == 0 {
+		statusRenderer.PrintInfo("No backups", dir)
+		return 0
+	}
+
+	var cutoff time.Time
+	if age != "" {
+		cutoff = now.Add(-maxAge)
+	}
+
+	fmt.Printf("Backups in %s:\n", dir)
+	fmt.Println(display.RenderBackups(backups, cutoff, now, colorEnabled))
+	fmt.Println()
+
+	if age == "" {
+		return 0
+	}
+
+	var expired []string
+
+	for _, backup := range backups {
+		if backup.Created.Before(cutoff) {
+			expired = append(expired, backup.Path)
+		}
+	}
+
+	return removeFiles(expired, "backup older than "+age, "backups older than "+age, dir, statusRenderer)
+}
+
+// removeFiles deletes paths, or only reports them on a dry run, and returns
+// the number that could not be deleted. singular and plural name what paths
+// are in the summary.
+func removeFiles(paths []string, singular, plural, dir string, statusRenderer *display.StatusRenderer) int {
+	if len(paths) == 0 {
+		return 0
+	}
+
+	noun := func(n int) string {
+		if n == 1 {
+			return singular
+		}
+
+		return plural
+	}
+
+	if dryRun {
+		statusRenderer.PrintInfo(fmt.Sprintf("Would delete %d %s in %s", len(paths), noun(len(paths)), dir), relativeTo(dir, paths)...)
+		return 0
+	}
+
+	var (
+		deleted []string
+		failed  int
+	)
+
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			statusRenderer.PrintError("Cannot delete "+path, err.Error())
+
+			failed++
+
+			continue
+		}
+
+		deleted = append(deleted, path)
+	}
+
+	if len(deleted) > 0 {
+		statusRenderer.PrintSuccess(fmt.Sprintf("Deleted %d %s in %s", len(deleted), noun(len(deleted)), dir), relativeTo(dir, deleted)...)
+	}
+
+	return failed
+}
+
+// relativeTo returns paths relative to dir where possible.
+func relativeTo(dir string, paths []string) []string {
+	relative := make([]string, len(paths))
+
+	for i, path := range paths {
+		relative[i] = path
+		if rel, err := filepath.Rel(dir, path); err == nil {
+			relative[i] = rel
+		}
+	}
+
+	return relative
+}
+
+func init() {
+	cleanCmd.Flags().StringVar(&olderThan, "older-than", "", "delete backups older than this age (e.g., '30d', '2w', '12h')")
+	cleanCmd.Flags().StringVar(&cleanBackupDir, "backup-dir", "", "directory of the backups to prune (default: the profile's, else .relay-backups)")
+	cleanCmd.Flags().BoolVar(&cleanSkipBackup, "no-backups", false, "leave backups alone and only remove temporary files")
+
+	rootCmd.AddCommand(cleanCmd)
+}
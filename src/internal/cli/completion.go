@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/howmanysmall/relay/src/internal/config"
+	"github.com/howmanysmall/relay/src/internal/core"
+	"github.com/howmanysmall/relay/src/internal/display"
+	"github.com/spf13/cobra"
+)
+
+// configExtensions are the file extensions of the config formats relay
+// reads, without their dots.
+var configExtensions = []string{"jsonc", "json", "toml", "yaml", "yml"}
+
+// completeProfiles completes the names of the profiles in the config file
+// given by --config, or else the one relay finds. A config file that is not
+// valid yet still completes, as long as it parses.
+func completeProfiles(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	loader := config.NewLoader()
+
+	configPath := configFile
+	if configPath == "" {
+		configPath = loader.FindConfig()
+	}
+
+	if configPath == "" {
+		return []string{"default"}, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	cfg, err := loader.Read(configPath)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	names := slices.DeleteFunc(display.ProfileNames(cfg), func(name string) bool {
+		return !strings.HasPrefix(name, toComplete)
+	})
+
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeProfileArgs completes every positional argument with a profile
+// name not given yet.
+func completeProfileArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	names, directive := completeProfiles(cmd, args, toComplete)
+
+	return slices.DeleteFunc(names, func(name string) bool {
+		return slices.Contains(args, name)
+	}), directive
+}
+
+// completeOneProfile completes the first positional argument with a profile
+// name, and nothing after it.
+func completeOneProfile(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	return completeProfiles(cmd, args, toComplete)
+}
+
+// completeConfigFile completes files with the extension of a config format.
+func completeConfigFile(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+	return configExtensions, cobra.ShellCompDirectiveFilterFileExt
+}
+
+// completeOneConfigFile completes the first positional argument with a
+// config file, and nothing after it.
+func completeOneConfigFile(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	return completeConfigFile(cmd, args, toComplete)
+}
+
+// completeDirs completes positional arguments with directories.
+func completeDirs(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+	return nil, cobra.ShellCompDirectiveFilterDirs
+}
+
+// completeOneDir completes the first positional argument with a directory,
+// and nothing after it.
+func completeOneDir(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	return completeDirs(cmd, args, toComplete)
+}
+
+// completeDirPair completes two positional arguments with directories, and
+// nothing after them.
+func completeDirPair(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) >= 2 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	return completeDirs(cmd, args, toComplete)
+}
+
+// completeChecksumAlgos completes the supported checksum algorithms.
+func completeChecksumAlgos(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+	return core.ChecksumAlgorithms(), cobra.ShellCompDirectiveNoFileComp
+}
+
+// fixedCompletions completes a flag with one of values.
+func fixedCompletions(values ...string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+		return values, cobra.ShellCompDirectiveNoFileComp
+	}
+}
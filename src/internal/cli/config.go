@@ -0,0 +1,232 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/howmanysmall/relay/src/internal/config"
+	"github.com/howmanysmall/relay/src/internal/display"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage configuration files",
+}
+
+var (
+	convertTo     string
+	convertOutput string
+	convertForce  bool
+)
+
+var configEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Edit a configuration profile interactively",
+	Long: `Edit the source, destination, mode, conflict strategy and filters of a
+profile with an interactive form, then write the configuration back.
+
+The file given by --config is edited, or the default config file if one is
+found, or a new relay.jsonc built from the defaults. Each answer is checked
+with the same rules relay applies when loading the file. Comments in a JSONC
+file are not kept.
+
+Examples:
+  relay config edit                            # Edit the default profile
+  relay config edit --profile backup           # Edit or create a named profile
+  relay config edit --config project.toml      # Edit a specific file`,
+	Args: cobra.NoArgs,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		colorEnabled := term.IsTerminal(int(os.Stdout.Fd()))
+		statusRenderer := display.NewStatusRenderer(colorEnabled, false)
+		loader := config.NewLoader()
+
+		configPath := configFile
+		if configPath == "" {
+			configPath = loader.FindConfig()
+		}
+
+		if configPath == "" {
+			configPath = "relay.jsonc"
+		}
+
+		cfg, err := loader.Read(configPath)
+
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			statusRenderer.PrintInfo("Creating new config", configPath)
+
+			cfg = loader.Defaults()
+		case err != nil:
+			return err
+		}
+
+		name, target := profileToEdit(cfg)
+
+		form := display.NewConfigForm(colorEnabled, loader.ValidateProfile)
+
+		save, err := form.Edit(name, target)
+		if err != nil {
+			return err
+		}
+
+		if !save {
+			statusRenderer.PrintInfo("Discarded changes", configPath)
+			return nil
+		}
+
+		if err := config.Save(cfg, configPath); err != nil {
+			return err
+		}
+
+		statusRenderer.PrintSuccess("Saved config", configPath)
+
+		return nil
+	},
+}
+
+// profileToEdit returns the name of the profile selected by --profile and the
+// profile itself, adding an empty one to cfg when it does not exist yet.
+var configConvertCmd = &cobra.Command{
+	Use:   "convert [config-file]",
+	Short: "Convert a configuration file to another format",
+	Long: `Read a configuration file and write it as JSON, JSONC, TOML or YAML. The
+file given as an argument is converted, or the one given by --config, or the
+default config file. It must be valid.
+
+The new file is written next to the old one with the new format's extension,
+unless --output names it. Line comments above a setting, and those heading
+the file, are carried over to formats that have comments: JSONC, TOML and
+YAML. Settings that relay fills in when left out, such as each profile's mode,
+are written out, and profiles that extend another stay separate.
+
+Examples:
+  relay config convert relay.toml --to jsonc    # Writes relay.jsonc
+  relay config convert --to yaml                # The default config file
+  relay config convert relay.jsonc -o ~/.config/relay/relay.yaml`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeOneConfigFile,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		colorEnabled := term.IsTerminal(int(os.Stdout.Fd()))
+		statusRenderer := display.NewStatusRenderer(colorEnabled, false)
+
+		from := configFile
+		if len(args) == 1 {
+			from = args[0]
+		}
+
+		if from == "" {
+			from = config.NewLoader().FindConfig()
+		}
+
+		if from == "" {
+			return errors.New("no config file found; pass one to convert")
+		}
+
+		to, err := convertPath(from)
+		if err != nil {
+			return err
+		}
+
+		cmd.SilenceUsage = true
+
+		if _, err := os.Lstat(to); err == nil && !convertForce {
+			return fmt.Errorf("%s already exists; use --force to replace it", to)
+		} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		if dryRun {
+			statusRenderer.PrintInfo("Would convert "+from, "to "+to)
+			return nil
+		}
+
+		dropped, err := config.Convert(from, to)
+		if err != nil {
+			return err
+		}
+
+		statusRenderer.PrintSuccess("Converted "+from, "to "+to)
+
+		if dropped > 0 {
+			statusRenderer.PrintWarning(display.CountOf(int64(dropped), "comment", "comments")+" could not be carried over",
+				"only line comments above a setting, or heading the file, are kept, and plain JSON has none")
+		}
+
+		return nil
+	},
+}
+
+// convertPath returns the file relay config convert writes for the config
+// file from, from --output and --to.
+func convertPath(from string) (string, error) {
+	format := strings.ToLower(strings.TrimPrefix(convertTo, "."))
+	if format == "" && convertOutput != "" {
+		format = strings.ToLower(strings.TrimPrefix(filepath.Ext(convertOutput), "."))
+	}
+
+	switch format {
+	case "json", "jsonc", "toml", "yaml", "yml":
+	case "":
+		return "", errors.New("no format to convert to: use --to or --output")
+	default:
+		return "", fmt.Errorf("unsupported config format %q: use json, jsonc, toml or yaml", format)
+	}
+
+	if convertOutput == "" {
+		to := strings.TrimSuffix(from, filepath.Ext(from)) + "." + format
+		if to == from {
+			return "", fmt.Errorf("%s is already %s; use --output to write a copy", from, format)
+		}
+
+		return to, nil
+	}
+
+	yaml := []string{"yaml", "yml"}
+	if ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(convertOutput), ".")); ext != format &&
+		!(slices.Contains(yaml, ext) && slices.Contains(yaml, format)) {
+		return "", fmt.Errorf("--output %s does not have the .%s extension relay needs to read it back", convertOutput, format)
+	}
+
+	return convertOutput, nil
+}
+
+func profileToEdit(cfg *config.Config) (string, *config.Profile) {
+	if profile == "" || profile == "default" {
+		if cfg.Default == nil {
+			if named, exists := cfg.Profiles["default"]; exists {
+				return "default", named
+			}
+
+			cfg.Default = &config.Profile{}
+		}
+
+		return "default", cfg.Default
+	}
+
+	if cfg.Profiles == nil {
+		cfg.Profiles = make(map[string]*config.Profile)
+	}
+
+	if _, exists := cfg.Profiles[profile]; !exists {
+		cfg.Profiles[profile] = &config.Profile{}
+	}
+
+	return profile, cfg.Profiles[profile]
+}
+
+func init() {
+	configConvertCmd.Flags().StringVar(&convertTo, "to", "", "format to write: json, jsonc, toml or yaml (default: from the --output extension)")
+	configConvertCmd.Flags().StringVarP(&convertOutput, "output", "o", "", "file to write (default: the input file with the new extension)")
+	configConvertCmd.Flags().BoolVar(&convertForce, "force", false, "replace the output file if it exists")
+
+	configCmd.AddCommand(configEditCmd)
+	configCmd.AddCommand(configConvertCmd)
+	rootCmd.AddCommand(configCmd)
+}
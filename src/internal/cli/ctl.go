@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"github.com/howmanysmall/relay/src/internal/core"
+	"github.com/spf13/cobra"
+)
+
+var ctlCmd = &cobra.Command{
+	Use:   "ctl",
+	Short: "Control a running daemon",
+	Long: `Pause, resume or trigger the profiles of a running relay daemon over its
+control socket, without restarting it.
+
+A paused profile is not mirrored by its schedule or by changes to its source.
+A mirror already going when it is paused runs to the end. When the profile is
+resumed, it is mirrored straight away if a run was skipped while it was
+paused, so changes made in the meantime are not left waiting for the next
+one.
+
+Examples:
+  relay ctl pause                # Pause every profile
+  relay ctl pause photos         # Pause one profile
+  relay ctl resume               # Resume every profile
+  relay ctl sync nas             # Mirror nas now`,
+}
+
+var ctlPauseCmd = &cobra.Command{
+	Use:   "pause [profile]",
+	Short: "Stop a running daemon from starting mirrors",
+	Long: `Ask the daemon listening on the control socket to stop mirroring a
+profile, or every profile when none is named, until relay ctl resume. Mirrors
+already going run to the end, and relay ctl sync still mirrors a paused
+profile.
+
+Examples:
+  relay ctl pause
+  relay ctl pause photos`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeOneProfile,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return controlDaemon(cmd, core.ControlRequest{Command: core.ControlPause, Profile: optionalArg(args)})
+	},
+}
+
+var ctlResumeCmd = &cobra.Command{
+	Use:   "resume [profile]",
+	Short: "Let a running daemon mirror paused profiles again",
+	Long: `Ask the daemon listening on the control socket to resume a paused
+profile, or every profile when none is named. A profile that missed a run
+while paused is mirrored straight away.
+
+Examples:
+  relay ctl resume
+  relay ctl resume photos`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeOneProfile,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return controlDaemon(cmd, core.ControlRequest{Command: core.ControlResume, Profile: optionalArg(args)})
+	},
+}
+
+var ctlSyncCmd = &cobra.Command{
+	Use:   "sync <profile>",
+	Short: "Make a running daemon mirror a profile now",
+	Long: `Ask the daemon listening on the control socket to mirror a profile now,
+whatever normally triggers it and even while it is paused. The command
+returns once the run has started; relay daemon status follows its progress.
+
+Examples:
+  relay ctl sync nas`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeOneProfile,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return controlDaemon(cmd, core.ControlRequest{Command: core.ControlSync, Profile: args[0]})
+	},
+}
+
+// optionalArg returns the only argument in args, or "" when there is none.
+func optionalArg(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+
+	return args[0]
+}
+
+func init() {
+	ctlCmd.PersistentFlags().StringVar(&controlSocket, "socket", "", "control socket path (default: relay/daemon.sock in the user cache directory)")
+
+	ctlCmd.AddCommand(ctlPauseCmd)
+	ctlCmd.AddCommand(ctlResumeCmd)
+	ctlCmd.AddCommand(ctlSyncCmd)
+	rootCmd.AddCommand(ctlCmd)
+}
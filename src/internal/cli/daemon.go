@@ -0,0 +1,575 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/howmanysmall/relay/src/internal/config"
+	"github.com/howmanysmall/relay/src/internal/core"
+	"github.com/howmanysmall/relay/src/internal/display"
+	"github.com/robfig/cron/v3"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// watchSettle is how long a watched source must go without changes before
+// relay daemon mirrors it, so that a burst of writes leads to one run.
+const watchSettle = time.Second
+
+var controlSocket string
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon [profile...]",
+	Short: "Keep every profile in sync and take commands over a control socket",
+	Long: `Stay resident and keep the profiles of the configuration file, or just
+those named, in sync:
+
+  - a profile with a "schedule" is mirrored at its scheduled times
+  - a profile with "watch" set, or in watch mode, is mirrored once at start
+    and again whenever its source changes
+  - any other profile with a source and destination is mirrored on demand
+
+Runs of the same profile never overlap. Sync-mode and pipeline profiles are
+left out. Each run is logged as it finishes and recorded for relay status
+and relay history.
+
+The daemon listens on a control socket, by default relay/daemon.sock in the
+user cache directory, through which relay daemon status, sync and stop, and
+relay ctl, talk to it. It runs until stopped with relay daemon stop, Ctrl+C
+or SIGTERM; running mirrors are cancelled and cleaned up first. Run it under
+a service manager, or in the background with &, to keep it going after
+logging out.
+
+Examples:
+  relay daemon                             # Profiles in the default config
+  relay daemon --config backups.jsonc      # Profiles in a specific file
+  relay daemon photos documents            # Just these profiles
+  relay daemon status                      # What the daemon is doing
+  relay daemon sync nas                    # Mirror nas now
+  relay daemon stop                        # Shut the daemon down`,
+	Args:              cobra.ArbitraryArgs,
+	ValidArgsFunction: completeProfileArgs,
+	RunE:              runDaemon,
+}
+
+var daemonStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show what a running daemon is doing",
+	Long: `Ask the daemon listening on the control socket for its profiles: what
+triggers each, the progress of the runs going now and how the last run of
+each went.
+
+Examples:
+  relay daemon status
+  relay daemon status --socket /run/relay.sock`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		colorEnabled := term.IsTerminal(int(os.Stdout.Fd()))
+
+		response, err := sendControl(cmd, core.ControlRequest{Command: core.ControlStatus})
+		if err != nil {
+			return err
+		}
+
+		if response.Daemon == nil {
+			return errors.New("the daemon sent no status")
+		}
+
+		fmt.Println(display.RenderDaemonState(response.Daemon, time.Now(), colorEnabled))
+
+		return nil
+	},
+}
+
+var daemonSyncCmd = &cobra.Command{
+	Use:   "sync <profile>",
+	Short: "Make a running daemon mirror a profile now",
+	Long: `Ask the daemon listening on the control socket to mirror a profile now,
+whatever normally triggers it. The command returns once the run has started;
+relay daemon status follows its progress.
+
+Examples:
+  relay daemon sync nas`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeOneProfile,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return controlDaemon(cmd, core.ControlRequest{Command: core.ControlSync, Profile: args[0]})
+	},
+}
+
+var daemonStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Shut a running daemon down",
+	Long: `Ask the daemon listening on the control socket to stop. Its running
+mirrors are cancelled and cleaned up before it exits.
+
+Examples:
+  relay daemon stop`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		return controlDaemon(cmd, core.ControlRequest{Command: core.ControlStop})
+	},
+}
+
+// daemon is the state of a running relay daemon.
+type daemon struct {
+	configPath string
+	started    time.Time
+	jobs       []*daemonJob
+	scheduler  *cron.Cron
+	stop       context.CancelFunc
+	wg         sync.WaitGroup // watch loops and runs started over the control socket
+}
+
+// daemonJob is a profile relay daemon keeps in sync.
+type daemonJob struct {
+	*profileMirror
+
+	trigger string       // schedule, watch or manual
+	entry   cron.EntryID // for schedule
+	paused  atomic.Bool
+	missed  atomic.Bool // a run was skipped while paused
+}
+
+// Run mirrors the profile once, unless it is paused; a run skipped then is
+// made up for when the profile is resumed.
+func (j *daemonJob) Run() {
+	if j.paused.Load() {
+		logger.Info("mirror skipped, profile paused", "profile", j.name)
+		j.missed.Store(true)
+		j.statusRenderer.PrintInfo(j.name + ": skipped, paused")
+
+		return
+	}
+
+	j.profileMirror.Run()
+}
+
+// runDaemon keeps the profiles of the config file named in args, or all of
+// them, in sync until stopped.
+func runDaemon(cmd *cobra.Command, args []string) error {
+	colorEnabled := term.IsTerminal(int(os.Stdout.Fd()))
+	statusRenderer := display.NewStatusRenderer(colorEnabled, false)
+
+	configPath, cfg, err := loadProfilesConfig()
+	if err != nil {
+		return err
+	}
+
+	if configPath == "" {
+		return errors.New("relay daemon needs a config file with profiles to keep in sync")
+	}
+
+	socketPath, err := controlSocketPath()
+	if err != nil {
+		return err
+	}
+
+	auditLog, err := openAuditLog()
+	if err != nil {
+		return err
+	}
+
+	defer closeAuditLog(auditLog, statusRenderer)
+
+	events, err := openEventSocket()
+	if err != nil {
+		return err
+	}
+
+	defer closeEventSocket(events, statusRenderer)
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	d := &daemon{configPath: statusConfigPath(), started: time.Now(), scheduler: cron.New(), stop: stop}
+
+	names := args
+	if len(names) == 0 {
+		names = display.ProfileNames(cfg)
+	}
+
+	for _, name := range names {
+		prof := profileNamed(cfg, name)
+		if prof == nil {
+			return fmt.Errorf("profile %s not found", name)
+		}
+
+		trigger := daemonTrigger(prof)
+
+		switch {
+		case trigger == "" && len(args) > 0:
+			return fmt.Errorf("profile %s cannot be kept in sync by the daemon: it needs a source and destination, "+
+				"and mirror or watch mode", name)
+		case trigger == "":
+			continue
+		}
+
+		job := &daemonJob{
+			profileMirror: &profileMirror{
+				ctx:            ctx,
+				command:        "daemon",
+				name:           name,
+				profile:        prof,
+				statusRenderer: statusRenderer,
+				colorEnabled:   colorEnabled,
+				auditLog:       auditLog,
+				events:         events,
+			},
+			trigger: trigger,
+		}
+
+		if trigger == "schedule" {
+			if job.entry, err = d.scheduler.AddJob(prof.Schedule, job); err != nil {
+				return fmt.Errorf("invalid schedule for profile %s: %w", name, err)
+			}
+		}
+
+		d.jobs = append(d.jobs, job)
+	}
+
+	if len(d.jobs) == 0 {
+		return fmt.Errorf("no profile in %s has a source and destination to mirror", configPath)
+	}
+
+	cmd.SilenceUsage = true
+
+	for _, job := range d.jobs {
+		release, err := registerDaemon(job.name, "daemon")
+		if err != nil {
+			return err
+		}
+
+		defer releaseDaemon(release, statusRenderer)
+	}
+
+	server, err := core.ListenControl(socketPath, d.handle)
+	if err != nil {
+		return err
+	}
+
+	d.scheduler.Start()
+
+	for _, job := range d.jobs {
+		d.describe(job)
+
+		if job.trigger == "watch" {
+			d.wg.Add(1)
+
+			go d.watch(ctx, job)
+		}
+	}
+
+	statusRenderer.PrintInfo("Listening for commands", socketPath)
+
+	<-ctx.Done()
+
+	statusRenderer.PrintInfo("Stopping daemon; waiting for running mirrors to stop")
+
+	// No more runs can be started over the socket once it is closed.
+	if err := server.Close(); err != nil {
+		statusRenderer.PrintWarning("Failed to close control socket", err.Error())
+	}
+
+	<-d.scheduler.Stop().Done()
+	d.wg.Wait()
+
+	return nil
+}
+
+// daemonTrigger returns what makes relay daemon mirror prof: schedule,
+// watch or manual, or "" when the daemon leaves it out.
+func daemonTrigger(prof *config.Profile) string {
+	switch {
+	case prof.Source == "" || prof.Destination == "" || len(prof.Pipeline) > 0 || prof.Mode == string(config.ModeSync):
+		return ""
+	case prof.Watch || prof.Mode == string(config.ModeWatch):
+		return "watch"
+	case prof.Schedule != "":
+		return "schedule"
+	default:
+		return "manual"
+	}
+}
+
+// describe prints the paths of job and what triggers it.
+func (d *daemon) describe(job *daemonJob) {
+	paths := fmt.Sprintf("%s: %s → %s", job.name, job.profile.Source, job.profile.Destination)
+
+	switch job.trigger {
+	case "schedule":
+		describeSchedule(job.statusRenderer, job.name, job.profile, d.scheduler.Entry(job.entry).Next)
+	case "watch":
+		job.statusRenderer.PrintInfo(paths, "mirrored whenever the source changes")
+	default:
+		job.statusRenderer.PrintInfo(paths, "mirrored on demand: relay daemon sync "+job.name)
+	}
+}
+
+// handle answers a command sent to the control socket.
+func (d *daemon) handle(request core.ControlRequest) core.ControlResponse {
+	logger.Debug("control request", "command", request.Command, "profile", request.Profile)
+
+	switch request.Command {
+	case core.ControlStatus:
+		state := &core.DaemonState{PID: os.Getpid(), Config: d.configPath, Started: d.started}
+
+		for _, job := range d.jobs {
+			profile := job.state()
+			profile.Trigger = job.trigger
+			profile.Paused = job.paused.Load()
+
+			if job.trigger == "schedule" {
+				profile.Schedule = job.profile.Schedule
+				profile.Next = d.scheduler.Entry(job.entry).Next
+			}
+
+			state.Profiles = append(state.Profiles, profile)
+		}
+
+		return core.ControlResponse{Daemon: state}
+	case core.ControlSync:
+		job := d.job(request.Profile)
+
+		switch {
+		case job == nil:
+			return d.unknownProfile(request.Profile)
+		case job.running.Load():
+			return core.ControlResponse{Error: fmt.Sprintf("profile %s is already being mirrored", job.name)}
+		}
+
+		// Asked for by name, so a paused profile is mirrored too.
+		d.start(job.profileMirror)
+
+		return core.ControlResponse{Message: "Started mirroring " + job.name}
+	case core.ControlPause, core.ControlResume:
+		return d.pause(request.Profile, request.Command == core.ControlPause)
+	case core.ControlStop:
+		d.stop()
+
+		return core.ControlResponse{Message: "Daemon stopping"}
+	default:
+		return core.ControlResponse{Error: fmt.Sprintf("unknown command %q", request.Command)}
+	}
+}
+
+// pause pauses or resumes the profile called name, or every profile when
+// name is empty. A resumed profile that missed a run while paused is mirrored
+// straight away.
+func (d *daemon) pause(name string, pause bool) core.ControlResponse {
+	jobs := d.jobs
+
+	if name != "" {
+		job := d.job(name)
+		if job == nil {
+			return d.unknownProfile(name)
+		}
+
+		jobs = []*daemonJob{job}
+	}
+
+	var changed []string
+
+	for _, job := range jobs {
+		if job.paused.Swap(pause) == pause {
+			continue
+		}
+
+		changed = append(changed, job.name)
+
+		if pause {
+			job.statusRenderer.PrintInfo(job.name + ": paused")
+			continue
+		}
+
+		job.statusRenderer.PrintInfo(job.name + ": resumed")
+
+		if job.missed.Swap(false) && !job.running.Load() {
+			d.start(job)
+		}
+	}
+
+	verb, state := "Resumed", "running"
+	if pause {
+		verb, state = "Paused", "paused"
+	}
+
+	switch {
+	case len(changed) == 0 && name != "":
+		return core.ControlResponse{Message: fmt.Sprintf("Profile %s is already %s", name, state)}
+	case len(changed) == 0:
+		return core.ControlResponse{Message: "Every profile is already " + state}
+	default:
+		return core.ControlResponse{Message: verb + " " + strings.Join(changed, ", ")}
+	}
+}
+
+// start runs job in the background.
+func (d *daemon) start(job cron.Job) {
+	d.wg.Add(1)
+
+	go func() {
+		defer d.wg.Done()
+		job.Run()
+	}()
+}
+
+// unknownProfile is the response to a command naming a profile the daemon
+// does not keep in sync.
+func (d *daemon) unknownProfile(name string) core.ControlResponse {
+	names := make([]string, len(d.jobs))
+	for i, job := range d.jobs {
+		names[i] = job.name
+	}
+
+	return core.ControlResponse{Error: fmt.Sprintf("the daemon does not keep profile %s in sync; profiles: %s",
+		name, strings.Join(names, ", "))}
+}
+
+// job returns the job of the profile called name, or nil.
+func (d *daemon) job(name string) *daemonJob {
+	for _, job := range d.jobs {
+		if job.name == name {
+			return job
+		}
+	}
+
+	return nil
+}
+
+// watch mirrors the profile of job once, then again whenever its source
+// has settled after changing, until ctx is done.
+func (d *daemon) watch(ctx context.Context, job *daemonJob) {
+	defer d.wg.Done()
+
+	source, err := filepath.Abs(job.profile.Source)
+	if err != nil {
+		job.statusRenderer.PrintError(job.name+": invalid source path", err.Error())
+		return
+	}
+
+	destination, _ := filepath.Abs(job.profile.Destination)
+
+	watcher, err := core.NewFileWatcher(0)
+	if err != nil {
+		job.statusRenderer.PrintError(job.name+": cannot watch the source", err.Error())
+		return
+	}
+
+	watcher.SetLogger(logger.With("profile", job.name))
+
+	if err := watchTree(watcher, source); err != nil {
+		job.statusRenderer.PrintWarning(job.name+": some changes to the source will be missed", err.Error(),
+			"→ relay doctor checks the inotify watch limit")
+	}
+
+	if err := watcher.Start(ctx); err != nil {
+		job.statusRenderer.PrintError(job.name+": cannot watch the source", err.Error())
+		return
+	}
+
+	defer func() { _ = watcher.Stop() }()
+
+	// Mirror once at start, to catch up with changes made while stopped.
+	settle := time.NewTimer(0)
+	defer settle.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-watcher.Events():
+			if !ok {
+				return
+			}
+
+			// The mirror's own writes, when the destination is inside the
+			// source, are not changes to act on.
+			if event.Path == destination || strings.HasPrefix(event.Path, destination+string(filepath.Separator)) {
+				continue
+			}
+
+			if event.Type == core.ChangeCreate && event.Info != nil && event.Info.IsDir {
+				_ = watchTree(watcher, event.Path)
+			}
+
+			settle.Reset(watchSettle)
+		case err, ok := <-watcher.Errors():
+			if ok {
+				job.statusRenderer.PrintWarning(job.name+": watcher error", err.Error())
+			}
+		case <-settle.C:
+			if ctx.Err() == nil {
+				job.Run()
+			}
+		}
+	}
+}
+
+// watchTree adds root and every directory below it to watcher, returning
+// the first directory that could not be added.
+func watchTree(watcher *core.FileWatcher, root string) error {
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return nil
+		}
+
+		return watcher.Add(path)
+	})
+}
+
+// controlSocketPath returns the control socket given by --socket, or the
+// default one.
+func controlSocketPath() (string, error) {
+	if controlSocket != "" {
+		return controlSocket, nil
+	}
+
+	return core.DefaultControlPath()
+}
+
+// sendControl sends request to the daemon on the control socket.
+func sendControl(cmd *cobra.Command, request core.ControlRequest) (*core.ControlResponse, error) {
+	socketPath, err := controlSocketPath()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd.SilenceUsage = true
+
+	return core.SendControl(socketPath, request)
+}
+
+// controlDaemon sends request to the daemon on the control socket and
+// prints what it did.
+func controlDaemon(cmd *cobra.Command, request core.ControlRequest) error {
+	colorEnabled := term.IsTerminal(int(os.Stdout.Fd()))
+
+	response, err := sendControl(cmd, request)
+	if err != nil {
+		return err
+	}
+
+	display.NewStatusRenderer(colorEnabled, false).PrintSuccess(response.Message)
+
+	return nil
+}
+
+func init() {
+	daemonCmd.PersistentFlags().StringVar(&controlSocket, "socket", "", "control socket path (default: relay/daemon.sock in the user cache directory)")
+
+	daemonCmd.AddCommand(daemonStatusCmd)
+	daemonCmd.AddCommand(daemonSyncCmd)
+	daemonCmd.AddCommand(daemonStopCmd)
+	rootCmd.AddCommand(daemonCmd)
+}
@@ -0,0 +1,197 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/howmanysmall/relay/src/internal/config"
+	"github.com/howmanysmall/relay/src/internal/core"
+	"github.com/howmanysmall/relay/src/internal/display"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	dedupeReport   bool
+	dedupeHardlink bool
+	dedupeMinSize  string
+	dedupeTop      int
+	dedupeJSON     bool
+)
+
+var dedupeCmd = &cobra.Command{
+	Use:   "dedupe [directory...]",
+	Short: "Find duplicate files, and optionally replace them with hard links",
+	Long: `Scan directories for files with identical content and report the space the
+extra copies waste. Files are first listed without being read; only files that
+share their size with another are hashed, with blake3.
+
+With --hardlink, every copy in a group is replaced with a hard link to the
+first path in it, freeing the space. Each link is made under a temporary name
+and renamed over the copy, so no copy is lost if relay is interrupted. A copy
+modified since the scan, or whose permissions differ from the first file's, is
+skipped: linked files share their permissions, owner and modification time.
+Copies on another filesystem than the first file cannot be linked and are
+reported. Combine with --dry-run to see what would be linked.
+
+Without a directory, the source of the profile chosen with --profile is
+scanned, or the current directory when it has none. Several directories are
+scanned separately; duplicates are only found within each.
+
+Examples:
+  relay dedupe ~/Pictures                    # Report duplicates (--report)
+  relay dedupe ~/Pictures --min-size 1MB     # Ignore small files
+  relay dedupe /srv/backups --hardlink       # Free the space
+  relay dedupe /srv/backups --hardlink --dry-run
+  relay dedupe ./build --json                # For scripts`,
+	ValidArgsFunction: completeDirs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		colorEnabled := term.IsTerminal(int(os.Stdout.Fd()))
+
+		if dedupeHardlink && (dedupeReport || dedupeJSON) {
+			return errors.New("--hardlink cannot be combined with --report or --json")
+		}
+
+		var minSize int64
+
+		if dedupeMinSize != "" {
+			size, err := config.ParseSize(dedupeMinSize)
+			if err != nil {
+				return fmt.Errorf("invalid --min-size: %w", err)
+			}
+
+			minSize = size
+		}
+
+		roots := args
+		if len(roots) == 0 {
+			prof, err := loadProfile()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			roots = []string{"."}
+			if prof.Source != "" {
+				roots = []string{prof.Source}
+			}
+		}
+
+		cmd.SilenceUsage = true
+
+		ctx, cancel := runContext(cmd)
+		defer cancel()
+
+		reports := make([]*core.DuplicateReport, 0, len(roots))
+		scanned := time.Now()
+
+		for _, root := range roots {
+			report, err := core.FindDuplicates(ctx, core.NewFileScanner(0), root, minSize)
+			if err != nil {
+				return runTimeoutError(ctx, err)
+			}
+
+			reports = append(reports, report)
+		}
+
+		if dedupeJSON {
+			data, err := json.MarshalIndent(reports, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to encode duplicates: %w", err)
+			}
+
+			fmt.Println(string(data))
+
+			return nil
+		}
+
+		var unreadable int
+
+		for i, report := range reports {
+			if !dedupeHardlink {
+				if i > 0 {
+					fmt.Println()
+				}
+
+				fmt.Println(display.RenderDuplicates(report, dedupeTop, colorEnabled))
+			}
+
+			unreadable += report.Unreadable
+		}
+
+		if dedupeHardlink {
+			if failed := linkDuplicates(reports, scanned, display.NewStatusRenderer(colorEnabled, false)); failed > 0 {
+				return fmt.Errorf("%d duplicate(s) could not be linked", failed)
+			}
+		}
+
+		if unreadable > 0 {
+			return errors.New("some entries could not be read and were not compared")
+		}
+
+		return nil
+	},
+}
+
+// linkDuplicates replaces the copies in every group of reports with hard
+// links, or only reports them on a dry run, and returns the number of copies
+// that could not be linked.
+func linkDuplicates(reports []*core.DuplicateReport, scanned time.Time, statusRenderer *display.StatusRenderer) int {
+	verb, freedNote := "Linked", " freed"
+	if dryRun {
+		verb, freedNote = "Would link", " would be freed"
+	}
+
+	var (
+		linked, skipped, failed int
+		freed                   int64
+	)
+
+	for _, report := range reports {
+		for i := range report.Groups {
+			group := &report.Groups[i]
+
+			result := core.LinkDuplicates(group, scanned, dryRun)
+
+			if verbose || dryRun {
+				for _, path := range result.Linked {
+					statusRenderer.PrintProgress(verb+" "+path, "to "+group.Paths[0])
+				}
+			}
+
+			for _, path := range result.Skipped {
+				statusRenderer.PrintWarning("Skipped "+path, "changed since the scan, or its permissions differ from "+group.Paths[0])
+			}
+
+			for _, err := range result.Errors {
+				statusRenderer.PrintError("Cannot link duplicate", err.Error())
+			}
+
+			linked += len(result.Linked)
+			skipped += len(result.Skipped)
+			failed += len(result.Errors)
+			freed += result.Freed
+		}
+	}
+
+	details := []string{display.FormatBytes(freed) + freedNote}
+	if skipped > 0 {
+		details = append(details, display.CountOf(int64(skipped), "copy", "copies")+" skipped")
+	}
+
+	statusRenderer.PrintSuccess(fmt.Sprintf("%s %s", verb, display.CountOf(int64(linked), "duplicate", "duplicates")), details...)
+
+	return failed
+}
+
+func init() {
+	dedupeCmd.Flags().BoolVar(&dedupeReport, "report", false, "only report the duplicates and the space they waste (the default)")
+	dedupeCmd.Flags().BoolVar(&dedupeHardlink, "hardlink", false, "replace duplicates with hard links to the first copy")
+	dedupeCmd.Flags().StringVar(&dedupeMinSize, "min-size", "", "ignore files smaller than this (e.g., '4KB', '1MB')")
+	dedupeCmd.Flags().IntVar(&dedupeTop, "top", 0, "how many groups to list, most wasted space first (0 = all)")
+	dedupeCmd.Flags().BoolVar(&dedupeJSON, "json", false, "print the duplicates as JSON")
+
+	rootCmd.AddCommand(dedupeCmd)
+}
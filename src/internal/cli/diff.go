@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/howmanysmall/relay/src/internal/core"
+	"github.com/howmanysmall/relay/src/internal/display"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	diffChecksum bool
+	diffJSON     bool
+)
+
+// errTreesDiffer is returned by relay diff when it finds differences, so that
+// scripts can tell from the exit code.
+var errTreesDiffer = errors.New("trees differ")
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <a> <b>",
+	Short: "Compare two directory trees without changing either",
+	Long: `List the paths that are only in a, only in b, or in both but different.
+Files are compared by size and modification time, as mirror does, unless
+--checksum compares their content instead. A directory only in one tree is
+listed once, without its contents. The profile's filters apply to both
+trees.
+
+relay diff exits with status 0 when the trees match and 1 when they differ.
+
+Examples:
+  relay diff ./src ./backup                # Compare by size and time
+  relay diff ./src ./backup --checksum     # Compare file content
+  relay diff ./src ./backup --json         # Machine-readable report`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeDirPair,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		a, err := filepath.Abs(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid path a: %w", err)
+		}
+
+		b, err := filepath.Abs(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid path b: %w", err)
+		}
+
+		colorEnabled := term.IsTerminal(int(os.Stdout.Fd()))
+		statusRenderer := display.NewStatusRenderer(colorEnabled, false)
+
+		engine, err := createSyncEngine()
+		if err != nil {
+			return fmt.Errorf("failed to create sync engine: %w", err)
+		}
+
+		opts := engine.Options()
+		opts.ChecksumVerify = diffChecksum
+		opts.ModifyWindow = modifyWindow
+		engine.SetOptions(opts)
+
+		ctx, cancel := runContext(cmd)
+		defer cancel()
+
+		diff, err := engine.DiffTrees(ctx, a, b)
+		if err != nil {
+			return runError(engine, runTimeoutError(ctx, err))
+		}
+
+		// A difference is an answer, not a misuse of the command.
+		cmd.SilenceUsage = true
+
+		unread := engine.GetErrors()
+
+		if diffJSON {
+			report := struct {
+				*core.TreeDiff
+				Errors []string `json:"errors,omitempty"` // entries that could not be read
+			}{TreeDiff: diff}
+
+			for _, syncErr := range unread {
+				report.Errors = append(report.Errors, syncErr.Error())
+			}
+
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to encode diff: %w", err)
+			}
+
+			fmt.Println(string(data))
+		} else {
+			fmt.Printf("A: %s\nB: %s\n\n", a, b)
+			fmt.Println(display.RenderTreeDiff(diff, colorEnabled))
+
+			if len(unread) > 0 {
+				details := make([]string, len(unread))
+				for i, syncErr := range unread {
+					details[i] = syncErr.Error()
+				}
+
+				statusRenderer.PrintWarning(fmt.Sprintf("%d entries could not be read and were not compared", len(unread)),
+					details...)
+			}
+		}
+
+		if len(unread) > 0 {
+			return runError(engine, nil)
+		}
+
+		if len(diff.Differences) > 0 {
+			return &ExitError{Code: exitCodeFailure, Err: errTreesDiffer}
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	diffCmd.Flags().BoolVar(&diffChecksum, "checksum", false, "compare file content by checksum instead of size and modification time")
+	diffCmd.Flags().BoolVar(&diffJSON, "json", false, "print the differences as JSON")
+	diffCmd.Flags().DurationVar(&modifyWindow, "modify-window", 0, "treat modification times within this window as equal (e.g., '2s')")
+
+	rootCmd.AddCommand(diffCmd)
+}
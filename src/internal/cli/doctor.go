@@ -0,0 +1,330 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/howmanysmall/relay/src/internal/config"
+	"github.com/howmanysmall/relay/src/internal/core"
+	"github.com/howmanysmall/relay/src/internal/display"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// doctorHashSize is how much data relay doctor hashes to measure checksum
+// throughput.
+const doctorHashSize = 64 << 20
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the environment relay runs in and suggest fixes",
+	Long: `Check the configuration file, the source and destination of the profile
+selected with --profile, and the machine, then print what was found with a
+suggestion for each problem:
+
+  - whether the config file is valid
+  - the file system of the source and destination, and whether it is on the
+    network or keeps coarse modification times
+  - free space on the destination against the size of the source
+  - whether zero-copy and reflink copies work in the destination
+  - whether the inotify watch limit covers the source's directories
+  - how fast the profile's checksum algorithm hashes
+
+The copy check writes and removes two small temporary files in the
+destination. relay doctor exits with status 1 when it finds a problem;
+warnings alone do not change the status.
+
+Examples:
+  relay doctor                             # Check the default profile
+  relay doctor --profile nas               # Check a named profile`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		colorEnabled := term.IsTerminal(int(os.Stdout.Fd()))
+		cmd.SilenceUsage = true
+
+		d := &doctor{statusRenderer: display.NewStatusRenderer(colorEnabled, false)}
+
+		fmt.Printf("🩺 Relay Doctor\n\n")
+
+		d.checkConfig()
+
+		prof, err := loadProfile()
+		if err != nil {
+			d.fail("Cannot load profile", err.Error())
+		} else {
+			d.checkProfile(prof)
+		}
+
+		fmt.Println()
+
+		if d.problems > 0 {
+			return fmt.Errorf("found %s and %s", display.CountOf(int64(d.problems), "problem", "problems"),
+				display.CountOf(int64(d.warnings), "warning", "warnings"))
+		}
+
+		d.statusRenderer.PrintSuccess("No problems found, " + display.CountOf(int64(d.warnings), "warning", "warnings"))
+
+		return nil
+	},
+}
+
+// doctor prints the findings of relay doctor and counts the warnings and
+// problems among them.
+type doctor struct {
+	statusRenderer *display.StatusRenderer
+	warnings       int
+	problems       int
+}
+
+func (d *doctor) ok(message string, details ...string) {
+	d.statusRenderer.PrintSuccess(message, details...)
+}
+
+func (d *doctor) info(message string, details ...string) {
+	d.statusRenderer.PrintInfo(message, details...)
+}
+
+func (d *doctor) warn(message string, details ...string) {
+	d.warnings++
+	d.statusRenderer.PrintWarning(message, details...)
+}
+
+func (d *doctor) fail(message string, details ...string) {
+	d.problems++
+	d.statusRenderer.PrintError(message, details...)
+}
+
+// checkConfig validates the config file relay would load.
+func (d *doctor) checkConfig() {
+	loader := config.NewLoader()
+
+	configPath := configFile
+	if configPath == "" {
+		configPath = loader.FindConfig()
+	}
+
+	if configPath == "" {
+		d.info("No config file; the built-in defaults are used", "→ relay init writes one")
+		return
+	}
+
+	issues, err := loader.Check(configPath)
+	if err != nil {
+		d.fail("Cannot read config", err.Error())
+		return
+	}
+
+	var errs, warnings []string
+
+	for _, issue := range issues {
+		if issue.Severity == config.SeverityError {
+			errs = append(errs, issue.String())
+		} else {
+			warnings = append(warnings, issue.String())
+		}
+	}
+
+	switch {
+	case len(errs) > 0:
+		d.fail(fmt.Sprintf("Config %s has %s", configPath, display.CountOf(int64(len(errs)), "error", "errors")),
+			append(errs, "→ relay validate "+configPath+" shows them all")...)
+	case len(warnings) > 0:
+		d.warn(fmt.Sprintf("Config %s is valid, with %s", configPath, display.CountOf(int64(len(warnings)), "warning", "warnings")),
+			warnings...)
+	default:
+		d.ok(fmt.Sprintf("Config %s is valid", configPath))
+	}
+}
+
+// checkProfile checks the source and destination of prof and the machine.
+func (d *doctor) checkProfile(prof *config.Profile) {
+	var tree *treeSize
+
+	if prof.Source == "" {
+		d.info("The profile has no source; source checks skipped")
+	} else {
+		tree = d.checkSource(prof.Source)
+	}
+
+	if prof.Destination == "" {
+		d.info("The profile has no destination; destination checks skipped")
+	} else {
+		d.checkDestination(prof, tree)
+	}
+
+	if tree != nil {
+		d.checkWatchLimit(tree)
+	}
+
+	d.checkChecksums(prof, tree)
+}
+
+// treeSize counts what a directory tree holds.
+type treeSize struct {
+	files int64
+	dirs  int64
+	bytes int64
+}
+
+// checkSource reports the size and file system of source and returns its
+// size, or nil when it cannot be read.
+func (d *doctor) checkSource(source string) *treeSize {
+	tree := &treeSize{}
+
+	err := filepath.WalkDir(source, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			tree.dirs++
+			return nil
+		}
+
+		if info, err := entry.Info(); err == nil {
+			tree.files++
+			tree.bytes += info.Size()
+		}
+
+		return nil
+	})
+	if err != nil {
+		d.fail("Cannot read source "+source, err.Error())
+		return nil
+	}
+
+	d.ok(fmt.Sprintf("Source %s holds %s in %s, %s", source, display.CountOf(tree.files, "file", "files"),
+		display.CountOf(tree.dirs, "directory", "directories"), display.FormatBytes(tree.bytes)))
+	d.checkFileSystem("Source", source)
+
+	return tree
+}
+
+// checkDestination reports the file system of the destination of prof, the
+// space free on it and the fast copy paths that work there.
+func (d *doctor) checkDestination(prof *config.Profile, tree *treeSize) {
+	destination := prof.Destination
+
+	fileSystem := d.checkFileSystem("Destination", destination)
+
+	if fileSystem != nil && fileSystem.Free >= 0 {
+		free := fmt.Sprintf("%s free of %s", display.FormatBytes(fileSystem.Free), display.FormatBytes(fileSystem.Total))
+
+		if tree != nil && fileSystem.Free < tree.bytes {
+			d.warn("The source may not fit in the destination", free+", the source holds "+display.FormatBytes(tree.bytes),
+				"→ free some space, or exclude large files with filters.maxFileSize")
+		} else {
+			d.ok("Destination space: " + free)
+		}
+	}
+
+	info, err := os.Stat(destination)
+	if err != nil || !info.IsDir() {
+		d.info("The destination does not exist yet; copy checks skipped")
+		return
+	}
+
+	support, err := core.ProbeCopySupport(destination)
+	if err != nil {
+		d.warn("Cannot check copy support in the destination", err.Error())
+		return
+	}
+
+	reflink := "the file system cannot clone files (no reflinks)"
+	if support.Reflink {
+		reflink = "the file system can clone files (reflinks)"
+	}
+
+	switch {
+	case support.ZeroCopy == "":
+		d.info("Zero-copy is not available; files are copied through a buffer", reflink)
+	case prof.Performance != nil && !prof.Performance.UseZeroCopy:
+		d.warn("Zero-copy ("+support.ZeroCopy+") works but the profile turns it off", reflink,
+			"→ set performance.useZeroCopy to true to copy without user-space buffers")
+	default:
+		d.ok("Zero-copy ("+support.ZeroCopy+") works in the destination", reflink)
+	}
+}
+
+// checkFileSystem reports the file system holding path, warning about
+// network and FAT file systems, and returns it, or nil when unknown.
+func (d *doctor) checkFileSystem(label, path string) *core.FileSystem {
+	fileSystem, err := core.StatFileSystem(path)
+	if err != nil {
+		d.warn("Cannot identify the file system of "+path, err.Error())
+		return nil
+	}
+
+	switch {
+	case fileSystem.Network:
+		d.warn(fmt.Sprintf("%s file system is %s, on the network", label, fileSystem.Type),
+			"→ set --file-timeout or performance.networkTimeout so a stalled mount cannot hang a run",
+			"→ fewer --workers often copy faster over the network")
+	case fileSystem.CoarseTimes:
+		d.warn(fmt.Sprintf("%s file system is %s, which keeps modification times to 2 seconds", label, fileSystem.Type),
+			"→ use --modify-window 2s so unchanged files are not copied again")
+	default:
+		d.ok(fmt.Sprintf("%s file system is %s", label, fileSystem.Type))
+	}
+
+	return fileSystem
+}
+
+// checkWatchLimit compares the inotify watch limit with the directories of
+// the source, each of which watch mode watches.
+func (d *doctor) checkWatchLimit(tree *treeSize) {
+	limit, ok := core.InotifyWatchLimit()
+	if !ok {
+		return
+	}
+
+	if tree.dirs > int64(limit) {
+		d.warn(fmt.Sprintf("Watch mode needs %d inotify watches, but the limit is %d", tree.dirs, limit),
+			fmt.Sprintf("→ sudo sysctl fs.inotify.max_user_watches=%d", tree.dirs*2),
+			"→ add the setting to /etc/sysctl.d/ to keep it after a reboot")
+
+		return
+	}
+
+	d.ok(fmt.Sprintf("The inotify watch limit of %d covers the source's %d directories", limit, tree.dirs))
+}
+
+// checkChecksums measures how fast the checksum algorithm of prof hashes
+// and estimates how long verifying the source takes.
+func (d *doctor) checkChecksums(prof *config.Profile, tree *treeSize) {
+	algo := "blake3"
+	if prof.Performance != nil && prof.Performance.ChecksumAlgo != "" {
+		algo = prof.Performance.ChecksumAlgo
+	}
+
+	rate, err := core.ChecksumThroughput(algo, doctorHashSize)
+	if errors.Is(err, core.ErrUnsupportedChecksum) {
+		d.fail("Unknown checksum algorithm "+algo, fmt.Sprintf("→ use one of %v", core.ChecksumAlgorithms()))
+		return
+	}
+
+	var details []string
+
+	if tree != nil {
+		if estimate := time.Duration(float64(tree.bytes) / rate * float64(time.Second)); estimate >= time.Second {
+			details = append(details, fmt.Sprintf("hashing the source takes at least %v", estimate.Round(time.Second)))
+		}
+	}
+
+	if algo != "blake3" {
+		if fastest, err := core.ChecksumThroughput("blake3", doctorHashSize); err == nil && fastest > rate*1.5 {
+			details = append(details, fmt.Sprintf("→ blake3 hashes at %s here; set performance.checksumAlgo to use it",
+				display.FormatSpeed(int64(fastest))))
+		}
+	}
+
+	d.ok(fmt.Sprintf("%s hashes at %s in memory", algo, display.FormatSpeed(int64(rate))), details...)
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
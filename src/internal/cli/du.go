@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/howmanysmall/relay/src/internal/core"
+	"github.com/howmanysmall/relay/src/internal/display"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	duTop   int
+	duDepth int
+	duJSON  bool
+)
+
+var duCmd = &cobra.Command{
+	Use:   "du [directory...]",
+	Short: "Show how much data a directory holds, and where",
+	Long: `Scan directories in parallel, without reading any file, and show their
+total size and file count, their largest files and directories, and how the
+size divides by file extension: an estimate of what a first mirror of them
+copies.
+
+Without a directory, the source of the profile chosen with --profile is
+measured, or the current directory when it has none. Sizes are apparent
+sizes, the sum of the files' lengths, and hard-linked files count once per
+link. --depth sets how many levels of directories below each root are
+ranked; --json prints every measurement for scripts.
+
+Examples:
+  relay du ~/Pictures                      # Size of a directory
+  relay du --profile nas                   # What the nas profile mirrors
+  relay du /srv --depth 2 --top 20         # Rank directories two levels deep
+  relay du ./build --json                  # For scripts`,
+	ValidArgsFunction: completeDirs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		colorEnabled := term.IsTerminal(int(os.Stdout.Fd()))
+
+		if duDepth < 1 {
+			return fmt.Errorf("invalid --depth %d: must be at least 1", duDepth)
+		}
+
+		roots := args
+		if len(roots) == 0 {
+			prof, err := loadProfile()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			roots = []string{"."}
+			if prof.Source != "" {
+				roots = []string{prof.Source}
+			}
+		}
+
+		cmd.SilenceUsage = true
+
+		ctx, cancel := runContext(cmd)
+		defer cancel()
+
+		usages := make([]*core.DiskUsage, 0, len(roots))
+
+		for _, root := range roots {
+			usage, err := core.MeasureDiskUsage(ctx, root, duDepth, duTop)
+			if err != nil {
+				return runTimeoutError(ctx, err)
+			}
+
+			usages = append(usages, usage)
+		}
+
+		if duJSON {
+			data, err := json.MarshalIndent(usages, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to encode disk usage: %w", err)
+			}
+
+			fmt.Println(string(data))
+
+			return nil
+		}
+
+		var unreadable int
+
+		for i, usage := range usages {
+			if i > 0 {
+				fmt.Println()
+			}
+
+			fmt.Println(display.RenderDiskUsage(usage, colorEnabled))
+
+			unreadable += usage.Unreadable
+		}
+
+		if unreadable > 0 {
+			return errors.New("some entries could not be read; the totals leave them out")
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	duCmd.Flags().IntVar(&duTop, "top", 10, "how many of the largest files, directories and extensions to list (0 = all)")
+	duCmd.Flags().IntVar(&duDepth, "depth", 1, "how many levels of directories below each root to rank")
+	duCmd.Flags().BoolVar(&duJSON, "json", false, "print the measurements as JSON")
+
+	rootCmd.AddCommand(duCmd)
+}
@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/howmanysmall/relay/src/internal/core"
+)
+
+// Exit codes. A failed run whose errors were classified exits with
+// exitCodeCategoryBase plus the dominant core.ErrorCategory:
+//
+//	10 unknown, 11 network, 12 permission, 13 disk,
+//	14 corruption, 15 configuration, 16 cancellation
+//
+// A config file or profile that cannot be used exits with the configuration
+// code before anything runs. A run stopped by --timeout, or whose plan was
+// declined at the --confirm prompt, counts as cancelled; one interrupted with
+// Ctrl+C exits with 130, as a shell reports a command killed by SIGINT.
+// relay schedule and relay daemon also stop their runs on SIGTERM, which
+// exits with 130 too.
+const (
+	exitCodeFailure       = 1
+	exitCodeCategoryBase  = 10
+	exitCodeConfiguration = exitCodeCategoryBase + int(core.ErrorCategoryConfiguration)
+	exitCodeInterrupted   = 130
+)
+
+// ExitError carries the process exit code for a failed command.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode returns the process exit code for an error returned by Execute.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+
+	return exitCodeFailure
+}
+
+// configError marks err, about the config file or the settings of a profile,
+// to exit with the configuration code.
+func configError(err error) error {
+	return &ExitError{Code: exitCodeConfiguration, Err: err}
+}
+
+// runError turns the outcome of a run into an *ExitError whose code reflects
+// the dominant error category. It returns nil only when err is nil and no
+// errors were collected.
+func runError(engine *core.SyncEngine, err error) error {
+	category, hasErrors := engine.DominantErrorCategory()
+	if err == nil && !hasErrors {
+		return nil
+	}
+
+	if err == nil {
+		err = fmt.Errorf("%d errors encountered", engine.GetStats().ErrorsEncountered)
+	}
+
+	code := exitCodeFailure
+
+	switch {
+	case errors.Is(err, errPlanDeclined):
+		code = exitCodeCategoryBase + int(core.ErrorCategoryCancellation)
+	case errors.Is(err, context.Canceled):
+		// Files cut short by the interrupt are collected as cancellation
+		// errors, but the run as a whole was stopped on purpose.
+		code = exitCodeInterrupted
+	case hasErrors:
+		code = exitCodeCategoryBase + int(category)
+	case errors.Is(err, context.DeadlineExceeded):
+		code = exitCodeCategoryBase + int(core.ErrorCategoryCancellation)
+	}
+
+	return &ExitError{Code: code, Err: err}
+}
@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/howmanysmall/relay/src/internal/core"
+	"github.com/howmanysmall/relay/src/internal/display"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	historyLimit int
+	historyPage  int
+	historyJSON  bool
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history [profile...]",
+	Short: "List past runs, newest first",
+	Long: `List the runs of mirror, sync, run, schedule and daemon recorded in the
+run journal, newest first: when each finished, the profile and command, what it
+changed and how many files it could not sync. Dry runs are not recorded.
+
+Runs are shown a page of --limit at a time; --page selects older pages.
+Naming profiles shows only their runs, and --config only the runs made with
+that config file. --json prints the runs of the page as a JSON array, with
+their full statistics.
+
+The journal is kept in the user cache directory and grows with every run;
+delete it to start afresh.
+
+Examples:
+  relay history                            # The 20 latest runs
+  relay history nas --page 2               # Older runs of the nas profile
+  relay history --limit 0 --json           # Every run, for scripts`,
+	ValidArgsFunction: completeProfileArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		colorEnabled := term.IsTerminal(int(os.Stdout.Fd()))
+
+		if historyLimit < 0 {
+			return fmt.Errorf("invalid --limit %d: must not be negative", historyLimit)
+		}
+
+		if historyPage < 1 {
+			return fmt.Errorf("invalid --page %d: pages start at 1", historyPage)
+		}
+
+		cmd.SilenceUsage = true
+
+		path, err := core.DefaultJournalPath()
+		if err != nil {
+			return err
+		}
+
+		runs, err := core.ReadJournal(path)
+		if err != nil {
+			return err
+		}
+
+		configPath := ""
+		if cmd.Flags().Changed("config") {
+			configPath = statusConfigPath()
+		}
+
+		runs = slices.DeleteFunc(runs, func(run core.ProfileStatus) bool {
+			return (len(args) > 0 && !slices.Contains(args, run.Profile)) || (configPath != "" && run.Config != configPath)
+		})
+		slices.Reverse(runs)
+
+		total := len(runs)
+		first, last := 0, total
+
+		if historyLimit > 0 {
+			first = min((historyPage-1)*historyLimit, total)
+			last = min(first+historyLimit, total)
+		}
+
+		page := runs[first:last]
+
+		if historyJSON {
+			if page == nil {
+				page = []core.ProfileStatus{}
+			}
+
+			data, err := json.MarshalIndent(page, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to encode history: %w", err)
+			}
+
+			fmt.Println(string(data))
+
+			return nil
+		}
+
+		switch {
+		case len(page) > 0:
+			fmt.Printf("Runs %d–%d of %d, newest first:\n", first+1, last, total)
+		case total > 0:
+			return fmt.Errorf("page %d is past the last run; %s recorded", historyPage, display.CountOf(int64(total), "run is", "runs are"))
+		}
+
+		fmt.Println(display.RenderHistory(page, time.Now(), colorEnabled))
+
+		if last < total {
+			fmt.Printf("\n→ --page %d shows older runs\n", historyPage+1)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	historyCmd.Flags().IntVar(&historyLimit, "limit", 20, "runs per page; 0 shows every run")
+	historyCmd.Flags().IntVar(&historyPage, "page", 1, "page of runs to show, 1 being the newest")
+	historyCmd.Flags().BoolVar(&historyJSON, "json", false, "print the runs as JSON")
+
+	rootCmd.AddCommand(historyCmd)
+}
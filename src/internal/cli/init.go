@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/howmanysmall/relay/src/internal/config"
+	"github.com/howmanysmall/relay/src/internal/display"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	initDefaults bool
+	initForce    bool
+	initProfiles []string
+)
+
+var initCmd = &cobra.Command{
+	Use:   "init [config-file]",
+	Short: "Create a configuration file",
+	Long: `Ask for the source, destination, mode, conflict strategy and filters of
+the default profile, and of any named profiles, then write a commented
+configuration file. The file is relay.jsonc unless given as an argument or
+with --config; a .toml extension writes TOML, and .yaml YAML.
+
+--defaults writes the built-in defaults without asking anything. --profiles
+adds named profiles that extend the default profile. An existing file is
+only replaced with --force.
+
+Examples:
+  relay init                               # Answer prompts, write relay.jsonc
+  relay init relay.toml                    # Write TOML instead
+  relay init --defaults                    # Write the defaults, no prompts
+  relay init --profiles nas,photos         # Also set up two named profiles`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeOneConfigFile,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		colorEnabled := term.IsTerminal(int(os.Stdout.Fd()))
+		statusRenderer := display.NewStatusRenderer(colorEnabled, false)
+		loader := config.NewLoader()
+
+		configPath := configFile
+		if len(args) == 1 {
+			configPath = args[0]
+		}
+
+		if configPath == "" {
+			configPath = "relay.jsonc"
+		}
+
+		switch ext := strings.ToLower(filepath.Ext(configPath)); ext {
+		case ".json", ".jsonc", ".toml", ".yaml", ".yml":
+		default:
+			return fmt.Errorf("unsupported config format %q: use .jsonc, .json, .toml or .yaml", ext)
+		}
+
+		cmd.SilenceUsage = true
+
+		if _, err := os.Lstat(configPath); err == nil && !initForce {
+			return fmt.Errorf("%s already exists; use --force to replace it", configPath)
+		} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		cfg := loader.Defaults()
+
+		for _, name := range initProfiles {
+			if err := addInitProfile(cfg, name); err != nil {
+				return err
+			}
+		}
+
+		if !initDefaults {
+			save, err := askInitConfig(cfg, display.NewConfigForm(colorEnabled, loader.ValidateProfile), configPath)
+			if err != nil {
+				return err
+			}
+
+			if !save {
+				statusRenderer.PrintInfo("Discarded config", configPath)
+				return nil
+			}
+		}
+
+		if err := config.SaveCommented(cfg, configPath); err != nil {
+			return err
+		}
+
+		statusRenderer.PrintSuccess("Wrote config", configPath)
+
+		return nil
+	},
+}
+
+// addInitProfile adds to cfg a named profile that extends the default one.
+func addInitProfile(cfg *config.Config, name string) error {
+	if name == "" || name == "default" {
+		return fmt.Errorf("invalid profile name %q", name)
+	}
+
+	if _, exists := cfg.Profiles[name]; exists {
+		return fmt.Errorf("profile %s already exists", name)
+	}
+
+	if cfg.Profiles == nil {
+		cfg.Profiles = make(map[string]*config.Profile)
+	}
+
+	cfg.Profiles[name] = &config.Profile{Mode: cfg.Default.Mode, Extends: "default"}
+
+	return nil
+}
+
+// askInitConfig fills in the default profile of cfg and each of its named
+// profiles with form, offers to add more named profiles, and returns whether
+// the user chose to write the result to configPath.
+func askInitConfig(cfg *config.Config, form *display.ConfigForm, configPath string) (bool, error) {
+	if err := form.Fill("default", cfg.Default); err != nil {
+		return false, err
+	}
+
+	for _, name := range initProfiles {
+		if err := form.Fill(name, cfg.Profiles[name]); err != nil {
+			return false, err
+		}
+	}
+
+	for {
+		name, err := form.Ask("Add a named profile", "its name, or Enter to finish")
+		if err != nil {
+			return false, err
+		}
+
+		if name == "" {
+			break
+		}
+
+		if err := addInitProfile(cfg, name); err != nil {
+			fmt.Println(err)
+			continue
+		}
+
+		if err := form.Fill(name, cfg.Profiles[name]); err != nil {
+			return false, err
+		}
+	}
+
+	return form.Confirm("Write " + configPath + "?")
+}
+
+func init() {
+	initCmd.Flags().BoolVar(&initDefaults, "defaults", false, "write the built-in defaults without prompting")
+	initCmd.Flags().BoolVar(&initForce, "force", false, "replace an existing config file")
+	initCmd.Flags().StringSliceVar(&initProfiles, "profiles", nil, "named profiles to add, extending the default profile")
+
+	rootCmd.AddCommand(initCmd)
+}
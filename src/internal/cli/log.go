@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/howmanysmall/relay/src/internal/core"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// logMaxSize is the size at which the --log-file is rotated.
+	logMaxSize = 10 * 1024 * 1024
+
+	// logBackups is how many rotated --log-file files are kept.
+	logBackups = 5
+)
+
+var (
+	logFile  string
+	logLevel string
+)
+
+// logger is where commands, engines and watchers log to: the --log-file, or
+// nowhere without one.
+var logger = slog.New(slog.DiscardHandler)
+
+var logOutput *core.LogFile
+
+// openLog opens the --log-file, if any, at --log-level and makes logger write
+// to it.
+func openLog(cmd *cobra.Command) error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(logLevel)); err != nil || strings.ContainsAny(logLevel, "+-") {
+		return fmt.Errorf("invalid --log-level %q: must be debug, info, warn or error", logLevel)
+	}
+
+	if logFile == "" {
+		if cmd.Flags().Changed("log-level") {
+			return errors.New("--log-level needs --log-file")
+		}
+
+		return nil
+	}
+
+	output, err := core.OpenLogFile(logFile, logMaxSize, logBackups)
+	if err != nil {
+		return err
+	}
+
+	logOutput = output
+	logger = slog.New(slog.NewTextHandler(output, &slog.HandlerOptions{Level: level}))
+	logger.Info("command started", "command", cmd.CommandPath())
+
+	return nil
+}
+
+// closeLog logs how the command ended, with err when it failed, and closes the
+// --log-file.
+func closeLog(err error) {
+	if logOutput == nil {
+		return
+	}
+
+	if err != nil {
+		logger.Error("command failed", "error", err)
+	} else {
+		logger.Info("command finished")
+	}
+
+	_ = logOutput.Close()
+	logOutput = nil
+	logger = slog.New(slog.DiscardHandler)
+}
+
+// logMirror logs how a scheduled or daemon mirror of profile ended.
+func logMirror(profile string, engine *core.SyncEngine, err error) {
+	switch {
+	case err != nil:
+		logger.Error("mirror failed", "profile", profile, "error", err)
+	case engine.GetStats().ErrorsEncountered > 0:
+		logger.Warn("mirror finished with errors", "profile", profile, "errors", engine.GetStats().ErrorsEncountered)
+	default:
+		logger.Info("mirror finished", "profile", profile, "changed", engine.GetStats().FilesChanged)
+	}
+}
@@ -4,12 +4,18 @@
 package cli
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/howmanysmall/relay/src/internal/config"
 	"github.com/howmanysmall/relay/src/internal/core"
 	"github.com/howmanysmall/relay/src/internal/display"
 	"github.com/spf13/cobra"
@@ -17,17 +23,57 @@ import (
 )
 
 var (
-	ifNewer  bool
-	smart    bool
-	turbo    bool
-	gentle   bool
-	since    string
-	filters  []string
-	excludes []string
+	ifNewer          bool
+	smart            bool
+	turbo            bool
+	gentle           bool
+	since            string
+	filters          []string
+	excludes         []string
+	includeFrom      []string
+	excludeFrom      []string
+	noIgnoreFiles    bool
+	includeRegex     []string
+	excludeRegex     []string
+	maxSize          string
+	minSize          string
+	deleteExtraneous bool
+	pruneEmptyDirs   bool
+	deleteOrder      string
+	detectMoves      bool
+	filesFrom        string
+	atomicDir        bool
+	fromArchive      bool
+	modifyWindow     time.Duration
+	doubleCheck      bool
+	appendOnly       bool
+	quarantine       bool
+	verifyAfter      bool
+	blockReport      string
+	maxFiles         int64
+	destSnapshot     bool
+	rescanDest       bool
+	fullScreen       bool
+	progressInterval time.Duration
+	relative         bool
+	rsyncPaths       bool
+	listChanges      bool
+	changesFile      string
+	ignoreCase       bool
+	writeChecksums   string
+	fanOut           bool
+	confirm          bool
+	warnDestNewer    bool
+	protectDestNewer bool
+	noCheckpoint     bool
 )
 
+// errPlanDeclined is returned when the user does not confirm the plan shown
+// by --confirm. It exits with the cancellation code, not as if interrupted.
+var errPlanDeclined = errors.New("plan not confirmed, nothing was changed")
+
 var mirrorCmd = &cobra.Command{
-	Use:   "mirror <source> <destination>",
+	Use:   "mirror <source> <destination> [destination...]",
 	Short: "One-way file mirroring from source to destination",
 	Long: `Mirror files from source to destination directory.
 This is a one-way operation - files are copied from source to destination,
@@ -38,21 +84,81 @@ Examples:
   relay mirror ./src ./dst --if-newer     # Only copy newer files
   relay mirror ./project ./backup --smart # Auto-exclude build artifacts
   relay mirror ./src ./dst --turbo        # Maximum performance mode
-  relay mirror ./docs ./web --since 1h    # Changes in last hour`,
-	Args: cobra.ExactArgs(2),
+  relay mirror ./docs ./web --since 1h    # Changes in last hour
+  relay mirror ./home ./nas --max-size 500MB # Skip files over 500MB
+  relay mirror ./src ./dst --exclude-regex '\.tmp$' --ignore-case # Also skip .TMP
+  relay mirror ./src ./dst --exclude-regex '\.(tmp|bak)$' # Skip by regular expression
+  relay mirror ./home ./nas --exclude-from excludes.txt # Skip the globs listed in a file
+  relay mirror ./src ./dst --delete       # Remove files no longer in source
+  relay mirror ./site ./www --files-from deploy.txt # Copy listed files in order
+  relay mirror ./build ./live --atomic-dir # Swap in the new version all at once
+  relay mirror ./vault ./audit --double-check # Require two checksums to match
+  relay mirror ./logs ./archive --append-only # Append only new data to growing files
+  relay mirror ./photos ./nas --quarantine # Skip sources that read back differently
+  relay mirror ./data ./prod --max-files 1000 # Copy at most 1000 files this run
+  relay mirror ./disk ./nas --verify-after --checksum-block-report # Locate damage in files that fail verification
+  relay mirror ./src /mnt/remote --dest-snapshot # Reuse the last destination listing
+  relay mirror ./media ./nas --fullscreen  # Full-screen dashboard with an operation log
+  relay mirror -R /home/user/docs ./backup # Mirror into ./backup/home/user/docs
+  relay mirror ./docs ./backup --rsync-paths # Mirror into ./backup/docs, as rsync would
+  relay mirror ./site ./www --list-changes # Print the paths that changed
+  relay mirror ./photos /mnt/cold --write-checksums # Self-verifying archive (.b3sum sidecars)
+  relay mirror ./docs ./backup /mnt/nas /media/usb --fan-out # Read once, write to all three
+  relay mirror ./docs ./docs-backup.tar.gz # Keep the mirror as a single archive
+  relay mirror --from-archive release.tar.gz ./site --delete # Make ./site match the archive
+  relay mirror ./home /mnt/backup --delete --confirm # Review the plan before anything changes
+  relay mirror ./data /mnt/nas --timeout 2h --file-timeout 10m # Unattended: never hang`,
+	Args:              cobra.MinimumNArgs(2),
+	ValidArgsFunction: completeDirs,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 2 && !fanOut {
+			return fmt.Errorf("mirroring to %d destinations requires --fan-out", len(args)-1)
+		}
+
 		source, err := filepath.Abs(args[0])
 		if err != nil {
 			return fmt.Errorf("invalid source path: %w", err)
 		}
 
-		destination, err := filepath.Abs(args[1])
-		if err != nil {
-			return fmt.Errorf("invalid destination path: %w", err)
+		destinations := make([]string, len(args)-1)
+		for i, arg := range args[1:] {
+			destinations[i], err = filepath.Abs(arg)
+			if err != nil {
+				return fmt.Errorf("invalid destination path: %w", err)
+			}
+
+			if relative {
+				// Uses the source as given, since Abs would drop a "/./" marker.
+				destinations[i], err = core.RelativeDestination(args[0], destinations[i])
+				if err != nil {
+					return fmt.Errorf("invalid source path: %w", err)
+				}
+			}
+
+			if rsyncPaths {
+				// Uses the source as given, since Abs drops a trailing slash.
+				destinations[i] = core.RsyncDestination(args[0], destinations[i])
+			}
+		}
+
+		destination := destinations[0]
+		archive := core.ArchiveFormatOf(destination)
+
+		if archive != core.ArchiveNone && atomicDir {
+			return errors.New("--atomic-dir cannot be used with an archive destination, which is always replaced atomically")
+		}
+
+		if fromArchive && archive != core.ArchiveNone {
+			return errors.New("--from-archive needs a destination directory, not another archive")
 		}
 
+		stdout, restoreStdout := redirectForJSON()
+		defer restoreStdout()
+
 		// Determine if we can use interactive UI
-		isInteractive := term.IsTerminal(int(os.Stdout.Fd())) && !verbose && !dryRun
+		// --confirm prompts and --warn-dest-newer lists files between
+		// scanning and copying, which the live dashboard would draw over.
+		isInteractive := term.IsTerminal(int(os.Stdout.Fd())) && !verbose && !dryRun && !confirm && !warnDestNewer && !jsonOutput()
 		colorEnabled := term.IsTerminal(int(os.Stdout.Fd()))
 
 		statusRenderer := display.NewStatusRenderer(colorEnabled, false)
@@ -62,36 +168,207 @@ Examples:
 		fmt.Println()
 
 		statusRenderer.PrintInfo("Starting mirror operation")
-		statusRenderer.PrintInfo(fmt.Sprintf("Source: %s", source))
-		statusRenderer.PrintInfo(fmt.Sprintf("Destination: %s", destination))
-		statusRenderer.PrintInfo("Mode: One-way mirror")
+		if fromArchive {
+			statusRenderer.PrintInfo(fmt.Sprintf("Source: %s (archive)", source))
+		} else {
+			statusRenderer.PrintInfo(fmt.Sprintf("Source: %s", source))
+		}
+
+		if fanOut {
+			for _, destination := range destinations {
+				statusRenderer.PrintInfo(fmt.Sprintf("Destination: %s", destination))
+			}
+
+			statusRenderer.PrintInfo("Mode: One-way mirror, reading each file once for all destinations")
+		} else {
+			statusRenderer.PrintInfo(fmt.Sprintf("Destination: %s", destination))
+
+			switch {
+			case archive != core.ArchiveNone:
+				statusRenderer.PrintInfo(fmt.Sprintf("Mode: One-way mirror into a %s archive", archive))
+			case fromArchive:
+				statusRenderer.PrintInfo(fmt.Sprintf("Mode: One-way mirror out of a %s archive", core.ArchiveFormatOf(source)))
+			default:
+				statusRenderer.PrintInfo("Mode: One-way mirror")
+			}
+		}
+
+		if atomicDir {
+			statusRenderer.PrintInfo("Destination will be swapped atomically when the sync completes")
+		}
 
 		if dryRun {
 			statusRenderer.PrintWarning("Running in dry-run mode (preview only)")
 		}
+
+		if readOnly {
+			statusRenderer.PrintWarning("Destination is read-only; any attempt to change it fails the run")
+		}
 		fmt.Println()
 
+		// Match filters the way the source filesystem compares names.
+		if !cmd.Flags().Changed("ignore-case") {
+			ignoreCase = core.CaseInsensitive(source)
+		}
+
 		engine, err := createSyncEngine()
 		if err != nil {
 			return fmt.Errorf("failed to create sync engine: %w", err)
 		}
 
-		ctx := cmd.Context()
-		if ctx == nil {
-			ctx = context.Background()
+		opts := engine.Options()
+		opts.DryRun = dryRun
+		opts.DeleteExtraneous = deleteExtraneous
+		opts.PruneEmptyDirs = pruneEmptyDirs
+		opts.DetectMoves = detectMoves
+
+		opts.DeleteOrder, err = core.ParseDeleteOrder(deleteOrder)
+		if err != nil {
+			return err
+		}
+		opts.ModifyWindow = modifyWindow
+		opts.AppendOnly = appendOnly
+		opts.VerifyAfter = verifyAfter
+
+		if blockReport != "" && !verifyAfter {
+			return errors.New("--checksum-block-report needs --verify-after")
+		}
+
+		opts.ChecksumBlockReport, err = config.ParseSize(blockReport)
+		if err != nil {
+			return fmt.Errorf("invalid --checksum-block-report: %w", err)
+		}
+		opts.Quarantine = quarantine
+		opts.MaxFiles = maxFiles
+		opts.WarnDestNewer = warnDestNewer
+		opts.ProtectDestNewer = protectDestNewer
+
+		if doubleCheck {
+			opts.ChecksumVerify = true
+			engine.SetDoubleCheck(true)
+		}
+
+		if verbose {
+			statusRenderer.PrintInfo(fmt.Sprintf("Checksum: %s", engine.ChecksumAlgorithm()))
+
+			if limit := engine.MaxOpenFiles(); limit > 0 {
+				statusRenderer.PrintInfo(fmt.Sprintf("Open files: at most %d at once", limit))
+			} else {
+				statusRenderer.PrintInfo("Open files: no limit")
+			}
+
+			if presets := engine.SmartPresets(source); presets != nil {
+				names := make([]string, len(presets))
+				for i, preset := range presets {
+					names[i] = preset.Name
+				}
+
+				statusRenderer.PrintInfo(fmt.Sprintf("Smart exclusions: %s", strings.Join(names, ", ")))
+			}
+		}
+
+		if crtimes && !core.CanPreserveCreationTimes() {
+			statusRenderer.PrintWarning("--crtimes has no effect on this platform",
+				"Creation times can only be set on macOS and Windows")
+		}
+
+		if filesFrom != "" {
+			opts.FileList, err = readFileList(filesFrom)
+			if err != nil {
+				return err
+			}
+
+			statusRenderer.PrintInfo(fmt.Sprintf("Syncing %d listed paths in order", len(opts.FileList)))
 		}
 
+		engine.SetOptions(opts)
+
+		// The dashboard shows the plan itself.
+		if !isInteractive {
+			engine.SetPreflight(confirmPlan(colorEnabled))
+		}
+
+		checksumMode, err := core.ParseChecksumFileMode(writeChecksums)
+		if err != nil {
+			return err
+		}
+
+		if err := engine.SetChecksumFiles(checksumMode); err != nil {
+			return err
+		}
+
+		finishChangeList, err := startChangeList(engine, statusRenderer)
+		if err != nil {
+			return err
+		}
+
+		defer finishChangeList()
+
+		auditLog, err := openAuditLog()
+		if err != nil {
+			return err
+		}
+
+		defer closeAuditLog(auditLog, statusRenderer)
+
+		engine.SetAuditLog(auditLog)
+
+		events, err := openEventSocket()
+		if err != nil {
+			return err
+		}
+
+		defer closeEventSocket(events, statusRenderer)
+
+		engine.SetEventStream(events)
+
+		// Atomic mirrors always sync into a fresh, empty staging directory.
+		if (destSnapshot || rescanDest) && !atomicDir {
+			snapshotPath, err := core.DefaultSnapshotPath(destination)
+			if err != nil {
+				return err
+			}
+
+			engine.SetDestinationSnapshot(snapshotPath, rescanDest)
+		}
+
+		checkpointPath, err := startCheckpoint(engine, source, destination, archive, statusRenderer)
+		if err != nil {
+			return err
+		}
+
+		// Archive entries carry the times they were archived with, not those
+		// of a filesystem clock.
+		if archive == core.ArchiveNone && !fromArchive {
+			for _, destination := range destinations {
+				warnClockSkew(engine, destination, modifyWindow, statusRenderer)
+			}
+		}
+
+		ctx, cancel := runContext(cmd)
+		defer cancel()
+
+		stopProgressFile := startProgressFile(ctx, engine)
+
 		// Start mirror operation with UI
 		if isInteractive {
 			// Use dashboard for interactive mode
-			dashboard := display.NewDashboard(engine, 100*time.Millisecond)
+			dashboard := display.NewDashboard(engine, max(progressInterval, minProgressInterval))
+			dashboard.SetFullScreen(fullScreen)
 
 			// Start dashboard in background
 			dashCtx, dashCancel := context.WithCancel(ctx)
 			go dashboard.Run(dashCtx)
 
 			// Run mirror operation
-			err := engine.Mirror(ctx, source, destination)
+			results, err := runMirror(ctx, engine, source, destinations)
+			err = runTimeoutError(ctx, err)
+			stopProgressFile(err == nil)
+			writeErrorLog(engine, statusRenderer)
+			writeStatsFile(engine, statusRenderer)
+			recordStatus(engineStatus(selectedProfile(), "mirror", source, strings.Join(destinations, ", "), engine), err, statusRenderer)
+			reportVanished(engine, statusRenderer)
+			reportFileTimeouts(engine, statusRenderer)
 
 			// Stop dashboard
 			dashCancel()
@@ -99,44 +376,621 @@ Examples:
 
 			if err != nil {
 				dashboard.ShowError(err)
-				return fmt.Errorf("mirror operation failed: %w", err)
+				reportCheckpoint(checkpointPath, statusRenderer)
+
+				return runError(engine, fmt.Errorf("mirror operation failed: %w", err))
 			}
 
 			// Show completion summary
 			stats := engine.GetStats()
 			dashboard.ShowCompletion(stats)
+			reportDestinations(results, statusRenderer)
+			reportCheckpoint(checkpointPath, statusRenderer)
 		} else {
 			// Use simple progress for non-interactive mode
 			statusRenderer.PrintProgress("Starting file scan...")
 
-			err := engine.Mirror(ctx, source, destination)
+			scanCtx, scanCancel := context.WithCancel(ctx)
+			go display.PrintScanProgress(scanCtx, engine, scanProgressInterval)
+
+			results, err := runMirror(ctx, engine, source, destinations)
+			err = runTimeoutError(ctx, err)
+			scanCancel()
+
+			stopProgressFile(err == nil)
+			writeErrorLog(engine, statusRenderer)
+			writeStatsFile(engine, statusRenderer)
+			recordStatus(engineStatus(selectedProfile(), "mirror", source, strings.Join(destinations, ", "), engine), err, statusRenderer)
+			reportVanished(engine, statusRenderer)
+			reportFileTimeouts(engine, statusRenderer)
+			reportCheckpoint(checkpointPath, statusRenderer)
+
+			if jsonOutput() {
+				if reportErr := printRunReport(stdout, "mirror", source, destination, engine, err); reportErr != nil {
+					return reportErr
+				}
+			}
+
+			if errors.Is(err, errPlanDeclined) {
+				statusRenderer.PrintInfo("Mirror cancelled, nothing was changed")
+				return runError(engine, err)
+			}
+
 			if err != nil {
 				statusRenderer.PrintError("Mirror operation failed", err.Error())
-				return fmt.Errorf("mirror operation failed: %w", err)
+				return runError(engine, fmt.Errorf("mirror operation failed: %w", err))
 			}
 
 			// Show final statistics
 			fmt.Println()
-			statusRenderer.PrintSuccess("Mirror completed successfully!")
+
+			if len(engine.GetErrors()) > 0 {
+				statusRenderer.PrintWarning("Mirror completed with errors")
+			} else {
+				statusRenderer.PrintSuccess("Mirror completed successfully!")
+			}
+
 			display.PrintSimpleStats(engine, colorEnabled)
+			reportDestinations(results, statusRenderer)
 		}
 
-		return nil
+		return runError(engine, nil)
 	},
 }
 
 func init() {
 	mirrorCmd.Flags().BoolVar(&ifNewer, "if-newer", false, "only copy files that are newer")
-	mirrorCmd.Flags().BoolVar(&smart, "smart", false, "automatically exclude common build artifacts")
+	mirrorCmd.Flags().BoolVar(&smart, "smart", false, "exclude version control directories, OS clutter and the build artifacts of the project types detected in the source")
 	mirrorCmd.Flags().BoolVar(&turbo, "turbo", false, "maximum performance mode")
 	mirrorCmd.Flags().BoolVar(&gentle, "gentle", false, "low resource usage mode")
-	mirrorCmd.Flags().StringVar(&since, "since", "", "only sync changes since specified time (e.g., '1h', '2d')")
+	mirrorCmd.Flags().StringVar(&since, "since", "", "only sync files modified since this time: an age (e.g., '1h', '2d') or a date (e.g., '2024-01-31', RFC 3339)")
 	mirrorCmd.Flags().StringSliceVar(&filters, "include", nil, "include patterns (glob)")
 	mirrorCmd.Flags().StringSliceVar(&excludes, "exclude", nil, "exclude patterns (glob)")
+	mirrorCmd.Flags().StringArrayVar(&includeFrom, "include-from", nil, "read include glob patterns from this file, one per line ('-' for stdin)")
+	mirrorCmd.Flags().StringArrayVar(&excludeFrom, "exclude-from", nil, "read exclude glob patterns from this file, one per line ('-' for stdin)")
+	mirrorCmd.Flags().StringArrayVar(&includeRegex, "include-regex", nil, "only include files whose relative path matches this regular expression")
+	mirrorCmd.Flags().StringArrayVar(&excludeRegex, "exclude-regex", nil, "exclude files whose relative path matches this regular expression")
+	mirrorCmd.Flags().BoolVar(&noIgnoreFiles, "no-relayignore", false, "do not exclude the glob patterns listed in .relayignore files in the source")
+	mirrorCmd.Flags().BoolVar(&ignoreCase, "ignore-case", false, "match filter patterns regardless of case (default: on when the source filesystem is case-insensitive)")
+	mirrorCmd.Flags().StringVar(&maxSize, "max-size", "", "exclude files larger than this size (e.g., '500MB')")
+	mirrorCmd.Flags().StringVar(&minSize, "min-size", "", "exclude files smaller than this size (e.g., '1KB')")
+	mirrorCmd.Flags().BoolVar(&deleteExtraneous, "delete", false, "delete destination files that no longer exist in source")
+	mirrorCmd.Flags().StringVar(&deleteOrder, "delete-order", "after", "with --delete, when to delete relative to copying: after (safest), before (frees space first) or during (per directory, keeps peak space low)")
+	mirrorCmd.Flags().BoolVar(&detectMoves, "detect-moves", false, "with --delete, rename destination directories whose contents match a renamed source directory instead of copying them again")
+	mirrorCmd.Flags().BoolVar(&pruneEmptyDirs, "prune-empty-dirs", true, "with --delete, also remove destination directories the deletions leave empty")
+	mirrorCmd.Flags().StringVar(&filesFrom, "files-from", "", "sync only the relative paths listed in this file, in order")
+	mirrorCmd.Flags().DurationVar(&modifyWindow, "modify-window", 0, "treat modification times within this window as equal (e.g., '2s')")
+	mirrorCmd.Flags().BoolVar(&doubleCheck, "double-check", false, "compare files with two independent checksums (slower; for audits)")
+	mirrorCmd.Flags().BoolVar(&appendOnly, "append-only", false, "append only the new tail of files that grew, after verifying the existing prefix")
+	mirrorCmd.Flags().Int64Var(&maxFiles, "max-files", 0, "copy at most this many files per run; the rest are left for the next run (0 = no limit)")
+	mirrorCmd.Flags().BoolVar(&quarantine, "quarantine", false, "re-read each source before copying and skip it if its checksum changed without an edit (suspected corruption)")
+	mirrorCmd.Flags().BoolVar(&verifyAfter, "verify-after", false, "once the sync finishes, rehash every destination file and fail the run if any differs from its source")
+	mirrorCmd.Flags().StringVar(&blockReport, "checksum-block-report", "", "with --verify-after, compare mismatched files with their source in blocks of this size and report the byte ranges that differ")
+	mirrorCmd.Flags().Lookup("checksum-block-report").NoOptDefVal = "1MB"
+	mirrorCmd.Flags().BoolVar(&atomicDir, "atomic-dir", false, "sync into a staging directory and atomically swap the destination symlink")
+	mirrorCmd.Flags().BoolVar(&fromArchive, "from-archive", false, "the source is a .tar, .tar.gz or .zip archive whose contents are mirrored into the destination")
+	mirrorCmd.Flags().BoolVar(&destSnapshot, "dest-snapshot", false, "reuse the destination listing saved by the last successful run instead of rescanning")
+	mirrorCmd.Flags().BoolVar(&rescanDest, "rescan-dest", false, "rescan the destination and refresh its saved listing")
+	mirrorCmd.Flags().BoolVarP(&relative, "relative", "R", false, "keep the full source path under the destination (a /./ in the source marks where the kept part starts)")
+	mirrorCmd.Flags().BoolVar(&rsyncPaths, "rsync-paths", false, "follow rsync's trailing-slash rule: src/ mirrors the contents of src, src mirrors into destination/src")
+	mirrorCmd.MarkFlagsMutuallyExclusive("relative", "rsync-paths")
+	mirrorCmd.Flags().BoolVar(&listChanges, "list-changes", false, "print the paths created, modified or deleted by this run once it finishes")
+	mirrorCmd.Flags().StringVar(&changesFile, "changes-file", "", "write the paths changed by this run to this file as they happen")
+	mirrorCmd.MarkFlagsMutuallyExclusive("list-changes", "changes-file")
+	mirrorCmd.Flags().StringVar(&writeChecksums, "write-checksums", "", "write checksum files into the destination: sidecar (one per file) or manifest (one per directory)")
+	mirrorCmd.Flags().Lookup("write-checksums").NoOptDefVal = "sidecar"
+	mirrorCmd.Flags().BoolVar(&confirm, "confirm", false, "show the plan after scanning and ask before copying or deleting anything")
+	mirrorCmd.Flags().BoolVar(&warnDestNewer, "warn-dest-newer", false, "list destination files newer than their source before overwriting them")
+	mirrorCmd.Flags().BoolVar(&protectDestNewer, "protect-dest-newer", false, "leave destination files newer than their source alone instead of overwriting them")
+	mirrorCmd.Flags().BoolVar(&noCheckpoint, "no-checkpoint", false, "do not keep a checkpoint for resuming this mirror if it is interrupted")
+	mirrorCmd.Flags().BoolVar(&fanOut, "fan-out", false, "mirror to every listed destination, reading each source file once")
+
+	for _, singleDestination := range []string{
+		"atomic-dir", "files-from", "append-only", "dest-snapshot", "rescan-dest",
+		"list-changes", "changes-file", "write-checksums", "detect-moves", "verify-after",
+	} {
+		mirrorCmd.MarkFlagsMutuallyExclusive("fan-out", singleDestination)
+	}
+
+	// These need a source directory.
+	for _, directorySource := range []string{
+		"fan-out", "atomic-dir", "files-from", "append-only", "quarantine", "relative", "rsync-paths",
+	} {
+		mirrorCmd.MarkFlagsMutuallyExclusive("from-archive", directorySource)
+	}
+
+	mirrorCmd.Flags().BoolVar(&fullScreen, "fullscreen", false, "show the dashboard full screen with a scrolling log of recent file operations")
+	mirrorCmd.Flags().DurationVar(&progressInterval, "progress-interval", dashboardRefreshRate, "how often the dashboard redraws (e.g., '1s' over slow SSH links; at least 50ms)")
 
 	rootCmd.AddCommand(mirrorCmd)
 }
 
+// startCheckpoint makes a mirror of source into destination keep a
+// checkpoint, unless --no-checkpoint is given, and returns its path. Only a
+// mirror of a whole source directory into a destination directory is
+// checkpointed; the path is empty for any other.
+func startCheckpoint(engine *core.SyncEngine, source, destination string, archive core.ArchiveFormat,
+	statusRenderer *display.StatusRenderer,
+) (string, error) {
+	if noCheckpoint || dryRun || fanOut || atomicDir || fromArchive || filesFrom != "" || archive != core.ArchiveNone {
+		return "", nil
+	}
+
+	path, err := core.DefaultCheckpointPath(source, destination)
+	if err != nil {
+		return "", err
+	}
+
+	workDir, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	engine.SetCheckpoint(path, workDir, os.Args[1:])
+
+	if checkpoint, err := core.ReadCheckpoint(path); err == nil && checkpoint != nil &&
+		checkpoint.Source == source && checkpoint.Destination == destination && checkpoint.Completed > 0 {
+		statusRenderer.PrintInfo(fmt.Sprintf("Resuming an interrupted mirror started %s", checkpoint.Started.Format(time.DateTime)),
+			display.CountOf(int64(checkpoint.Completed), "file is", "files are")+" already done")
+	}
+
+	return path, nil
+}
+
+// reportCheckpoint tells how to carry on a mirror that left its checkpoint
+// at path behind.
+func reportCheckpoint(path string, statusRenderer *display.StatusRenderer) {
+	if path == "" {
+		return
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		statusRenderer.PrintInfo("Progress saved; relay resume, or this command again, carries on from here")
+	}
+}
+
+// startChangeList sets up --list-changes or --changes-file and returns a
+// function that prints the list, or closes the file, once the run is done.
+// Failing to write the file is reported but does not fail the run.
+func startChangeList(engine *core.SyncEngine, statusRenderer *display.StatusRenderer) (func(), error) {
+	switch {
+	case changesFile != "":
+		file, err := os.Create(changesFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create changes file: %w", err)
+		}
+
+		writer := bufio.NewWriter(file)
+		engine.SetChangeList(true, writer)
+
+		return func() {
+			err := engine.ChangeListError()
+			if flushErr := writer.Flush(); err == nil {
+				err = flushErr
+			}
+
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+
+			if err != nil {
+				statusRenderer.PrintWarning("Failed to write changes file", err.Error())
+			}
+		}, nil
+	case listChanges || jsonOutput():
+		engine.SetChangeList(true, nil)
+		// A preview, or the JSON report of a dry run, lists what it would
+		// change.
+		engine.SetDryRunChanges(preview || jsonOutput())
+
+		return func() {
+			if !listChanges {
+				return
+			}
+
+			changes := engine.GetChangedFiles()
+
+			if preview {
+				fmt.Println()
+
+				if len(changes) == 0 {
+					statusRenderer.PrintInfo("Nothing would change")
+					return
+				}
+
+				statusRenderer.PrintInfo("Would change " + display.CountOf(int64(len(changes)), "path", "paths"))
+			}
+
+			for _, change := range changes {
+				fmt.Println(change)
+			}
+		}, nil
+	default:
+		return func() {}, nil
+	}
+}
+
 func createSyncEngine() (*core.SyncEngine, error) {
-	return core.NewSyncEngine()
+	prof, err := loadProfile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	return newProfileEngine(prof)
+}
+
+// checksumSeedFor returns the --checksum-seed, or else the seed of prof.
+func checksumSeedFor(prof *config.Profile) string {
+	if checksumSeed == "" && prof.Performance != nil {
+		return prof.Performance.ChecksumSeed
+	}
+
+	return checksumSeed
+}
+
+// checkChecksumSeed rejects a checksum seed with an algorithm that would
+// ignore it, as the config loader does for a profile's own settings.
+func checkChecksumSeed(algo, seed string) error {
+	if seed != "" && algo != "blake3" {
+		return fmt.Errorf("a checksum seed requires the blake3 checksum algorithm, got %s", algo)
+	}
+
+	return nil
+}
+
+// newProfileEngine creates an engine configured from prof and the global
+// flags, which take precedence over it.
+func newProfileEngine(prof *config.Profile) (*core.SyncEngine, error) {
+	engine, err := core.NewSyncEngine()
+	if err != nil {
+		return nil, err
+	}
+
+	engine.SetLogger(logger)
+
+	seed := checksumSeedFor(prof)
+
+	if prof.Performance != nil && prof.Performance.ChecksumAlgo != "" {
+		if err := engine.SetChecksumAlgorithm(prof.Performance.ChecksumAlgo); err != nil {
+			return nil, configError(fmt.Errorf("invalid checksumAlgo in config: %w", err))
+		}
+
+		if err := checkChecksumSeed(prof.Performance.ChecksumAlgo, seed); err != nil {
+			return nil, configError(err)
+		}
+	}
+
+	engine.SetChecksumSeed(seed)
+
+	sample := sampleChecksum
+	if sample == "" && prof.Performance != nil {
+		sample = prof.Performance.SampleChecksum
+	}
+
+	sampleSize, err := config.ParseSize(sample)
+	if err != nil {
+		return nil, configError(fmt.Errorf("invalid --sample-checksum: %w", err))
+	}
+
+	engine.SetSampleChecksum(sampleSize)
+
+	parallel := parallelHash
+	if parallel == "" && prof.Performance != nil {
+		parallel = prof.Performance.ParallelHash
+	}
+
+	parallelSize, err := config.ParseSize(parallel)
+	if err != nil {
+		return nil, configError(fmt.Errorf("invalid --parallel-hash: %w", err))
+	}
+
+	engine.SetParallelHash(parallelSize)
+
+	checksumConcurrency := checksumProcs
+	if checksumConcurrency == 0 && prof.Performance != nil {
+		checksumConcurrency = prof.Performance.ChecksumConcurrency
+	}
+
+	engine.SetChecksumConcurrency(checksumConcurrency)
+
+	openFiles := maxOpenFiles
+	if openFiles == 0 && prof.Performance != nil {
+		openFiles = prof.Performance.MaxOpenFiles
+	}
+
+	engine.SetMaxOpenFiles(openFiles)
+
+	if prof.Performance != nil && prof.Performance.ConcurrencyMultiplier != nil {
+		multiplier := prof.Performance.ConcurrencyMultiplier
+		engine.SetConcurrencyMultipliers(multiplier.Scan, multiplier.Copy)
+	}
+
+	if prof.BufferSize != "" && prof.BufferSize != "auto" {
+		bufferSize, err := config.ParseSize(prof.BufferSize)
+		if err != nil {
+			return nil, configError(fmt.Errorf("invalid bufferSize in config: %w", err))
+		}
+
+		engine.SetBufferSize(bufferSize)
+	}
+
+	engine.SetConflictConfig(prof.Conflict)
+
+	opts := engine.Options()
+	opts.PreservePerms = !noPerms
+	opts.PreserveTimes = !noTimes
+	opts.PreserveCrtimes = crtimes
+	opts.ReadOnly = readOnly
+	opts.Timeout = fileTimeout
+	engine.SetOptions(opts)
+
+	filter, err := buildFileFilter(prof)
+	if err != nil {
+		return nil, configError(err)
+	}
+
+	engine.SetFilter(filter)
+
+	schedule, err := buildBandwidthSchedule(prof)
+	if err != nil {
+		return nil, configError(err)
+	}
+
+	engine.SetBandwidthSchedule(schedule)
+
+	return engine, nil
+}
+
+// runMirror mirrors source to its destination, staging and swapping the whole
+// destination when --atomic-dir is set and reading source as an archive with
+// --from-archive. Dry runs always preview against the live destination. With
+// --fan-out it mirrors to every destination in one pass and returns how each
+// one fared.
+func runMirror(ctx context.Context, engine *core.SyncEngine, source string, destinations []string) ([]*core.FanOutResult, error) {
+	if fanOut {
+		return engine.MirrorFanOut(ctx, source, destinations, engine.Options())
+	}
+
+	if fromArchive {
+		_, err := engine.SyncFromArchive(ctx, source, destinations[0], engine.Options())
+		return nil, err
+	}
+
+	if core.ArchiveFormatOf(destinations[0]) != core.ArchiveNone {
+		_, err := engine.SyncToArchive(ctx, source, destinations[0], engine.Options())
+		return nil, err
+	}
+
+	if atomicDir && !dryRun {
+		return nil, engine.MirrorAtomic(ctx, source, destinations[0])
+	}
+
+	return nil, engine.Mirror(ctx, source, destinations[0])
+}
+
+// confirmPlan returns a preflight hook that prints the plan of the run and,
+// with --confirm, asks before anything is changed. Dry runs are not asked
+// about, since they change nothing.
+func confirmPlan(colorEnabled bool) func(plan *core.SyncPlan) error {
+	renderer := display.NewProgressRenderer(colorEnabled, 80)
+
+	return func(plan *core.SyncPlan) error {
+		fmt.Println(renderer.RenderPlan(plan))
+
+		if maxFiles > 0 && plan.FilesToCopy > maxFiles {
+			fmt.Printf("   Only %d files will be copied this run (--max-files)\n", maxFiles)
+		}
+
+		if warnDestNewer {
+			verb := "overwrite"
+			if protectDestNewer {
+				verb = "keep"
+			}
+
+			for _, path := range plan.DestNewer {
+				fmt.Printf("   ⚠️  Newer at destination, will %s: %s\n", verb, path)
+			}
+		}
+
+		if !confirm || dryRun {
+			return nil
+		}
+
+		fmt.Print("Proceed? [y/N]: ")
+
+		input, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("failed to read confirmation: %w", err)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(input)) {
+		case "y", "yes":
+			return nil
+		default:
+			return errPlanDeclined
+		}
+	}
+}
+
+// reportDestinations prints how each destination of a fan-out mirror fared.
+func reportDestinations(results []*core.FanOutResult, statusRenderer *display.StatusRenderer) {
+	for _, result := range results {
+		switch {
+		case result.Err != nil:
+			statusRenderer.PrintError(result.Destination, result.Err.Error())
+		case result.Stats.ErrorsEncountered > 0:
+			statusRenderer.PrintWarning(result.Destination, fmt.Sprintf("%d files changed, %d errors",
+				result.Stats.FilesChanged, result.Stats.ErrorsEncountered))
+		default:
+			statusRenderer.PrintSuccess(result.Destination, fmt.Sprintf("%d files changed", result.Stats.FilesChanged))
+		}
+	}
+}
+
+// warnClockSkew probes destination for clock skew, which makes files look
+// perpetually newer or older, and warns when it exceeds the modify window.
+func warnClockSkew(engine *core.SyncEngine, destination string, window time.Duration, statusRenderer *display.StatusRenderer) {
+	skew, err := engine.MeasureClockSkew(destination)
+	if err != nil {
+		if verbose {
+			statusRenderer.PrintInfo("Clock skew check skipped", err.Error())
+		}
+
+		return
+	}
+
+	if verbose {
+		statusRenderer.PrintInfo(fmt.Sprintf("Clock skew: destination %v", skew.Destination))
+	}
+
+	if skew.Significant() && skew.Destination.Abs() > window {
+		statusRenderer.PrintWarning(
+			fmt.Sprintf("Destination clock differs from this machine's by %v", skew.Destination.Round(time.Second)),
+			"Modification times will not compare reliably; use --modify-window to tolerate the skew",
+		)
+	}
+}
+
+// readFileList reads the --files-from list.
+func readFileList(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file list: %w", err)
+	}
+
+	list, err := core.ReadFileList(file)
+	_ = file.Close()
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid file list %s: %w", path, err)
+	}
+
+	return list, nil
+}
+
+// buildBandwidthSchedule returns the --bwlimit schedule when given, falling
+// back to the profile's bandwidthSchedule table.
+func buildBandwidthSchedule(prof *config.Profile) (*config.BandwidthSchedule, error) {
+	if bandwidthLimit != "" {
+		schedule, err := config.ParseBandwidthSchedule(bandwidthLimit)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --bwlimit: %w", err)
+		}
+
+		return schedule, nil
+	}
+
+	if prof.Performance == nil {
+		return nil, nil
+	}
+
+	schedule, err := config.NewBandwidthSchedule(prof.Performance.BandwidthSchedule)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bandwidth schedule: %w", err)
+	}
+
+	return schedule, nil
+}
+
+// buildFileFilter combines the profile's filter rules with command-line
+// overrides. When both set a size limit, the more restrictive one wins;
+// patterns from both apply together.
+func buildFileFilter(prof *config.Profile) (*core.FileFilter, error) {
+	var rules config.FilterRules
+	if prof.Filters != nil {
+		rules = *prof.Filters
+	}
+
+	minBytes, err := restrictiveSize(minSize, rules.MinFileSize, false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid minimum size: %w", err)
+	}
+
+	maxBytes, err := restrictiveSize(maxSize, rules.MaxFileSize, true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid maximum size: %w", err)
+	}
+
+	if maxBytes > 0 && minBytes > maxBytes {
+		return nil, fmt.Errorf("minimum size %d exceeds maximum size %d", minBytes, maxBytes)
+	}
+
+	filter := core.NewFileFilter()
+	filter.SetSizeLimits(minBytes, maxBytes)
+
+	if since != "" {
+		cutoff, err := config.ParseSince(since, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("invalid --since: %w", err)
+		}
+
+		filter.SetModifiedSince(cutoff)
+	}
+	filter.SetIgnoreCase(ignoreCase)
+	filter.SetIgnoreFiles(!noIgnoreFiles)
+	filter.SetSmart(smart || rules.Smart)
+
+	err = filter.SetRegexPatterns(
+		slices.Concat(rules.IncludeRegex, includeRegex),
+		slices.Concat(rules.ExcludeRegex, excludeRegex),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	includeGlobs, err := readPatternFiles(slices.Concat(rules.Include, filters), slices.Concat(rules.IncludeFrom, includeFrom))
+	if err != nil {
+		return nil, err
+	}
+
+	excludeGlobs, err := readPatternFiles(slices.Concat(rules.Exclude, excludes), slices.Concat(rules.ExcludeFrom, excludeFrom))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := filter.SetGlobPatterns(includeGlobs, excludeGlobs); err != nil {
+		return nil, err
+	}
+
+	return filter, nil
+}
+
+// readPatternFiles returns patterns followed by those listed in each of
+// files.
+func readPatternFiles(patterns, files []string) ([]string, error) {
+	for _, file := range files {
+		listed, err := core.ReadPatternFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		patterns = append(patterns, listed...)
+	}
+
+	return patterns, nil
+}
+
+// restrictiveSize parses two optional size limits and returns the tighter
+// one: the smaller for an upper bound, the larger for a lower bound.
+func restrictiveSize(flagValue, configValue string, upper bool) (int64, error) {
+	flagBytes, err := config.ParseSize(flagValue)
+	if err != nil {
+		return 0, err
+	}
+
+	configBytes, err := config.ParseSize(configValue)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case flagBytes == 0:
+		return configBytes, nil
+	case configBytes == 0:
+		return flagBytes, nil
+	case upper:
+		return min(flagBytes, configBytes), nil
+	default:
+		return max(flagBytes, configBytes), nil
+	}
 }
@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/howmanysmall/relay/src/internal/config"
+	"github.com/howmanysmall/relay/src/internal/display"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var profilesCmd = &cobra.Command{
+	Use:     "profiles",
+	Aliases: []string{"list-profiles"},
+	Short:   "List and inspect the profiles in the configuration file",
+	Long: `List every profile in the configuration file with its mode and
+source → destination. The default profile, used when --profile is not given,
+is marked with *. Inherited settings are resolved, so a profile that extends
+another shows the paths it will actually use.
+
+Without a subcommand, relay profiles lists the profiles.
+
+Examples:
+  relay profiles                           # Profiles in the default config
+  relay profiles list --config project.toml
+  relay profiles show nas                  # Every setting nas will use`,
+	Args: cobra.NoArgs,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		return listProfiles()
+	},
+}
+
+var profilesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the profiles in the configuration file",
+	Long: `List every profile in the configuration file with its mode and
+source → destination. The default profile is marked with *.
+
+Examples:
+  relay profiles list                      # Profiles in the default config
+  relay profiles list --config project.toml`,
+	Args: cobra.NoArgs,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		return listProfiles()
+	},
+}
+
+var profilesShowCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Print a profile with inheritance and defaults applied",
+	Long: `Print every setting of a profile as relay will use it: with the settings
+it inherits through extends and the defaults relay fills in. The profile is
+printed as JSON, in the layout of a config file.
+
+Examples:
+  relay profiles show default              # The default profile
+  relay profiles show nas --config project.toml`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeOneProfile,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configPath, cfg, err := loadProfilesConfig()
+		if err != nil {
+			return err
+		}
+
+		cmd.SilenceUsage = true
+
+		name := args[0]
+
+		resolved := profileNamed(cfg, name)
+		if resolved == nil {
+			return fmt.Errorf("profile %s not found; profiles: %s", name, strings.Join(display.ProfileNames(cfg), ", "))
+		}
+
+		data, err := json.MarshalIndent(resolved, "", "\t")
+		if err != nil {
+			return fmt.Errorf("failed to encode profile: %w", err)
+		}
+
+		if configPath == "" {
+			fmt.Printf("Profile %s of the built-in defaults:\n", name)
+		} else {
+			fmt.Printf("Profile %s in %s:\n", name, configPath)
+		}
+
+		fmt.Println(string(data))
+
+		return nil
+	},
+}
+
+// listProfiles prints the profiles of the configuration file.
+func listProfiles() error {
+	colorEnabled := term.IsTerminal(int(os.Stdout.Fd()))
+
+	configPath, cfg, err := loadProfilesConfig()
+	if err != nil {
+		return err
+	}
+
+	if configPath == "" {
+		fmt.Println("No config file found; built-in defaults:")
+	} else {
+		fmt.Printf("Profiles in %s:\n", configPath)
+	}
+
+	fmt.Println(display.RenderProfiles(cfg, colorEnabled))
+
+	return nil
+}
+
+// loadProfilesConfig loads the configuration file given by --config, or the
+// default one, and returns its path, which is empty for the built-in
+// defaults.
+func loadProfilesConfig() (string, *config.Config, error) {
+	loader := config.NewLoader()
+
+	configPath := configFile
+	if configPath == "" {
+		configPath = loader.FindConfig()
+	}
+
+	cfg, err := loader.Load(configPath)
+	if err != nil {
+		return "", nil, configError(err)
+	}
+
+	return configPath, cfg, nil
+}
+
+func init() {
+	profilesCmd.AddCommand(profilesListCmd)
+	profilesCmd.AddCommand(profilesShowCmd)
+	rootCmd.AddCommand(profilesCmd)
+}
@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/howmanysmall/relay/src/internal/core"
+	"github.com/howmanysmall/relay/src/internal/display"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	restoreAt        string
+	restoreList      bool
+	restoreBackupDir string
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <path>",
+	Short: "Restore a file from the backups conflicts saved",
+	Long: `Copy the newest backup of a file back to its place, or with --at the
+newest made at or before that time. --list shows the backups available
+instead.
+
+Backups are looked up by the file's path in the backup directory:
+--backup-dir, or the profile's conflict.backupDir, or .relay-backups. The
+file the backup replaces is backed up first, so a restore can be undone
+with another.
+
+Examples:
+  relay restore ./dst/notes.md --list              # Show the versions kept
+  relay restore ./dst/notes.md                     # Restore the newest
+  relay restore ./dst/notes.md --at "2024-05-01 14:30"
+  relay restore ./dst/notes.md --at 2024-05-01 --dry-run`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		colorEnabled := term.IsTerminal(int(os.Stdout.Fd()))
+		statusRenderer := display.NewStatusRenderer(colorEnabled, false)
+		path := args[0]
+
+		var at time.Time
+
+		if restoreAt != "" {
+			var err error
+
+			at, err = core.ParseBackupTime(restoreAt)
+			if err != nil {
+				return fmt.Errorf("invalid --at: %w", err)
+			}
+		}
+
+		prof, err := loadProfile()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		cmd.SilenceUsage = true
+
+		dir := backupDirOf(prof, restoreBackupDir)
+
+		backups, err := core.BackupsOf(dir, path)
+		if err != nil {
+			return err
+		}
+
+		if len(backups) == 0 {
+			return fmt.Errorf("%w of %s in %s", core.ErrNoBackup, filepath.Base(path), dir)
+		}
+
+		if restoreList {
+			fmt.Printf("Backups of %s in %s:\n", filepath.Base(path), dir)
+			fmt.Println(display.RenderBackups(backups, time.Time{}, time.Now(), colorEnabled))
+
+			return nil
+		}
+
+		backup, err := core.BackupAt(backups, at)
+		if err != nil {
+			return err
+		}
+
+		from := fmt.Sprintf("from the backup of %s", backup.Created.Format(time.DateTime))
+
+		if dryRun {
+			statusRenderer.PrintInfo("Would restore "+path, from, backup.Path)
+			return nil
+		}
+
+		saved, err := core.RestoreBackup(dir, backup, path)
+
+		switch {
+		case errors.Is(err, core.ErrBackupMatches):
+			statusRenderer.PrintInfo(path+" already matches the backup", from)
+			return nil
+		case err != nil:
+			return err
+		}
+
+		details := []string{from}
+		if saved != "" {
+			details = append(details, "the replaced version was saved to "+saved)
+		}
+
+		statusRenderer.PrintSuccess("Restored "+path, details...)
+
+		return nil
+	},
+}
+
+func init() {
+	restoreCmd.Flags().StringVar(&restoreAt, "at", "", "restore the newest backup made at or before this time (e.g., '2024-05-01 14:30' or '2024-05-01')")
+	restoreCmd.Flags().BoolVar(&restoreList, "list", false, "list the backups of the file instead of restoring one")
+	restoreCmd.Flags().StringVar(&restoreBackupDir, "backup-dir", "", "directory the backups are in (default: the profile's, else .relay-backups)")
+
+	rootCmd.AddCommand(restoreCmd)
+}
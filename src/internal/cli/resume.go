@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/howmanysmall/relay/src/internal/core"
+	"github.com/howmanysmall/relay/src/internal/display"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var resumeList bool
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume [destination]",
+	Short: "Carry on a mirror that was interrupted",
+	Long: `Run an interrupted mirror again, from the directory and with the arguments
+it was started with, so that it carries on where it stopped.
+
+relay mirror keeps a checkpoint of the files it has found or made identical
+at the destination, and removes it once a mirror completes. A mirror that
+finds the checkpoint of an interrupted one between the same source and
+destination does not hash those files again while their sizes and
+modification times are unchanged, so running the same command again resumes
+it too.
+
+With several interrupted mirrors, name the destination of the one to resume.
+--list shows them all.
+
+Examples:
+  relay resume                             # The only interrupted mirror
+  relay resume /mnt/nas/photos             # The mirror into /mnt/nas/photos
+  relay resume --list                      # Every interrupted mirror`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeOneDir,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		colorEnabled := term.IsTerminal(int(os.Stdout.Fd()))
+
+		cmd.SilenceUsage = true
+
+		dir, err := core.CheckpointDir()
+		if err != nil {
+			return err
+		}
+
+		checkpoints, err := core.ListCheckpoints(dir)
+		if err != nil {
+			return err
+		}
+
+		if resumeList {
+			fmt.Println(display.RenderCheckpoints(checkpoints, time.Now(), colorEnabled))
+			return nil
+		}
+
+		checkpoint, err := chooseCheckpoint(checkpoints, args)
+		if err != nil {
+			return err
+		}
+
+		if len(checkpoint.Command) == 0 {
+			return fmt.Errorf("the checkpoint of the mirror into %s does not record its command; run the mirror again",
+				checkpoint.Destination)
+		}
+
+		executable, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("failed to locate the relay executable: %w", err)
+		}
+
+		display.NewStatusRenderer(colorEnabled, false).PrintInfo(
+			fmt.Sprintf("Resuming the mirror into %s", checkpoint.Destination),
+			fmt.Sprintf("%s done so far", display.CountOf(int64(checkpoint.Completed), "file", "files")))
+
+		mirror := exec.Command(executable, checkpoint.Command...)
+		mirror.Dir = checkpoint.Dir
+		mirror.Stdin = os.Stdin
+		mirror.Stdout = os.Stdout
+		mirror.Stderr = os.Stderr
+
+		// Ctrl+C reaches the mirror too, which stops cleanly; this process
+		// waits for it rather than exiting first.
+		interrupts := make(chan os.Signal, 1)
+		signal.Notify(interrupts, os.Interrupt)
+
+		defer signal.Stop(interrupts)
+
+		if err := mirror.Run(); err != nil {
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				code := exitErr.ExitCode()
+				if code < 0 { // killed by a signal
+					code = exitCodeInterrupted
+				}
+
+				return &ExitError{Code: code, Err: fmt.Errorf("resumed mirror failed: %w", err)}
+			}
+
+			return fmt.Errorf("failed to resume mirror: %w", err)
+		}
+
+		return nil
+	},
+}
+
+// chooseCheckpoint returns the checkpoint of the mirror into the destination
+// in args, or the only one when args is empty.
+func chooseCheckpoint(checkpoints []*core.Checkpoint, args []string) (*core.Checkpoint, error) {
+	if len(args) == 1 {
+		destination, err := filepath.Abs(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid destination path: %w", err)
+		}
+
+		for _, checkpoint := range checkpoints {
+			if checkpoint.Destination == destination {
+				return checkpoint, nil
+			}
+		}
+
+		return nil, fmt.Errorf("no interrupted mirror into %s", destination)
+	}
+
+	switch len(checkpoints) {
+	case 0:
+		return nil, errors.New("no interrupted mirror to resume")
+	case 1:
+		return checkpoints[0], nil
+	default:
+		return nil, fmt.Errorf("%d mirrors were interrupted; name the destination of the one to resume (see relay resume --list)",
+			len(checkpoints))
+	}
+}
+
+func init() {
+	resumeCmd.Flags().BoolVar(&resumeList, "list", false, "list the interrupted mirrors instead of resuming one")
+
+	rootCmd.AddCommand(resumeCmd)
+}
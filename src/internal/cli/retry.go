@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/howmanysmall/relay/src/internal/core"
+	"github.com/howmanysmall/relay/src/internal/display"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var retryCmd = &cobra.Command{
+	Use:   "retry <error-log>",
+	Short: "Retry only the files that failed in a previous run",
+	Long: `Re-attempt the copies recorded in an error log written with --error-log,
+without rescanning or comparing the source and destination trees.
+
+Examples:
+  relay mirror ./src ./dst --error-log errors.json   # Record failures
+  relay retry errors.json                            # Retry just those files
+  relay retry errors.json --error-log remaining.json # Record what still fails`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		colorEnabled := term.IsTerminal(int(os.Stdout.Fd()))
+		statusRenderer := display.NewStatusRenderer(colorEnabled, false)
+
+		file, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to open error log: %w", err)
+		}
+
+		failed, err := core.ReadErrorLog(file)
+		_ = file.Close()
+
+		if err != nil {
+			return err
+		}
+
+		statusRenderer.PrintInfo(fmt.Sprintf("Retrying %d failed files from %s", len(failed), args[0]))
+
+		if dryRun {
+			for _, entry := range failed {
+				if entry.Operation == "copy" && entry.Destination != "" {
+					statusRenderer.PrintInfo(fmt.Sprintf("Would copy %s -> %s", entry.Path, entry.Destination))
+				} else {
+					statusRenderer.PrintWarning(fmt.Sprintf("Cannot replay %s (%s)", entry.Path, entry.Operation))
+				}
+			}
+
+			return nil
+		}
+
+		engine, err := createSyncEngine()
+		if err != nil {
+			return fmt.Errorf("failed to create sync engine: %w", err)
+		}
+
+		ctx, cancel := runContext(cmd)
+		defer cancel()
+
+		err = runTimeoutError(ctx, engine.RetryFailed(ctx, failed))
+		writeErrorLog(engine, statusRenderer)
+		writeStatsFile(engine, statusRenderer)
+		reportVanished(engine, statusRenderer)
+		reportFileTimeouts(engine, statusRenderer)
+
+		if err != nil {
+			statusRenderer.PrintError("Retry finished with failures", err.Error())
+			display.PrintSimpleStats(engine, colorEnabled)
+
+			return runError(engine, fmt.Errorf("retry failed: %w", err))
+		}
+
+		statusRenderer.PrintSuccess("All failed files copied successfully!")
+		display.PrintSimpleStats(engine, colorEnabled)
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(retryCmd)
+}
@@ -1,32 +1,133 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
 
+	"github.com/howmanysmall/relay/src/internal/config"
+	"github.com/howmanysmall/relay/src/internal/core"
+	"github.com/howmanysmall/relay/src/internal/display"
 	"github.com/spf13/cobra"
 )
 
+const (
+	// dashboardRefreshRate is how often live progress output is refreshed.
+	dashboardRefreshRate = 100 * time.Millisecond
+
+	// minProgressInterval is the fastest --progress-interval allowed; faster
+	// redraws only burn CPU.
+	minProgressInterval = 50 * time.Millisecond
+
+	// scanProgressInterval is how often plain output reports scan progress.
+	scanProgressInterval = 5 * time.Second
+)
+
 var (
-	configFile string
-	verbose    bool
-	dryRun     bool
-	workers    int
-	bufferSize string
-	profile    string
+	configFile     string
+	verbose        bool
+	dryRun         bool
+	readOnly       bool
+	workers        int
+	bufferSize     string
+	profile        string
+	checksumSeed   string
+	checksumProcs  int
+	sampleChecksum string
+	parallelHash   string
+	maxOpenFiles   int
+	progressFile   string
+	errorLog       string
+	statsFile      string
+	auditLogPath   string
+	eventSocket    string
+	noPerms        bool
+	noTimes        bool
+	crtimes        bool
+	bandwidthLimit string
+	rawBytes       bool
+	units          string
+	runTimeout     time.Duration
+	fileTimeout    time.Duration
+	preview        bool
 )
 
+// errRunTimeout is the cause of a run stopped by --timeout.
+var errRunTimeout = errors.New("run timed out")
+
 var rootCmd = &cobra.Command{
-	Use:   "relay",
+	Use:   "relay [source destination]",
 	Short: "High-performance file mirroring and synchronization tool",
 	Long: `Relay is a blazing-fast, cross-platform file mirroring and synchronization utility.
 Built for performance and usability, it supports real-time monitoring, conflict resolution,
 and beautiful terminal output.
 
+Given a source and a destination, relay mirrors one to the other like relay
+mirror does; with --preview it only reports what the mirror would change.
+relay mirror takes the full set of mirror options.
+
 Examples:
   relay mirror ./source ./backup          # One-way mirror
   relay sync ./local ./remote             # Two-way sync
   relay watch --config relay.jsonc        # Watch mode
   relay ./src ./dst --preview             # Preview changes`,
+	Args:                       rootArgs,
+	SuggestionsMinimumDistance: 2,
+	PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+		if err := applyOutputFormat(cmd); err != nil {
+			return err
+		}
+
+		if err := applyByteUnits(); err != nil {
+			return err
+		}
+
+		return openLog(cmd)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return cmd.Help()
+		}
+
+		if preview {
+			dryRun = true
+			listChanges = true
+		}
+
+		return mirrorCmd.RunE(cmd, args)
+	},
+}
+
+// rootArgs accepts no arguments, which shows the help, or a source and a
+// destination to mirror. A single argument, or a source that does not exist
+// but is close to a command's name, is a mistyped command and is reported as
+// cobra would.
+func rootArgs(cmd *cobra.Command, args []string) error {
+	var suggestions []string
+
+	if len(args) > 0 {
+		if _, err := os.Stat(args[0]); err != nil {
+			suggestions = cmd.SuggestionsFor(args[0])
+		}
+	}
+
+	switch {
+	case len(args) == 0 || len(args) == 2 && len(suggestions) == 0:
+		return nil
+	case len(args) == 1 || len(suggestions) > 0:
+		message := fmt.Sprintf("unknown command %q for %q", args[0], cmd.CommandPath())
+		if len(suggestions) > 0 {
+			message += "\n\nDid you mean this?\n\t" + strings.Join(suggestions, "\n\t") + "\n"
+		}
+
+		return errors.New(message)
+	default:
+		return fmt.Errorf("accepts a source and a destination, received %d arguments", len(args))
+	}
 }
 
 // SetVersionInfo sets the version information for the CLI.
@@ -40,16 +141,266 @@ Commit: %s
 
 // Execute runs the root command for the relay CLI.
 func Execute() error {
-	return rootCmd.Execute()
+	err := rootCmd.Execute()
+	closeLog(err)
+
+	return err
+}
+
+// loadProfile loads the configuration file and returns the selected profile.
+// When no configuration file exists the built-in defaults are used.
+func loadProfile() (*config.Profile, error) {
+	cfg, err := config.NewLoader().Load(configFile)
+	if err != nil {
+		return nil, configError(err)
+	}
+
+	if profile == "" || profile == "default" {
+		if cfg.Default != nil {
+			return cfg.Default, nil
+		}
+
+		if named, exists := cfg.Profiles["default"]; exists {
+			return named, nil
+		}
+
+		return &config.Profile{}, nil
+	}
+
+	named, exists := cfg.Profiles[profile]
+	if !exists {
+		return nil, configError(fmt.Errorf("profile %s not found", profile))
+	}
+
+	return named, nil
+}
+
+// startProgressFile begins writing progress to --progress-file, if set, and
+// returns a function that stops the writer. The file is removed only after a
+// successful run so that a failed run leaves its last known progress behind.
+func startProgressFile(ctx context.Context, engine *core.SyncEngine) func(success bool) {
+	if progressFile == "" {
+		return func(bool) {}
+	}
+
+	writer := display.NewProgressFile(engine, progressFile, dashboardRefreshRate)
+	writerCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		writer.Run(writerCtx)
+	}()
+
+	return func(success bool) {
+		cancel()
+		<-done
+
+		if success {
+			_ = writer.Remove()
+		}
+	}
+}
+
+// writeErrorLog writes the engine's collected errors to --error-log, if set.
+// Failing to write the log is reported but does not fail the run.
+func writeErrorLog(engine *core.SyncEngine, statusRenderer *display.StatusRenderer) {
+	if errorLog == "" {
+		return
+	}
+
+	if err := engine.WriteErrorLog(errorLog); err != nil {
+		statusRenderer.PrintWarning("Failed to write error log", err.Error())
+	}
+}
+
+// writeStatsFile writes the engine's statistics to --stats-file, if set.
+// Failing to write the file is reported but does not fail the run.
+func writeStatsFile(engine *core.SyncEngine, statusRenderer *display.StatusRenderer) {
+	if statsFile == "" {
+		return
+	}
+
+	if err := engine.WriteStatsFile(statsFile); err != nil {
+		statusRenderer.PrintWarning("Failed to write stats file", err.Error())
+	}
+}
+
+// openAuditLog opens --audit-log for appending, or returns nil when it is not
+// set, which leaves auditing off.
+func openAuditLog() (*core.AuditLog, error) {
+	if auditLogPath == "" {
+		return nil, nil
+	}
+
+	return core.OpenAuditLog(auditLogPath)
+}
+
+// closeAuditLog closes log, if there is one. Failing to write it is reported
+// but does not fail the run, whose changes are already made.
+func closeAuditLog(log *core.AuditLog, statusRenderer *display.StatusRenderer) {
+	if log == nil {
+		return
+	}
+
+	if err := log.Close(); err != nil {
+		statusRenderer.PrintError("Audit log is incomplete", err.Error())
+	}
+}
+
+// openEventSocket starts streaming run events on --event-socket, or returns
+// nil when it is not set.
+func openEventSocket() (*core.EventStream, error) {
+	if eventSocket == "" {
+		return nil, nil
+	}
+
+	return core.ListenEvents(eventSocket)
+}
+
+// closeEventSocket closes stream, if there is one, once its clients have
+// been sent every event.
+func closeEventSocket(stream *core.EventStream, statusRenderer *display.StatusRenderer) {
+	if stream == nil {
+		return
+	}
+
+	if err := stream.Close(); err != nil {
+		statusRenderer.PrintWarning("Failed to close event socket", err.Error())
+	}
+}
+
+// reportVanished lists, in verbose mode, source files that were deleted
+// between the scan and the copy. They are skipped rather than counted as
+// errors.
+func reportVanished(engine *core.SyncEngine, statusRenderer *display.StatusRenderer) {
+	if !verbose {
+		return
+	}
+
+	for _, path := range engine.VanishedFiles() {
+		statusRenderer.PrintInfo("Skipped vanished file", path)
+	}
+}
+
+// reportFileTimeouts warns about files skipped because copying them took
+// longer than --file-timeout.
+func reportFileTimeouts(engine *core.SyncEngine, statusRenderer *display.StatusRenderer) {
+	var timedOut int
+
+	for _, syncErr := range engine.GetErrors() {
+		if errors.Is(syncErr, core.ErrFileTimeout) {
+			timedOut++
+		}
+	}
+
+	if timedOut > 0 {
+		statusRenderer.PrintWarning(fmt.Sprintf("%d files timed out after %v (--file-timeout)", timedOut, fileTimeout),
+			"They were not copied; the rest of the run carried on")
+	}
+}
+
+// runContext returns the context for a command's run, which is cancelled by
+// Ctrl+C, so the run stops cleanly and still writes its reports, and expires
+// after --timeout when it is set. A second Ctrl+C kills relay at once.
+func runContext(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	context.AfterFunc(ctx, stop)
+
+	ctx, cancel := withRunTimeout(ctx)
+
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// withRunTimeout returns a context derived from ctx for one run, which
+// expires after --timeout when it is set.
+func withRunTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if runTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeoutCause(ctx, runTimeout, errRunTimeout)
+}
+
+// runTimeoutError returns an error naming --timeout when it stopped the run
+// of ctx, whatever the run itself returned, and err otherwise.
+func runTimeoutError(ctx context.Context, err error) error {
+	if !errors.Is(context.Cause(ctx), errRunTimeout) {
+		return err
+	}
+
+	return fmt.Errorf("%w after %v (--timeout): %w", errRunTimeout, runTimeout, context.DeadlineExceeded)
+}
+
+// applyByteUnits configures how byte counts are printed from --bytes and
+// --units.
+func applyByteUnits() error {
+	if rawBytes {
+		display.SetByteUnits(display.UnitsRaw)
+		return nil
+	}
+
+	byteUnits, err := display.ParseByteUnits(units)
+	if err != nil {
+		return err
+	}
+
+	display.SetByteUnits(byteUnits)
+
+	return nil
 }
 
 func init() {
 	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "config file (default is relay.jsonc)")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
 	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "preview changes without executing")
+	rootCmd.PersistentFlags().BoolVar(&readOnly, "read-only", false, "refuse every change to the destination and fail the run if one is attempted")
 	rootCmd.PersistentFlags().IntVar(&workers, "workers", 0, "number of worker goroutines (0 = auto)")
 	rootCmd.PersistentFlags().StringVar(&bufferSize, "buffer", "auto", "buffer size for operations")
 	rootCmd.PersistentFlags().StringVar(&profile, "profile", "default", "configuration profile to use")
+	rootCmd.PersistentFlags().StringVar(&progressFile, "progress-file", "", "periodically write progress as JSON to this file")
+	rootCmd.PersistentFlags().StringVar(&errorLog, "error-log", "", "write collected errors as JSON to this file after the run")
+	rootCmd.PersistentFlags().StringVar(&auditLogPath, "audit-log", "", "append every change made to the destination, with checksums, to this hash-chained log (mirror, sync, schedule and run)")
+	rootCmd.PersistentFlags().StringVar(&eventSocket, "event-socket", "", "stream run events as JSON lines to clients of a Unix socket created at this path (mirror, sync, schedule and run)")
+	rootCmd.PersistentFlags().Bool("perms", true, "preserve file permissions (default)")
+	rootCmd.PersistentFlags().BoolVar(&noPerms, "no-perms", false, "do not preserve file permissions")
+	rootCmd.PersistentFlags().Bool("times", true, "preserve modification times (default)")
+	rootCmd.PersistentFlags().BoolVar(&noTimes, "no-times", false, "do not preserve modification times")
+	rootCmd.PersistentFlags().BoolVar(&crtimes, "crtimes", false, "preserve creation times where the OS can set them (macOS, Windows)")
+	rootCmd.MarkFlagsMutuallyExclusive("perms", "no-perms")
+	rootCmd.MarkFlagsMutuallyExclusive("times", "no-times")
+	rootCmd.PersistentFlags().StringVar(&statsFile, "stats-file", "", "write run statistics as JSON to this file after the run")
+	rootCmd.PersistentFlags().StringVar(&bandwidthLimit, "bwlimit", "", "bandwidth limit per second, optionally by time of day (e.g., '09:00-17:00:5MB,default:unlimited')")
+	rootCmd.PersistentFlags().BoolVar(&rawBytes, "bytes", false, "print exact byte counts instead of scaled units")
+	rootCmd.PersistentFlags().StringVar(&units, "units", "iec", "byte units for output: iec (KiB, 1024) or si (kB, 1000)")
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "output", outputText, "output format: text, or json for scripts (mirror, sync and the commands that have --json)")
+	rootCmd.PersistentFlags().StringVar(&logFile, "log-file", "", "log what relay does to this file, rotated at 10 MiB with 5 old files kept")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "least severe messages written to --log-file: debug, info, warn or error")
+	rootCmd.PersistentFlags().DurationVar(&runTimeout, "timeout", 0, "stop the whole run after this long (e.g., '2h'; 0 = no limit)")
+	rootCmd.PersistentFlags().DurationVar(&fileTimeout, "file-timeout", 0, "give up on any single file copy that takes longer than this, retries included, and carry on (0 = no limit)")
+	rootCmd.PersistentFlags().StringVar(&checksumSeed, "checksum-seed", "", "seed for keyed blake3 checksums (must match across runs)")
+	rootCmd.PersistentFlags().StringVar(&sampleChecksum, "sample-checksum", "", "hash large files from samples of this size at their start, middle and end instead of in full (fast, not an integrity check)")
+	rootCmd.PersistentFlags().Lookup("sample-checksum").NoOptDefVal = "4MB"
+	rootCmd.PersistentFlags().StringVar(&parallelHash, "parallel-hash", "", "hash blake3 files of at least this size on every CPU at once instead of on one")
+	rootCmd.PersistentFlags().Lookup("parallel-hash").NoOptDefVal = "256MB"
+	rootCmd.PersistentFlags().IntVar(&checksumProcs, "checksum-parallelism", 0, "maximum files hashed at once (0 = limited only by scan concurrency)")
+	rootCmd.PersistentFlags().IntVar(&maxOpenFiles, "max-open-files", 0, "maximum files open at once across scanning and copying (0 = 80% of the open file limit)")
+
+	rootCmd.Flags().BoolVar(&preview, "preview", false, "with a source and destination, report what mirroring would change without changing anything")
+
+	_ = rootCmd.RegisterFlagCompletionFunc("profile", completeProfiles)
+	_ = rootCmd.RegisterFlagCompletionFunc("config", completeConfigFile)
+	_ = rootCmd.RegisterFlagCompletionFunc("units", fixedCompletions("iec", "si"))
+	_ = rootCmd.RegisterFlagCompletionFunc("output", fixedCompletions(outputText, outputJSON))
+	_ = rootCmd.RegisterFlagCompletionFunc("log-level", fixedCompletions("debug", "info", "warn", "error"))
 
 	// Version will be set dynamically
 }
@@ -0,0 +1,304 @@
+package cli
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/howmanysmall/relay/src/internal/config"
+	"github.com/howmanysmall/relay/src/internal/core"
+	"github.com/howmanysmall/relay/src/internal/display"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run <profile>",
+	Short: "Run the pipeline of mirrors defined by a profile",
+	Long: `Mirror each step of a profile's "pipeline" in order. Every step has its
+own source and destination, and may replace the profile's filters; the rest
+of the profile's settings apply to all of them.
+
+A step that fails stops the pipeline, and the steps after it are skipped,
+unless the step sets "continueOnError". Each step's statistics are printed
+as it finishes. --stats-file reports every step, --error-log collects the
+errors of every step, and --timeout bounds the whole pipeline.
+
+Examples:
+  relay run offsite                       # Pipeline of the offsite profile
+  relay run offsite --dry-run             # Preview every step`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeOneProfile,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		colorEnabled := term.IsTerminal(int(os.Stdout.Fd()))
+		statusRenderer := display.NewStatusRenderer(colorEnabled, false)
+
+		cfg, err := config.NewLoader().Load(configFile)
+		if err != nil {
+			return configError(err)
+		}
+
+		name := args[0]
+
+		prof := profileNamed(cfg, name)
+		if prof == nil {
+			return fmt.Errorf("profile %s not found", name)
+		}
+
+		if len(prof.Pipeline) == 0 {
+			return fmt.Errorf("profile %s has no pipeline", name)
+		}
+
+		auditLog, err := openAuditLog()
+		if err != nil {
+			return err
+		}
+
+		defer closeAuditLog(auditLog, statusRenderer)
+
+		events, err := openEventSocket()
+		if err != nil {
+			return err
+		}
+
+		defer closeEventSocket(events, statusRenderer)
+
+		ctx, cancel := runContext(cmd)
+		defer cancel()
+
+		results := make([]pipelineResult, len(prof.Pipeline))
+
+		var failed error
+
+		for i, step := range prof.Pipeline {
+			result := &results[i]
+			result.step = step
+			result.name = cmp.Or(step.Name, "step "+strconv.Itoa(i+1))
+
+			if failed != nil {
+				result.skipped = true
+				statusRenderer.PrintWarning(fmt.Sprintf("%s: skipped", result.name))
+
+				continue
+			}
+
+			statusRenderer.PrintProgress(fmt.Sprintf("%s: %s → %s", result.name, step.Source, step.Destination))
+
+			result.engine, result.err = runPipelineStep(ctx, prof, step, auditLog, events)
+			if result.engine != nil {
+				result.err = runError(result.engine, runTimeoutError(ctx, result.err))
+			}
+
+			switch {
+			case result.err == nil:
+				stats := result.engine.GetStats()
+				statusRenderer.PrintSuccess(fmt.Sprintf("%s: mirror finished", result.name),
+					fmt.Sprintf("%d files changed in %v", stats.FilesChanged, stats.Duration.Round(time.Millisecond)))
+			case step.ContinueOnError:
+				statusRenderer.PrintWarning(fmt.Sprintf("%s: mirror failed; continuing", result.name), result.err.Error())
+			default:
+				statusRenderer.PrintError(fmt.Sprintf("%s: mirror failed; stopping the pipeline", result.name), result.err.Error())
+
+				failed = fmt.Errorf("pipeline %s failed at %s: %w", name, result.name, result.err)
+			}
+
+			if result.engine != nil && verbose {
+				display.PrintSimpleStats(result.engine, colorEnabled)
+			}
+		}
+
+		writePipelineErrorLog(results, statusRenderer)
+		writePipelineStatsFile(results, statusRenderer)
+		recordStatus(pipelineStatus(name, results), failed, statusRenderer)
+
+		if failed == nil {
+			statusRenderer.PrintSuccess(fmt.Sprintf("Pipeline %s finished", name), pipelineSummary(results))
+		}
+
+		return failed
+	},
+}
+
+// pipelineResult is the outcome of one step of a pipeline.
+type pipelineResult struct {
+	step    config.PipelineStep
+	name    string
+	engine  *core.SyncEngine // nil when the step was skipped or never started
+	err     error
+	skipped bool
+}
+
+// pipelineSummary totals the changes made by the steps of a pipeline.
+func pipelineSummary(results []pipelineResult) string {
+	var changed, failed int64
+
+	for _, result := range results {
+		if result.engine != nil {
+			changed += result.engine.GetStats().FilesChanged
+		}
+
+		if result.err != nil {
+			failed++
+		}
+	}
+
+	summary := fmt.Sprintf("%d steps, %d files changed", len(results), changed)
+	if failed > 0 {
+		summary += fmt.Sprintf(", %d steps failed", failed)
+	}
+
+	return summary
+}
+
+// pipelineStatus returns the status of a run of the pipeline of the profile
+// called name, with the statistics of its steps added up.
+func pipelineStatus(name string, results []pipelineResult) *core.ProfileStatus {
+	status := &core.ProfileStatus{Profile: name, Command: "run"}
+
+	var stats []*core.SyncStats
+
+	for _, result := range results {
+		if result.engine != nil {
+			stats = append(stats, result.engine.GetStats())
+			status.PendingErrors += len(result.engine.GetErrors())
+		}
+	}
+
+	if len(stats) > 0 {
+		status.Stats = core.SumStats(stats...)
+	}
+
+	return status
+}
+
+// runPipelineStep mirrors step with the settings of prof, returning the
+// engine once it has started.
+func runPipelineStep(ctx context.Context, prof *config.Profile, step config.PipelineStep,
+	auditLog *core.AuditLog, events *core.EventStream,
+) (*core.SyncEngine, error) {
+	source, err := filepath.Abs(step.Source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source path: %w", err)
+	}
+
+	destination, err := filepath.Abs(step.Destination)
+	if err != nil {
+		return nil, fmt.Errorf("invalid destination path: %w", err)
+	}
+
+	stepProfile := *prof
+	stepProfile.Source = step.Source
+	stepProfile.Destination = step.Destination
+
+	if step.Filters != nil {
+		stepProfile.Filters = step.Filters
+	}
+
+	engine, err := newProfileEngine(&stepProfile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sync engine: %w", err)
+	}
+
+	opts := engine.Options()
+	opts.DryRun = dryRun
+	engine.SetOptions(opts)
+	engine.SetAuditLog(auditLog)
+	engine.SetEventStream(events)
+
+	return engine, engine.Mirror(ctx, source, destination)
+}
+
+// writePipelineErrorLog writes the errors of every step to --error-log, if
+// set. Failing to write the log is reported but does not fail the run.
+func writePipelineErrorLog(results []pipelineResult, statusRenderer *display.StatusRenderer) {
+	if errorLog == "" {
+		return
+	}
+
+	var stepErrors []*core.SyncError
+
+	for _, result := range results {
+		if result.engine != nil {
+			stepErrors = append(stepErrors, result.engine.GetErrors()...)
+		}
+	}
+
+	handler := core.NewErrorHandler(len(stepErrors))
+	for _, err := range stepErrors {
+		handler.AddError(err)
+	}
+
+	file, err := os.Create(errorLog)
+	if err != nil {
+		statusRenderer.PrintWarning("Failed to write error log", err.Error())
+		return
+	}
+
+	err = handler.WriteJSON(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		statusRenderer.PrintWarning("Failed to write error log", err.Error())
+	}
+}
+
+// writePipelineStatsFile writes the statistics of every step to
+// --stats-file, if set. Failing to write the file is reported but does not
+// fail the run.
+func writePipelineStatsFile(results []pipelineResult, statusRenderer *display.StatusRenderer) {
+	if statsFile == "" {
+		return
+	}
+
+	type stepReport struct {
+		Name        string `json:"name"`
+		Source      string `json:"source"`
+		Destination string `json:"destination"`
+		Error       string `json:"error,omitempty"`
+		Skipped     bool   `json:"skipped,omitempty"`
+		*core.SyncStats
+	}
+
+	var report struct {
+		Steps []stepReport `json:"steps"`
+	}
+
+	for _, result := range results {
+		step := stepReport{
+			Name:        result.name,
+			Source:      result.step.Source,
+			Destination: result.step.Destination,
+			Skipped:     result.skipped,
+		}
+
+		if result.err != nil {
+			step.Error = result.err.Error()
+		}
+
+		if result.engine != nil {
+			step.SyncStats = result.engine.GetStats()
+		}
+
+		report.Steps = append(report.Steps, step)
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err == nil {
+		err = os.WriteFile(statsFile, append(data, '\n'), 0o644)
+	}
+
+	if err != nil {
+		statusRenderer.PrintWarning("Failed to write stats file", err.Error())
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+}
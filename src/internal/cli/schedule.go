@@ -0,0 +1,378 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/howmanysmall/relay/src/internal/config"
+	"github.com/howmanysmall/relay/src/internal/core"
+	"github.com/howmanysmall/relay/src/internal/display"
+	"github.com/robfig/cron/v3"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Stay resident and mirror each profile on its own schedule",
+	Long: `Run as a self-contained backup scheduler. Every profile in the
+configuration file with a "schedule" cron expression is mirrored from its
+source to its destination at those times, until relay is interrupted.
+
+Schedules use the five standard cron fields (minute, hour, day of month,
+month, day of week) in local time, or descriptors such as @daily and
+@every 30m. A run that is still going when its next time comes is skipped
+rather than started twice. Each run's outcome is logged as it finishes and
+recorded for relay status and relay history; --stats-file and --error-log
+describe the most recent run, while --audit-log collects the changes of
+every run.
+
+Without a subcommand, relay schedule runs the scheduler.
+
+Examples:
+  relay schedule run                        # Profiles in the default config
+  relay schedule run --config backups.jsonc # Profiles in a specific file
+  relay schedule list                       # When each profile runs next`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		return runScheduler(cmd)
+	},
+}
+
+var scheduleRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Run the scheduler until interrupted",
+	Long: `Mirror every profile with a schedule at its scheduled times, until relay
+is stopped with Ctrl+C or SIGTERM, which lets running mirrors finish first.
+
+Examples:
+  relay schedule run                        # Profiles in the default config
+  relay schedule run --config backups.jsonc # Profiles in a specific file`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		return runScheduler(cmd)
+	},
+}
+
+var scheduleListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the scheduled profiles and when each runs next",
+	Long: `List every profile with a schedule, its paths and cron expression, and
+when the scheduler would next mirror it.
+
+Examples:
+  relay schedule list                       # Profiles in the default config
+  relay schedule list --config backups.jsonc`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		colorEnabled := term.IsTerminal(int(os.Stdout.Fd()))
+		statusRenderer := display.NewStatusRenderer(colorEnabled, false)
+
+		configPath, cfg, scheduled, err := loadScheduledProfiles()
+		if err != nil {
+			return err
+		}
+
+		cmd.SilenceUsage = true
+
+		fmt.Printf("Schedules in %s:\n", configPath)
+
+		now := time.Now()
+
+		for _, name := range scheduled {
+			prof := profileNamed(cfg, name)
+
+			schedule, err := cron.ParseStandard(prof.Schedule)
+			if err != nil {
+				return fmt.Errorf("invalid schedule for profile %s: %w", name, err)
+			}
+
+			describeSchedule(statusRenderer, name, prof, schedule.Next(now))
+		}
+
+		return nil
+	},
+}
+
+// runScheduler mirrors every scheduled profile at its times until the
+// process is interrupted.
+func runScheduler(cmd *cobra.Command) error {
+	colorEnabled := term.IsTerminal(int(os.Stdout.Fd()))
+	statusRenderer := display.NewStatusRenderer(colorEnabled, false)
+
+	_, cfg, scheduled, err := loadScheduledProfiles()
+	if err != nil {
+		return err
+	}
+
+	auditLog, err := openAuditLog()
+	if err != nil {
+		return err
+	}
+
+	defer closeAuditLog(auditLog, statusRenderer)
+
+	events, err := openEventSocket()
+	if err != nil {
+		return err
+	}
+
+	defer closeEventSocket(events, statusRenderer)
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	scheduler := cron.New()
+
+	for _, name := range scheduled {
+		prof := profileNamed(cfg, name)
+
+		job := &profileMirror{
+			ctx:            ctx,
+			command:        "schedule",
+			name:           name,
+			profile:        prof,
+			statusRenderer: statusRenderer,
+			colorEnabled:   colorEnabled,
+			auditLog:       auditLog,
+			events:         events,
+		}
+
+		if _, err := scheduler.AddJob(prof.Schedule, job); err != nil {
+			return fmt.Errorf("invalid schedule for profile %s: %w", name, err)
+		}
+	}
+
+	for _, name := range scheduled {
+		release, err := registerDaemon(name, "schedule")
+		if err != nil {
+			return err
+		}
+
+		defer releaseDaemon(release, statusRenderer)
+	}
+
+	scheduler.Start()
+
+	for _, entry := range scheduler.Entries() {
+		job := entry.Job.(*profileMirror)
+		describeSchedule(statusRenderer, job.name, job.profile, entry.Next)
+	}
+
+	<-ctx.Done()
+
+	statusRenderer.PrintInfo("Stopping scheduler; waiting for running mirrors to finish")
+	<-scheduler.Stop().Done()
+
+	return nil
+}
+
+// loadScheduledProfiles loads the configuration file given by --config, or
+// the default one, and returns its path, the config and the names of its
+// scheduled profiles, of which there must be at least one.
+func loadScheduledProfiles() (string, *config.Config, []string, error) {
+	loader := config.NewLoader()
+
+	configPath := configFile
+	if configPath == "" {
+		configPath = loader.FindConfig()
+	}
+
+	if configPath == "" {
+		return "", nil, nil, errors.New("relay schedule needs a config file with scheduled profiles")
+	}
+
+	cfg, err := loader.Load(configPath)
+	if err != nil {
+		return "", nil, nil, configError(err)
+	}
+
+	scheduled := scheduledProfiles(cfg)
+	if len(scheduled) == 0 {
+		return "", nil, nil, fmt.Errorf("no profile in %s has a schedule", configPath)
+	}
+
+	return configPath, cfg, scheduled, nil
+}
+
+// describeSchedule prints the paths and schedule of the profile prof called
+// name, and when it next runs.
+func describeSchedule(statusRenderer *display.StatusRenderer, name string, prof *config.Profile, next time.Time) {
+	statusRenderer.PrintInfo(fmt.Sprintf("%s: %s → %s", name, prof.Source, prof.Destination),
+		fmt.Sprintf("schedule %q, next run %s", prof.Schedule, next.Format(time.DateTime)))
+}
+
+// scheduledProfiles returns the names of the profiles in cfg that have a
+// schedule, the default profile first and the rest sorted.
+func scheduledProfiles(cfg *config.Config) []string {
+	var names []string
+
+	for name, prof := range cfg.Profiles {
+		if prof.Schedule != "" && (name != "default" || cfg.Default == nil) {
+			names = append(names, name)
+		}
+	}
+
+	slices.Sort(names)
+
+	if cfg.Default != nil && cfg.Default.Schedule != "" {
+		names = slices.Insert(names, 0, "default")
+	}
+
+	return names
+}
+
+// profileNamed returns the profile called name, as named by scheduledProfiles.
+func profileNamed(cfg *config.Config, name string) *config.Profile {
+	if name == "default" && cfg.Default != nil {
+		return cfg.Default
+	}
+
+	return cfg.Profiles[name]
+}
+
+// profileMirror mirrors one profile on behalf of a resident command: the
+// cron job of relay schedule, or a profile relay daemon keeps in sync. Runs
+// of the same profile never overlap.
+type profileMirror struct {
+	ctx            context.Context
+	command        string // the resident command, recorded as having run the profile
+	name           string
+	profile        *config.Profile
+	statusRenderer *display.StatusRenderer
+	colorEnabled   bool
+	auditLog       *core.AuditLog    // shared by every job; nil when not auditing
+	events         *core.EventStream // shared by every job; nil without --event-socket
+	running        atomic.Bool
+
+	mu      sync.Mutex
+	engine  *core.SyncEngine    // the current run's, while one is going
+	started time.Time           // when the current run started
+	last    *core.ProfileStatus // the last run's outcome
+}
+
+// Run mirrors the profile once and logs the outcome.
+func (j *profileMirror) Run() {
+	if !j.running.CompareAndSwap(false, true) {
+		j.statusRenderer.PrintWarning(fmt.Sprintf("%s: skipped, the previous run is still going", j.name))
+		return
+	}
+	defer j.running.Store(false)
+
+	started := time.Now()
+	j.statusRenderer.PrintProgress(fmt.Sprintf("%s: mirror started at %s", j.name, started.Format(time.DateTime)))
+
+	j.mu.Lock()
+	j.started = started
+	j.mu.Unlock()
+
+	engine, err := j.mirror()
+
+	logMirror(j.name, engine, err)
+
+	switch {
+	case err != nil:
+		j.statusRenderer.PrintError(fmt.Sprintf("%s: mirror failed after %v", j.name, time.Since(started).Round(time.Second)), err.Error())
+	case engine.GetStats().ErrorsEncountered > 0:
+		stats := engine.GetStats()
+		j.statusRenderer.PrintWarning(fmt.Sprintf("%s: mirror finished with errors", j.name),
+			fmt.Sprintf("%d files changed, %d errors in %v", stats.FilesChanged, stats.ErrorsEncountered, stats.Duration.Round(time.Second)))
+	default:
+		stats := engine.GetStats()
+		j.statusRenderer.PrintSuccess(fmt.Sprintf("%s: mirror finished", j.name),
+			fmt.Sprintf("%d files changed in %v", stats.FilesChanged, stats.Duration.Round(time.Second)))
+	}
+
+	if engine == nil {
+		return
+	}
+
+	writeErrorLog(engine, j.statusRenderer)
+	writeStatsFile(engine, j.statusRenderer)
+
+	status := engineStatus(j.name, j.command, j.profile.Source, j.profile.Destination, engine)
+	recordStatus(status, err, j.statusRenderer)
+
+	j.mu.Lock()
+	j.engine = nil
+	j.last = status
+	j.mu.Unlock()
+
+	if verbose {
+		display.PrintSimpleStats(engine, j.colorEnabled)
+	}
+}
+
+// state describes the profile and its current run, if any, for relay
+// daemon status.
+func (j *profileMirror) state() core.DaemonProfileState {
+	state := core.DaemonProfileState{
+		Name:        j.name,
+		Source:      j.profile.Source,
+		Destination: j.profile.Destination,
+		Running:     j.running.Load(),
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if state.Running {
+		state.RunStarted = j.started
+	}
+
+	if j.engine != nil {
+		state.Progress = j.engine.GetProgress()
+	}
+
+	state.Last = j.last
+
+	return state
+}
+
+// mirror runs the profile's mirror, returning the engine once it has started.
+func (j *profileMirror) mirror() (*core.SyncEngine, error) {
+	source, err := filepath.Abs(j.profile.Source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source path: %w", err)
+	}
+
+	destination, err := filepath.Abs(j.profile.Destination)
+	if err != nil {
+		return nil, fmt.Errorf("invalid destination path: %w", err)
+	}
+
+	engine, err := newProfileEngine(j.profile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sync engine: %w", err)
+	}
+
+	opts := engine.Options()
+	opts.DryRun = dryRun
+	engine.SetOptions(opts)
+	engine.SetAuditLog(j.auditLog)
+	engine.SetEventStream(j.events)
+
+	j.mu.Lock()
+	j.engine = engine
+	j.mu.Unlock()
+
+	ctx, cancel := withRunTimeout(j.ctx)
+	defer cancel()
+
+	return engine, runTimeoutError(ctx, engine.Mirror(ctx, source, destination))
+}
+
+func init() {
+	scheduleCmd.AddCommand(scheduleRunCmd)
+	scheduleCmd.AddCommand(scheduleListCmd)
+	rootCmd.AddCommand(scheduleCmd)
+}
@@ -0,0 +1,365 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/howmanysmall/relay/src/internal/core"
+	"github.com/howmanysmall/relay/src/internal/display"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	serviceName    string
+	serviceSystem  bool
+	serviceNoStart bool
+)
+
+var serviceCmd = &cobra.Command{
+	Use:   "service",
+	Short: "Install relay daemon as a service that starts at login or boot",
+	Long: `Register relay daemon with the system's service manager, so that it
+starts by itself and is restarted when it fails:
+
+  - Linux: a systemd unit, a user unit started at login, or with --system a
+    system unit started at boot
+  - macOS: a launchd agent loaded at login, or with --system a launch daemon
+    loaded at boot; output goes to ~/Library/Logs/<name>.log
+  - Windows: a scheduled task run at logon, or with --system at startup as
+    SYSTEM
+
+--system needs administrator rights. --dry-run prints the files and commands
+instead of applying them.`,
+}
+
+var serviceInstallCmd = &cobra.Command{
+	Use:   "install [profile...]",
+	Short: "Install and start relay daemon as a service",
+	Long: `Install relay daemon as a service that keeps the profiles of the
+configuration file, or just those named, in sync, and start it now. The
+service runs this relay executable with the config file in use, given by
+--config or found in the current directory, as an absolute path.
+
+Installing again under the same --name replaces the service.
+
+Examples:
+  relay service install                    # Every profile, at login
+  relay service install photos --name relay-photos
+  relay service install --system           # At boot, for the whole system
+  relay service install --dry-run          # Print the unit without installing`,
+	ValidArgsFunction: completeProfileArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		colorEnabled := term.IsTerminal(int(os.Stdout.Fd()))
+		statusRenderer := display.NewStatusRenderer(colorEnabled, false)
+
+		configPath := statusConfigPath()
+		if configPath == "" {
+			return errors.New("relay service needs a config file; create one with relay init")
+		}
+
+		_, cfg, err := loadProfilesConfig()
+		if err != nil {
+			return err
+		}
+
+		// Catch what relay daemon would refuse now, not once the service fails.
+		for _, name := range args {
+			prof := profileNamed(cfg, name)
+			if prof == nil {
+				return fmt.Errorf("profile %s not found", name)
+			}
+
+			if daemonTrigger(prof) == "" {
+				return fmt.Errorf("profile %s cannot be kept in sync by the daemon: it needs a source and destination, "+
+					"and mirror or watch mode", name)
+			}
+		}
+
+		executable, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("failed to locate the relay executable: %w", err)
+		}
+
+		if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+			executable = resolved
+		}
+
+		plan, err := newServicePlan(core.ServiceSpec{
+			Name:        serviceName,
+			Description: "relay daemon for " + configPath,
+			Command:     append([]string{executable, "daemon", "--config", configPath}, args...),
+			WorkingDir:  filepath.Dir(configPath),
+			System:      serviceSystem,
+		})
+		if err != nil {
+			return err
+		}
+
+		cmd.SilenceUsage = true
+
+		commands := plan.register
+		if !serviceNoStart {
+			commands = append(commands, plan.start...)
+		}
+
+		if dryRun {
+			if plan.path != "" {
+				fmt.Printf("Would write %s:\n\n%s\n", plan.path, plan.content)
+			}
+
+			printServiceCommands(commands)
+
+			return nil
+		}
+
+		if plan.path != "" {
+			if err := os.MkdirAll(filepath.Dir(plan.path), 0o755); err != nil {
+				return fmt.Errorf("failed to create %s: %w", filepath.Dir(plan.path), err)
+			}
+
+			if err := os.WriteFile(plan.path, []byte(plan.content), 0o644); err != nil {
+				return fmt.Errorf("failed to write service file: %w", err)
+			}
+
+			statusRenderer.PrintSuccess("Wrote "+plan.path, plan.content)
+		}
+
+		if err := runServiceCommands(commands); err != nil {
+			return err
+		}
+
+		if serviceNoStart {
+			statusRenderer.PrintSuccess("Installed service "+serviceName, "it starts at the next "+plan.startsAt)
+		} else {
+			statusRenderer.PrintSuccess("Installed and started service "+serviceName, "it starts again at every "+plan.startsAt)
+		}
+
+		return nil
+	},
+}
+
+var serviceUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Stop relay daemon and remove its service",
+	Long: `Stop the service installed by relay service install under --name, and
+remove it so it no longer starts at login or boot.
+
+Examples:
+  relay service uninstall
+  relay service uninstall --name relay-photos
+  relay service uninstall --system`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		colorEnabled := term.IsTerminal(int(os.Stdout.Fd()))
+		statusRenderer := display.NewStatusRenderer(colorEnabled, false)
+
+		plan, err := newServicePlan(core.ServiceSpec{Name: serviceName, System: serviceSystem})
+		if err != nil {
+			return err
+		}
+
+		cmd.SilenceUsage = true
+
+		if dryRun {
+			printServiceCommands(append(plan.stop, plan.unregister...))
+
+			if plan.path != "" {
+				fmt.Printf("Would delete %s\n", plan.path)
+			}
+
+			return nil
+		}
+
+		// The service may not be running, or not loaded; stopping it is best effort.
+		if err := runServiceCommands(plan.stop); err != nil {
+			statusRenderer.PrintWarning("Could not stop service "+serviceName, err.Error())
+		}
+
+		if plan.path != "" {
+			if err := os.Remove(plan.path); errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("service %s is not installed: %s does not exist", serviceName, plan.path)
+			} else if err != nil {
+				return fmt.Errorf("failed to remove service file: %w", err)
+			}
+		}
+
+		if err := runServiceCommands(plan.unregister); err != nil {
+			return err
+		}
+
+		statusRenderer.PrintSuccess("Uninstalled service "+serviceName, plan.path)
+
+		return nil
+	},
+}
+
+// servicePlan is how a service is installed and removed on this platform.
+type servicePlan struct {
+	path       string     // service file to write; empty when the commands register it
+	content    string     // of the service file
+	register   [][]string // commands making the service start at login or boot
+	start      [][]string // commands starting it now
+	stop       [][]string // commands stopping it
+	unregister [][]string // commands run once the service file is removed
+	startsAt   string     // login, logon, boot or startup
+}
+
+// newServicePlan returns how spec is installed and removed on this platform.
+func newServicePlan(spec core.ServiceSpec) (*servicePlan, error) {
+	if spec.Name == "" || strings.ContainsAny(spec.Name, `/\ `) {
+		return nil, fmt.Errorf("invalid service name %q", spec.Name)
+	}
+
+	switch runtime.GOOS {
+	case "linux":
+		return systemdPlan(spec)
+	case "darwin":
+		return launchdPlan(spec)
+	case "windows":
+		return scheduledTaskPlan(spec), nil
+	default:
+		return nil, fmt.Errorf("relay service does not support %s; run relay daemon from your init system", runtime.GOOS)
+	}
+}
+
+// systemdPlan installs spec as a systemd user unit, or system unit with
+// spec.System.
+func systemdPlan(spec core.ServiceSpec) (*servicePlan, error) {
+	systemctl := []string{"systemctl", "--user"}
+	dir := "/etc/systemd/system"
+	startsAt := "login"
+
+	if spec.System {
+		systemctl = []string{"systemctl"}
+		startsAt = "boot"
+	} else {
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to locate config directory: %w", err)
+		}
+
+		dir = filepath.Join(configDir, "systemd", "user")
+	}
+
+	unit := spec.Name + ".service"
+	with := func(args ...string) []string {
+		return append(append([]string{}, systemctl...), args...)
+	}
+
+	return &servicePlan{
+		path:       filepath.Join(dir, unit),
+		content:    core.SystemdUnit(spec),
+		register:   [][]string{with("daemon-reload"), with("enable", unit)},
+		start:      [][]string{with("restart", unit)},
+		stop:       [][]string{with("disable", "--now", unit)},
+		unregister: [][]string{with("daemon-reload")},
+		startsAt:   startsAt,
+	}, nil
+}
+
+// launchdPlan installs spec as a launchd agent, or launch daemon with
+// spec.System.
+func launchdPlan(spec core.ServiceSpec) (*servicePlan, error) {
+	label := spec.Name
+	if !strings.Contains(label, ".") {
+		label = "com.github.howmanysmall." + label
+	}
+
+	domain := "system"
+	dir := "/Library/LaunchDaemons"
+	logDir := "/Library/Logs"
+	startsAt := "boot"
+
+	if !spec.System {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to locate home directory: %w", err)
+		}
+
+		domain = "gui/" + strconv.Itoa(os.Getuid())
+		dir = filepath.Join(home, "Library", "LaunchAgents")
+		logDir = filepath.Join(home, "Library", "Logs")
+		startsAt = "login"
+	}
+
+	spec.LogPath = filepath.Join(logDir, spec.Name+".log")
+	spec.Name = label
+	path := filepath.Join(dir, label+".plist")
+
+	return &servicePlan{
+		path:    path,
+		content: core.LaunchdPlist(spec),
+		// Unload any earlier version first, so that bootstrap loads this one.
+		start:    [][]string{{"launchctl", "bootout", domain + "/" + label}, {"launchctl", "bootstrap", domain, path}},
+		stop:     [][]string{{"launchctl", "bootout", domain + "/" + label}},
+		startsAt: startsAt,
+	}, nil
+}
+
+// scheduledTaskPlan installs spec as a Windows scheduled task run at logon,
+// or at startup as SYSTEM with spec.System.
+func scheduledTaskPlan(spec core.ServiceSpec) *servicePlan {
+	create := []string{"schtasks", "/Create", "/F", "/TN", spec.Name, "/TR", core.WindowsCommandLine(spec.Command)}
+	startsAt := "logon"
+
+	if spec.System {
+		create = append(create, "/SC", "ONSTART", "/RU", "SYSTEM")
+		startsAt = "startup"
+	} else {
+		create = append(create, "/SC", "ONLOGON")
+	}
+
+	return &servicePlan{
+		register:   [][]string{create},
+		start:      [][]string{{"schtasks", "/Run", "/TN", spec.Name}},
+		stop:       [][]string{{"schtasks", "/End", "/TN", spec.Name}},
+		unregister: [][]string{{"schtasks", "/Delete", "/F", "/TN", spec.Name}},
+		startsAt:   startsAt,
+	}
+}
+
+// runServiceCommands runs commands in order, stopping at the first that
+// fails. Their output is shown as it comes. A launchctl bootout of a
+// service that is not loaded is not a failure.
+func runServiceCommands(commands [][]string) error {
+	for _, args := range commands {
+		command := exec.Command(args[0], args[1:]...)
+		command.Stdout = os.Stdout
+		command.Stderr = os.Stderr
+
+		if err := command.Run(); err != nil {
+			if args[0] == "launchctl" && args[1] == "bootout" {
+				continue
+			}
+
+			return fmt.Errorf("%s failed: %w", strings.Join(args, " "), err)
+		}
+	}
+
+	return nil
+}
+
+// printServiceCommands prints the commands a dry run would have run.
+func printServiceCommands(commands [][]string) {
+	for _, args := range commands {
+		fmt.Println("Would run: " + strings.Join(args, " "))
+	}
+}
+
+func init() {
+	serviceCmd.PersistentFlags().StringVar(&serviceName, "name", "relay", "name of the service")
+	serviceCmd.PersistentFlags().BoolVar(&serviceSystem, "system", false, "install for the whole system, started at boot (needs administrator rights)")
+	serviceInstallCmd.Flags().BoolVar(&serviceNoStart, "no-start", false, "install without starting the service now")
+
+	serviceCmd.AddCommand(serviceInstallCmd)
+	serviceCmd.AddCommand(serviceUninstallCmd)
+	rootCmd.AddCommand(serviceCmd)
+}
@@ -0,0 +1,258 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/howmanysmall/relay/src/internal/core"
+	"github.com/howmanysmall/relay/src/internal/display"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	snapshotMethod    string
+	snapshotJSON      bool
+	snapshotRetention core.RetentionPolicy
+)
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot [source] <store>",
+	Short: "Take point-in-time copies of a directory",
+	Long: `Copy a source directory into a new snapshot in a snapshot store, a
+directory holding one subdirectory per snapshot named after when it was taken,
+such as 2026-03-01T09-00-00.
+
+Only files that changed since the newest snapshot in the store are copied.
+Unchanged files are shared with it, so they cost no space: by reflink where
+the store's file system can clone files (Btrfs, XFS, APFS), else by hard link,
+as rsnapshot does. Hard-linked files are one file in every snapshot that holds
+them, so never edit a snapshot in place; --method reflink refuses that sharing
+and copies where cloning is unavailable. A snapshot is written under a hidden
+name and only appears once complete.
+
+With one argument, it is the store, and the source of the profile chosen with
+--profile is snapshotted; the profile's filters and .relayignore files apply.
+The --keep flags prune old snapshots once the new one is taken, like relay
+snapshot prune.
+
+Without a subcommand, relay snapshot creates a snapshot.
+
+Examples:
+  relay snapshot ~/Documents /backup/documents
+  relay snapshot /backup/documents --profile docs
+  relay snapshot ~/Documents /backup/documents --keep-daily 7 --keep-weekly 4
+  relay snapshot list /backup/documents
+  relay snapshot prune /backup/documents --keep-last 10`,
+	Args:              cobra.RangeArgs(1, 2),
+	ValidArgsFunction: completeDirPair,
+	RunE:              runSnapshotCreate,
+}
+
+var snapshotCreateCmd = &cobra.Command{
+	Use:   "create [source] <store>",
+	Short: "Take a snapshot of a directory",
+	Long: `Copy a source directory into a new snapshot in a snapshot store, sharing
+files unchanged since the newest snapshot instead of copying them.
+
+Examples:
+  relay snapshot create ~/Documents /backup/documents
+  relay snapshot create /backup/documents --profile docs --keep-daily 7`,
+	Args:              cobra.RangeArgs(1, 2),
+	ValidArgsFunction: completeDirPair,
+	RunE:              runSnapshotCreate,
+}
+
+var snapshotListCmd = &cobra.Command{
+	Use:   "list <store>",
+	Short: "List the snapshots in a store",
+	Long: `List the snapshots in a snapshot store, oldest first, with their size and
+how much each copied rather than shared with the snapshot before it.
+
+Examples:
+  relay snapshot list /backup/documents
+  relay snapshot list /backup/documents --json`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeOneDir,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		colorEnabled := term.IsTerminal(int(os.Stdout.Fd()))
+
+		cmd.SilenceUsage = true
+
+		snapshots, err := core.ListSnapshots(args[0])
+		if err != nil {
+			return err
+		}
+
+		if snapshotJSON {
+			return printSnapshotJSON(snapshots)
+		}
+
+		fmt.Println(display.RenderSnapshots(args[0], snapshots, colorEnabled))
+
+		return nil
+	},
+}
+
+var snapshotPruneCmd = &cobra.Command{
+	Use:   "prune <store>",
+	Short: "Delete the snapshots a retention policy does not keep",
+	Long: `Delete old snapshots from a snapshot store. The --keep flags say which to
+keep: --keep-last N keeps the newest N, and --keep-daily N keeps the newest
+snapshot of each of the last N days that have one, and likewise for the other
+periods. A snapshot any flag keeps is kept, and the newest snapshot is always
+kept. Use --dry-run to see what would be deleted.
+
+Examples:
+  relay snapshot prune /backup/documents --keep-last 10
+  relay snapshot prune /backup/documents --keep-daily 7 --keep-weekly 4 --keep-monthly 12
+  relay snapshot prune /backup/documents --keep-daily 7 --dry-run`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeOneDir,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		colorEnabled := term.IsTerminal(int(os.Stdout.Fd()))
+
+		if snapshotRetention.Empty() {
+			return errors.New("no retention policy: set at least one --keep flag")
+		}
+
+		cmd.SilenceUsage = true
+
+		return pruneSnapshots(args[0], display.NewStatusRenderer(colorEnabled, false))
+	},
+}
+
+// runSnapshotCreate takes a snapshot of the source into the store, then
+// prunes the store when a retention policy is set.
+func runSnapshotCreate(cmd *cobra.Command, args []string) error {
+	colorEnabled := term.IsTerminal(int(os.Stdout.Fd()))
+	statusRenderer := display.NewStatusRenderer(colorEnabled, false)
+
+	if !slices.Contains([]string{core.SnapshotAuto, core.SnapshotHardlink, core.SnapshotReflink}, snapshotMethod) {
+		return fmt.Errorf("invalid --method %q: must be auto, hardlink or reflink", snapshotMethod)
+	}
+
+	prof, err := loadProfile()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	source, store := prof.Source, args[0]
+	if len(args) == 2 {
+		source, store = args[0], args[1]
+	}
+
+	if source == "" {
+		return errors.New("no source: pass one, or choose a profile with a source")
+	}
+
+	filter, err := buildFileFilter(prof)
+	if err != nil {
+		return err
+	}
+
+	cmd.SilenceUsage = true
+
+	if dryRun {
+		statusRenderer.PrintInfo("Would snapshot "+source, "into "+store)
+		return nil
+	}
+
+	ctx, cancel := runContext(cmd)
+	defer cancel()
+
+	snapshot, err := core.CreateSnapshot(ctx, source, store, core.SnapshotOptions{Method: snapshotMethod, Filter: filter})
+	if err != nil {
+		return runTimeoutError(ctx, err)
+	}
+
+	if snapshotJSON {
+		if err := printSnapshotJSON(snapshot); err != nil {
+			return err
+		}
+	} else {
+		details := []string{
+			fmt.Sprintf("%s in %s", display.FormatBytes(snapshot.Size), display.CountOf(snapshot.Files, "file", "files")),
+			fmt.Sprintf("%s copied (%s)", display.CountOf(snapshot.Copied, "file", "files"), display.FormatBytes(snapshot.CopiedBytes)),
+		}
+
+		if snapshot.Shared > 0 {
+			details = append(details, fmt.Sprintf("%s shared by %s (%s)",
+				display.CountOf(snapshot.Shared, "file", "files"), snapshot.Method, display.FormatBytes(snapshot.SharedBytes)))
+		}
+
+		statusRenderer.PrintSuccess("Snapshot "+snapshot.Path, details...)
+	}
+
+	if !snapshotRetention.Empty() {
+		if err := pruneSnapshots(store, statusRenderer); err != nil {
+			return err
+		}
+	}
+
+	if snapshot.Unreadable > 0 {
+		return fmt.Errorf("%s could not be read and are missing from the snapshot",
+			display.CountOf(int64(snapshot.Unreadable), "source entry", "source entries"))
+	}
+
+	return nil
+}
+
+// pruneSnapshots deletes the snapshots in store the retention policy does not
+// keep, or only reports them on a dry run.
+func pruneSnapshots(store string, statusRenderer *display.StatusRenderer) error {
+	removed, err := core.PruneSnapshots(store, snapshotRetention, dryRun)
+
+	names := make([]string, len(removed))
+	for i, snapshot := range removed {
+		names[i] = snapshot.Name
+	}
+
+	switch {
+	case len(removed) == 0:
+	case dryRun:
+		statusRenderer.PrintInfo("Would delete "+display.CountOf(int64(len(removed)), "snapshot", "snapshots"), names...)
+	default:
+		statusRenderer.PrintSuccess("Deleted "+display.CountOf(int64(len(removed)), "snapshot", "snapshots"), names...)
+	}
+
+	return err
+}
+
+// printSnapshotJSON prints v, a snapshot or list of them, as JSON.
+func printSnapshotJSON(v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshots: %w", err)
+	}
+
+	fmt.Println(string(data))
+
+	return nil
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{snapshotCmd, snapshotCreateCmd} {
+		cmd.Flags().StringVar(&snapshotMethod, "method", core.SnapshotAuto, "how unchanged files are shared with the previous snapshot: auto, hardlink or reflink")
+		cmd.Flags().BoolVar(&snapshotJSON, "json", false, "print the snapshot as JSON")
+	}
+
+	for _, cmd := range []*cobra.Command{snapshotCmd, snapshotCreateCmd, snapshotPruneCmd} {
+		cmd.Flags().IntVar(&snapshotRetention.Last, "keep-last", 0, "keep the newest N snapshots")
+		cmd.Flags().IntVar(&snapshotRetention.Hourly, "keep-hourly", 0, "keep the newest snapshot of each of the last N hours")
+		cmd.Flags().IntVar(&snapshotRetention.Daily, "keep-daily", 0, "keep the newest snapshot of each of the last N days")
+		cmd.Flags().IntVar(&snapshotRetention.Weekly, "keep-weekly", 0, "keep the newest snapshot of each of the last N weeks")
+		cmd.Flags().IntVar(&snapshotRetention.Monthly, "keep-monthly", 0, "keep the newest snapshot of each of the last N months")
+		cmd.Flags().IntVar(&snapshotRetention.Yearly, "keep-yearly", 0, "keep the newest snapshot of each of the last N years")
+	}
+
+	snapshotListCmd.Flags().BoolVar(&snapshotJSON, "json", false, "print the snapshots as JSON")
+
+	snapshotCmd.AddCommand(snapshotCreateCmd)
+	snapshotCmd.AddCommand(snapshotListCmd)
+	snapshotCmd.AddCommand(snapshotPruneCmd)
+	rootCmd.AddCommand(snapshotCmd)
+}
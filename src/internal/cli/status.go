@@ -0,0 +1,180 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/howmanysmall/relay/src/internal/config"
+	"github.com/howmanysmall/relay/src/internal/core"
+	"github.com/howmanysmall/relay/src/internal/display"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status [profile...]",
+	Short: "Show when each profile last ran and how it went",
+	Long: `Show, for every profile in the configuration file or just those named,
+when it last synced, which command ran it, how many files that run changed,
+how many files it could not sync, and whether relay schedule or relay daemon
+is running it now.
+
+Runs of mirror, sync, run, schedule and daemon are recorded under the
+profile they used, --profile for mirror and sync; dry runs are not. The records are kept
+in the user cache directory, per config file and profile.
+
+Examples:
+  relay status                             # Profiles in the default config
+  relay status nas offsite                 # Just these profiles
+  relay status --config backups.jsonc      # Profiles in a specific file`,
+	ValidArgsFunction: completeProfileArgs,
+	RunE: func(_ *cobra.Command, args []string) error {
+		colorEnabled := term.IsTerminal(int(os.Stdout.Fd()))
+		configPath := statusConfigPath()
+
+		cfg, err := config.NewLoader().Load(configPath)
+		if err != nil {
+			return err
+		}
+
+		names := args
+		if len(names) == 0 {
+			names = display.ProfileNames(cfg)
+		}
+
+		entries := make([]display.ProfileStatusEntry, len(names))
+
+		for i, name := range names {
+			prof := profileNamed(cfg, name)
+			if prof == nil {
+				return fmt.Errorf("profile %s not found", name)
+			}
+
+			path, err := core.DefaultStatusPath(configPath, name)
+			if err != nil {
+				return err
+			}
+
+			last, err := core.ReadProfileStatus(path)
+			if err != nil {
+				return err
+			}
+
+			entries[i] = display.ProfileStatusEntry{Name: name, Profile: prof, Last: last, Daemon: core.ReadDaemonStatus(path)}
+		}
+
+		if configPath == "" {
+			fmt.Println("No config file found; built-in defaults:")
+		} else {
+			fmt.Printf("Status of %s:\n", configPath)
+		}
+
+		fmt.Println(display.RenderProfileStatus(entries, time.Now(), colorEnabled))
+
+		return nil
+	},
+}
+
+// statusConfigPath returns the absolute path of the config file in use, the
+// one named by --config or else the one relay finds, or "" when there is
+// none. Run status is recorded per config file.
+func statusConfigPath() string {
+	configPath := configFile
+	if configPath == "" {
+		configPath = config.NewLoader().FindConfig()
+	}
+
+	if configPath == "" {
+		return ""
+	}
+
+	if absConfig, err := filepath.Abs(configPath); err == nil {
+		configPath = absConfig
+	}
+
+	return configPath
+}
+
+// selectedProfile returns the name of the profile chosen by --profile.
+func selectedProfile() string {
+	if profile == "" {
+		return "default"
+	}
+
+	return profile
+}
+
+// engineStatus returns the status of a run of the profile called name by
+// command, made with engine.
+func engineStatus(name, command, source, destination string, engine *core.SyncEngine) *core.ProfileStatus {
+	return &core.ProfileStatus{
+		Profile:       name,
+		Command:       command,
+		Source:        source,
+		Destination:   destination,
+		PendingErrors: len(engine.GetErrors()),
+		Stats:         engine.GetStats(),
+	}
+}
+
+// recordStatus saves status, the outcome of a run that failed with runErr if
+// it did, for relay status and appends it to the journal relay history
+// reads. Dry runs and declined plans change nothing and are not recorded.
+// Failing to save the status is reported but does not fail the run.
+func recordStatus(status *core.ProfileStatus, runErr error, statusRenderer *display.StatusRenderer) {
+	if dryRun || errors.Is(runErr, errPlanDeclined) {
+		return
+	}
+
+	status.Config = statusConfigPath()
+	status.Finished = time.Now()
+
+	if runErr != nil {
+		status.Error = runErr.Error()
+	}
+
+	path, err := core.DefaultStatusPath(status.Config, status.Profile)
+	if err == nil {
+		err = core.WriteProfileStatus(path, status)
+	}
+
+	if err != nil {
+		statusRenderer.PrintWarning("Failed to record run status", err.Error())
+	}
+
+	path, err = core.DefaultJournalPath()
+	if err == nil {
+		err = core.AppendJournal(path, status)
+	}
+
+	if err != nil {
+		statusRenderer.PrintWarning("Failed to record run history", err.Error())
+	}
+}
+
+// registerDaemon records, for relay status, that this process runs the
+// profile called name until the returned function is called.
+func registerDaemon(name, command string) (func() error, error) {
+	path, err := core.DefaultStatusPath(statusConfigPath(), name)
+	if err != nil {
+		return nil, err
+	}
+
+	return core.RegisterDaemon(path, command)
+}
+
+// releaseDaemon removes the record made by registerDaemon. Failing to remove
+// it is reported but harmless: relay status ignores the records of processes
+// that have exited.
+func releaseDaemon(release func() error, statusRenderer *display.StatusRenderer) {
+	if err := release(); err != nil {
+		statusRenderer.PrintWarning("Failed to clear daemon status", err.Error())
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+}
@@ -2,9 +2,14 @@ package cli
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 
+	"github.com/howmanysmall/relay/src/internal/config"
+	"github.com/howmanysmall/relay/src/internal/core"
+	"github.com/howmanysmall/relay/src/internal/display"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 var (
@@ -18,16 +23,23 @@ var syncCmd = &cobra.Command{
 	Use:   "sync <path1> <path2>",
 	Short: "Two-way synchronization between two directories",
 	Long: `Synchronize files between two directories in both directions.
-Changes in either directory will be reflected in the other, with
-intelligent conflict resolution.
+Files created, changed or deleted in either directory since the last sync
+between them are created, changed or deleted in the other. The first sync
+merges the two and deletes nothing.
+
+A file changed in both directories is a conflict, resolved by the flags
+below or the profile's conflict strategy; path1 is the local side and path2
+the remote one. A file deleted on one side and changed on the other is
+copied back.
 
 Examples:
   relay sync ./local ./remote             # Basic two-way sync
   relay sync ./a ./b --prefer-local       # Local changes win conflicts
   relay sync ./a ./b --ask                # Interactive conflict resolution
   relay sync ./docs ./backup --backup     # Create backups before overwriting`,
-	Args: cobra.ExactArgs(2),
-	RunE: func(_ *cobra.Command, args []string) error {
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeDirPair,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		path1, err := filepath.Abs(args[0])
 		if err != nil {
 			return fmt.Errorf("invalid path1: %w", err)
@@ -38,6 +50,9 @@ Examples:
 			return fmt.Errorf("invalid path2: %w", err)
 		}
 
+		stdout, restoreStdout := redirectForJSON()
+		defer restoreStdout()
+
 		fmt.Printf("🔄 Relay Sync\n")
 		fmt.Printf("Path 1:      %s\n", path1)
 		fmt.Printf("Path 2:      %s\n", path2)
@@ -57,16 +72,116 @@ Examples:
 			fmt.Printf("Status:      Dry run (preview mode)\n")
 		}
 
-		// TODO: Implement sync logic
-		return fmt.Errorf("sync functionality not yet implemented")
+		colorEnabled := term.IsTerminal(int(os.Stdout.Fd()))
+		statusRenderer := display.NewStatusRenderer(colorEnabled, false)
+
+		prof, err := loadProfile()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		engine, err := newProfileEngine(prof)
+		if err != nil {
+			return fmt.Errorf("failed to create sync engine: %w", err)
+		}
+
+		engine.SetConflictConfig(syncConflictConfig(prof.Conflict))
+
+		opts := engine.Options()
+		opts.DryRun = dryRun
+		engine.SetOptions(opts)
+
+		statePath, err := core.DefaultTwoWayStatePath(path1, path2)
+		if err != nil {
+			return err
+		}
+
+		engine.SetTwoWayState(statePath)
+
+		if jsonOutput() {
+			engine.SetChangeList(true, nil)
+			engine.SetDryRunChanges(true)
+		}
+
+		auditLog, err := openAuditLog()
+		if err != nil {
+			return err
+		}
+
+		defer closeAuditLog(auditLog, statusRenderer)
+
+		engine.SetAuditLog(auditLog)
+
+		events, err := openEventSocket()
+		if err != nil {
+			return err
+		}
+
+		defer closeEventSocket(events, statusRenderer)
+
+		engine.SetEventStream(events)
+
+		ctx, cancel := runContext(cmd)
+		defer cancel()
+
+		fmt.Println()
+
+		err = runTimeoutError(ctx, engine.SyncTwoWay(ctx, path1, path2))
+		writeErrorLog(engine, statusRenderer)
+		writeStatsFile(engine, statusRenderer)
+		recordStatus(engineStatus(selectedProfile(), "sync", path1, path2, engine), err, statusRenderer)
+
+		if jsonOutput() {
+			if reportErr := printRunReport(stdout, "sync", path1, path2, engine, err); reportErr != nil {
+				return reportErr
+			}
+		}
+
+		if err != nil {
+			statusRenderer.PrintError("Sync operation failed", err.Error())
+			return runError(engine, fmt.Errorf("sync operation failed: %w", err))
+		}
+
+		if len(engine.GetErrors()) > 0 {
+			statusRenderer.PrintWarning("Sync completed with errors")
+		} else {
+			statusRenderer.PrintSuccess("Sync completed successfully!")
+		}
+
+		display.PrintSimpleStats(engine, colorEnabled)
+
+		return runError(engine, nil)
 	},
 }
 
+// syncConflictConfig returns the profile's conflict settings, cfg, with the
+// sync flags applied. Path1 is the local side and path2 the remote one.
+func syncConflictConfig(cfg *config.ConflictConfig) *config.ConflictConfig {
+	merged := config.ConflictConfig{Strategy: string(config.ConflictNewest)}
+	if cfg != nil {
+		merged = *cfg
+	}
+
+	switch {
+	case preferLocal:
+		merged.Strategy = string(config.ConflictSource)
+	case preferRemote:
+		merged.Strategy = string(config.ConflictDestination)
+	}
+
+	merged.Interactive = merged.Interactive || ask
+	merged.Backup = merged.Backup || backup
+
+	return &merged
+}
+
 func init() {
 	syncCmd.Flags().BoolVar(&preferLocal, "prefer-local", false, "prefer local files in conflicts")
 	syncCmd.Flags().BoolVar(&preferRemote, "prefer-remote", false, "prefer remote files in conflicts")
 	syncCmd.Flags().BoolVar(&ask, "ask", false, "interactive conflict resolution")
 	syncCmd.Flags().BoolVar(&backup, "backup", false, "create backups before overwriting")
 
+	syncCmd.MarkFlagsMutuallyExclusive("prefer-local", "prefer-remote", "ask")
+
 	rootCmd.AddCommand(syncCmd)
 }
@@ -1,37 +1,115 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
+	"os"
 
+	"github.com/howmanysmall/relay/src/internal/config"
+	"github.com/howmanysmall/relay/src/internal/display"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
+var fixConfig bool
+
 var validateCmd = &cobra.Command{
-	Use:   "validate",
+	Use:   "validate [config-file]",
 	Short: "Validate configuration files",
 	Long: `Validate the syntax and semantics of Relay configuration files.
 This command checks for proper JSON/JSONC/TOML syntax, validates against
 the schema, and reports any configuration issues.
 
+Syntax errors are reported with their line and column. Keys relay does not
+know, sources that do not exist and malformed include or exclude globs are
+errors; destinations that do not exist yet are only warnings, since relay
+creates them. Relative paths are checked against the working directory.
+The command fails when the file has any errors.
+
+With --fix, a file without errors is written back with the defaults relay
+fills in made explicit. Comments in a JSONC file are not kept.
+
 Examples:
   relay validate                           # Validate default config
-  relay validate --config myproject.jsonc # Validate specific config
-  relay validate --profile production     # Validate specific profile`,
-	RunE: func(_ *cobra.Command, _ []string) error {
+  relay validate myproject.jsonc           # Validate specific config
+  relay validate --config myproject.jsonc  # The same
+  relay validate --fix                     # Write back normalized defaults`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeOneConfigFile,
+	RunE: func(_ *cobra.Command, args []string) error {
+		colorEnabled := term.IsTerminal(int(os.Stdout.Fd()))
+		statusRenderer := display.NewStatusRenderer(colorEnabled, false)
+		loader := config.NewLoader()
+
 		configPath := configFile
+		if len(args) == 1 {
+			configPath = args[0]
+		}
+
 		if configPath == "" {
-			configPath = "relay.jsonc"
+			configPath = loader.FindConfig()
+		}
+
+		if configPath == "" {
+			return errors.New("no config file found; pass one to validate")
 		}
 
 		fmt.Printf("🔍 Relay Validate\n")
 		fmt.Printf("Config:      %s\n", configPath)
-		fmt.Printf("Profile:     %s\n", profile)
+		fmt.Println()
+
+		issues, err := loader.Check(configPath)
+		if err != nil {
+			return err
+		}
+
+		var errorCount int
+
+		for _, issue := range issues {
+			if issue.Severity == config.SeverityError {
+				errorCount++
 
-		// TODO: Implement validation logic
-		return fmt.Errorf("validate functionality not yet implemented")
+				statusRenderer.PrintError(issue.String())
+			} else {
+				statusRenderer.PrintWarning(issue.String())
+			}
+		}
+
+		if errorCount > 0 {
+			return fmt.Errorf("%s has %d errors", configPath, errorCount)
+		}
+
+		if fixConfig {
+			if err := fixConfigFile(loader, configPath); err != nil {
+				return err
+			}
+
+			statusRenderer.PrintSuccess(fmt.Sprintf("Wrote %s with normalized defaults", configPath))
+		}
+
+		statusRenderer.PrintSuccess(fmt.Sprintf("%s is valid", configPath))
+
+		return nil
 	},
 }
 
+// fixConfigFile writes the config file at path back with the defaults Load
+// fills in spelled out.
+func fixConfigFile(loader *config.Loader, path string) error {
+	cfg, err := loader.Read(path)
+	if err != nil {
+		return err
+	}
+
+	if err := loader.Normalize(cfg); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	return config.Save(cfg, path)
+}
+
 func init() {
+	validateCmd.Flags().BoolVar(&fixConfig, "fix", false, "write the config back with normalized defaults when it has no errors")
+
 	rootCmd.AddCommand(validateCmd)
 }
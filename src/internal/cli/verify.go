@@ -0,0 +1,185 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/howmanysmall/relay/src/internal/config"
+	"github.com/howmanysmall/relay/src/internal/core"
+	"github.com/howmanysmall/relay/src/internal/display"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	repair       bool
+	verifyJSON   bool
+	checksumAlgo string
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [source destination]",
+	Short: "Check that a destination holds an intact copy of its source",
+	Long: `Hash every file of the source again, together with its copy in the
+destination, and report the copies that are missing or different. Without
+paths, the source and destination of --profile are verified. Files only in
+the destination are not reported; relay diff lists them.
+
+--repair copies each missing or different file again and verifies the new
+copy. The exit status is 0 when every copy is intact, or was repaired, and
+14 (corruption) or the code of the dominant error otherwise.
+
+Examples:
+  relay verify ./photos /mnt/nas/photos            # Verify a mirror
+  relay verify --profile nas                       # Verify a profile's mirror
+  relay verify ./src ./dst --checksum-algo sha256  # Hash with SHA-256
+  relay verify ./src ./dst --repair                # Copy damaged files again
+  relay verify ./src ./dst --json                  # Machine-readable report`,
+	Args: func(_ *cobra.Command, args []string) error {
+		if len(args) != 0 && len(args) != 2 {
+			return fmt.Errorf("accepts no paths or a source and a destination, received %d", len(args))
+		}
+
+		return nil
+	},
+	ValidArgsFunction: completeDirPair,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		colorEnabled := term.IsTerminal(int(os.Stdout.Fd()))
+		statusRenderer := display.NewStatusRenderer(colorEnabled, false)
+
+		prof, err := loadProfile()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		source, destination, err := verifyPaths(prof, args)
+		if err != nil {
+			return err
+		}
+
+		engine, err := newProfileEngine(prof)
+		if err != nil {
+			return fmt.Errorf("failed to create sync engine: %w", err)
+		}
+
+		if checksumAlgo != "" {
+			if err := engine.SetChecksumAlgorithm(checksumAlgo); err != nil {
+				return fmt.Errorf("invalid --checksum-algo: %w", err)
+			}
+
+			if err := checkChecksumSeed(checksumAlgo, checksumSeedFor(prof)); err != nil {
+				return configError(err)
+			}
+		}
+
+		opts := engine.Options()
+		opts.DryRun = dryRun
+
+		opts.ChecksumBlockReport, err = config.ParseSize(blockReport)
+		if err != nil {
+			return fmt.Errorf("invalid --checksum-block-report: %w", err)
+		}
+
+		engine.SetOptions(opts)
+
+		cmd.SilenceUsage = true
+
+		ctx, cancel := runContext(cmd)
+		defer cancel()
+
+		report, err := engine.VerifyTree(ctx, source, destination, repair)
+		err = runTimeoutError(ctx, err)
+		writeErrorLog(engine, statusRenderer)
+		writeStatsFile(engine, statusRenderer)
+
+		if report == nil {
+			return runError(engine, err)
+		}
+
+		if verifyJSON {
+			data, jsonErr := json.MarshalIndent(report, "", "  ")
+			if jsonErr != nil {
+				return fmt.Errorf("failed to encode report: %w", jsonErr)
+			}
+
+			fmt.Println(string(data))
+
+			return runError(engine, err)
+		}
+
+		printVerifyReport(report, statusRenderer)
+
+		switch {
+		case errors.Is(err, core.ErrVerifyMismatch):
+			statusRenderer.PrintError("Destination does not match the source", err.Error())
+		case err != nil:
+			statusRenderer.PrintError("Verification failed", err.Error())
+		case len(report.Failures) > 0:
+			statusRenderer.PrintSuccess(fmt.Sprintf("Destination repaired: %d files copied again", len(report.Failures)))
+		default:
+			statusRenderer.PrintSuccess(fmt.Sprintf("Destination verified: %d files intact", report.Verified))
+		}
+
+		return runError(engine, err)
+	},
+}
+
+// verifyPaths returns the absolute source and destination to verify: those
+// given as arguments, or else those of prof.
+func verifyPaths(prof *config.Profile, args []string) (string, string, error) {
+	source, destination := prof.Source, prof.Destination
+	if len(args) == 2 {
+		source, destination = args[0], args[1]
+	}
+
+	if source == "" || destination == "" {
+		return "", "", errors.New("relay verify needs a source and a destination, as arguments or from --profile")
+	}
+
+	absSource, err := filepath.Abs(source)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid source path: %w", err)
+	}
+
+	absDestination, err := filepath.Abs(destination)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid destination path: %w", err)
+	}
+
+	return absSource, absDestination, nil
+}
+
+// printVerifyReport lists the files verify found missing or different and
+// whether each was repaired.
+func printVerifyReport(report *core.VerifyReport, statusRenderer *display.StatusRenderer) {
+	fmt.Printf("Source:      %s\nDestination: %s\n\n", report.Source, report.Destination)
+
+	for _, failure := range report.Failures {
+		switch {
+		case failure.Repaired:
+			statusRenderer.PrintSuccess("Repaired "+failure.Path, failure.Reason)
+		case dryRun && repair:
+			statusRenderer.PrintWarning("Would repair "+failure.Path, failure.Reason)
+		default:
+			statusRenderer.PrintError(failure.Path, failure.Reason)
+		}
+	}
+
+	if len(report.Failures) > 0 {
+		fmt.Println()
+	}
+}
+
+func init() {
+	verifyCmd.Flags().BoolVar(&repair, "repair", false, "copy missing and different files again and verify the new copies")
+	verifyCmd.Flags().BoolVar(&verifyJSON, "json", false, "print the report as JSON")
+	verifyCmd.Flags().StringVar(&checksumAlgo, "checksum-algo", "", "checksum algorithm to verify with: blake3, md5 or sha256 (default: the profile's, else blake3)")
+	verifyCmd.Flags().StringVar(&blockReport, "checksum-block-report", "", "compare different files with their source in blocks of this size and report the byte ranges that differ")
+	verifyCmd.Flags().Lookup("checksum-block-report").NoOptDefVal = "1MB"
+	_ = verifyCmd.RegisterFlagCompletionFunc("checksum-algo", completeChecksumAlgos)
+
+	rootCmd.AddCommand(verifyCmd)
+}
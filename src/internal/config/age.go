@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ageUnits maps the day and week suffixes ParseAge accepts on top of those
+// of time.ParseDuration to their lengths.
+var ageUnits = map[string]time.Duration{
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+}
+
+// ParseAge parses an age such as "30d", "2w" or "12h" into a duration. Days
+// and weeks are whole numbers of 24-hour days; any other value is parsed by
+// time.ParseDuration. Ages are non-negative.
+func ParseAge(age string) (time.Duration, error) {
+	trimmed := strings.TrimSpace(age)
+
+	for suffix, unit := range ageUnits {
+		number, ok := strings.CutSuffix(trimmed, suffix)
+		if !ok {
+			continue
+		}
+
+		count, err := strconv.Atoi(number)
+		if err != nil || count < 0 {
+			return 0, fmt.Errorf("invalid age %q: expected a whole number of %s", age, unitName(suffix))
+		}
+
+		return time.Duration(count) * unit, nil
+	}
+
+	duration, err := time.ParseDuration(trimmed)
+	if err != nil {
+		return 0, fmt.Errorf("invalid age %q: %w", age, err)
+	}
+
+	if duration < 0 {
+		return 0, fmt.Errorf("invalid age %q: must be non-negative", age)
+	}
+
+	return duration, nil
+}
+
+func unitName(suffix string) string {
+	if suffix == "w" {
+		return "weeks"
+	}
+
+	return "days"
+}
+
+// sinceLayouts are the timestamps ParseSince accepts besides an age; all but
+// RFC 3339 are in local time.
+var sinceLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// ParseSince parses a point in time given as an age before now, such as "1h"
+// or "2d" (see ParseAge), an RFC 3339 timestamp, or a local date or date and
+// time such as "2024-01-31" or "2024-01-31 09:00".
+func ParseSince(value string, now time.Time) (time.Time, error) {
+	trimmed := strings.TrimSpace(value)
+
+	for _, layout := range sinceLayouts {
+		if cutoff, err := time.ParseInLocation(layout, trimmed, now.Location()); err == nil {
+			return cutoff, nil
+		}
+	}
+
+	age, err := ParseAge(trimmed)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: expected an age such as 2d or a date such as 2024-01-31", value)
+	}
+
+	return now.Add(-age), nil
+}
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BandwidthWindow maps a time-of-day window such as "09:00-17:00" (or
+// "default") to a bandwidth limit such as "5MB" (per second) or "unlimited".
+type BandwidthWindow struct {
+	Window string `json:"window" toml:"window" yaml:"window"`
+	Limit  string `json:"limit" toml:"limit" yaml:"limit"`
+}
+
+// BandwidthSchedule resolves the bandwidth limit in effect at a given time of
+// day. A limit of zero means unlimited.
+type BandwidthSchedule struct {
+	rules        []bandwidthRule
+	defaultLimit int64
+}
+
+type bandwidthRule struct {
+	start int // minutes since midnight, inclusive
+	end   int // minutes since midnight, exclusive
+	limit int64
+}
+
+// ParseBandwidthSchedule parses a schedule such as
+// "09:00-17:00:5MB,default:unlimited". A bare limit such as "10MB" applies at
+// all times.
+func ParseBandwidthSchedule(spec string) (*BandwidthSchedule, error) {
+	var windows []BandwidthWindow
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		separator := strings.LastIndex(entry, ":")
+		if separator == -1 {
+			windows = append(windows, BandwidthWindow{Window: "default", Limit: entry})
+			continue
+		}
+
+		windows = append(windows, BandwidthWindow{
+			Window: strings.TrimSpace(entry[:separator]),
+			Limit:  strings.TrimSpace(entry[separator+1:]),
+		})
+	}
+
+	return NewBandwidthSchedule(windows)
+}
+
+// NewBandwidthSchedule builds a schedule from configured windows. When
+// windows overlap, the first matching window wins.
+func NewBandwidthSchedule(windows []BandwidthWindow) (*BandwidthSchedule, error) {
+	schedule := &BandwidthSchedule{}
+
+	for _, window := range windows {
+		limit, err := parseBandwidthLimit(window.Limit)
+		if err != nil {
+			return nil, fmt.Errorf("invalid limit for window %q: %w", window.Window, err)
+		}
+
+		if window.Window == "default" || window.Window == "" {
+			schedule.defaultLimit = limit
+			continue
+		}
+
+		startText, endText, found := strings.Cut(window.Window, "-")
+		if !found {
+			return nil, fmt.Errorf("invalid window %q: expected HH:MM-HH:MM", window.Window)
+		}
+
+		start, err := parseClock(startText)
+		if err != nil {
+			return nil, fmt.Errorf("invalid window %q: %w", window.Window, err)
+		}
+
+		end, err := parseClock(endText)
+		if err != nil {
+			return nil, fmt.Errorf("invalid window %q: %w", window.Window, err)
+		}
+
+		if start == end {
+			return nil, fmt.Errorf("invalid window %q: start and end are equal", window.Window)
+		}
+
+		schedule.rules = append(schedule.rules, bandwidthRule{start: start, end: end, limit: limit})
+	}
+
+	return schedule, nil
+}
+
+// LimitAt returns the bandwidth limit in bytes per second at the given time,
+// or zero when transfers are unlimited.
+func (s *BandwidthSchedule) LimitAt(t time.Time) int64 {
+	minute := t.Hour()*60 + t.Minute()
+
+	for _, rule := range s.rules {
+		if rule.contains(minute) {
+			return rule.limit
+		}
+	}
+
+	return s.defaultLimit
+}
+
+// IsUnlimited reports whether the schedule never limits bandwidth.
+func (s *BandwidthSchedule) IsUnlimited() bool {
+	if s.defaultLimit > 0 {
+		return false
+	}
+
+	for _, rule := range s.rules {
+		if rule.limit > 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r bandwidthRule) contains(minute int) bool {
+	if r.start < r.end {
+		return minute >= r.start && minute < r.end
+	}
+
+	// Window wraps past midnight, e.g. 22:00-06:00.
+	return minute >= r.start || minute < r.end
+}
+
+func parseBandwidthLimit(limit string) (int64, error) {
+	trimmed := strings.TrimSpace(limit)
+	if strings.EqualFold(trimmed, "unlimited") {
+		return 0, nil
+	}
+
+	return ParseSize(strings.TrimSuffix(strings.TrimSuffix(trimmed, "/s"), "ps"))
+}
+
+func parseClock(clock string) (int, error) {
+	hourText, minuteText, found := strings.Cut(strings.TrimSpace(clock), ":")
+	if !found {
+		return 0, fmt.Errorf("invalid time %q: expected HH:MM", clock)
+	}
+
+	hour, err := strconv.Atoi(hourText)
+	if err != nil || hour < 0 || hour > 24 {
+		return 0, fmt.Errorf("invalid hour in %q", clock)
+	}
+
+	minute, err := strconv.Atoi(minuteText)
+	if err != nil || minute < 0 || minute > 59 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("invalid minute in %q", clock)
+	}
+
+	return hour*60 + minute, nil
+}
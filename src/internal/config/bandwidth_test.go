@@ -0,0 +1,51 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseBandwidthSchedule(t *testing.T) {
+	t.Parallel()
+
+	day := func(hour, minute int) time.Time {
+		return time.Date(2025, 1, 1, hour, minute, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		name    string
+		spec    string
+		at      time.Time
+		want    int64
+		wantErr bool
+	}{
+		{name: "flat limit", spec: "10MB", at: day(3, 0), want: 10 << 20},
+		{name: "inside window", spec: "09:00-17:00:5MB,default:unlimited", at: day(12, 30), want: 5 << 20},
+		{name: "window end is exclusive", spec: "09:00-17:00:5MB,default:unlimited", at: day(17, 0), want: 0},
+		{name: "default outside window", spec: "09:00-17:00:5MB,default:50MB", at: day(20, 0), want: 50 << 20},
+		{name: "window wrapping midnight", spec: "22:00-06:00:1MB", at: day(2, 0), want: 1 << 20},
+		{name: "per-second suffix", spec: "default:2MB/s", at: day(0, 0), want: 2 << 20},
+		{name: "bad clock", spec: "9am-5pm:5MB", wantErr: true},
+		{name: "bad limit", spec: "09:00-17:00:fast", wantErr: true},
+		{name: "empty window", spec: "09:00-09:00:5MB", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			schedule, err := ParseBandwidthSchedule(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseBandwidthSchedule(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if got := schedule.LimitAt(tt.at); got != tt.want {
+				t.Errorf("LimitAt(%s) = %d, want %d", tt.at.Format("15:04"), got, tt.want)
+			}
+		})
+	}
+}
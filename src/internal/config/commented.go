@@ -0,0 +1,150 @@
+package config
+
+import (
+	"bytes"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// settingComments describes each setting SaveCommented annotates, by key.
+var settingComments = map[string]string{
+	"version":          "Version of the config file format.",
+	"default":          "Settings used when no --profile is given.",
+	"profiles":         "Named profiles, selected with --profile.",
+	"mode":             "mirror copies source to destination, sync copies both ways, watch mirrors on every change.",
+	"source":           "Directory to copy from.",
+	"destination":      "Directory to copy to.",
+	"watch":            "Keep running and sync again whenever the source changes.",
+	"workers":          "Files copied at once; 0 picks a count from the number of CPUs.",
+	"bufferSize":       "Copy buffer size: auto, or a size such as 1MB.",
+	"extends":          "Profile whose settings fill in the ones left unset here.",
+	"schedule":         "Five-field cron expression at which relay schedule mirrors this profile.",
+	"pipeline":         "Mirrors relay run performs in order.",
+	"filters":          "Which files are copied.",
+	"smart":            "Skip VCS dirs, OS clutter and build output of detected project types.",
+	"include":          "Glob patterns of the paths to copy.",
+	"exclude":          "Glob patterns of the paths to skip.",
+	"respectGitignore": "Skip the paths .gitignore files ignore.",
+	"ignoreHidden":     "Skip files and directories whose names start with a dot.",
+	"maxFileSize":      "Skip files larger than this size, such as 1GB.",
+	"minFileSize":      "Skip files smaller than this size, such as 1KB.",
+	"includeRegex":     "Only copy the paths matching one of these regular expressions.",
+	"excludeRegex":     "Skip the paths matching any of these regular expressions.",
+	"conflict":         "What to do with a file that changed on both sides.",
+	"strategy":         "newest, source, destination, interactive, smart, skip or keep-newest:N.",
+	"backup":           "Keep the version a conflict replaces.",
+	"backupDir":        "Directory the replaced versions are kept in.",
+	"interactive":      "Ask how to resolve each conflict.",
+	"retry":            "How failed operations are retried.",
+	"maxAttempts":      "Attempts per operation, the first included.",
+	"initialDelay":     "Delay before the first retry.",
+	"maxDelay":         "Longest delay between two retries.",
+	"multiplier":       "Factor by which exponential backoff grows the delay.",
+	"backoff":          "linear, exponential or fixed.",
+	"performance":      "Tuning for large trees and slow disks.",
+	"checksumAlgo":     "blake3, md5 or sha256.",
+	"ioConcurrency":    "Concurrent disk operations; -1 picks a count automatically.",
+}
+
+var (
+	jsonKeyPattern    = regexp.MustCompile(`^(\s*)"([^"]+)":`)
+	tomlKeyPattern    = regexp.MustCompile(`^(\s*)([A-Za-z0-9_-]+) =`)
+	tomlHeaderPattern = regexp.MustCompile(`^(\s*)\[\[?([^\]]+)\]\]?$`)
+	yamlKeyPattern    = regexp.MustCompile(`^(\s*)(?:- )?"?([A-Za-z0-9_$-]+)"?:`)
+)
+
+// initHeader starts the files SaveCommented writes.
+var initHeader = []string{
+	"relay configuration, written by relay init.",
+	"Run relay validate after editing it.",
+}
+
+// SaveCommented writes config to path as Save does, with a comment above the
+// first occurrence of each setting that says what it does. Plain JSON files
+// cannot hold comments and are written as Save writes them.
+func SaveCommented(config *Config, path string) error {
+	data, err := encode(config, path)
+	if err != nil {
+		return err
+	}
+
+	if marker, keyOf := commentSyntax(path); marker != "" {
+		data = annotate(data, marker, keyOf, initHeader, settingComments)
+	}
+
+	return writeConfigFile(path, data)
+}
+
+// commentSyntax returns the line comment marker of the config format of
+// path, and a function that finds the setting a line of it starts, with the
+// line's indentation. The marker is empty for plain JSON, which has no
+// comments.
+func commentSyntax(path string) (string, func(line string) (indent, key string)) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonc":
+		return "//", func(line string) (string, string) {
+			return submatches(jsonKeyPattern, line)
+		}
+	case ".toml":
+		return "#", func(line string) (string, string) {
+			if indent, key := submatches(tomlKeyPattern, line); key != "" {
+				return indent, key
+			}
+
+			indent, table := submatches(tomlHeaderPattern, line)
+
+			return indent, table[strings.LastIndex(table, ".")+1:]
+		}
+	case ".yaml", ".yml":
+		return "#", func(line string) (string, string) {
+			return submatches(yamlKeyPattern, line)
+		}
+	default:
+		return "", nil
+	}
+}
+
+// annotate inserts the comment for the key that keyOf finds on each line of
+// data, at the line's indentation, the first time the key appears. A comment
+// may span lines. The file starts with the header lines, if any.
+func annotate(data []byte, marker string, keyOf func(line string) (indent, key string), header []string, comments map[string]string) []byte {
+	var out bytes.Buffer
+
+	for _, line := range header {
+		out.WriteString(strings.TrimRight(marker+" "+line, " ") + "\n")
+	}
+
+	if len(header) > 0 {
+		out.WriteString("\n")
+	}
+
+	seen := make(map[string]bool)
+
+	for line := range strings.Lines(string(data)) {
+		indent, key := keyOf(strings.TrimRight(line, "\n"))
+
+		if comment, exists := comments[key]; exists && !seen[key] {
+			seen[key] = true
+
+			for commentLine := range strings.Lines(comment) {
+				out.WriteString(strings.TrimRight(indent+marker+" "+strings.TrimRight(commentLine, "\n"), " ") + "\n")
+			}
+		}
+
+		out.WriteString(line)
+	}
+
+	return out.Bytes()
+}
+
+// submatches returns the first two groups pattern captures in line, or empty
+// strings when it does not match.
+func submatches(pattern *regexp.Regexp, line string) (string, string) {
+	match := pattern.FindStringSubmatch(line)
+	if match == nil {
+		return "", ""
+	}
+
+	return match[1], match[2]
+}
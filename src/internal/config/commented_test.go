@@ -0,0 +1,68 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveCommented(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		comment string // expected above the mode of the default profile
+	}{
+		{name: "relay.jsonc", comment: "\t\t// mirror copies source"},
+		{name: "relay.toml", comment: "# mirror copies source"},
+		{name: "relay.yaml", comment: "  # mirror copies source"},
+		{name: "relay.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), tt.name)
+			loader := NewLoader()
+
+			want := loader.Defaults()
+			want.Default.Source = "./src"
+			want.Profiles = map[string]*Profile{
+				"nas": {Mode: string(ModeMirror), Destination: "/mnt/nas", Extends: "default"},
+			}
+
+			if err := SaveCommented(want, path); err != nil {
+				t.Fatalf("SaveCommented failed: %v", err)
+			}
+
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("ReadFile failed: %v", err)
+			}
+
+			if tt.comment != "" && !strings.Contains(string(content), tt.comment) {
+				t.Errorf("%s has no comment %q:\n%s", tt.name, tt.comment, content)
+			}
+
+			if count := strings.Count(string(content), "Directory to copy to."); tt.comment != "" && count != 1 {
+				t.Errorf("destination commented %d times, want once:\n%s", count, content)
+			}
+
+			got, err := loader.Read(path)
+			if err != nil {
+				t.Fatalf("Read failed: %v", err)
+			}
+
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(want)
+
+			if !bytes.Equal(gotJSON, wantJSON) {
+				t.Errorf("Read after SaveCommented = %s, want %s", gotJSON, wantJSON)
+			}
+		})
+	}
+}
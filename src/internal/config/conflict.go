@@ -0,0 +1,34 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseConflictStrategy splits a strategy such as "keep-newest:5" into the
+// strategy and its version count. Strategies other than keep-newest take no
+// count and return 0.
+func ParseConflictStrategy(spec string) (ConflictStrategy, int, error) {
+	name, count, hasCount := strings.Cut(spec, ":")
+	strategy := ConflictStrategy(name)
+
+	if strategy != ConflictKeepNewest {
+		if hasCount {
+			return "", 0, fmt.Errorf("conflict strategy %s does not take a count", name)
+		}
+
+		return strategy, 0, nil
+	}
+
+	if !hasCount {
+		return "", 0, fmt.Errorf("conflict strategy %s requires a count, e.g. %s:5", name, name)
+	}
+
+	versions, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil || versions < 1 {
+		return "", 0, fmt.Errorf("invalid version count %q for %s: must be a positive integer", count, name)
+	}
+
+	return strategy, versions, nil
+}
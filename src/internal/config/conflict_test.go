@@ -0,0 +1,39 @@
+package config
+
+import "testing"
+
+func TestParseConflictStrategy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		spec         string
+		wantStrategy ConflictStrategy
+		wantVersions int
+		wantErr      bool
+	}{
+		{spec: "newest", wantStrategy: ConflictNewest},
+		{spec: "skip", wantStrategy: ConflictSkip},
+		{spec: "keep-newest:5", wantStrategy: ConflictKeepNewest, wantVersions: 5},
+		{spec: "keep-newest:1", wantStrategy: ConflictKeepNewest, wantVersions: 1},
+		{spec: "keep-newest", wantErr: true},
+		{spec: "keep-newest:0", wantErr: true},
+		{spec: "keep-newest:many", wantErr: true},
+		{spec: "source:3", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			t.Parallel()
+
+			strategy, versions, err := ParseConflictStrategy(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseConflictStrategy(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+
+			if strategy != tt.wantStrategy || versions != tt.wantVersions {
+				t.Errorf("ParseConflictStrategy(%q) = %s, %d; want %s, %d",
+					tt.spec, strategy, versions, tt.wantStrategy, tt.wantVersions)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Convert reads the config file at from and writes it to to, in the format
+// selected by each one's extension. The config must be valid; it is written
+// with the defaults Normalize fills in, and profiles keep their extends
+// rather than being merged.
+//
+// Line comments directly above a setting, or heading the file, are carried
+// over when the target format has comments: JSONC, TOML and YAML do, plain
+// JSON does not. Comments are matched to settings by name, so a comment is
+// placed above the first setting of that name. Convert returns how many
+// comments it could not carry over.
+func Convert(from, to string) (int, error) {
+	content, err := os.ReadFile(from)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read config file %s: %w", from, err)
+	}
+
+	loader := NewLoader()
+
+	config, err := loader.Read(from)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := loader.Normalize(config); err != nil {
+		return 0, fmt.Errorf("invalid config %s: %w", from, err)
+	}
+
+	data, err := encode(config, to)
+	if err != nil {
+		return 0, err
+	}
+
+	header, comments, dropped := extractComments(content, from)
+
+	if marker, keyOf := commentSyntax(to); marker != "" {
+		data = annotate(data, marker, keyOf, header, comments)
+	} else {
+		dropped += len(comments)
+		if len(header) > 0 {
+			dropped++
+		}
+	}
+
+	return dropped, writeConfigFile(to, data)
+}
+
+// extractComments returns the line comments of content, a config file at
+// path: the block heading the file, set apart by a blank line, and the block
+// directly above each setting, by the setting's name. Comments that precede
+// nothing, or a setting already commented, are only counted in dropped.
+func extractComments(content []byte, path string) (header []string, comments map[string]string, dropped int) {
+	comments = make(map[string]string)
+
+	marker, keyOf := commentSyntax(path)
+	if marker == "" {
+		return nil, comments, 0
+	}
+
+	var (
+		pending []string
+		seenKey bool
+	)
+
+	for line := range strings.Lines(string(content)) {
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case strings.HasPrefix(trimmed, marker):
+			pending = append(pending, strings.TrimSpace(strings.TrimPrefix(trimmed, marker)))
+			continue
+		case trimmed == "":
+			if !seenKey && header == nil && len(pending) > 0 {
+				header, pending = pending, nil
+			}
+
+			continue
+		}
+
+		_, key := keyOf(strings.TrimRight(line, "\r\n"))
+
+		switch {
+		case len(pending) == 0:
+		case key == "" && !seenKey && header == nil:
+			// Above the opening brace of a JSONC file, or a YAML document
+			// marker.
+			header = pending
+		default:
+			if _, exists := comments[key]; key == "" || exists {
+				dropped++
+			} else {
+				comments[key] = strings.Join(pending, "\n")
+			}
+		}
+
+		pending = nil
+		seenKey = seenKey || key != ""
+	}
+
+	if len(pending) > 0 {
+		dropped++
+	}
+
+	return header, comments, dropped
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const convertInput = `// Backups of the laptop.
+// Edited by hand.
+
+{
+	"default": {
+		// Where the work lives.
+		"source": "./src",
+		"destination": "./dst",
+		"retry": {
+			"maxAttempts": 5,
+			"initialDelay": 250000000
+		}
+	},
+	"profiles": {
+		// The NAS in the hall.
+		"nas": {
+			"extends": "default",
+			"destination": "/mnt/nas"
+		}
+	}
+	// Left at the end.
+}
+`
+
+func TestConvert(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		comments []string // expected in the converted file
+		dropped  int
+	}{
+		{name: "relay.toml", comments: []string{"# Backups of the laptop.", "# Where the work lives.", "# The NAS in the hall."}, dropped: 1},
+		{name: "relay.yaml", comments: []string{"# Edited by hand.", "  # Where the work lives.", "  # The NAS in the hall."}, dropped: 1},
+		{name: "relay.json", dropped: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+
+			from := filepath.Join(dir, "relay.jsonc")
+			if err := os.WriteFile(from, []byte(convertInput), 0o644); err != nil {
+				t.Fatalf("Failed to write config: %v", err)
+			}
+
+			to := filepath.Join(dir, tt.name)
+
+			dropped, err := Convert(from, to)
+			if err != nil {
+				t.Fatalf("Convert failed: %v", err)
+			}
+
+			if dropped != tt.dropped {
+				t.Errorf("dropped %d comments, want %d", dropped, tt.dropped)
+			}
+
+			content, err := os.ReadFile(to)
+			if err != nil {
+				t.Fatalf("ReadFile failed: %v", err)
+			}
+
+			for _, comment := range tt.comments {
+				if !strings.Contains(string(content), comment+"\n") {
+					t.Errorf("%s has no comment %q:\n%s", tt.name, comment, content)
+				}
+			}
+
+			loader := NewLoader()
+
+			got, err := loader.Read(to)
+			if err != nil {
+				t.Fatalf("Read failed: %v", err)
+			}
+
+			want, err := loader.Read(from)
+			if err != nil {
+				t.Fatalf("Read failed: %v", err)
+			}
+
+			if err := loader.Normalize(want); err != nil {
+				t.Fatalf("Normalize failed: %v", err)
+			}
+
+			if got.Default.Mode != string(ModeMirror) || got.Default.BufferSize != "auto" || got.Version != "1.0" {
+				t.Errorf("defaults not filled in: %+v", got.Default)
+			}
+
+			if got.Profiles["nas"].Extends != "default" || got.Profiles["nas"].Source != "" {
+				t.Errorf("nas = %+v, want its extends kept rather than merged", got.Profiles["nas"])
+			}
+
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(want)
+
+			if !bytes.Equal(gotJSON, wantJSON) {
+				t.Errorf("converted = %s, want %s", gotJSON, wantJSON)
+			}
+		})
+	}
+}
+
+func TestConvertInvalid(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	from := filepath.Join(dir, "relay.toml")
+	if err := os.WriteFile(from, []byte("[default]\nmode = \"teleport\"\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	if _, err := Convert(from, filepath.Join(dir, "relay.yaml")); err == nil {
+		t.Error("Convert accepted an invalid config")
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "relay.yaml")); !os.IsNotExist(err) {
+		t.Errorf("Convert wrote a file for an invalid config (%v)", err)
+	}
+}
@@ -2,15 +2,20 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/pelletier/go-toml/v2"
-	"github.com/tidwall/gjson"
+	"github.com/robfig/cron/v3"
+	"go.yaml.in/yaml/v3"
 )
 
 // Loader handles loading and parsing configuration files from multiple formats.
@@ -67,9 +72,13 @@ func (l *Loader) findDefaultConfig() string {
 		"relay.jsonc",
 		"relay.json",
 		"relay.toml",
+		"relay.yaml",
+		"relay.yml",
 		".relay.jsonc",
 		".relay.json",
 		".relay.toml",
+		".relay.yaml",
+		".relay.yml",
 	}
 
 	for _, searchPath := range l.searchPaths {
@@ -98,6 +107,10 @@ func (l *Loader) parseByExtension(content []byte, ext string) (*Config, error) {
 		if err := toml.Unmarshal(content, &config); err != nil {
 			return nil, fmt.Errorf("invalid TOML: %w", err)
 		}
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(content, &config); err != nil {
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
 	default:
 		return nil, fmt.Errorf("unsupported config format: %s", ext)
 	}
@@ -106,37 +119,54 @@ func (l *Loader) parseByExtension(content []byte, ext string) (*Config, error) {
 }
 
 func (l *Loader) stripJSONComments(content string) string {
-	result := gjson.Parse(content)
-	if !result.IsObject() {
-		return content
-	}
-
-	var cleaned map[string]interface{}
-	if err := json.Unmarshal([]byte(content), &cleaned); err != nil {
-		// If standard JSON parsing fails, try manual comment removal
-		lines := strings.Split(content, "\n")
-
-		var cleanedLines []string
+	return string(blankJSONComments([]byte(content)))
+}
 
-		for _, line := range lines {
-			trimmed := strings.TrimSpace(line)
-			if strings.HasPrefix(trimmed, "//") {
-				continue
+// blankJSONComments replaces the // and /* */ comments of JSONC content with
+// spaces, keeping line breaks, so what remains is JSON whose errors are at
+// the same line and column as in the original.
+func blankJSONComments(content []byte) []byte {
+	cleaned := bytes.Clone(content)
+
+	inString, escaped := false, false
+
+	for i := 0; i < len(cleaned); i++ {
+		c := cleaned[i]
+
+		switch {
+		case inString:
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '/' && i+1 < len(cleaned) && cleaned[i+1] == '/':
+			for ; i < len(cleaned) && cleaned[i] != '\n'; i++ {
+				cleaned[i] = ' '
+			}
+		case c == '/' && i+1 < len(cleaned) && cleaned[i+1] == '*':
+			end := bytes.Index(cleaned[i+2:], []byte("*/"))
+			if end < 0 {
+				// Left for the JSON decoder to report.
+				return cleaned
 			}
 
-			if idx := strings.Index(line, "//"); idx != -1 {
-				line = line[:idx]
+			for j := i; j < i+2+end+2; j++ {
+				if cleaned[j] != '\n' {
+					cleaned[j] = ' '
+				}
 			}
 
-			cleanedLines = append(cleanedLines, line)
+			i += 2 + end + 1
 		}
-
-		return strings.Join(cleanedLines, "\n")
 	}
 
-	cleanedJSON, _ := json.Marshal(cleaned)
-
-	return string(cleanedJSON)
+	return cleaned
 }
 
 func (l *Loader) validateConfig(config *Config) error {
@@ -187,6 +217,24 @@ func (l *Loader) validateProfile(profile *Profile) error {
 		return fmt.Errorf("workers must be non-negative, got %d", profile.Workers)
 	}
 
+	if profile.BufferSize != "" && profile.BufferSize != "auto" {
+		if _, err := ParseSize(profile.BufferSize); err != nil {
+			return fmt.Errorf("invalid bufferSize: %w", err)
+		}
+	}
+
+	if profile.Schedule != "" {
+		if err := validateSchedule(profile); err != nil {
+			return err
+		}
+	}
+
+	for i := range profile.Pipeline {
+		if err := l.validatePipelineStep(&profile.Pipeline[i]); err != nil {
+			return fmt.Errorf("invalid pipeline step %d: %w", i+1, err)
+		}
+	}
+
 	// Set defaults
 	if profile.Workers == 0 {
 		profile.Workers = -1 // Auto-detect
@@ -196,6 +244,12 @@ func (l *Loader) validateProfile(profile *Profile) error {
 		profile.BufferSize = "auto"
 	}
 
+	if profile.Filters != nil {
+		if err := l.validateFilterRules(profile.Filters); err != nil {
+			return fmt.Errorf("invalid filters: %w", err)
+		}
+	}
+
 	if profile.Conflict != nil {
 		if err := l.validateConflictConfig(profile.Conflict); err != nil {
 			return fmt.Errorf("invalid conflict config: %w", err)
@@ -206,6 +260,71 @@ func (l *Loader) validateProfile(profile *Profile) error {
 		l.validateRetryConfig(profile.Retry)
 	}
 
+	if profile.Performance != nil {
+		if err := l.validatePerformanceConfig(profile.Performance); err != nil {
+			return fmt.Errorf("invalid performance config: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (l *Loader) validatePerformanceConfig(config *PerformanceConfig) error {
+	if config.ChecksumSeed != "" && config.ChecksumAlgo != "" && config.ChecksumAlgo != "blake3" {
+		return fmt.Errorf("checksumSeed requires the blake3 checksum algorithm, got %s", config.ChecksumAlgo)
+	}
+
+	if _, err := NewBandwidthSchedule(config.BandwidthSchedule); err != nil {
+		return fmt.Errorf("invalid bandwidthSchedule: %w", err)
+	}
+
+	if config.ChecksumConcurrency < 0 {
+		return fmt.Errorf("checksumConcurrency must be non-negative, got %d", config.ChecksumConcurrency)
+	}
+
+	if config.MaxOpenFiles < 0 {
+		return fmt.Errorf("maxOpenFiles must be non-negative, got %d", config.MaxOpenFiles)
+	}
+
+	if _, err := ParseSize(config.SampleChecksum); err != nil {
+		return fmt.Errorf("invalid sampleChecksum: %w", err)
+	}
+
+	if _, err := ParseSize(config.ParallelHash); err != nil {
+		return fmt.Errorf("invalid parallelHash: %w", err)
+	}
+
+	if multiplier := config.ConcurrencyMultiplier; multiplier != nil {
+		if multiplier.Scan < 0 || multiplier.Copy < 0 {
+			return fmt.Errorf("concurrencyMultiplier values must be non-negative, got scan=%g copy=%g",
+				multiplier.Scan, multiplier.Copy)
+		}
+	}
+
+	return nil
+}
+
+func (l *Loader) validateFilterRules(rules *FilterRules) error {
+	maxSize, err := ParseSize(rules.MaxFileSize)
+	if err != nil {
+		return fmt.Errorf("invalid maxFileSize: %w", err)
+	}
+
+	minSize, err := ParseSize(rules.MinFileSize)
+	if err != nil {
+		return fmt.Errorf("invalid minFileSize: %w", err)
+	}
+
+	if maxSize > 0 && minSize > maxSize {
+		return fmt.Errorf("minFileSize %s is larger than maxFileSize %s", rules.MinFileSize, rules.MaxFileSize)
+	}
+
+	for _, pattern := range slices.Concat(rules.IncludeRegex, rules.ExcludeRegex) {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid regex %q: %w", pattern, err)
+		}
+	}
+
 	return nil
 }
 
@@ -221,12 +340,18 @@ func (l *Loader) validateConflictConfig(config *ConflictConfig) error {
 		string(ConflictInteractive),
 		string(ConflictSmart),
 		string(ConflictSkip),
+		string(ConflictKeepNewest) + ":N",
 	}
 
-	isValid := false
+	strategy, _, err := ParseConflictStrategy(config.Strategy)
+	if err != nil {
+		return err
+	}
 
-	for _, strategy := range validStrategies {
-		if config.Strategy == strategy {
+	isValid := strategy == ConflictKeepNewest
+
+	for _, valid := range validStrategies {
+		if string(strategy) == valid {
 			isValid = true
 			break
 		}
@@ -265,6 +390,48 @@ func (l *Loader) validateRetryConfig(config *RetryConfig) {
 	}
 }
 
+// validateSchedule checks that a scheduled profile has a valid cron
+// expression and something to mirror.
+func validateSchedule(profile *Profile) error {
+	if _, err := cron.ParseStandard(profile.Schedule); err != nil {
+		return fmt.Errorf("invalid schedule %q: %w", profile.Schedule, err)
+	}
+
+	if profile.Mode != string(ModeMirror) {
+		return fmt.Errorf("schedule requires mirror mode, got %s", profile.Mode)
+	}
+
+	if profile.Source == "" || profile.Destination == "" {
+		return errors.New("schedule requires both source and destination")
+	}
+
+	return nil
+}
+
+// validatePipelineStep checks that a pipeline step is a mirror with
+// somewhere to mirror from and to.
+func (l *Loader) validatePipelineStep(step *PipelineStep) error {
+	if step.Mode == "" {
+		step.Mode = string(ModeMirror)
+	}
+
+	if step.Mode != string(ModeMirror) {
+		return fmt.Errorf("pipeline steps must be mirrors, got mode %s", step.Mode)
+	}
+
+	if step.Source == "" || step.Destination == "" {
+		return errors.New("pipeline steps require both source and destination")
+	}
+
+	if step.Filters != nil {
+		if err := l.validateFilterRules(step.Filters); err != nil {
+			return fmt.Errorf("invalid filters: %w", err)
+		}
+	}
+
+	return nil
+}
+
 func (l *Loader) resolveExtends(config *Config) error {
 	// Resolve inheritance for named profiles
 	for name, profile := range config.Profiles {
@@ -337,9 +504,9 @@ func (l *Loader) getDefaultConfig() *Config {
 			Workers:    -1, // Auto-detect
 			BufferSize: "auto",
 			Filters: &FilterRules{
-				Smart:            true,
-				Include:          []string{"**/*"},
-				Exclude:          []string{".git", "node_modules", "*.tmp", "*.swp"},
+				Smart:            false,
+				Include:          []string{},
+				Exclude:          []string{},
 				RespectGitignore: true,
 				IgnoreHidden:     false,
 			},
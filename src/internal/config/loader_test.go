@@ -372,3 +372,214 @@ func TestLoaderAutoDetectFormat(t *testing.T) {
 		})
 	}
 }
+
+func TestLoaderChecksumSeedRequiresBlake3(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		algo    string
+		wantErr bool
+	}{
+		{name: "blake3 accepts seed", algo: "blake3", wantErr: false},
+		{name: "default algorithm accepts seed", algo: "", wantErr: false},
+		{name: "sha256 rejects seed", algo: "sha256", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tempDir := t.TempDir()
+			configFile := filepath.Join(tempDir, "config.json")
+
+			content := `{"default": {"performance": {"checksumAlgo": "` + tt.algo + `", "checksumSeed": "secret"}}}`
+			if err := os.WriteFile(configFile, []byte(content), 0o644); err != nil {
+				t.Fatalf("Failed to write config file: %v", err)
+			}
+
+			_, err := NewLoader().Load(configFile)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoaderSchedule(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		profile string
+		wantErr bool
+	}{
+		{name: "daily", profile: `{"source": "/data", "destination": "/backup", "schedule": "0 2 * * *"}`},
+		{name: "descriptor", profile: `{"source": "/data", "destination": "/backup", "schedule": "@hourly"}`},
+		{name: "invalid expression", profile: `{"source": "/data", "destination": "/backup", "schedule": "0 2 * *"}`, wantErr: true},
+		{name: "missing destination", profile: `{"source": "/data", "schedule": "0 2 * * *"}`, wantErr: true},
+		{name: "not mirror mode", profile: `{"mode": "sync", "source": "/a", "destination": "/b", "schedule": "0 2 * * *"}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tempDir := t.TempDir()
+			configFile := filepath.Join(tempDir, "config.json")
+
+			content := `{"profiles": {"nightly": ` + tt.profile + `}}`
+			if err := os.WriteFile(configFile, []byte(content), 0o644); err != nil {
+				t.Fatalf("Failed to write config file: %v", err)
+			}
+
+			_, err := NewLoader().Load(configFile)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoaderPipeline(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		profile string
+		wantErr bool
+	}{
+		{name: "two steps", profile: `{"pipeline": [{"source": "/a", "destination": "/b"}, {"source": "/b", "destination": "/c", "continueOnError": true}]}`},
+		{name: "step filters", profile: `{"pipeline": [{"source": "/a", "destination": "/b", "filters": {"maxFileSize": "1GB"}}]}`},
+		{name: "missing source", profile: `{"pipeline": [{"destination": "/b"}]}`, wantErr: true},
+		{name: "sync step", profile: `{"pipeline": [{"mode": "sync", "source": "/a", "destination": "/b"}]}`, wantErr: true},
+		{name: "invalid step filters", profile: `{"pipeline": [{"source": "/a", "destination": "/b", "filters": {"maxFileSize": "lots"}}]}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tempDir := t.TempDir()
+			configFile := filepath.Join(tempDir, "config.json")
+
+			content := `{"profiles": {"offsite": ` + tt.profile + `}}`
+			if err := os.WriteFile(configFile, []byte(content), 0o644); err != nil {
+				t.Fatalf("Failed to write config file: %v", err)
+			}
+
+			cfg, err := NewLoader().Load(configFile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err == nil && cfg.Profiles["offsite"].Pipeline[0].Mode != string(ModeMirror) {
+				t.Errorf("step mode = %q, want %q", cfg.Profiles["offsite"].Pipeline[0].Mode, ModeMirror)
+			}
+		})
+	}
+}
+
+func TestLoaderConcurrencyMultiplier(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		content  string
+		wantScan float64
+		wantCopy float64
+		wantErr  bool
+	}{
+		{
+			name:     "both stages",
+			content:  `{"default": {"performance": {"concurrencyMultiplier": {"scan": 1, "copy": 8}}}}`,
+			wantScan: 1,
+			wantCopy: 8,
+		},
+		{
+			name:     "copy only",
+			content:  `{"default": {"performance": {"concurrencyMultiplier": {"copy": 0.5}}}}`,
+			wantCopy: 0.5,
+		},
+		{
+			name:    "negative rejected",
+			content: `{"default": {"performance": {"concurrencyMultiplier": {"scan": -1}}}}`,
+			wantErr: true,
+		},
+		{
+			name:    "negative checksum concurrency rejected",
+			content: `{"default": {"performance": {"checksumConcurrency": -2, "concurrencyMultiplier": {"scan": 1}}}}`,
+			wantErr: true,
+		},
+		{
+			name:    "negative max open files rejected",
+			content: `{"default": {"performance": {"maxOpenFiles": -1, "concurrencyMultiplier": {"scan": 1}}}}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid sample checksum size rejected",
+			content: `{"default": {"performance": {"sampleChecksum": "lots", "concurrencyMultiplier": {"scan": 1}}}}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid parallel hash size rejected",
+			content: `{"default": {"performance": {"parallelHash": "big", "concurrencyMultiplier": {"scan": 1}}}}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tempDir := t.TempDir()
+			configFile := filepath.Join(tempDir, "config.json")
+
+			if err := os.WriteFile(configFile, []byte(tt.content), 0o644); err != nil {
+				t.Fatalf("Failed to write config file: %v", err)
+			}
+
+			cfg, err := NewLoader().Load(configFile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			multiplier := cfg.Default.Performance.ConcurrencyMultiplier
+			if multiplier.Scan != tt.wantScan || multiplier.Copy != tt.wantCopy {
+				t.Errorf("ConcurrencyMultiplier = %+v, want scan=%g copy=%g", *multiplier, tt.wantScan, tt.wantCopy)
+			}
+		})
+	}
+}
+
+func TestLoaderFilterRegex(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		filters string
+		wantErr bool
+	}{
+		{name: "valid patterns", filters: `{"includeRegex": ["\\.go$"], "excludeRegex": ["_test\\.go$"]}`},
+		{name: "invalid include", filters: `{"includeRegex": ["[a-"]}`, wantErr: true},
+		{name: "invalid exclude", filters: `{"excludeRegex": ["(unclosed"]}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tempDir := t.TempDir()
+			configFile := filepath.Join(tempDir, "config.json")
+
+			content := `{"default": {"filters": ` + tt.filters + `}}`
+			if err := os.WriteFile(configFile, []byte(content), 0o644); err != nil {
+				t.Fatalf("Failed to write config file: %v", err)
+			}
+
+			_, err := NewLoader().Load(configFile)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
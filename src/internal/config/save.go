@@ -0,0 +1,138 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"go.yaml.in/yaml/v3"
+)
+
+// Read parses the config file at path without validating it, filling in
+// defaults or resolving extends, so it can be edited and written back as the
+// user wrote it.
+func (l *Loader) Read(path string) (*Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	config, err := l.parseByExtension(content, strings.ToLower(filepath.Ext(path)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+// FindConfig returns the config file Load uses when no path is given, or ""
+// when none exists.
+func (l *Loader) FindConfig() string {
+	return l.findDefaultConfig()
+}
+
+// Defaults returns the built-in configuration Load uses when no config file
+// exists, in the form it is written to a file.
+func (l *Loader) Defaults() *Config {
+	config := l.getDefaultConfig()
+
+	// Files spell auto-detected workers as 0; Load rejects negative counts.
+	config.Default.Workers = 0
+
+	return config
+}
+
+// ValidateProfile checks profile against the same rules Load applies. Unlike
+// Load it leaves profile untouched rather than filling in defaults.
+func (l *Loader) ValidateProfile(profile *Profile) error {
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return fmt.Errorf("failed to copy profile: %w", err)
+	}
+
+	var clone Profile
+	if err := json.Unmarshal(data, &clone); err != nil {
+		return fmt.Errorf("failed to copy profile: %w", err)
+	}
+
+	return l.validateProfile(&clone)
+}
+
+// Save writes config to path in the format selected by its extension, as
+// tab-indented JSON, as TOML or as YAML. Comments in an existing JSONC file are not
+// kept. The file is replaced atomically, so a failed write leaves the old
+// config intact.
+func Save(config *Config, path string) error {
+	data, err := encode(config, path)
+	if err != nil {
+		return err
+	}
+
+	return writeConfigFile(path, data)
+}
+
+// encode returns config in the format selected by the extension of path.
+func encode(config *Config, path string) ([]byte, error) {
+	var (
+		data []byte
+		err  error
+	)
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".jsonc":
+		data, err = json.MarshalIndent(config, "", "\t")
+		data = append(data, '\n')
+	case ".toml":
+		data, err = toml.Marshal(config)
+	case ".yaml", ".yml":
+		var buf bytes.Buffer
+
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+
+		err = encoder.Encode(config)
+		data = buf.Bytes()
+	default:
+		return nil, fmt.Errorf("unsupported config format: %s", filepath.Ext(path))
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+
+	return data, nil
+}
+
+// writeConfigFile atomically replaces the file at path with data.
+func writeConfigFile(path string, data []byte) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write config file %s: %w", path, err)
+	}
+
+	defer func() { _ = os.Remove(temp.Name()) }()
+
+	if _, err := temp.Write(data); err != nil {
+		_ = temp.Close()
+		return fmt.Errorf("failed to write config file %s: %w", path, err)
+	}
+
+	if err := temp.Chmod(0o644); err != nil {
+		_ = temp.Close()
+		return fmt.Errorf("failed to write config file %s: %w", path, err)
+	}
+
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("failed to write config file %s: %w", path, err)
+	}
+
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write config file %s: %w", path, err)
+	}
+
+	return nil
+}
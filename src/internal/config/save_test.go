@@ -0,0 +1,94 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSaveRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"relay.json", "relay.jsonc", "relay.toml"} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), name)
+			loader := NewLoader()
+
+			want := loader.Defaults()
+			want.Profiles = map[string]*Profile{
+				"backup": {
+					Mode:        string(ModeMirror),
+					Source:      "./photos",
+					Destination: "/mnt/backup",
+					Filters: &FilterRules{
+						Include:      []string{},
+						Exclude:      []string{},
+						ExcludeRegex: []string{`\.tmp$`},
+						MaxFileSize:  "1GB",
+					},
+					Conflict: &ConflictConfig{Strategy: "keep-newest:5"},
+				},
+			}
+
+			if err := Save(want, path); err != nil {
+				t.Fatalf("Save failed: %v", err)
+			}
+
+			got, err := loader.Read(path)
+			if err != nil {
+				t.Fatalf("Read failed: %v", err)
+			}
+
+			// TOML decodes missing lists as empty rather than nil, so compare
+			// the encoded forms.
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(want)
+
+			if !bytes.Equal(gotJSON, wantJSON) {
+				t.Errorf("Read after Save = %s, want %s", gotJSON, wantJSON)
+			}
+
+			if _, err := loader.Load(path); err != nil {
+				t.Errorf("Load of saved config failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoaderValidateProfile(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		profile Profile
+		wantErr bool
+	}{
+		{"empty", Profile{}, false},
+		{"valid", Profile{Mode: "sync", Conflict: &ConflictConfig{Strategy: "skip"}}, false},
+		{"bad mode", Profile{Mode: "copy"}, true},
+		{"bad strategy", Profile{Conflict: &ConflictConfig{Strategy: "oldest"}}, true},
+		{"bad regex", Profile{Filters: &FilterRules{IncludeRegex: []string{"("}}}, true},
+		{"bad size", Profile{Filters: &FilterRules{MaxFileSize: "huge"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			before := tt.profile
+
+			err := NewLoader().ValidateProfile(&tt.profile)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateProfile error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(tt.profile, before) {
+				t.Errorf("ValidateProfile modified the profile: %+v, was %+v", tt.profile, before)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// sizeUnits maps human-readable size suffixes to their byte multipliers.
+// Units are binary (1KB = 1024 bytes) to match how sizes are displayed.
+var sizeUnits = map[string]int64{
+	"":    1,
+	"B":   1,
+	"K":   1 << 10,
+	"KB":  1 << 10,
+	"KIB": 1 << 10,
+	"M":   1 << 20,
+	"MB":  1 << 20,
+	"MIB": 1 << 20,
+	"G":   1 << 30,
+	"GB":  1 << 30,
+	"GIB": 1 << 30,
+	"T":   1 << 40,
+	"TB":  1 << 40,
+	"TIB": 1 << 40,
+}
+
+// ParseSize parses a human-readable size such as "500MB", "1.5 GB" or "4096"
+// into a number of bytes. An empty string parses as zero.
+func ParseSize(size string) (int64, error) {
+	trimmed := strings.TrimSpace(size)
+	if trimmed == "" {
+		return 0, nil
+	}
+
+	split := strings.IndexFunc(trimmed, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if split == -1 {
+		split = len(trimmed)
+	}
+
+	number := trimmed[:split]
+	unit := strings.ToUpper(strings.TrimSpace(trimmed[split:]))
+
+	multiplier, exists := sizeUnits[unit]
+	if !exists {
+		return 0, fmt.Errorf("invalid size %q: unknown unit %q", size, unit)
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", size, err)
+	}
+
+	if value < 0 {
+		return 0, fmt.Errorf("invalid size %q: must be non-negative", size)
+	}
+
+	return int64(value * float64(multiplier)), nil
+}
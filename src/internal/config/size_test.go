@@ -0,0 +1,40 @@
+package config
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{input: "", want: 0},
+		{input: "4096", want: 4096},
+		{input: "1B", want: 1},
+		{input: "1KB", want: 1024},
+		{input: "1kb", want: 1024},
+		{input: "500MB", want: 500 * 1024 * 1024},
+		{input: "1.5 GB", want: 3 * 512 * 1024 * 1024},
+		{input: "2TiB", want: 2 << 40},
+		{input: "10XB", wantErr: true},
+		{input: "MB", wantErr: true},
+		{input: "1.2.3MB", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ParseSize(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSize(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("ParseSize(%q) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
@@ -6,61 +6,98 @@ import (
 
 // Config represents the main configuration structure for relay.
 type Config struct {
-	Version  string              `json:"version" toml:"version"`
-	Default  *Profile            `json:"default,omitempty" toml:"default,omitempty"`
-	Profiles map[string]*Profile `json:"profiles,omitempty" toml:"profiles,omitempty"`
+	Schema   string              `json:"$schema,omitempty" toml:"-" yaml:"-"`
+	Version  string              `json:"version" toml:"version" yaml:"version"`
+	Default  *Profile            `json:"default,omitempty" toml:"default,omitempty" yaml:"default,omitempty"`
+	Profiles map[string]*Profile `json:"profiles,omitempty" toml:"profiles,omitempty" yaml:"profiles,omitempty"`
 }
 
 // Profile defines synchronization settings and behavior.
 type Profile struct {
-	Mode        string             `json:"mode" toml:"mode"`
-	Source      string             `json:"source,omitempty" toml:"source,omitempty"`
-	Destination string             `json:"destination,omitempty" toml:"destination,omitempty"`
-	Watch       bool               `json:"watch" toml:"watch"`
-	Workers     int                `json:"workers" toml:"workers"`
-	BufferSize  string             `json:"bufferSize" toml:"bufferSize"`
-	Filters     *FilterRules       `json:"filters,omitempty" toml:"filters,omitempty"`
-	Conflict    *ConflictConfig    `json:"conflict,omitempty" toml:"conflict,omitempty"`
-	Retry       *RetryConfig       `json:"retry,omitempty" toml:"retry,omitempty"`
-	Performance *PerformanceConfig `json:"performance,omitempty" toml:"performance,omitempty"`
-	Extends     string             `json:"extends,omitempty" toml:"extends,omitempty"`
+	Mode        string             `json:"mode" toml:"mode" yaml:"mode"`
+	Source      string             `json:"source,omitempty" toml:"source,omitempty" yaml:"source,omitempty"`
+	Destination string             `json:"destination,omitempty" toml:"destination,omitempty" yaml:"destination,omitempty"`
+	Watch       bool               `json:"watch" toml:"watch" yaml:"watch"`
+	Workers     int                `json:"workers" toml:"workers" yaml:"workers"`
+	BufferSize  string             `json:"bufferSize" toml:"bufferSize" yaml:"bufferSize"`
+	Filters     *FilterRules       `json:"filters,omitempty" toml:"filters,omitempty" yaml:"filters,omitempty"`
+	Conflict    *ConflictConfig    `json:"conflict,omitempty" toml:"conflict,omitempty" yaml:"conflict,omitempty"`
+	Retry       *RetryConfig       `json:"retry,omitempty" toml:"retry,omitempty" yaml:"retry,omitempty"`
+	Performance *PerformanceConfig `json:"performance,omitempty" toml:"performance,omitempty" yaml:"performance,omitempty"`
+	Extends     string             `json:"extends,omitempty" toml:"extends,omitempty" yaml:"extends,omitempty"`
+	// Schedule is a five-field cron expression at which `relay schedule`
+	// mirrors Source to Destination. It is not inherited through Extends.
+	Schedule string `json:"schedule,omitempty" toml:"schedule,omitempty" yaml:"schedule,omitempty"`
+	// Pipeline is a sequence of mirrors that `relay run` performs in order,
+	// each with the rest of the profile's settings. It is not inherited
+	// through Extends.
+	Pipeline []PipelineStep `json:"pipeline,omitempty" toml:"pipeline,omitempty" yaml:"pipeline,omitempty"`
+}
+
+// PipelineStep is one mirror of a profile's pipeline. A failed step stops
+// the pipeline unless ContinueOnError is set.
+type PipelineStep struct {
+	Name            string       `json:"name,omitempty" toml:"name,omitempty" yaml:"name,omitempty"`
+	Mode            string       `json:"mode,omitempty" toml:"mode,omitempty" yaml:"mode,omitempty"` // only mirror for now
+	Source          string       `json:"source" toml:"source" yaml:"source"`
+	Destination     string       `json:"destination" toml:"destination" yaml:"destination"`
+	Filters         *FilterRules `json:"filters,omitempty" toml:"filters,omitempty" yaml:"filters,omitempty"` // replace the profile's filters for this step
+	ContinueOnError bool         `json:"continueOnError,omitempty" toml:"continueOnError,omitempty" yaml:"continueOnError,omitempty"`
 }
 
 // FilterRules defines file filtering and exclusion patterns.
 type FilterRules struct {
-	Smart            bool     `json:"smart" toml:"smart"`
-	Include          []string `json:"include" toml:"include"`
-	Exclude          []string `json:"exclude" toml:"exclude"`
-	RespectGitignore bool     `json:"respectGitignore" toml:"respectGitignore"`
-	IgnoreHidden     bool     `json:"ignoreHidden" toml:"ignoreHidden"`
-	MaxFileSize      string   `json:"maxFileSize,omitempty" toml:"maxFileSize,omitempty"`
-	MinFileSize      string   `json:"minFileSize,omitempty" toml:"minFileSize,omitempty"`
+	Smart            bool     `json:"smart" toml:"smart" yaml:"smart"`
+	Include          []string `json:"include" toml:"include" yaml:"include"`
+	Exclude          []string `json:"exclude" toml:"exclude" yaml:"exclude"`
+	RespectGitignore bool     `json:"respectGitignore" toml:"respectGitignore" yaml:"respectGitignore"`
+	IgnoreHidden     bool     `json:"ignoreHidden" toml:"ignoreHidden" yaml:"ignoreHidden"`
+	MaxFileSize      string   `json:"maxFileSize,omitempty" toml:"maxFileSize,omitempty" yaml:"maxFileSize,omitempty"`
+	MinFileSize      string   `json:"minFileSize,omitempty" toml:"minFileSize,omitempty" yaml:"minFileSize,omitempty"`
+	IncludeRegex     []string `json:"includeRegex,omitempty" toml:"includeRegex,omitempty" yaml:"includeRegex,omitempty"`
+	ExcludeRegex     []string `json:"excludeRegex,omitempty" toml:"excludeRegex,omitempty" yaml:"excludeRegex,omitempty"`
+	IncludeFrom      []string `json:"includeFrom,omitempty" toml:"includeFrom,omitempty" yaml:"includeFrom,omitempty"` // files listing more include globs, one per line
+	ExcludeFrom      []string `json:"excludeFrom,omitempty" toml:"excludeFrom,omitempty" yaml:"excludeFrom,omitempty"`
 }
 
 // ConflictConfig defines how file conflicts should be resolved.
 type ConflictConfig struct {
-	Strategy    string `json:"strategy" toml:"strategy"`
-	Backup      bool   `json:"backup" toml:"backup"`
-	BackupDir   string `json:"backupDir,omitempty" toml:"backupDir,omitempty"`
-	Interactive bool   `json:"interactive" toml:"interactive"`
+	Strategy    string `json:"strategy" toml:"strategy" yaml:"strategy"`
+	Backup      bool   `json:"backup" toml:"backup" yaml:"backup"`
+	BackupDir   string `json:"backupDir,omitempty" toml:"backupDir,omitempty" yaml:"backupDir,omitempty"`
+	Interactive bool   `json:"interactive" toml:"interactive" yaml:"interactive"`
 }
 
 // RetryConfig defines retry behavior for failed operations.
 type RetryConfig struct {
-	MaxAttempts  int           `json:"maxAttempts" toml:"maxAttempts"`
-	InitialDelay time.Duration `json:"initialDelay" toml:"initialDelay"`
-	MaxDelay     time.Duration `json:"maxDelay" toml:"maxDelay"`
-	Multiplier   float64       `json:"multiplier" toml:"multiplier"`
-	Backoff      string        `json:"backoff" toml:"backoff"`
+	MaxAttempts  int           `json:"maxAttempts" toml:"maxAttempts" yaml:"maxAttempts"`
+	InitialDelay time.Duration `json:"initialDelay" toml:"initialDelay" yaml:"initialDelay"`
+	MaxDelay     time.Duration `json:"maxDelay" toml:"maxDelay" yaml:"maxDelay"`
+	Multiplier   float64       `json:"multiplier" toml:"multiplier" yaml:"multiplier"`
+	Backoff      string        `json:"backoff" toml:"backoff" yaml:"backoff"`
 }
 
 // PerformanceConfig defines performance optimization settings.
 type PerformanceConfig struct {
-	UseZeroCopy    bool          `json:"useZeroCopy" toml:"useZeroCopy"`
-	EnableCaching  bool          `json:"enableCaching" toml:"enableCaching"`
-	ChecksumAlgo   string        `json:"checksumAlgo" toml:"checksumAlgo"`
-	IOConcurrency  int           `json:"ioConcurrency" toml:"ioConcurrency"`
-	NetworkTimeout time.Duration `json:"networkTimeout" toml:"networkTimeout"`
+	UseZeroCopy           bool                   `json:"useZeroCopy" toml:"useZeroCopy" yaml:"useZeroCopy"`
+	EnableCaching         bool                   `json:"enableCaching" toml:"enableCaching" yaml:"enableCaching"`
+	ChecksumAlgo          string                 `json:"checksumAlgo" toml:"checksumAlgo" yaml:"checksumAlgo"`
+	ChecksumSeed          string                 `json:"checksumSeed,omitempty" toml:"checksumSeed,omitempty" yaml:"checksumSeed,omitempty"`
+	ChecksumConcurrency   int                    `json:"checksumConcurrency,omitempty" toml:"checksumConcurrency,omitempty" yaml:"checksumConcurrency,omitempty"`
+	SampleChecksum        string                 `json:"sampleChecksum,omitempty" toml:"sampleChecksum,omitempty" yaml:"sampleChecksum,omitempty"` // sample size; hash large files from samples
+	ParallelHash          string                 `json:"parallelHash,omitempty" toml:"parallelHash,omitempty" yaml:"parallelHash,omitempty"`       // size from which files are hashed on every CPU
+	IOConcurrency         int                    `json:"ioConcurrency" toml:"ioConcurrency" yaml:"ioConcurrency"`
+	MaxOpenFiles          int                    `json:"maxOpenFiles,omitempty" toml:"maxOpenFiles,omitempty" yaml:"maxOpenFiles,omitempty"`
+	NetworkTimeout        time.Duration          `json:"networkTimeout" toml:"networkTimeout" yaml:"networkTimeout"`
+	BandwidthSchedule     []BandwidthWindow      `json:"bandwidthSchedule,omitempty" toml:"bandwidthSchedule,omitempty" yaml:"bandwidthSchedule,omitempty"`
+	ConcurrencyMultiplier *ConcurrencyMultiplier `json:"concurrencyMultiplier,omitempty" toml:"concurrencyMultiplier,omitempty" yaml:"concurrencyMultiplier,omitempty"`
+}
+
+// ConcurrencyMultiplier scales GOMAXPROCS to size worker pools when worker
+// counts are auto-detected. Zero keeps the default for that stage.
+type ConcurrencyMultiplier struct {
+	Scan float64 `json:"scan,omitempty" toml:"scan,omitempty" yaml:"scan,omitempty"`
+	Copy float64 `json:"copy,omitempty" toml:"copy,omitempty" yaml:"copy,omitempty"`
 }
 
 // ConflictStrategy represents different conflict resolution strategies
@@ -74,6 +111,7 @@ const (
 	ConflictInteractive ConflictStrategy = "interactive"
 	ConflictSmart       ConflictStrategy = "smart"
 	ConflictSkip        ConflictStrategy = "skip"
+	ConflictKeepNewest  ConflictStrategy = "keep-newest" // written as "keep-newest:N"
 )
 
 // SyncMode represents different synchronization modes
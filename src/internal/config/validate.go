@@ -0,0 +1,401 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"go.yaml.in/yaml/v3"
+)
+
+// Severity ranks an Issue found by Check.
+type Severity int
+
+// Issue severities. Only errors make a config invalid.
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+// Issue is a problem Check found in a config file.
+type Issue struct {
+	Severity Severity
+	Key      string // dotted path of the key at fault, such as profiles.nas.source; empty for the whole file
+	Line     int    // 1-based position in the file, or 0 when unknown
+	Column   int    // 0 when unknown, as for YAML
+	Message  string
+}
+
+// String formats the issue with its position or key, whichever is known.
+func (i Issue) String() string {
+	switch {
+	case i.Line > 0 && i.Column > 0:
+		return fmt.Sprintf("line %d, column %d: %s", i.Line, i.Column, i.Message)
+	case i.Line > 0:
+		return fmt.Sprintf("line %d: %s", i.Line, i.Message)
+	case i.Key != "":
+		return i.Key + ": " + i.Message
+	default:
+		return i.Message
+	}
+}
+
+// Check validates the config file at path more thoroughly than Load. Besides
+// the rules Load enforces, it reports syntax errors with their line and
+// column, keys Load would silently ignore, sources that do not exist,
+// destinations that do not exist yet, malformed glob patterns and missing
+// pattern files. Relative paths are checked against the working directory,
+// as relay resolves them. The error is only for a file that cannot be read.
+func (l *Loader) Check(path string) ([]Issue, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	var (
+		raw    any
+		config Config
+		format string
+	)
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".jsonc":
+		format = "json"
+		content = blankJSONComments(content)
+
+		if err := json.Unmarshal(content, &raw); err != nil {
+			return []Issue{jsonIssue(content, err)}, nil
+		}
+
+		if err := json.Unmarshal(content, &config); err != nil {
+			return []Issue{jsonIssue(content, err)}, nil
+		}
+	case ".toml":
+		format = "toml"
+
+		if err := toml.Unmarshal(content, &raw); err != nil {
+			return []Issue{tomlIssue(err)}, nil
+		}
+
+		if err := toml.Unmarshal(content, &config); err != nil {
+			return []Issue{tomlIssue(err)}, nil
+		}
+	case ".yaml", ".yml":
+		format = "yaml"
+
+		if err := yaml.Unmarshal(content, &raw); err != nil {
+			return []Issue{yamlIssue(err)}, nil
+		}
+
+		if err := yaml.Unmarshal(content, &config); err != nil {
+			return []Issue{yamlIssue(err)}, nil
+		}
+	default:
+		return []Issue{{Message: fmt.Sprintf("unsupported config format: %s", filepath.Ext(path))}}, nil
+	}
+
+	var issues []Issue
+
+	for _, key := range unknownKeys(raw, reflect.TypeFor[Config](), format, "") {
+		issues = append(issues, Issue{Key: key, Message: "unknown key; relay ignores it"})
+	}
+
+	if err := l.validateConfig(&config); err != nil {
+		return append(issues, Issue{Message: err.Error()}), nil
+	}
+
+	if err := l.resolveExtends(&config); err != nil {
+		return append(issues, Issue{Message: err.Error()}), nil
+	}
+
+	for _, name := range profileKeys(&config) {
+		profile := config.Default
+		if name != "default" {
+			profile = config.Profiles[strings.TrimPrefix(name, "profiles.")]
+		}
+
+		issues = append(issues, checkPaths(name, profile.Source, profile.Destination)...)
+		issues = append(issues, checkGlobs(name+".filters", profile.Filters)...)
+
+		for i, step := range profile.Pipeline {
+			key := fmt.Sprintf("%s.pipeline[%d]", name, i)
+			issues = append(issues, checkPaths(key, step.Source, step.Destination)...)
+			issues = append(issues, checkGlobs(key+".filters", step.Filters)...)
+		}
+	}
+
+	return issues, nil
+}
+
+// Normalize fills in the defaults Load applies to config, as they are written
+// to a file, and reports the first rule config breaks. Profile inheritance is
+// left unresolved.
+func (l *Loader) Normalize(config *Config) error {
+	if err := l.validateConfig(config); err != nil {
+		return err
+	}
+
+	profiles := slices.Collect(maps.Values(config.Profiles))
+	if config.Default != nil {
+		profiles = append(profiles, config.Default)
+	}
+
+	// Files spell auto-detected workers as 0; Load rejects negative counts.
+	for _, profile := range profiles {
+		if profile.Workers < 0 {
+			profile.Workers = 0
+		}
+	}
+
+	return nil
+}
+
+// profileKeys returns the keys of config's profiles, the default profile
+// first and the rest sorted.
+func profileKeys(config *Config) []string {
+	var keys []string
+
+	for name := range config.Profiles {
+		keys = append(keys, "profiles."+name)
+	}
+
+	slices.Sort(keys)
+
+	if config.Default != nil {
+		keys = slices.Insert(keys, 0, "default")
+	}
+
+	return keys
+}
+
+// checkPaths reports a source that does not exist as an error, and a
+// destination that does not exist as a warning, since relay creates it.
+func checkPaths(key, source, destination string) []Issue {
+	var issues []Issue
+
+	if source != "" {
+		if _, err := os.Stat(source); err != nil {
+			issues = append(issues, Issue{Key: key + ".source", Message: pathProblem(source, err)})
+		}
+	}
+
+	if destination != "" {
+		if _, err := os.Stat(destination); errors.Is(err, os.ErrNotExist) {
+			issues = append(issues, Issue{
+				Severity: SeverityWarning,
+				Key:      key + ".destination",
+				Message:  fmt.Sprintf("%s does not exist yet; relay will create it", destination),
+			})
+		} else if err != nil {
+			issues = append(issues, Issue{Key: key + ".destination", Message: pathProblem(destination, err)})
+		}
+	}
+
+	return issues
+}
+
+// pathProblem describes why path could not be statted.
+func pathProblem(path string, err error) string {
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Sprintf("%s does not exist", path)
+	}
+
+	return fmt.Sprintf("cannot access %s: %v", path, err)
+}
+
+// checkGlobs reports the include and exclude patterns of rules that are not
+// valid globs, and the pattern files they name that cannot be read.
+func checkGlobs(key string, rules *FilterRules) []Issue {
+	if rules == nil {
+		return nil
+	}
+
+	var issues []Issue
+
+	for field, patterns := range map[string][]string{"include": rules.Include, "exclude": rules.Exclude} {
+		for i, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				issues = append(issues, Issue{
+					Key:     fmt.Sprintf("%s.%s[%d]", key, field, i),
+					Message: fmt.Sprintf("invalid glob %q: %v", pattern, err),
+				})
+			}
+		}
+	}
+
+	for field, files := range map[string][]string{"includeFrom": rules.IncludeFrom, "excludeFrom": rules.ExcludeFrom} {
+		for i, file := range files {
+			if _, err := os.Stat(file); err != nil {
+				issues = append(issues, Issue{
+					Key:     fmt.Sprintf("%s.%s[%d]", key, field, i),
+					Message: fmt.Sprintf("cannot read pattern file %s", file),
+				})
+			}
+		}
+	}
+
+	slices.SortFunc(issues, func(a, b Issue) int { return strings.Compare(a.Key, b.Key) })
+
+	return issues
+}
+
+// unknownKeys returns the dotted paths of the keys in value, decoded from a
+// file of format into generic maps and slices, that match no field of typ.
+// Keys match as they do when the file is loaded: case-insensitively, except
+// in YAML.
+func unknownKeys(value any, typ reflect.Type, format, key string) []string {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	var unknown []string
+
+	switch typ.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+
+		for _, name := range slices.Sorted(maps.Keys(object)) {
+			field, ok := fieldNamed(typ, format, name)
+			if !ok {
+				unknown = append(unknown, joinKey(key, name))
+				continue
+			}
+
+			unknown = append(unknown, unknownKeys(object[name], field.Type, format, joinKey(key, name))...)
+		}
+	case reflect.Map:
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+
+		for _, name := range slices.Sorted(maps.Keys(object)) {
+			unknown = append(unknown, unknownKeys(object[name], typ.Elem(), format, joinKey(key, name))...)
+		}
+	case reflect.Slice:
+		elements, ok := value.([]any)
+		if !ok {
+			return nil
+		}
+
+		for i, element := range elements {
+			unknown = append(unknown, unknownKeys(element, typ.Elem(), format, fmt.Sprintf("%s[%d]", key, i))...)
+		}
+	}
+
+	return unknown
+}
+
+// fieldNamed returns the field of typ that the key name decodes into.
+func fieldNamed(typ reflect.Type, format, name string) (reflect.StructField, bool) {
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+
+		tag, _, _ := strings.Cut(field.Tag.Get(format), ",")
+		if tag == "-" {
+			continue
+		}
+
+		if tag == "" {
+			tag = field.Name
+		}
+
+		if tag == name || (format != "yaml" && strings.EqualFold(tag, name)) {
+			return field, true
+		}
+	}
+
+	return reflect.StructField{}, false
+}
+
+func joinKey(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+
+	return parent + "." + name
+}
+
+// jsonIssue describes a JSON decoding error, at its position in content when
+// the error has one.
+func jsonIssue(content []byte, err error) Issue {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+
+	switch {
+	case errors.As(err, &syntaxErr):
+		line, column := offsetPosition(content, syntaxErr.Offset)
+		return Issue{Line: line, Column: column, Message: syntaxErr.Error()}
+	case errors.As(err, &typeErr):
+		line, column := offsetPosition(content, typeErr.Offset)
+		message := fmt.Sprintf("%s must be %s, not %s", typeErr.Field, typeErr.Type, typeErr.Value)
+
+		return Issue{Key: typeErr.Field, Line: line, Column: column, Message: message}
+	default:
+		return Issue{Message: err.Error()}
+	}
+}
+
+// tomlIssue describes a TOML decoding error, at its position when the error
+// has one.
+func tomlIssue(err error) Issue {
+	var decodeErr *toml.DecodeError
+	if !errors.As(err, &decodeErr) {
+		return Issue{Message: err.Error()}
+	}
+
+	line, column := decodeErr.Position()
+
+	return Issue{Key: strings.Join(decodeErr.Key(), "."), Line: line, Column: column, Message: decodeErr.Error()}
+}
+
+// yamlLinePattern finds the line a YAML decoding error is at.
+var yamlLinePattern = regexp.MustCompile(`line ([0-9]+):`)
+
+// yamlIssue describes a YAML decoding error, at the line of its first
+// problem when the error gives one. YAML errors have no column.
+func yamlIssue(err error) Issue {
+	message := strings.TrimPrefix(err.Error(), "yaml: ")
+
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) && len(typeErr.Errors) > 0 {
+		message = typeErr.Errors[0]
+	}
+
+	match := yamlLinePattern.FindStringSubmatchIndex(message)
+	if match == nil {
+		return Issue{Message: message}
+	}
+
+	line, _ := strconv.Atoi(message[match[2]:match[3]])
+
+	return Issue{Line: line, Message: strings.TrimSpace(message[match[1]:])}
+}
+
+// offsetPosition returns the 1-based line and column of the byte before
+// offset, where encoding/json reports an error to have been found.
+func offsetPosition(content []byte, offset int64) (int, int) {
+	offset = min(max(offset-1, 0), int64(len(content)))
+	before := content[:offset]
+
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+
+	return line, column
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoaderCheck(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		file    string
+		content string // {dir} is replaced with an existing directory
+		want    []string
+	}{
+		{
+			name: "valid JSONC",
+			file: "relay.jsonc",
+			content: `{
+	// "//" inside strings is not a comment
+	"profiles": {
+		"nas": { "source": "{dir}", "destination": "{dir}" /* block */ }
+	}
+}`,
+		},
+		{
+			name:    "syntax error position",
+			file:    "relay.jsonc",
+			content: "{\n\t// comment\n\t\"profiles\": {\n\t\t\"nas\": { \"source\": \"src\",, }\n\t}\n}",
+			want:    []string{"line 4, column 28: invalid character ',' looking for beginning of object key string"},
+		},
+		{
+			name:    "wrong type",
+			file:    "relay.json",
+			content: `{"profiles": {"nas": {"workers": "four"}}}`,
+			want:    []string{"line 1, column 39: profiles.nas.workers must be int, not string"},
+		},
+		{
+			name:    "TOML syntax error position",
+			file:    "relay.toml",
+			content: "[profiles.nas]\nsource = \"src\n",
+			want:    []string{"line 2, column 14: toml: basic strings cannot have new lines"},
+		},
+		{
+			name:    "YAML syntax error line",
+			file:    "relay.yaml",
+			content: "profiles:\n  nas: source: src\n",
+			want:    []string{"line 2: mapping values are not allowed in this context"},
+		},
+		{
+			name:    "YAML wrong type",
+			file:    "relay.yaml",
+			content: "profiles:\n  nas:\n    workers: four\n",
+			want:    []string{"line 3: cannot unmarshal !!str `four` into int"},
+		},
+		{
+			name:    "YAML keys are case-sensitive",
+			file:    "relay.yaml",
+			content: "profiles:\n  nas:\n    source: \"{dir}\"\n    Destination: \"{dir}\"\n",
+			want:    []string{"profiles.nas.Destination: unknown key; relay ignores it"},
+		},
+		{
+			name: "unknown keys",
+			file: "relay.json",
+			content: `{"profiles": {"nas": {
+				"source": "{dir}", "destinaton": "{dir}",
+				"retry": {"maxAttempt": 3}, "Destination": "{dir}"
+			}}}`,
+			want: []string{
+				"profiles.nas.destinaton: unknown key; relay ignores it",
+				"profiles.nas.retry.maxAttempt: unknown key; relay ignores it",
+			},
+		},
+		{
+			name: "paths and globs",
+			file: "relay.toml",
+			content: `[default]
+source = "{dir}/missing"
+destination = "{dir}/new"
+
+[default.filters]
+exclude = ["*.tmp", "[a-"]
+excludeFrom = ["{dir}/excludes.txt"]
+
+[[default.pipeline]]
+source = "{dir}"
+destination = "{dir}"
+`,
+			want: []string{
+				"default.source: {dir}/missing does not exist",
+				"default.destination: {dir}/new does not exist yet; relay will create it",
+				"default.filters.excludeFrom[0]: cannot read pattern file {dir}/excludes.txt",
+				`default.filters.exclude[1]: invalid glob "[a-": syntax error in pattern`,
+			},
+		},
+		{
+			name:    "rules Load enforces",
+			file:    "relay.json",
+			content: `{"profiles": {"nas": {"mode": "teleport"}}}`,
+			want:    []string{"invalid profile nas: invalid mode teleport, must be one of: [mirror sync watch]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tempDir := t.TempDir()
+			configFile := filepath.Join(tempDir, tt.file)
+
+			content := strings.ReplaceAll(tt.content, "{dir}", tempDir)
+			if err := os.WriteFile(configFile, []byte(content), 0o644); err != nil {
+				t.Fatalf("Failed to write config file: %v", err)
+			}
+
+			issues, err := NewLoader().Check(configFile)
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+
+			var got []string
+			for _, issue := range issues {
+				got = append(got, issue.String())
+			}
+
+			var want []string
+			for _, line := range tt.want {
+				want = append(want, strings.ReplaceAll(line, "{dir}", tempDir))
+			}
+
+			if !slices.Equal(got, want) {
+				t.Errorf("issues = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestLoaderNormalize(t *testing.T) {
+	t.Parallel()
+
+	config := &Config{Profiles: map[string]*Profile{"nas": {Retry: &RetryConfig{}}}}
+
+	if err := NewLoader().Normalize(config); err != nil {
+		t.Fatalf("Normalize failed: %v", err)
+	}
+
+	nas := config.Profiles["nas"]
+	if config.Version != "1.0" || nas.Mode != "mirror" || nas.Workers != 0 || nas.Retry.MaxAttempts != 3 {
+		t.Errorf("Normalize = version %q, %+v, retry %+v; want defaults filled in with workers left at 0",
+			config.Version, nas, nas.Retry)
+	}
+}
@@ -0,0 +1,119 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// activityLogSize is how many recent operations the engine keeps for display.
+const activityLogSize = 256
+
+// FileOperation is a change made to the destination, or in a dry run one that
+// would be made.
+type FileOperation struct {
+	Time time.Time  `json:"time"`
+	Type ChangeType `json:"type"`
+	Path string     `json:"path"` // relative to the destination root
+	Size int64      `json:"size"` // bytes written, or freed by a deletion
+}
+
+// String formats the operation as a change list line: its type and path,
+// separated by a tab.
+func (op FileOperation) String() string {
+	return op.Type.String() + "\t" + op.Path
+}
+
+// SetChangeList makes each run record the paths it actually changed; dry runs
+// record nothing, unless SetDryRunChanges is set. With w nil the changes are kept in memory for
+// GetChangedFiles. Otherwise each change is written to w as a String line as
+// it happens and nothing is kept, which bounds memory for enormous change
+// sets.
+func (e *SyncEngine) SetChangeList(enabled bool, w io.Writer) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.listChanges = enabled
+	e.changeOut = w
+}
+
+// SetDryRunChanges makes dry runs record the changes they would make in the
+// change list, as a preview of the run, instead of nothing.
+func (e *SyncEngine) SetDryRunChanges(enabled bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.dryRunChanges = enabled
+}
+
+// GetChangedFiles returns the changes the last run kept in memory, in the
+// order they were made.
+func (e *SyncEngine) GetChangedFiles() []FileOperation {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return append([]FileOperation(nil), e.changes...)
+}
+
+// ChangeListError returns the first error writing the last run's change list
+// to the writer given to SetChangeList.
+func (e *SyncEngine) ChangeListError() error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.changeErr
+}
+
+// recordOperation appends an operation to the bounded activity log, dropping
+// the oldest entry once activityLogSize is reached, and to the change list
+// when one is being recorded.
+func (e *SyncEngine) recordOperation(changeType ChangeType, relPath string, size int64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	operation := FileOperation{Time: time.Now(), Type: changeType, Path: relPath, Size: size}
+
+	if e.listChanges && (!e.stats.DryRun || e.dryRunChanges) {
+		e.recordChange(operation)
+	}
+
+	e.events.Send(changeEvent(changeType, relPath, size, operation.Time))
+	e.logger.Debug("change", "type", changeType.String(), "path", relPath, "size", size, "dry_run", e.stats.DryRun)
+
+	if len(e.activity) < activityLogSize {
+		e.activity = append(e.activity, operation)
+		return
+	}
+
+	e.activity[e.activityNext] = operation
+	e.activityNext = (e.activityNext + 1) % activityLogSize
+}
+
+// RecentOperations returns up to the last activityLogSize operations of the
+// current run, oldest first.
+func (e *SyncEngine) RecentOperations() []FileOperation {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	operations := make([]FileOperation, 0, len(e.activity))
+	operations = append(operations, e.activity[e.activityNext:]...)
+	operations = append(operations, e.activity[:e.activityNext]...)
+
+	return operations
+}
+
+// recordChange adds operation to the change list. The caller must hold e.mu.
+func (e *SyncEngine) recordChange(operation FileOperation) {
+	if e.changeOut == nil {
+		e.changes = append(e.changes, operation)
+		return
+	}
+
+	if e.changeErr != nil {
+		return
+	}
+
+	if _, err := fmt.Fprintln(e.changeOut, operation); err != nil {
+		e.changeErr = fmt.Errorf("failed to write change list: %w", err)
+	}
+}
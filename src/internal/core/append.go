@@ -0,0 +1,130 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// appendCheckBlockSize is the size of each block compared when verifying
+// that a destination file is a prefix of its source.
+const appendCheckBlockSize = 64 * 1024
+
+// ErrNotPrefix is returned by AppendFile when the destination is not a
+// prefix of the source, so the tail cannot simply be appended.
+var ErrNotPrefix = errors.New("destination is not a prefix of source")
+
+// AppendFile brings an append-only destination up to date by writing only the
+// bytes of src past the destination's current length. Before writing, blocks
+// at the start, middle and end of the existing destination are compared with
+// the source; if any differ, ErrNotPrefix is returned and dst is untouched.
+// It returns the number of bytes appended.
+func (fc *FileCopier) AppendFile(ctx context.Context, src, dst string) (int64, error) {
+	src, dst = toExtendedPath(src), toExtendedPath(dst)
+
+	if err := fc.guard.check("append to", dst); err != nil {
+		return 0, err
+	}
+
+	release, err := fc.openFiles.acquire(ctx, 2)
+	if err != nil {
+		return 0, fmt.Errorf("append cancelled: %w", err)
+	}
+
+	defer release()
+
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open source file %s: %w", src, err)
+	}
+
+	defer func() { _ = srcFile.Close() }()
+
+	dstFile, err := os.OpenFile(dst, os.O_RDWR, 0)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open destination file %s: %w", dst, err)
+	}
+
+	defer func() { _ = dstFile.Close() }()
+
+	srcInfo, err := srcFile.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat source file %s: %w", src, err)
+	}
+
+	dstInfo, err := dstFile.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat destination file %s: %w", dst, err)
+	}
+
+	prefixLen := dstInfo.Size()
+	if prefixLen == 0 || prefixLen >= srcInfo.Size() {
+		return 0, ErrNotPrefix
+	}
+
+	if err := verifyPrefix(srcFile, dstFile, prefixLen); err != nil {
+		return 0, err
+	}
+
+	if _, err := srcFile.Seek(prefixLen, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to seek source file: %w", err)
+	}
+
+	if _, err := dstFile.Seek(prefixLen, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to seek destination file: %w", err)
+	}
+
+	appended, err := fc.bufferedCopy(ctx, srcFile, dstFile)
+	if err != nil {
+		// Drop the partial tail so the destination is still a valid prefix.
+		_ = dstFile.Truncate(prefixLen)
+		return 0, fmt.Errorf("failed to append to %s: %w", dst, err)
+	}
+
+	if err := dstFile.Sync(); err != nil {
+		return appended, fmt.Errorf("failed to sync destination file: %w", err)
+	}
+
+	if fc.preservePerms {
+		if err := os.Chmod(dst, srcInfo.Mode()); err != nil {
+			return appended, fmt.Errorf("failed to set file permissions: %w", err)
+		}
+	}
+
+	if fc.preserveTimes {
+		if err := os.Chtimes(dst, srcInfo.ModTime(), srcInfo.ModTime()); err != nil {
+			return appended, fmt.Errorf("failed to set file times: %w", err)
+		}
+	}
+
+	return appended, nil
+}
+
+// verifyPrefix spot-checks that the first prefixLen bytes of dst match src
+// by comparing a block at the start, middle and end of the prefix.
+func verifyPrefix(src, dst io.ReaderAt, prefixLen int64) error {
+	blockSize := min(int64(appendCheckBlockSize), prefixLen)
+	offsets := []int64{0, (prefixLen - blockSize) / 2, prefixLen - blockSize}
+
+	srcBlock := make([]byte, blockSize)
+	dstBlock := make([]byte, blockSize)
+
+	for _, offset := range offsets {
+		if _, err := src.ReadAt(srcBlock, offset); err != nil {
+			return fmt.Errorf("failed to read source block at %d: %w", offset, err)
+		}
+
+		if _, err := dst.ReadAt(dstBlock, offset); err != nil {
+			return fmt.Errorf("failed to read destination block at %d: %w", offset, err)
+		}
+
+		if !bytes.Equal(srcBlock, dstBlock) {
+			return fmt.Errorf("%w: block at offset %d differs", ErrNotPrefix, offset)
+		}
+	}
+
+	return nil
+}
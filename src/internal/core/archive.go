@@ -0,0 +1,744 @@
+package core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"cmp"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ErrArchiveOption is returned by SyncToArchive for options that only make
+// sense for a destination tree of individual files.
+var ErrArchiveOption = errors.New("not supported with an archive destination")
+
+// ArchiveFormat is the kind of archive file SyncToArchive writes.
+type ArchiveFormat int
+
+// Archive formats
+const (
+	// ArchiveNone is a destination directory rather than an archive.
+	ArchiveNone ArchiveFormat = iota
+	ArchiveTar
+	ArchiveTarGzip
+	ArchiveZip
+)
+
+func (f ArchiveFormat) String() string {
+	switch f {
+	case ArchiveTar:
+		return "tar"
+	case ArchiveTarGzip:
+		return "tar.gz"
+	case ArchiveZip:
+		return "zip"
+	default:
+		return "none"
+	}
+}
+
+// ArchiveFormatOf returns the archive format the name of path selects: .tar,
+// .tar.gz or .tgz, or .zip, in any case. An existing directory is never an
+// archive, whatever its name.
+func ArchiveFormatOf(path string) ArchiveFormat {
+	name := strings.ToLower(filepath.Base(path))
+
+	var format ArchiveFormat
+
+	switch {
+	case strings.HasSuffix(name, ".tar"):
+		format = ArchiveTar
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		format = ArchiveTarGzip
+	case strings.HasSuffix(name, ".zip"):
+		format = ArchiveZip
+	default:
+		return ArchiveNone
+	}
+
+	if info, err := os.Stat(toExtendedPath(path)); err == nil && info.IsDir() {
+		return ArchiveNone
+	}
+
+	return format
+}
+
+// modTimePrecision is how coarsely the format stores modification times.
+// Tar archives are written in the PAX format, which keeps nanoseconds.
+func (f ArchiveFormat) modTimePrecision() time.Duration {
+	if f == ArchiveZip {
+		return time.Second
+	}
+
+	return 0
+}
+
+// SyncToArchive mirrors source into the single archive file archivePath, in
+// the format ArchiveFormatOf selects, instead of a tree of files. Each source
+// file becomes an entry named by its relative path, with its mode and
+// modification time; symlinks are stored as links.
+//
+// Entries are compared with the source by type, size, permissions and
+// modification time as stored in the archive. When any differs, or
+// DeleteExtraneous finds entries to drop, the whole archive is written again
+// to a temporary file beside it that then replaces it, so it is never left
+// half written; otherwise it is left alone. Entries without a source are
+// carried over unless DeleteExtraneous is set, and so are entries whose
+// source cannot be read and entries whose names are not plain relative paths.
+//
+// File lists, append-only copies, directory move detection, VerifyAfter,
+// quarantine, MaxFiles, ProtectDestNewer, destination snapshots and checksum
+// files need a tree of individual files and are rejected with
+// ErrArchiveOption.
+func (e *SyncEngine) SyncToArchive(ctx context.Context, source, archivePath string, opts SyncOptions) (*SyncStats, error) {
+	format := ArchiveFormatOf(archivePath)
+	if format == ArchiveNone {
+		return nil, fmt.Errorf("%s is not a .tar, .tar.gz, .tgz or .zip file", archivePath)
+	}
+
+	if err := e.checkArchiveOptions(opts); err != nil {
+		return nil, err
+	}
+
+	if err := e.startRun(opts.DryRun); err != nil {
+		return nil, err
+	}
+
+	defer e.endRun()
+
+	e.applyCopyOptions(opts)
+
+	// The archive would end up containing its own previous version.
+	if inside, err := archiveWithin(source, archivePath); err != nil || inside {
+		return e.GetStats(), cmp.Or(err, fmt.Errorf("archive %s is inside the source %s", archivePath, source))
+	}
+
+	scanned, err := e.scanner.ScanWithFilter(ctx, source, e.sourceFilter(source))
+	if err != nil {
+		if !e.recordIncompleteScan(err) {
+			return e.GetStats(), fmt.Errorf("failed to scan source directory: %w", err)
+		}
+	}
+
+	// The source root itself has no entry.
+	sourceFiles := slices.DeleteFunc(scanned, func(file *FileInfo) bool {
+		relPath, err := filepath.Rel(source, file.Path)
+		return err != nil || relPath == "."
+	})
+	slices.SortFunc(sourceFiles, func(a, b *FileInfo) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	entries, exists, err := readArchiveIndex(archivePath, format)
+	if err != nil {
+		return e.GetStats(), err
+	}
+
+	// Archives may store coarser times than the source filesystem.
+	opts.ModifyWindow = max(opts.ModifyWindow, format.modTimePrecision())
+
+	if err := e.runPreflight(e.planSync(source, sourceFiles, entries, opts)); err != nil {
+		return e.GetStats(), err
+	}
+
+	changes := e.archiveChanges(source, sourceFiles, entries, opts)
+	if exists && len(changes) == 0 {
+		return e.finishRun(), nil
+	}
+
+	var skipped map[string]bool
+
+	if !opts.DryRun {
+		skipped, err = e.writeArchive(ctx, source, archivePath, format, sourceFiles, exists, opts)
+		if err != nil {
+			e.errorHandler.AddError(ClassifySyncError("archive", archivePath, err))
+			atomic.AddInt64(&e.stats.ErrorsEncountered, 1)
+
+			return e.finishRun(), err
+		}
+	}
+
+	for _, change := range changes {
+		if !skipped[change.relPath] {
+			e.countArchiveChange(archivePath, change)
+		}
+	}
+
+	return e.finishRun(), e.guard.runError()
+}
+
+// checkArchiveOptions rejects the options SyncToArchive cannot honour.
+func (e *SyncEngine) checkArchiveOptions(opts SyncOptions) error {
+	switch {
+	case opts.FileList != nil:
+		return fmt.Errorf("file lists are %w", ErrArchiveOption)
+	case opts.AppendOnly:
+		return fmt.Errorf("append-only copies are %w", ErrArchiveOption)
+	case opts.DetectMoves && opts.DeleteExtraneous:
+		return fmt.Errorf("directory move detection is %w", ErrArchiveOption)
+	case opts.VerifyAfter:
+		return fmt.Errorf("verifying after the sync is %w", ErrArchiveOption)
+	case opts.Quarantine:
+		return fmt.Errorf("quarantine is %w", ErrArchiveOption)
+	case opts.MaxFiles > 0:
+		return fmt.Errorf("file limits are %w", ErrArchiveOption)
+	case opts.ProtectDestNewer:
+		return fmt.Errorf("keeping newer destination files is %w", ErrArchiveOption)
+	case e.snapshotPath != "":
+		return fmt.Errorf("destination snapshots are %w", ErrArchiveOption)
+	case e.checksumMode != ChecksumFilesOff:
+		return fmt.Errorf("checksum files are %w", ErrArchiveOption)
+	}
+
+	return nil
+}
+
+// archiveWithin reports whether archivePath is inside the directory dir.
+func archiveWithin(dir, archivePath string) (bool, error) {
+	resolvedDir, err := resolvePath(dir)
+	if err != nil {
+		return false, fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+
+	resolvedArchive, err := resolvePath(archivePath)
+	if err != nil {
+		return false, fmt.Errorf("failed to resolve %s: %w", archivePath, err)
+	}
+
+	relPath, err := filepath.Rel(resolvedDir, resolvedArchive)
+
+	return err == nil && filepath.IsLocal(relPath), nil
+}
+
+// archiveChange is an entry a run adds, replaces or drops.
+type archiveChange struct {
+	changeType ChangeType
+	relPath    string
+	source     *FileInfo // nil when dropped
+	entry      *FileInfo // nil when added
+}
+
+// archiveChanges compares the source with the entries of the archive.
+func (e *SyncEngine) archiveChanges(source string, sourceFiles []*FileInfo, entries map[string]*FileInfo, opts SyncOptions) []archiveChange {
+	var changes []archiveChange
+
+	for _, file := range sourceFiles {
+		relPath, err := filepath.Rel(source, file.Path)
+		if err != nil {
+			continue
+		}
+
+		entry, exists := entries[relPath]
+
+		switch {
+		case !exists:
+			changes = append(changes, archiveChange{changeType: ChangeCreate, relPath: relPath, source: file})
+		case fs.FileMode(file.Mode).Type() != fs.FileMode(entry.Mode).Type():
+			changes = append(changes, archiveChange{changeType: ChangeModify, relPath: relPath, source: file, entry: entry})
+		case file.IsDir:
+			// Directory sizes and times follow their contents.
+		case fs.FileMode(file.Mode).Perm() != fs.FileMode(entry.Mode).Perm(), e.needsSync(file, entry, opts):
+			changes = append(changes, archiveChange{changeType: ChangeModify, relPath: relPath, source: file, entry: entry})
+		}
+	}
+
+	if !opts.DeleteExtraneous {
+		return changes
+	}
+
+	inSource := relativePaths(source, sourceFiles)
+
+	for _, relPath := range slices.Sorted(maps.Keys(entries)) {
+		if _, exists := inSource[relPath]; !exists {
+			changes = append(changes, archiveChange{changeType: ChangeDelete, relPath: relPath, entry: entries[relPath]})
+		}
+	}
+
+	return changes
+}
+
+// countArchiveChange counts one change in the run's statistics and records
+// it in the activity log and audit log.
+func (e *SyncEngine) countArchiveChange(archivePath string, change archiveChange) {
+	entryPath := filepath.Join(archivePath, change.relPath)
+
+	switch change.changeType {
+	case ChangeDelete:
+		atomic.AddInt64(&e.stats.FilesDeleted, 1)
+		if !change.entry.IsDir {
+			atomic.AddInt64(&e.stats.BytesDeleted, change.entry.Size)
+		}
+
+		e.recordOperation(ChangeDelete, change.relPath, change.entry.Size)
+	case ChangeCreate:
+		atomic.AddInt64(&e.stats.FilesCreated, 1)
+		if !change.source.IsDir {
+			atomic.AddInt64(&e.stats.FilesChanged, 1)
+		}
+
+		e.recordOperation(ChangeCreate, change.relPath, change.source.Size)
+	default:
+		atomic.AddInt64(&e.stats.FilesModified, 1)
+		atomic.AddInt64(&e.stats.FilesChanged, 1)
+		e.recordOperation(ChangeModify, change.relPath, change.source.Size)
+	}
+
+	e.audit(change.changeType, entryPath, "", change.entry, change.source)
+}
+
+// writeArchive writes the archive afresh to a temporary file beside
+// archivePath, then moves it into place. Source files that cannot be read are
+// recorded and skipped, keeping their old entries; their relative paths are
+// returned. Any other failure leaves the archive as it was.
+func (e *SyncEngine) writeArchive(ctx context.Context, source, archivePath string, format ArchiveFormat, sourceFiles []*FileInfo, exists bool, opts SyncOptions) (map[string]bool, error) {
+	if err := e.guard.check("write", archivePath); err != nil {
+		return nil, err
+	}
+
+	perm := fs.FileMode(0o644)
+
+	var oldSize int64
+
+	if info, err := os.Stat(toExtendedPath(archivePath)); err == nil {
+		perm, oldSize = info.Mode().Perm(), info.Size()
+	}
+
+	dir := filepath.Dir(archivePath)
+	if err := os.MkdirAll(toExtendedPath(dir), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	temp, err := os.CreateTemp(toExtendedPath(dir), "."+filepath.Base(archivePath)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary archive: %w", err)
+	}
+
+	committed := false
+
+	defer func() {
+		if !committed {
+			_ = temp.Close()
+			_ = os.Remove(temp.Name())
+		}
+	}()
+
+	atomic.StoreInt64(&e.progress.Total, int64(len(sourceFiles)))
+
+	writer := newArchiveWriter(temp, format)
+	skipped := make(map[string]bool)
+
+	for _, file := range sourceFiles {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		relPath, err := filepath.Rel(source, file.Path)
+		if err != nil {
+			continue
+		}
+
+		started, err := e.addToArchive(ctx, writer, relPath, file)
+
+		switch {
+		case err == nil:
+		case started:
+			return nil, fmt.Errorf("failed to archive %s: %w", file.Path, err)
+		case errors.Is(err, fs.ErrNotExist):
+			e.recordVanished(file.Path)
+
+			skipped[relPath] = true
+		default:
+			e.errorHandler.AddError(ClassifySyncError("archive", file.Path, err))
+			atomic.AddInt64(&e.stats.ErrorsEncountered, 1)
+
+			skipped[relPath] = true
+		}
+
+		atomic.AddInt64(&e.progress.Current, 1)
+		e.updateProgress(file.Path)
+	}
+
+	if exists {
+		inSource := relativePaths(source, sourceFiles)
+
+		err := writer.keep(archivePath, func(name string) bool {
+			relPath, ok := archiveRelPath(name)
+			if !ok {
+				return true
+			}
+
+			if _, exists := inSource[relPath]; exists {
+				return skipped[relPath]
+			}
+
+			return !opts.DeleteExtraneous
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy entries from %s: %w", archivePath, err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish archive: %w", err)
+	}
+
+	if err := temp.Chmod(perm); err != nil {
+		return nil, fmt.Errorf("failed to set archive permissions: %w", err)
+	}
+
+	if err := temp.Sync(); err != nil {
+		return nil, fmt.Errorf("failed to flush archive: %w", err)
+	}
+
+	info, err := temp.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat archive: %w", err)
+	}
+
+	if err := temp.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close archive: %w", err)
+	}
+
+	if err := os.Rename(temp.Name(), toExtendedPath(archivePath)); err != nil {
+		return nil, fmt.Errorf("failed to replace %s: %w", archivePath, err)
+	}
+
+	committed = true
+
+	atomic.AddInt64(&e.stats.NetBytesChange, info.Size()-oldSize)
+
+	return skipped, nil
+}
+
+// addToArchive writes the entry for file, read afresh from disk. started
+// reports whether the entry was begun, after which a failure leaves the
+// archive unusable.
+func (e *SyncEngine) addToArchive(ctx context.Context, writer archiveWriter, relPath string, file *FileInfo) (started bool, err error) {
+	path := toExtendedPath(file.Path)
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		return false, err
+	}
+
+	var link string
+
+	switch {
+	case info.Mode()&fs.ModeSymlink != 0:
+		if link, err = os.Readlink(path); err != nil {
+			return false, err
+		}
+	case info.IsDir():
+	case !info.Mode().IsRegular():
+		// Devices, sockets and pipes have no archivable content.
+		return false, nil
+	}
+
+	var reader *os.File
+
+	if info.Mode().IsRegular() {
+		release, err := e.openFiles.acquire(ctx, 1)
+		if err != nil {
+			return false, err
+		}
+
+		defer release()
+
+		if reader, err = os.Open(path); err != nil {
+			return false, err
+		}
+
+		defer func() { _ = reader.Close() }()
+
+		// The entry's size must be the size of the content that follows.
+		if info, err = reader.Stat(); err != nil {
+			return false, err
+		}
+	}
+
+	content, err := writer.create(archiveName(relPath, info.IsDir()), info, link)
+	if err != nil {
+		return true, err
+	}
+
+	if reader == nil {
+		return true, nil
+	}
+
+	n, err := e.copier.bufferedCopy(ctx, io.LimitReader(reader, info.Size()), content)
+	if err != nil {
+		return true, err
+	}
+
+	if n < info.Size() {
+		return true, fmt.Errorf("file shrank to %d bytes while being archived: %w", n, io.ErrUnexpectedEOF)
+	}
+
+	atomic.AddInt64(&e.stats.BytesTransferred, n)
+
+	return true, nil
+}
+
+// archiveName returns the entry name of relPath: slash-separated, with a
+// trailing slash for a directory.
+func archiveName(relPath string, isDir bool) string {
+	name := filepath.ToSlash(relPath)
+	if isDir {
+		name += "/"
+	}
+
+	return name
+}
+
+// archiveRelPath returns the relative path of the entry name, and false for
+// names that are not a plain relative path, such as absolute ones or ones
+// containing "..", which are never compared with the source.
+func archiveRelPath(name string) (string, bool) {
+	name = strings.TrimSuffix(name, "/")
+	if name == "" || path.Clean(name) != name || !filepath.IsLocal(filepath.FromSlash(name)) {
+		return "", false
+	}
+
+	return filepath.FromSlash(name), true
+}
+
+// readArchiveIndex lists the entries of the archive at archivePath keyed by
+// relative path, each with its Path under archivePath. exists is false when
+// there is no archive yet, which lists as empty.
+func readArchiveIndex(archivePath string, format ArchiveFormat) (entries map[string]*FileInfo, exists bool, err error) {
+	if _, err := os.Stat(toExtendedPath(archivePath)); errors.Is(err, fs.ErrNotExist) {
+		return map[string]*FileInfo{}, false, nil
+	}
+
+	entries = make(map[string]*FileInfo)
+
+	add := func(name string, size int64, modTime time.Time, mode fs.FileMode) {
+		if relPath, ok := archiveRelPath(name); ok {
+			entries[relPath] = &FileInfo{
+				Path:    filepath.Join(archivePath, relPath),
+				Size:    size,
+				ModTime: modTime,
+				Mode:    uint32(mode),
+				IsDir:   mode.IsDir(),
+			}
+		}
+	}
+
+	if format == ArchiveZip {
+		err = walkZip(archivePath, func(file *zip.File) error {
+			add(file.Name, int64(file.UncompressedSize64), file.Modified, file.Mode())
+			return nil
+		})
+	} else {
+		err = walkTar(archivePath, format, func(header *tar.Header, _ io.Reader) error {
+			size := header.Size
+			if header.Typeflag == tar.TypeSymlink {
+				// Tar stores a link's target in the header; a symlink's own
+				// size, as scanned, is the length of its target.
+				size = int64(len(header.Linkname))
+			}
+
+			add(header.Name, size, header.ModTime, header.FileInfo().Mode())
+
+			return nil
+		})
+	}
+
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to read archive %s: %w", archivePath, err)
+	}
+
+	return entries, true, nil
+}
+
+// walkTar calls fn for each entry of the tar archive at archivePath, with a
+// reader for its content.
+func walkTar(archivePath string, format ArchiveFormat, fn func(header *tar.Header, content io.Reader) error) error {
+	file, err := os.Open(toExtendedPath(archivePath))
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = file.Close() }()
+
+	var reader io.Reader = file
+
+	if format == ArchiveTarGzip {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+
+		defer func() { _ = gz.Close() }()
+
+		reader = gz
+	}
+
+	tr := tar.NewReader(reader)
+
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err := fn(header, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// walkZip calls fn for each entry of the zip archive at archivePath.
+func walkZip(archivePath string, fn func(file *zip.File) error) error {
+	reader, err := zip.OpenReader(toExtendedPath(archivePath))
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = reader.Close() }()
+
+	for _, file := range reader.File {
+		if err := fn(file); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// archiveWriter writes the entries of one archive.
+type archiveWriter interface {
+	// create starts the entry name for a file described by info and returns
+	// the writer for its content, which only regular files have. link is
+	// the target of a symlink.
+	create(name string, info fs.FileInfo, link string) (io.Writer, error)
+	// keep copies, unchanged, the entries of the archive at path that keep
+	// reports true for.
+	keep(path string, keep func(name string) bool) error
+	Close() error
+}
+
+func newArchiveWriter(w io.Writer, format ArchiveFormat) archiveWriter {
+	switch format {
+	case ArchiveZip:
+		return &zipArchiveWriter{zw: zip.NewWriter(w)}
+	case ArchiveTarGzip:
+		gz := gzip.NewWriter(w)
+		return &tarArchiveWriter{tw: tar.NewWriter(gz), gz: gz, format: format}
+	default:
+		return &tarArchiveWriter{tw: tar.NewWriter(w), format: format}
+	}
+}
+
+type tarArchiveWriter struct {
+	tw     *tar.Writer
+	gz     *gzip.Writer // nil when uncompressed
+	format ArchiveFormat
+}
+
+func (w *tarArchiveWriter) create(name string, info fs.FileInfo, link string) (io.Writer, error) {
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return nil, err
+	}
+
+	header.Name = name
+	header.Format = tar.FormatPAX
+	// Access and change times would make every rewrite differ.
+	header.AccessTime, header.ChangeTime = time.Time{}, time.Time{}
+
+	if err := w.tw.WriteHeader(header); err != nil {
+		return nil, err
+	}
+
+	return w.tw, nil
+}
+
+func (w *tarArchiveWriter) keep(path string, keep func(name string) bool) error {
+	return walkTar(path, w.format, func(header *tar.Header, content io.Reader) error {
+		if !keep(header.Name) {
+			return nil
+		}
+
+		if err := w.tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		_, err := io.Copy(w.tw, content)
+
+		return err
+	})
+}
+
+func (w *tarArchiveWriter) Close() error {
+	if err := w.tw.Close(); err != nil {
+		return err
+	}
+
+	if w.gz != nil {
+		return w.gz.Close()
+	}
+
+	return nil
+}
+
+type zipArchiveWriter struct {
+	zw *zip.Writer
+}
+
+func (w *zipArchiveWriter) create(name string, info fs.FileInfo, link string) (io.Writer, error) {
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return nil, err
+	}
+
+	header.Name = name
+	if !info.IsDir() {
+		header.Method = zip.Deflate
+	}
+
+	content, err := w.zw.CreateHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	// Zip stores a symlink's target as its content.
+	if link != "" {
+		if _, err := io.WriteString(content, link); err != nil {
+			return nil, err
+		}
+	}
+
+	return content, nil
+}
+
+func (w *zipArchiveWriter) keep(path string, keep func(name string) bool) error {
+	return walkZip(path, func(file *zip.File) error {
+		if !keep(file.Name) {
+			return nil
+		}
+
+		// Copies the compressed data as is.
+		return w.zw.Copy(file)
+	})
+}
+
+func (w *zipArchiveWriter) Close() error {
+	return w.zw.Close()
+}
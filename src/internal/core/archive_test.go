@@ -0,0 +1,374 @@
+package core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"errors"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestArchiveFormatOf(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "backup.zip")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want ArchiveFormat
+	}{
+		{"backup.tar", ArchiveTar},
+		{"backup.tar.gz", ArchiveTarGzip},
+		{"BACKUP.TGZ", ArchiveTarGzip},
+		{"backup.zip", ArchiveZip},
+		{"backup", ArchiveNone},
+		{"backup.gz", ArchiveNone},
+		{dir, ArchiveNone},
+	}
+
+	for _, tt := range tests {
+		if got := ArchiveFormatOf(tt.path); got != tt.want {
+			t.Errorf("ArchiveFormatOf(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestSyncEngineSyncToArchive(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"backup.tar", "backup.tar.gz", "backup.zip"} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			tempDir := t.TempDir()
+
+			sourceDir := filepath.Join(tempDir, "source")
+			archivePath := filepath.Join(tempDir, "out", name)
+
+			modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+			writeTreeFile(t, filepath.Join(sourceDir, "a.txt"), "alpha", modTime)
+			writeTreeFile(t, filepath.Join(sourceDir, "docs", "b.txt"), "beta", modTime)
+			writeTreeFile(t, filepath.Join(sourceDir, "old.txt"), "stale", modTime)
+
+			if err := os.Symlink("a.txt", filepath.Join(sourceDir, "link")); err != nil {
+				t.Fatalf("Symlink failed: %v", err)
+			}
+
+			engine, err := NewSyncEngine()
+			if err != nil {
+				t.Fatalf("NewSyncEngine failed: %v", err)
+			}
+
+			opts := engine.Options()
+
+			stats := syncArchive(t, engine, sourceDir, archivePath, opts)
+			if stats.FilesCreated != 5 || stats.BytesTransferred != int64(len("alphabetastale")) {
+				t.Errorf("created %d entries, transferred %d bytes; want 5 and %d",
+					stats.FilesCreated, stats.BytesTransferred, len("alphabetastale"))
+			}
+
+			want := map[string]string{"a.txt": "alpha", "docs/": "", "docs/b.txt": "beta", "old.txt": "stale", "link": "-> a.txt"}
+			if got := readArchive(t, archivePath); !maps.Equal(got, want) {
+				t.Errorf("archive = %v, want %v", got, want)
+			}
+
+			// Nothing changed, so the archive is not written again.
+			before, err := os.Stat(archivePath)
+			if err != nil {
+				t.Fatalf("Stat failed: %v", err)
+			}
+
+			if stats := syncArchive(t, engine, sourceDir, archivePath, opts); stats.FilesChanged != 0 {
+				t.Errorf("unchanged source changed %d entries", stats.FilesChanged)
+			}
+
+			if after, err := os.Stat(archivePath); err != nil || !os.SameFile(before, after) {
+				t.Errorf("unchanged archive was rewritten (err %v)", err)
+			}
+
+			// Entries without a source are kept until DeleteExtraneous.
+			writeTreeFile(t, filepath.Join(sourceDir, "a.txt"), "ALPHA!", modTime)
+			removeTreePath(t, filepath.Join(sourceDir, "old.txt"))
+
+			if stats := syncArchive(t, engine, sourceDir, archivePath, opts); stats.FilesModified != 1 || stats.FilesDeleted != 0 {
+				t.Errorf("modified %d and deleted %d entries, want 1 and 0", stats.FilesModified, stats.FilesDeleted)
+			}
+
+			want["a.txt"] = "ALPHA!"
+			if got := readArchive(t, archivePath); !maps.Equal(got, want) {
+				t.Errorf("archive = %v, want %v", got, want)
+			}
+
+			opts.DeleteExtraneous = true
+
+			if stats := syncArchive(t, engine, sourceDir, archivePath, opts); stats.FilesDeleted != 1 {
+				t.Errorf("deleted %d entries, want 1", stats.FilesDeleted)
+			}
+
+			delete(want, "old.txt")
+			if got := readArchive(t, archivePath); !maps.Equal(got, want) {
+				t.Errorf("archive = %v, want %v", got, want)
+			}
+
+			entries, _, err := readArchiveIndex(archivePath, ArchiveFormatOf(archivePath))
+			if err != nil {
+				t.Fatalf("readArchiveIndex failed: %v", err)
+			}
+
+			if entry := entries["docs/b.txt"]; entry == nil || !entry.ModTime.Equal(modTime) || os.FileMode(entry.Mode).Perm() != 0o644 {
+				t.Errorf("docs/b.txt entry = %+v, want mode 0644 and time %v", entry, modTime)
+			}
+		})
+	}
+}
+
+func TestSyncEngineSyncToArchiveRejects(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	writeTreeFile(t, filepath.Join(sourceDir, "a.txt"), "alpha", time.Now())
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	opts := engine.Options()
+	opts.VerifyAfter = true
+
+	if _, err := engine.SyncToArchive(context.Background(), sourceDir, filepath.Join(tempDir, "out.zip"), opts); !errors.Is(err, ErrArchiveOption) {
+		t.Errorf("VerifyAfter error = %v, want ErrArchiveOption", err)
+	}
+
+	inside := filepath.Join(sourceDir, "self.tar")
+	if _, err := engine.SyncToArchive(context.Background(), sourceDir, inside, engine.Options()); err == nil {
+		t.Error("archiving into the source succeeded")
+	}
+
+	if _, err := os.Stat(inside); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("archive inside the source was written (err %v)", err)
+	}
+}
+
+func TestSyncEngineSyncFromArchive(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"backup.tar", "backup.tar.gz", "backup.zip"} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			tempDir := t.TempDir()
+
+			sourceDir := filepath.Join(tempDir, "source")
+			archivePath := filepath.Join(tempDir, name)
+			destDir := filepath.Join(tempDir, "dest")
+
+			modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+			writeTreeFile(t, filepath.Join(sourceDir, "a.txt"), "alpha", modTime)
+			writeTreeFile(t, filepath.Join(sourceDir, "docs", "b.txt"), "beta", modTime)
+
+			if err := os.Symlink("a.txt", filepath.Join(sourceDir, "link")); err != nil {
+				t.Fatalf("Symlink failed: %v", err)
+			}
+
+			engine, err := NewSyncEngine()
+			if err != nil {
+				t.Fatalf("NewSyncEngine failed: %v", err)
+			}
+
+			syncArchive(t, engine, sourceDir, archivePath, engine.Options())
+
+			opts := engine.Options()
+
+			if stats := syncFromArchive(t, engine, archivePath, destDir, opts); stats.FilesCreated != 4 {
+				t.Errorf("created %d files, want 4", stats.FilesCreated)
+			}
+
+			want := map[string]string{"a.txt": "alpha", "docs/": "", "docs/b.txt": "beta", "link": "alpha"}
+			if got := readTree(t, destDir); !maps.Equal(got, want) {
+				t.Errorf("destination = %v, want %v", got, want)
+			}
+
+			if target, err := os.Readlink(filepath.Join(destDir, "link")); err != nil || target != "a.txt" {
+				t.Errorf("link points to %q (err %v), want a.txt", target, err)
+			}
+
+			if info, err := os.Stat(filepath.Join(destDir, "docs", "b.txt")); err != nil || !info.ModTime().Equal(modTime) {
+				t.Errorf("docs/b.txt modification time = %v (err %v), want %v", info.ModTime(), err, modTime)
+			}
+
+			if stats := syncFromArchive(t, engine, archivePath, destDir, opts); stats.FilesCreated+stats.FilesModified != 0 {
+				t.Errorf("unchanged destination had %d files copied", stats.FilesCreated+stats.FilesModified)
+			}
+
+			// Changed and extra destination files are brought back to the archive.
+			writeTreeFile(t, filepath.Join(destDir, "docs", "b.txt"), "BETA", modTime.Add(-time.Hour))
+			writeTreeFile(t, filepath.Join(destDir, "extra.txt"), "extra", modTime)
+
+			opts.DeleteExtraneous = true
+
+			if stats := syncFromArchive(t, engine, archivePath, destDir, opts); stats.FilesModified != 1 || stats.FilesDeleted != 1 {
+				t.Errorf("modified %d and deleted %d files, want 1 and 1", stats.FilesModified, stats.FilesDeleted)
+			}
+
+			if got := readTree(t, destDir); !maps.Equal(got, want) {
+				t.Errorf("destination = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestSyncEngineSyncFromArchiveUnsafeEntries(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	archivePath := filepath.Join(tempDir, "evil.tar")
+	destDir := filepath.Join(tempDir, "dest")
+	outside := filepath.Join(tempDir, "outside")
+
+	if err := os.Mkdir(outside, 0o755); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+
+	file, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	tw := tar.NewWriter(file)
+	entries := []struct {
+		header  tar.Header
+		content string
+	}{
+		{tar.Header{Name: "../escape.txt", Mode: 0o644, Size: 4}, "evil"},
+		{tar.Header{Name: "dir", Typeflag: tar.TypeSymlink, Linkname: outside}, ""},
+		{tar.Header{Name: "dir/through.txt", Mode: 0o644, Size: 4}, "evil"},
+		{tar.Header{Name: "ok.txt", Mode: 0o644, Size: 2}, "ok"},
+	}
+
+	for _, entry := range entries {
+		if err := tw.WriteHeader(&entry.header); err != nil {
+			t.Fatalf("WriteHeader failed: %v", err)
+		}
+
+		if _, err := io.WriteString(tw, entry.content); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	if err := errors.Join(tw.Close(), file.Close()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	stats, err := engine.SyncFromArchive(context.Background(), archivePath, destDir, engine.Options())
+	if err != nil {
+		t.Fatalf("SyncFromArchive failed: %v", err)
+	}
+
+	if errs := engine.GetErrors(); stats.ErrorsEncountered == 0 || len(errs) != 2 {
+		t.Errorf("recorded errors %v, want one for ../escape.txt and one for dir/through.txt", errs)
+	}
+
+	if got := readTree(t, outside); len(got) != 0 {
+		t.Errorf("entries were written outside the destination: %v", got)
+	}
+
+	if _, err := os.Stat(filepath.Join(tempDir, "escape.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("../escape.txt was extracted (err %v)", err)
+	}
+
+	if content, err := os.ReadFile(filepath.Join(destDir, "ok.txt")); err != nil || string(content) != "ok" {
+		t.Errorf("ok.txt = %q (err %v), want ok", content, err)
+	}
+}
+
+func syncArchive(t *testing.T, engine *SyncEngine, source, archivePath string, opts SyncOptions) *SyncStats {
+	t.Helper()
+
+	stats, err := engine.SyncToArchive(context.Background(), source, archivePath, opts)
+	if err != nil {
+		t.Fatalf("SyncToArchive failed: %v", err)
+	}
+
+	if stats.ErrorsEncountered != 0 {
+		t.Fatalf("SyncToArchive encountered %d errors: %v", stats.ErrorsEncountered, engine.GetErrors())
+	}
+
+	return stats
+}
+
+// readArchive maps each entry name of an archive to its content, like
+// readTree. Symlinks are recorded as "-> target".
+func readArchive(t *testing.T, archivePath string) map[string]string {
+	t.Helper()
+
+	tree := make(map[string]string)
+	format := ArchiveFormatOf(archivePath)
+
+	var err error
+	if format == ArchiveZip {
+		err = walkZip(archivePath, func(file *zip.File) error {
+			reader, err := file.Open()
+			if err != nil {
+				return err
+			}
+
+			defer func() { _ = reader.Close() }()
+
+			content, err := io.ReadAll(reader)
+			if file.Mode()&os.ModeSymlink != 0 {
+				content = append([]byte("-> "), content...)
+			}
+
+			tree[file.Name] = string(content)
+
+			return err
+		})
+	} else {
+		err = walkTar(archivePath, format, func(header *tar.Header, reader io.Reader) error {
+			content, err := io.ReadAll(reader)
+			if header.Typeflag == tar.TypeSymlink {
+				content = []byte("-> " + header.Linkname)
+			}
+
+			tree[header.Name] = string(content)
+
+			return err
+		})
+	}
+
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", archivePath, err)
+	}
+
+	return tree
+}
+
+func syncFromArchive(t *testing.T, engine *SyncEngine, archivePath, dest string, opts SyncOptions) *SyncStats {
+	t.Helper()
+
+	stats, err := engine.SyncFromArchive(context.Background(), archivePath, dest, opts)
+	if err != nil {
+		t.Fatalf("SyncFromArchive failed: %v", err)
+	}
+
+	if stats.ErrorsEncountered != 0 {
+		t.Fatalf("SyncFromArchive encountered %d errors: %v", stats.ErrorsEncountered, engine.GetErrors())
+	}
+
+	return stats
+}
@@ -0,0 +1,500 @@
+package core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"cmp"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// errArchiveEscape is returned for an archive entry that would be written
+// through a symlink in the destination, which could place it outside.
+var errArchiveEscape = errors.New("refusing to write through a symlink in the destination")
+
+// SyncFromArchive mirrors the contents of the archive at archivePath, in the
+// format ArchiveFormatOf selects, into destination as Sync mirrors a
+// directory: entries are compared with the destination the same way, by
+// size, modification time and checksums computed from the archive, and only
+// the files that differ are written, with the usual conflict handling and
+// deletions. Entries are read in archive order by a single worker. Hard links
+// and special files in the archive are skipped, as are entries whose names are
+// not plain relative paths, which are reported as errors, and .relayignore
+// files are not read. An entry is never written through a symlink in the
+// destination.
+//
+// File lists, append-only copies, directory move detection and quarantine
+// need a source directory and are rejected with ErrArchiveOption.
+func (e *SyncEngine) SyncFromArchive(ctx context.Context, archivePath, destination string, opts SyncOptions) (*SyncStats, error) {
+	format := ArchiveFormatOf(archivePath)
+	if format == ArchiveNone {
+		return nil, fmt.Errorf("%s is not a .tar, .tar.gz, .tgz or .zip file", archivePath)
+	}
+
+	switch {
+	case opts.FileList != nil:
+		return nil, fmt.Errorf("file lists are %w", ErrArchiveOption)
+	case opts.AppendOnly:
+		return nil, fmt.Errorf("append-only copies are %w", ErrArchiveOption)
+	case opts.DetectMoves && opts.DeleteExtraneous:
+		return nil, fmt.Errorf("directory move detection is %w", ErrArchiveOption)
+	case opts.Quarantine:
+		return nil, fmt.Errorf("quarantine is %w", ErrArchiveOption)
+	}
+
+	// Deletions must never reach the archive being read.
+	if opts.DeleteExtraneous {
+		if inside, err := archiveWithin(destination, archivePath); err != nil || inside {
+			return nil, cmp.Or(err, fmt.Errorf("archive %s is inside the destination %s", archivePath, destination))
+		}
+	}
+
+	archive := &archiveSource{engine: e, path: archivePath, format: format, destination: destination}
+	defer archive.close()
+
+	return e.syncFrom(ctx, archivePath, destination, archive, opts)
+}
+
+// archiveSource is an archive read as the source of a run. Its entries are
+// listed by scan and then extracted in the same order; a tar archive is read
+// from the start again only when an entry before the current position is
+// wanted, such as on a retry.
+type archiveSource struct {
+	engine      *SyncEngine
+	path        string
+	format      ArchiveFormat
+	destination string
+
+	index   map[string]int      // relative path to position in the archive
+	present map[string]struct{} // every entry and the directories implied by them
+
+	mu   sync.Mutex
+	file *os.File
+	gz   *gzip.Reader
+	tr   *tar.Reader
+	next int // position of the next tar entry
+	zip  *zip.ReadCloser
+}
+
+// scan lists the entries of the archive that filter accepts, in archive
+// order, with checksums computed from their content. When a name appears more
+// than once, the last entry wins, as it would when extracting.
+func (a *archiveSource) scan(ctx context.Context, filter FilterFunc) ([]*FileInfo, error) {
+	a.index = make(map[string]int)
+	a.present = make(map[string]struct{})
+
+	entries := make(map[string]*FileInfo)
+
+	add := func(position int, name string, info fs.FileInfo, link string, content func() (io.ReadCloser, error)) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		relPath, ok := archiveRelPath(name)
+		if !ok {
+			a.engine.errorHandler.AddError(ClassifySyncError("scan", a.path,
+				fmt.Errorf("entry name %q is not a relative path", name)))
+			atomic.AddInt64(&a.engine.stats.ErrorsEncountered, 1)
+
+			return nil
+		}
+
+		for dir := relPath; dir != "."; dir = filepath.Dir(dir) {
+			a.present[dir] = struct{}{}
+		}
+
+		file := &FileInfo{
+			Path:    filepath.Join(a.path, relPath),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			Mode:    uint32(info.Mode()),
+			IsDir:   info.IsDir(),
+		}
+
+		if link != "" {
+			// A symlink's own size, as scanned on disk, is its target's length.
+			file.Size = int64(len(link))
+		}
+
+		delete(entries, relPath)
+
+		if !filter(file.Path, file) {
+			return nil
+		}
+
+		if err := a.engine.checksumEntry(file, content); err != nil {
+			return fmt.Errorf("failed to hash %s: %w", name, err)
+		}
+
+		a.index[relPath] = position
+		entries[relPath] = file
+
+		return nil
+	}
+
+	position := 0
+
+	var err error
+	if a.format == ArchiveZip {
+		err = walkZip(a.path, func(file *zip.File) error {
+			defer func() { position++ }()
+
+			if !archivable(file.Mode()) {
+				return nil
+			}
+
+			var link string
+
+			if file.Mode()&fs.ModeSymlink != 0 {
+				target, err := readZipLink(file)
+				if err != nil {
+					return err
+				}
+
+				link = target
+			}
+
+			return add(position, file.Name, file.FileInfo(), link, file.Open)
+		})
+	} else {
+		err = walkTar(a.path, a.format, func(header *tar.Header, content io.Reader) error {
+			defer func() { position++ }()
+
+			if header.Typeflag == tar.TypeLink || !archivable(header.FileInfo().Mode()) {
+				return nil
+			}
+
+			return add(position, header.Name, header.FileInfo(), header.Linkname, func() (io.ReadCloser, error) {
+				return io.NopCloser(content), nil
+			})
+		})
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive %s: %w", a.path, err)
+	}
+
+	files := slices.Collect(maps.Values(entries))
+	slices.SortFunc(files, func(x, y *FileInfo) int {
+		return a.position(x.Path) - a.position(y.Path)
+	})
+
+	return files, nil
+}
+
+// archivable reports whether an entry of mode can be extracted: a regular
+// file, a directory or a symlink.
+func archivable(mode fs.FileMode) bool {
+	return mode.IsRegular() || mode.IsDir() || mode&fs.ModeSymlink != 0
+}
+
+// readZipLink returns the target of a symlink stored in a zip archive.
+func readZipLink(file *zip.File) (string, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+
+	defer func() { _ = reader.Close() }()
+
+	target, err := io.ReadAll(io.LimitReader(reader, 4096))
+
+	return string(target), err
+}
+
+// checksumEntry fills in the digests of a regular archive entry, hashing its
+// content in full. Sampling needs to seek, which a compressed stream cannot,
+// so entries that would be sampled are left without a checksum and compare
+// by size and modification time, as do empty files.
+func (e *SyncEngine) checksumEntry(file *FileInfo, content func() (io.ReadCloser, error)) error {
+	if !fs.FileMode(file.Mode).IsRegular() || file.Size == 0 || e.scanner.sampled(file.Size) {
+		return nil
+	}
+
+	reader, err := content()
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = reader.Close() }()
+
+	checksum, secondary, err := e.scanner.calculateChecksum(reader)
+	if err != nil {
+		return err
+	}
+
+	file.Checksum = checksum
+	file.ChecksumAlgo = e.scanner.fileLabel(file.Size)
+
+	if e.scanner.secondaryAlgo != "" {
+		file.SecondaryChecksum = secondary
+		file.SecondaryChecksumAlgo = e.scanner.secondaryAlgo
+	}
+
+	return nil
+}
+
+// position returns the position in the archive of the entry at path.
+func (a *archiveSource) position(path string) int {
+	relPath, _ := filepath.Rel(a.path, path)
+	return a.index[relPath]
+}
+
+// lstat reports whether path names an entry of the archive, or a directory
+// one implies, whether or not the filter excluded it.
+func (a *archiveSource) lstat(path string) error {
+	relPath, err := filepath.Rel(a.path, path)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := a.present[relPath]; !ok {
+		return &fs.PathError{Op: "lstat", Path: path, Err: fs.ErrNotExist}
+	}
+
+	return nil
+}
+
+// extract writes the entry at src to dst.
+func (a *archiveSource) extract(ctx context.Context, copier *FileCopier, src, dst string) error {
+	relPath, err := filepath.Rel(a.path, src)
+	if err != nil {
+		return err
+	}
+
+	position, ok := a.index[relPath]
+	if !ok {
+		return fmt.Errorf("%s is not in the archive", relPath)
+	}
+
+	if err := a.checkParents(dst); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.format == ArchiveZip {
+		return a.extractZip(ctx, copier, position, dst)
+	}
+
+	if a.tr == nil || position < a.next {
+		if err := a.rewind(); err != nil {
+			return err
+		}
+	}
+
+	for {
+		header, err := a.tr.Next()
+		if err != nil {
+			return fmt.Errorf("failed to read archive %s: %w", a.path, err)
+		}
+
+		a.next++
+
+		if a.next-1 == position {
+			return copier.copyFromReader(ctx, a.tr, dst, header.FileInfo(), header.Linkname)
+		}
+	}
+}
+
+// extractZip writes the zip entry at position to dst.
+func (a *archiveSource) extractZip(ctx context.Context, copier *FileCopier, position int, dst string) error {
+	if a.zip == nil {
+		reader, err := zip.OpenReader(toExtendedPath(a.path))
+		if err != nil {
+			return err
+		}
+
+		a.zip = reader
+	}
+
+	file := a.zip.File[position]
+
+	var link string
+
+	if file.Mode()&fs.ModeSymlink != 0 {
+		var err error
+		if link, err = readZipLink(file); err != nil {
+			return err
+		}
+	}
+
+	content, err := file.Open()
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = content.Close() }()
+
+	return copier.copyFromReader(ctx, content, dst, file.FileInfo(), link)
+}
+
+// rewind starts reading a tar archive from its first entry.
+func (a *archiveSource) rewind() error {
+	a.closeTar()
+
+	file, err := os.Open(toExtendedPath(a.path))
+	if err != nil {
+		return err
+	}
+
+	var reader io.Reader = file
+
+	if a.format == ArchiveTarGzip {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			_ = file.Close()
+			return err
+		}
+
+		a.gz, reader = gz, gz
+	}
+
+	a.file, a.tr, a.next = file, tar.NewReader(reader), 0
+
+	return nil
+}
+
+// checkParents rejects dst when a directory between the destination and it
+// is a symlink.
+func (a *archiveSource) checkParents(dst string) error {
+	relPath, err := filepath.Rel(a.destination, dst)
+	if err != nil {
+		return err
+	}
+
+	for dir := filepath.Dir(relPath); dir != "."; dir = filepath.Dir(dir) {
+		info, err := os.Lstat(toExtendedPath(filepath.Join(a.destination, dir)))
+		if err == nil && info.Mode()&fs.ModeSymlink != 0 {
+			return fmt.Errorf("%w: %s", errArchiveEscape, filepath.Join(a.destination, dir))
+		}
+	}
+
+	return nil
+}
+
+func (a *archiveSource) closeTar() {
+	if a.gz != nil {
+		_ = a.gz.Close()
+	}
+
+	if a.file != nil {
+		_ = a.file.Close()
+	}
+
+	a.file, a.gz, a.tr = nil, nil, nil
+}
+
+func (a *archiveSource) close() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.closeTar()
+
+	if a.zip != nil {
+		_ = a.zip.Close()
+		a.zip = nil
+	}
+}
+
+// copySource copies the source file src to dst, from the archive when the
+// run reads from one.
+func (e *SyncEngine) copySource(ctx context.Context, src, dst string) error {
+	if e.fromArchive != nil {
+		return e.fromArchive.extract(ctx, e.copier, src, dst)
+	}
+
+	return e.copier.CopyFile(ctx, src, dst)
+}
+
+// lstatSource reports whether the source path exists, as an error wrapping
+// fs.ErrNotExist when it does not.
+func (e *SyncEngine) lstatSource(path string) error {
+	if e.fromArchive != nil {
+		return e.fromArchive.lstat(path)
+	}
+
+	_, err := os.Lstat(toExtendedPath(path))
+
+	return err
+}
+
+// copyFromReader writes content to dst as a file described by info, as
+// CopyFile does for a file on disk, or creates dst as a symlink to link.
+func (fc *FileCopier) copyFromReader(ctx context.Context, content io.Reader, dst string, info fs.FileInfo, link string) error {
+	dst = toExtendedPath(dst)
+
+	if err := fc.guard.check("copy to", dst); err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		return fc.copyDirectory(ctx, "", dst, info)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o750); err != nil {
+		return fmt.Errorf("failed to create destination directory: %w", err)
+	}
+
+	// Neither a new symlink nor the file's content may go through an old one.
+	if stat, err := os.Lstat(dst); err == nil && (stat.Mode()&fs.ModeSymlink != 0 || info.Mode()&fs.ModeSymlink != 0) {
+		if err := os.Remove(dst); err != nil {
+			return fmt.Errorf("failed to replace %s: %w", dst, err)
+		}
+	}
+
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if err := os.Symlink(link, dst); err != nil {
+			return fmt.Errorf("failed to create symlink %s: %w", dst, err)
+		}
+
+		return nil
+	}
+
+	release, err := fc.openFiles.acquire(ctx, 1)
+	if err != nil {
+		return fmt.Errorf("copy cancelled: %w", err)
+	}
+
+	defer release()
+
+	dstFile, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return fmt.Errorf("failed to create destination file %s: %w", dst, err)
+	}
+
+	bytesWritten, err := fc.bufferedCopy(ctx, io.LimitReader(content, info.Size()), dstFile)
+	if err == nil && bytesWritten != info.Size() {
+		err = fmt.Errorf("incomplete copy: expected %d bytes, wrote %d bytes", info.Size(), bytesWritten)
+	}
+
+	if err == nil {
+		err = dstFile.Sync()
+	}
+
+	if closeErr := dstFile.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		_ = os.Remove(dst)
+		return fmt.Errorf("failed to extract file content: %w", err)
+	}
+
+	return fc.applyMetadata(dst, info)
+}
@@ -0,0 +1,123 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrLiveDirNotSymlink is returned by MirrorAtomic when the destination
+// already exists as a regular directory, which cannot be swapped atomically.
+var ErrLiveDirNotSymlink = errors.New("atomic destination must be a symlink or not exist")
+
+// MirrorAtomic mirrors source into a fresh staging directory next to
+// destination and, only if every file copied cleanly, atomically repoints the
+// destination symlink at it. Readers of destination therefore see either the
+// previous complete version or the new one, never a partial sync. The
+// previous version is removed after the swap.
+func (e *SyncEngine) MirrorAtomic(ctx context.Context, source, destination string) error {
+	if err := checkDistinctPaths(source, destination); err != nil {
+		return err
+	}
+
+	previous, err := currentVersion(destination)
+	if err != nil {
+		return err
+	}
+
+	staging := versionPath(destination, time.Now())
+
+	stats, err := e.Sync(ctx, source, staging, e.options)
+	if err == nil && stats.ErrorsEncountered > 0 {
+		err = fmt.Errorf("%d errors during sync", stats.ErrorsEncountered)
+	}
+
+	if err != nil {
+		_ = os.RemoveAll(staging)
+		return fmt.Errorf("atomic sync aborted, %s left unchanged: %w", destination, err)
+	}
+
+	if err := e.guard.check("swap", destination); err != nil {
+		_ = os.RemoveAll(staging)
+		return err
+	}
+
+	if err := swapSymlink(destination, filepath.Base(staging)); err != nil {
+		_ = os.RemoveAll(staging)
+		return err
+	}
+
+	if previous != "" && isVersionOf(previous, destination) {
+		if err := os.RemoveAll(previous); err != nil {
+			return fmt.Errorf("failed to remove previous version %s: %w", previous, err)
+		}
+	}
+
+	return nil
+}
+
+// currentVersion returns the directory the destination symlink points at, or
+// "" when the destination does not exist yet.
+func currentVersion(destination string) (string, error) {
+	info, err := os.Lstat(destination)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("failed to stat %s: %w", destination, err)
+	}
+
+	if info.Mode()&os.ModeSymlink == 0 {
+		return "", fmt.Errorf("%w: %s", ErrLiveDirNotSymlink, destination)
+	}
+
+	target, err := os.Readlink(destination)
+	if err != nil {
+		return "", fmt.Errorf("failed to read symlink %s: %w", destination, err)
+	}
+
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(destination), target)
+	}
+
+	return target, nil
+}
+
+// versionPath returns a hidden sibling of destination for a new version.
+func versionPath(destination string, now time.Time) string {
+	base := filepath.Base(destination)
+	return filepath.Join(filepath.Dir(destination), fmt.Sprintf(".%s.relay-%d", base, now.UnixNano()))
+}
+
+// isVersionOf reports whether path is a version directory created by
+// MirrorAtomic for destination, so unrelated symlink targets are never removed.
+func isVersionOf(path, destination string) bool {
+	prefix := "." + filepath.Base(destination) + ".relay-"
+
+	return filepath.Dir(filepath.Clean(path)) == filepath.Dir(destination) &&
+		strings.HasPrefix(filepath.Base(path), prefix)
+}
+
+// swapSymlink points destination at target by renaming a temporary symlink
+// over it, which replaces the link atomically.
+func swapSymlink(destination, target string) error {
+	tempLink := destination + ".relay-link"
+	_ = os.Remove(tempLink)
+
+	if err := os.Symlink(target, tempLink); err != nil {
+		return fmt.Errorf("failed to create symlink: %w", err)
+	}
+
+	if err := os.Rename(tempLink, destination); err != nil {
+		_ = os.Remove(tempLink)
+		return fmt.Errorf("failed to swap %s: %w", destination, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,206 @@
+package core
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrAuditLogTampered is returned when an audit log's hash chain does not
+// verify, meaning an entry was changed, removed or reordered.
+var ErrAuditLogTampered = errors.New("audit log hash chain broken")
+
+// AuditEntry is one change made to a destination, as recorded in an audit
+// log. Each entry carries the hash of the one before it, so altering any
+// entry breaks the chain from there on.
+type AuditEntry struct {
+	Seq          int64      `json:"seq"`
+	Time         time.Time  `json:"time"`
+	Action       ChangeType `json:"action"`
+	Path         string     `json:"path"`           // absolute destination path
+	From         string     `json:"from,omitempty"` // the old path of a rename
+	OldChecksum  string     `json:"oldChecksum,omitempty"`
+	NewChecksum  string     `json:"newChecksum,omitempty"`
+	ChecksumAlgo string     `json:"checksumAlgo,omitempty"`
+	Prev         string     `json:"prev"` // hash of the previous entry; empty for the first
+	Hash         string     `json:"hash"` // SHA-256 of this entry encoded with Hash empty
+}
+
+// digest returns the hash of the entry with its Hash field left out.
+func (a AuditEntry) digest() (string, error) {
+	a.Hash = ""
+
+	data, err := json.Marshal(a)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// AuditLog appends hash-chained entries to a JSON Lines file. It is safe for
+// concurrent use, including by several engines at once.
+type AuditLog struct {
+	mu   sync.Mutex
+	file *os.File
+	seq  int64
+	last string
+	err  error
+}
+
+// OpenAuditLog opens the audit log at path for appending, creating it if
+// needed. An existing log must verify, so the new entries extend an intact
+// chain; it is never rewritten.
+func OpenAuditLog(path string) (*AuditLog, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	last, err := VerifyAuditLog(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("cannot append to %s: %w", path, err)
+	}
+
+	return &AuditLog{file: file, seq: last.Seq, last: last.Hash}, nil
+}
+
+// Record appends entry to the log, filling in its sequence number and
+// hashes. The first error writing the log is kept for Close, and nothing is
+// written after it.
+func (l *AuditLog) Record(entry AuditEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.err != nil {
+		return
+	}
+
+	entry.Seq = l.seq + 1
+	entry.Time = entry.Time.UTC()
+	entry.Prev = l.last
+
+	hash, err := entry.digest()
+	if err == nil {
+		entry.Hash = hash
+
+		var line []byte
+		if line, err = json.Marshal(entry); err == nil {
+			_, err = l.file.Write(append(line, '\n'))
+		}
+	}
+
+	if err != nil {
+		l.err = fmt.Errorf("failed to write audit log: %w", err)
+		return
+	}
+
+	l.seq = entry.Seq
+	l.last = entry.Hash
+}
+
+// Close flushes the log to disk and closes it, returning the first error
+// writing it.
+func (l *AuditLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	err := l.err
+	if syncErr := l.file.Sync(); err == nil && syncErr != nil {
+		err = fmt.Errorf("failed to flush audit log: %w", syncErr)
+	}
+
+	if closeErr := l.file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to close audit log: %w", closeErr)
+	}
+
+	return err
+}
+
+// VerifyAuditLog checks the hash chain of the audit log read from r and
+// returns its last entry, which is zero for an empty log. An error wrapping
+// ErrAuditLogTampered names the first entry that does not verify.
+func VerifyAuditLog(r io.Reader) (AuditEntry, error) {
+	var last AuditEntry
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return last, fmt.Errorf("%w: line %d is not an audit entry: %w", ErrAuditLogTampered, line, err)
+		}
+
+		hash, err := entry.digest()
+		if err != nil {
+			return last, err
+		}
+
+		switch {
+		case entry.Seq != last.Seq+1:
+			return last, fmt.Errorf("%w: line %d has sequence number %d, want %d", ErrAuditLogTampered, line, entry.Seq, last.Seq+1)
+		case entry.Prev != last.Hash:
+			return last, fmt.Errorf("%w: line %d does not follow the entry before it", ErrAuditLogTampered, line)
+		case entry.Hash != hash:
+			return last, fmt.Errorf("%w: line %d was modified", ErrAuditLogTampered, line)
+		}
+
+		last = entry
+	}
+
+	if err := scanner.Err(); err != nil {
+		return last, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	return last, nil
+}
+
+// SetAuditLog records every change runs make to the destination in log,
+// with the checksums of the file before and after; dry runs record nothing.
+// The caller closes log once the engine is done with it. A nil log turns
+// auditing off.
+func (e *SyncEngine) SetAuditLog(log *AuditLog) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.auditLog = log
+}
+
+// audit records a change to destPath in the audit log, if there is one.
+// before is the destination file as it was and after the source it now
+// matches; either is nil when the file did not exist on that side.
+func (e *SyncEngine) audit(action ChangeType, destPath, from string, before, after *FileInfo) {
+	e.mu.RLock()
+	log, dryRun := e.auditLog, e.stats.DryRun
+	e.mu.RUnlock()
+
+	if log == nil || dryRun {
+		return
+	}
+
+	entry := AuditEntry{Time: time.Now(), Action: action, Path: destPath, From: from}
+
+	if before != nil {
+		entry.OldChecksum, entry.ChecksumAlgo = before.Checksum, before.ChecksumAlgo
+	}
+
+	if after != nil {
+		entry.NewChecksum = after.Checksum
+		if after.ChecksumAlgo != "" {
+			entry.ChecksumAlgo = after.ChecksumAlgo
+		}
+	}
+
+	log.Record(entry)
+}
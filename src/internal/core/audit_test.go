@@ -0,0 +1,121 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyncEngineAuditLog(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	destDir := filepath.Join(tempDir, "dest")
+	logPath := filepath.Join(tempDir, "audit.jsonl")
+
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	writeTreeFile(t, filepath.Join(sourceDir, "new.txt"), "new", modTime)
+	writeTreeFile(t, filepath.Join(sourceDir, "changed.txt"), "after", modTime)
+	writeTreeFile(t, filepath.Join(destDir, "changed.txt"), "before", modTime.Add(-time.Hour))
+	writeTreeFile(t, filepath.Join(destDir, "stale.txt"), "stale", modTime)
+
+	run := func(dryRun bool) {
+		t.Helper()
+
+		log, err := OpenAuditLog(logPath)
+		if err != nil {
+			t.Fatalf("OpenAuditLog failed: %v", err)
+		}
+
+		engine, err := NewSyncEngine()
+		if err != nil {
+			t.Fatalf("NewSyncEngine failed: %v", err)
+		}
+
+		opts := engine.Options()
+		opts.DeleteExtraneous = true
+		opts.DryRun = dryRun
+		engine.SetOptions(opts)
+		engine.SetAuditLog(log)
+
+		mirrorTree(t, engine, sourceDir, destDir)
+
+		if err := log.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+	}
+
+	run(true)
+	run(false)
+
+	// A second run has nothing to change, but must extend the same chain.
+	writeTreeFile(t, filepath.Join(sourceDir, "later.txt"), "later", modTime)
+	run(false)
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+
+	last, err := VerifyAuditLog(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("VerifyAuditLog failed: %v", err)
+	}
+
+	checksum := func(content string) string {
+		sum, _, err := NewFileScanner(1).calculateChecksum(strings.NewReader(content))
+		if err != nil {
+			t.Fatalf("calculateChecksum failed: %v", err)
+		}
+
+		return sum
+	}
+
+	// Entries are written as workers finish, so compare them by path.
+	want := map[string]AuditEntry{
+		"new.txt":     {Action: ChangeCreate, NewChecksum: checksum("new")},
+		"changed.txt": {Action: ChangeModify, OldChecksum: checksum("before"), NewChecksum: checksum("after")},
+		"stale.txt":   {Action: ChangeDelete, OldChecksum: checksum("stale")},
+		"later.txt":   {Action: ChangeCreate, NewChecksum: checksum("later")},
+	}
+
+	if last.Seq != int64(len(want)) {
+		t.Fatalf("log has %d entries, want %d:\n%s", last.Seq, len(want), data)
+	}
+
+	for line := range strings.Lines(string(data)) {
+		var entry AuditEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+
+		rel, _ := filepath.Rel(destDir, entry.Path)
+		expected, ok := want[rel]
+
+		if !ok || entry.Action != expected.Action || entry.OldChecksum != expected.OldChecksum ||
+			entry.NewChecksum != expected.NewChecksum || entry.ChecksumAlgo != "blake3" {
+			t.Errorf("unexpected entry %+v", entry)
+		}
+	}
+
+	// Editing any entry breaks the chain.
+	tampered := bytes.Replace(data, []byte(`"action":"delete"`), []byte(`"action":"create"`), 1)
+	if _, err := VerifyAuditLog(bytes.NewReader(tampered)); !errors.Is(err, ErrAuditLogTampered) {
+		t.Errorf("VerifyAuditLog of an edited log = %v, want ErrAuditLogTampered", err)
+	}
+
+	if err := os.WriteFile(logPath, tampered, 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	if _, err := OpenAuditLog(logPath); !errors.Is(err, ErrAuditLogTampered) {
+		t.Errorf("OpenAuditLog of an edited log = %v, want ErrAuditLogTampered", err)
+	}
+}
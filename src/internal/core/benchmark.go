@@ -0,0 +1,298 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// benchmarkBlock is the size of the random block benchmark files repeat.
+const benchmarkBlock = 1 << 20
+
+// BufferBenchmark is how fast a file was written and read back through a
+// buffer of one size.
+type BufferBenchmark struct {
+	BufferSize int64   `json:"bufferSize"`
+	Write      float64 `json:"write"` // bytes per second, flushing to disk included
+	Read       float64 `json:"read"`  // bytes per second; often served from the page cache
+}
+
+// CopyBenchmark is how fast a file was copied by one method.
+type CopyBenchmark struct {
+	Method string  `json:"method"` // buffered, reflink, or the zero-copy system call
+	Rate   float64 `json:"rate"`   // bytes per second
+}
+
+// ChecksumBenchmark is how fast an algorithm hashed data in memory.
+type ChecksumBenchmark struct {
+	Algo string  `json:"algo"`
+	Rate float64 `json:"rate"` // bytes per second
+}
+
+// BenchmarkReport collects the measurements of relay benchmark.
+type BenchmarkReport struct {
+	Dir       string              `json:"dir"`
+	FileSize  int64               `json:"fileSize"`
+	Buffers   []BufferBenchmark   `json:"buffers"`
+	Copies    []CopyBenchmark     `json:"copies"`
+	Checksums []ChecksumBenchmark `json:"checksums"`
+}
+
+// BenchmarkAdvice is the performance settings a BenchmarkReport suggests.
+type BenchmarkAdvice struct {
+	BufferSize   int64  `json:"bufferSize"`
+	UseZeroCopy  bool   `json:"useZeroCopy"`
+	ChecksumAlgo string `json:"checksumAlgo"`
+}
+
+// advisedChecksums are the algorithms Recommend chooses between. md5 is
+// measured but never advised: it is not collision resistant.
+var advisedChecksums = []string{"blake3", "sha256"}
+
+// BenchmarkBuffers writes a file of size bytes in dir through a buffer of
+// each of bufferSizes, flushing it to disk, and reads it back through the
+// same buffer. The files are removed afterwards.
+func BenchmarkBuffers(ctx context.Context, dir string, size int64, bufferSizes []int64) ([]BufferBenchmark, error) {
+	results := make([]BufferBenchmark, 0, len(bufferSizes))
+
+	for _, bufferSize := range bufferSizes {
+		result := BufferBenchmark{BufferSize: bufferSize}
+
+		err := withBenchmarkFile(dir, func(file *os.File) error {
+			elapsed, err := timed(func() error { return writeBenchmarkData(ctx, file, size, bufferSize) })
+			if err != nil {
+				return err
+			}
+
+			result.Write = throughput(size, elapsed)
+
+			if _, err := file.Seek(0, io.SeekStart); err != nil {
+				return fmt.Errorf("failed to rewind benchmark file: %w", err)
+			}
+
+			elapsed, err = timed(func() error { return readBenchmarkData(ctx, file, bufferSize) })
+			if err != nil {
+				return err
+			}
+
+			result.Read = throughput(size, elapsed)
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// BenchmarkCopy copies a file of size bytes within dir the way the engine
+// does: through a buffer of bufferSize, and by reflink or zero-copy where the
+// file system and platform offer one. The files are removed afterwards.
+func BenchmarkCopy(ctx context.Context, dir string, size, bufferSize int64) ([]CopyBenchmark, error) {
+	support, err := ProbeCopySupport(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	methods := []string{"buffered"}
+
+	switch {
+	case support.Reflink:
+		methods = append(methods, "reflink")
+	case support.ZeroCopy != "":
+		methods = append(methods, support.ZeroCopy)
+	}
+
+	var results []CopyBenchmark
+
+	err = withBenchmarkFile(dir, func(src *os.File) error {
+		if err := writeBenchmarkData(ctx, src, size, benchmarkBlock); err != nil {
+			return err
+		}
+
+		for _, method := range methods {
+			copier := NewFileCopier(bufferSize, method != "buffered")
+			dst := src.Name() + ".copy"
+
+			elapsed, err := timed(func() error { return copier.CopyFile(ctx, src.Name(), dst) })
+			_ = os.Remove(dst)
+
+			if err != nil {
+				return fmt.Errorf("failed to copy benchmark file: %w", err)
+			}
+
+			results = append(results, CopyBenchmark{Method: method, Rate: throughput(size, elapsed)})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// withBenchmarkFile calls fn with a new empty temporary file in dir, which is
+// removed afterwards.
+func withBenchmarkFile(dir string, fn func(file *os.File) error) error {
+	file, err := os.CreateTemp(dir, ".relay-benchmark-*")
+	if err != nil {
+		return fmt.Errorf("failed to create benchmark file: %w", err)
+	}
+
+	defer func() {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+	}()
+
+	return fn(file)
+}
+
+// writeBenchmarkData writes size bytes of random data to file in writes of
+// bufferSize bytes and flushes them to disk.
+func writeBenchmarkData(ctx context.Context, file *os.File, size, bufferSize int64) error {
+	block := make([]byte, benchmarkBlock)
+	_, _ = rand.Read(block)
+
+	buffer := make([]byte, bufferSize)
+
+	for written := int64(0); written < size; {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		chunk := buffer[:min(bufferSize, size-written)]
+		for filled := 0; filled < len(chunk); {
+			filled += copy(chunk[filled:], block[(written+int64(filled))%benchmarkBlock:])
+		}
+
+		n, err := file.Write(chunk)
+		written += int64(n)
+
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", filepath.Base(file.Name()), err)
+		}
+	}
+
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to flush %s: %w", filepath.Base(file.Name()), err)
+	}
+
+	return nil
+}
+
+// readBenchmarkData reads file to the end in reads of bufferSize bytes.
+func readBenchmarkData(ctx context.Context, file *os.File, bufferSize int64) error {
+	buffer := make([]byte, bufferSize)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		_, err := file.Read(buffer)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", filepath.Base(file.Name()), err)
+		}
+	}
+}
+
+// timed returns how long fn took.
+func timed(fn func() error) (time.Duration, error) {
+	start := time.Now()
+	err := fn()
+
+	return time.Since(start), err
+}
+
+// throughput returns size bytes over elapsed in bytes per second.
+func throughput(size int64, elapsed time.Duration) float64 {
+	return float64(size) / max(elapsed.Seconds(), 1e-9)
+}
+
+// BenchmarkChecksums hashes size bytes in memory with every supported
+// algorithm.
+func BenchmarkChecksums(size int) []ChecksumBenchmark {
+	algorithms := ChecksumAlgorithms()
+	results := make([]ChecksumBenchmark, 0, len(algorithms))
+
+	for _, algo := range algorithms {
+		rate, err := ChecksumThroughput(algo, size)
+		if err != nil {
+			continue
+		}
+
+		results = append(results, ChecksumBenchmark{Algo: algo, Rate: rate})
+	}
+
+	return results
+}
+
+// Recommend suggests settings from the measurements in r: the smallest
+// buffer that writes and reads a file within a tenth of the fastest one,
+// zero-copy unless it is clearly slower than copying through a buffer, and
+// the faster of blake3 and sha256. Settings r has no measurements for keep
+// their defaults.
+func (r *BenchmarkReport) Recommend() BenchmarkAdvice {
+	advice := BenchmarkAdvice{BufferSize: 64 << 10, UseZeroCopy: true, ChecksumAlgo: "blake3"}
+
+	// Time to write and read back one byte, so that neither dominates.
+	cost := func(result BufferBenchmark) float64 {
+		return 1/max(result.Write, 1e-9) + 1/max(result.Read, 1e-9)
+	}
+
+	if len(r.Buffers) > 0 {
+		best := cost(r.Buffers[0])
+		for _, result := range r.Buffers[1:] {
+			best = min(best, cost(result))
+		}
+
+		advice.BufferSize = 0
+
+		for _, result := range r.Buffers {
+			if cost(result) <= best*1.1 && (advice.BufferSize == 0 || result.BufferSize < advice.BufferSize) {
+				advice.BufferSize = result.BufferSize
+			}
+		}
+	}
+
+	var buffered, fastest float64
+
+	for _, result := range r.Copies {
+		if result.Method == "buffered" {
+			buffered = result.Rate
+		} else {
+			fastest = max(fastest, result.Rate)
+		}
+	}
+
+	if buffered > 0 && fastest > 0 {
+		advice.UseZeroCopy = fastest >= buffered*0.9
+	}
+
+	var fastestHash float64
+
+	for _, result := range r.Checksums {
+		if slices.Contains(advisedChecksums, result.Algo) && result.Rate > fastestHash {
+			advice.ChecksumAlgo = result.Algo
+			fastestHash = result.Rate
+		}
+	}
+
+	return advice
+}
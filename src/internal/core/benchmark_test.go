@@ -0,0 +1,88 @@
+package core
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestBenchmarkReportRecommend(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		report BenchmarkReport
+		want   BenchmarkAdvice
+	}{
+		{
+			name:   "no measurements keep the defaults",
+			report: BenchmarkReport{},
+			want:   BenchmarkAdvice{BufferSize: 64 << 10, UseZeroCopy: true, ChecksumAlgo: "blake3"},
+		},
+		{
+			name: "smallest buffer close to the fastest",
+			report: BenchmarkReport{
+				Buffers: []BufferBenchmark{
+					{BufferSize: 4 << 10, Write: 100, Read: 100},
+					{BufferSize: 64 << 10, Write: 190, Read: 200},
+					{BufferSize: 1 << 20, Write: 200, Read: 200},
+				},
+			},
+			want: BenchmarkAdvice{BufferSize: 64 << 10, UseZeroCopy: true, ChecksumAlgo: "blake3"},
+		},
+		{
+			name: "slow zero-copy and fast sha256",
+			report: BenchmarkReport{
+				Copies: []CopyBenchmark{{Method: "buffered", Rate: 300}, {Method: "sendfile", Rate: 100}},
+				Checksums: []ChecksumBenchmark{
+					{Algo: "blake3", Rate: 100},
+					{Algo: "md5", Rate: 500},
+					{Algo: "sha256", Rate: 200},
+				},
+			},
+			want: BenchmarkAdvice{BufferSize: 64 << 10, UseZeroCopy: false, ChecksumAlgo: "sha256"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.report.Recommend(); got != tt.want {
+				t.Errorf("Recommend() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBenchmarkLeavesNoFiles(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	buffers, err := BenchmarkBuffers(context.Background(), dir, 256<<10, []int64{4 << 10, 64 << 10})
+	if err != nil {
+		t.Fatalf("BenchmarkBuffers failed: %v", err)
+	}
+
+	if len(buffers) != 2 || buffers[0].Write <= 0 || buffers[1].Read <= 0 {
+		t.Errorf("BenchmarkBuffers = %+v, want a write and read rate per buffer size", buffers)
+	}
+
+	copies, err := BenchmarkCopy(context.Background(), dir, 256<<10, 64<<10)
+	if err != nil {
+		t.Fatalf("BenchmarkCopy failed: %v", err)
+	}
+
+	if len(copies) == 0 || copies[0].Method != "buffered" || copies[0].Rate <= 0 {
+		t.Errorf("BenchmarkCopy = %+v, want a buffered copy first", copies)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+
+	if len(entries) != 0 {
+		t.Errorf("benchmark left %d files behind", len(entries))
+	}
+}
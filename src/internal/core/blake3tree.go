@@ -0,0 +1,321 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// blake3 exposes no way to hash part of the input on its own, so the tree
+// hash below is implemented here from the specification. Its digests are
+// identical to those of the blake3 package.
+
+const (
+	blake3ChunkLen = 1024
+	blake3BlockLen = 64
+
+	// blake3SegmentChunks is how many chunks a worker hashes at a time. A
+	// power of two, so each segment is a complete subtree of the hash tree.
+	blake3SegmentChunks = 1024
+	blake3SegmentLen    = blake3SegmentChunks * blake3ChunkLen
+)
+
+const (
+	blake3ChunkStart uint32 = 1 << iota
+	blake3ChunkEnd
+	blake3Parent
+	blake3Root
+	blake3KeyedHash
+)
+
+var blake3IV = [8]uint32{
+	0x6A09E667, 0xBB67AE85, 0x3C6EF372, 0xA54FF53A,
+	0x510E527F, 0x9B05688C, 0x1F83D9AB, 0x5BE0CD19,
+}
+
+// blake3Node is the input to one compression that has not been made yet,
+// kept so the last one can be made with the root flag.
+type blake3Node struct {
+	cv       [8]uint32
+	block    [16]uint32
+	counter  uint64
+	blockLen uint32
+	flags    uint32
+}
+
+// chainingValue compresses the node as an inner node of the tree.
+func (n *blake3Node) chainingValue() [8]uint32 {
+	out := blake3Compress(&n.cv, &n.block, n.counter, n.blockLen, n.flags)
+	return [8]uint32(out[:8])
+}
+
+// rootDigest compresses the node as the root and returns the 32-byte digest.
+func (n *blake3Node) rootDigest() []byte {
+	out := blake3Compress(&n.cv, &n.block, 0, n.blockLen, n.flags|blake3Root)
+
+	digest := make([]byte, 32)
+	for i, word := range out[:8] {
+		binary.LittleEndian.PutUint32(digest[4*i:], word)
+	}
+
+	return digest
+}
+
+// blake3Round mixes the state s with the message words m, first down the
+// columns and then along the diagonals. The state is kept in locals so it
+// stays in registers.
+func blake3Round(s, m *[16]uint32) {
+	v0, v1, v2, v3, v4, v5, v6, v7 := s[0], s[1], s[2], s[3], s[4], s[5], s[6], s[7]
+	v8, v9, v10, v11, v12, v13, v14, v15 := s[8], s[9], s[10], s[11], s[12], s[13], s[14], s[15]
+
+	v0 += v4 + m[0]
+	v12 = bits.RotateLeft32(v12^v0, -16)
+	v8 += v12
+	v4 = bits.RotateLeft32(v4^v8, -12)
+	v0 += v4 + m[1]
+	v12 = bits.RotateLeft32(v12^v0, -8)
+	v8 += v12
+	v4 = bits.RotateLeft32(v4^v8, -7)
+	v1 += v5 + m[2]
+	v13 = bits.RotateLeft32(v13^v1, -16)
+	v9 += v13
+	v5 = bits.RotateLeft32(v5^v9, -12)
+	v1 += v5 + m[3]
+	v13 = bits.RotateLeft32(v13^v1, -8)
+	v9 += v13
+	v5 = bits.RotateLeft32(v5^v9, -7)
+	v2 += v6 + m[4]
+	v14 = bits.RotateLeft32(v14^v2, -16)
+	v10 += v14
+	v6 = bits.RotateLeft32(v6^v10, -12)
+	v2 += v6 + m[5]
+	v14 = bits.RotateLeft32(v14^v2, -8)
+	v10 += v14
+	v6 = bits.RotateLeft32(v6^v10, -7)
+	v3 += v7 + m[6]
+	v15 = bits.RotateLeft32(v15^v3, -16)
+	v11 += v15
+	v7 = bits.RotateLeft32(v7^v11, -12)
+	v3 += v7 + m[7]
+	v15 = bits.RotateLeft32(v15^v3, -8)
+	v11 += v15
+	v7 = bits.RotateLeft32(v7^v11, -7)
+
+	v0 += v5 + m[8]
+	v15 = bits.RotateLeft32(v15^v0, -16)
+	v10 += v15
+	v5 = bits.RotateLeft32(v5^v10, -12)
+	v0 += v5 + m[9]
+	v15 = bits.RotateLeft32(v15^v0, -8)
+	v10 += v15
+	v5 = bits.RotateLeft32(v5^v10, -7)
+	v1 += v6 + m[10]
+	v12 = bits.RotateLeft32(v12^v1, -16)
+	v11 += v12
+	v6 = bits.RotateLeft32(v6^v11, -12)
+	v1 += v6 + m[11]
+	v12 = bits.RotateLeft32(v12^v1, -8)
+	v11 += v12
+	v6 = bits.RotateLeft32(v6^v11, -7)
+	v2 += v7 + m[12]
+	v13 = bits.RotateLeft32(v13^v2, -16)
+	v8 += v13
+	v7 = bits.RotateLeft32(v7^v8, -12)
+	v2 += v7 + m[13]
+	v13 = bits.RotateLeft32(v13^v2, -8)
+	v8 += v13
+	v7 = bits.RotateLeft32(v7^v8, -7)
+	v3 += v4 + m[14]
+	v14 = bits.RotateLeft32(v14^v3, -16)
+	v9 += v14
+	v4 = bits.RotateLeft32(v4^v9, -12)
+	v3 += v4 + m[15]
+	v14 = bits.RotateLeft32(v14^v3, -8)
+	v9 += v14
+	v4 = bits.RotateLeft32(v4^v9, -7)
+
+	s[0], s[1], s[2], s[3], s[4], s[5], s[6], s[7] = v0, v1, v2, v3, v4, v5, v6, v7
+	s[8], s[9], s[10], s[11], s[12], s[13], s[14], s[15] = v8, v9, v10, v11, v12, v13, v14, v15
+}
+
+func blake3Compress(cv *[8]uint32, block *[16]uint32, counter uint64, blockLen, flags uint32) [16]uint32 {
+	s := [16]uint32{
+		cv[0], cv[1], cv[2], cv[3], cv[4], cv[5], cv[6], cv[7],
+		blake3IV[0], blake3IV[1], blake3IV[2], blake3IV[3],
+		uint32(counter), uint32(counter >> 32), blockLen, flags,
+	}
+
+	m := *block
+	for round := range 7 {
+		if round > 0 {
+			m = [16]uint32{m[2], m[6], m[3], m[10], m[7], m[0], m[4], m[13], m[1], m[11], m[12], m[5], m[9], m[14], m[15], m[8]}
+		}
+
+		blake3Round(&s, &m)
+	}
+
+	for i := range 8 {
+		s[i] ^= s[i+8]
+		s[i+8] ^= cv[i]
+	}
+
+	return s
+}
+
+// blake3TreeHasher hashes one input as blake3, or keyed blake3.
+type blake3TreeHasher struct {
+	key   [8]uint32
+	flags uint32
+}
+
+func newBlake3TreeHasher(key []byte) *blake3TreeHasher {
+	if key == nil {
+		return &blake3TreeHasher{key: blake3IV}
+	}
+
+	h := &blake3TreeHasher{flags: blake3KeyedHash}
+	for i := range h.key {
+		h.key[i] = binary.LittleEndian.Uint32(key[4*i:])
+	}
+
+	return h
+}
+
+// chunkNode returns the last compression of the chunk data, which is at most
+// blake3ChunkLen bytes and is chunk number counter of the input.
+func (h *blake3TreeHasher) chunkNode(data []byte, counter uint64) blake3Node {
+	node := blake3Node{cv: h.key, counter: counter, flags: h.flags | blake3ChunkStart}
+
+	for {
+		n := min(len(data), blake3BlockLen)
+
+		var padded [blake3BlockLen]byte
+		copy(padded[:], data[:n])
+
+		for i := range node.block {
+			node.block[i] = binary.LittleEndian.Uint32(padded[4*i:])
+		}
+
+		node.blockLen = uint32(n)
+		data = data[n:]
+
+		if len(data) == 0 {
+			node.flags |= blake3ChunkEnd
+			return node
+		}
+
+		node.cv = node.chainingValue()
+		node.flags &^= blake3ChunkStart
+	}
+}
+
+// parentNode returns the compression joining two subtrees.
+func (h *blake3TreeHasher) parentNode(left, right *[8]uint32) blake3Node {
+	node := blake3Node{cv: h.key, blockLen: blake3BlockLen, flags: h.flags | blake3Parent}
+	copy(node.block[:8], left[:])
+	copy(node.block[8:], right[:])
+
+	return node
+}
+
+// subtreeCV returns the chaining value of the complete subtree made of the
+// chunks of data, a power of two of them, the first being chunk number
+// counter.
+func (h *blake3TreeHasher) subtreeCV(data []byte, counter uint64) [8]uint32 {
+	var stack cvStack
+
+	for i := 0; i < len(data); i += blake3ChunkLen {
+		node := h.chunkNode(data[i:i+blake3ChunkLen], counter+uint64(i/blake3ChunkLen))
+		stack.push(h, node.chainingValue(), uint64(i/blake3ChunkLen+1))
+	}
+
+	return stack.cvs[0]
+}
+
+// cvStack holds the chaining values of the complete subtrees not yet joined
+// into a larger one, largest first.
+type cvStack struct {
+	cvs [][8]uint32
+}
+
+// push adds the chaining value of the total'th subtree of a size, joining
+// each pair of equal subtrees it completes.
+func (s *cvStack) push(h *blake3TreeHasher, cv [8]uint32, total uint64) {
+	for ; total&1 == 0; total >>= 1 {
+		left := s.cvs[len(s.cvs)-1]
+		s.cvs = s.cvs[:len(s.cvs)-1]
+
+		parent := h.parentNode(&left, &cv)
+		cv = parent.chainingValue()
+	}
+
+	s.cvs = append(s.cvs, cv)
+}
+
+// sum returns the digest of an input whose remaining subtrees are on the
+// stack and whose last chunk compresses as last.
+func (s *cvStack) sum(h *blake3TreeHasher, last blake3Node) []byte {
+	for i := len(s.cvs) - 1; i >= 0; i-- {
+		cv := last.chainingValue()
+		last = h.parentNode(&s.cvs[i], &cv)
+	}
+
+	return last.rootDigest()
+}
+
+// hashParallel returns the digest of the size bytes of reader, hashing
+// segments of the input on up to workers goroutines at once. reader must
+// support concurrent reads, as *os.File does.
+func (h *blake3TreeHasher) hashParallel(reader io.ReaderAt, size int64, workers int) ([]byte, error) {
+	// The last segment holds the final chunk, which is only compressed once
+	// it is known to be the root or not, so it is hashed separately.
+	segments := max(size-1, 0) / blake3SegmentLen
+
+	tail := make([]byte, size-segments*blake3SegmentLen)
+	if n, err := reader.ReadAt(tail, segments*blake3SegmentLen); err != nil && (n < len(tail) || !errors.Is(err, io.EOF)) {
+		return nil, fmt.Errorf("failed to read final segment: %w", err)
+	}
+
+	cvs := make([][8]uint32, segments)
+
+	var group errgroup.Group
+	group.SetLimit(max(workers, 1))
+
+	for i := range segments {
+		group.Go(func() error {
+			buf := make([]byte, blake3SegmentLen)
+			if _, err := reader.ReadAt(buf, i*blake3SegmentLen); err != nil {
+				return fmt.Errorf("failed to read segment %d: %w", i, err)
+			}
+
+			cvs[i] = h.subtreeCV(buf, uint64(i)*blake3SegmentChunks)
+
+			return nil
+		})
+	}
+
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+
+	var stack cvStack
+	for i, cv := range cvs {
+		stack.push(h, cv, uint64(i+1))
+	}
+
+	// The tail is under blake3SegmentLen bytes past the last full segment,
+	// so its chunks only ever join each other on the stack.
+	counter := uint64(segments) * blake3SegmentChunks
+	chunks := max(len(tail)-1, 0) / blake3ChunkLen
+
+	for i := range chunks {
+		node := h.chunkNode(tail[i*blake3ChunkLen:(i+1)*blake3ChunkLen], counter+uint64(i))
+		stack.push(h, node.chainingValue(), uint64(i+1))
+	}
+
+	return stack.sum(h, h.chunkNode(tail[chunks*blake3ChunkLen:], counter+uint64(chunks))), nil
+}
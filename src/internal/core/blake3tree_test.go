@@ -0,0 +1,70 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/zeebo/blake3"
+)
+
+func TestBlake3TreeHasherMatchesBlake3(t *testing.T) {
+	t.Parallel()
+
+	key := bytes.Repeat([]byte{0x5a}, 32)
+
+	data := make([]byte, 4*blake3SegmentLen+blake3ChunkLen+7)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+
+	sizes := []int{
+		0, 1, blake3BlockLen, blake3ChunkLen - 1, blake3ChunkLen, blake3ChunkLen + 1,
+		3 * blake3ChunkLen, blake3SegmentLen - 1, blake3SegmentLen, blake3SegmentLen + 1,
+		3*blake3SegmentLen + 5000, 4 * blake3SegmentLen, len(data),
+	}
+
+	for _, size := range sizes {
+		for _, keyed := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%d-keyed-%t", size, keyed), func(t *testing.T) {
+				t.Parallel()
+
+				want := blake3.New()
+				hasher := newBlake3TreeHasher(nil)
+
+				if keyed {
+					var err error
+					if want, err = blake3.NewKeyed(key); err != nil {
+						t.Fatalf("NewKeyed failed: %v", err)
+					}
+
+					hasher = newBlake3TreeHasher(key)
+				}
+
+				_, _ = want.Write(data[:size])
+
+				got, err := hasher.hashParallel(bytes.NewReader(data[:size]), int64(size), 3)
+				if err != nil {
+					t.Fatalf("hashParallel failed: %v", err)
+				}
+
+				if !bytes.Equal(got, want.Sum(nil)) {
+					t.Errorf("digest = %s, want %s", hex.EncodeToString(got), hex.EncodeToString(want.Sum(nil)))
+				}
+			})
+		}
+	}
+}
+
+func TestBlake3TreeHasherShortRead(t *testing.T) {
+	t.Parallel()
+
+	data := make([]byte, 2*blake3SegmentLen)
+
+	for _, size := range []int64{int64(len(data)) + 10, 3 * blake3SegmentLen} {
+		if _, err := newBlake3TreeHasher(nil).hashParallel(bytes.NewReader(data), size, 2); err == nil {
+			t.Errorf("hashParallel of %d bytes from %d succeeded, want an error", size, len(data))
+		}
+	}
+}
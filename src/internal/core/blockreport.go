@@ -0,0 +1,123 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// blockReportMaxRanges is how many differing ranges a BlockMismatchError
+// names in its message; all of them are kept in Ranges.
+const blockReportMaxRanges = 8
+
+// ByteRange is the span of a file from Start up to, but not including, End.
+type ByteRange struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+// String formats the range as inclusive byte offsets, such as "0-65535".
+func (r ByteRange) String() string {
+	return fmt.Sprintf("%d-%d", r.Start, r.End-1)
+}
+
+// BlockMismatchError is a whole-file checksum mismatch found by VerifyAfter,
+// with the byte ranges where the destination differs from its source, found
+// by comparing the two block by block. Adjacent differing blocks are merged
+// into one range.
+type BlockMismatchError struct {
+	Err       error // the whole-file mismatch
+	BlockSize int64
+	Blocks    int64 // blocks compared
+	Differing int64 // blocks that differ
+	Ranges    []ByteRange
+}
+
+func (b *BlockMismatchError) Error() string {
+	if b.Differing == 0 {
+		return fmt.Sprintf("%v; all %d blocks of %d bytes now match, so the source or destination changed while it was verified",
+			b.Err, b.Blocks, b.BlockSize)
+	}
+
+	ranges := make([]string, 0, min(len(b.Ranges), blockReportMaxRanges))
+	for _, r := range b.Ranges[:cap(ranges)] {
+		ranges = append(ranges, r.String())
+	}
+
+	if more := len(b.Ranges) - len(ranges); more > 0 {
+		ranges = append(ranges, fmt.Sprintf("and %d more", more))
+	}
+
+	return fmt.Sprintf("%v; %d of %d blocks of %d bytes differ, at bytes %s",
+		b.Err, b.Differing, b.Blocks, b.BlockSize, strings.Join(ranges, ", "))
+}
+
+func (b *BlockMismatchError) Unwrap() error {
+	return b.Err
+}
+
+// reportBlocks returns mismatch, the whole-file mismatch of the destination
+// file read by dst, as a BlockMismatchError locating the damage when
+// ChecksumBlockReport is set. Without a source file on disk to compare with,
+// mismatch is returned as it is.
+func (e *SyncEngine) reportBlocks(ctx context.Context, source *FileInfo, dst io.ReaderAt, mismatch error) error {
+	if e.blockReport <= 0 || e.fromArchive != nil {
+		return mismatch
+	}
+
+	src, err := os.Open(toExtendedPath(source.Path))
+	if err != nil {
+		return fmt.Errorf("%w; cannot compare blocks: %w", mismatch, err)
+	}
+
+	defer func() { _ = src.Close() }()
+
+	report := &BlockMismatchError{Err: mismatch, BlockSize: e.blockReport}
+	if err := report.compare(ctx, src, dst, source.Size); err != nil {
+		return fmt.Errorf("%w; cannot compare blocks: %w", mismatch, err)
+	}
+
+	return report
+}
+
+// compare reads the first size bytes of src and dst a block at a time and
+// records the ranges where they differ.
+func (b *BlockMismatchError) compare(ctx context.Context, src, dst io.ReaderAt, size int64) error {
+	srcBlock := make([]byte, min(b.BlockSize, size))
+	dstBlock := make([]byte, len(srcBlock))
+
+	for offset := int64(0); offset < size; offset += b.BlockSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		n := min(b.BlockSize, size-offset)
+
+		if _, err := src.ReadAt(srcBlock[:n], offset); err != nil {
+			return fmt.Errorf("failed to read source block at %d: %w", offset, err)
+		}
+
+		if _, err := dst.ReadAt(dstBlock[:n], offset); err != nil {
+			return fmt.Errorf("failed to read destination block at %d: %w", offset, err)
+		}
+
+		b.Blocks++
+
+		if bytes.Equal(srcBlock[:n], dstBlock[:n]) {
+			continue
+		}
+
+		b.Differing++
+
+		if last := len(b.Ranges) - 1; last >= 0 && b.Ranges[last].End == offset {
+			b.Ranges[last].End = offset + n
+		} else {
+			b.Ranges = append(b.Ranges, ByteRange{Start: offset, End: offset + n})
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"unicode"
+)
+
+// CaseInsensitive reports whether the filesystem holding dir ignores case in
+// names. It looks dir up again under a case-swapped name, so nothing is
+// written. When dir's name has no letters to swap, it assumes the platform
+// default: case-insensitive on Windows and macOS.
+func CaseInsensitive(dir string) bool {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return platformCaseInsensitive()
+	}
+
+	name := filepath.Base(absDir)
+
+	swapped := strings.Map(swapCase, name)
+	if swapped == name {
+		return platformCaseInsensitive()
+	}
+
+	original, err := os.Stat(toExtendedPath(absDir))
+	if err != nil {
+		return platformCaseInsensitive()
+	}
+
+	other, err := os.Stat(toExtendedPath(filepath.Join(filepath.Dir(absDir), swapped)))
+	if err != nil {
+		return false
+	}
+
+	return os.SameFile(original, other)
+}
+
+func platformCaseInsensitive() bool {
+	return runtime.GOOS == "windows" || runtime.GOOS == "darwin"
+}
+
+func swapCase(r rune) rune {
+	if unicode.IsUpper(r) {
+		return unicode.ToLower(r)
+	}
+
+	return unicode.ToUpper(r)
+}
@@ -0,0 +1,411 @@
+package core
+
+import (
+	"bufio"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// checkpointVersion is bumped whenever the checkpoint format changes, so
+// checkpoints from another version start the mirror afresh rather than being
+// misread.
+const checkpointVersion = 1
+
+// checkpointFlushEvery is how many completed files are buffered before they
+// are written to the checkpoint, bounding the work lost to a crash.
+const checkpointFlushEvery = 256
+
+// Checkpoint describes a mirror that stopped before it finished. Its file
+// holds this header followed by one line per file the mirror found or made
+// identical at the destination.
+type Checkpoint struct {
+	Version     int       `json:"version"`
+	Source      string    `json:"source"`
+	Destination string    `json:"destination"`
+	Checksum    string    `json:"checksum"` // configuration the recorded digests were made with
+	Dir         string    `json:"dir"`      // working directory of Command
+	Command     []string  `json:"command"`  // relay arguments that run the mirror again
+	Started     time.Time `json:"started"`  // when the first of its runs started
+
+	Path      string    `json:"-"` // the checkpoint file
+	Completed int       `json:"-"` // files recorded as done
+	Updated   time.Time `json:"-"` // when a file was last recorded
+}
+
+// checkpointEntry is a file a checkpointed mirror left identical in source
+// and destination.
+type checkpointEntry struct {
+	Path        string    `json:"path"` // relative to Source and Destination
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"modTime"`
+	DestModTime time.Time `json:"destModTime"`
+	Checksum    string    `json:"checksum,omitempty"`
+	Secondary   string    `json:"secondary,omitempty"`
+}
+
+// checkpointWriter records completed files to a checkpoint file.
+type checkpointWriter struct {
+	mu          sync.Mutex
+	path        string
+	file        *os.File
+	writer      *bufio.Writer
+	destination string
+	seeded      map[string]checkpointEntry // files carried over from the previous run
+	recorded    int                        // files in the checkpoint, carried over or not
+	pending     int
+	err         error
+}
+
+// CheckpointDir returns the directory checkpoints are kept in, in the user
+// cache directory.
+func CheckpointDir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+
+	return filepath.Join(cacheDir, "relay", "checkpoints"), nil
+}
+
+// DefaultCheckpointPath returns where the checkpoint of mirrors from source
+// to destination is kept: a file in CheckpointDir named after both absolute
+// paths.
+func DefaultCheckpointPath(source, destination string) (string, error) {
+	absSource, err := filepath.Abs(source)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", source, err)
+	}
+
+	absDest, err := filepath.Abs(destination)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", destination, err)
+	}
+
+	dir, err := CheckpointDir()
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(absSource + "\x00" + absDest))
+
+	return filepath.Join(dir, hex.EncodeToString(sum[:8])+".jsonl"), nil
+}
+
+// ReadCheckpoint returns the checkpoint at path, or nil when there is none
+// or it was written by another version of relay.
+func ReadCheckpoint(path string) (*Checkpoint, error) {
+	checkpoint, entries, err := readCheckpoint(path)
+	if checkpoint == nil || err != nil {
+		return nil, err
+	}
+
+	completed := make(map[string]struct{}, len(entries))
+	for _, entry := range entries {
+		completed[entry.Path] = struct{}{}
+	}
+
+	checkpoint.Completed = len(completed)
+
+	return checkpoint, nil
+}
+
+// ListCheckpoints returns the checkpoints in dir, most recently updated
+// first.
+func ListCheckpoints(dir string) ([]*Checkpoint, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to list checkpoints: %w", err)
+	}
+
+	var checkpoints []*Checkpoint
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".jsonl" {
+			continue
+		}
+
+		checkpoint, err := ReadCheckpoint(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		if checkpoint != nil {
+			checkpoints = append(checkpoints, checkpoint)
+		}
+	}
+
+	slices.SortFunc(checkpoints, func(a, b *Checkpoint) int {
+		return cmp.Or(b.Updated.Compare(a.Updated), strings.Compare(a.Path, b.Path))
+	})
+
+	return checkpoints, nil
+}
+
+// readCheckpoint returns the header and entries of the checkpoint at path.
+// A missing file, or one in another format, has no checkpoint. Entries that
+// cannot be parsed, such as one cut short by a crash, are skipped.
+func readCheckpoint(path string) (*Checkpoint, []checkpointEntry, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, nil
+	}
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open checkpoint: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to stat checkpoint: %w", err)
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var checkpoint Checkpoint
+	if !scanner.Scan() || json.Unmarshal(scanner.Bytes(), &checkpoint) != nil || checkpoint.Version != checkpointVersion {
+		return nil, nil, scanner.Err()
+	}
+
+	checkpoint.Path = path
+	checkpoint.Updated = info.ModTime()
+
+	var entries []checkpointEntry
+
+	for scanner.Scan() {
+		var entry checkpointEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || entry.Path == "" {
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	return &checkpoint, entries, nil
+}
+
+// SetCheckpoint makes mirrors of a whole source directory record each file
+// they find or make identical at the destination in a checkpoint at path,
+// which is removed once a mirror completes without errors. A mirror that
+// finds a checkpoint left by an interrupted mirror between the same source
+// and destination does not hash the files it records again while their
+// sizes and modification times are unchanged. dir and command are the
+// working directory and relay arguments that run the mirror again, for relay
+// resume. An empty path disables checkpoints.
+func (e *SyncEngine) SetCheckpoint(path, dir string, command []string) {
+	e.checkpointPath = path
+	e.checkpointHeader = Checkpoint{Dir: dir, Command: command}
+}
+
+// openCheckpoint starts the checkpoint of a mirror from source to
+// destination, carrying over the files completed by the interrupted mirror
+// it resumes. The scanner is told their digests, so that they are not read
+// again while unchanged.
+func (e *SyncEngine) openCheckpoint(source, destination string) error {
+	if e.checkpointPath == "" {
+		return nil
+	}
+
+	header := e.checkpointHeader
+	header.Version = checkpointVersion
+	header.Checksum = e.snapshotLabel()
+	header.Started = time.Now()
+
+	var err error
+
+	if header.Source, err = filepath.Abs(source); err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", source, err)
+	}
+
+	if header.Destination, err = filepath.Abs(destination); err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", destination, err)
+	}
+
+	previous, entries, err := readCheckpoint(e.checkpointPath)
+	if err != nil {
+		return err
+	}
+
+	if previous == nil || previous.Source != header.Source || previous.Destination != header.Destination ||
+		previous.Checksum != header.Checksum {
+		entries = nil
+	} else {
+		header.Started = previous.Started
+	}
+
+	w := &checkpointWriter{path: e.checkpointPath, destination: destination, seeded: make(map[string]checkpointEntry, len(entries))}
+
+	for _, entry := range entries {
+		if entry.Checksum != "" {
+			e.scanner.seedChecksum(filepath.Join(source, entry.Path), entry.Size, entry.ModTime, entry.Checksum, entry.Secondary)
+			e.scanner.seedChecksum(filepath.Join(destination, entry.Path), entry.Size, entry.DestModTime, entry.Checksum, entry.Secondary)
+		}
+
+		w.seeded[entry.Path] = entry
+	}
+
+	w.recorded = len(entries)
+
+	if err := w.rewrite(&header, entries); err != nil {
+		return err
+	}
+
+	e.checkpoint = w
+
+	return nil
+}
+
+// rewrite replaces the checkpoint file with header and entries, then opens it
+// for appending.
+func (w *checkpointWriter) rewrite(header *Checkpoint, entries []checkpointEntry) error {
+	if err := os.MkdirAll(filepath.Dir(w.path), 0o750); err != nil {
+		return fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(w.path), filepath.Base(w.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary checkpoint: %w", err)
+	}
+
+	tempPath := tempFile.Name()
+	writer := bufio.NewWriter(tempFile)
+	encoder := json.NewEncoder(writer)
+
+	err = encoder.Encode(header)
+	for i := 0; err == nil && i < len(entries); i++ {
+		err = encoder.Encode(&entries[i])
+	}
+
+	if err == nil {
+		err = writer.Flush()
+	}
+
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+
+	if err := os.Rename(tempPath, w.path); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to replace checkpoint: %w", err)
+	}
+
+	w.file, err = os.OpenFile(w.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open checkpoint: %w", err)
+	}
+
+	w.writer = bufio.NewWriter(w.file)
+
+	return nil
+}
+
+// recordCheckpoint notes in the checkpoint that the destination copy of file,
+// at relPath, matches it.
+func (e *SyncEngine) recordCheckpoint(relPath string, file *FileInfo) {
+	if e.checkpoint == nil || file.IsDir {
+		return
+	}
+
+	e.checkpoint.record(relPath, file)
+}
+
+func (w *checkpointWriter) record(relPath string, file *FileInfo) {
+	if seeded, ok := w.seeded[relPath]; ok && seeded.Size == file.Size && seeded.ModTime.Equal(file.ModTime) {
+		return
+	}
+
+	// The destination's own time is kept, which differs from the source's
+	// when times are not preserved.
+	destInfo, err := os.Lstat(toExtendedPath(filepath.Join(w.destination, relPath)))
+	if err != nil {
+		return
+	}
+
+	data, err := json.Marshal(checkpointEntry{
+		Path:        relPath,
+		Size:        file.Size,
+		ModTime:     file.ModTime,
+		DestModTime: destInfo.ModTime(),
+		Checksum:    file.Checksum,
+		Secondary:   file.SecondaryChecksum,
+	})
+	if err != nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.err != nil {
+		return
+	}
+
+	if _, err := w.writer.Write(append(data, '\n')); err != nil {
+		w.err = err
+		return
+	}
+
+	w.recorded++
+	w.pending++
+
+	if w.pending >= checkpointFlushEvery {
+		w.pending = 0
+		w.err = w.writer.Flush()
+	}
+}
+
+// closeCheckpoint ends the checkpoint of the current mirror, removing it when
+// the mirror is complete or recorded nothing, and keeping it for the next
+// mirror otherwise. A failure to write it is recorded as an error of the run.
+func (e *SyncEngine) closeCheckpoint(complete bool) {
+	w := e.checkpoint
+	if w == nil {
+		return
+	}
+
+	e.checkpoint = nil
+
+	err := w.err
+	if flushErr := w.writer.Flush(); err == nil {
+		err = flushErr
+	}
+
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if (complete || w.recorded == 0) && err == nil {
+		err = os.Remove(w.path)
+	}
+
+	if err != nil {
+		e.errorHandler.AddError(ClassifySyncError("checkpoint", w.path, err))
+		atomic.AddInt64(&e.stats.ErrorsEncountered, 1)
+	}
+}
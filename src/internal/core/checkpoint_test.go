@@ -0,0 +1,193 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSyncEngineCheckpoint(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	destDir := filepath.Join(tempDir, "dest")
+	checkpointPath := filepath.Join(tempDir, "checkpoints", "mirror.jsonl")
+
+	files := map[string]string{
+		filepath.Join(sourceDir, "a.txt"):            "alpha",
+		filepath.Join(sourceDir, "b.txt"):            "bravo",
+		filepath.Join(sourceDir, "blocked", "c.txt"): "charlie",
+		// A file where the source has a directory stops the mirror completing.
+		filepath.Join(destDir, "blocked"): "in the way",
+	}
+
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	mirror := func() *SyncEngine {
+		t.Helper()
+
+		engine, err := NewSyncEngine()
+		if err != nil {
+			t.Fatalf("NewSyncEngine failed: %v", err)
+		}
+
+		engine.SetCheckpoint(checkpointPath, tempDir, []string{"mirror", "source", "dest"})
+
+		_, _ = engine.Sync(context.Background(), sourceDir, destDir, engine.Options())
+
+		return engine
+	}
+
+	if engine := mirror(); engine.GetStats().ErrorsEncountered == 0 {
+		t.Fatal("first mirror succeeded, want it to fail on the blocked directory")
+	}
+
+	checkpoint, err := ReadCheckpoint(checkpointPath)
+	if err != nil || checkpoint == nil {
+		t.Fatalf("ReadCheckpoint = %v, %v; want the checkpoint of the failed mirror", checkpoint, err)
+	}
+
+	if checkpoint.Completed != 2 || checkpoint.Source != sourceDir || checkpoint.Destination != destDir ||
+		checkpoint.Dir != tempDir || len(checkpoint.Command) != 3 {
+		t.Errorf("checkpoint = %+v, want a.txt and b.txt completed and the command recorded", checkpoint)
+	}
+
+	// Change b.txt without changing its size or time: a resumed mirror trusts
+	// the checkpoint instead of hashing it again, so leaves it alone.
+	bPath := filepath.Join(sourceDir, "b.txt")
+
+	info, err := os.Stat(bPath)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+
+	if err := os.WriteFile(bPath, []byte("BRAVO"), 0o644); err != nil {
+		t.Fatalf("Failed to rewrite b.txt: %v", err)
+	}
+
+	if err := os.Chtimes(bPath, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+
+	if err := os.Remove(filepath.Join(destDir, "blocked")); err != nil {
+		t.Fatalf("Failed to unblock: %v", err)
+	}
+
+	engine := mirror()
+	if stats := engine.GetStats(); stats.ErrorsEncountered != 0 || stats.FilesChanged != 1 {
+		t.Errorf("resumed mirror: %d errors, %d files changed; want 0 errors and only c.txt copied",
+			stats.ErrorsEncountered, stats.FilesChanged)
+	}
+
+	if content, err := os.ReadFile(filepath.Join(destDir, "b.txt")); err != nil || string(content) != "bravo" {
+		t.Errorf("dest b.txt = %q, %v; want it left as checkpointed", content, err)
+	}
+
+	if _, err := os.Stat(checkpointPath); !os.IsNotExist(err) {
+		t.Errorf("checkpoint still exists after a complete mirror (err %v)", err)
+	}
+}
+
+func TestCheckpointOfOtherMirrorIgnored(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	checkpointPath := filepath.Join(tempDir, "mirror.jsonl")
+
+	if err := os.MkdirAll(sourceDir, 0o755); err != nil {
+		t.Fatalf("Failed to create source: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(sourceDir, "a.txt"), []byte("alpha"), 0o644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	stale := `{"version":1,"source":"/elsewhere","destination":"/other","checksum":"blake3","started":"2026-01-01T00:00:00Z"}` + "\n" +
+		`{"path":"a.txt","size":5,"modTime":"2026-01-01T00:00:00Z","destModTime":"2026-01-01T00:00:00Z","checksum":"00"}` + "\n"
+
+	if err := os.WriteFile(checkpointPath, []byte(stale), 0o600); err != nil {
+		t.Fatalf("Failed to write checkpoint: %v", err)
+	}
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	engine.SetCheckpoint(checkpointPath, tempDir, nil)
+
+	if err := engine.openCheckpoint(sourceDir, filepath.Join(tempDir, "dest")); err != nil {
+		t.Fatalf("openCheckpoint failed: %v", err)
+	}
+
+	checkpoint, err := ReadCheckpoint(checkpointPath)
+	if err != nil || checkpoint == nil {
+		t.Fatalf("ReadCheckpoint = %v, %v; want the new checkpoint", checkpoint, err)
+	}
+
+	if checkpoint.Completed != 0 || checkpoint.Source != sourceDir || checkpoint.Started.Before(time.Now().Add(-time.Minute)) {
+		t.Errorf("checkpoint = %+v, want a fresh one for this mirror", checkpoint)
+	}
+
+	// A mirror stopped before completing any file leaves nothing to resume.
+	engine.closeCheckpoint(false)
+
+	if _, err := os.Stat(checkpointPath); !os.IsNotExist(err) {
+		t.Errorf("empty checkpoint kept (err %v), want it removed", err)
+	}
+}
+
+func TestListCheckpoints(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	if checkpoints, err := ListCheckpoints(filepath.Join(dir, "missing")); err != nil || checkpoints != nil {
+		t.Errorf("ListCheckpoints(missing) = %v, %v; want nil, nil", checkpoints, err)
+	}
+
+	header := `{"version":1,"source":"/src","destination":"/dst/%s"}` + "\n"
+	now := time.Now()
+
+	for i, name := range []string{"old", "new"} {
+		path := filepath.Join(dir, name+".jsonl")
+
+		if err := os.WriteFile(path, []byte(fmt.Sprintf(header, name)+`{"path":"x"}`+"\n"+`{"path":"x"}`+"\n"+`{"pa`), 0o600); err != nil {
+			t.Fatalf("Failed to write checkpoint: %v", err)
+		}
+
+		modTime := now.Add(time.Duration(i-1) * time.Hour)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("Chtimes failed: %v", err)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "future.jsonl"), []byte(`{"version":99}`+"\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write checkpoint: %v", err)
+	}
+
+	checkpoints, err := ListCheckpoints(dir)
+	if err != nil {
+		t.Fatalf("ListCheckpoints failed: %v", err)
+	}
+
+	if len(checkpoints) != 2 || checkpoints[0].Destination != "/dst/new" || checkpoints[1].Destination != "/dst/old" {
+		t.Fatalf("ListCheckpoints = %+v, want new then old, without the other version's", checkpoints)
+	}
+
+	if checkpoints[0].Completed != 1 {
+		t.Errorf("Completed = %d, want 1 for a file recorded twice and a cut-off line", checkpoints[0].Completed)
+	}
+}
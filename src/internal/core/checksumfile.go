@@ -0,0 +1,192 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// ChecksumFileMode selects whether checksum files are written alongside the
+// mirrored files, so the destination can be verified later without relay.
+type ChecksumFileMode int
+
+// Checksum file modes
+const (
+	ChecksumFilesOff      ChecksumFileMode = iota
+	ChecksumFilesSidecar                   // <file>.b3sum next to each file
+	ChecksumFilesManifest                  // one B3SUMS per directory
+)
+
+// checksumFileNames maps each algorithm label to its sidecar extension and
+// manifest name, following the b3sum, md5sum and sha256sum conventions.
+var checksumFileNames = map[string]struct{ sidecarExt, manifest string }{
+	"blake3": {".b3sum", "B3SUMS"},
+	"md5":    {".md5", "MD5SUMS"},
+	"sha256": {".sha256", "SHA256SUMS"},
+}
+
+// ParseChecksumFileMode parses a --write-checksums value.
+func ParseChecksumFileMode(value string) (ChecksumFileMode, error) {
+	switch strings.ToLower(value) {
+	case "", "off", "none":
+		return ChecksumFilesOff, nil
+	case "sidecar":
+		return ChecksumFilesSidecar, nil
+	case "manifest":
+		return ChecksumFilesManifest, nil
+	default:
+		return ChecksumFilesOff, fmt.Errorf("invalid checksum file mode %q: expected sidecar or manifest", value)
+	}
+}
+
+// SetChecksumFiles makes each run write checksum files in the destination in
+// the format of b3sum or sha256sum ("<digest>  <name>"), so `b3sum -c` can
+// verify the archive. Files are listed once they match their source: copied
+// this run or already up to date. Checksum files are rewritten only when their
+// content changes and are kept by DeleteExtraneous. Keyed digests cannot be
+// checked by those tools, nor can sampled ones, so the mode is rejected while
+// a checksum seed or sample size is set.
+func (e *SyncEngine) SetChecksumFiles(mode ChecksumFileMode) error {
+	if mode != ChecksumFilesOff {
+		if _, ok := checksumFileNames[e.scanner.checksumLabel()]; !ok {
+			return fmt.Errorf("checksum files are not supported with %s checksums", e.scanner.checksumLabel())
+		}
+
+		if e.scanner.sampleSize > 0 {
+			return errors.New("checksum files are not supported with sampled checksums")
+		}
+	}
+
+	e.checksumMode = mode
+
+	return nil
+}
+
+// recordVerified notes that the destination copy of file matches it after
+// this run, so it is listed in the checksum files and rechecked by
+// VerifyAfter.
+func (e *SyncEngine) recordVerified(relPath string, file *FileInfo) {
+	e.recordCheckpoint(relPath, file)
+
+	if (e.checksumMode == ChecksumFilesOff && !e.verifyAfter) || file.IsDir {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.verified[relPath] = file
+}
+
+// checksumFileOf reports whether relPath is a checksum file this engine
+// writes for an entry of the source, which DeleteExtraneous must keep.
+func (e *SyncEngine) checksumFileOf(relPath string, inSource map[string]struct{}) bool {
+	names, ok := checksumFileNames[e.scanner.checksumLabel()]
+	if !ok {
+		return false
+	}
+
+	switch e.checksumMode {
+	case ChecksumFilesSidecar:
+		_, exists := inSource[strings.TrimSuffix(relPath, names.sidecarExt)]
+		return strings.HasSuffix(relPath, names.sidecarExt) && exists
+	case ChecksumFilesManifest:
+		dir := filepath.Dir(relPath)
+		_, exists := inSource[dir]
+
+		return filepath.Base(relPath) == names.manifest && (dir == "." || exists)
+	default:
+		return false
+	}
+}
+
+// writeChecksumFiles writes the checksum files for every verified file.
+// Paths that also exist in the source are never overwritten.
+func (e *SyncEngine) writeChecksumFiles(destination string, inSource map[string]struct{}) {
+	names := checksumFileNames[e.scanner.checksumLabel()]
+
+	// Digest lines keyed by checksum file, relative to the destination.
+	contents := make(map[string][]string)
+
+	for relPath, file := range e.verified {
+		digest, err := e.fileDigest(file)
+		if err != nil {
+			e.errorHandler.AddError(ClassifySyncError("checksum-file", file.Path, err))
+			atomic.AddInt64(&e.stats.ErrorsEncountered, 1)
+
+			continue
+		}
+
+		checksumPath := relPath + names.sidecarExt
+		if e.checksumMode == ChecksumFilesManifest {
+			checksumPath = filepath.Join(filepath.Dir(relPath), names.manifest)
+		}
+
+		line := fmt.Sprintf("%s  %s\n", digest, filepath.Base(relPath))
+		contents[checksumPath] = append(contents[checksumPath], line)
+	}
+
+	for relPath, lines := range contents {
+		if _, exists := inSource[relPath]; exists {
+			continue
+		}
+
+		// Lines are "<digest>  <name>"; list them by name.
+		sort.Slice(lines, func(i, j int) bool {
+			return lines[i][strings.Index(lines[i], "  "):] < lines[j][strings.Index(lines[j], "  "):]
+		})
+
+		path := filepath.Join(destination, relPath)
+
+		err := e.guard.check("write", path)
+		if err == nil {
+			err = writeIfChanged(path, []byte(strings.Join(lines, "")))
+		}
+
+		if err != nil {
+			e.errorHandler.AddError(ClassifySyncError("checksum-file", path, err))
+			atomic.AddInt64(&e.stats.ErrorsEncountered, 1)
+		}
+	}
+}
+
+// fileDigest returns file's primary digest from the scan. Empty files are
+// never hashed during scans, so theirs is computed here.
+func (e *SyncEngine) fileDigest(file *FileInfo) (string, error) {
+	if file.Size > 0 {
+		if file.Checksum == "" {
+			return "", fmt.Errorf("no checksum was computed for %s", file.Path)
+		}
+
+		return file.Checksum, nil
+	}
+
+	hasher, err := e.scanner.newHasher(e.scanner.checksumAlgo)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// writeIfChanged writes data to path unless the file already holds exactly
+// that, so unchanged checksum files keep their modification times.
+func writeIfChanged(path string, data []byte) error {
+	path = toExtendedPath(path)
+
+	if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, data) {
+		return nil
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write checksum file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package core
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// tempFilePatterns match the names of the temporary files relay writes next
+// to their target and renames into place, which an interrupted run can leave
+// behind.
+var tempFilePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^\..+\.[0-9]+\.relay$`),  // clonefile copies, dedupe links
+	regexp.MustCompile(`^\..+\.[0-9]+\.tmp$`),    // archives
+	regexp.MustCompile(`^.+\.relay-link$`),       // atomic mirror symlink swaps
+	regexp.MustCompile(`^\.relay-clock-[0-9]+$`), // clock skew probes
+}
+
+// Backup is a file CreateBackup saved before a conflict replaced it.
+type Backup struct {
+	Path     string    `json:"path"`
+	Original string    `json:"original"` // base name of the file backed up
+	Created  time.Time `json:"created"`
+	Size     int64     `json:"size"`
+}
+
+// ListBackups returns the backups in dir and the directories below it,
+// oldest first. A missing dir holds none. Other files in dir are ignored.
+func ListBackups(dir string) ([]Backup, error) {
+	var backups []Backup
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+
+			return nil
+		}
+
+		original, created, ok := parseBackupName(entry.Name())
+		if !ok || !entry.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+
+		backups = append(backups, Backup{
+			Path:     path,
+			Original: original,
+			Created:  created,
+			Size:     info.Size(),
+		})
+
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	slices.SortFunc(backups, func(a, b Backup) int {
+		return cmp.Or(a.Created.Compare(b.Created), cmp.Compare(a.Path, b.Path))
+	})
+
+	return backups, nil
+}
+
+// parseBackupName splits a backup file name into the name of the file backed
+// up and the time the backup was made, reporting whether name is a backup.
+func parseBackupName(name string) (string, time.Time, bool) {
+	rest, ok := strings.CutSuffix(name, ".backup")
+	if !ok {
+		return "", time.Time{}, false
+	}
+
+	dot := strings.LastIndexByte(rest, '.')
+	if dot <= 0 {
+		return "", time.Time{}, false
+	}
+
+	created, ok := parseBackupStamp(rest[dot+1:])
+	if !ok {
+		return "", time.Time{}, false
+	}
+
+	return rest[:dot], created, true
+}
+
+// IsTempFile reports whether name is that of a temporary file relay writes
+// while copying.
+func IsTempFile(name string) bool {
+	return slices.ContainsFunc(tempFilePatterns, func(pattern *regexp.Regexp) bool {
+		return pattern.MatchString(name)
+	})
+}
+
+// FindTempFiles returns the temporary files under root, as IsTempFile
+// recognizes them, last modified before cutoff. Newer ones may belong to a
+// copy still in progress. Directories that cannot be read are skipped.
+func FindTempFiles(ctx context.Context, root string, cutoff time.Time) ([]string, error) {
+	var found []string
+
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		if err != nil {
+			if path == root {
+				return err
+			}
+
+			return nil
+		}
+
+		if entry.IsDir() || !IsTempFile(entry.Name()) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			return nil
+		}
+
+		found = append(found, path)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search %s: %w", root, err)
+	}
+
+	return found, nil
+}
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestListBackups(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	modTime := time.Now()
+
+	writeTreeFile(t, filepath.Join(dir, "notes.txt.20240301_090000.backup"), "new", modTime)
+	writeTreeFile(t, filepath.Join(dir, "a.b.txt.20230102_150405.backup"), "old", modTime)
+	writeTreeFile(t, filepath.Join(dir, "notes.txt.backup"), "no stamp", modTime)
+	writeTreeFile(t, filepath.Join(dir, "unrelated.txt"), "other", modTime)
+
+	backups, err := ListBackups(dir)
+	if err != nil {
+		t.Fatalf("ListBackups failed: %v", err)
+	}
+
+	var originals []string
+	for _, backup := range backups {
+		originals = append(originals, backup.Original)
+	}
+
+	if want := []string{"a.b.txt", "notes.txt"}; !slices.Equal(originals, want) {
+		t.Fatalf("originals = %v, want %v oldest first", originals, want)
+	}
+
+	if want := time.Date(2023, 1, 2, 15, 4, 5, 0, time.Local); !backups[0].Created.Equal(want) {
+		t.Errorf("Created = %v, want %v", backups[0].Created, want)
+	}
+
+	if backups[0].Size != 3 {
+		t.Errorf("Size = %d, want 3", backups[0].Size)
+	}
+
+	missing, err := ListBackups(filepath.Join(dir, "missing"))
+	if err != nil || missing != nil {
+		t.Errorf("ListBackups of a missing directory = %v, %v; want none", missing, err)
+	}
+}
+
+func TestFindTempFiles(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	old := time.Now().Add(-2 * time.Hour)
+
+	for _, name := range []string{
+		".photo.jpg.4011902.relay",
+		"sub/.archive.tar.zst.88.tmp",
+		"sub/.relay-clock-1234",
+		"current.relay-link",
+		"report.tmp",
+		".hidden.relay",
+		"notes.relay",
+	} {
+		writeTreeFile(t, filepath.Join(root, name), "x", old)
+	}
+
+	writeTreeFile(t, filepath.Join(root, ".fresh.txt.77.relay"), "x", time.Now())
+
+	found, err := FindTempFiles(context.Background(), root, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("FindTempFiles failed: %v", err)
+	}
+
+	var got []string
+	for _, path := range found {
+		rel, _ := filepath.Rel(root, path)
+		got = append(got, filepath.ToSlash(rel))
+	}
+
+	slices.Sort(got)
+
+	want := []string{".photo.jpg.4011902.relay", "current.relay-link", "sub/.archive.tar.zst.88.tmp", "sub/.relay-clock-1234"}
+	if !slices.Equal(got, want) {
+		t.Errorf("FindTempFiles = %v, want %v", got, want)
+	}
+}
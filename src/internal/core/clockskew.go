@@ -0,0 +1,91 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ClockSkewThreshold is the skew beyond which modification-time comparison
+// between source and destination becomes unreliable.
+const ClockSkewThreshold = 2 * time.Second
+
+// ErrClockProbeSkipped is returned by SyncEngine.MeasureClockSkew for runs
+// that must not write to the destination.
+var ErrClockProbeSkipped = errors.New("the destination is not written to in a dry run or read-only run")
+
+// ClockSkew holds how far the destination's filesystem clock is ahead of the
+// local clock. A negative value means the filesystem is behind.
+type ClockSkew struct {
+	Destination time.Duration
+}
+
+// Significant reports whether the skew exceeds ClockSkewThreshold.
+func (c ClockSkew) Significant() bool {
+	return c.Destination.Abs() > ClockSkewThreshold
+}
+
+// MeasureClockSkew probes destination for the skew of its clock. The source
+// is never written to, so its clock is taken to be the local one. Dry runs
+// and read-only runs make no probe and return ErrClockProbeSkipped.
+func (e *SyncEngine) MeasureClockSkew(destination string) (ClockSkew, error) {
+	if e.options.DryRun || e.options.ReadOnly {
+		return ClockSkew{}, ErrClockProbeSkipped
+	}
+
+	skew, err := MeasureClockSkew(destination)
+	if err != nil {
+		return ClockSkew{}, err
+	}
+
+	return ClockSkew{Destination: skew}, nil
+}
+
+// MeasureClockSkew writes a short-lived probe file in dir and compares the
+// modification time the filesystem reports for it with the local clock. The
+// modification time of dir is restored once the probe is removed.
+func MeasureClockSkew(dir string) (time.Duration, error) {
+	dirInfo, err := os.Stat(dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat %s: %w", dir, err)
+	}
+
+	before := time.Now()
+
+	probe, err := os.CreateTemp(dir, ".relay-clock-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create clock probe in %s: %w", dir, err)
+	}
+
+	probePath := probe.Name()
+	defer func() {
+		_ = os.Remove(probePath)
+		_ = os.Chtimes(dir, time.Time{}, dirInfo.ModTime())
+	}()
+
+	_, err = probe.Write([]byte{0})
+	if cerr := probe.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to write clock probe %s: %w", probePath, err)
+	}
+
+	after := time.Now()
+
+	info, err := os.Stat(probePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat clock probe %s: %w", probePath, err)
+	}
+
+	// Filesystems without sub-second timestamps can report up to a second
+	// early, so anything within the probe window is treated as no skew.
+	modTime := info.ModTime()
+	if !modTime.Before(before.Truncate(time.Second)) && !modTime.After(after) {
+		return 0, nil
+	}
+
+	return modTime.Sub(before.Add(after.Sub(before) / 2)), nil
+}
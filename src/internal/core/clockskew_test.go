@@ -0,0 +1,123 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestClockSkewSignificant(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		skew ClockSkew
+		want bool
+	}{
+		{name: "in sync", skew: ClockSkew{}, want: false},
+		{name: "destination ahead", skew: ClockSkew{Destination: 5 * time.Second}, want: true},
+		{name: "destination behind", skew: ClockSkew{Destination: -3 * time.Second}, want: true},
+		{name: "within threshold", skew: ClockSkew{Destination: ClockSkewThreshold}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.skew.Significant(); got != tt.want {
+				t.Errorf("Significant() = %v, want %v (skew %v)", got, tt.want, tt.skew.Destination)
+			}
+		})
+	}
+}
+
+func TestMeasureClockSkewLocal(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(dir, modTime, modTime); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+
+	skew, err := MeasureClockSkew(dir)
+	if err != nil {
+		t.Fatalf("MeasureClockSkew failed: %v", err)
+	}
+
+	if skew.Abs() > ClockSkewThreshold {
+		t.Errorf("Local filesystem skew = %v, want about zero", skew)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("probe left %d entries behind", len(entries))
+	}
+
+	if info, err := os.Stat(dir); err != nil || !info.ModTime().Equal(modTime) {
+		t.Errorf("directory modification time not restored (err %v)", err)
+	}
+}
+
+func TestSyncEngineMeasureClockSkewSkipped(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		set  func(opts *SyncOptions)
+	}{
+		{name: "dry run", set: func(opts *SyncOptions) { opts.DryRun = true }},
+		{name: "read-only", set: func(opts *SyncOptions) { opts.ReadOnly = true }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+
+			modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+			if err := os.Chtimes(dir, modTime, modTime); err != nil {
+				t.Fatalf("Chtimes failed: %v", err)
+			}
+
+			engine, err := NewSyncEngine()
+			if err != nil {
+				t.Fatalf("NewSyncEngine failed: %v", err)
+			}
+
+			opts := engine.Options()
+			tt.set(&opts)
+			engine.SetOptions(opts)
+
+			if _, err := engine.MeasureClockSkew(dir); !errors.Is(err, ErrClockProbeSkipped) {
+				t.Errorf("MeasureClockSkew = %v, want ErrClockProbeSkipped", err)
+			}
+
+			if info, err := os.Stat(dir); err != nil || !info.ModTime().Equal(modTime) {
+				t.Errorf("destination modification time changed (err %v)", err)
+			}
+		})
+	}
+}
+
+func TestSyncEngineNeedsSyncModifyWindow(t *testing.T) {
+	t.Parallel()
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	now := time.Now()
+	source := &FileInfo{Size: 10, ModTime: now}
+	dest := &FileInfo{Size: 10, ModTime: now.Add(-time.Second)}
+
+	if !engine.needsSync(source, dest, SyncOptions{}) {
+		t.Errorf("Expected differing modtimes to need sync without a modify window")
+	}
+
+	if engine.needsSync(source, dest, SyncOptions{ModifyWindow: 2 * time.Second}) {
+		t.Errorf("Expected modtimes within the modify window to compare equal")
+	}
+}
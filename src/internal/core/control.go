@@ -0,0 +1,224 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// controlTimeout bounds how long one exchange on a control socket may take,
+// so that a client that stops halfway never holds up the daemon.
+const controlTimeout = 10 * time.Second
+
+// Control commands a daemon understands.
+const (
+	// ControlStatus asks for the state of the daemon and its profiles.
+	ControlStatus = "status"
+	// ControlSync starts a run of Profile now.
+	ControlSync = "sync"
+	// ControlStop shuts the daemon down once its runs have stopped.
+	ControlStop = "stop"
+	// ControlPause stops the schedule and watch of Profile, or of every
+	// profile when it is empty, from starting runs.
+	ControlPause = "pause"
+	// ControlResume undoes ControlPause.
+	ControlResume = "resume"
+)
+
+// ControlRequest is one command sent to a daemon's control socket.
+type ControlRequest struct {
+	Command string `json:"command"`
+	Profile string `json:"profile,omitempty"` // ControlSync, ControlPause and ControlResume
+}
+
+// ControlResponse is a daemon's answer to a ControlRequest.
+type ControlResponse struct {
+	Error   string       `json:"error,omitempty"`   // why the command failed, if it did
+	Message string       `json:"message,omitempty"` // what the command did
+	Daemon  *DaemonState `json:"daemon,omitempty"`  // ControlStatus
+}
+
+// DaemonState describes a running daemon.
+type DaemonState struct {
+	PID      int                  `json:"pid"`
+	Config   string               `json:"config"`
+	Started  time.Time            `json:"started"`
+	Profiles []DaemonProfileState `json:"profiles"`
+}
+
+// DaemonProfileState describes one profile a daemon keeps in sync.
+type DaemonProfileState struct {
+	Name        string         `json:"name"`
+	Trigger     string         `json:"trigger"`            // schedule, watch or manual
+	Schedule    string         `json:"schedule,omitempty"` // the cron expression, for schedule
+	Next        time.Time      `json:"next,omitzero"`      // when a schedule next runs it
+	Source      string         `json:"source"`
+	Destination string         `json:"destination"`
+	Running     bool           `json:"running"`
+	Paused      bool           `json:"paused,omitempty"`
+	RunStarted  time.Time      `json:"runStarted,omitzero"`
+	Progress    *Progress      `json:"progress,omitempty"` // the current run's, while Running
+	Last        *ProfileStatus `json:"last,omitempty"`     // the last run this daemon finished
+}
+
+// ControlServer answers commands on a Unix domain socket, one request and
+// response per connection.
+type ControlServer struct {
+	listener net.Listener
+	handle   func(ControlRequest) ControlResponse
+	wg       sync.WaitGroup
+}
+
+// DefaultControlPath returns the control socket of the current user's
+// daemon, in the user cache directory.
+func DefaultControlPath() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+
+	return filepath.Join(cacheDir, "relay", "daemon.sock"), nil
+}
+
+// ListenControl creates a Unix domain socket at path, accessible only to the
+// current user, and answers each request on it with handle until Close. A
+// socket left behind by an earlier process is replaced; one a daemon still
+// answers on, or any other file at path, is an error.
+func ListenControl(path string, handle func(ControlRequest) ControlResponse) (*ControlServer, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create control socket directory: %w", err)
+	}
+
+	listener, err := listenSocket(path, "control")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &ControlServer{listener: listener, handle: handle}
+
+	s.wg.Add(1)
+
+	go s.accept()
+
+	return s, nil
+}
+
+// listenSocket creates the Unix domain socket at path, named kind in errors,
+// accessible only to the current user. A socket left behind by an earlier
+// process is replaced; one another process still listens on, or any other
+// file at path, is an error.
+func listenSocket(path, kind string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&fs.ModeSocket == 0 {
+			return nil, fmt.Errorf("cannot create %s socket: %s exists and is not a socket", kind, path)
+		}
+
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("cannot create %s socket: another process is already listening on %s", kind, path)
+		}
+
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale %s socket: %w", kind, err)
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s socket: %w", kind, err)
+	}
+
+	if err := os.Chmod(path, 0o600); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("failed to restrict %s socket: %w", kind, err)
+	}
+
+	return listener, nil
+}
+
+func (s *ControlServer) accept() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+
+		if err != nil {
+			continue
+		}
+
+		s.wg.Add(1)
+
+		go s.serve(conn)
+	}
+}
+
+// serve answers the one request sent on conn.
+func (s *ControlServer) serve(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() { _ = conn.Close() }()
+
+	_ = conn.SetDeadline(time.Now().Add(controlTimeout))
+
+	var (
+		request  ControlRequest
+		response ControlResponse
+	)
+
+	if err := json.NewDecoder(conn).Decode(&request); err != nil {
+		response.Error = fmt.Sprintf("invalid request: %v", err)
+	} else {
+		response = s.handle(request)
+	}
+
+	_ = json.NewEncoder(conn).Encode(response)
+}
+
+// Close stops accepting requests, waits for those being answered and
+// removes the socket.
+func (s *ControlServer) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+
+	if err != nil {
+		return fmt.Errorf("failed to close control socket: %w", err)
+	}
+
+	return nil
+}
+
+// SendControl sends request to the daemon listening on the control socket
+// at path and returns its response. A response reporting an error is
+// returned as that error.
+func SendControl(path string, request ControlRequest) (*ControlResponse, error) {
+	conn, err := net.DialTimeout("unix", path, controlTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("no daemon is listening on %s: %w", path, err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	_ = conn.SetDeadline(time.Now().Add(controlTimeout))
+
+	if err := json.NewEncoder(conn).Encode(request); err != nil {
+		return nil, fmt.Errorf("failed to send %s to daemon: %w", request.Command, err)
+	}
+
+	var response ControlResponse
+	if err := json.NewDecoder(conn).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to read daemon response: %w", err)
+	}
+
+	if response.Error != "" {
+		return nil, errors.New(response.Error)
+	}
+
+	return &response, nil
+}
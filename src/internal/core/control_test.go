@@ -0,0 +1,110 @@
+package core
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestControlServerRoundTrip(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "daemon.sock")
+
+	server, err := ListenControl(path, func(request ControlRequest) ControlResponse {
+		switch request.Command {
+		case ControlStatus:
+			return ControlResponse{Daemon: &DaemonState{PID: 42, Profiles: []DaemonProfileState{{Name: "nas", Running: true}}}}
+		case ControlSync:
+			return ControlResponse{Message: "Started mirroring " + request.Profile}
+		case ControlPause:
+			return ControlResponse{Message: "Paused " + request.Profile}
+		default:
+			return ControlResponse{Error: "unknown command " + request.Command}
+		}
+	})
+	if err != nil {
+		t.Fatalf("ListenControl failed: %v", err)
+	}
+
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("control socket mode = %v (err %v), want 0600", info.Mode().Perm(), err)
+	}
+
+	response, err := SendControl(path, ControlRequest{Command: ControlStatus})
+	if err != nil {
+		t.Fatalf("SendControl(status) failed: %v", err)
+	}
+
+	if response.Daemon == nil || response.Daemon.PID != 42 || len(response.Daemon.Profiles) != 1 || !response.Daemon.Profiles[0].Running {
+		t.Errorf("status response = %+v, want the daemon's state", response)
+	}
+
+	response, err = SendControl(path, ControlRequest{Command: ControlSync, Profile: "nas"})
+	if err != nil || response.Message != "Started mirroring nas" {
+		t.Errorf("SendControl(sync) = %+v, %v; want the daemon's message", response, err)
+	}
+
+	response, err = SendControl(path, ControlRequest{Command: ControlPause, Profile: "nas"})
+	if err != nil || response.Message != "Paused nas" {
+		t.Errorf("SendControl(pause) = %+v, %v; want the daemon's message", response, err)
+	}
+
+	if _, err := SendControl(path, ControlRequest{Command: "reboot"}); err == nil || !strings.Contains(err.Error(), "unknown command") {
+		t.Errorf("SendControl(reboot) error = %v, want the daemon's error", err)
+	}
+
+	// A second daemon must not take over the socket of a running one.
+	if _, err := ListenControl(path, nil); err == nil {
+		t.Error("ListenControl on a socket in use succeeded, want an error")
+	}
+
+	if err := server.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("socket was not removed on Close (err %v)", err)
+	}
+
+	if _, err := SendControl(path, ControlRequest{Command: ControlStatus}); err == nil {
+		t.Error("SendControl after Close succeeded, want an error")
+	}
+}
+
+func TestListenControlReplacesStaleSocket(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "daemon.sock")
+
+	// A socket file no process listens on, as a crashed daemon leaves.
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = listener.Close()
+
+	server, err := ListenControl(path, func(ControlRequest) ControlResponse {
+		return ControlResponse{Message: "ok"}
+	})
+	if err != nil {
+		t.Fatalf("ListenControl over a stale socket failed: %v", err)
+	}
+	defer func() { _ = server.Close() }()
+
+	if response, err := SendControl(path, ControlRequest{Command: ControlStatus}); err != nil || response.Message != "ok" {
+		t.Errorf("SendControl = %+v, %v; want ok", response, err)
+	}
+
+	// Any other file is left alone.
+	plain := filepath.Join(t.TempDir(), "daemon.sock")
+	if err := os.WriteFile(plain, nil, 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	if _, err := ListenControl(plain, nil); err == nil {
+		t.Error("ListenControl over a regular file succeeded, want an error")
+	}
+}
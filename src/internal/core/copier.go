@@ -5,18 +5,26 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"runtime"
+
+	"github.com/howmanysmall/relay/src/internal/config"
 )
 
 // FileCopier handles copying files with various optimizations and options.
 type FileCopier struct {
-	bufferSize    int64
-	useZeroCopy   bool
-	preservePerms bool
-	preserveTimes bool
-	workers       int
+	bufferSize      int64
+	useZeroCopy     bool
+	preservePerms   bool
+	preserveTimes   bool
+	preserveCrtimes bool // creation times, where the OS can set them
+	workers         int
+	limiter         *bandwidthLimiter
+	openFiles       *openFileLimiter // shared with the scanner; nil: unbounded
+	guard           *writeGuard      // shared with the engine; nil: writable
+	logger          *slog.Logger
 }
 
 // NewFileCopier creates a new file copier with the specified buffer size and zero-copy option.
@@ -31,11 +39,18 @@ func NewFileCopier(bufferSize int64, useZeroCopy bool) *FileCopier {
 		preservePerms: true,
 		preserveTimes: true,
 		workers:       runtime.GOMAXPROCS(0),
+		logger:        discardLogger,
 	}
 }
 
 // CopyFile copies a file or directory from source to destination.
 func (fc *FileCopier) CopyFile(ctx context.Context, src, dst string) error {
+	src, dst = toExtendedPath(src), toExtendedPath(dst)
+
+	if err := fc.guard.check("copy to", dst); err != nil {
+		return err
+	}
+
 	srcInfo, err := os.Stat(src)
 	if err != nil {
 		return fmt.Errorf("failed to stat source file %s: %w", src, err)
@@ -53,6 +68,24 @@ func (fc *FileCopier) copyRegularFile(ctx context.Context, src, dst string, srcI
 		return fmt.Errorf("failed to create destination directory: %w", err)
 	}
 
+	release, err := fc.openFiles.acquire(ctx, 2)
+	if err != nil {
+		return fmt.Errorf("copy cancelled: %w", err)
+	}
+
+	defer release()
+
+	// Cloning bypasses the bandwidth limiter, so only clone when unlimited.
+	if fc.useZeroCopy && fc.limiter == nil {
+		if handled, err := fc.cloneFile(ctx, src, dst); handled {
+			if err == nil {
+				fc.logger.Debug("file copied", "source", src, "destination", dst, "bytes", srcInfo.Size(), "method", "clone")
+			}
+
+			return err
+		}
+	}
+
 	dstFile, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, srcInfo.Mode())
 	if err != nil {
 		return fmt.Errorf("failed to create destination file %s: %w", dst, err)
@@ -76,7 +109,10 @@ func (fc *FileCopier) copyRegularFile(ctx context.Context, src, dst string, srcI
 	}()
 
 	var bytesWritten int64
+
+	method := "buffered"
 	if fc.useZeroCopy && fc.canUseZeroCopy(srcFile, dstFile) {
+		method = "zero-copy"
 		bytesWritten, err = fc.zeroCopy(ctx, srcFile, dstFile, srcInfo.Size())
 	} else {
 		bytesWritten, err = fc.bufferedCopy(ctx, srcFile, dstFile)
@@ -102,6 +138,14 @@ func (fc *FileCopier) copyRegularFile(ctx context.Context, src, dst string, srcI
 		return fmt.Errorf("failed to sync destination file: %w", err)
 	}
 
+	fc.logger.Debug("file copied", "source", src, "destination", dst, "bytes", bytesWritten, "method", method)
+
+	return fc.applyMetadata(dst, srcInfo)
+}
+
+// applyMetadata gives dst the permissions, modification time and creation
+// time of the source, as configured.
+func (fc *FileCopier) applyMetadata(dst string, srcInfo os.FileInfo) error {
 	if fc.preservePerms {
 		if err := os.Chmod(dst, srcInfo.Mode()); err != nil {
 			return fmt.Errorf("failed to set file permissions: %w", err)
@@ -114,6 +158,14 @@ func (fc *FileCopier) copyRegularFile(ctx context.Context, src, dst string, srcI
 		}
 	}
 
+	if fc.preserveCrtimes {
+		if crtime, ok := creationTime(srcInfo); ok {
+			if err := setCreationTime(dst, crtime); err != nil {
+				return fmt.Errorf("failed to set file creation time: %w", err)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -151,6 +203,10 @@ func (fc *FileCopier) bufferedCopy(ctx context.Context, src io.Reader, dst io.Wr
 
 		bytesRead, err := src.Read(buffer)
 		if bytesRead > 0 {
+			if limitErr := fc.throttle(ctx, bytesRead); limitErr != nil {
+				return totalBytes, limitErr
+			}
+
 			bytesWritten, writeErr := dst.Write(buffer[:bytesRead])
 			totalBytes += int64(bytesWritten)
 
@@ -204,11 +260,32 @@ func (fc *FileCopier) zeroCopy(ctx context.Context, src, dst *os.File, size int6
 }
 
 func (fc *FileCopier) zeroCopyDarwin(ctx context.Context, src, dst *os.File, _ int64) (int64, error) {
-	// macOS copyfile is not available through standard syscalls in Go
-	// Fall back to buffered copy for now
+	// Cloning through copyfile(3) happens before the files are opened (see
+	// cloneFile); by the time we have descriptors the buffered path is the
+	// only option left.
 	return fc.bufferedCopy(ctx, src, dst)
 }
 
+// throttle blocks until n more bytes may be copied under the bandwidth limit.
+func (fc *FileCopier) throttle(ctx context.Context, n int) error {
+	if fc.limiter == nil {
+		return nil
+	}
+
+	return fc.limiter.wait(ctx, n)
+}
+
+// SetBandwidthSchedule limits copy throughput according to schedule. A nil or
+// unlimited schedule removes any limit.
+func (fc *FileCopier) SetBandwidthSchedule(schedule *config.BandwidthSchedule) {
+	if schedule == nil || schedule.IsUnlimited() {
+		fc.limiter = nil
+		return
+	}
+
+	fc.limiter = newBandwidthLimiter(schedule)
+}
+
 // SetPreservePermissions sets whether to preserve file permissions during copy.
 func (fc *FileCopier) SetPreservePermissions(preserve bool) {
 	fc.preservePerms = preserve
@@ -219,6 +296,26 @@ func (fc *FileCopier) SetPreserveTimes(preserve bool) {
 	fc.preserveTimes = preserve
 }
 
+// SetPreserveCreationTimes sets whether to preserve file creation times
+// during copy. It has no effect where CanPreserveCreationTimes reports false.
+func (fc *FileCopier) SetPreserveCreationTimes(preserve bool) {
+	fc.preserveCrtimes = preserve
+}
+
+// CanPreserveCreationTimes reports whether this platform can set the creation
+// (birth) time of a file, as macOS and Windows can and Linux cannot.
+func CanPreserveCreationTimes() bool {
+	return creationTimesSupported
+}
+
+// SetWorkers sets how many files a sync copies at once. Non-positive values
+// are ignored.
+func (fc *FileCopier) SetWorkers(workers int) {
+	if workers > 0 {
+		fc.workers = workers
+	}
+}
+
 // SetBufferSize sets the buffer size for file operations.
 func (fc *FileCopier) SetBufferSize(size int64) {
 	if size > 0 {
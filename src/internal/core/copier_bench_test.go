@@ -0,0 +1,126 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	benchLargeFileSize = 64 << 20 // 64 MiB
+	benchSmallFileSize = 4 << 10  // 4 KiB
+	benchSmallFiles    = 256
+)
+
+// writeBenchFile creates a file of size bytes with non-repeating content so
+// filesystems cannot compress or deduplicate it.
+func writeBenchFile(b *testing.B, path string, size int) {
+	b.Helper()
+
+	content := make([]byte, size)
+	for i := range content {
+		content[i] = byte(i*31 + i>>8)
+	}
+
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		b.Fatalf("Failed to create benchmark file: %v", err)
+	}
+}
+
+func BenchmarkFileCopierLargeFile(b *testing.B) {
+	tempDir := b.TempDir()
+	sourceFile := filepath.Join(tempDir, "large.bin")
+	writeBenchFile(b, sourceFile, benchLargeFileSize)
+
+	for _, zeroCopy := range []bool{false, true} {
+		name := "buffered"
+		if zeroCopy {
+			name = "zero-copy"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			copier := NewFileCopier(0, zeroCopy)
+			destFile := filepath.Join(tempDir, name+".bin")
+
+			b.SetBytes(benchLargeFileSize)
+
+			for b.Loop() {
+				if err := copier.CopyFile(context.Background(), sourceFile, destFile); err != nil {
+					b.Fatalf("CopyFile failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkFileCopierSmallFiles(b *testing.B) {
+	sourceDir := b.TempDir()
+	destDir := b.TempDir()
+
+	for i := range benchSmallFiles {
+		writeBenchFile(b, filepath.Join(sourceDir, fmt.Sprintf("file%03d.txt", i)), benchSmallFileSize)
+	}
+
+	copier := NewFileCopier(0, true)
+
+	b.SetBytes(benchSmallFiles * benchSmallFileSize)
+
+	for b.Loop() {
+		for i := range benchSmallFiles {
+			name := fmt.Sprintf("file%03d.txt", i)
+			if err := copier.CopyFile(context.Background(), filepath.Join(sourceDir, name), filepath.Join(destDir, name)); err != nil {
+				b.Fatalf("CopyFile failed: %v", err)
+			}
+		}
+	}
+}
+
+// BenchmarkFileCopierChecksum measures what verifying a copy by re-hashing the
+// destination adds on top of the copy itself.
+func BenchmarkFileCopierChecksum(b *testing.B) {
+	tempDir := b.TempDir()
+	sourceFile := filepath.Join(tempDir, "large.bin")
+	writeBenchFile(b, sourceFile, benchLargeFileSize)
+
+	for _, verify := range []bool{false, true} {
+		name := "copy"
+		if verify {
+			name = "copy+checksum"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			copier := NewFileCopier(0, true)
+			scanner := NewFileScanner(1)
+			destFile := filepath.Join(tempDir, "verified.bin")
+
+			b.SetBytes(benchLargeFileSize)
+
+			for b.Loop() {
+				if err := copier.CopyFile(context.Background(), sourceFile, destFile); err != nil {
+					b.Fatalf("CopyFile failed: %v", err)
+				}
+
+				if verify {
+					checksumBenchFile(b, scanner, destFile)
+				}
+			}
+		})
+	}
+}
+
+func checksumBenchFile(b *testing.B, scanner *FileScanner, path string) {
+	b.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		b.Fatalf("Failed to open %s: %v", path, err)
+	}
+
+	if _, _, err := scanner.calculateChecksum(file); err != nil {
+		b.Fatalf("calculateChecksum failed: %v", err)
+	}
+
+	_ = file.Close()
+}
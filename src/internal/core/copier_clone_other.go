@@ -0,0 +1,14 @@
+//go:build !darwin || !cgo
+
+package core
+
+import "context"
+
+// cloneFileSupported reports whether cloneFile is implemented.
+const cloneFileSupported = false
+
+// cloneFile is only implemented on macOS with cgo enabled; elsewhere the
+// regular zero-copy or buffered paths are used.
+func (fc *FileCopier) cloneFile(_ context.Context, _, _ string) (bool, error) {
+	return false, nil
+}
@@ -0,0 +1,115 @@
+//go:build darwin && cgo
+
+package core
+
+/*
+#include <copyfile.h>
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+	"unsafe"
+)
+
+// cloneFileSupported reports whether cloneFile is implemented.
+const cloneFileSupported = true
+
+// cloneFile copies src to dst with copyfile(3) and COPYFILE_CLONE, which
+// clones the file on APFS when both paths share a volume and otherwise copies
+// data, permissions, timestamps, ACLs and extended attributes in one call.
+// It reports false when copyfile fails so the caller can fall back to the
+// buffered path.
+func (fc *FileCopier) cloneFile(ctx context.Context, src, dst string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return true, err
+	}
+
+	// COPYFILE_CLONE implies COPYFILE_EXCL, so clone into a fresh temporary
+	// name beside the destination and rename it into place afterwards.
+	tempFile, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*.relay")
+	if err != nil {
+		return false, nil
+	}
+
+	tempPath := tempFile.Name()
+	_ = tempFile.Close()
+	_ = os.Remove(tempPath)
+
+	var mode os.FileMode
+
+	if !fc.preservePerms {
+		if mode, err = createdMode(src, dst, tempPath); err != nil {
+			return false, nil
+		}
+	}
+
+	cSrc := C.CString(src)
+	defer C.free(unsafe.Pointer(cSrc))
+
+	cDst := C.CString(tempPath)
+	defer C.free(unsafe.Pointer(cDst))
+
+	if rc := C.copyfile(cSrc, cDst, nil, C.copyfile_flags_t(C.COPYFILE_CLONE)); rc != 0 {
+		_ = os.Remove(tempPath)
+		return false, nil
+	}
+
+	// copyfile always carries over permissions and timestamps; honour
+	// SetPreservePermissions(false) and SetPreserveTimes(false).
+	if !fc.preservePerms {
+		if err := os.Chmod(tempPath, mode); err != nil {
+			_ = os.Remove(tempPath)
+			return true, fmt.Errorf("failed to set file permissions: %w", err)
+		}
+	}
+
+	if !fc.preserveTimes {
+		now := time.Now()
+		if err := os.Chtimes(tempPath, now, now); err != nil {
+			_ = os.Remove(tempPath)
+			return true, fmt.Errorf("failed to set file times: %w", err)
+		}
+	}
+
+	if err := os.Rename(tempPath, dst); err != nil {
+		_ = os.Remove(tempPath)
+		return true, fmt.Errorf("failed to move cloned file into place: %w", err)
+	}
+
+	return true, nil
+}
+
+// createdMode returns the permissions the buffered path leaves dst with when
+// it does not preserve them: those of dst when it exists, and otherwise the
+// mode of src less the umask, read from a file briefly created at probe.
+func createdMode(src, dst, probe string) (os.FileMode, error) {
+	if info, err := os.Stat(dst); err == nil {
+		return info.Mode().Perm(), nil
+	}
+
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return 0, err
+	}
+
+	file, err := os.OpenFile(probe, os.O_WRONLY|os.O_CREATE|os.O_EXCL, srcInfo.Mode().Perm())
+	if err != nil {
+		return 0, err
+	}
+
+	info, err := file.Stat()
+	_ = file.Close()
+	_ = os.Remove(probe)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return info.Mode().Perm(), nil
+}
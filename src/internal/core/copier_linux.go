@@ -25,6 +25,10 @@ func (fc *FileCopier) zeroCopyLinux(ctx context.Context, src, dst *os.File, size
 			chunkSize = remaining
 		}
 
+		if err := fc.throttle(ctx, int(chunkSize)); err != nil {
+			return totalBytes, err
+		}
+
 		bytesWritten, err := syscall.Sendfile(int(dst.Fd()), int(src.Fd()), nil, int(chunkSize))
 		if err != nil {
 			return fc.bufferedCopy(ctx, src, dst)
@@ -0,0 +1,20 @@
+//go:build !darwin && !windows
+
+package core
+
+import (
+	"os"
+	"time"
+)
+
+// Linux and most other systems cannot set a file's birth time, so creation
+// times are not preserved there.
+const creationTimesSupported = false
+
+func creationTime(_ os.FileInfo) (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func setCreationTime(_ string, _ time.Time) error {
+	return nil
+}
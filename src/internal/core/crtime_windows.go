@@ -0,0 +1,44 @@
+//go:build windows
+
+package core
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+const creationTimesSupported = true
+
+// creationTime returns the creation time of the file described by info.
+func creationTime(info os.FileInfo) (time.Time, bool) {
+	data, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return time.Unix(0, data.CreationTime.Nanoseconds()), true
+}
+
+// setCreationTime sets the creation time of path, leaving its access and
+// modification times unchanged.
+func setCreationTime(path string, crtime time.Time) error {
+	name, err := syscall.UTF16PtrFromString(toExtendedPath(path))
+	if err != nil {
+		return err
+	}
+
+	// FILE_FLAG_BACKUP_SEMANTICS is required to open directories.
+	handle, err := syscall.CreateFile(name, syscall.FILE_WRITE_ATTRIBUTES,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil, syscall.OPEN_EXISTING, syscall.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = syscall.CloseHandle(handle) }()
+
+	ctime := syscall.NsecToFiletime(crtime.UnixNano())
+
+	return syscall.SetFileTime(handle, &ctime, nil, nil)
+}
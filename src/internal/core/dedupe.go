@@ -0,0 +1,289 @@
+package core
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// DuplicateGroup is a set of files with the same content.
+type DuplicateGroup struct {
+	Checksum string   `json:"checksum"`
+	Size     int64    `json:"size"`  // of each file
+	Paths    []string `json:"paths"` // sorted; hard links to one file are listed once each
+	// Copies is how many separate files hold the content; paths already
+	// hard-linked together count once.
+	Copies int `json:"copies"`
+}
+
+// Wasted returns the space the group's extra copies take, which linking them
+// would free.
+func (g *DuplicateGroup) Wasted() int64 {
+	return g.Size * int64(g.Copies-1)
+}
+
+// DuplicateReport is what FindDuplicates found under Root.
+type DuplicateReport struct {
+	Root       string           `json:"root"`
+	Files      int64            `json:"files"`  // files scanned
+	Hashed     int64            `json:"hashed"` // files hashed because another had the same size
+	Groups     []DuplicateGroup `json:"groups"` // most wasted space first
+	Unreadable int              `json:"unreadable,omitempty"`
+}
+
+// Wasted returns the space all extra copies take.
+func (r *DuplicateReport) Wasted() int64 {
+	var wasted int64
+	for i := range r.Groups {
+		wasted += r.Groups[i].Wasted()
+	}
+
+	return wasted
+}
+
+// LinkResult is what LinkDuplicates did to one group.
+type LinkResult struct {
+	Linked  []string // paths replaced with a link to the group's first path
+	Skipped []string // paths that changed since the scan or differ in permissions
+	Freed   int64
+	Errors  []error
+}
+
+// FindDuplicates scans root with scanner and groups the files with identical
+// content. Files are listed first without being read; only those sharing
+// their size with another file of at least minSize bytes are hashed, with
+// the scanner's checksum settings, which should not sample. Empty files are
+// never reported. Entries that cannot be read are counted in Unreadable
+// rather than failing the scan.
+func FindDuplicates(ctx context.Context, scanner *FileScanner, root string, minSize int64) (*DuplicateReport, error) {
+	report := &DuplicateReport{Root: root, Groups: []DuplicateGroup{}}
+	minSize = max(minSize, 1)
+
+	var (
+		mu     sync.Mutex
+		bySize = make(map[int64][]*FileInfo)
+	)
+
+	// Listing without checksums; files that cannot have a duplicate are
+	// never read.
+	scanner.SetSkipChecksums(true)
+
+	_, err := scanner.ScanWithFilter(ctx, root, func(_ string, info *FileInfo) bool {
+		if info.IsDir || !fs.FileMode(info.Mode).IsRegular() {
+			return false
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		report.Files++
+
+		if info.Size >= minSize {
+			bySize[info.Size] = append(bySize[info.Size], info)
+		}
+
+		return false
+	})
+
+	var incomplete *IncompleteScanError
+
+	switch {
+	case errors.As(err, &incomplete):
+		report.Unreadable = len(incomplete.Failures)
+	case err != nil:
+		return nil, err
+	}
+
+	var candidates []*FileInfo
+
+	for _, files := range bySize {
+		if len(files) > 1 {
+			candidates = append(candidates, files...)
+		}
+	}
+
+	report.Hashed = int64(len(candidates))
+
+	scanner.SetSkipChecksums(false)
+
+	if err := hashCandidates(ctx, scanner, candidates); err != nil {
+		return nil, err
+	}
+
+	byChecksum := make(map[string][]*FileInfo)
+
+	for _, file := range candidates {
+		if file.Checksum == "" {
+			report.Unreadable++
+			continue
+		}
+
+		byChecksum[file.Checksum] = append(byChecksum[file.Checksum], file)
+	}
+
+	for _, files := range byChecksum {
+		if len(files) < 2 {
+			continue
+		}
+
+		group := DuplicateGroup{Checksum: files[0].Checksum, Size: files[0].Size}
+		for _, file := range files {
+			group.Paths = append(group.Paths, file.Path)
+		}
+
+		slices.Sort(group.Paths)
+
+		group.Copies = distinctFiles(group.Paths)
+		if group.Copies > 1 {
+			report.Groups = append(report.Groups, group)
+		}
+	}
+
+	slices.SortFunc(report.Groups, func(a, b DuplicateGroup) int {
+		return cmp.Or(cmp.Compare(b.Wasted(), a.Wasted()), strings.Compare(a.Paths[0], b.Paths[0]))
+	})
+
+	return report, nil
+}
+
+// hashCandidates fills in the checksums of files, hashing as many at once as
+// the scanner scans. A file that cannot be read is left without one.
+func hashCandidates(ctx context.Context, scanner *FileScanner, files []*FileInfo) error {
+	sem := semaphore.NewWeighted(scanner.maxConcurrency)
+
+	var wg sync.WaitGroup
+
+	for _, file := range files {
+		if err := sem.Acquire(ctx, 1); err != nil {
+			wg.Wait()
+			return err
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			defer sem.Release(1)
+
+			scanner.populateChecksum(file)
+		}()
+	}
+
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+// distinctFiles returns how many separate files paths name, counting hard
+// links to the same file once. Paths that cannot be statted count as
+// separate.
+func distinctFiles(paths []string) int {
+	var seen []os.FileInfo
+
+	count := 0
+
+	for _, path := range paths {
+		info, err := os.Stat(toExtendedPath(path))
+		if err != nil {
+			count++
+			continue
+		}
+
+		if !slices.ContainsFunc(seen, func(other os.FileInfo) bool { return os.SameFile(info, other) }) {
+			seen = append(seen, info)
+			count++
+		}
+	}
+
+	return count
+}
+
+// LinkDuplicates replaces every file of group other than its first path with
+// a hard link to that first path, freeing the space the copies took. Each
+// link is made under a temporary name and renamed over the copy, so a copy is
+// never lost. A copy whose size or modification time changed since the scan
+// is skipped, as is one whose permissions differ from the first path's, since
+// linked files share their permissions, owner and times. When the first path
+// itself changed, the copies may be the only ones left with the scanned
+// content, so the whole group is skipped. Paths already linked to the first
+// are left alone. With dryRun nothing is changed, and the result tells what
+// would be.
+func LinkDuplicates(group *DuplicateGroup, scanned time.Time, dryRun bool) *LinkResult {
+	result := &LinkResult{}
+
+	keep := group.Paths[0]
+
+	keepInfo, err := os.Lstat(toExtendedPath(keep))
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Errorf("failed to stat %s: %w", keep, err))
+		return result
+	}
+
+	if changedSinceScan(keepInfo, group.Size, scanned) {
+		result.Skipped = append(result.Skipped, group.Paths[1:]...)
+		return result
+	}
+
+	for _, path := range group.Paths[1:] {
+		info, err := os.Lstat(toExtendedPath(path))
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Errorf("failed to stat %s: %w", path, err))
+			continue
+		}
+
+		if os.SameFile(keepInfo, info) {
+			continue
+		}
+
+		if changedSinceScan(info, group.Size, scanned) || info.Mode().Perm() != keepInfo.Mode().Perm() {
+			result.Skipped = append(result.Skipped, path)
+			continue
+		}
+
+		if !dryRun {
+			if err := replaceWithLink(keep, path); err != nil {
+				result.Errors = append(result.Errors, err)
+				continue
+			}
+		}
+
+		result.Linked = append(result.Linked, path)
+		result.Freed += group.Size
+	}
+
+	return result
+}
+
+// changedSinceScan reports whether the file of info is no longer a regular
+// file of size bytes last modified by the scan at scanned.
+func changedSinceScan(info os.FileInfo, size int64, scanned time.Time) bool {
+	return !info.Mode().IsRegular() || info.Size() != size || info.ModTime().After(scanned)
+}
+
+// replaceWithLink atomically replaces path with a hard link to target.
+func replaceWithLink(target, path string) error {
+	// Named like a clonefile copy, so that relay clean removes one left
+	// behind by a crash.
+	temp := filepath.Join(filepath.Dir(path), fmt.Sprintf(".%s.%d.relay", filepath.Base(path), time.Now().UnixNano()))
+
+	if err := os.Link(toExtendedPath(target), toExtendedPath(temp)); err != nil {
+		return fmt.Errorf("failed to link %s to %s: %w", path, target, err)
+	}
+
+	if err := os.Rename(toExtendedPath(temp), toExtendedPath(path)); err != nil {
+		_ = os.Remove(toExtendedPath(temp))
+		return fmt.Errorf("failed to replace %s with a link: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,187 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+
+	files := map[string]string{
+		"a/one.txt":     "duplicate content",
+		"b/two.txt":     "duplicate content",
+		"c/three.txt":   "duplicate content",
+		"same-size.txt": "different content",
+		"unique.txt":    "only one of these",
+		"small-1.txt":   "x",
+		"small-2.txt":   "x",
+		"empty-1.txt":   "",
+		"empty-2.txt":   "",
+	}
+
+	for path, content := range files {
+		full := filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	// A hard link is the same file, not another copy.
+	if err := os.Link(filepath.Join(root, "a", "one.txt"), filepath.Join(root, "linked.txt")); err != nil {
+		t.Skipf("Hard links not supported: %v", err)
+	}
+
+	report, err := FindDuplicates(context.Background(), NewFileScanner(0), root, 2)
+	if err != nil {
+		t.Fatalf("FindDuplicates failed: %v", err)
+	}
+
+	if report.Files != 10 {
+		t.Errorf("Files = %d, want 10", report.Files)
+	}
+
+	if len(report.Groups) != 1 {
+		t.Fatalf("Groups = %+v, want one group", report.Groups)
+	}
+
+	group := report.Groups[0]
+
+	wantPaths := []string{
+		filepath.Join(root, "a", "one.txt"),
+		filepath.Join(root, "b", "two.txt"),
+		filepath.Join(root, "c", "three.txt"),
+		filepath.Join(root, "linked.txt"),
+	}
+	if !slices.Equal(group.Paths, wantPaths) {
+		t.Errorf("Paths = %v, want %v", group.Paths, wantPaths)
+	}
+
+	if group.Copies != 3 || group.Wasted() != 2*int64(len("duplicate content")) {
+		t.Errorf("Copies = %d, Wasted = %d; want 3 and %d", group.Copies, group.Wasted(), 2*len("duplicate content"))
+	}
+
+	// Below the minimum size the single-byte files are not considered.
+	report, err = FindDuplicates(context.Background(), NewFileScanner(0), root, 0)
+	if err != nil {
+		t.Fatalf("FindDuplicates failed: %v", err)
+	}
+
+	if len(report.Groups) != 2 {
+		t.Errorf("without a minimum size, Groups = %+v, want the small files too", report.Groups)
+	}
+}
+
+func TestLinkDuplicates(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+
+	paths := []string{
+		filepath.Join(root, "keep.txt"),
+		filepath.Join(root, "copy.txt"),
+		filepath.Join(root, "private.txt"),
+		filepath.Join(root, "changed.txt"),
+	}
+
+	for _, path := range paths {
+		if err := os.WriteFile(path, []byte("same"), 0o644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	if err := os.Chmod(paths[2], 0o600); err != nil {
+		t.Fatalf("Failed to chmod: %v", err)
+	}
+
+	scanned := time.Now()
+
+	if err := os.Chtimes(paths[3], scanned.Add(time.Hour), scanned.Add(time.Hour)); err != nil {
+		t.Fatalf("Failed to set times: %v", err)
+	}
+
+	group := &DuplicateGroup{Size: 4, Paths: paths, Copies: 4}
+
+	result := LinkDuplicates(group, scanned, true)
+	if len(result.Errors) != 0 || !slices.Equal(result.Linked, paths[1:2]) {
+		t.Fatalf("dry run: %+v, want only copy.txt linked", result)
+	}
+
+	info, err := os.Stat(paths[1])
+	if err != nil {
+		t.Fatalf("Failed to stat: %v", err)
+	}
+
+	keepInfo, err := os.Stat(paths[0])
+	if err != nil {
+		t.Fatalf("Failed to stat: %v", err)
+	}
+
+	if os.SameFile(info, keepInfo) {
+		t.Fatal("dry run linked copy.txt")
+	}
+
+	result = LinkDuplicates(group, scanned, false)
+	if len(result.Errors) != 0 {
+		t.Skipf("Hard links not supported: %v", result.Errors)
+	}
+
+	if !slices.Equal(result.Linked, paths[1:2]) || !slices.Equal(result.Skipped, paths[2:]) || result.Freed != 4 {
+		t.Errorf("result = %+v, want copy.txt linked and the others skipped", result)
+	}
+
+	if info, err = os.Stat(paths[1]); err != nil || !os.SameFile(info, keepInfo) {
+		t.Errorf("copy.txt is not a link to keep.txt (%v)", err)
+	}
+
+	// Linking again finds nothing left to do.
+	if result = LinkDuplicates(group, scanned, false); len(result.Linked) != 0 {
+		t.Errorf("second run linked %v", result.Linked)
+	}
+}
+
+func TestLinkDuplicatesKeptFileChanged(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte("original"), 0o644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	report, err := FindDuplicates(context.Background(), NewFileScanner(0), root, 0)
+	if err != nil || len(report.Groups) != 1 {
+		t.Fatalf("FindDuplicates = %+v, %v; want one group", report, err)
+	}
+
+	scanned := time.Now()
+	group := &report.Groups[0]
+
+	// The kept file is edited after the scan, keeping its size.
+	keep := group.Paths[0]
+	if err := os.WriteFile(keep, []byte("modified"), 0o644); err != nil {
+		t.Fatalf("Failed to edit file: %v", err)
+	}
+
+	if err := os.Chtimes(keep, scanned.Add(time.Hour), scanned.Add(time.Hour)); err != nil {
+		t.Fatalf("Failed to set times: %v", err)
+	}
+
+	result := LinkDuplicates(group, scanned, false)
+	if len(result.Linked) != 0 || !slices.Equal(result.Skipped, group.Paths[1:]) {
+		t.Errorf("result = %+v, want the whole group skipped", result)
+	}
+
+	if data, err := os.ReadFile(group.Paths[1]); err != nil || string(data) != "original" {
+		t.Errorf("copy = %q (err %v), want the original content kept", data, err)
+	}
+}
@@ -0,0 +1,180 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// DeleteOrder selects when DeleteExtraneous removes destination entries
+// relative to copying.
+type DeleteOrder int
+
+// Delete orders
+const (
+	// DeleteAfter deletes once every file is copied, so nothing is missing
+	// from the destination while the run is in progress.
+	DeleteAfter DeleteOrder = iota
+	// DeleteBefore deletes before anything is copied, freeing space first.
+	DeleteBefore
+	// DeleteDuring deletes the extraneous entries of each source directory
+	// just before that directory is synced, keeping peak space low without
+	// removing everything up front.
+	DeleteDuring
+)
+
+func (o DeleteOrder) String() string {
+	switch o {
+	case DeleteBefore:
+		return "before"
+	case DeleteDuring:
+		return "during"
+	default:
+		return "after"
+	}
+}
+
+// ParseDeleteOrder parses a --delete-order value.
+func ParseDeleteOrder(value string) (DeleteOrder, error) {
+	switch strings.ToLower(value) {
+	case "", "after":
+		return DeleteAfter, nil
+	case "before":
+		return DeleteBefore, nil
+	case "during":
+		return DeleteDuring, nil
+	default:
+		return DeleteAfter, fmt.Errorf("invalid delete order %q: expected before, after or during", value)
+	}
+}
+
+// deletionPass removes the extraneous entries of one destination in the
+// order of opts.DeleteOrder. Entries are grouped under the nearest directory
+// that is in the source listing, so a group holds whole stale subtrees and
+// can be deleted on its own. Empty directories are pruned once every group is
+// done.
+type deletionPass struct {
+	engine      *SyncEngine
+	source      string
+	destination string
+	inSource    map[string]struct{}
+	destMap     map[string]*FileInfo
+	stats       *SyncStats
+	opts        SyncOptions
+	groups      map[string]*deletionGroup
+
+	mu      sync.Mutex
+	deleted []string
+	err     error
+}
+
+// deletionGroup is the extraneous entries under one source directory,
+// longest paths first.
+type deletionGroup struct {
+	once  sync.Once
+	paths []string
+}
+
+// newDeletionPass works out the extraneous entries of destMap.
+func (e *SyncEngine) newDeletionPass(source, destination string, sourceFiles []*FileInfo, destMap map[string]*FileInfo, stats *SyncStats, opts SyncOptions) *deletionPass {
+	d := &deletionPass{
+		engine:      e,
+		source:      source,
+		destination: destination,
+		inSource:    relativePaths(source, sourceFiles),
+		destMap:     destMap,
+		stats:       stats,
+		opts:        opts,
+		groups:      make(map[string]*deletionGroup),
+	}
+
+	for _, relPath := range e.extraneousPaths(d.inSource, destMap) {
+		dir := filepath.Dir(relPath)
+		for dir != "." {
+			if _, ok := d.inSource[dir]; ok {
+				break
+			}
+
+			dir = filepath.Dir(dir)
+		}
+
+		if d.groups[dir] == nil {
+			d.groups[dir] = &deletionGroup{}
+		}
+
+		d.groups[dir].paths = append(d.groups[dir].paths, relPath)
+	}
+
+	return d
+}
+
+// beforeSync runs the deletions due before sourceFile is synced, which only
+// DeleteDuring has: those in its directory and, for a directory, in it.
+// Workers syncing the same directory wait for its deletions to finish. A nil
+// pass has nothing to do.
+func (d *deletionPass) beforeSync(ctx context.Context, sourceFile *FileInfo) {
+	if d == nil || d.opts.DeleteOrder != DeleteDuring {
+		return
+	}
+
+	relPath, err := filepath.Rel(d.source, sourceFile.Path)
+	if err != nil {
+		return
+	}
+
+	d.deleteGroup(ctx, filepath.Dir(relPath))
+
+	if sourceFile.IsDir {
+		d.deleteGroup(ctx, relPath)
+	}
+}
+
+// finish runs every deletion not done yet and prunes the directories they
+// left empty. It must be called once; a nil pass has nothing to do.
+func (d *deletionPass) finish(ctx context.Context) error {
+	if d == nil {
+		return nil
+	}
+
+	for _, dir := range slices.Sorted(maps.Keys(d.groups)) {
+		d.deleteGroup(ctx, dir)
+	}
+
+	d.mu.Lock()
+	deleted, err := d.deleted, d.err
+	d.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	if d.opts.PruneEmptyDirs {
+		return d.engine.pruneEmptyDirs(ctx, d.destination, d.inSource, d.destMap, deleted, d.stats, d.opts)
+	}
+
+	return nil
+}
+
+// deleteGroup deletes the group under dir, once.
+func (d *deletionPass) deleteGroup(ctx context.Context, dir string) {
+	group := d.groups[dir]
+	if group == nil {
+		return
+	}
+
+	group.once.Do(func() {
+		deleted, err := d.engine.deleteExtraneous(ctx, d.source, d.destination, group.paths, d.destMap, d.stats, d.opts)
+
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		d.deleted = append(d.deleted, deleted...)
+		if d.err == nil {
+			d.err = err
+		}
+	})
+}
@@ -0,0 +1,253 @@
+package core
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/zeebo/blake3"
+)
+
+// dirMove is a destination directory whose contents match a directory that
+// is new in the source, so renaming it replaces copying the whole subtree.
+type dirMove struct {
+	from string // relative to the destination
+	to   string
+}
+
+// findDirMoves matches source directories missing from destMap with
+// extraneous destination directories holding exactly the same files, compared
+// by a fingerprint of their relative paths, sizes and checksums. Files
+// without a checksum cannot be compared, so subtrees holding any are never
+// matched, nor are subtrees without files, nor destination directories that
+// still exist in the source though they are not in its listing. Only the
+// outermost match is kept when a matched directory contains others.
+func findDirMoves(source string, sourceFiles []*FileInfo, destMap map[string]*FileInfo) []dirMove {
+	inSource := make(map[string]*FileInfo, len(sourceFiles))
+
+	for _, file := range sourceFiles {
+		if relPath, err := filepath.Rel(source, file.Path); err == nil {
+			inSource[relPath] = file
+		}
+	}
+
+	var appeared, vanished []string
+
+	for relPath, file := range inSource {
+		if _, exists := destMap[relPath]; file.IsDir && !exists {
+			appeared = append(appeared, relPath)
+		}
+	}
+
+	for relPath, file := range destMap {
+		if _, exists := inSource[relPath]; file.IsDir && !exists {
+			// Like deletions, only directories confirmed gone are moved.
+			if _, err := os.Lstat(toExtendedPath(filepath.Join(source, relPath))); errors.Is(err, fs.ErrNotExist) {
+				vanished = append(vanished, relPath)
+			}
+		}
+	}
+
+	if len(appeared) == 0 || len(vanished) == 0 {
+		return nil
+	}
+
+	sourcePrints := subtreeFingerprints(inSource, appeared)
+	destPrints := subtreeFingerprints(destMap, vanished)
+
+	byPrint := make(map[string][]string, len(destPrints))
+	for _, relPath := range vanished {
+		if fingerprint, ok := destPrints[relPath]; ok {
+			byPrint[fingerprint] = append(byPrint[fingerprint], relPath)
+		}
+	}
+
+	for _, candidates := range byPrint {
+		slices.Sort(candidates)
+	}
+
+	// Shallowest first, so a matched directory claims everything below it.
+	slices.SortFunc(appeared, func(a, b string) int {
+		if depth := pathDepth(a) - pathDepth(b); depth != 0 {
+			return depth
+		}
+
+		return strings.Compare(a, b)
+	})
+
+	var moves []dirMove
+
+	for _, relPath := range appeared {
+		fingerprint, ok := sourcePrints[relPath]
+		if !ok || slices.ContainsFunc(moves, func(move dirMove) bool { return within(relPath, move.to) }) {
+			continue
+		}
+
+		candidates := byPrint[fingerprint]
+
+		index := slices.IndexFunc(candidates, func(from string) bool {
+			return !slices.ContainsFunc(moves, func(move dirMove) bool {
+				return within(from, move.from) || within(move.from, from)
+			})
+		})
+		if index < 0 {
+			continue
+		}
+
+		moves = append(moves, dirMove{from: candidates[index], to: relPath})
+	}
+
+	return moves
+}
+
+// subtreeFingerprints returns a fingerprint of the contents of each of dirs
+// in files, keyed by relative path. Directories whose contents cannot be
+// compared are left out.
+func subtreeFingerprints(files map[string]*FileInfo, dirs []string) map[string]string {
+	wanted := make(map[string]struct{}, len(dirs))
+	for _, dir := range dirs {
+		wanted[dir] = struct{}{}
+	}
+
+	entries := make(map[string][]string, len(dirs))
+	unusable := make(map[string]bool)
+	hasFiles := make(map[string]bool)
+
+	for relPath, file := range files {
+		for dir := filepath.Dir(relPath); dir != "."; dir = filepath.Dir(dir) {
+			if _, ok := wanted[dir]; !ok {
+				continue
+			}
+
+			inner := filepath.ToSlash(relPath[len(dir)+1:])
+
+			switch {
+			case file.IsDir:
+				entries[dir] = append(entries[dir], inner+"/")
+			case file.Size > 0 && file.Checksum == "":
+				unusable[dir] = true
+			default:
+				hasFiles[dir] = true
+				entries[dir] = append(entries[dir], inner+"\x00"+strconv.FormatInt(file.Size, 10)+"\x00"+file.ChecksumAlgo+":"+file.Checksum)
+			}
+		}
+	}
+
+	fingerprints := make(map[string]string, len(entries))
+
+	for dir, lines := range entries {
+		if unusable[dir] || !hasFiles[dir] {
+			continue
+		}
+
+		slices.Sort(lines)
+
+		hasher := blake3.New()
+		for _, line := range lines {
+			_, _ = hasher.WriteString(line + "\n")
+		}
+
+		fingerprints[dir] = hex.EncodeToString(hasher.Sum(nil))
+	}
+
+	return fingerprints
+}
+
+// within reports whether relPath is dir or inside it.
+func within(relPath, dir string) bool {
+	return relPath == dir || strings.HasPrefix(relPath, dir+string(filepath.Separator))
+}
+
+// withDirMoves returns a copy of destMap as it will be once moves are made.
+func withDirMoves(destination string, destMap map[string]*FileInfo, moves []dirMove) map[string]*FileInfo {
+	if len(moves) == 0 {
+		return destMap
+	}
+
+	moved := make(map[string]*FileInfo, len(destMap))
+	for relPath, file := range destMap {
+		moved[relPath] = file
+	}
+
+	for _, move := range moves {
+		applyDirMove(destination, moved, move)
+	}
+
+	return moved
+}
+
+// applyDirMove re-keys the entries of destMap under move.from to move.to.
+func applyDirMove(destination string, destMap map[string]*FileInfo, move dirMove) {
+	for relPath, file := range destMap {
+		if !within(relPath, move.from) {
+			continue
+		}
+
+		newPath := move.to + relPath[len(move.from):]
+
+		renamed := *file
+		renamed.Path = filepath.Join(destination, newPath)
+
+		delete(destMap, relPath)
+		destMap[newPath] = &renamed
+	}
+}
+
+// moveDirs renames the destination directories of moves, or counts them in a
+// dry run, and updates destMap to match, which is what a saved snapshot is
+// built from. A directory that cannot be moved is left in place and its files
+// are copied as usual.
+func (e *SyncEngine) moveDirs(destination string, moves []dirMove, destMap map[string]*FileInfo, stats *SyncStats, opts SyncOptions) {
+	for _, move := range moves {
+		if !opts.DryRun {
+			if err := e.moveDir(destination, move); err != nil {
+				e.errorHandler.AddError(ClassifySyncError("move", filepath.Join(destination, move.from), err))
+				atomic.AddInt64(&stats.ErrorsEncountered, 1)
+
+				continue
+			}
+		}
+
+		applyDirMove(destination, destMap, move)
+		atomic.AddInt64(&stats.DirsMoved, 1)
+		e.recordOperation(ChangeRename, move.to, 0)
+		e.audit(ChangeRename, filepath.Join(destination, move.to), filepath.Join(destination, move.from), nil, nil)
+	}
+}
+
+// moveDir renames one destination directory, creating the parent it moves
+// into when that is new as well.
+func (e *SyncEngine) moveDir(destination string, move dirMove) error {
+	from := filepath.Join(destination, move.from)
+	to := filepath.Join(destination, move.to)
+
+	if err := e.guard.check("move", from); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(toExtendedPath(filepath.Dir(to)), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", filepath.Dir(to), err)
+	}
+
+	// Never replace something that appeared since the scan.
+	if _, err := os.Lstat(toExtendedPath(to)); !errors.Is(err, fs.ErrNotExist) {
+		if err == nil {
+			err = fs.ErrExist
+		}
+
+		return fmt.Errorf("cannot move to %s: %w", to, err)
+	}
+
+	if err := os.Rename(toExtendedPath(from), toExtendedPath(to)); err != nil {
+		return fmt.Errorf("failed to move %s to %s: %w", from, to, err)
+	}
+
+	return nil
+}
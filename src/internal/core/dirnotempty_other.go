@@ -0,0 +1,14 @@
+//go:build !windows
+
+package core
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isDirNotEmpty reports whether err is the failure to remove a directory
+// that still has entries.
+func isDirNotEmpty(err error) bool {
+	return errors.Is(err, syscall.ENOTEMPTY)
+}
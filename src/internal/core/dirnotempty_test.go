@@ -0,0 +1,23 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIsDirNotEmpty(t *testing.T) {
+	t.Parallel()
+	dir := filepath.Join(t.TempDir(), "dir")
+
+	writeTreeFile(t, filepath.Join(dir, "kept.txt"), "kept", time.Now())
+
+	if err := os.Remove(dir); !isDirNotEmpty(err) {
+		t.Errorf("isDirNotEmpty(%v) = false for a directory with entries", err)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "missing")); isDirNotEmpty(err) {
+		t.Errorf("isDirNotEmpty(%v) = true for a missing entry", err)
+	}
+}
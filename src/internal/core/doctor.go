@@ -0,0 +1,132 @@
+package core
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// probeSize is the size of the file ProbeCopySupport copies.
+const probeSize = 64 << 10
+
+// FileSystem describes the file system holding a path, as far as the
+// platform reports it.
+type FileSystem struct {
+	Type        string // e.g. ext4, nfs or apfs; "unknown" when not reported
+	Network     bool   // served over the network
+	CoarseTimes bool   // keeps modification times to 2 seconds, like FAT
+	Free        int64  // bytes available to unprivileged users; -1 when unknown
+	Total       int64  // size in bytes; -1 when unknown
+}
+
+// CopySupport reports the fast copy paths available in a directory.
+type CopySupport struct {
+	ZeroCopy string // system call relay copies through without user-space buffers; empty when none works
+	Reflink  bool   // the file system can clone a file by sharing its blocks
+}
+
+// StatFileSystem describes the file system holding path, or its nearest
+// existing ancestor when path does not exist yet.
+func StatFileSystem(path string) (*FileSystem, error) {
+	existing, err := nearestExisting(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return statFileSystem(existing)
+}
+
+// ProbeCopySupport copies a small temporary file within dir, which must
+// exist, to find the fast copy paths available there. The files are removed
+// afterwards.
+func ProbeCopySupport(dir string) (*CopySupport, error) {
+	src, err := os.CreateTemp(dir, ".relay-probe-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create probe file: %w", err)
+	}
+
+	defer func() {
+		_ = src.Close()
+		_ = os.Remove(src.Name())
+	}()
+
+	data := make([]byte, probeSize)
+	_, _ = rand.Read(data)
+
+	if _, err := src.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to write probe file: %w", err)
+	}
+
+	return probeCopySupport(src, dir)
+}
+
+// withProbeTarget calls probe with a new empty temporary file in dir, which
+// is removed afterwards.
+func withProbeTarget(dir string, probe func(dst *os.File)) error {
+	dst, err := os.CreateTemp(dir, ".relay-probe-*")
+	if err != nil {
+		return fmt.Errorf("failed to create probe file: %w", err)
+	}
+
+	defer func() {
+		_ = dst.Close()
+		_ = os.Remove(dst.Name())
+	}()
+
+	probe(dst)
+
+	return nil
+}
+
+// ChecksumThroughput hashes size bytes in memory with algo and returns the
+// rate in bytes per second, an upper bound for verifying files from disk.
+func ChecksumThroughput(algo string, size int) (float64, error) {
+	newHash, ok := checksumHashers[algo]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnsupportedChecksum, algo)
+	}
+
+	hasher := newHash()
+
+	data := make([]byte, size)
+	_, _ = rand.Read(data)
+
+	start := time.Now()
+	_, _ = hasher.Write(data)
+	hasher.Sum(nil)
+
+	return float64(size) / max(time.Since(start).Seconds(), 1e-9), nil
+}
+
+// InotifyWatchLimit returns the number of inotify watches each user may
+// hold, which bounds the directories watch mode can follow, and false on
+// platforms without inotify.
+func InotifyWatchLimit() (int, bool) {
+	return inotifyWatchLimit()
+}
+
+// nearestExisting returns path, or the closest of its ancestors that exists.
+func nearestExisting(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		_, err := os.Stat(path)
+		if err == nil || !errors.Is(err, fs.ErrNotExist) {
+			return path, err
+		}
+
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", err
+		}
+
+		path = parent
+	}
+}
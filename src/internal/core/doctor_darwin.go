@@ -0,0 +1,50 @@
+//go:build darwin
+
+package core
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func statFileSystem(path string) (*FileSystem, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		return nil, fmt.Errorf("failed to stat file system of %s: %w", path, err)
+	}
+
+	name := unix.ByteSliceToString(stat.Fstypename[:])
+
+	return &FileSystem{
+		Type:        name,
+		Network:     stat.Flags&unix.MNT_LOCAL == 0,
+		CoarseTimes: name == "msdos" || name == "exfat",
+		Free:        int64(stat.Bavail) * int64(stat.Bsize),
+		Total:       int64(stat.Blocks) * int64(stat.Bsize),
+	}, nil
+}
+
+func probeCopySupport(src *os.File, dir string) (*CopySupport, error) {
+	support := &CopySupport{}
+
+	// clonefile(2) creates its target, so the probe target is removed first.
+	err := withProbeTarget(dir, func(dst *os.File) {
+		_ = os.Remove(dst.Name())
+		support.Reflink = unix.Clonefile(src.Name(), dst.Name(), 0) == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if support.Reflink && cloneFileSupported {
+		support.ZeroCopy = "clonefile"
+	}
+
+	return support, nil
+}
+
+func inotifyWatchLimit() (int, bool) {
+	return 0, false
+}
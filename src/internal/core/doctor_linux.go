@@ -0,0 +1,91 @@
+//go:build linux
+
+package core
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// fileSystemTypes names the file systems statfs(2) reports by magic number.
+var fileSystemTypes = map[uint32]FileSystem{
+	0xEF53:     {Type: "ext4"},
+	0x9123683E: {Type: "btrfs"},
+	0x58465342: {Type: "xfs"},
+	0x2FC12FC1: {Type: "zfs"},
+	0xF2F52010: {Type: "f2fs"},
+	0x01021994: {Type: "tmpfs"},
+	0x794C7630: {Type: "overlay"},
+	0x5346544E: {Type: "ntfs"},
+	0x7366746E: {Type: "ntfs3"},
+	0x65735546: {Type: "fuse"},
+	0x4D44:     {Type: "vfat", CoarseTimes: true},
+	0x2011BAB0: {Type: "exfat", CoarseTimes: true},
+	0x6969:     {Type: "nfs", Network: true},
+	0x517B:     {Type: "smb", Network: true},
+	0xFF534D42: {Type: "cifs", Network: true},
+	0xFE534D42: {Type: "smb2", Network: true},
+	0x00C36400: {Type: "ceph", Network: true},
+	0x47504653: {Type: "gpfs", Network: true},
+	0x0BD00BD0: {Type: "lustre", Network: true},
+	0x6B414653: {Type: "afs", Network: true},
+}
+
+func statFileSystem(path string) (*FileSystem, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		return nil, fmt.Errorf("failed to stat file system of %s: %w", path, err)
+	}
+
+	info, known := fileSystemTypes[uint32(stat.Type)]
+	if !known {
+		info.Type = fmt.Sprintf("unknown (0x%X)", uint32(stat.Type))
+	}
+
+	info.Free = int64(stat.Bavail) * int64(stat.Bsize)
+	info.Total = int64(stat.Blocks) * int64(stat.Bsize)
+
+	return &info, nil
+}
+
+func probeCopySupport(src *os.File, dir string) (*CopySupport, error) {
+	support := &CopySupport{}
+
+	err := withProbeTarget(dir, func(dst *os.File) {
+		written, err := syscall.Sendfile(int(dst.Fd()), int(src.Fd()), new(int64), probeSize)
+		if err == nil && written == probeSize {
+			support.ZeroCopy = "sendfile"
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = withProbeTarget(dir, func(dst *os.File) {
+		support.Reflink = unix.IoctlFileClone(int(dst.Fd()), int(src.Fd())) == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return support, nil
+}
+
+func inotifyWatchLimit() (int, bool) {
+	data, err := os.ReadFile("/proc/sys/fs/inotify/max_user_watches")
+	if err != nil {
+		return 0, false
+	}
+
+	limit, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, false
+	}
+
+	return limit, true
+}
@@ -0,0 +1,17 @@
+//go:build !linux && !darwin
+
+package core
+
+import "os"
+
+func statFileSystem(_ string) (*FileSystem, error) {
+	return &FileSystem{Type: "unknown", Free: -1, Total: -1}, nil
+}
+
+func probeCopySupport(_ *os.File, _ string) (*CopySupport, error) {
+	return &CopySupport{}, nil
+}
+
+func inotifyWatchLimit() (int, bool) {
+	return 0, false
+}
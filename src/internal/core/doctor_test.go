@@ -0,0 +1,62 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStatFileSystem(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	// A destination that does not exist yet is looked up on its parent.
+	fileSystem, err := StatFileSystem(filepath.Join(dir, "not", "yet"))
+	if err != nil {
+		t.Fatalf("StatFileSystem failed: %v", err)
+	}
+
+	if fileSystem.Type == "" {
+		t.Error("Type is empty")
+	}
+
+	if fileSystem.Free > fileSystem.Total {
+		t.Errorf("Free = %d exceeds Total = %d", fileSystem.Free, fileSystem.Total)
+	}
+}
+
+func TestProbeCopySupportLeavesNoFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	if _, err := ProbeCopySupport(dir); err != nil {
+		t.Fatalf("ProbeCopySupport failed: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+
+	if len(entries) != 0 {
+		t.Errorf("ProbeCopySupport left %d files behind", len(entries))
+	}
+}
+
+func TestChecksumThroughput(t *testing.T) {
+	t.Parallel()
+
+	for _, algo := range ChecksumAlgorithms() {
+		rate, err := ChecksumThroughput(algo, 1<<20)
+		if err != nil || rate <= 0 {
+			t.Errorf("ChecksumThroughput(%s) = %v, %v; want a positive rate", algo, rate, err)
+		}
+	}
+
+	if _, err := ChecksumThroughput("crc32", 1<<20); !errors.Is(err, ErrUnsupportedChecksum) {
+		t.Errorf("ChecksumThroughput(crc32) error = %v, want ErrUnsupportedChecksum", err)
+	}
+}
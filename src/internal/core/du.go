@@ -0,0 +1,220 @@
+package core
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// DiskUsage totals the apparent sizes of the files in a directory tree.
+type DiskUsage struct {
+	Root         string           `json:"root"`
+	Size         int64            `json:"size"`
+	Files        int64            `json:"files"`
+	Dirs         int64            `json:"dirs"`
+	Unreadable   int              `json:"unreadable,omitempty"` // entries that could not be read, and are left out
+	LargestFiles []UsageEntry     `json:"largestFiles"`
+	LargestDirs  []UsageEntry     `json:"largestDirs"`
+	Extensions   []ExtensionUsage `json:"extensions"` // largest first
+}
+
+// UsageEntry is the size of a file, or of everything under a directory.
+type UsageEntry struct {
+	Path  string `json:"path"` // relative to DiskUsage.Root
+	Size  int64  `json:"size"`
+	Files int64  `json:"files,omitempty"` // for a directory, the files under it
+}
+
+// ExtensionUsage totals the files with one extension.
+type ExtensionUsage struct {
+	Extension string `json:"extension"` // lower case with its dot, or empty for none
+	Size      int64  `json:"size"`
+	Files     int64  `json:"files"`
+}
+
+// usageTally accumulates a DiskUsage from the entries of a scan, which
+// arrive concurrently.
+type usageTally struct {
+	mu         sync.Mutex
+	usage      *DiskUsage
+	depth      int
+	top        int
+	dirs       map[string]*UsageEntry
+	extensions map[string]*ExtensionUsage
+}
+
+// MeasureDiskUsage scans root with the concurrent FileScanner, without
+// reading any file, and totals its files. The largest files, directories and
+// extensions are listed, top of each, or all of them when top is not
+// positive; directories up to depth levels below root are considered.
+// Entries that cannot be read are counted in Unreadable rather than failing
+// the scan.
+func MeasureDiskUsage(ctx context.Context, root string, depth, top int) (*DiskUsage, error) {
+	usage := &DiskUsage{
+		Root:         root,
+		LargestFiles: []UsageEntry{},
+		LargestDirs:  []UsageEntry{},
+		Extensions:   []ExtensionUsage{},
+	}
+
+	tally := &usageTally{
+		usage:      usage,
+		depth:      depth,
+		top:        top,
+		dirs:       make(map[string]*UsageEntry),
+		extensions: make(map[string]*ExtensionUsage),
+	}
+
+	scanner := NewFileScanner(0)
+	scanner.SetSkipChecksums(true)
+
+	// Nothing is kept by the scan; the tally sees every entry as it is found.
+	_, err := scanner.ScanWithFilter(ctx, root, func(path string, info *FileInfo) bool {
+		tally.add(path, info)
+		return false
+	})
+
+	var incomplete *IncompleteScanError
+
+	switch {
+	case errors.As(err, &incomplete):
+		usage.Unreadable = len(incomplete.Failures)
+	case err != nil:
+		return nil, err
+	}
+
+	return tally.summary(), nil
+}
+
+// add counts the entry at path.
+func (t *usageTally) add(path string, info *FileInfo) {
+	relPath, err := filepath.Rel(t.usage.Root, path)
+	if err != nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if info.IsDir {
+		if relPath == "." {
+			return
+		}
+
+		t.usage.Dirs++
+
+		if dirDepth(relPath) <= t.depth {
+			t.dir(relPath)
+		}
+
+		return
+	}
+
+	// A root that is a file is listed by its name.
+	if relPath == "." {
+		relPath = filepath.Base(path)
+	}
+
+	t.usage.Size += info.Size
+	t.usage.Files++
+
+	for dir := filepath.Dir(relPath); dir != "."; dir = filepath.Dir(dir) {
+		if dirDepth(dir) <= t.depth {
+			entry := t.dir(dir)
+			entry.Size += info.Size
+			entry.Files++
+		}
+	}
+
+	ext := extensionOf(filepath.Base(relPath))
+
+	usage, ok := t.extensions[ext]
+	if !ok {
+		usage = &ExtensionUsage{Extension: ext}
+		t.extensions[ext] = usage
+	}
+
+	usage.Size += info.Size
+	usage.Files++
+
+	t.usage.LargestFiles = insertLargest(t.usage.LargestFiles, UsageEntry{Path: relPath, Size: info.Size}, t.top)
+}
+
+// dir returns the entry of the directory at relPath, creating it if needed.
+func (t *usageTally) dir(relPath string) *UsageEntry {
+	entry, ok := t.dirs[relPath]
+	if !ok {
+		entry = &UsageEntry{Path: relPath}
+		t.dirs[relPath] = entry
+	}
+
+	return entry
+}
+
+// summary returns the DiskUsage with its directories and extensions sorted,
+// largest first, and cut to the top.
+func (t *usageTally) summary() *DiskUsage {
+	for _, entry := range t.dirs {
+		t.usage.LargestDirs = append(t.usage.LargestDirs, *entry)
+	}
+
+	slices.SortFunc(t.usage.LargestDirs, compareUsage)
+
+	for _, usage := range t.extensions {
+		t.usage.Extensions = append(t.usage.Extensions, *usage)
+	}
+
+	slices.SortFunc(t.usage.Extensions, func(a, b ExtensionUsage) int {
+		return cmp.Or(cmp.Compare(b.Size, a.Size), cmp.Compare(b.Files, a.Files), strings.Compare(a.Extension, b.Extension))
+	})
+
+	if t.top > 0 {
+		t.usage.LargestDirs = t.usage.LargestDirs[:min(t.top, len(t.usage.LargestDirs))]
+		t.usage.Extensions = t.usage.Extensions[:min(t.top, len(t.usage.Extensions))]
+	}
+
+	return t.usage
+}
+
+// insertLargest adds entry to entries, which are sorted largest first, and
+// keeps no more than top of them when top is positive.
+func insertLargest(entries []UsageEntry, entry UsageEntry, top int) []UsageEntry {
+	i, _ := slices.BinarySearchFunc(entries, entry, compareUsage)
+	if top > 0 && i >= top {
+		return entries
+	}
+
+	entries = slices.Insert(entries, i, entry)
+	if top > 0 && len(entries) > top {
+		entries = entries[:top]
+	}
+
+	return entries
+}
+
+// compareUsage orders entries largest first, then by path.
+func compareUsage(a, b UsageEntry) int {
+	return cmp.Or(cmp.Compare(b.Size, a.Size), strings.Compare(a.Path, b.Path))
+}
+
+// dirDepth returns how many levels below the root relPath is, 1 for an
+// entry directly in it.
+func dirDepth(relPath string) int {
+	return strings.Count(relPath, string(os.PathSeparator)) + 1
+}
+
+// extensionOf returns the lower-case extension of name, with its dot. A name
+// whose only dot starts it, such as .bashrc, has none.
+func extensionOf(name string) string {
+	ext := filepath.Ext(name)
+	if ext == name {
+		return ""
+	}
+
+	return strings.ToLower(ext)
+}
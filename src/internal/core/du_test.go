@@ -0,0 +1,92 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMeasureDiskUsage(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+
+	files := map[string]int{
+		"readme.md":            10,
+		".bashrc":              5,
+		"photos/a.JPG":         300,
+		"photos/b.jpg":         200,
+		"photos/2026/c.jpg":    400,
+		"music/song.flac":      600,
+		"music/empty/.keep":    0,
+		"docs/notes/plan.txt":  50,
+		"docs/notes/draft.txt": 20,
+		"docs/archive.tar.gz":  100,
+	}
+
+	for path, size := range files {
+		full := filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+
+		if err := os.WriteFile(full, []byte(strings.Repeat("x", size)), 0o644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	usage, err := MeasureDiskUsage(context.Background(), root, 1, 3)
+	if err != nil {
+		t.Fatalf("MeasureDiskUsage failed: %v", err)
+	}
+
+	if usage.Size != 1685 || usage.Files != 10 || usage.Dirs != 6 || usage.Unreadable != 0 {
+		t.Errorf("totals = %d bytes, %d files, %d dirs, %d unreadable; want 1685, 10, 6, 0",
+			usage.Size, usage.Files, usage.Dirs, usage.Unreadable)
+	}
+
+	wantFiles := []UsageEntry{
+		{Path: filepath.Join("music", "song.flac"), Size: 600},
+		{Path: filepath.Join("photos", "2026", "c.jpg"), Size: 400},
+		{Path: filepath.Join("photos", "a.JPG"), Size: 300},
+	}
+	if !slices.Equal(usage.LargestFiles, wantFiles) {
+		t.Errorf("LargestFiles = %+v, want %+v", usage.LargestFiles, wantFiles)
+	}
+
+	// Only the top-level directories are considered at depth 1.
+	wantDirs := []UsageEntry{
+		{Path: "photos", Size: 900, Files: 3},
+		{Path: "music", Size: 600, Files: 2},
+		{Path: "docs", Size: 170, Files: 3},
+	}
+	if !slices.Equal(usage.LargestDirs, wantDirs) {
+		t.Errorf("LargestDirs = %+v, want %+v", usage.LargestDirs, wantDirs)
+	}
+
+	wantExtensions := []ExtensionUsage{
+		{Extension: ".jpg", Size: 900, Files: 3},
+		{Extension: ".flac", Size: 600, Files: 1},
+		{Extension: ".gz", Size: 100, Files: 1},
+	}
+	if !slices.Equal(usage.Extensions, wantExtensions) {
+		t.Errorf("Extensions = %+v, want %+v", usage.Extensions, wantExtensions)
+	}
+
+	usage, err = MeasureDiskUsage(context.Background(), root, 2, 0)
+	if err != nil {
+		t.Fatalf("MeasureDiskUsage failed: %v", err)
+	}
+
+	if len(usage.LargestDirs) != 6 || len(usage.LargestFiles) != 10 {
+		t.Errorf("depth 2, no limit: %d dirs and %d files listed, want 6 and 10", len(usage.LargestDirs), len(usage.LargestFiles))
+	}
+
+	// A dot that only starts the name, as in .bashrc, is no extension.
+	if i := slices.IndexFunc(usage.Extensions, func(ext ExtensionUsage) bool { return ext.Extension == "" }); i < 0 ||
+		usage.Extensions[i].Files != 2 {
+		t.Errorf("Extensions = %+v, want .bashrc and .keep without an extension", usage.Extensions)
+	}
+}
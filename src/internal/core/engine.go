@@ -2,9 +2,19 @@ package core
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"math"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -14,15 +24,50 @@ import (
 
 // SyncEngine orchestrates file synchronization operations.
 type SyncEngine struct {
-	scanner      *FileScanner
-	copier       *FileCopier
-	watcher      *FileWatcher
-	resolver     *ConflictResolver
-	retryManager *RetryManager
-	errorHandler *ErrorHandler
-	stats        *SyncStats
-	progress     *Progress
-	mu           sync.RWMutex
+	scanner          *FileScanner
+	copier           *FileCopier
+	watcher          *FileWatcher
+	resolver         *ConflictResolver
+	retryManager     *RetryManager
+	errorHandler     *ErrorHandler
+	filter           *FileFilter
+	options          SyncOptions
+	stats            *SyncStats
+	progress         *Progress
+	vanished         []string
+	snapshotPath     string
+	checkpointPath   string     // see SetCheckpoint
+	checkpointHeader Checkpoint // Dir and Command of checkpoints
+	checkpoint       *checkpointWriter
+	syncState        string // where SyncTwoWay keeps its state; see SetTwoWayState
+	rescanDest       bool
+	destChanges      map[string]*FileInfo // nil values are deletions
+	filesStarted     int64                // copies begun this run, for MaxFiles
+	activity         []FileOperation      // ring buffer of recent operations
+	activityNext     int                  // index of the oldest entry once activity is full
+	running          atomic.Bool          // set while Sync or RetryFailed runs; see startRun
+	listChanges      bool
+	dryRunChanges    bool            // dry runs record the changes they would make too
+	changeOut        io.Writer       // nil keeps changes in memory
+	changes          []FileOperation // changes made this run, when recorded in memory
+	changeErr        error
+	auditLog         *AuditLog
+	checksumMode     ChecksumFileMode
+	verified         map[string]*FileInfo // files matching their source this run, for checksum files and VerifyAfter
+	verifyAfter      bool                 // SyncOptions.VerifyAfter of the current run, outside dry runs
+	blockReport      int64                // SyncOptions.ChecksumBlockReport of the current run
+	fanOut           []*fanOutTarget      // destinations of a MirrorFanOut run
+	preflight        func(plan *SyncPlan) error
+	plan             *SyncPlan
+	fileTimeout      time.Duration  // SyncOptions.Timeout of the current run
+	fromArchive      *archiveSource // source of the current run when it is an archive
+	events           *EventStream
+	logger           *slog.Logger // see SetLogger
+	openFiles        *openFileLimiter
+	guard            *writeGuard  // shared with the copier
+	samples          []rateSample // bytes transferred over time, for Metrics
+	activeFiles      int64        // files being synced by workers right now
+	mu               sync.RWMutex
 }
 
 // NewSyncEngine creates a new synchronization engine with default settings.
@@ -32,92 +77,450 @@ func NewSyncEngine() (*SyncEngine, error) {
 		return nil, fmt.Errorf("failed to create file watcher: %w", err)
 	}
 
-	return &SyncEngine{
+	engine := &SyncEngine{
 		scanner:      NewFileScanner(0),      // Auto-detect concurrency
 		copier:       NewFileCopier(0, true), // Auto buffer size, enable zero-copy
 		watcher:      watcher,
 		resolver:     NewConflictResolver(nil), // Use default config
 		retryManager: NewRetryManager(nil),     // Use default retry config
 		errorHandler: NewErrorHandler(1000),    // Max 1000 errors
+		filter:       NewFileFilter(),
+		options:      DefaultMirrorOptions(),
 		stats:        &SyncStats{},
 		progress:     &Progress{},
-	}, nil
+		destChanges:  make(map[string]*FileInfo),
+		guard:        &writeGuard{},
+		logger:       discardLogger,
+	}
+
+	engine.copier.guard = engine.guard
+	engine.SetMaxOpenFiles(0)
+
+	return engine, nil
 }
 
-// Mirror performs one-way mirroring from source to destination.
-func (e *SyncEngine) Mirror(ctx context.Context, source, destination string) error {
-	e.resetStats()
-	e.stats.StartTime = time.Now()
+// Default multipliers applied to GOMAXPROCS when concurrency is auto-detected.
+const (
+	DefaultScanConcurrencyMultiplier = 2.0
+	DefaultCopyConcurrencyMultiplier = 1.0
+)
+
+// SetConcurrencyMultipliers sizes the auto-detected scan and copy worker pools
+// relative to GOMAXPROCS, so the same setting suits machines with different
+// core counts. A non-positive multiplier restores that stage's default.
+func (e *SyncEngine) SetConcurrencyMultipliers(scanMultiplier, copyMultiplier float64) {
+	if scanMultiplier <= 0 {
+		scanMultiplier = DefaultScanConcurrencyMultiplier
+	}
+
+	if copyMultiplier <= 0 {
+		copyMultiplier = DefaultCopyConcurrencyMultiplier
+	}
+
+	e.scanner.SetMaxConcurrency(autoConcurrency(scanMultiplier))
+	e.copier.SetWorkers(autoConcurrency(copyMultiplier))
+}
+
+// autoConcurrency scales GOMAXPROCS by multiplier, never returning less than 1.
+func autoConcurrency(multiplier float64) int {
+	return max(1, int(math.Round(float64(runtime.GOMAXPROCS(0))*multiplier)))
+}
+
+// SetDoubleCheck additionally hashes every file with a second algorithm and
+// treats files as equal only when both digests match. It is meant for audit
+// and verification runs, where the extra hashing cost is acceptable.
+func (e *SyncEngine) SetDoubleCheck(enabled bool) {
+	switch {
+	case !enabled:
+		e.scanner.SetSecondaryChecksumAlgorithm("")
+	case e.scanner.checksumAlgo == "sha256":
+		e.scanner.SetSecondaryChecksumAlgorithm("blake3")
+	default:
+		e.scanner.SetSecondaryChecksumAlgorithm("sha256")
+	}
+}
+
+// SetChecksumConcurrency caps concurrent checksum computations. Non-positive
+// values leave hashing bounded only by scan concurrency.
+func (e *SyncEngine) SetChecksumConcurrency(concurrency int) {
+	e.scanner.SetChecksumConcurrency(concurrency)
+}
+
+// SetChecksumAlgorithm selects the algorithm files are compared with. An
+// algorithm this build does not support is rejected with the list of those it
+// does, since files would otherwise be left without checksums and compared by
+// size and modification time alone.
+func (e *SyncEngine) SetChecksumAlgorithm(algo string) error {
+	if _, ok := checksumHashers[algo]; !ok {
+		return fmt.Errorf("%w %q (supported: %s)", ErrUnsupportedChecksum, algo, strings.Join(ChecksumAlgorithms(), ", "))
+	}
+
+	e.scanner.SetChecksumAlgorithm(algo)
+
+	return nil
+}
+
+// SetSampleChecksum hashes files larger than three samples of size bytes from
+// their start, middle and end plus their size, rather than in full. It trades
+// certainty for speed on very large files such as VM images: a change outside
+// the samples that keeps the size and modification time goes unnoticed. A
+// non-positive size hashes every file in full.
+func (e *SyncEngine) SetSampleChecksum(size int64) {
+	e.scanner.SetSampleSize(size)
+}
+
+// SetParallelHash hashes files of at least size bytes on every CPU at once
+// rather than on one, for single multi-gigabyte files that one core cannot
+// hash as fast as the disk reads them. Digests are unchanged. Each CPU runs a
+// portable implementation, several times slower per core than the vectorized
+// one used on amd64, so it pays off on many-core machines and on those the
+// vectorized one does not cover. It applies to blake3 without SetDoubleCheck,
+// and a non-positive size turns it off.
+func (e *SyncEngine) SetParallelHash(size int64) {
+	e.scanner.SetParallelHashSize(size)
+}
+
+// SetBufferSize sets the size of the buffer files are copied through when
+// zero-copy is not used. A non-positive size keeps the 64KB default.
+func (e *SyncEngine) SetBufferSize(size int64) {
+	e.copier.SetBufferSize(size)
+}
+
+// ChecksumAlgorithm returns the name of the algorithm files are compared
+// with, marked when large files are sampled, followed by the second
+// algorithm of SetDoubleCheck when it is on.
+func (e *SyncEngine) ChecksumAlgorithm() string {
+	label := e.scanner.checksumLabel() + e.scanner.sampleLabel()
+	if e.scanner.secondaryAlgo == "" {
+		return label
+	}
+
+	return label + " + " + e.scanner.secondaryAlgo
+}
+
+// SetMaxOpenFiles bounds how many files the scanner and copier hold open at
+// once across all workers, independently of the worker counts. A
+// non-positive limit restores DefaultOpenFileLimit.
+func (e *SyncEngine) SetMaxOpenFiles(limit int) {
+	if limit <= 0 {
+		limit = DefaultOpenFileLimit()
+	}
+
+	e.openFiles = newOpenFileLimiter(limit)
+	e.scanner.openFiles = e.openFiles
+	e.copier.openFiles = e.openFiles
+}
+
+// MaxOpenFiles returns the bound on concurrently open files, or 0 when there
+// is none.
+func (e *SyncEngine) MaxOpenFiles() int {
+	if e.openFiles == nil {
+		return 0
+	}
+
+	return int(e.openFiles.limit)
+}
+
+// SetChecksumSeed enables keyed blake3 checksums derived from seed.
+func (e *SyncEngine) SetChecksumSeed(seed string) {
+	e.scanner.SetChecksumSeed(seed)
+}
+
+// SetBandwidthSchedule limits copy throughput by time of day.
+func (e *SyncEngine) SetBandwidthSchedule(schedule *config.BandwidthSchedule) {
+	e.copier.SetBandwidthSchedule(schedule)
+}
+
+// SetConflictConfig replaces how conflicts are resolved. A nil config
+// restores the default newest-wins strategy.
+func (e *SyncEngine) SetConflictConfig(cfg *config.ConflictConfig) {
+	e.resolver = NewConflictResolver(cfg)
+}
+
+// SetFilter replaces the filter applied to source files during a sync.
+func (e *SyncEngine) SetFilter(filter *FileFilter) {
+	if filter == nil {
+		filter = NewFileFilter()
+	}
+
+	e.filter = filter
+}
 
-	opts := SyncOptions{
+// DefaultMirrorOptions returns the options used by Mirror unless overridden
+// with SetOptions.
+func DefaultMirrorOptions() SyncOptions {
+	return SyncOptions{
 		DryRun:           false,
 		Recursive:        true,
 		PreservePerms:    true,
 		PreserveTimes:    true,
 		DeleteExtraneous: false,
+		PruneEmptyDirs:   true,
 		ChecksumVerify:   true,
 		Workers:          0, // Auto-detect
 	}
+}
+
+// Options returns the options used by Mirror.
+func (e *SyncEngine) Options() SyncOptions {
+	return e.options
+}
 
-	_, err := e.Sync(ctx, source, destination, opts)
+// SetOptions replaces the options used by Mirror. Permission and time
+// preservation, the per-file timeout and read-only mode also apply to copies
+// made by RetryFailed.
+func (e *SyncEngine) SetOptions(opts SyncOptions) {
+	e.options = opts
+	e.applyCopyOptions(opts)
+}
+
+// applyCopyOptions configures the copier for opts.
+func (e *SyncEngine) applyCopyOptions(opts SyncOptions) {
+	e.copier.SetPreservePermissions(opts.PreservePerms)
+	e.copier.SetPreserveTimes(opts.PreserveTimes)
+	e.copier.SetPreserveCreationTimes(opts.PreserveCrtimes)
+	e.fileTimeout = opts.Timeout
+	e.verifyAfter = opts.VerifyAfter && !opts.DryRun
+	e.blockReport = opts.ChecksumBlockReport
+	e.guard.enabled.Store(opts.ReadOnly)
+}
+
+// Mirror performs one-way mirroring from source to destination.
+func (e *SyncEngine) Mirror(ctx context.Context, source, destination string) error {
+	_, err := e.Sync(ctx, source, destination, e.options)
 
 	return err
 }
 
 // Sync performs synchronization between source and destination with the given options.
 func (e *SyncEngine) Sync(ctx context.Context, source, destination string, opts SyncOptions) (*SyncStats, error) {
-	e.resetStats()
-	e.stats.StartTime = time.Now()
+	return e.syncFrom(ctx, source, destination, nil, opts)
+}
+
+// syncFrom runs Sync, reading the source from archive when it is not nil.
+func (e *SyncEngine) syncFrom(ctx context.Context, source, destination string, archive *archiveSource, opts SyncOptions) (*SyncStats, error) {
+	if err := e.startRun(opts.DryRun); err != nil {
+		return nil, err
+	}
 
-	sourceFiles, err := e.scanner.Scan(ctx, source)
+	defer e.endRun()
+
+	e.applyCopyOptions(opts)
+
+	e.fromArchive = archive
+	defer func() { e.fromArchive = nil }()
+
+	if err := checkDistinctPaths(source, destination); err != nil {
+		return e.GetStats(), err
+	}
+
+	if opts.FileList != nil {
+		return e.syncFileList(ctx, source, destination, opts)
+	}
+
+	// The checkpoint is opened before scanning so that the files an
+	// interrupted mirror completed are not hashed again. Archive sources and
+	// dry runs are not checkpointed.
+	if archive == nil && !opts.DryRun {
+		if err := e.openCheckpoint(source, destination); err != nil {
+			e.errorHandler.AddError(ClassifySyncError("checkpoint", e.checkpointPath, err))
+			atomic.AddInt64(&e.stats.ErrorsEncountered, 1)
+		}
+	}
+
+	complete := false
+	defer func() { e.closeCheckpoint(complete) }()
+
+	sourceFiles, err := e.scanSource(ctx, source)
 	if err != nil {
-		return e.stats, fmt.Errorf("failed to scan source directory: %w", err)
+		if !e.recordIncompleteScan(err) {
+			return e.GetStats(), fmt.Errorf("failed to scan source directory: %w", err)
+		}
+	}
+
+	destMap, fromSnapshot := e.loadSnapshot(destination)
+	if !fromSnapshot {
+		destMap, err = e.scanDestination(ctx, destination)
+		if err != nil {
+			return e.GetStats(), err
+		}
+	}
+
+	var moves []dirMove
+	if opts.DetectMoves && opts.DeleteExtraneous {
+		moves = findDirMoves(source, sourceFiles, destMap)
+	}
+
+	plan := e.planSync(source, sourceFiles, withDirMoves(destination, destMap, moves), opts)
+	plan.DirsToMove = int64(len(moves))
+
+	if err := e.runPreflight(plan); err != nil {
+		return e.GetStats(), err
+	}
+
+	e.moveDirs(destination, moves, destMap, e.stats, opts)
+
+	var deletions *deletionPass
+	if opts.DeleteExtraneous {
+		deletions = e.newDeletionPass(source, destination, sourceFiles, destMap, e.stats, opts)
+	}
+
+	if opts.DeleteOrder == DeleteBefore {
+		if err := deletions.finish(ctx); err != nil {
+			return e.GetStats(), err
+		}
 	}
 
-	e.stats.FilesScanned = int64(len(sourceFiles))
-	e.progress.Total = int64(len(sourceFiles))
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = e.copier.workers
+	}
+
+	// Archive entries are read in order from a single stream.
+	if archive != nil {
+		workers = 1
+	}
 
-	destFiles, err := e.scanner.Scan(ctx, destination)
+	err = e.syncFiles(ctx, sourceFiles, workers, func(file *FileInfo) error {
+		deletions.beforeSync(ctx, file)
+		return e.syncFile(ctx, source, destination, file, destMap, opts)
+	})
 	if err != nil {
-		if !os.IsNotExist(err) {
-			return e.stats, fmt.Errorf("failed to scan destination directory: %w", err)
+		return e.GetStats(), err
+	}
+
+	if opts.DeleteOrder != DeleteBefore {
+		if err := deletions.finish(ctx); err != nil {
+			return e.GetStats(), err
 		}
+	}
 
-		destFiles = []*FileInfo{}
+	if e.checksumMode != ChecksumFilesOff && !opts.DryRun {
+		e.writeChecksumFiles(destination, relativePaths(source, sourceFiles))
 	}
 
-	destMap := make(map[string]*FileInfo)
+	verifyErr := e.verifyDestination(ctx, destination)
+
+	// A snapshot is only trustworthy when every change was applied.
+	if e.snapshotPath != "" && !opts.DryRun && atomic.LoadInt64(&e.stats.ErrorsEncountered) == 0 {
+		if err := e.saveSnapshot(destination, destMap); err != nil {
+			e.errorHandler.AddError(ClassifySyncError("snapshot", e.snapshotPath, err))
+			atomic.AddInt64(&e.stats.ErrorsEncountered, 1)
+		}
+	}
+
+	complete = verifyErr == nil && e.guard.runError() == nil && atomic.LoadInt64(&e.stats.ErrorsEncountered) == 0
+	e.closeCheckpoint(complete)
+
+	stats := e.finishRun()
+	if err := e.guard.runError(); err != nil {
+		return stats, err
+	}
+
+	return stats, verifyErr
+}
+
+// scanSource lists the source, which is an archive's entries when the run
+// reads from one.
+func (e *SyncEngine) scanSource(ctx context.Context, source string) ([]*FileInfo, error) {
+	if e.fromArchive != nil {
+		return e.fromArchive.scan(ctx, e.sourceFilter(source))
+	}
+
+	return e.scanner.ScanWithFilter(ctx, source, e.sourceFilter(source))
+}
+
+// scanDestination lists the destination keyed by relative path. A missing
+// destination is treated as empty.
+func (e *SyncEngine) scanDestination(ctx context.Context, destination string) (map[string]*FileInfo, error) {
+	destFiles, err := e.scanner.ScanWithFilter(ctx, destination, e.countScanned)
+	if err != nil {
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			destFiles = []*FileInfo{}
+		case !e.recordIncompleteScan(err):
+			return nil, fmt.Errorf("failed to scan destination directory: %w", err)
+		}
+	}
+
+	destMap := make(map[string]*FileInfo, len(destFiles))
 
 	for _, file := range destFiles {
 		relPath, _ := filepath.Rel(destination, file.Path)
 		destMap[relPath] = file
 	}
 
-	var wg sync.WaitGroup
+	return destMap, nil
+}
 
-	semaphore := make(chan struct{}, opts.Workers)
-	if opts.Workers <= 0 {
-		semaphore = make(chan struct{}, e.scanner.maxConcurrency)
+// syncFileList syncs only the paths in opts.FileList, statting each one
+// directly and copying them one at a time in list order.
+func (e *SyncEngine) syncFileList(ctx context.Context, source, destination string, opts SyncOptions) (*SyncStats, error) {
+	if opts.DeleteExtraneous {
+		return e.GetStats(), ErrFileListDelete
 	}
 
+	sourceFiles := e.statFileList(source, opts.FileList, e.sourceFilter(source))
+	destMap := e.statDestinationList(destination, opts.FileList)
+
+	if err := e.runPreflight(e.planSync(source, sourceFiles, destMap, opts)); err != nil {
+		return e.GetStats(), err
+	}
+
+	err := e.syncFiles(ctx, sourceFiles, 1, func(file *FileInfo) error {
+		return e.syncFile(ctx, source, destination, file, destMap, opts)
+	})
+	if err != nil {
+		return e.GetStats(), err
+	}
+
+	if e.checksumMode != ChecksumFilesOff && !opts.DryRun {
+		e.writeChecksumFiles(destination, relativePaths(source, sourceFiles))
+	}
+
+	verifyErr := e.verifyDestination(ctx, destination)
+
+	stats := e.finishRun()
+	if err := e.guard.runError(); err != nil {
+		return stats, err
+	}
+
+	return stats, verifyErr
+}
+
+// syncFiles calls syncFile for each of sourceFiles with up to workers files in
+// flight. Files are started in slice order, so a single worker processes them
+// strictly in order.
+func (e *SyncEngine) syncFiles(ctx context.Context, sourceFiles []*FileInfo, workers int, syncFile func(file *FileInfo) error) error {
+	atomic.StoreInt64(&e.stats.FilesScanned, int64(len(sourceFiles)))
+	atomic.StoreInt64(&e.progress.Total, int64(len(sourceFiles)))
+
+	var wg sync.WaitGroup
+
+	semaphore := make(chan struct{}, workers)
+
 	for i, sourceFile := range sourceFiles {
 		select {
 		case <-ctx.Done():
-			return e.stats, ctx.Err()
+			wg.Wait()
+			return ctx.Err()
 		case semaphore <- struct{}{}:
 		}
 
 		wg.Add(1)
 
 		go func(file *FileInfo, _ int) {
+			atomic.AddInt64(&e.activeFiles, 1)
+
 			defer func() {
+				atomic.AddInt64(&e.activeFiles, -1)
 				<-semaphore
 				wg.Done()
 				atomic.AddInt64(&e.progress.Current, 1)
 				e.updateProgress(file.Path)
 			}()
 
-			if err := e.syncFile(ctx, source, destination, file, destMap, opts); err != nil {
+			if err := syncFile(file); err != nil {
 				atomic.AddInt64(&e.stats.ErrorsEncountered, 1)
 			}
 		}(sourceFile, i)
@@ -125,10 +528,247 @@ func (e *SyncEngine) Sync(ctx context.Context, source, destination string, opts
 
 	wg.Wait()
 
-	e.stats.EndTime = time.Now()
-	e.stats.Duration = e.stats.EndTime.Sub(e.stats.StartTime)
+	return nil
+}
+
+// recordIncompleteScan records the unreadable entries of an incomplete scan
+// as errors. It reports false when err is not an *IncompleteScanError.
+func (e *SyncEngine) recordIncompleteScan(err error) bool {
+	var incomplete *IncompleteScanError
+	if !errors.As(err, &incomplete) {
+		return false
+	}
+
+	for _, failure := range incomplete.Failures {
+		e.errorHandler.AddError(ClassifySyncError("scan", failure.Path, failure.Err))
+		atomic.AddInt64(&e.stats.ErrorsEncountered, 1)
+	}
+
+	return true
+}
+
+// extraneousPaths returns the destination entries that have no counterpart
+// in the source listing, longest paths first so that directory contents come
+// before the directory.
+func (e *SyncEngine) extraneousPaths(inSource map[string]struct{}, destMap map[string]*FileInfo) []string {
+	var extraneous []string
 
-	return e.stats, nil
+	for relPath := range destMap {
+		if _, exists := inSource[relPath]; !exists && !e.checksumFileOf(relPath, inSource) {
+			extraneous = append(extraneous, relPath)
+		}
+	}
+
+	sort.Slice(extraneous, func(i, j int) bool {
+		return len(extraneous[i]) > len(extraneous[j])
+	})
+
+	return extraneous
+}
+
+// deleteExtraneous removes the extraneous destination entries, in order, and
+// returns those it removed. Because a scan can be incomplete or filtered,
+// each candidate is first confirmed absent with a direct lstat of the source
+// path; anything that still exists, or whose existence cannot be determined,
+// is kept. Deletions and failures are counted in stats.
+func (e *SyncEngine) deleteExtraneous(ctx context.Context, source, destination string, extraneous []string, destMap map[string]*FileInfo, stats *SyncStats, opts SyncOptions) ([]string, error) {
+	var deleted []string
+
+	for _, relPath := range extraneous {
+		if err := ctx.Err(); err != nil {
+			return deleted, err
+		}
+
+		sourcePath := filepath.Join(source, relPath)
+		if err := e.lstatSource(sourcePath); !errors.Is(err, fs.ErrNotExist) {
+			if err != nil {
+				e.errorHandler.AddError(ClassifySyncError("verify-delete", sourcePath, err))
+				atomic.AddInt64(&stats.ErrorsEncountered, 1)
+			}
+
+			continue
+		}
+
+		destPath := filepath.Join(destination, relPath)
+
+		if !opts.DryRun {
+			err := e.guard.check("delete", destPath)
+			if err == nil {
+				err = os.Remove(toExtendedPath(destPath))
+			}
+
+			switch {
+			case err == nil:
+				e.recordRemoved(relPath)
+			case errors.Is(err, fs.ErrNotExist):
+				// Already gone, e.g. listed by a stale snapshot.
+				e.recordRemoved(relPath)
+				continue
+			case destMap[relPath].IsDir && isDirNotEmpty(err):
+				// Directories that still hold kept entries are left in place.
+				continue
+			default:
+				e.errorHandler.AddError(ClassifySyncError("delete", destPath, err))
+				atomic.AddInt64(&stats.ErrorsEncountered, 1)
+
+				continue
+			}
+		}
+
+		atomic.AddInt64(&stats.FilesDeleted, 1)
+
+		deleted = append(deleted, relPath)
+		e.audit(ChangeDelete, destPath, "", destMap[relPath], nil)
+
+		if file := destMap[relPath]; !file.IsDir {
+			atomic.AddInt64(&stats.BytesDeleted, file.Size)
+			atomic.AddInt64(&stats.NetBytesChange, -file.Size)
+			e.recordOperation(ChangeDelete, relPath, file.Size)
+		} else {
+			e.recordOperation(ChangeDelete, relPath, 0)
+		}
+	}
+
+	return deleted, nil
+}
+
+// relativePaths returns the set of files' paths relative to root.
+func relativePaths(root string, files []*FileInfo) map[string]struct{} {
+	paths := make(map[string]struct{}, len(files))
+
+	for _, file := range files {
+		relPath, err := filepath.Rel(root, file.Path)
+		if err == nil {
+			paths[relPath] = struct{}{}
+		}
+	}
+
+	return paths
+}
+
+// sizeChange returns how much the destination grows when dest (nil if
+// missing) is replaced by a copy of source. Directory sizes are ignored.
+func sizeChange(source, dest *FileInfo) int64 {
+	var change int64
+	if !source.IsDir {
+		change = source.Size
+	}
+
+	if dest != nil && !dest.IsDir {
+		change -= dest.Size
+	}
+
+	return change
+}
+
+// ErrSamePath is returned when the source and destination resolve to the same
+// directory, which would make a sync a confusing no-op at best.
+var ErrSamePath = errors.New("source and destination are the same path")
+
+// checkDistinctPaths rejects a source and destination that refer to the same
+// location after cleaning the paths and resolving symlinks.
+func checkDistinctPaths(source, destination string) error {
+	resolvedSource, err := resolvePath(source)
+	if err != nil {
+		return fmt.Errorf("failed to resolve source path: %w", err)
+	}
+
+	resolvedDestination, err := resolvePath(destination)
+	if err != nil {
+		return fmt.Errorf("failed to resolve destination path: %w", err)
+	}
+
+	if resolvedSource == resolvedDestination {
+		return fmt.Errorf("%w: %s", ErrSamePath, resolvedSource)
+	}
+
+	return nil
+}
+
+// resolvePath returns the absolute, symlink-free form of path. Paths that do
+// not exist yet are resolved through their nearest existing ancestor.
+func resolvePath(path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	resolved, err := filepath.EvalSymlinks(absPath)
+	if err == nil {
+		return resolved, nil
+	}
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+
+	parent := filepath.Dir(absPath)
+	if parent == absPath {
+		return absPath, nil
+	}
+
+	resolvedParent, err := resolvePath(parent)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(resolvedParent, filepath.Base(absPath)), nil
+}
+
+// countScanned records scan progress so long scans show signs of life.
+func (e *SyncEngine) countScanned(_ string, _ *FileInfo) bool {
+	atomic.AddInt64(&e.progress.Scanned, 1)
+	return true
+}
+
+// sourceFilter returns a FilterFunc that applies the engine's FileFilter,
+// including the ignore files in source and its smart exclusions, to paths
+// under source and records exclusions. An unusable ignore file is recorded as
+// an error and excludes nothing.
+func (e *SyncEngine) sourceFilter(source string) FilterFunc {
+	var ignores *ignoreFiles
+
+	smart := e.filter.smartExcludesIn(source)
+
+	// Ignore files are read from disk, so an archive has none.
+	if e.fromArchive == nil {
+		ignores = e.filter.ignoreFilesIn(source, func(path string, err error) {
+			e.errorHandler.AddError(ClassifySyncError("ignore", path, err))
+			atomic.AddInt64(&e.stats.ErrorsEncountered, 1)
+		})
+	}
+
+	return func(path string, info *FileInfo) bool {
+		e.countScanned(path, info)
+
+		relPath, err := filepath.Rel(source, path)
+		if err != nil {
+			relPath = path
+		}
+
+		decision := e.filter.Evaluate(relPath, info)
+		if decision == FilterInclude && ignores != nil && ignores.excluded(relPath, info.IsDir) {
+			decision = FilterExcludePattern
+		}
+
+		if decision == FilterInclude && smart != nil && smart.excluded(relPath, info.IsDir) {
+			decision = FilterExcludePattern
+		}
+
+		switch decision {
+		case FilterExcludeSize:
+			atomic.AddInt64(&e.stats.ExcludedBySize, 1)
+			return false
+		case FilterExcludePattern:
+			atomic.AddInt64(&e.stats.ExcludedByPattern, 1)
+			return false
+		case FilterExcludeTime:
+			atomic.AddInt64(&e.stats.ExcludedByTime, 1)
+			return false
+		default:
+			return true
+		}
+	}
 }
 
 func (e *SyncEngine) syncFile(ctx context.Context, source, destination string, sourceFile *FileInfo, destMap map[string]*FileInfo, opts SyncOptions) error {
@@ -140,40 +780,42 @@ func (e *SyncEngine) syncFile(ctx context.Context, source, destination string, s
 	destPath := filepath.Join(destination, relPath)
 	destFile, exists := destMap[relPath]
 
+	// Identical content is never a conflict; only its metadata may be stale.
+	if exists && contentMatches(sourceFile, destFile) {
+		return e.syncMetadata(relPath, destPath, sourceFile, destFile, e.stats, opts)
+	}
+
 	needsSync := !exists
 	if exists {
 		needsSync = e.needsSync(sourceFile, destFile, opts)
+	}
+
+	if !needsSync {
+		e.recordVerified(relPath, sourceFile)
+		return nil
+	}
+
+	// A deferred file is left alone, so its conflict is only resolved, and
+	// counted, backed up or asked about, in the run that copies it.
+	if !sourceFile.IsDir && !e.reserveCopy(opts) {
+		atomic.AddInt64(&e.stats.FilesDeferred, 1)
+		return nil
+	}
 
-		// Check for conflicts
-		if needsSync {
-			if conflict := e.resolver.DetectConflict(sourceFile, destFile); conflict != nil {
-				atomic.AddInt64(&e.stats.ConflictsFound, 1)
-
-				resolution, err := e.resolver.ResolveConflict(ctx, conflict)
-				if err != nil {
-					return fmt.Errorf("failed to resolve conflict for %s: %w", sourceFile.Path, err)
-				}
-
-				switch resolution {
-				case ResolutionSkip:
-					return nil
-				case ResolutionUseDestination:
-					return nil
-				case ResolutionBackupAndUseSource:
-					if _, err := e.resolver.CreateBackup(destPath); err != nil {
-						return fmt.Errorf("failed to create backup: %w", err)
-					}
-
-					atomic.AddInt64(&e.stats.ConflictsResolved, 1)
-				case ResolutionUseSource:
-					atomic.AddInt64(&e.stats.ConflictsResolved, 1)
-				}
+	if exists {
+		overwrite, err := e.resolveConflict(ctx, relPath, destPath, sourceFile, destFile, e.stats, opts)
+		if err != nil || !overwrite {
+			if !sourceFile.IsDir {
+				e.releaseCopy(opts)
 			}
+
+			return err
 		}
 	}
 
-	if !needsSync {
-		return nil
+	changeType := ChangeCreate
+	if exists {
+		changeType = ChangeModify
 	}
 
 	if opts.DryRun {
@@ -183,31 +825,66 @@ func (e *SyncEngine) syncFile(ctx context.Context, source, destination string, s
 			atomic.AddInt64(&e.stats.FilesCreated, 1)
 		}
 
+		atomic.AddInt64(&e.stats.NetBytesChange, sizeChange(sourceFile, destFile))
+		e.recordOperation(changeType, relPath, sourceFile.Size)
+
 		return nil
 	}
 
 	if sourceFile.IsDir {
-		if err := os.MkdirAll(destPath, os.FileMode(sourceFile.Mode)); err != nil {
+		if err := e.guard.check("create directory", destPath); err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(toExtendedPath(destPath), os.FileMode(sourceFile.Mode)); err != nil {
 			return fmt.Errorf("failed to create directory %s: %w", destPath, err)
 		}
 
+		e.recordWritten(relPath, destPath, sourceFile)
+		e.recordOperation(changeType, relPath, 0)
+		e.audit(changeType, destPath, "", destFile, sourceFile)
 		atomic.AddInt64(&e.stats.FilesCreated, 1)
 
 		return nil
 	}
 
-	copyErr := e.retryManager.ExecuteWithRetry(ctx, func() error {
-		return e.copier.CopyFile(ctx, sourceFile.Path, destPath)
-	})
-	if copyErr != nil {
-		syncErr := ClassifySyncError("copy", sourceFile.Path, copyErr)
-		e.errorHandler.AddError(syncErr)
-		atomic.AddInt64(&e.stats.ErrorsEncountered, 1)
+	if opts.Quarantine {
+		if err := e.recheckSource(sourceFile); err != nil {
+			return e.quarantineSource(sourceFile, err)
+		}
+	}
 
-		return fmt.Errorf("failed to copy file %s to %s after retries: %w", sourceFile.Path, destPath, copyErr)
+	if opts.AppendOnly && exists && !destFile.IsDir && destFile.Size < sourceFile.Size {
+		// Falls through to a full copy when the destination is not a prefix
+		// of the source or the append fails.
+		if appended, err := e.copier.AppendFile(ctx, sourceFile.Path, destPath); err == nil {
+			e.recordWritten(relPath, destPath, sourceFile)
+			e.recordVerified(relPath, sourceFile)
+			e.recordOperation(ChangeModify, relPath, appended)
+			e.audit(ChangeModify, destPath, "", destFile, sourceFile)
+			atomic.AddInt64(&e.stats.BytesTransferred, appended)
+			atomic.AddInt64(&e.stats.NetBytesChange, sizeChange(sourceFile, destFile))
+			atomic.AddInt64(&e.stats.FilesModified, 1)
+			atomic.AddInt64(&e.stats.FilesChanged, 1)
+
+			return nil
+		}
 	}
 
+	if err := e.copyWithRetry(ctx, sourceFile.Path, destPath); err != nil {
+		if errors.Is(err, errSourceVanished) {
+			return nil
+		}
+
+		return err
+	}
+
+	e.recordWritten(relPath, destPath, sourceFile)
+	e.recordVerified(relPath, sourceFile)
+	e.recordOperation(changeType, relPath, sourceFile.Size)
+	e.audit(changeType, destPath, "", destFile, sourceFile)
 	atomic.AddInt64(&e.stats.BytesTransferred, sourceFile.Size)
+	atomic.AddInt64(&e.stats.NetBytesChange, sizeChange(sourceFile, destFile))
 
 	if exists {
 		atomic.AddInt64(&e.stats.FilesModified, 1)
@@ -220,17 +897,249 @@ func (e *SyncEngine) syncFile(ctx context.Context, source, destination string, s
 	return nil
 }
 
+// resolveConflict checks whether replacing destFile, at relPath in the
+// destination, with sourceFile is a conflict and, if so, resolves it,
+// counting it in stats. It reports whether
+// the destination should be overwritten. With opts.ProtectDestNewer, a
+// destination newer than its source is kept whatever the strategy.
+func (e *SyncEngine) resolveConflict(ctx context.Context, relPath, destPath string, sourceFile, destFile *FileInfo, stats *SyncStats, opts SyncOptions) (bool, error) {
+	if opts.ProtectDestNewer && checkDestNewer(sourceFile, destFile, opts) {
+		atomic.AddInt64(&stats.DestNewerKept, 1)
+		return false, nil
+	}
+
+	conflict := e.resolver.DetectConflict(sourceFile, destFile)
+	if conflict == nil {
+		return true, nil
+	}
+
+	atomic.AddInt64(&stats.ConflictsFound, 1)
+	e.emit(Event{Type: EventConflictDetected, Path: relPath, Conflict: conflict.Conflict.String()})
+
+	resolution, err := e.resolver.ResolveConflict(ctx, conflict)
+	if err != nil {
+		return false, fmt.Errorf("failed to resolve conflict for %s: %w", sourceFile.Path, err)
+	}
+
+	switch resolution {
+	case ResolutionSkip, ResolutionUseDestination:
+		return false, nil
+	case ResolutionBackupAndUseSource:
+		if err := e.guard.check("back up", destPath); err != nil {
+			return false, err
+		}
+
+		if _, err := e.resolver.CreateBackup(destPath); err != nil {
+			return false, fmt.Errorf("failed to create backup: %w", err)
+		}
+
+		atomic.AddInt64(&stats.ConflictsResolved, 1)
+	case ResolutionUseSource:
+		atomic.AddInt64(&stats.ConflictsResolved, 1)
+	}
+
+	return true, nil
+}
+
+// reserveCopy claims one of the opts.MaxFiles copies allowed per run. Once
+// they are used up, remaining out-of-date files are deferred: they are still
+// compared, so the run can report how many are left, but not copied.
+// Directories are always created and do not count toward the limit.
+func (e *SyncEngine) reserveCopy(opts SyncOptions) bool {
+	if opts.MaxFiles <= 0 {
+		return true
+	}
+
+	for {
+		started := atomic.LoadInt64(&e.filesStarted)
+		if started >= opts.MaxFiles {
+			return false
+		}
+
+		if atomic.CompareAndSwapInt64(&e.filesStarted, started, started+1) {
+			return true
+		}
+	}
+}
+
+// releaseCopy gives back a copy claimed by reserveCopy that was not made.
+func (e *SyncEngine) releaseCopy(opts SyncOptions) {
+	if opts.MaxFiles > 0 {
+		atomic.AddInt64(&e.filesStarted, -1)
+	}
+}
+
+// copyWithRetry copies src to dst under the retry policy and records a
+// SyncError with enough detail to replay the copy if every attempt fails.
+func (e *SyncEngine) copyWithRetry(ctx context.Context, src, dst string) error {
+	ctx, cancel := e.fileContext(ctx)
+	defer cancel()
+
+	copyErr := e.retryManager.ExecuteWithRetry(ctx, func() error {
+		err := e.copySource(ctx, src, dst)
+		if err != nil && (sourceVanished(src, err) || errors.Is(err, ErrReadOnly) || errors.Is(err, errArchiveEscape)) {
+			// Retrying cannot bring a deleted source back, make the
+			// destination writable or move a symlink out of the way.
+			return NewRetryableError(err, false)
+		}
+
+		return err
+	})
+	if copyErr != nil {
+		if sourceVanished(src, copyErr) {
+			e.recordVanished(src)
+			return errSourceVanished
+		}
+
+		copyErr = e.fileTimeoutError(ctx, copyErr)
+
+		syncErr := ClassifySyncError("copy", src, copyErr)
+		syncErr.Destination = dst
+		e.errorHandler.AddError(syncErr)
+		atomic.AddInt64(&e.stats.ErrorsEncountered, 1)
+
+		return fmt.Errorf("failed to copy file %s to %s after retries: %w", src, dst, copyErr)
+	}
+
+	return nil
+}
+
+// ErrFileTimeout marks a copy stopped because it took longer than
+// SyncOptions.Timeout. The run carries on with the remaining files.
+var ErrFileTimeout = errors.New("file copy timed out")
+
+// fileContext returns the context for copying one file, retries included,
+// which expires after the per-file timeout when one is set.
+func (e *SyncEngine) fileContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if e.fileTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeoutCause(ctx, e.fileTimeout, ErrFileTimeout)
+}
+
+// fileTimeoutError wraps err with ErrFileTimeout when fileCtx expired because
+// of the per-file timeout, rather than because the run itself was stopped.
+func (e *SyncEngine) fileTimeoutError(fileCtx context.Context, err error) error {
+	if !errors.Is(context.Cause(fileCtx), ErrFileTimeout) {
+		return err
+	}
+
+	return fmt.Errorf("%w after %v: %w", ErrFileTimeout, e.fileTimeout, err)
+}
+
+// errSourceVanished is returned by copyWithRetry when the source file was
+// deleted after it was scanned. Callers treat it as a skip, not a failure.
+var errSourceVanished = errors.New("source file vanished")
+
+// sourceVanished reports whether err comes from a source file that no longer
+// exists, as opposed to a missing destination directory.
+func sourceVanished(src string, err error) bool {
+	if !errors.Is(err, fs.ErrNotExist) {
+		return false
+	}
+
+	_, statErr := os.Lstat(toExtendedPath(src))
+
+	return errors.Is(statErr, fs.ErrNotExist)
+}
+
+func (e *SyncEngine) recordVanished(path string) {
+	atomic.AddInt64(&e.stats.FilesVanished, 1)
+
+	e.mu.Lock()
+	e.vanished = append(e.vanished, path)
+	e.mu.Unlock()
+}
+
+// VanishedFiles returns the source files that disappeared between the scan
+// and the copy in the last run.
+func (e *SyncEngine) VanishedFiles() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return slices.Clone(e.vanished)
+}
+
+// RetryFailed re-attempts the copies recorded in a previous run's error log
+// without rescanning either tree. Entries that cannot be replayed, such as
+// those without a destination, are skipped. Copies that fail again are
+// collected as errors so a fresh error log can be written.
+func (e *SyncEngine) RetryFailed(ctx context.Context, failed []*SyncError) error {
+	if err := e.startRun(false); err != nil {
+		return err
+	}
+
+	defer e.endRun()
+
+	atomic.StoreInt64(&e.progress.Total, int64(len(failed)))
+
+	var stillFailing int
+
+	for _, entry := range failed {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		atomic.AddInt64(&e.progress.Current, 1)
+		e.updateProgress(entry.Path)
+
+		if entry.Operation != "copy" || entry.Destination == "" {
+			continue
+		}
+
+		atomic.AddInt64(&e.stats.FilesScanned, 1)
+
+		info, err := os.Stat(entry.Path)
+		if err != nil {
+			syncErr := ClassifySyncError("stat", entry.Path, err)
+			syncErr.Destination = entry.Destination
+			e.errorHandler.AddError(syncErr)
+			atomic.AddInt64(&e.stats.ErrorsEncountered, 1)
+
+			stillFailing++
+
+			continue
+		}
+
+		if err := e.copyWithRetry(ctx, entry.Path, entry.Destination); err != nil {
+			if !errors.Is(err, errSourceVanished) {
+				stillFailing++
+			}
+
+			continue
+		}
+
+		atomic.AddInt64(&e.stats.BytesTransferred, info.Size())
+		atomic.AddInt64(&e.stats.FilesModified, 1)
+		atomic.AddInt64(&e.stats.FilesChanged, 1)
+	}
+
+	e.finishRun()
+
+	if err := e.guard.runError(); err != nil {
+		return err
+	}
+
+	if stillFailing > 0 {
+		return fmt.Errorf("%d of %d failed files could not be copied", stillFailing, len(failed))
+	}
+
+	return nil
+}
+
 func (e *SyncEngine) needsSync(source, dest *FileInfo, opts SyncOptions) bool {
 	if source.Size != dest.Size {
 		return true
 	}
 
-	if !source.ModTime.Equal(dest.ModTime) {
+	// ModifyWindow tolerates coarse timestamps and clock skew between hosts.
+	if source.ModTime.Sub(dest.ModTime).Abs() > opts.ModifyWindow {
 		return true
 	}
 
-	if opts.ChecksumVerify && source.Checksum != "" && dest.Checksum != "" {
-		return source.Checksum != dest.Checksum
+	if opts.ChecksumVerify {
+		return checksumsDiffer(source, dest)
 	}
 
 	return false
@@ -270,19 +1179,27 @@ func (e *SyncEngine) Watch(ctx context.Context, configPath string) error {
 }
 
 func (e *SyncEngine) handleWatchEvents(ctx context.Context, profile *config.Profile) {
+	dirs := make(dirTimes)
+
+	ticker := time.NewTicker(watchDirTimesInterval)
+	defer ticker.Stop()
+
 	for {
 		select {
 		case <-ctx.Done():
+			e.restoreDirTimes(dirs, profile.Source, profile.Destination)
 			return
 		case event := <-e.watcher.Events():
-			e.handleChangeEvent(ctx, event, profile)
+			e.handleChangeEvent(ctx, event, profile, dirs)
+		case <-ticker.C:
+			e.restoreDirTimes(dirs, profile.Source, profile.Destination)
 		case err := <-e.watcher.Errors():
-			fmt.Printf("Watcher error: %v\n", err)
+			e.watchError("Watcher error", "", err)
 		}
 	}
 }
 
-func (e *SyncEngine) handleChangeEvent(ctx context.Context, event ChangeEvent, profile *config.Profile) {
+func (e *SyncEngine) handleChangeEvent(ctx context.Context, event ChangeEvent, profile *config.Profile, dirs dirTimes) {
 	relPath, err := filepath.Rel(profile.Source, event.Path)
 	if err != nil {
 		return
@@ -292,16 +1209,64 @@ func (e *SyncEngine) handleChangeEvent(ctx context.Context, event ChangeEvent, p
 
 	switch event.Type {
 	case ChangeCreate, ChangeModify:
-		if event.Info != nil && !event.Info.IsDir {
+		switch {
+		case event.Info == nil:
+		case event.Info.IsDir && event.Type == ChangeCreate:
+			if err := e.mirrorNewDirectory(ctx, event.Path, profile.Source, profile.Destination, dirs); err != nil {
+				e.watchError("Failed to sync directory", event.Path, err)
+			}
+		case event.Info.IsDir:
+			dirs[relPath] = struct{}{}
+		default:
+			dirs.touch(relPath)
+
 			if err := e.copier.CopyFile(ctx, event.Path, destPath); err != nil {
-				fmt.Printf("Failed to sync file %s: %v\n", event.Path, err)
+				e.watchError("Failed to sync file", event.Path, err)
 			}
 		}
 	case ChangeDelete:
-		if err := os.Remove(destPath); err != nil && !os.IsNotExist(err) {
-			fmt.Printf("Failed to delete file %s: %v\n", destPath, err)
+		dirs.touch(relPath)
+
+		if info, err := os.Lstat(toExtendedPath(destPath)); err == nil && info.IsDir() {
+			_ = e.watcher.Remove(event.Path)
+
+			if err := e.removeWatched(destPath, os.RemoveAll); err != nil {
+				e.watchError("Failed to delete directory", destPath, err)
+			}
+
+			return
 		}
+
+		if err := e.removeWatched(destPath, os.Remove); err != nil && !os.IsNotExist(err) {
+			e.watchError("Failed to delete file", destPath, err)
+		}
+	}
+}
+
+// watchError reports an error of watch mode about path, which is empty when
+// the error is not about a file, on stdout and in the log.
+func (e *SyncEngine) watchError(message, path string, err error) {
+	if path == "" {
+		fmt.Printf("%s: %v\n", message, err)
+	} else {
+		fmt.Printf("%s %s: %v\n", message, path, err)
+	}
+
+	e.mu.RLock()
+	logger := e.logger
+	e.mu.RUnlock()
+
+	logger.Error(message, "path", path, "error", err)
+}
+
+// removeWatched deletes destPath with remove, unless the destination is
+// read-only.
+func (e *SyncEngine) removeWatched(destPath string, remove func(string) error) error {
+	if err := e.guard.check("delete", destPath); err != nil {
+		return err
 	}
+
+	return remove(toExtendedPath(destPath))
 }
 
 // GetStats returns a snapshot of the current synchronization statistics.
@@ -309,7 +1274,7 @@ func (e *SyncEngine) GetStats() *SyncStats {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
-	statsCopy := *e.stats
+	statsCopy := e.loadStats()
 
 	return &statsCopy
 }
@@ -319,7 +1284,7 @@ func (e *SyncEngine) GetProgress() *Progress {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
-	progressCopy := *e.progress
+	progressCopy := e.progress.load()
 
 	return &progressCopy
 }
@@ -329,39 +1294,142 @@ func (e *SyncEngine) GetErrors() []*SyncError {
 	return e.errorHandler.GetErrors()
 }
 
+// WriteErrorLog writes the collected synchronization errors to path as JSON.
+func (e *SyncEngine) WriteErrorLog(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create error log %s: %w", path, err)
+	}
+
+	if err := e.errorHandler.WriteJSON(file); err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close error log %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// RunReport is the outcome of a run: its statistics, the changed files when
+// the change list is kept in memory, each destination's statistics after a
+// fan-out mirror, and the errors collected.
+type RunReport struct {
+	*SyncStats
+	ChangedFiles []FileOperation     `json:"changedFiles,omitempty"`
+	Destinations []DestinationReport `json:"destinations,omitempty"`
+	Errors       []*SyncError        `json:"errors,omitempty"`
+}
+
+// DestinationReport is the outcome of a fan-out mirror for one destination.
+type DestinationReport struct {
+	Destination string `json:"destination"`
+	Error       string `json:"error,omitempty"`
+	*SyncStats
+}
+
+// Report returns the outcome of the last run. Its errors carry recovery
+// suggestions, as in the error log.
+func (e *SyncEngine) Report() *RunReport {
+	report := &RunReport{
+		SyncStats:    e.GetStats(),
+		ChangedFiles: e.GetChangedFiles(),
+		Errors:       e.errorHandler.GetErrorsWithSuggestions(),
+	}
+
+	for _, result := range e.fanOutResults() {
+		destination := DestinationReport{Destination: result.Destination, SyncStats: result.Stats}
+		if result.Err != nil {
+			destination.Error = result.Err.Error()
+		}
+
+		report.Destinations = append(report.Destinations, destination)
+	}
+
+	return report
+}
+
+// WriteStatsFile writes the statistics of the last run as JSON to path: its
+// Report, without the errors, which WriteErrorLog writes.
+func (e *SyncEngine) WriteStatsFile(path string) error {
+	report := e.Report()
+	report.Errors = nil
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode stats: %w", err)
+	}
+
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write stats file %s: %w", path, err)
+	}
+
+	return nil
+}
+
 // GetErrorSummary returns a summary count of errors by category.
 func (e *SyncEngine) GetErrorSummary() map[ErrorCategory]int {
 	return e.errorHandler.GetSummary()
 }
 
+// DominantErrorCategory returns the most frequent category among collected
+// errors. It reports false when no errors were collected.
+func (e *SyncEngine) DominantErrorCategory() (ErrorCategory, bool) {
+	return e.errorHandler.DominantCategory()
+}
+
 // ClearErrors clears all accumulated synchronization errors.
 func (e *SyncEngine) ClearErrors() {
 	e.errorHandler.Clear()
 }
 
+// resetStats replaces the statistics, progress and per-run state. Workers
+// update e.stats and e.progress without the lock, so this must only be called
+// from startRun, before the run's workers start and after the previous run's
+// have finished.
 func (e *SyncEngine) resetStats() {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
 	e.stats = &SyncStats{}
 	e.progress = &Progress{}
+	e.vanished = nil
+	e.destChanges = make(map[string]*FileInfo)
+	e.activity = nil
+	e.activityNext = 0
+	e.changes = nil
+	e.changeErr = nil
+	e.verified = make(map[string]*FileInfo)
+	e.fanOut = nil
+	e.plan = nil
+	e.samples = nil
+	atomic.StoreInt64(&e.filesStarted, 0)
 }
 
 func (e *SyncEngine) updateProgress(currentFile string) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	current := atomic.LoadInt64(&e.progress.Current)
+	total := atomic.LoadInt64(&e.progress.Total)
+
 	e.progress.CurrentFile = currentFile
-	if e.progress.Total > 0 {
-		e.progress.Percentage = float64(e.progress.Current) / float64(e.progress.Total) * 100
+	if total > 0 {
+		e.progress.Percentage = float64(current) / float64(total) * 100
 	}
 
-	elapsed := time.Since(e.stats.StartTime)
-	if elapsed > 0 && e.progress.Current > 0 {
-		e.progress.Speed = int64(float64(e.stats.BytesTransferred) / elapsed.Seconds())
+	now := time.Now()
+	transferred := e.loadStats().BytesTransferred
+	e.recordRate(now, transferred)
+
+	elapsed := now.Sub(e.stats.StartTime)
+	if elapsed > 0 && current > 0 {
+		e.progress.Speed = int64(float64(transferred) / elapsed.Seconds())
 
 		if e.progress.Speed > 0 {
-			remaining := e.progress.Total - e.progress.Current
+			remaining := total - current
 			e.progress.ETA = time.Duration(float64(remaining) / float64(e.progress.Speed) * float64(time.Second))
 		}
 	}
@@ -0,0 +1,2028 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/howmanysmall/relay/src/internal/config"
+	"github.com/zeebo/blake3"
+)
+
+func TestSyncEngineRejectsIdenticalPaths(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	if err := os.Mkdir(sourceDir, 0o755); err != nil {
+		t.Fatalf("Failed to create source directory: %v", err)
+	}
+
+	linkDir := filepath.Join(tempDir, "link")
+	if err := os.Symlink(sourceDir, linkDir); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		destination string
+	}{
+		{name: "same path", destination: sourceDir},
+		{name: "unclean path", destination: filepath.Join(sourceDir, "..", "source") + "/"},
+		{name: "symlink to source", destination: linkDir},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			engine, err := NewSyncEngine()
+			if err != nil {
+				t.Fatalf("NewSyncEngine failed: %v", err)
+			}
+
+			err = engine.Mirror(context.Background(), sourceDir, tt.destination)
+			if !errors.Is(err, ErrSamePath) {
+				t.Errorf("Mirror() error = %v, want ErrSamePath", err)
+			}
+		})
+	}
+}
+
+func TestSyncEngineMirrorCreatesDestination(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	if err := os.MkdirAll(filepath.Join(sourceDir, "nested"), 0o755); err != nil {
+		t.Fatalf("Failed to create source directory: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(sourceDir, "nested", "file.txt"), []byte("content"), 0o644); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+
+	destDir := filepath.Join(tempDir, "missing", "dest")
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	if err := engine.Mirror(context.Background(), sourceDir, destDir); err != nil {
+		t.Fatalf("Mirror failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(destDir, "nested", "file.txt"))
+	if err != nil {
+		t.Fatalf("Failed to read mirrored file: %v", err)
+	}
+
+	if string(content) != "content" {
+		t.Errorf("Content mismatch: got %q, want %q", string(content), "content")
+	}
+
+	// source, source/nested and source/nested/file.txt; the destination
+	// did not exist yet.
+	if scanned := engine.GetProgress().Scanned; scanned != 3 {
+		t.Errorf("Scanned = %d, want 3", scanned)
+	}
+}
+
+func TestSyncEngineRetryFailed(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceFile := filepath.Join(tempDir, "source.txt")
+	if err := os.WriteFile(sourceFile, []byte("retry me"), 0o644); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+
+	destFile := filepath.Join(tempDir, "dest", "source.txt")
+
+	// Round-trip through the error log format to make sure it carries
+	// everything needed to replay the copy.
+	handler := NewErrorHandler(10)
+	failure := ClassifySyncError("copy", sourceFile, errors.New("connection reset"))
+	failure.Destination = destFile
+	handler.AddError(failure)
+	handler.AddError(ClassifySyncError("delete", filepath.Join(tempDir, "gone.txt"), errors.New("boom")))
+
+	var buf bytes.Buffer
+	if err := handler.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+
+	failed, err := ReadErrorLog(&buf)
+	if err != nil {
+		t.Fatalf("ReadErrorLog failed: %v", err)
+	}
+
+	if failed[0].Category != ErrorCategoryNetwork {
+		t.Errorf("Category = %v, want %v", failed[0].Category, ErrorCategoryNetwork)
+	}
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	if err := engine.RetryFailed(context.Background(), failed); err != nil {
+		t.Fatalf("RetryFailed failed: %v", err)
+	}
+
+	content, err := os.ReadFile(destFile)
+	if err != nil {
+		t.Fatalf("Failed to read retried file: %v", err)
+	}
+
+	if string(content) != "retry me" {
+		t.Errorf("Content mismatch: got %q, want %q", string(content), "retry me")
+	}
+
+	if stats := engine.GetStats(); stats.FilesChanged != 1 {
+		t.Errorf("FilesChanged = %d, want 1", stats.FilesChanged)
+	}
+}
+
+func TestSyncEngineDeleteExtraneous(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	destDir := filepath.Join(tempDir, "dest")
+
+	files := map[string]string{
+		filepath.Join(sourceDir, "keep.txt"):             "keep",
+		filepath.Join(sourceDir, "large.bin"):            "too large to mirror",
+		filepath.Join(destDir, "keep.txt"):               "keep",
+		filepath.Join(destDir, "large.bin"):              "stale copy",
+		filepath.Join(destDir, "extra.txt"):              "extra",
+		filepath.Join(destDir, "old", "nested", "a.txt"): "old",
+	}
+
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	// large.bin is filtered out of the source scan, but it still exists in
+	// the source, so its destination copy must survive.
+	filter := NewFileFilter()
+	filter.SetSizeLimits(0, 8)
+	engine.SetFilter(filter)
+
+	opts := engine.Options()
+	opts.DeleteExtraneous = true
+	engine.SetOptions(opts)
+
+	if err := engine.Mirror(context.Background(), sourceDir, destDir); err != nil {
+		t.Fatalf("Mirror failed: %v", err)
+	}
+
+	for _, rel := range []string{"keep.txt", "large.bin"} {
+		if _, err := os.Stat(filepath.Join(destDir, rel)); err != nil {
+			t.Errorf("Expected %s to be kept: %v", rel, err)
+		}
+	}
+
+	for _, rel := range []string{"extra.txt", "old"} {
+		if _, err := os.Stat(filepath.Join(destDir, rel)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be deleted, got err = %v", rel, err)
+		}
+	}
+
+	// extra.txt, old, old/nested and old/nested/a.txt.
+	if stats := engine.GetStats(); stats.FilesDeleted != 4 {
+		t.Errorf("FilesDeleted = %d, want 4", stats.FilesDeleted)
+	}
+}
+
+func TestSyncEngineFileList(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	destDir := filepath.Join(tempDir, "dest")
+
+	for _, rel := range []string{"data/a.txt", "data/b.txt", "manifest.json", "unlisted.txt"} {
+		path := filepath.Join(sourceDir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+
+		if err := os.WriteFile(path, []byte(rel), 0o644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	list, err := ReadFileList(strings.NewReader("# data first\ndata/a.txt\n\ndata/b.txt\nmissing.txt\nmanifest.json\n"))
+	if err != nil {
+		t.Fatalf("ReadFileList failed: %v", err)
+	}
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	opts := engine.Options()
+	opts.FileList = list
+	engine.SetOptions(opts)
+
+	if err := engine.Mirror(context.Background(), sourceDir, destDir); err != nil {
+		t.Fatalf("Mirror failed: %v", err)
+	}
+
+	for _, rel := range []string{"data/a.txt", "data/b.txt", "manifest.json"} {
+		if _, err := os.Stat(filepath.Join(destDir, rel)); err != nil {
+			t.Errorf("Expected %s to be copied: %v", rel, err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(destDir, "unlisted.txt")); !os.IsNotExist(err) {
+		t.Errorf("Unlisted file should not be copied, got err = %v", err)
+	}
+
+	if stats := engine.GetStats(); stats.FilesChanged != 3 || stats.ErrorsEncountered != 1 {
+		t.Errorf("FilesChanged = %d, ErrorsEncountered = %d, want 3 and 1", stats.FilesChanged, stats.ErrorsEncountered)
+	}
+
+	opts.DeleteExtraneous = true
+	engine.SetOptions(opts)
+
+	if err := engine.Mirror(context.Background(), sourceDir, destDir); !errors.Is(err, ErrFileListDelete) {
+		t.Errorf("Mirror() error = %v, want ErrFileListDelete", err)
+	}
+}
+
+func TestReadFileList(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "relative paths", input: "a.txt\r\nsub/b.txt\n", want: []string{"a.txt", filepath.Join("sub", "b.txt")}},
+		{name: "leading slash", input: "/a.txt\n", want: []string{"a.txt"}},
+		{name: "comments", input: "; note\n# note\nc.txt\n", want: []string{"c.txt"}},
+		{name: "parent escape", input: "../secret\n", wantErr: true},
+		{name: "nested escape", input: "sub/../../secret\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ReadFileList(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadFileList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ReadFileList() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyncEngineMirrorAtomic(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	if err := os.Mkdir(sourceDir, 0o755); err != nil {
+		t.Fatalf("Failed to create source directory: %v", err)
+	}
+
+	liveDir := filepath.Join(tempDir, "live")
+	sourceFile := filepath.Join(sourceDir, "index.html")
+
+	var versions []string
+
+	for _, content := range []string{"v1", "v2"} {
+		if err := os.WriteFile(sourceFile, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write source file: %v", err)
+		}
+
+		engine, err := NewSyncEngine()
+		if err != nil {
+			t.Fatalf("NewSyncEngine failed: %v", err)
+		}
+
+		if err := engine.MirrorAtomic(context.Background(), sourceDir, liveDir); err != nil {
+			t.Fatalf("MirrorAtomic failed: %v", err)
+		}
+
+		got, err := os.ReadFile(filepath.Join(liveDir, "index.html"))
+		if err != nil {
+			t.Fatalf("Failed to read live file: %v", err)
+		}
+
+		if string(got) != content {
+			t.Errorf("Live content = %q, want %q", string(got), content)
+		}
+
+		target, err := os.Readlink(liveDir)
+		if err != nil {
+			t.Fatalf("Live path is not a symlink: %v", err)
+		}
+
+		versions = append(versions, filepath.Join(tempDir, target))
+	}
+
+	if _, err := os.Stat(versions[0]); !os.IsNotExist(err) {
+		t.Errorf("Previous version %s should be removed, got err = %v", versions[0], err)
+	}
+
+	realDir := filepath.Join(tempDir, "real")
+	if err := os.Mkdir(realDir, 0o755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	if err := engine.MirrorAtomic(context.Background(), sourceDir, realDir); !errors.Is(err, ErrLiveDirNotSymlink) {
+		t.Errorf("MirrorAtomic() error = %v, want ErrLiveDirNotSymlink", err)
+	}
+}
+
+func TestSyncEngineSetConcurrencyMultipliers(t *testing.T) {
+	t.Parallel()
+
+	procs := runtime.GOMAXPROCS(0)
+
+	tests := []struct {
+		name        string
+		scan        float64
+		copy        float64
+		wantScan    int64
+		wantWorkers int
+	}{
+		{name: "defaults", wantScan: int64(procs * 2), wantWorkers: procs},
+		{name: "network bound", scan: 1, copy: 8, wantScan: int64(procs), wantWorkers: procs * 8},
+		{name: "never below one", scan: 0.0001, copy: 0.0001, wantScan: 1, wantWorkers: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			engine, err := NewSyncEngine()
+			if err != nil {
+				t.Fatalf("NewSyncEngine failed: %v", err)
+			}
+
+			engine.SetConcurrencyMultipliers(tt.scan, tt.copy)
+
+			if engine.scanner.maxConcurrency != tt.wantScan {
+				t.Errorf("scan concurrency = %d, want %d", engine.scanner.maxConcurrency, tt.wantScan)
+			}
+
+			if engine.copier.workers != tt.wantWorkers {
+				t.Errorf("copy workers = %d, want %d", engine.copier.workers, tt.wantWorkers)
+			}
+		})
+	}
+}
+
+func TestSyncEngineVanishedSourceIsSkipped(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	destDir := filepath.Join(tempDir, "dest")
+
+	// Scanned, then deleted before the copy phase reached it.
+	vanished := &FileInfo{Path: filepath.Join(sourceDir, "build.tmp"), Size: 42}
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	err = engine.syncFile(context.Background(), sourceDir, destDir, vanished, map[string]*FileInfo{}, engine.Options())
+	if err != nil {
+		t.Fatalf("syncFile() error = %v, want nil for a vanished source", err)
+	}
+
+	stats := engine.GetStats()
+	if stats.FilesVanished != 1 || stats.ErrorsEncountered != 0 {
+		t.Errorf("FilesVanished = %d, ErrorsEncountered = %d, want 1 and 0", stats.FilesVanished, stats.ErrorsEncountered)
+	}
+
+	if got := engine.VanishedFiles(); !slices.Equal(got, []string{vanished.Path}) {
+		t.Errorf("VanishedFiles() = %v, want [%s]", got, vanished.Path)
+	}
+}
+
+func TestSyncEngineAppendOnly(t *testing.T) {
+	t.Parallel()
+
+	logData := bytes.Repeat([]byte("2025-01-01T00:00:00Z request served\n"), 8192)
+
+	tests := []struct {
+		name        string
+		destContent []byte
+		wantBytes   int64
+	}{
+		{name: "appends tail", destContent: logData[:200000], wantBytes: int64(len(logData) - 200000)},
+		{name: "rotated log is recopied", destContent: bytes.Repeat([]byte("x"), 200000), wantBytes: int64(len(logData))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tempDir := t.TempDir()
+
+			sourceFile := filepath.Join(tempDir, "source", "app.log")
+			destFile := filepath.Join(tempDir, "dest", "app.log")
+
+			for path, content := range map[string][]byte{sourceFile: logData, destFile: tt.destContent} {
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatalf("Failed to create directory: %v", err)
+				}
+
+				if err := os.WriteFile(path, content, 0o644); err != nil {
+					t.Fatalf("Failed to write file: %v", err)
+				}
+			}
+
+			// The destination is the older copy.
+			past := time.Now().Add(-time.Hour)
+			if err := os.Chtimes(destFile, past, past); err != nil {
+				t.Fatalf("Failed to set times: %v", err)
+			}
+
+			engine, err := NewSyncEngine()
+			if err != nil {
+				t.Fatalf("NewSyncEngine failed: %v", err)
+			}
+
+			opts := engine.Options()
+			opts.AppendOnly = true
+			engine.SetOptions(opts)
+
+			if err := engine.Mirror(context.Background(), filepath.Dir(sourceFile), filepath.Dir(destFile)); err != nil {
+				t.Fatalf("Mirror failed: %v", err)
+			}
+
+			got, err := os.ReadFile(destFile)
+			if err != nil {
+				t.Fatalf("Failed to read destination: %v", err)
+			}
+
+			if !bytes.Equal(got, logData) {
+				t.Errorf("Destination content differs from source")
+			}
+
+			if stats := engine.GetStats(); stats.BytesTransferred != tt.wantBytes {
+				t.Errorf("BytesTransferred = %d, want %d", stats.BytesTransferred, tt.wantBytes)
+			}
+		})
+	}
+}
+
+func TestSyncEngineMirrorsEmptyFiles(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	destDir := filepath.Join(tempDir, "dest")
+
+	emptyFiles := []string{".keep", filepath.Join("logs", "empty.log"), filepath.Join("a", "b", "__init__.py")}
+
+	for _, rel := range emptyFiles {
+		path := filepath.Join(sourceDir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatalf("Failed to create empty file: %v", err)
+		}
+	}
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	if err := engine.Mirror(context.Background(), sourceDir, destDir); err != nil {
+		t.Fatalf("Mirror failed: %v", err)
+	}
+
+	for _, rel := range emptyFiles {
+		info, err := os.Stat(filepath.Join(destDir, rel))
+		if err != nil {
+			t.Errorf("Expected empty file %s to be created: %v", rel, err)
+			continue
+		}
+
+		if !info.Mode().IsRegular() || info.Size() != 0 {
+			t.Errorf("%s: mode %v size %d, want an empty regular file", rel, info.Mode(), info.Size())
+		}
+	}
+
+	// Empty files carry no checksum, so size and modtime alone decide
+	// equality: a second run must not copy them again.
+	if err := engine.Mirror(context.Background(), sourceDir, destDir); err != nil {
+		t.Fatalf("Second mirror failed: %v", err)
+	}
+
+	if stats := engine.GetStats(); stats.FilesChanged != 0 {
+		t.Errorf("Second run: FilesChanged = %d, want 0", stats.FilesChanged)
+	}
+}
+
+func TestSyncEngineDestinationSnapshot(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	destDir := filepath.Join(tempDir, "dest")
+	snapshotPath := filepath.Join(tempDir, "cache", "dest.json")
+
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	writeTreeFile(t, filepath.Join(sourceDir, "a.txt"), "a", modTime)
+	writeTreeFile(t, filepath.Join(sourceDir, "sub", "b.txt"), "b", modTime)
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	engine.SetDestinationSnapshot(snapshotPath, false)
+	mirrorTree(t, engine, sourceDir, destDir)
+
+	if _, err := os.Stat(snapshotPath); err != nil {
+		t.Fatalf("Expected snapshot to be written: %v", err)
+	}
+
+	// Removing a nested file leaves the destination root untouched, so the
+	// snapshot is trusted and the file is not restored.
+	nested := filepath.Join(destDir, "sub", "b.txt")
+	removeTreePath(t, nested)
+	mirrorTree(t, engine, sourceDir, destDir)
+
+	if _, err := os.Stat(nested); !os.IsNotExist(err) {
+		t.Fatalf("Expected snapshot to hide nested removal, got err = %v", err)
+	}
+
+	engine.SetDestinationSnapshot(snapshotPath, true)
+	mirrorTree(t, engine, sourceDir, destDir)
+
+	if _, err := os.Stat(nested); err != nil {
+		t.Fatalf("Expected rescan to restore %s: %v", nested, err)
+	}
+
+	// A top-level change moves the root's modification time, which
+	// invalidates the snapshot.
+	engine.SetDestinationSnapshot(snapshotPath, false)
+	removeTreePath(t, nested)
+	writeTreeFile(t, filepath.Join(destDir, "extra.txt"), "extra", modTime)
+	mirrorTree(t, engine, sourceDir, destDir)
+
+	if _, err := os.Stat(nested); err != nil {
+		t.Fatalf("Expected stale snapshot to be ignored: %v", err)
+	}
+}
+
+func TestSyncEngineDryRunDeleteReportsFreedSpace(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	destDir := filepath.Join(tempDir, "dest")
+
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	writeTreeFile(t, filepath.Join(sourceDir, "keep.txt"), "keep", modTime)
+	writeTreeFile(t, filepath.Join(sourceDir, "new.txt"), "12345", modTime)
+	writeTreeFile(t, filepath.Join(destDir, "keep.txt"), "keep", modTime)
+	writeTreeFile(t, filepath.Join(destDir, "extra.txt"), "extra!", modTime)
+	writeTreeFile(t, filepath.Join(destDir, "old", "a.txt"), "old", modTime)
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	opts := engine.Options()
+	opts.DryRun = true
+	opts.DeleteExtraneous = true
+	engine.SetOptions(opts)
+
+	mirrorTree(t, engine, sourceDir, destDir)
+
+	stats := engine.GetStats()
+	if !stats.DryRun {
+		t.Error("DryRun = false, want true")
+	}
+
+	// extra.txt, old and old/a.txt; directories take no space.
+	if stats.FilesDeleted != 3 || stats.BytesDeleted != 9 {
+		t.Errorf("FilesDeleted = %d, BytesDeleted = %d; want 3, 9", stats.FilesDeleted, stats.BytesDeleted)
+	}
+
+	// new.txt adds 5 bytes, the deletions free 9.
+	if stats.NetBytesChange != -4 {
+		t.Errorf("NetBytesChange = %d, want -4", stats.NetBytesChange)
+	}
+
+	if _, err := os.Stat(filepath.Join(destDir, "extra.txt")); err != nil {
+		t.Errorf("Dry run removed extra.txt: %v", err)
+	}
+
+	statsPath := filepath.Join(tempDir, "stats.json")
+	if err := engine.WriteStatsFile(statsPath); err != nil {
+		t.Fatalf("WriteStatsFile failed: %v", err)
+	}
+
+	data, err := os.ReadFile(statsPath)
+	if err != nil {
+		t.Fatalf("Failed to read stats file: %v", err)
+	}
+
+	for _, want := range []string{`"bytesDeleted": 9`, `"netBytesChange": -4`, `"dryRun": true`} {
+		if !bytes.Contains(data, []byte(want)) {
+			t.Errorf("Stats file missing %s:\n%s", want, data)
+		}
+	}
+}
+
+func TestSyncEngineSetOptionsPreservation(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	destDir := filepath.Join(tempDir, "dest")
+
+	modTime := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	writeTreeFile(t, filepath.Join(sourceDir, "a.txt"), "a", modTime)
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	opts := engine.Options()
+	opts.PreserveTimes = false
+	opts.PreservePerms = false
+	engine.SetOptions(opts)
+
+	if engine.copier.preserveTimes || engine.copier.preservePerms {
+		t.Fatal("SetOptions did not disable preservation on the copier")
+	}
+
+	mirrorTree(t, engine, sourceDir, destDir)
+
+	info, err := os.Stat(filepath.Join(destDir, "a.txt"))
+	if err != nil {
+		t.Fatalf("Failed to stat copy: %v", err)
+	}
+
+	if info.ModTime().Equal(modTime) {
+		t.Errorf("ModTime = %v, want the copy time when times are not preserved", info.ModTime())
+	}
+}
+
+func TestSyncEngineQuarantinesUnstableSource(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	destDir := filepath.Join(tempDir, "dest")
+	sourcePath := filepath.Join(sourceDir, "photo.raw")
+
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	writeTreeFile(t, sourcePath, "original pixels", modTime)
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	files, err := engine.scanner.Scan(context.Background(), sourcePath)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	// Same size and modification time, different bytes: what bit rot looks
+	// like between the scan and the copy.
+	writeTreeFile(t, sourcePath, "flipped pixels!", modTime)
+
+	opts := engine.Options()
+	opts.Quarantine = true
+
+	err = engine.syncFile(context.Background(), sourceDir, destDir, files[0], map[string]*FileInfo{}, opts)
+	if !errors.Is(err, ErrSuspectedCorruption) {
+		t.Fatalf("syncFile() error = %v, want ErrSuspectedCorruption", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(destDir, "photo.raw")); !os.IsNotExist(err) {
+		t.Errorf("Quarantined file was copied, stat err = %v", err)
+	}
+
+	if stats := engine.GetStats(); stats.FilesQuarantined != 1 {
+		t.Errorf("FilesQuarantined = %d, want 1", stats.FilesQuarantined)
+	}
+
+	if category, ok := engine.DominantErrorCategory(); !ok || category != ErrorCategoryCorruption {
+		t.Errorf("DominantErrorCategory() = %v, %v; want Corruption", category, ok)
+	}
+
+	// An edit changes the modification time and is copied normally.
+	writeTreeFile(t, sourcePath, "edited pixels!!", modTime.Add(time.Minute))
+
+	if err := engine.syncFile(context.Background(), sourceDir, destDir, files[0], map[string]*FileInfo{}, opts); err != nil {
+		t.Fatalf("syncFile() after edit error = %v", err)
+	}
+}
+
+func TestSyncEngineMaxFilesBatches(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	destDir := filepath.Join(tempDir, "dest")
+
+	backupDir := filepath.Join(tempDir, "backups")
+
+	// Every destination file conflicts with its source, and each conflict
+	// is backed up.
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for i := range 5 {
+		name := fmt.Sprintf("file%d.txt", i)
+		writeTreeFile(t, filepath.Join(sourceDir, "batch", name), "data", modTime)
+		writeTreeFile(t, filepath.Join(destDir, "batch", name), "old", modTime.Add(-time.Hour))
+	}
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	engine.SetConflictConfig(&config.ConflictConfig{Strategy: "keep-newest:10", BackupDir: backupDir})
+
+	opts := engine.Options()
+	opts.MaxFiles = 2
+	engine.SetOptions(opts)
+
+	// Deferred files are not backed up until the run that copies them.
+	for run, want := range []struct{ changed, deferred, backups int64 }{{2, 3, 2}, {2, 1, 4}, {1, 0, 5}} {
+		mirrorTree(t, engine, sourceDir, destDir)
+
+		stats := engine.GetStats()
+		if stats.FilesChanged != want.changed || stats.FilesDeferred != want.deferred {
+			t.Errorf("Run %d: FilesChanged = %d, FilesDeferred = %d; want %d, %d",
+				run+1, stats.FilesChanged, stats.FilesDeferred, want.changed, want.deferred)
+		}
+
+		list, err := ListBackups(backupDir)
+		if err != nil {
+			t.Fatalf("ListBackups failed: %v", err)
+		}
+
+		var backups int64
+
+		for _, backup := range list {
+			if strings.HasPrefix(backup.Original, "file") {
+				backups++
+			}
+		}
+
+		if backups != want.backups {
+			t.Errorf("Run %d: %d file backups, want %d", run+1, backups, want.backups)
+		}
+	}
+
+	if got := readTree(t, filepath.Join(destDir, "batch")); len(got) != 5 || got["file4.txt"] != "data" {
+		t.Errorf("Destination = %v, want all 5 files copied", got)
+	}
+}
+
+func TestSyncEngineReserveCopyConcurrent(t *testing.T) {
+	t.Parallel()
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	opts := SyncOptions{MaxFiles: 1}
+
+	var (
+		wg       sync.WaitGroup
+		reserved atomic.Int64
+	)
+
+	for range 16 {
+		wg.Go(func() {
+			if engine.reserveCopy(opts) {
+				reserved.Add(1)
+			}
+		})
+	}
+
+	wg.Wait()
+
+	if reserved.Load() != 1 {
+		t.Fatalf("%d of 16 concurrent reservations succeeded, want 1", reserved.Load())
+	}
+
+	// A copy given back, say because its conflict was skipped, is free
+	// again however many reservations failed meanwhile.
+	engine.releaseCopy(opts)
+
+	if !engine.reserveCopy(opts) {
+		t.Error("reserveCopy failed after the only reservation was released")
+	}
+}
+
+func TestSyncEngineWatchMirrorsDirectories(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	destDir := filepath.Join(tempDir, "dest")
+	profile := &config.Profile{Source: sourceDir, Destination: destDir}
+
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	writeTreeFile(t, filepath.Join(sourceDir, "new", "nested", "file.txt"), "data", modTime)
+
+	for _, dir := range []string{filepath.Join(sourceDir, "new", "nested"), filepath.Join(sourceDir, "new")} {
+		if err := os.Chtimes(dir, modTime, modTime); err != nil {
+			t.Fatalf("Chtimes failed: %v", err)
+		}
+	}
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	ctx := context.Background()
+	dirs := make(dirTimes)
+	newDir := filepath.Join(sourceDir, "new")
+
+	engine.handleChangeEvent(ctx, ChangeEvent{
+		Type: ChangeCreate,
+		Path: newDir,
+		Info: &FileInfo{Path: newDir, IsDir: true},
+	}, profile, dirs)
+	engine.restoreDirTimes(dirs, sourceDir, destDir)
+
+	if got := readTree(t, destDir); got["new/nested/file.txt"] != "data" {
+		t.Errorf("Destination tree = %v, want new/nested/file.txt mirrored", got)
+	}
+
+	for _, rel := range []string{"new", filepath.Join("new", "nested")} {
+		info, err := os.Stat(filepath.Join(destDir, rel))
+		if err != nil {
+			t.Fatalf("Stat %s failed: %v", rel, err)
+		}
+
+		if !info.ModTime().Equal(modTime) {
+			t.Errorf("%s modtime = %v, want %v", rel, info.ModTime(), modTime)
+		}
+	}
+
+	emptyDir := filepath.Join(sourceDir, "empty")
+	if err := os.Mkdir(emptyDir, 0o700); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+
+	engine.handleChangeEvent(ctx, ChangeEvent{
+		Type: ChangeCreate,
+		Path: emptyDir,
+		Info: &FileInfo{Path: emptyDir, IsDir: true},
+	}, profile, dirs)
+
+	info, err := os.Stat(filepath.Join(destDir, "empty"))
+	if err != nil || !info.IsDir() {
+		t.Fatalf("Empty directory not mirrored (err = %v)", err)
+	}
+
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0o700 {
+		t.Errorf("Empty directory mode = %v, want %v", info.Mode().Perm(), os.FileMode(0o700))
+	}
+
+	if err := os.RemoveAll(newDir); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+
+	engine.handleChangeEvent(ctx, ChangeEvent{Type: ChangeDelete, Path: newDir}, profile, dirs)
+
+	if _, err := os.Stat(filepath.Join(destDir, "new")); !os.IsNotExist(err) {
+		t.Errorf("Destination directory still exists after delete (err = %v)", err)
+	}
+}
+
+func TestSyncEngineRecentOperations(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	destDir := filepath.Join(tempDir, "dest")
+
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	writeTreeFile(t, filepath.Join(sourceDir, "new.txt"), "fresh", modTime)
+	writeTreeFile(t, filepath.Join(destDir, "stale.txt"), "old", modTime)
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	opts := engine.Options()
+	opts.DeleteExtraneous = true
+	engine.SetOptions(opts)
+
+	mirrorTree(t, engine, sourceDir, destDir)
+
+	got := make(map[string]FileOperation)
+	for _, operation := range engine.RecentOperations() {
+		got[operation.Path] = operation
+	}
+
+	if op := got["new.txt"]; op.Type != ChangeCreate || op.Size != 5 {
+		t.Errorf("new.txt operation = %+v, want create of 5 bytes", op)
+	}
+
+	if op := got["stale.txt"]; op.Type != ChangeDelete || op.Size != 3 {
+		t.Errorf("stale.txt operation = %+v, want delete of 3 bytes", op)
+	}
+
+	for i := range activityLogSize + 10 {
+		engine.recordOperation(ChangeModify, fmt.Sprintf("file%d", i), 0)
+	}
+
+	operations := engine.RecentOperations()
+	if len(operations) != activityLogSize {
+		t.Fatalf("RecentOperations returned %d entries, want %d", len(operations), activityLogSize)
+	}
+
+	if first, last := operations[0].Path, operations[len(operations)-1].Path; first != "file10" ||
+		last != fmt.Sprintf("file%d", activityLogSize+9) {
+		t.Errorf("RecentOperations spans %s..%s, want the newest %d in order", first, last, activityLogSize)
+	}
+}
+
+func TestSyncEngineStatsReadableDuringSync(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	destDir := filepath.Join(tempDir, "dest")
+
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for i := range 50 {
+		writeTreeFile(t, filepath.Join(sourceDir, fmt.Sprintf("file%02d.txt", i)), "data", modTime)
+	}
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	done := make(chan struct{})
+	polled := make(chan struct{})
+
+	// Under -race this reports any field read without the engine's discipline.
+	go func() {
+		defer close(polled)
+
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				_ = engine.GetStats()
+				_ = engine.GetProgress()
+			}
+		}
+	}()
+
+	mirrorTree(t, engine, sourceDir, destDir)
+	close(done)
+	<-polled
+
+	stats := engine.GetStats()
+	if stats.FilesChanged != 50 || stats.EndTime.IsZero() || stats.Duration < 0 {
+		t.Errorf("Final stats = %+v, want 50 files changed and an end time", stats)
+	}
+
+	if progress := engine.GetProgress(); progress.Total == 0 || progress.Current != progress.Total {
+		t.Errorf("Final progress = %d/%d, want complete", progress.Current, progress.Total)
+	}
+}
+
+func TestSyncEngineRejectsOverlappingRuns(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	destDir := filepath.Join(tempDir, "dest")
+
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for i := range 20 {
+		writeTreeFile(t, filepath.Join(sourceDir, fmt.Sprintf("file%02d.txt", i)), "data", modTime)
+	}
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	if err := engine.startRun(false); err != nil {
+		t.Fatalf("startRun failed: %v", err)
+	}
+
+	if err := engine.Mirror(context.Background(), sourceDir, destDir); !errors.Is(err, ErrRunInProgress) {
+		t.Errorf("Mirror during a run returned %v, want ErrRunInProgress", err)
+	}
+
+	if err := engine.RetryFailed(context.Background(), nil); !errors.Is(err, ErrRunInProgress) {
+		t.Errorf("RetryFailed during a run returned %v, want ErrRunInProgress", err)
+	}
+
+	engine.endRun()
+
+	// Racing runs: one may be turned away, but under -race neither may reset
+	// the statistics while the other's workers are updating them.
+	var wg sync.WaitGroup
+
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			errs[i] = engine.Mirror(context.Background(), sourceDir, destDir)
+		}()
+	}
+
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil && !errors.Is(err, ErrRunInProgress) {
+			t.Errorf("Run %d failed: %v", i+1, err)
+		}
+	}
+
+	if got := readTree(t, destDir); len(got) != 20 {
+		t.Errorf("Destination has %d entries, want 20", len(got))
+	}
+}
+
+func TestSyncEngineChangeList(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	destDir := filepath.Join(tempDir, "dest")
+
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	writeTreeFile(t, filepath.Join(sourceDir, "new.txt"), "fresh", modTime)
+	writeTreeFile(t, filepath.Join(sourceDir, "same.txt"), "same", modTime)
+	writeTreeFile(t, filepath.Join(destDir, "same.txt"), "same", modTime)
+	writeTreeFile(t, filepath.Join(destDir, "stale.txt"), "old", modTime)
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	opts := engine.Options()
+	opts.DeleteExtraneous = true
+	opts.DryRun = true
+	engine.SetOptions(opts)
+	engine.SetChangeList(true, nil)
+
+	mirrorTree(t, engine, sourceDir, destDir)
+
+	if changes := engine.GetChangedFiles(); len(changes) != 0 {
+		t.Errorf("Dry run recorded changes %v, want none", changes)
+	}
+
+	engine.SetDryRunChanges(true)
+
+	mirrorTree(t, engine, sourceDir, destDir)
+
+	previewed := make([]string, 0, 2)
+	for _, change := range engine.GetChangedFiles() {
+		previewed = append(previewed, change.String())
+	}
+
+	slices.Sort(previewed)
+
+	if want := []string{"create\tnew.txt", "delete\tstale.txt"}; !slices.Equal(previewed, want) {
+		t.Errorf("Previewed changes = %q, want %q", previewed, want)
+	}
+
+	engine.SetDryRunChanges(false)
+
+	var streamed bytes.Buffer
+
+	opts.DryRun = false
+	engine.SetOptions(opts)
+	engine.SetChangeList(true, &streamed)
+
+	mirrorTree(t, engine, sourceDir, destDir)
+
+	if changes := engine.GetChangedFiles(); len(changes) != 0 {
+		t.Errorf("Streamed change list also kept %v in memory", changes)
+	}
+
+	lines := strings.Split(strings.TrimSpace(streamed.String()), "\n")
+	slices.Sort(lines)
+
+	if want := []string{"create\tnew.txt", "delete\tstale.txt"}; !slices.Equal(lines, want) {
+		t.Errorf("Streamed change list = %q, want %q", lines, want)
+	}
+
+	writeTreeFile(t, filepath.Join(sourceDir, "new.txt"), "newer", modTime.Add(time.Minute))
+	engine.SetChangeList(true, nil)
+
+	mirrorTree(t, engine, sourceDir, destDir)
+
+	changes := engine.GetChangedFiles()
+	if len(changes) != 1 || changes[0].Type != ChangeModify || changes[0].Path != "new.txt" {
+		t.Errorf("Recorded changes = %v, want a single modify of new.txt", changes)
+	}
+
+	statsPath := filepath.Join(tempDir, "stats.json")
+	if err := engine.WriteStatsFile(statsPath); err != nil {
+		t.Fatalf("WriteStatsFile failed: %v", err)
+	}
+
+	data, err := os.ReadFile(statsPath)
+	if err != nil {
+		t.Fatalf("Failed to read stats file: %v", err)
+	}
+
+	if !bytes.Contains(data, []byte(`"type": "modify"`)) || !bytes.Contains(data, []byte(`"filesChanged": 1`)) {
+		t.Errorf("Stats file lacks the change list or counters:\n%s", data)
+	}
+}
+
+func TestSyncEngineChecksumFiles(t *testing.T) {
+	t.Parallel()
+
+	digest := func(content string) string {
+		sum := blake3.Sum256([]byte(content))
+		return hex.EncodeToString(sum[:])
+	}
+
+	tests := []struct {
+		name string
+		mode ChecksumFileMode
+		want map[string]string
+	}{
+		{
+			name: "sidecar",
+			mode: ChecksumFilesSidecar,
+			want: map[string]string{
+				"a.txt.b3sum":      digest("alpha") + "  a.txt\n",
+				"sub/b.txt.b3sum":  digest("beta") + "  b.txt\n",
+				"sub/empty.b3sum":  digest("") + "  empty\n",
+				"orphan.txt.b3sum": "",
+				"B3SUMS":           "",
+			},
+		},
+		{
+			name: "manifest",
+			mode: ChecksumFilesManifest,
+			want: map[string]string{
+				"B3SUMS":           digest("alpha") + "  a.txt\n",
+				"sub/B3SUMS":       digest("beta") + "  b.txt\n" + digest("") + "  empty\n",
+				"orphan.txt.b3sum": "",
+				"a.txt.b3sum":      "",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tempDir := t.TempDir()
+
+			sourceDir := filepath.Join(tempDir, "source")
+			destDir := filepath.Join(tempDir, "dest")
+
+			modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+			writeTreeFile(t, filepath.Join(sourceDir, "a.txt"), "alpha", modTime)
+			writeTreeFile(t, filepath.Join(sourceDir, "sub", "b.txt"), "beta", modTime)
+			writeTreeFile(t, filepath.Join(sourceDir, "sub", "empty"), "", modTime)
+
+			// An up-to-date file still gets listed, and a removed one loses its entry.
+			writeTreeFile(t, filepath.Join(destDir, "sub", "b.txt"), "beta", modTime)
+			writeTreeFile(t, filepath.Join(destDir, "orphan.txt"), "gone", modTime)
+			writeTreeFile(t, filepath.Join(destDir, "orphan.txt.b3sum"), digest("gone")+"  orphan.txt\n", modTime)
+
+			engine, err := NewSyncEngine()
+			if err != nil {
+				t.Fatalf("NewSyncEngine failed: %v", err)
+			}
+
+			if err := engine.SetChecksumFiles(tt.mode); err != nil {
+				t.Fatalf("SetChecksumFiles failed: %v", err)
+			}
+
+			opts := engine.Options()
+			opts.DeleteExtraneous = true
+			engine.SetOptions(opts)
+
+			// The second run must keep the checksum files despite --delete.
+			for range 2 {
+				mirrorTree(t, engine, sourceDir, destDir)
+			}
+
+			got := readTree(t, destDir)
+			for relPath, want := range tt.want {
+				content, exists := got[relPath]
+				if want == "" {
+					if exists {
+						t.Errorf("%s exists, want it absent", relPath)
+					}
+
+					continue
+				}
+
+				if content != want {
+					t.Errorf("%s = %q, want %q", relPath, content, want)
+				}
+			}
+		})
+	}
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	engine.SetChecksumSeed("secret")
+
+	if err := engine.SetChecksumFiles(ChecksumFilesSidecar); err == nil {
+		t.Error("SetChecksumFiles accepted keyed checksums, which b3sum cannot verify")
+	}
+}
+
+func TestSyncEngineMirrorFanOut(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	writeTreeFile(t, filepath.Join(sourceDir, "a.txt"), "alpha", modTime)
+	writeTreeFile(t, filepath.Join(sourceDir, "sub", "b.txt"), "beta", modTime)
+
+	emptyDir := filepath.Join(tempDir, "empty")
+
+	// Already holds a.txt, plus a stale b.txt and an extraneous file.
+	partialDir := filepath.Join(tempDir, "partial")
+	writeTreeFile(t, filepath.Join(partialDir, "a.txt"), "alpha", modTime)
+	writeTreeFile(t, filepath.Join(partialDir, "sub", "b.txt"), "old", modTime.Add(-time.Hour))
+	writeTreeFile(t, filepath.Join(partialDir, "extra.txt"), "extra", modTime)
+
+	// Cannot be scanned, since its parent is a regular file.
+	blocker := filepath.Join(tempDir, "blocker")
+	writeTreeFile(t, blocker, "", modTime)
+	brokenDir := filepath.Join(blocker, "dest")
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	opts := engine.Options()
+	opts.DeleteExtraneous = true
+
+	results, err := engine.MirrorFanOut(context.Background(), sourceDir, []string{emptyDir, partialDir, brokenDir}, opts)
+	if err != nil {
+		t.Fatalf("MirrorFanOut failed: %v", err)
+	}
+
+	want := map[string]string{"a.txt": "alpha", "sub/": "", "sub/b.txt": "beta"}
+	for _, dir := range []string{emptyDir, partialDir} {
+		if got := readTree(t, dir); !maps.Equal(got, want) {
+			t.Errorf("%s = %v, want %v", dir, got, want)
+		}
+	}
+
+	wantChanged := []int64{2, 1, 0}
+	for i, result := range results {
+		if result.Stats.FilesChanged != wantChanged[i] {
+			t.Errorf("%s: FilesChanged = %d, want %d", result.Destination, result.Stats.FilesChanged, wantChanged[i])
+		}
+	}
+
+	if results[1].Stats.FilesDeleted != 1 {
+		t.Errorf("%s: FilesDeleted = %d, want 1", partialDir, results[1].Stats.FilesDeleted)
+	}
+
+	if results[2].Err == nil {
+		t.Errorf("%s: want an error for the unusable destination", brokenDir)
+	}
+
+	if stats := engine.GetStats(); stats.FilesChanged != 3 || stats.ErrorsEncountered != 1 {
+		t.Errorf("GetStats = %d changed, %d errors; want 3 changed, 1 error", stats.FilesChanged, stats.ErrorsEncountered)
+	}
+
+	_, err = engine.MirrorFanOut(context.Background(), sourceDir, []string{emptyDir, emptyDir + "/."}, opts)
+	if !errors.Is(err, ErrSamePath) {
+		t.Errorf("MirrorFanOut with a repeated destination = %v, want ErrSamePath", err)
+	}
+}
+
+func TestSyncEnginePreflight(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	destDir := filepath.Join(tempDir, "dest")
+
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	writeTreeFile(t, filepath.Join(sourceDir, "same.txt"), "same", modTime)
+	writeTreeFile(t, filepath.Join(sourceDir, "changed.txt"), "new content", modTime)
+	writeTreeFile(t, filepath.Join(sourceDir, "sub", "new.txt"), "new", modTime)
+	writeTreeFile(t, filepath.Join(destDir, "same.txt"), "same", modTime)
+	writeTreeFile(t, filepath.Join(destDir, "changed.txt"), "old", modTime)
+	writeTreeFile(t, filepath.Join(destDir, "extra.txt"), "extra", modTime)
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	opts := engine.Options()
+	opts.DeleteExtraneous = true
+	engine.SetOptions(opts)
+
+	errDeclined := errors.New("declined")
+
+	var got SyncPlan
+
+	engine.SetPreflight(func(plan *SyncPlan) error {
+		got = *plan
+		return errDeclined
+	})
+
+	if err := engine.Mirror(context.Background(), sourceDir, destDir); !errors.Is(err, errDeclined) {
+		t.Fatalf("Mirror = %v, want the preflight error", err)
+	}
+
+	want := SyncPlan{
+		FilesToCopy:    2,
+		BytesToCopy:    int64(len("new content") + len("new")),
+		FilesUnchanged: 1,
+		DirsToCreate:   1,
+		FilesToDelete:  1,
+		BytesToDelete:  int64(len("extra")),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("plan = %+v, want %+v", got, want)
+	}
+
+	if plan := engine.GetPlan(); plan == nil || !reflect.DeepEqual(*plan, want) {
+		t.Errorf("GetPlan = %+v, want %+v", plan, want)
+	}
+
+	// A declined plan leaves the destination untouched.
+	wantTree := map[string]string{"same.txt": "same", "changed.txt": "old", "extra.txt": "extra"}
+	if tree := readTree(t, destDir); !maps.Equal(tree, wantTree) {
+		t.Errorf("destination = %v, want %v", tree, wantTree)
+	}
+
+	engine.SetPreflight(nil)
+	mirrorTree(t, engine, sourceDir, destDir)
+
+	if stats := engine.GetStats(); stats.FilesChanged != want.FilesToCopy || stats.FilesDeleted != want.FilesToDelete {
+		t.Errorf("stats = %d changed, %d deleted; want %d, %d",
+			stats.FilesChanged, stats.FilesDeleted, want.FilesToCopy, want.FilesToDelete)
+	}
+}
+
+func TestSyncEngineFileTimeout(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	destDir := filepath.Join(tempDir, "dest")
+
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	writeTreeFile(t, filepath.Join(sourceDir, "slow.bin"), strings.Repeat("x", 256*1024), modTime)
+	writeTreeFile(t, filepath.Join(sourceDir, "fast.txt"), "fast", modTime)
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	// At 64KB/s the large file needs seconds, far past the timeout.
+	schedule, err := config.ParseBandwidthSchedule("64KB")
+	if err != nil {
+		t.Fatalf("ParseBandwidthSchedule failed: %v", err)
+	}
+
+	engine.SetBandwidthSchedule(schedule)
+
+	opts := engine.Options()
+	opts.Timeout = 200 * time.Millisecond
+	engine.SetOptions(opts)
+
+	start := time.Now()
+
+	if err := engine.Mirror(context.Background(), sourceDir, destDir); err != nil {
+		t.Fatalf("Mirror failed: %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Mirror took %v, want the slow copy abandoned after the timeout", elapsed)
+	}
+
+	syncErrors := engine.GetErrors()
+	if len(syncErrors) != 1 || !errors.Is(syncErrors[0], ErrFileTimeout) {
+		t.Fatalf("errors = %v, want one ErrFileTimeout", syncErrors)
+	}
+
+	if syncErrors[0].Path != filepath.Join(sourceDir, "slow.bin") {
+		t.Errorf("timed out path = %s, want slow.bin", syncErrors[0].Path)
+	}
+
+	want := map[string]string{"fast.txt": "fast"}
+	if tree := readTree(t, destDir); !maps.Equal(tree, want) {
+		t.Errorf("destination = %v, want %v", tree, want)
+	}
+}
+
+func TestSyncEngineMetadataOnly(t *testing.T) {
+	t.Parallel()
+
+	for _, dryRun := range []bool{false, true} {
+		t.Run(fmt.Sprintf("dryRun=%v", dryRun), func(t *testing.T) {
+			t.Parallel()
+			tempDir := t.TempDir()
+
+			sourceDir := filepath.Join(tempDir, "source")
+			destDir := filepath.Join(tempDir, "dest")
+
+			sourceTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+			destTime := sourceTime.Add(-24 * time.Hour)
+
+			writeTreeFile(t, filepath.Join(sourceDir, "same.txt"), "same content", sourceTime)
+			writeTreeFile(t, filepath.Join(destDir, "same.txt"), "same content", destTime)
+			writeTreeFile(t, filepath.Join(sourceDir, "changed.txt"), "new content!", sourceTime)
+			writeTreeFile(t, filepath.Join(destDir, "changed.txt"), "old content!", destTime)
+
+			destPath := filepath.Join(destDir, "same.txt")
+			if err := os.Chmod(destPath, 0o600); err != nil {
+				t.Fatalf("Chmod failed: %v", err)
+			}
+
+			engine, err := NewSyncEngine()
+			if err != nil {
+				t.Fatalf("NewSyncEngine failed: %v", err)
+			}
+
+			opts := engine.Options()
+			opts.DryRun = dryRun
+			engine.SetOptions(opts)
+
+			mirrorTree(t, engine, sourceDir, destDir)
+
+			stats := engine.GetStats()
+			if stats.MetadataUpdated != 1 || stats.FilesModified != 1 {
+				t.Errorf("MetadataUpdated = %d, FilesModified = %d; want 1, 1", stats.MetadataUpdated, stats.FilesModified)
+			}
+
+			if !dryRun && stats.BytesTransferred != int64(len("new content!")) {
+				t.Errorf("BytesTransferred = %d, want only changed.txt copied", stats.BytesTransferred)
+			}
+
+			info, err := os.Stat(destPath)
+			if err != nil {
+				t.Fatalf("Stat failed: %v", err)
+			}
+
+			wantTime, wantMode := sourceTime, os.FileMode(0o644)
+			if dryRun {
+				wantTime, wantMode = destTime, 0o600
+			}
+
+			if !info.ModTime().Equal(wantTime) {
+				t.Errorf("modification time = %v, want %v", info.ModTime(), wantTime)
+			}
+
+			if runtime.GOOS != "windows" && info.Mode().Perm() != wantMode {
+				t.Errorf("mode = %v, want %v", info.Mode().Perm(), wantMode)
+			}
+		})
+	}
+}
+
+func TestSyncEngineSetChecksumAlgorithm(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		algo    string
+		wantErr bool
+	}{
+		{algo: "blake3"},
+		{algo: "sha256"},
+		{algo: "md5"},
+		{algo: "xxh3", wantErr: true},
+		{algo: "BLAKE3", wantErr: true},
+		{algo: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algo, func(t *testing.T) {
+			t.Parallel()
+
+			engine, err := NewSyncEngine()
+			if err != nil {
+				t.Fatalf("NewSyncEngine failed: %v", err)
+			}
+
+			err = engine.SetChecksumAlgorithm(tt.algo)
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnsupportedChecksum) || !strings.Contains(err.Error(), "blake3, md5, sha256") {
+					t.Errorf("SetChecksumAlgorithm(%q) = %v, want ErrUnsupportedChecksum listing the supported algorithms", tt.algo, err)
+				}
+
+				if got := engine.ChecksumAlgorithm(); got != "blake3" {
+					t.Errorf("ChecksumAlgorithm() = %q after a rejected algorithm, want blake3 kept", got)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("SetChecksumAlgorithm(%q) failed: %v", tt.algo, err)
+			}
+
+			if got := engine.ChecksumAlgorithm(); got != tt.algo {
+				t.Errorf("ChecksumAlgorithm() = %q, want %q", got, tt.algo)
+			}
+
+			// Every supported algorithm must actually produce digests.
+			if _, _, err := engine.scanner.calculateChecksum(strings.NewReader("content")); err != nil {
+				t.Errorf("calculateChecksum with %s failed: %v", tt.algo, err)
+			}
+		})
+	}
+}
+
+func TestSyncEngineMaxOpenFiles(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	want := make(map[string]string)
+
+	for i := range 20 {
+		name := fmt.Sprintf("file%02d.txt", i)
+		writeTreeFile(t, filepath.Join(sourceDir, name), name, modTime)
+		want[name] = name
+	}
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	if got, wantDefault := engine.MaxOpenFiles(), DefaultOpenFileLimit(); got != wantDefault {
+		t.Errorf("default MaxOpenFiles() = %d, want %d", got, wantDefault)
+	}
+
+	// A single open file is enough for copies needing two, and for a fan-out
+	// needing one more than its destinations, without deadlocking.
+	engine.SetMaxOpenFiles(1)
+
+	if got := engine.MaxOpenFiles(); got != 1 {
+		t.Fatalf("MaxOpenFiles() = %d, want 1", got)
+	}
+
+	destDir := filepath.Join(tempDir, "dest")
+	mirrorTree(t, engine, sourceDir, destDir)
+
+	if got := readTree(t, destDir); !maps.Equal(got, want) {
+		t.Errorf("destination = %v, want %v", got, want)
+	}
+
+	fanOutDirs := []string{filepath.Join(tempDir, "a"), filepath.Join(tempDir, "b")}
+
+	if _, err := engine.MirrorFanOut(context.Background(), sourceDir, fanOutDirs, engine.Options()); err != nil {
+		t.Fatalf("MirrorFanOut failed: %v", err)
+	}
+
+	for _, dir := range fanOutDirs {
+		if got := readTree(t, dir); !maps.Equal(got, want) {
+			t.Errorf("%s = %v, want %v", dir, got, want)
+		}
+	}
+}
+
+func TestSyncEnginePruneEmptyDirs(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		prune      bool
+		dryRun     bool
+		wantPruned int64
+		wantCache  bool
+	}{
+		{name: "pruned", prune: true, wantPruned: 1},
+		{name: "dry run", prune: true, dryRun: true, wantPruned: 1, wantCache: true},
+		{name: "disabled", wantCache: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tempDir := t.TempDir()
+
+			sourceDir := filepath.Join(tempDir, "source")
+			destDir := filepath.Join(tempDir, "dest")
+
+			// cache exists in the source but is excluded, so the deletion pass
+			// keeps the destination directory after emptying it.
+			modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+			writeTreeFile(t, filepath.Join(sourceDir, IgnoreFileName), "cache/\n", modTime)
+			writeTreeFile(t, filepath.Join(sourceDir, "docs", "readme.md"), "readme", modTime)
+
+			if err := os.MkdirAll(filepath.Join(sourceDir, "cache"), 0o755); err != nil {
+				t.Fatalf("Failed to create directory: %v", err)
+			}
+
+			if err := os.MkdirAll(filepath.Join(sourceDir, "empty"), 0o755); err != nil {
+				t.Fatalf("Failed to create directory: %v", err)
+			}
+
+			writeTreeFile(t, filepath.Join(destDir, IgnoreFileName), "cache/\n", modTime)
+			writeTreeFile(t, filepath.Join(destDir, "docs", "readme.md"), "readme", modTime)
+			writeTreeFile(t, filepath.Join(destDir, "docs", "stale.md"), "stale", modTime)
+			writeTreeFile(t, filepath.Join(destDir, "cache", "old", "entry.tmp"), "old", modTime)
+
+			if err := os.MkdirAll(filepath.Join(destDir, "empty"), 0o755); err != nil {
+				t.Fatalf("Failed to create directory: %v", err)
+			}
+
+			engine, err := NewSyncEngine()
+			if err != nil {
+				t.Fatalf("NewSyncEngine failed: %v", err)
+			}
+
+			opts := engine.Options()
+			opts.DeleteExtraneous = true
+			opts.PruneEmptyDirs = tt.prune
+			opts.DryRun = tt.dryRun
+			engine.SetOptions(opts)
+
+			mirrorTree(t, engine, sourceDir, destDir)
+
+			// docs/stale.md, cache/old and cache/old/entry.tmp.
+			stats := engine.GetStats()
+			if stats.FilesDeleted != 3 || stats.DirsPruned != tt.wantPruned {
+				t.Errorf("FilesDeleted, DirsPruned = %d, %d, want 3, %d", stats.FilesDeleted, stats.DirsPruned, tt.wantPruned)
+			}
+
+			if _, err := os.Stat(filepath.Join(destDir, "cache")); (err == nil) != tt.wantCache {
+				t.Errorf("cache exists = %v, want %v", err == nil, tt.wantCache)
+			}
+
+			// The source root, mirrored empty directories and directories
+			// that still hold files are never pruned.
+			for _, dir := range []string{".", "docs", "empty"} {
+				if _, err := os.Stat(filepath.Join(destDir, dir)); err != nil {
+					t.Errorf("%s was removed: %v", dir, err)
+				}
+			}
+		})
+	}
+}
+
+func TestSyncEngineDestNewer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		protect  bool
+		wantTree map[string]string
+		wantKept int64
+	}{
+		{
+			name:     "warn lists and overwrites",
+			wantTree: map[string]string{"edited.txt": "source", "stale.txt": "source"},
+		},
+		{
+			name:     "protect lists and keeps",
+			protect:  true,
+			wantTree: map[string]string{"edited.txt": "edited at destination", "stale.txt": "source"},
+			wantKept: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tempDir := t.TempDir()
+
+			sourceDir := filepath.Join(tempDir, "source")
+			destDir := filepath.Join(tempDir, "dest")
+
+			modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+			writeTreeFile(t, filepath.Join(sourceDir, "edited.txt"), "source", modTime)
+			writeTreeFile(t, filepath.Join(sourceDir, "stale.txt"), "source", modTime)
+			writeTreeFile(t, filepath.Join(destDir, "edited.txt"), "edited at destination", modTime.Add(time.Minute))
+			writeTreeFile(t, filepath.Join(destDir, "stale.txt"), "old", modTime.Add(-time.Minute))
+
+			engine, err := NewSyncEngine()
+			if err != nil {
+				t.Fatalf("NewSyncEngine failed: %v", err)
+			}
+
+			// The source strategy alone would overwrite the edited file.
+			engine.SetConflictConfig(&config.ConflictConfig{Strategy: string(config.ConflictSource)})
+
+			opts := engine.Options()
+			opts.WarnDestNewer = !tt.protect
+			opts.ProtectDestNewer = tt.protect
+			engine.SetOptions(opts)
+
+			mirrorTree(t, engine, sourceDir, destDir)
+
+			if plan := engine.GetPlan(); plan == nil || !slices.Equal(plan.DestNewer, []string{"edited.txt"}) {
+				t.Errorf("plan = %+v, want edited.txt listed as newer at the destination", plan)
+			}
+
+			if got := readTree(t, destDir); !maps.Equal(got, tt.wantTree) {
+				t.Errorf("destination = %v, want %v", got, tt.wantTree)
+			}
+
+			if kept := engine.GetStats().DestNewerKept; kept != tt.wantKept {
+				t.Errorf("DestNewerKept = %d, want %d", kept, tt.wantKept)
+			}
+		})
+	}
+}
+
+func TestSyncEngineDeleteOrder(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		order DeleteOrder
+		// inOrder reports whether the deletion del may come before (or after,
+		// when deletedFirst is false) the creation created.
+		inOrder func(del, created FileOperation, deletedFirst bool) bool
+	}{
+		{order: DeleteAfter, inOrder: func(_, _ FileOperation, deletedFirst bool) bool { return !deletedFirst }},
+		{order: DeleteBefore, inOrder: func(_, _ FileOperation, deletedFirst bool) bool { return deletedFirst }},
+		{order: DeleteDuring, inOrder: func(del, created FileOperation, deletedFirst bool) bool {
+			// Only deletions in the same directory must make room first.
+			return deletedFirst || filepath.Dir(del.Path) != filepath.Dir(created.Path)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.order.String(), func(t *testing.T) {
+			t.Parallel()
+			tempDir := t.TempDir()
+
+			sourceDir := filepath.Join(tempDir, "source")
+			destDir := filepath.Join(tempDir, "dest")
+
+			modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+			writeTreeFile(t, filepath.Join(sourceDir, "a", "new.txt"), "new", modTime)
+			writeTreeFile(t, filepath.Join(sourceDir, "b", "keep.txt"), "keep", modTime)
+			writeTreeFile(t, filepath.Join(destDir, "a", "old.txt"), "old", modTime)
+			writeTreeFile(t, filepath.Join(destDir, "b", "keep.txt"), "keep", modTime)
+			writeTreeFile(t, filepath.Join(destDir, "stale", "gone.txt"), "gone", modTime)
+
+			engine, err := NewSyncEngine()
+			if err != nil {
+				t.Fatalf("NewSyncEngine failed: %v", err)
+			}
+
+			engine.SetChangeList(true, nil)
+
+			opts := engine.Options()
+			opts.DeleteExtraneous = true
+			opts.DeleteOrder = tt.order
+			opts.Workers = 1
+			engine.SetOptions(opts)
+
+			mirrorTree(t, engine, sourceDir, destDir)
+
+			want := map[string]string{"a/": "", "a/new.txt": "new", "b/": "", "b/keep.txt": "keep"}
+			if got := readTree(t, destDir); !maps.Equal(got, want) {
+				t.Errorf("destination = %v, want %v", got, want)
+			}
+
+			changes := engine.GetChangedFiles()
+			for i, del := range changes {
+				if del.Type != ChangeDelete {
+					continue
+				}
+
+				for j, created := range changes {
+					if created.Type != ChangeCreate {
+						continue
+					}
+
+					if !tt.inOrder(del, created, i < j) {
+						t.Errorf("delete of %s at %d, create of %s at %d: out of order", del.Path, i, created.Path, j)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSyncEngineDetectMoves(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	destDir := filepath.Join(tempDir, "dest")
+
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	// photos/2024 was renamed to archive/photos-2024; misc changed content
+	// and must not be matched.
+	for _, root := range []string{filepath.Join(sourceDir, "archive", "photos-2024"), filepath.Join(destDir, "photos", "2024")} {
+		writeTreeFile(t, filepath.Join(root, "a.jpg"), "alpha", modTime)
+		writeTreeFile(t, filepath.Join(root, "trip", "b.jpg"), "beta", modTime)
+	}
+
+	writeTreeFile(t, filepath.Join(sourceDir, "misc-new", "c.txt"), "new", modTime)
+	writeTreeFile(t, filepath.Join(destDir, "misc", "c.txt"), "old", modTime)
+
+	before, err := os.Stat(filepath.Join(destDir, "photos", "2024", "trip", "b.jpg"))
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	opts := engine.Options()
+	opts.DeleteExtraneous = true
+	opts.DetectMoves = true
+	engine.SetOptions(opts)
+
+	mirrorTree(t, engine, sourceDir, destDir)
+
+	if got, want := readTree(t, destDir), readTree(t, sourceDir); !maps.Equal(got, want) {
+		t.Errorf("destination = %v, want %v", got, want)
+	}
+
+	after, err := os.Stat(filepath.Join(destDir, "archive", "photos-2024", "trip", "b.jpg"))
+	if err != nil || !os.SameFile(before, after) {
+		t.Errorf("b.jpg was not moved with its directory (err %v)", err)
+	}
+
+	stats := engine.GetStats()
+	if stats.DirsMoved != 1 || stats.BytesTransferred != int64(len("new")) {
+		t.Errorf("moved %d directories, transferred %d bytes; want 1 and %d", stats.DirsMoved, stats.BytesTransferred, len("new"))
+	}
+
+	if plan := engine.GetPlan(); plan == nil || plan.DirsToMove != 1 || plan.FilesToCopy != 1 {
+		t.Errorf("plan = %+v, want 1 directory to move and 1 file to copy", plan)
+	}
+}
+
+func TestSyncEngineChecksumBlockReport(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	destDir := filepath.Join(tempDir, "dest")
+
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	// Ten blocks of 4 bytes and a short one of 2. The destination has the
+	// second, third, seventh and last damaged.
+	content := "0000111122223333444455556666777788889999ab"
+	damaged := []byte(content)
+	damaged[5], damaged[9], damaged[27], damaged[41] = '#', '#', '#', '#'
+
+	writeTreeFile(t, filepath.Join(sourceDir, "big.bin"), content, modTime)
+	writeTreeFile(t, filepath.Join(destDir, "big.bin"), string(damaged), modTime)
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	opts := engine.Options()
+	opts.ChecksumVerify = false
+	opts.VerifyAfter = true
+	opts.ChecksumBlockReport = 4
+
+	if _, err := engine.Sync(context.Background(), sourceDir, destDir, opts); !errors.Is(err, ErrVerifyMismatch) {
+		t.Fatalf("Sync error = %v, want ErrVerifyMismatch", err)
+	}
+
+	errs := engine.GetErrors()
+	if len(errs) != 1 {
+		t.Fatalf("errors = %v, want one", errs)
+	}
+
+	want := []ByteRange{{4, 12}, {24, 28}, {40, 42}}
+	if !slices.Equal(errs[0].Ranges, want) {
+		t.Errorf("ranges = %v, want %v", errs[0].Ranges, want)
+	}
+
+	if !strings.Contains(errs[0].Message, "4 of 11 blocks of 4 bytes differ, at bytes 4-11, 24-27, 40-41") {
+		t.Errorf("message = %q, want the damaged ranges", errs[0].Message)
+	}
+}
+
+func TestSyncEngineVerifyAfter(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	destDir := filepath.Join(tempDir, "dest")
+
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	writeTreeFile(t, filepath.Join(sourceDir, "copied.txt"), "copied", modTime)
+	writeTreeFile(t, filepath.Join(sourceDir, "rotten.txt"), "intact", modTime)
+
+	// Same size and time, so without checksums it looks up to date.
+	writeTreeFile(t, filepath.Join(destDir, "rotten.txt"), "in#act", modTime)
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	opts := engine.Options()
+	opts.ChecksumVerify = false
+	opts.VerifyAfter = true
+
+	stats, err := engine.Sync(context.Background(), sourceDir, destDir, opts)
+	if !errors.Is(err, ErrVerifyMismatch) {
+		t.Fatalf("Sync error = %v, want ErrVerifyMismatch", err)
+	}
+
+	if stats.FilesReverified != 2 || stats.VerifyMismatches != 1 || stats.ErrorsEncountered != 1 {
+		t.Errorf("reverified %d files with %d mismatches and %d errors, want 2, 1 and 1",
+			stats.FilesReverified, stats.VerifyMismatches, stats.ErrorsEncountered)
+	}
+
+	errs := engine.GetErrors()
+	if len(errs) != 1 || errs[0].Category != ErrorCategoryCorruption || errs[0].Path != filepath.Join(destDir, "rotten.txt") {
+		t.Errorf("errors = %v, want one corruption error for rotten.txt", errs)
+	}
+
+	if errs[0].Ranges != nil {
+		t.Errorf("ranges = %v without a block report", errs[0].Ranges)
+	}
+
+	// Once the file is copied again, the destination verifies.
+	opts.ChecksumVerify = true
+
+	stats, err = engine.Sync(context.Background(), sourceDir, destDir, opts)
+	if err != nil || stats.FilesReverified != 2 || stats.VerifyMismatches != 0 {
+		t.Errorf("second sync reverified %d files with %d mismatches (err %v), want 2 and 0",
+			stats.FilesReverified, stats.VerifyMismatches, err)
+	}
+}
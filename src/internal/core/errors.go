@@ -1,7 +1,12 @@
 package core
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"sync"
 	"time"
 )
 
@@ -38,16 +43,38 @@ func (ec ErrorCategory) String() string {
 	}
 }
 
+// MarshalText encodes the category by name so that error logs are readable.
+func (ec ErrorCategory) MarshalText() ([]byte, error) {
+	return []byte(ec.String()), nil
+}
+
+// UnmarshalText decodes a category written by MarshalText. Unrecognized
+// names decode as ErrorCategoryUnknown.
+func (ec *ErrorCategory) UnmarshalText(text []byte) error {
+	for category := ErrorCategoryUnknown; category <= ErrorCategoryCancellation; category++ {
+		if category.String() == string(text) {
+			*ec = category
+			return nil
+		}
+	}
+
+	*ec = ErrorCategoryUnknown
+
+	return nil
+}
+
 // SyncError represents a detailed synchronization error
 type SyncError struct {
 	Category    ErrorCategory `json:"category"`
 	Operation   string        `json:"operation"`
 	Path        string        `json:"path"`
+	Destination string        `json:"destination,omitempty"`
 	Message     string        `json:"message"`
 	Underlying  error         `json:"-"`
 	Timestamp   time.Time     `json:"timestamp"`
 	Recoverable bool          `json:"recoverable"`
 	Suggestion  string        `json:"suggestion"`
+	Ranges      []ByteRange   `json:"ranges,omitempty"` // byte ranges found damaged by a checksum block report
 }
 
 func (se *SyncError) Error() string {
@@ -142,10 +169,14 @@ func NewCancellationError(operation, path string, err error) *SyncError {
 	}
 }
 
-// ErrorHandler manages error collection and reporting
+// ErrorHandler manages error collection and reporting. It is safe for
+// concurrent use, so errors can be read while workers are still adding them.
 type ErrorHandler struct {
+	mu        sync.RWMutex
 	errors    []*SyncError
 	maxErrors int
+	notify    func(err *SyncError) // called with each error added
+	logger    *slog.Logger         // nil: errors are not logged
 }
 
 // NewErrorHandler creates a new error handler with the specified maximum error count.
@@ -162,16 +193,39 @@ func NewErrorHandler(maxErrors int) *ErrorHandler {
 
 // AddError adds an error to the handler.
 func (eh *ErrorHandler) AddError(err *SyncError) {
+	eh.mu.Lock()
+	defer eh.mu.Unlock()
+
 	if len(eh.errors) >= eh.maxErrors {
 		// Remove oldest error to make room
 		eh.errors = eh.errors[1:]
 	}
 
 	eh.errors = append(eh.errors, err)
+
+	if eh.logger != nil {
+		eh.logger.Warn("sync error", "category", err.Category.String(), "operation", err.Operation,
+			"path", err.Path, "message", err.Message)
+	}
+
+	if eh.notify != nil {
+		eh.notify(err)
+	}
+}
+
+// setNotify makes AddError pass each error to notify; nil stops it.
+func (eh *ErrorHandler) setNotify(notify func(err *SyncError)) {
+	eh.mu.Lock()
+	defer eh.mu.Unlock()
+
+	eh.notify = notify
 }
 
 // GetErrors returns all collected errors.
 func (eh *ErrorHandler) GetErrors() []*SyncError {
+	eh.mu.RLock()
+	defer eh.mu.RUnlock()
+
 	result := make([]*SyncError, len(eh.errors))
 	copy(result, eh.errors)
 
@@ -180,6 +234,9 @@ func (eh *ErrorHandler) GetErrors() []*SyncError {
 
 // GetErrorsByCategory returns errors of a specific category.
 func (eh *ErrorHandler) GetErrorsByCategory(category ErrorCategory) []*SyncError {
+	eh.mu.RLock()
+	defer eh.mu.RUnlock()
+
 	var result []*SyncError
 
 	for _, err := range eh.errors {
@@ -193,6 +250,9 @@ func (eh *ErrorHandler) GetErrorsByCategory(category ErrorCategory) []*SyncError
 
 // GetRecoverableErrors returns errors that can be retried.
 func (eh *ErrorHandler) GetRecoverableErrors() []*SyncError {
+	eh.mu.RLock()
+	defer eh.mu.RUnlock()
+
 	var result []*SyncError
 
 	for _, err := range eh.errors {
@@ -206,21 +266,68 @@ func (eh *ErrorHandler) GetRecoverableErrors() []*SyncError {
 
 // Clear removes all errors from the handler.
 func (eh *ErrorHandler) Clear() {
+	eh.mu.Lock()
+	defer eh.mu.Unlock()
+
 	eh.errors = eh.errors[:0]
 }
 
 // HasErrors returns true if any errors have been collected.
 func (eh *ErrorHandler) HasErrors() bool {
-	return len(eh.errors) > 0
+	return eh.ErrorCount() > 0
 }
 
 // ErrorCount returns the total number of errors.
 func (eh *ErrorHandler) ErrorCount() int {
+	eh.mu.RLock()
+	defer eh.mu.RUnlock()
+
 	return len(eh.errors)
 }
 
+// GetErrorsWithSuggestions returns all collected errors, each carrying a
+// recovery suggestion so that it is actionable on its own.
+func (eh *ErrorHandler) GetErrorsWithSuggestions() []*SyncError {
+	entries := eh.GetErrors()
+	for i, err := range entries {
+		if err.Suggestion == "" {
+			withSuggestion := *err
+			withSuggestion.Suggestion = GetRecoverySuggestion(err)
+			entries[i] = &withSuggestion
+		}
+	}
+
+	return entries
+}
+
+// WriteJSON writes all collected errors to w as a JSON array. Each entry
+// carries a recovery suggestion so the log is actionable on its own.
+func (eh *ErrorHandler) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(eh.GetErrorsWithSuggestions()); err != nil {
+		return fmt.Errorf("failed to encode error log: %w", err)
+	}
+
+	return nil
+}
+
+// ReadErrorLog decodes an error log written by WriteJSON.
+func ReadErrorLog(r io.Reader) ([]*SyncError, error) {
+	var entries []*SyncError
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("failed to decode error log: %w", err)
+	}
+
+	return entries, nil
+}
+
 // GetSummary returns a summary of errors by category.
 func (eh *ErrorHandler) GetSummary() map[ErrorCategory]int {
+	eh.mu.RLock()
+	defer eh.mu.RUnlock()
+
 	summary := make(map[ErrorCategory]int)
 	for _, err := range eh.errors {
 		summary[err.Category]++
@@ -229,6 +336,34 @@ func (eh *ErrorHandler) GetSummary() map[ErrorCategory]int {
 	return summary
 }
 
+// categorySeverity ranks categories for DominantCategory tie-breaks, most
+// severe first.
+var categorySeverity = []ErrorCategory{
+	ErrorCategoryCorruption,
+	ErrorCategoryDisk,
+	ErrorCategoryPermission,
+	ErrorCategoryNetwork,
+	ErrorCategoryConfiguration,
+	ErrorCategoryCancellation,
+	ErrorCategoryUnknown,
+}
+
+// DominantCategory returns the most frequent error category, preferring the
+// more severe category on a tie. It reports false when there are no errors.
+func (eh *ErrorHandler) DominantCategory() (ErrorCategory, bool) {
+	summary := eh.GetSummary()
+
+	dominant, found := ErrorCategoryUnknown, false
+
+	for _, category := range categorySeverity {
+		if summary[category] > summary[dominant] || (!found && summary[category] > 0) {
+			dominant, found = category, true
+		}
+	}
+
+	return dominant, found
+}
+
 // ClassifySyncError automatically classifies an error into the appropriate category.
 func ClassifySyncError(operation, path string, err error) *SyncError {
 	if err == nil {
@@ -253,6 +388,13 @@ func ClassifySyncError(operation, path string, err error) *SyncError {
 		return NewCancellationError(operation, path, err)
 	}
 
+	if errors.Is(err, ErrReadOnly) {
+		syncErr := NewConfigurationError(operation, path, err)
+		syncErr.Suggestion = "The run is read-only; this change would have been made to the destination"
+
+		return syncErr
+	}
+
 	// Default to unknown error with retryable status
 	return &SyncError{
 		Category:    ErrorCategoryUnknown,
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestErrorHandlerWriteJSON(t *testing.T) {
+	t.Parallel()
+
+	handler := NewErrorHandler(10)
+	handler.AddError(NewPermissionError("copy", "/src/secret.txt", errors.New("permission denied")))
+	handler.AddError(&SyncError{
+		Category:  ErrorCategoryDisk,
+		Operation: "copy",
+		Path:      "/src/large.bin",
+		Message:   "no space left on device",
+	})
+
+	var buf bytes.Buffer
+	if err := handler.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+
+	var entries []map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entries); err != nil {
+		t.Fatalf("Error log is not valid JSON: %v", err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+
+	if entries[0]["category"] != "Permission" {
+		t.Errorf("category = %v, want Permission", entries[0]["category"])
+	}
+
+	if entries[1]["suggestion"] != GetRecoverySuggestion(&SyncError{Category: ErrorCategoryDisk}) {
+		t.Errorf("Missing suggestion was not filled in: %v", entries[1]["suggestion"])
+	}
+
+	if _, exists := entries[0]["Underlying"]; exists {
+		t.Errorf("Underlying error should not be serialized")
+	}
+}
+
+func TestErrorHandlerDominantCategory(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		categories []ErrorCategory
+		want       ErrorCategory
+		wantFound  bool
+	}{
+		{name: "no errors", wantFound: false},
+		{
+			name:       "most frequent wins",
+			categories: []ErrorCategory{ErrorCategoryNetwork, ErrorCategoryNetwork, ErrorCategoryDisk},
+			want:       ErrorCategoryNetwork,
+			wantFound:  true,
+		},
+		{
+			name:       "tie goes to the more severe",
+			categories: []ErrorCategory{ErrorCategoryNetwork, ErrorCategoryPermission},
+			want:       ErrorCategoryPermission,
+			wantFound:  true,
+		},
+		{
+			name:       "unknown only",
+			categories: []ErrorCategory{ErrorCategoryUnknown},
+			want:       ErrorCategoryUnknown,
+			wantFound:  true,
+		},
+		{
+			name:       "unknown outnumbers",
+			categories: []ErrorCategory{ErrorCategoryUnknown, ErrorCategoryUnknown, ErrorCategoryDisk},
+			want:       ErrorCategoryUnknown,
+			wantFound:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := NewErrorHandler(10)
+			for _, category := range tt.categories {
+				handler.AddError(&SyncError{Category: category})
+			}
+
+			got, found := handler.DominantCategory()
+			if got != tt.want || found != tt.wantFound {
+				t.Errorf("DominantCategory() = %v, %v; want %v, %v", got, found, tt.want, tt.wantFound)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// eventBacklog is how many events are queued for a client before it is
+// considered stalled and disconnected, so a slow reader never holds up a run.
+const eventBacklog = 4096
+
+// eventWriteTimeout bounds how long writing one event to a client may take,
+// so that Close is not held up by a client that stopped reading.
+const eventWriteTimeout = 5 * time.Second
+
+// EventType names the kind of an Event.
+type EventType string
+
+// Event types, in the order a run sends them.
+const (
+	// EventScanStarted begins a run. DryRun is set for previews.
+	EventScanStarted EventType = "scan-started"
+	// EventPlan carries the plan worked out once the trees are scanned.
+	EventPlan EventType = "plan"
+	// EventConflictDetected reports a destination file that conflicts with
+	// its source, before the conflict strategy resolves it.
+	EventConflictDetected EventType = "conflict-detected"
+	// EventFileCopied reports a file or directory created or modified;
+	// Change tells which.
+	EventFileCopied EventType = "file-copied"
+	// EventFileDeleted reports a destination entry deleted.
+	EventFileDeleted EventType = "file-deleted"
+	// EventFileRenamed reports a destination directory renamed to match a
+	// moved source directory.
+	EventFileRenamed EventType = "file-renamed"
+	// EventError reports an error recorded during the run.
+	EventError EventType = "error"
+	// EventCompleted ends a run, successful or not, with its statistics.
+	EventCompleted EventType = "completed"
+)
+
+// Event is one step of a run, as streamed to event socket clients. Fields
+// that do not apply to its type are left out of the JSON.
+type Event struct {
+	Type     EventType  `json:"type"`
+	Time     time.Time  `json:"time"`
+	Path     string     `json:"path,omitempty"`     // relative to the destination root
+	Change   string     `json:"change,omitempty"`   // create, modify, delete or rename
+	Size     int64      `json:"size,omitempty"`     // bytes written, or freed by a deletion
+	Conflict string     `json:"conflict,omitempty"` // conflict-detected: how the files differ
+	DryRun   bool       `json:"dryRun,omitempty"`   // scan-started
+	Plan     *SyncPlan  `json:"plan,omitempty"`
+	Error    *SyncError `json:"error,omitempty"`
+	Stats    *SyncStats `json:"stats,omitempty"` // completed
+}
+
+// EventStream sends events as JSON Lines to every client connected to a
+// Unix domain socket. Clients that connect mid-run receive the events from
+// then on. A client that falls eventBacklog events behind is disconnected.
+// It is safe for concurrent use, including by several engines at once.
+type EventStream struct {
+	listener net.Listener
+	mu       sync.Mutex
+	clients  map[*eventClient]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+type eventClient struct {
+	conn  net.Conn
+	queue chan []byte
+}
+
+// ListenEvents creates a Unix domain socket at path, accessible only to the
+// current user, and accepts event stream clients on it until Close. A socket
+// left behind by an earlier process is replaced; one another process still
+// listens on, or any other file at path, is an error.
+func ListenEvents(path string) (*EventStream, error) {
+	listener, err := listenSocket(path, "event")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &EventStream{listener: listener, clients: make(map[*eventClient]struct{})}
+
+	s.wg.Add(1)
+
+	go s.accept()
+
+	return s, nil
+}
+
+// Addr returns the address of the socket.
+func (s *EventStream) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+func (s *EventStream) accept() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+
+		if err != nil {
+			continue
+		}
+
+		client := &eventClient{conn: conn, queue: make(chan []byte, eventBacklog)}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+
+			_ = conn.Close()
+
+			return
+		}
+
+		s.clients[client] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go s.write(client)
+	}
+}
+
+// write sends the client's queued events until the queue is closed or
+// writing fails.
+func (s *EventStream) write(client *eventClient) {
+	defer s.wg.Done()
+	defer func() { _ = client.conn.Close() }()
+
+	for line := range client.queue {
+		_ = client.conn.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+
+		if _, err := client.conn.Write(line); err != nil {
+			s.drop(client)
+			return
+		}
+	}
+}
+
+// drop disconnects client, if it is still connected.
+func (s *EventStream) drop(client *eventClient) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.clients[client]; ok {
+		delete(s.clients, client)
+		close(client.queue)
+	}
+}
+
+// Send queues event for every connected client without waiting for them. A
+// nil stream sends nothing.
+func (s *EventStream) Send(event Event) {
+	if s == nil {
+		return
+	}
+
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for client := range s.clients {
+		select {
+		case client.queue <- line:
+		default:
+			// Stalled; the client can reconnect and pick up from there.
+			delete(s.clients, client)
+			close(client.queue)
+		}
+	}
+}
+
+// Close stops accepting clients, sends each connected client the events
+// queued for it and disconnects it, and removes the socket.
+func (s *EventStream) Close() error {
+	s.mu.Lock()
+	s.closed = true
+
+	for client := range s.clients {
+		delete(s.clients, client)
+		close(client.queue)
+	}
+	s.mu.Unlock()
+
+	err := s.listener.Close()
+	s.wg.Wait()
+
+	if err != nil {
+		return fmt.Errorf("failed to close event socket: %w", err)
+	}
+
+	return nil
+}
+
+// SetEventStream sends the events of every run to stream. The caller closes
+// stream once the engine is done with it. A nil stream turns events off.
+func (e *SyncEngine) SetEventStream(stream *EventStream) {
+	e.mu.Lock()
+	e.events = stream
+	e.mu.Unlock()
+
+	var notify func(err *SyncError)
+
+	if stream != nil {
+		notify = func(err *SyncError) {
+			stream.Send(Event{Type: EventError, Time: err.Timestamp, Error: err})
+		}
+	}
+
+	e.errorHandler.setNotify(notify)
+}
+
+// emit sends event to the event stream, if there is one.
+func (e *SyncEngine) emit(event Event) {
+	e.mu.RLock()
+	stream := e.events
+	e.mu.RUnlock()
+
+	stream.Send(event)
+}
+
+// changeEvent returns the event for a change of changeType to relPath.
+func changeEvent(changeType ChangeType, relPath string, size int64, at time.Time) Event {
+	event := Event{Type: EventFileCopied, Time: at, Path: relPath, Change: changeType.String(), Size: size}
+
+	switch changeType {
+	case ChangeDelete:
+		event.Type = EventFileDeleted
+	case ChangeRename:
+		event.Type = EventFileRenamed
+	}
+
+	return event
+}
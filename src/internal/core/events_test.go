@@ -0,0 +1,150 @@
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSyncEngineEventStream(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	destDir := filepath.Join(tempDir, "dest")
+
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	writeTreeFile(t, filepath.Join(sourceDir, "a.txt"), "alpha", modTime)
+	writeTreeFile(t, filepath.Join(destDir, "stale.txt"), "stale", modTime)
+
+	stream, err := ListenEvents(filepath.Join(tempDir, "events.sock"))
+	if err != nil {
+		t.Fatalf("ListenEvents failed: %v", err)
+	}
+
+	conn, err := net.Dial("unix", stream.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+
+	defer func() { _ = conn.Close() }()
+
+	// Events sent before the client is registered would not reach it.
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		stream.mu.Lock()
+		connected := len(stream.clients)
+		stream.mu.Unlock()
+
+		if connected == 1 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("client was never registered")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	opts := engine.Options()
+	opts.DeleteExtraneous = true
+	engine.SetOptions(opts)
+	engine.SetEventStream(stream)
+
+	mirrorTree(t, engine, sourceDir, destDir)
+
+	if err := stream.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	var events []Event
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("line %q is not an event: %v", scanner.Text(), err)
+		}
+
+		events = append(events, event)
+	}
+
+	// The destination root's time differs from the source's, which counts
+	// as a conflict; it is not what this test is about.
+	events = slices.DeleteFunc(events, func(event Event) bool {
+		return event.Type == EventConflictDetected && event.Path == "."
+	})
+
+	var types []EventType
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+
+	want := []EventType{EventScanStarted, EventPlan, EventFileCopied, EventFileDeleted, EventCompleted}
+	if !slices.Equal(types, want) {
+		t.Fatalf("event types = %v, want %v", types, want)
+	}
+
+	if copied := events[2]; copied.Path != "a.txt" || copied.Change != "create" || copied.Size != 5 {
+		t.Errorf("file-copied event = %+v, want a.txt created with 5 bytes", copied)
+	}
+
+	if deleted := events[3]; deleted.Path != "stale.txt" {
+		t.Errorf("file-deleted event = %+v, want stale.txt", deleted)
+	}
+
+	if plan := events[1].Plan; plan == nil || plan.FilesToCopy != 1 || plan.FilesToDelete != 1 {
+		t.Errorf("plan event = %+v, want 1 file to copy and 1 to delete", events[1].Plan)
+	}
+
+	if stats := events[4].Stats; stats == nil || stats.FilesCreated != 1 || stats.FilesDeleted != 1 {
+		t.Errorf("completed event stats = %+v, want 1 created and 1 deleted", stats)
+	}
+
+	if _, err := os.Lstat(stream.Addr().String()); !os.IsNotExist(err) {
+		t.Errorf("socket was not removed on Close (err %v)", err)
+	}
+}
+
+func TestListenEventsSocket(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "events.sock")
+
+	stream, err := ListenEvents(path)
+	if err != nil {
+		t.Fatalf("ListenEvents failed: %v", err)
+	}
+
+	defer func() { _ = stream.Close() }()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("socket permissions = %v, want 0600", perm)
+	}
+
+	// A socket still in use is not taken over.
+	if _, err := ListenEvents(path); err == nil {
+		t.Error("ListenEvents over a live socket succeeded, want an error")
+	}
+
+	if conn, err := net.Dial("unix", path); err != nil {
+		t.Errorf("first stream no longer reachable: %v", err)
+	} else {
+		_ = conn.Close()
+	}
+}
@@ -0,0 +1,600 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+)
+
+// ErrFanOutOption is returned by MirrorFanOut for options that only make
+// sense with a single destination.
+var ErrFanOutOption = errors.New("not supported with multiple destinations")
+
+// FanOutResult reports how one destination of a fan-out mirror fared.
+type FanOutResult struct {
+	Destination string
+	Stats       *SyncStats
+	// Err is set when the destination could not be mirrored at all, for
+	// example because it could not be scanned. Failures of single files are
+	// counted in Stats and collected with the engine's other errors.
+	Err error
+}
+
+// fanOutTarget is one destination of a fan-out mirror.
+type fanOutTarget struct {
+	destination string
+	destMap     map[string]*FileInfo
+	stats       *SyncStats // counters only; written atomically
+	err         error      // set when the destination is skipped entirely
+}
+
+// fanOutWrite is a destination that needs a copy of the current file.
+type fanOutWrite struct {
+	target   *fanOutTarget
+	destPath string
+	destFile *FileInfo // nil when the destination has no such entry
+}
+
+// MirrorFanOut mirrors source to every destination in one pass. The source is
+// scanned once and each out-of-date file is read once, its contents written
+// to all destinations that need it. Destinations are compared, counted and
+// fail independently: a destination that cannot be scanned is skipped, and a
+// file that cannot be written to one destination is still written to the
+// others. GetStats totals all destinations.
+//
+// File lists, append-only copies, destination snapshots, checksum files,
+// change lists, verification after the sync and archive destinations are
+// tied to a single destination and rejected with ErrFanOutOption.
+func (e *SyncEngine) MirrorFanOut(ctx context.Context, source string, destinations []string, opts SyncOptions) ([]*FanOutResult, error) {
+	if err := e.checkFanOutOptions(source, destinations, opts); err != nil {
+		return nil, err
+	}
+
+	if err := e.startRun(opts.DryRun); err != nil {
+		return nil, err
+	}
+
+	defer e.endRun()
+
+	e.applyCopyOptions(opts)
+
+	targets := make([]*fanOutTarget, len(destinations))
+	for i, destination := range destinations {
+		targets[i] = &fanOutTarget{destination: destination, stats: &SyncStats{}}
+	}
+
+	e.mu.Lock()
+	e.fanOut = targets
+	e.mu.Unlock()
+
+	sourceFiles, err := e.scanner.ScanWithFilter(ctx, source, e.sourceFilter(source))
+	if err != nil {
+		if !e.recordIncompleteScan(err) {
+			return e.fanOutResults(), fmt.Errorf("failed to scan source directory: %w", err)
+		}
+	}
+
+	for _, target := range targets {
+		target.destMap, err = e.scanDestination(ctx, target.destination)
+		if err != nil {
+			target.err = err
+			e.errorHandler.AddError(ClassifySyncError("scan", target.destination, err))
+			atomic.AddInt64(&target.stats.ErrorsEncountered, 1)
+		}
+	}
+
+	// The plan totals all destinations, like GetStats.
+	plan := &SyncPlan{}
+
+	for _, target := range targets {
+		if target.err == nil {
+			plan.add(e.planSync(source, sourceFiles, target.destMap, opts))
+		}
+	}
+
+	if err := e.runPreflight(plan); err != nil {
+		return e.fanOutResults(), err
+	}
+
+	var deletions []*deletionPass
+
+	if opts.DeleteExtraneous {
+		for _, target := range targets {
+			if target.err == nil {
+				deletions = append(deletions, e.newDeletionPass(source, target.destination, sourceFiles, target.destMap, target.stats, opts))
+			}
+		}
+	}
+
+	if opts.DeleteOrder == DeleteBefore {
+		for _, deletion := range deletions {
+			if err := deletion.finish(ctx); err != nil {
+				return e.fanOutResults(), err
+			}
+		}
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = e.copier.workers
+	}
+
+	err = e.syncFiles(ctx, sourceFiles, workers, func(file *FileInfo) error {
+		for _, deletion := range deletions {
+			deletion.beforeSync(ctx, file)
+		}
+
+		return e.fanOutFile(ctx, source, file, targets, opts)
+	})
+	if err != nil {
+		return e.fanOutResults(), err
+	}
+
+	if opts.DeleteOrder != DeleteBefore {
+		for _, deletion := range deletions {
+			if err := deletion.finish(ctx); err != nil {
+				return e.fanOutResults(), err
+			}
+		}
+	}
+
+	e.finishRun()
+
+	return e.fanOutResults(), e.guard.runError()
+}
+
+// checkFanOutOptions rejects options MirrorFanOut cannot honor and
+// destinations that overlap each other or the source.
+func (e *SyncEngine) checkFanOutOptions(source string, destinations []string, opts SyncOptions) error {
+	switch {
+	case opts.FileList != nil:
+		return fmt.Errorf("file lists are %w", ErrFanOutOption)
+	case opts.AppendOnly:
+		return fmt.Errorf("append-only copies are %w", ErrFanOutOption)
+	case opts.DetectMoves && opts.DeleteExtraneous:
+		return fmt.Errorf("directory move detection is %w", ErrFanOutOption)
+	case e.snapshotPath != "":
+		return fmt.Errorf("destination snapshots are %w", ErrFanOutOption)
+	case e.checksumMode != ChecksumFilesOff:
+		return fmt.Errorf("checksum files are %w", ErrFanOutOption)
+	case e.listChanges:
+		return fmt.Errorf("change lists are %w", ErrFanOutOption)
+	case opts.VerifyAfter:
+		return fmt.Errorf("verifying after the sync is %w", ErrFanOutOption)
+	}
+
+	// Destinations that cannot be resolved fail on their own when scanned.
+	for i, destination := range destinations {
+		if format := ArchiveFormatOf(destination); format != ArchiveNone {
+			return fmt.Errorf("%s archive destinations are %w", format, ErrFanOutOption)
+		}
+
+		if err := checkDistinctPaths(source, destination); errors.Is(err, ErrSamePath) {
+			return err
+		}
+
+		// Two workers writing the same file at once would corrupt it.
+		for _, other := range destinations[:i] {
+			if err := checkDistinctPaths(other, destination); errors.Is(err, ErrSamePath) {
+				return fmt.Errorf("destination listed twice: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// fanOutFile brings sourceFile up to date in every target that needs it,
+// reading a regular file once for all of them. Failures are counted against
+// the target they occur in; the returned error is for failures of the source.
+func (e *SyncEngine) fanOutFile(ctx context.Context, source string, sourceFile *FileInfo, targets []*fanOutTarget, opts SyncOptions) error {
+	relPath, err := filepath.Rel(source, sourceFile.Path)
+	if err != nil {
+		return fmt.Errorf("failed to get relative path: %w", err)
+	}
+
+	var writes []fanOutWrite
+
+	for _, target := range targets {
+		if target.err != nil {
+			continue
+		}
+
+		destPath := filepath.Join(target.destination, relPath)
+
+		destFile, exists := target.destMap[relPath]
+		if exists && contentMatches(sourceFile, destFile) {
+			if err := e.syncMetadata(relPath, destPath, sourceFile, destFile, target.stats, opts); err != nil {
+				atomic.AddInt64(&target.stats.ErrorsEncountered, 1)
+			}
+
+			continue
+		}
+
+		if exists && !e.needsSync(sourceFile, destFile, opts) {
+			continue
+		}
+
+		writes = append(writes, fanOutWrite{target: target, destPath: destPath, destFile: destFile})
+	}
+
+	if len(writes) == 0 {
+		return nil
+	}
+
+	// As in syncFile, conflicts are only resolved in the run that copies.
+	if !sourceFile.IsDir && !e.reserveCopy(opts) {
+		for _, write := range writes {
+			atomic.AddInt64(&write.target.stats.FilesDeferred, 1)
+		}
+
+		return nil
+	}
+
+	writes = slices.DeleteFunc(writes, func(write fanOutWrite) bool {
+		if write.destFile == nil {
+			return false
+		}
+
+		overwrite, err := e.resolveConflict(ctx, relPath, write.destPath, sourceFile, write.destFile, write.target.stats, opts)
+		if err != nil {
+			atomic.AddInt64(&write.target.stats.ErrorsEncountered, 1)
+		}
+
+		return err != nil || !overwrite
+	})
+
+	if len(writes) == 0 {
+		if !sourceFile.IsDir {
+			e.releaseCopy(opts)
+		}
+
+		return nil
+	}
+
+	if opts.DryRun || sourceFile.IsDir {
+		for _, write := range writes {
+			e.fanOutEntry(relPath, sourceFile, write, opts)
+		}
+
+		return nil
+	}
+
+	if opts.Quarantine {
+		if err := e.recheckSource(sourceFile); err != nil {
+			return e.quarantineSource(sourceFile, err)
+		}
+	}
+
+	destPaths := make([]string, len(writes))
+	for i, write := range writes {
+		destPaths[i] = write.destPath
+	}
+
+	for i, err := range e.copyToAllWithRetry(ctx, sourceFile.Path, destPaths) {
+		switch {
+		case errors.Is(err, errSourceVanished):
+			return nil
+		case err != nil:
+			atomic.AddInt64(&writes[i].target.stats.ErrorsEncountered, 1)
+			continue
+		}
+
+		stats := writes[i].target.stats
+		atomic.AddInt64(&stats.BytesTransferred, sourceFile.Size)
+		atomic.AddInt64(&stats.NetBytesChange, sizeChange(sourceFile, writes[i].destFile))
+		atomic.AddInt64(&stats.FilesChanged, 1)
+		e.countWrite(stats, relPath, writes[i].destPath, sourceFile, writes[i].destFile)
+	}
+
+	return nil
+}
+
+// fanOutEntry creates a directory in one target, or counts what a dry run
+// would write there.
+func (e *SyncEngine) fanOutEntry(relPath string, sourceFile *FileInfo, write fanOutWrite, opts SyncOptions) {
+	stats := write.target.stats
+
+	if opts.DryRun {
+		atomic.AddInt64(&stats.NetBytesChange, sizeChange(sourceFile, write.destFile))
+		e.countWrite(stats, relPath, write.destPath, sourceFile, write.destFile)
+
+		return
+	}
+
+	err := e.guard.check("create directory", write.destPath)
+	if err == nil {
+		err = os.MkdirAll(toExtendedPath(write.destPath), os.FileMode(sourceFile.Mode))
+	}
+
+	if err != nil {
+		err = fmt.Errorf("failed to create directory %s: %w", write.destPath, err)
+		e.errorHandler.AddError(ClassifySyncError("mkdir", write.destPath, err))
+		atomic.AddInt64(&stats.ErrorsEncountered, 1)
+
+		return
+	}
+
+	e.countWrite(stats, relPath, write.destPath, sourceFile, write.destFile)
+}
+
+// countWrite counts sourceFile as created or modified in stats and records
+// the operation.
+func (e *SyncEngine) countWrite(stats *SyncStats, relPath, destPath string, sourceFile, destFile *FileInfo) {
+	size := sourceFile.Size
+	if sourceFile.IsDir {
+		size = 0
+	}
+
+	changeType := ChangeCreate
+	if destFile != nil {
+		changeType = ChangeModify
+		atomic.AddInt64(&stats.FilesModified, 1)
+	} else {
+		atomic.AddInt64(&stats.FilesCreated, 1)
+	}
+
+	e.recordOperation(changeType, relPath, size)
+	e.audit(changeType, destPath, "", destFile, sourceFile)
+}
+
+// copyToAllWithRetry copies src to every path in dsts under the retry
+// policy, reading src once per attempt. Only destinations that failed are
+// retried. It returns one error per destination, nil where the copy
+// succeeded, and records failures like copyWithRetry. When the source has
+// vanished, every remaining destination gets errSourceVanished.
+func (e *SyncEngine) copyToAllWithRetry(ctx context.Context, src string, dsts []string) []error {
+	ctx, cancel := e.fileContext(ctx)
+	defer cancel()
+
+	errs := make([]error, len(dsts))
+
+	pending := make([]int, len(dsts))
+	for i := range dsts {
+		pending[i] = i
+	}
+
+	copyErr := e.retryManager.ExecuteWithRetry(ctx, func() error {
+		paths := make([]string, len(pending))
+		for j, i := range pending {
+			paths[j] = dsts[i]
+		}
+
+		var failed []int
+
+		for j, err := range e.copier.CopyFileToAll(ctx, src, paths) {
+			errs[pending[j]] = err
+			if err != nil {
+				failed = append(failed, pending[j])
+			}
+		}
+
+		pending = failed
+		if len(failed) == 0 {
+			return nil
+		}
+
+		err := errs[failed[0]]
+		if sourceVanished(src, err) || errors.Is(err, ErrReadOnly) {
+			// Retrying cannot bring a deleted source back or make the
+			// destinations writable.
+			return NewRetryableError(err, false)
+		}
+
+		return err
+	})
+	if copyErr == nil {
+		return errs
+	}
+
+	if sourceVanished(src, copyErr) {
+		e.recordVanished(src)
+
+		for _, i := range pending {
+			errs[i] = errSourceVanished
+		}
+
+		return errs
+	}
+
+	for _, i := range pending {
+		errs[i] = e.fileTimeoutError(ctx, errs[i])
+
+		syncErr := ClassifySyncError("copy", src, errs[i])
+		syncErr.Destination = dsts[i]
+		e.errorHandler.AddError(syncErr)
+
+		errs[i] = fmt.Errorf("failed to copy file %s to %s after retries: %w", src, dsts[i], errs[i])
+	}
+
+	return errs
+}
+
+// fanOutResults returns the per-destination results of the current or last
+// fan-out mirror.
+func (e *SyncEngine) fanOutResults() []*FanOutResult {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	results := make([]*FanOutResult, len(e.fanOut))
+
+	for i, target := range e.fanOut {
+		stats := target.stats.load()
+		stats.FilesScanned = atomic.LoadInt64(&e.stats.FilesScanned)
+		stats.DryRun = e.stats.DryRun
+		stats.StartTime = e.stats.StartTime
+		stats.EndTime = e.stats.EndTime
+		stats.Duration = e.stats.Duration
+
+		results[i] = &FanOutResult{Destination: target.destination, Stats: &stats, Err: target.err}
+	}
+
+	return results
+}
+
+// CopyFileToAll copies the regular file src to every path in dsts, reading it
+// once and writing each chunk to all destinations. A destination that fails
+// is dropped and its partial file removed while the others continue. It
+// returns one error per destination, nil for those written successfully.
+// Cloning and zero-copy are not used, since they cannot share one read; a
+// bandwidth limit applies to the read.
+func (fc *FileCopier) CopyFileToAll(ctx context.Context, src string, dsts []string) []error {
+	errs := make([]error, len(dsts))
+	writers := make([]*os.File, len(dsts))
+
+	// In read-only mode every destination is refused alike.
+	for i, dst := range dsts {
+		errs[i] = fc.guard.check("copy to", dst)
+	}
+
+	if slices.ContainsFunc(errs, func(err error) bool { return err != nil }) {
+		return errs
+	}
+
+	// abandon drops destination i, removing its partial copy.
+	abandon := func(i int, err error) {
+		_ = writers[i].Close()
+		_ = os.Remove(toExtendedPath(dsts[i]))
+		writers[i] = nil
+		errs[i] = err
+	}
+
+	// abandonAll drops every destination still being written, or fails all of
+	// them if none was opened yet.
+	abandonAll := func(err error) {
+		for i := range dsts {
+			switch {
+			case writers[i] != nil:
+				abandon(i, err)
+			case errs[i] == nil:
+				errs[i] = err
+			}
+		}
+	}
+
+	release, err := fc.openFiles.acquire(ctx, 1+len(dsts))
+	if err != nil {
+		abandonAll(fmt.Errorf("copy cancelled: %w", err))
+		return errs
+	}
+
+	defer release()
+
+	srcFile, err := os.Open(toExtendedPath(src))
+	if err != nil {
+		abandonAll(fmt.Errorf("failed to open source file %s: %w", src, err))
+		return errs
+	}
+
+	defer func() { _ = srcFile.Close() }()
+
+	srcInfo, err := srcFile.Stat()
+	if err != nil {
+		abandonAll(fmt.Errorf("failed to stat source file %s: %w", src, err))
+		return errs
+	}
+
+	for i, dst := range dsts {
+		dst = toExtendedPath(dst)
+
+		if err := os.MkdirAll(filepath.Dir(dst), 0o750); err != nil {
+			errs[i] = fmt.Errorf("failed to create destination directory: %w", err)
+			continue
+		}
+
+		writers[i], err = os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, srcInfo.Mode())
+		if err != nil {
+			writers[i] = nil
+			errs[i] = fmt.Errorf("failed to create destination file %s: %w", dst, err)
+		}
+	}
+
+	buffer := make([]byte, fc.bufferSize)
+
+	var totalBytes int64
+
+	for fc.anyOpen(writers) {
+		if err := ctx.Err(); err != nil {
+			abandonAll(fmt.Errorf("copy cancelled: %w", err))
+			return errs
+		}
+
+		bytesRead, readErr := srcFile.Read(buffer)
+		if bytesRead > 0 {
+			if err := fc.throttle(ctx, bytesRead); err != nil {
+				abandonAll(fmt.Errorf("copy cancelled: %w", err))
+				return errs
+			}
+
+			for i, writer := range writers {
+				if writer == nil {
+					continue
+				}
+
+				written, err := writer.Write(buffer[:bytesRead])
+				if err == nil && written != bytesRead {
+					err = io.ErrShortWrite
+				}
+
+				if err != nil {
+					abandon(i, fmt.Errorf("failed to copy file content: %w", err))
+				}
+			}
+
+			totalBytes += int64(bytesRead)
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+
+		if readErr != nil {
+			abandonAll(fmt.Errorf("failed to copy file content: %w", readErr))
+			return errs
+		}
+	}
+
+	if totalBytes != srcInfo.Size() && fc.anyOpen(writers) {
+		abandonAll(fmt.Errorf("incomplete copy: expected %d bytes, read %d bytes", srcInfo.Size(), totalBytes))
+		return errs
+	}
+
+	for i, writer := range writers {
+		if writer == nil {
+			continue
+		}
+
+		if err := writer.Sync(); err != nil {
+			abandon(i, fmt.Errorf("failed to sync destination file: %w", err))
+			continue
+		}
+
+		if err := writer.Close(); err != nil {
+			writers[i] = nil
+			errs[i] = fmt.Errorf("failed to close destination file: %w", err)
+
+			continue
+		}
+
+		writers[i] = nil
+		errs[i] = fc.applyMetadata(toExtendedPath(dsts[i]), srcInfo)
+	}
+
+	return errs
+}
+
+// anyOpen reports whether any destination is still being written.
+func (fc *FileCopier) anyOpen(writers []*os.File) bool {
+	for _, writer := range writers {
+		if writer != nil {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,117 @@
+package core
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// ErrFileListDelete is returned when DeleteExtraneous is combined with a file
+// list, since deciding what is extraneous requires a full source scan.
+var ErrFileListDelete = errors.New("deleting extraneous files requires a full source scan, not a file list")
+
+// ReadFileList reads relative paths, one per line, in the order given. Blank
+// lines and lines starting with '#' or ';' are ignored.
+func ReadFileList(r io.Reader) ([]string, error) {
+	var paths []string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		relPath, err := cleanListedPath(line)
+		if err != nil {
+			return nil, err
+		}
+
+		paths = append(paths, relPath)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read file list: %w", err)
+	}
+
+	return paths, nil
+}
+
+// cleanListedPath normalizes a listed path and rejects paths that would
+// escape the source root.
+func cleanListedPath(path string) (string, error) {
+	cleaned := filepath.Clean(filepath.FromSlash(strings.TrimPrefix(path, "/")))
+	if cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) || filepath.IsAbs(cleaned) {
+		return "", fmt.Errorf("listed path %q is outside the source directory", path)
+	}
+
+	return cleaned, nil
+}
+
+// statFileList stats each listed path under root directly instead of walking
+// the tree, preserving the list order. Paths that cannot be read are recorded
+// as errors; paths that are filtered out are dropped.
+func (e *SyncEngine) statFileList(root string, list []string, filter FilterFunc) []*FileInfo {
+	files := make([]*FileInfo, 0, len(list))
+
+	for _, relPath := range list {
+		path := filepath.Join(root, relPath)
+
+		info, err := e.statListedFile(path)
+		if err != nil {
+			e.errorHandler.AddError(ClassifySyncError("stat", path, err))
+			atomic.AddInt64(&e.stats.ErrorsEncountered, 1)
+
+			continue
+		}
+
+		if filter != nil && !filter(path, info) {
+			continue
+		}
+
+		e.scanner.populateChecksum(info)
+		files = append(files, info)
+	}
+
+	return files
+}
+
+func (e *SyncEngine) statListedFile(path string) (*FileInfo, error) {
+	stat, err := os.Stat(toExtendedPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file %s: %w", path, err)
+	}
+
+	return e.scanner.statFileInfo(path, fs.FileInfoToDirEntry(stat))
+}
+
+// statDestinationList stats the destination counterpart of each listed path.
+// Missing destinations are simply left out of the map.
+func (e *SyncEngine) statDestinationList(root string, list []string) map[string]*FileInfo {
+	destMap := make(map[string]*FileInfo, len(list))
+
+	for _, relPath := range list {
+		path := filepath.Join(root, relPath)
+
+		info, err := e.statListedFile(path)
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				e.errorHandler.AddError(ClassifySyncError("stat", path, err))
+				atomic.AddInt64(&e.stats.ErrorsEncountered, 1)
+			}
+
+			continue
+		}
+
+		e.scanner.populateChecksum(info)
+		destMap[relPath] = info
+	}
+
+	return destMap
+}
@@ -0,0 +1,291 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// FileFilter decides which scanned source files take part in a sync.
+type FileFilter struct {
+	minSize      int64
+	maxSize      int64
+	since        time.Time // zero: no cutoff
+	include      []string  // regex sources, kept to recompile on SetIgnoreCase
+	exclude      []string
+	ignoreCase   bool
+	includeRegex []*regexp.Regexp
+	excludeRegex []*regexp.Regexp
+	includeGlobs []string // glob sources, kept to recompile on SetIgnoreCase
+	excludeGlobs []string
+	includeGlob  []ignorePattern
+	excludeGlob  []ignorePattern
+	readIgnores  bool // honor IgnoreFileName files in the source tree
+	smart        bool // apply the SmartPresets detected in the source tree
+}
+
+// FilterDecision describes why a file was kept or excluded by a FileFilter.
+type FilterDecision int
+
+// Filter decisions
+const (
+	FilterInclude FilterDecision = iota
+	FilterExcludeSize
+	FilterExcludePattern
+	FilterExcludeTime
+)
+
+// NewFileFilter creates a filter that includes every file not excluded by an
+// IgnoreFileName file in the source tree.
+func NewFileFilter() *FileFilter {
+	return &FileFilter{readIgnores: true}
+}
+
+// SetIgnoreFiles sets whether IgnoreFileName files found in the source tree
+// exclude what they list. They are honored by default and apply on top of
+// the other rules of the filter, with the same case sensitivity.
+func (f *FileFilter) SetIgnoreFiles(enabled bool) {
+	f.readIgnores = enabled
+}
+
+// ignoreFilesIn returns the ignore files of the source tree at root, or nil
+// when they are not honored. onError is called for each one that cannot be
+// read or holds an invalid pattern.
+func (f *FileFilter) ignoreFilesIn(root string, onError func(path string, err error)) *ignoreFiles {
+	if !f.readIgnores {
+		return nil
+	}
+
+	return newIgnoreFiles(root, f.ignoreCase, onError)
+}
+
+// SetSmart sets whether the filter excludes the build output, caches and
+// version control directories of the kinds of project detected at the root of
+// the source tree, as listed in SmartPresets. Like IgnoreFileName files, the
+// exclusions apply on top of the other rules of the filter.
+func (f *FileFilter) SetSmart(enabled bool) {
+	f.smart = enabled
+}
+
+// SetSizeLimits excludes regular files smaller than minSize or larger than
+// maxSize. A zero limit disables that bound.
+func (f *FileFilter) SetSizeLimits(minSize, maxSize int64) {
+	f.minSize = minSize
+	f.maxSize = maxSize
+}
+
+// SetModifiedSince excludes regular files last modified before cutoff, so
+// only those changed since then are synced. A zero cutoff disables it.
+func (f *FileFilter) SetModifiedSince(cutoff time.Time) {
+	f.since = cutoff
+}
+
+// SetRegexPatterns compiles Go regular expressions matched against paths
+// relative to the sync root, using forward slashes on every platform. When
+// include patterns are given, a file must match at least one of them. A file
+// is excluded when its path or any parent directory's path matches an exclude
+// pattern.
+func (f *FileFilter) SetRegexPatterns(include, exclude []string) error {
+	includeRegex, err := compilePatterns("include", include, f.ignoreCase)
+	if err != nil {
+		return err
+	}
+
+	excludeRegex, err := compilePatterns("exclude", exclude, f.ignoreCase)
+	if err != nil {
+		return err
+	}
+
+	f.include, f.exclude = include, exclude
+	f.includeRegex, f.excludeRegex = includeRegex, excludeRegex
+
+	return nil
+}
+
+// SetGlobPatterns sets glob patterns in the syntax of IgnoreFileName files,
+// matched against paths relative to the sync root: one without a slash
+// matches a name at any depth, ** matches any number of directories and a
+// trailing / matches only directories. When include patterns are given, a
+// file must match at least one of them or of the include regular
+// expressions; a directory it is in matching counts. A file is excluded when
+// its path or any parent directory's path matches an exclude pattern.
+func (f *FileFilter) SetGlobPatterns(include, exclude []string) error {
+	includeGlob, err := compileGlobs("include", include, f.ignoreCase)
+	if err != nil {
+		return err
+	}
+
+	excludeGlob, err := compileGlobs("exclude", exclude, f.ignoreCase)
+	if err != nil {
+		return err
+	}
+
+	f.includeGlobs, f.excludeGlobs = include, exclude
+	f.includeGlob, f.excludeGlob = includeGlob, excludeGlob
+
+	return nil
+}
+
+// SetIgnoreCase makes every pattern match regardless of case, as paths do on
+// case-insensitive filesystems, so an exclude of `\.TMP$` also skips
+// file.tmp. It applies to patterns set before and after the call.
+func (f *FileFilter) SetIgnoreCase(enabled bool) {
+	f.ignoreCase = enabled
+
+	// The patterns already compiled once, and (?i) or lowering a glob keeps a
+	// valid one valid.
+	_ = f.SetRegexPatterns(f.include, f.exclude)
+	_ = f.SetGlobPatterns(f.includeGlobs, f.excludeGlobs)
+}
+
+func compileGlobs(kind string, patterns []string, ignoreCase bool) ([]ignorePattern, error) {
+	compiled := make([]ignorePattern, 0, len(patterns))
+
+	for _, pattern := range patterns {
+		glob, err := compileIgnorePattern(pattern, ignoreCase)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s glob: %w", kind, err)
+		}
+
+		compiled = append(compiled, glob)
+	}
+
+	return compiled, nil
+}
+
+// ReadPatternFile reads the patterns listed in the file at path, or on stdin
+// when path is "-", one per line. Surrounding whitespace is trimmed, and
+// blank lines and lines starting with # are skipped.
+func ReadPatternFile(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open pattern file: %w", err)
+		}
+
+		defer func() { _ = file.Close() }()
+
+		r = file
+	}
+
+	var patterns []string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		patterns = append(patterns, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read pattern file %s: %w", path, err)
+	}
+
+	return patterns, nil
+}
+
+func compilePatterns(kind string, patterns []string, ignoreCase bool) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+
+	for _, pattern := range patterns {
+		source := pattern
+		if ignoreCase {
+			source = "(?i)" + pattern
+		}
+
+		re, err := regexp.Compile(source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s regex %q: %w", kind, pattern, err)
+		}
+
+		compiled = append(compiled, re)
+	}
+
+	return compiled, nil
+}
+
+// Evaluate returns the filter decision for a scanned file at relPath.
+// Directories are never excluded by size, modification time or include
+// patterns so that their contents can still be reached.
+func (f *FileFilter) Evaluate(relPath string, info *FileInfo) FilterDecision {
+	slashPath := filepath.ToSlash(relPath)
+
+	for dir := slashPath; dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if matchesAny(f.excludeRegex, dir) {
+			return FilterExcludePattern
+		}
+	}
+
+	var names []string
+	if (len(f.includeGlob) > 0 || len(f.excludeGlob) > 0) && slashPath != "." {
+		names = strings.Split(slashPath, "/")
+		if f.ignoreCase {
+			names = strings.Split(strings.ToLower(slashPath), "/")
+		}
+	}
+
+	if matchesGlob(f.excludeGlob, names, info.IsDir) {
+		return FilterExcludePattern
+	}
+
+	if info.IsDir {
+		return FilterInclude
+	}
+
+	included := len(f.includeRegex) == 0 && len(f.includeGlob) == 0 ||
+		matchesAny(f.includeRegex, slashPath) || matchesGlob(f.includeGlob, names, false)
+	if !included {
+		return FilterExcludePattern
+	}
+
+	if f.minSize > 0 && info.Size < f.minSize {
+		return FilterExcludeSize
+	}
+
+	if f.maxSize > 0 && info.Size > f.maxSize {
+		return FilterExcludeSize
+	}
+
+	if !f.since.IsZero() && info.ModTime.Before(f.since) {
+		return FilterExcludeTime
+	}
+
+	return FilterInclude
+}
+
+// matchesGlob reports whether any of patterns matches the entry at the path
+// split into names, or a directory above it.
+func matchesGlob(patterns []ignorePattern, names []string, isDir bool) bool {
+	for end := 1; end <= len(names); end++ {
+		entryIsDir := end < len(names) || isDir
+
+		for _, pattern := range patterns {
+			if pattern.matches(names[:end], entryIsDir) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func matchesAny(patterns []*regexp.Regexp, value string) bool {
+	for _, re := range patterns {
+		if re.MatchString(value) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,387 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileFilterSizeLimits(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		minSize int64
+		maxSize int64
+		info    *FileInfo
+		want    FilterDecision
+	}{
+		{
+			name: "no limits includes everything",
+			info: &FileInfo{Size: 1 << 40},
+			want: FilterInclude,
+		},
+		{
+			name:    "larger than max is excluded",
+			maxSize: 100,
+			info:    &FileInfo{Size: 101},
+			want:    FilterExcludeSize,
+		},
+		{
+			name:    "equal to max is included",
+			maxSize: 100,
+			info:    &FileInfo{Size: 100},
+			want:    FilterInclude,
+		},
+		{
+			name:    "smaller than min is excluded",
+			minSize: 10,
+			info:    &FileInfo{Size: 9},
+			want:    FilterExcludeSize,
+		},
+		{
+			name:    "directories ignore size limits",
+			minSize: 10,
+			maxSize: 100,
+			info:    &FileInfo{Size: 4096, IsDir: true},
+			want:    FilterInclude,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			filter := NewFileFilter()
+			filter.SetSizeLimits(tt.minSize, tt.maxSize)
+
+			if got := filter.Evaluate("file", tt.info); got != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileFilterModifiedSince(t *testing.T) {
+	t.Parallel()
+
+	cutoff := time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	filter := NewFileFilter()
+	filter.SetModifiedSince(cutoff)
+
+	tests := []struct {
+		name string
+		info *FileInfo
+		want FilterDecision
+	}{
+		{"modified after the cutoff", &FileInfo{ModTime: cutoff.Add(time.Second)}, FilterInclude},
+		{"modified at the cutoff", &FileInfo{ModTime: cutoff}, FilterInclude},
+		{"modified before the cutoff", &FileInfo{ModTime: cutoff.Add(-time.Second)}, FilterExcludeTime},
+		{"directories are kept", &FileInfo{ModTime: cutoff.Add(-time.Hour), IsDir: true}, FilterInclude},
+	}
+
+	for _, tt := range tests {
+		if got := filter.Evaluate("file", tt.info); got != tt.want {
+			t.Errorf("%s: Evaluate() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFileFilterRegexPatterns(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		relPath string
+		isDir   bool
+		want    FilterDecision
+	}{
+		{
+			name:    "exclude matches extension",
+			exclude: []string{`\.(tmp|bak)$`},
+			relPath: "notes/draft.bak",
+			want:    FilterExcludePattern,
+		},
+		{
+			name:    "exclude leaves other files",
+			exclude: []string{`\.(tmp|bak)$`},
+			relPath: "notes/draft.txt",
+			want:    FilterInclude,
+		},
+		{
+			name:    "excluded directory excludes its contents",
+			exclude: []string{`^build$`},
+			relPath: "build/out/app.bin",
+			want:    FilterExcludePattern,
+		},
+		{
+			name:    "include matches date in file name",
+			include: []string{`\d{4}-\d{2}-\d{2}\.log$`},
+			relPath: "logs/2025-01-31.log",
+			want:    FilterInclude,
+		},
+		{
+			name:    "include rejects non-matching file",
+			include: []string{`\d{4}-\d{2}-\d{2}\.log$`},
+			relPath: "logs/latest.log",
+			want:    FilterExcludePattern,
+		},
+		{
+			name:    "include does not hide directories",
+			include: []string{`\.log$`},
+			relPath: "logs",
+			isDir:   true,
+			want:    FilterInclude,
+		},
+		{
+			name:    "exclude wins over include",
+			include: []string{`\.log$`},
+			exclude: []string{`^logs/old/`},
+			relPath: "logs/old/a.log",
+			want:    FilterExcludePattern,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			filter := NewFileFilter()
+			if err := filter.SetRegexPatterns(tt.include, tt.exclude); err != nil {
+				t.Fatalf("SetRegexPatterns failed: %v", err)
+			}
+
+			info := &FileInfo{Size: 1, IsDir: tt.isDir}
+			if got := filter.Evaluate(filepath.FromSlash(tt.relPath), info); got != tt.want {
+				t.Errorf("Evaluate(%q) = %v, want %v", tt.relPath, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileFilterGlobPatterns(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		include      []string
+		exclude      []string
+		includeRegex []string
+		relPath      string
+		isDir        bool
+		want         FilterDecision
+	}{
+		{
+			name:    "exclude matches a name at any depth",
+			exclude: []string{"*.tmp"},
+			relPath: "notes/draft.tmp",
+			want:    FilterExcludePattern,
+		},
+		{
+			name:    "excluded directory excludes its contents",
+			exclude: []string{"node_modules/"},
+			relPath: "web/node_modules/react/index.js",
+			want:    FilterExcludePattern,
+		},
+		{
+			name:    "anchored exclude only matches from the root",
+			exclude: []string{"/build"},
+			relPath: "src/build/main.go",
+			want:    FilterInclude,
+		},
+		{
+			name:    "include rejects non-matching file",
+			include: []string{"*.go"},
+			relPath: "README.md",
+			want:    FilterExcludePattern,
+		},
+		{
+			name:    "included directory includes its contents",
+			include: []string{"docs/"},
+			relPath: "docs/guide/intro.md",
+			want:    FilterInclude,
+		},
+		{
+			name:    "include does not hide directories",
+			include: []string{"*.go"},
+			relPath: "cmd",
+			isDir:   true,
+			want:    FilterInclude,
+		},
+		{
+			name:         "a file may match either kind of include",
+			include:      []string{"*.go"},
+			includeRegex: []string{`\.md$`},
+			relPath:      "README.md",
+			want:         FilterInclude,
+		},
+		{
+			name:    "exclude wins over include",
+			include: []string{"*.log"},
+			exclude: []string{"logs/old/**"},
+			relPath: "logs/old/a.log",
+			want:    FilterExcludePattern,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			filter := NewFileFilter()
+			if err := filter.SetGlobPatterns(tt.include, tt.exclude); err != nil {
+				t.Fatalf("SetGlobPatterns failed: %v", err)
+			}
+
+			if err := filter.SetRegexPatterns(tt.includeRegex, nil); err != nil {
+				t.Fatalf("SetRegexPatterns failed: %v", err)
+			}
+
+			info := &FileInfo{Size: 1, IsDir: tt.isDir}
+			if got := filter.Evaluate(filepath.FromSlash(tt.relPath), info); got != tt.want {
+				t.Errorf("Evaluate(%q) = %v, want %v", tt.relPath, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadPatternFile(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "excludes.txt")
+
+	content := "# build output\n*.o\n\n  build/  \n# caches\n.cache\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	patterns, err := ReadPatternFile(path)
+	if err != nil {
+		t.Fatalf("ReadPatternFile failed: %v", err)
+	}
+
+	if got, want := strings.Join(patterns, ","), "*.o,build/,.cache"; got != want {
+		t.Errorf("ReadPatternFile() = %s, want %s", got, want)
+	}
+
+	if _, err := ReadPatternFile(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("ReadPatternFile of a missing file succeeded, want an error")
+	}
+}
+
+func TestFileFilterInvalidRegex(t *testing.T) {
+	t.Parallel()
+
+	err := NewFileFilter().SetRegexPatterns(nil, []string{`(unclosed`})
+	if err == nil || !strings.Contains(err.Error(), "invalid exclude regex") {
+		t.Errorf("SetRegexPatterns() error = %v, want invalid exclude regex", err)
+	}
+}
+
+func TestFileFilterIgnoreCase(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		include    []string
+		exclude    []string
+		relPath    string
+		ignoreCase bool
+		want       FilterDecision
+	}{
+		{
+			name:    "upper-case exclude misses lower-case file by default",
+			exclude: []string{`\.TMP$`},
+			relPath: "cache/file.tmp",
+			want:    FilterInclude,
+		},
+		{
+			name:       "upper-case exclude matches lower-case file",
+			exclude:    []string{`\.TMP$`},
+			relPath:    "cache/file.tmp",
+			ignoreCase: true,
+			want:       FilterExcludePattern,
+		},
+		{
+			name:       "lower-case exclude matches mixed-case file",
+			exclude:    []string{`\.tmp$`},
+			relPath:    "cache/Report.Tmp",
+			ignoreCase: true,
+			want:       FilterExcludePattern,
+		},
+		{
+			name:       "mixed-case directory exclude matches its contents",
+			exclude:    []string{`^Build$`},
+			relPath:    "BUILD/out/app.bin",
+			ignoreCase: true,
+			want:       FilterExcludePattern,
+		},
+		{
+			name:       "alternation is case-insensitive throughout",
+			exclude:    []string{`\.bak$|\.old$`},
+			relPath:    "notes/draft.OLD",
+			ignoreCase: true,
+			want:       FilterExcludePattern,
+		},
+		{
+			name:       "include matches regardless of case",
+			include:    []string{`\.jpe?g$`},
+			relPath:    "photos/IMG_0001.JPG",
+			ignoreCase: true,
+			want:       FilterInclude,
+		},
+		{
+			name:    "include is case-sensitive by default",
+			include: []string{`\.jpe?g$`},
+			relPath: "photos/IMG_0001.JPG",
+			want:    FilterExcludePattern,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Set ignore-case on both sides of the patterns to cover recompiling.
+			for _, before := range []bool{true, false} {
+				filter := NewFileFilter()
+				if before {
+					filter.SetIgnoreCase(tt.ignoreCase)
+				}
+
+				if err := filter.SetRegexPatterns(tt.include, tt.exclude); err != nil {
+					t.Fatalf("SetRegexPatterns failed: %v", err)
+				}
+
+				if !before {
+					filter.SetIgnoreCase(tt.ignoreCase)
+				}
+
+				info := &FileInfo{Size: 1}
+				if got := filter.Evaluate(filepath.FromSlash(tt.relPath), info); got != tt.want {
+					t.Errorf("Evaluate(%q) with ignore-case set before patterns = %v: %v, want %v",
+						tt.relPath, before, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCaseInsensitive(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "CaseProbe")
+	if err := os.Mkdir(dir, 0o750); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+
+	// Detection must agree with what the filesystem actually does.
+	_, err := os.Stat(filepath.Join(filepath.Dir(dir), "cASEpROBE"))
+	if want := err == nil; CaseInsensitive(dir) != want {
+		t.Errorf("CaseInsensitive(%q) = %v, want %v", dir, !want, want)
+	}
+}
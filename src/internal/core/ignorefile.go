@@ -0,0 +1,205 @@
+package core
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// IgnoreFileName is the name of the files listing glob patterns to exclude
+// from the directory they are in and everything below it.
+const IgnoreFileName = ".relayignore"
+
+// ignorePattern is one line of an ignore file, split into path segments.
+type ignorePattern struct {
+	segments []string
+	dirOnly  bool
+}
+
+// parseIgnoreFile reads glob patterns from an ignore file, one per line.
+// Blank lines and lines starting with # are skipped. A pattern matches paths
+// relative to the directory holding the file: one without a slash matches a
+// name at any depth, one with a slash (or a leading /) is anchored to that
+// directory, ** matches any number of directories, and a trailing / matches
+// only directories. Anything matched is excluded along with its contents.
+func parseIgnoreFile(r io.Reader, ignoreCase bool) ([]ignorePattern, error) {
+	var patterns []ignorePattern
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		pattern, err := compileIgnorePattern(line, ignoreCase)
+		if err != nil {
+			return nil, err
+		}
+
+		patterns = append(patterns, pattern)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ignore file: %w", err)
+	}
+
+	return patterns, nil
+}
+
+func compileIgnorePattern(line string, ignoreCase bool) (ignorePattern, error) {
+	pattern := ignorePattern{dirOnly: strings.HasSuffix(line, "/")}
+
+	glob := strings.Trim(line, "/")
+	if glob == "" {
+		return pattern, fmt.Errorf("invalid ignore pattern %q: matches nothing", line)
+	}
+
+	if ignoreCase {
+		glob = strings.ToLower(glob)
+	}
+
+	// Anchored patterns start at the ignore file's directory; others may
+	// match at any depth below it.
+	if !strings.HasPrefix(line, "/") && !strings.Contains(glob, "/") {
+		glob = "**/" + glob
+	}
+
+	pattern.segments = strings.Split(glob, "/")
+
+	for _, segment := range pattern.segments {
+		if _, err := path.Match(segment, ""); err != nil {
+			return pattern, fmt.Errorf("invalid ignore pattern %q: %w", line, err)
+		}
+	}
+
+	return pattern, nil
+}
+
+// matches reports whether the entry at the slash-separated segments of name
+// is matched by the pattern.
+func (p ignorePattern) matches(name []string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+
+	return matchSegments(p.segments, name)
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+
+		if matched, _ := path.Match(pattern[0], name[0]); !matched {
+			return false
+		}
+
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
+
+// ignoreFiles loads the ignore files of a source tree as the scan reaches
+// them. It is safe for concurrent use.
+type ignoreFiles struct {
+	root       string
+	ignoreCase bool
+	onError    func(path string, err error)
+	mu         sync.Mutex
+	dirs       map[string][]ignorePattern // by slash-separated relative directory
+}
+
+func newIgnoreFiles(root string, ignoreCase bool, onError func(path string, err error)) *ignoreFiles {
+	return &ignoreFiles{
+		root:       root,
+		ignoreCase: ignoreCase,
+		onError:    onError,
+		dirs:       make(map[string][]ignorePattern),
+	}
+}
+
+// excluded reports whether the entry at relPath, or any directory above it,
+// is matched by an ignore file in a directory above that entry.
+func (i *ignoreFiles) excluded(relPath string, isDir bool) bool {
+	slashPath := filepath.ToSlash(relPath)
+	if slashPath == "." {
+		return false
+	}
+
+	parts := strings.Split(slashPath, "/")
+
+	names := parts
+	if i.ignoreCase {
+		names = strings.Split(strings.ToLower(slashPath), "/")
+	}
+
+	for end := 1; end <= len(parts); end++ {
+		entryIsDir := end < len(parts) || isDir
+
+		for start := range end {
+			for _, pattern := range i.load(parts[:start]) {
+				if pattern.matches(names[start:end], entryIsDir) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// load returns the patterns of the ignore file in the directory at dirParts,
+// reading it on first use. A missing file has no patterns; any other failure
+// is reported to onError once and also leaves the directory without them.
+func (i *ignoreFiles) load(dirParts []string) []ignorePattern {
+	dir := path.Join(dirParts...)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if patterns, loaded := i.dirs[dir]; loaded {
+		return patterns
+	}
+
+	filePath := filepath.Join(i.root, filepath.FromSlash(dir), IgnoreFileName)
+
+	patterns, err := i.read(filePath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		i.onError(filePath, err)
+	}
+
+	i.dirs[dir] = patterns
+
+	return patterns
+}
+
+func (i *ignoreFiles) read(filePath string) ([]ignorePattern, error) {
+	file, err := os.Open(toExtendedPath(filePath))
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = file.Close() }()
+
+	return parseIgnoreFile(file, i.ignoreCase)
+}
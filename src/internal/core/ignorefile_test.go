@@ -0,0 +1,203 @@
+package core
+
+import (
+	"context"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIgnoreFilesExcluded(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		files      map[string]string // ignore file contents by directory
+		relPath    string
+		isDir      bool
+		ignoreCase bool
+		want       bool
+	}{
+		{
+			name:    "name pattern matches at any depth",
+			files:   map[string]string{".": "*.tmp"},
+			relPath: "a/b/file.tmp",
+			want:    true,
+		},
+		{
+			name:    "name pattern does not match a longer name",
+			files:   map[string]string{".": "*.tmp"},
+			relPath: "a/file.tmp.keep",
+		},
+		{
+			name:    "matched directory excludes its contents",
+			files:   map[string]string{".": "node_modules"},
+			relPath: "web/node_modules/pkg/index.js",
+			want:    true,
+		},
+		{
+			name:    "anchored pattern matches only from its directory",
+			files:   map[string]string{".": "/build"},
+			relPath: "src/build",
+			isDir:   true,
+		},
+		{
+			name:    "anchored pattern matches at its directory",
+			files:   map[string]string{".": "/build"},
+			relPath: "build/app.bin",
+			want:    true,
+		},
+		{
+			name:    "pattern with a slash is anchored",
+			files:   map[string]string{".": "docs/*.pdf"},
+			relPath: "other/docs/manual.pdf",
+		},
+		{
+			name:    "double star spans directories",
+			files:   map[string]string{".": "logs/**/*.log"},
+			relPath: "logs/2024/01/app.log",
+			want:    true,
+		},
+		{
+			name:    "directory pattern skips files",
+			files:   map[string]string{".": "cache/"},
+			relPath: "cache",
+		},
+		{
+			name:    "directory pattern matches directories",
+			files:   map[string]string{".": "cache/"},
+			relPath: "cache/entry",
+			want:    true,
+		},
+		{
+			name:    "nested file is relative to its directory",
+			files:   map[string]string{"project": "/out"},
+			relPath: "project/out/app.bin",
+			want:    true,
+		},
+		{
+			name:    "nested file does not reach outside its directory",
+			files:   map[string]string{"project": "*.bak"},
+			relPath: "other/notes.bak",
+		},
+		{
+			name:    "comments and blank lines are skipped",
+			files:   map[string]string{".": "# *.txt\n\n  *.bak  \n"},
+			relPath: "notes.txt",
+		},
+		{
+			name:    "patterns are case-sensitive by default",
+			files:   map[string]string{".": "*.TMP"},
+			relPath: "file.tmp",
+		},
+		{
+			name:       "ignore-case matches regardless of case",
+			files:      map[string]string{".": "*.TMP"},
+			relPath:    "Cache/File.tmp",
+			ignoreCase: true,
+			want:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			root := t.TempDir()
+
+			for dir, content := range tt.files {
+				writeTreeFile(t, filepath.Join(root, dir, IgnoreFileName), content, time.Now())
+			}
+
+			ignores := newIgnoreFiles(root, tt.ignoreCase, func(path string, err error) {
+				t.Errorf("unexpected error for %s: %v", path, err)
+			})
+
+			if got := ignores.excluded(filepath.FromSlash(tt.relPath), tt.isDir); got != tt.want {
+				t.Errorf("excluded(%q) = %v, want %v", tt.relPath, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyncEngineIgnoreFile(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	destDir := filepath.Join(tempDir, "dest")
+
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	writeTreeFile(t, filepath.Join(sourceDir, IgnoreFileName), "*.log\n/tmp/\n", modTime)
+	writeTreeFile(t, filepath.Join(sourceDir, "keep.txt"), "keep", modTime)
+	writeTreeFile(t, filepath.Join(sourceDir, "debug.log"), "log", modTime)
+	writeTreeFile(t, filepath.Join(sourceDir, "tmp", "scratch.txt"), "scratch", modTime)
+	writeTreeFile(t, filepath.Join(sourceDir, "app", IgnoreFileName), "dist\n", modTime)
+	writeTreeFile(t, filepath.Join(sourceDir, "app", "main.go"), "main", modTime)
+	writeTreeFile(t, filepath.Join(sourceDir, "app", "dist", "bundle.js"), "bundle", modTime)
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	mirrorTree(t, engine, sourceDir, destDir)
+
+	want := map[string]string{
+		IgnoreFileName:          "*.log\n/tmp/\n",
+		"keep.txt":              "keep",
+		"app/":                  "",
+		"app/" + IgnoreFileName: "dist\n",
+		"app/main.go":           "main",
+	}
+	if got := readTree(t, destDir); !maps.Equal(got, want) {
+		t.Errorf("destination = %v, want %v", got, want)
+	}
+
+	if stats := engine.GetStats(); stats.ExcludedByPattern == 0 {
+		t.Error("ExcludedByPattern = 0, want the ignored entries counted")
+	}
+
+	// Disabled, the same tree is mirrored in full.
+	filter := NewFileFilter()
+	filter.SetIgnoreFiles(false)
+	engine.SetFilter(filter)
+
+	mirrorTree(t, engine, sourceDir, destDir)
+
+	if _, err := os.Stat(filepath.Join(destDir, "app", "dist", "bundle.js")); err != nil {
+		t.Errorf("ignored file not copied with ignore files disabled: %v", err)
+	}
+}
+
+func TestSyncEngineInvalidIgnoreFile(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	destDir := filepath.Join(tempDir, "dest")
+
+	writeTreeFile(t, filepath.Join(sourceDir, IgnoreFileName), "[unclosed\n", time.Now())
+	writeTreeFile(t, filepath.Join(sourceDir, "file.txt"), "file", time.Now())
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	if err := engine.Mirror(context.Background(), sourceDir, destDir); err != nil {
+		t.Fatalf("Mirror failed: %v", err)
+	}
+
+	syncErrors := engine.GetErrors()
+	if len(syncErrors) != 1 || !strings.Contains(syncErrors[0].Error(), "invalid ignore pattern") {
+		t.Fatalf("errors = %v, want one invalid ignore pattern error", syncErrors)
+	}
+
+	// The unusable file excludes nothing.
+	if _, err := os.Stat(filepath.Join(destDir, "file.txt")); err != nil {
+		t.Errorf("file.txt not copied: %v", err)
+	}
+}
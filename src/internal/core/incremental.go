@@ -0,0 +1,107 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/zeebo/blake3"
+)
+
+const (
+	// incrementalMinSize is the smallest file whose hash state is kept for
+	// resuming; smaller files are cheap enough to hash again in full.
+	incrementalMinSize = 1 << 20
+
+	// incrementalGuardLen is how many bytes at each end of the previously
+	// hashed content are read back to check it was only appended to.
+	incrementalGuardLen = 4096
+)
+
+// resumableHash is the blake3 state after hashing the first size bytes of a
+// file, kept so that bytes appended later can be hashed on their own. At
+// about 20KB each, states are only kept for files that have been seen to
+// grow.
+type resumableHash struct {
+	hasher *blake3.Hasher
+	size   int64
+	guard  []byte // the first and last incrementalGuardLen of those bytes
+}
+
+// incremental reports whether a file of size bytes that grew can be hashed
+// from the state kept for its earlier content. The state is only kept for the
+// plain digest, so not with a second algorithm or sampling.
+func (s *FileScanner) incremental(size int64) bool {
+	return size >= incrementalMinSize && s.checksumAlgo == "blake3" && s.secondaryAlgo == "" && !s.sampled(size)
+}
+
+// appendChecksum returns the digest of file, which is size bytes long, and
+// the state to resume from when it grows again. When previous is the state of
+// a shorter version whose bytes at both ends are unchanged, only the bytes
+// past it are read; otherwise the file is hashed in full. Files that are
+// rewritten rather than appended to almost always change at one end, but a
+// rewrite that keeps both is taken for an append.
+func (s *FileScanner) appendChecksum(file *os.File, size int64, previous *resumableHash) (string, *resumableHash, error) {
+	var (
+		hasher *blake3.Hasher
+		offset int64
+	)
+
+	if previous != nil && previous.size < size {
+		guard, err := readGuard(file, previous.size)
+		if err != nil {
+			return "", nil, err
+		}
+
+		if bytes.Equal(guard, previous.guard) {
+			hasher, offset = previous.hasher.Clone(), previous.size
+		}
+	}
+
+	if hasher == nil {
+		primary, err := s.newHasher(s.checksumAlgo)
+		if err != nil {
+			return "", nil, err
+		}
+
+		hasher = primary.(*blake3.Hasher)
+	}
+
+	n, err := io.Copy(hasher, io.NewSectionReader(file, offset, size-offset))
+	if err != nil {
+		return "", nil, err
+	}
+
+	if n < size-offset {
+		return "", nil, fmt.Errorf("file shrank to %d bytes while being hashed: %w", offset+n, io.ErrUnexpectedEOF)
+	}
+
+	guard, err := readGuard(file, size)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Sum may change the hasher's internal buffers, so the state is cloned first.
+	next := &resumableHash{hasher: hasher.Clone(), size: size, guard: guard}
+
+	return hex.EncodeToString(hasher.Sum(nil)), next, nil
+}
+
+// readGuard returns the first and last incrementalGuardLen bytes of the first
+// size bytes of file.
+func readGuard(file *os.File, size int64) ([]byte, error) {
+	n := min(size, incrementalGuardLen)
+	guard := make([]byte, 2*n)
+
+	if _, err := file.ReadAt(guard[:n], 0); err != nil {
+		return nil, fmt.Errorf("failed to read start of file: %w", err)
+	}
+
+	if _, err := file.ReadAt(guard[n:], size-n); err != nil {
+		return nil, fmt.Errorf("failed to read end of hashed content: %w", err)
+	}
+
+	return guard, nil
+}
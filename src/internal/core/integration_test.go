@@ -0,0 +1,300 @@
+package core
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/howmanysmall/relay/src/internal/config"
+)
+
+// mirrorScenario drives SyncEngine.Mirror end to end: the initial tree is
+// mirrored, mutate changes source and/or destination, and a second mirror must
+// leave the destination exactly matching want. A nil want means the
+// destination must match the final source tree. New features should add a
+// scenario here alongside their unit tests.
+type mirrorScenario struct {
+	name      string
+	initial   map[string]string
+	configure func(t *testing.T, engine *SyncEngine)
+	mutate    func(t *testing.T, source, dest string)
+	want      map[string]string
+}
+
+func TestMirrorIntegration(t *testing.T) {
+	t.Parallel()
+
+	baseTime := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+	editTime := baseTime.Add(time.Hour)
+
+	withDelete := func(t *testing.T, engine *SyncEngine) {
+		t.Helper()
+
+		opts := engine.Options()
+		opts.DeleteExtraneous = true
+		engine.SetOptions(opts)
+	}
+
+	withStrategy := func(strategy config.ConflictStrategy) func(*testing.T, *SyncEngine) {
+		return func(_ *testing.T, engine *SyncEngine) {
+			engine.SetConflictConfig(&config.ConflictConfig{Strategy: string(strategy)})
+		}
+	}
+
+	editDestination := func(t *testing.T, _, dest string) {
+		t.Helper()
+		writeTreeFile(t, filepath.Join(dest, "doc.txt"), "destination edit", time.Now())
+	}
+
+	scenarios := []mirrorScenario{
+		{
+			name: "add modify delete and rename with delete",
+			initial: map[string]string{
+				"a.txt":             "original",
+				"old.txt":           "obsolete",
+				"docs/guide.md":     "# Guide",
+				"docs/img/logo.png": "png",
+			},
+			configure: withDelete,
+			mutate: func(t *testing.T, source, _ string) {
+				t.Helper()
+				writeTreeFile(t, filepath.Join(source, "a.txt"), "modified", editTime)
+				writeTreeFile(t, filepath.Join(source, "new.txt"), "new", editTime)
+				removeTreePath(t, filepath.Join(source, "old.txt"))
+
+				if err := os.Rename(filepath.Join(source, "docs"), filepath.Join(source, "manual")); err != nil {
+					t.Fatalf("Failed to rename: %v", err)
+				}
+			},
+		},
+		{
+			name: "extraneous files remain without delete",
+			initial: map[string]string{
+				"a.txt":   "original",
+				"old.txt": "obsolete",
+			},
+			mutate: func(t *testing.T, source, _ string) {
+				t.Helper()
+				writeTreeFile(t, filepath.Join(source, "a.txt"), "modified", editTime)
+				removeTreePath(t, filepath.Join(source, "old.txt"))
+			},
+			want: map[string]string{
+				"a.txt":   "modified",
+				"old.txt": "obsolete",
+			},
+		},
+		{
+			name: "filtered files are neither copied nor deleted",
+			initial: map[string]string{
+				"small.txt": "small",
+				"large.bin": "much too large",
+			},
+			configure: func(t *testing.T, engine *SyncEngine) {
+				t.Helper()
+				withDelete(t, engine)
+
+				filter := NewFileFilter()
+				filter.SetSizeLimits(0, 8)
+				engine.SetFilter(filter)
+			},
+			want: map[string]string{
+				"small.txt": "small",
+			},
+		},
+		{
+			name: "regex filters select files",
+			initial: map[string]string{
+				"logs/2025-01-31.log": "dated",
+				"logs/latest.log":     "latest",
+				"notes/draft.bak":     "backup",
+			},
+			configure: func(t *testing.T, engine *SyncEngine) {
+				t.Helper()
+
+				filter := NewFileFilter()
+				if err := filter.SetRegexPatterns([]string{`\d{4}-\d{2}-\d{2}\.log$`}, []string{`^notes$`}); err != nil {
+					t.Fatalf("SetRegexPatterns failed: %v", err)
+				}
+
+				engine.SetFilter(filter)
+			},
+			want: map[string]string{
+				"logs/2025-01-31.log": "dated",
+			},
+		},
+		{
+			name:      "conflict newest keeps newer destination",
+			initial:   map[string]string{"doc.txt": "v1"},
+			configure: withStrategy(config.ConflictNewest),
+			mutate:    editDestination,
+			want:      map[string]string{"doc.txt": "destination edit"},
+		},
+		{
+			name:      "conflict source overwrites destination",
+			initial:   map[string]string{"doc.txt": "v1"},
+			configure: withStrategy(config.ConflictSource),
+			mutate:    editDestination,
+			want:      map[string]string{"doc.txt": "v1"},
+		},
+		{
+			name:      "conflict destination keeps destination",
+			initial:   map[string]string{"doc.txt": "v1"},
+			configure: withStrategy(config.ConflictDestination),
+			mutate:    editDestination,
+			want:      map[string]string{"doc.txt": "destination edit"},
+		},
+		{
+			name:      "conflict skip leaves destination",
+			initial:   map[string]string{"doc.txt": "v1"},
+			configure: withStrategy(config.ConflictSkip),
+			mutate:    editDestination,
+			want:      map[string]string{"doc.txt": "destination edit"},
+		},
+	}
+
+	for _, sc := range scenarios {
+		t.Run(sc.name, func(t *testing.T) {
+			t.Parallel()
+			tempDir := t.TempDir()
+
+			sourceDir := filepath.Join(tempDir, "source")
+			destDir := filepath.Join(tempDir, "dest")
+
+			for rel, content := range sc.initial {
+				writeTreeFile(t, filepath.Join(sourceDir, filepath.FromSlash(rel)), content, baseTime)
+			}
+
+			engine, err := NewSyncEngine()
+			if err != nil {
+				t.Fatalf("NewSyncEngine failed: %v", err)
+			}
+
+			if sc.configure != nil {
+				sc.configure(t, engine)
+			}
+
+			mirrorTree(t, engine, sourceDir, destDir)
+
+			if sc.mutate != nil {
+				sc.mutate(t, sourceDir, destDir)
+			}
+
+			mirrorTree(t, engine, sourceDir, destDir)
+
+			want := sc.want
+			if want == nil {
+				want = readTree(t, sourceDir)
+			} else {
+				want = withParentDirs(want)
+			}
+
+			got := readTree(t, destDir)
+			for rel, content := range want {
+				if gotContent, ok := got[rel]; !ok {
+					t.Errorf("Destination is missing %s", rel)
+				} else if gotContent != content {
+					t.Errorf("Destination %s = %q, want %q", rel, gotContent, content)
+				}
+			}
+
+			for rel := range got {
+				if _, ok := want[rel]; !ok {
+					t.Errorf("Destination has unexpected %s", rel)
+				}
+			}
+		})
+	}
+}
+
+func mirrorTree(t *testing.T, engine *SyncEngine, source, dest string) {
+	t.Helper()
+
+	if err := engine.Mirror(context.Background(), source, dest); err != nil {
+		t.Fatalf("Mirror failed: %v", err)
+	}
+
+	if stats := engine.GetStats(); stats.ErrorsEncountered != 0 {
+		t.Fatalf("Mirror encountered %d errors: %v", stats.ErrorsEncountered, engine.GetErrors())
+	}
+}
+
+func writeTreeFile(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("Failed to set times on %s: %v", path, err)
+	}
+}
+
+func removeTreePath(t *testing.T, path string) {
+	t.Helper()
+
+	if err := os.RemoveAll(path); err != nil {
+		t.Fatalf("Failed to remove %s: %v", path, err)
+	}
+}
+
+// readTree maps each slash-separated relative path under root to its
+// content. Directories are recorded with a trailing slash and empty content.
+func readTree(t *testing.T, root string) map[string]string {
+	t.Helper()
+
+	tree := make(map[string]string)
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == root {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			tree[filepath.ToSlash(rel)+"/"] = ""
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		tree[filepath.ToSlash(rel)] = string(content)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to read tree %s: %v", root, err)
+	}
+
+	return tree
+}
+
+// withParentDirs adds the directory entries readTree reports for each file.
+func withParentDirs(files map[string]string) map[string]string {
+	tree := make(map[string]string, len(files))
+
+	for rel, content := range files {
+		tree[rel] = content
+
+		for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+			tree[dir+"/"] = ""
+		}
+	}
+
+	return tree
+}
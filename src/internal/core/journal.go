@@ -0,0 +1,98 @@
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// journalMu serializes appends from this process, such as the jobs of relay
+// schedule finishing together, so that their lines never interleave.
+var journalMu sync.Mutex
+
+// DefaultJournalPath returns where the run journal is kept: a file in the
+// user cache directory, beside the status files, shared by every config file
+// and profile.
+func DefaultJournalPath() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+
+	return filepath.Join(cacheDir, "relay", "history.jsonl"), nil
+}
+
+// AppendJournal adds run to the end of the journal at path, one JSON object
+// per line. Earlier entries are never rewritten.
+func AppendJournal(path string, run *ProfileStatus) error {
+	run.Version = profileStatusVersion
+
+	data, err := json.Marshal(run)
+	if err != nil {
+		return fmt.Errorf("failed to encode run: %w", err)
+	}
+
+	journalMu.Lock()
+	defer journalMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("failed to create journal directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+
+	// One write per entry, so that runs in other processes appending at the
+	// same time do not split it.
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to append to journal: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close journal: %w", err)
+	}
+
+	return nil
+}
+
+// ReadJournal returns the runs in the journal at path, oldest first, or nil
+// when none has been recorded. Lines that cannot be parsed, such as one cut
+// short by a crash, and entries in another format are skipped.
+func ReadJournal(path string) ([]ProfileStatus, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var runs []ProfileStatus
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		var run ProfileStatus
+		if err := json.Unmarshal(scanner.Bytes(), &run); err != nil || run.Version != profileStatusVersion {
+			continue
+		}
+
+		runs = append(runs, run)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+
+	return runs, nil
+}
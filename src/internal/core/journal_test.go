@@ -0,0 +1,62 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJournalAppendAndRead(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "relay", "history.jsonl")
+
+	if runs, err := ReadJournal(path); runs != nil || err != nil {
+		t.Fatalf("ReadJournal before any run = %v, %v; want nil, nil", runs, err)
+	}
+
+	finished := time.Now().Truncate(time.Second)
+
+	for i, profile := range []string{"default", "nas", "default"} {
+		run := &ProfileStatus{
+			Profile:       profile,
+			Command:       "mirror",
+			Finished:      finished.Add(time.Duration(i) * time.Minute),
+			PendingErrors: i,
+			Stats:         &SyncStats{FilesChanged: int64(i + 1)},
+		}
+
+		if err := AppendJournal(path, run); err != nil {
+			t.Fatalf("AppendJournal failed: %v", err)
+		}
+	}
+
+	// A line cut short by a crash and an entry in another format are skipped.
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatalf("Failed to open journal: %v", err)
+	}
+
+	if _, err := file.WriteString("{\"version\": 99, \"profile\": \"nas\"}\n{\"version\": 1, \"prof"); err != nil {
+		t.Fatalf("Failed to write journal: %v", err)
+	}
+
+	_ = file.Close()
+
+	runs, err := ReadJournal(path)
+	if err != nil {
+		t.Fatalf("ReadJournal failed: %v", err)
+	}
+
+	if len(runs) != 3 {
+		t.Fatalf("ReadJournal returned %d runs, want 3: %+v", len(runs), runs)
+	}
+
+	for i, want := range []string{"default", "nas", "default"} {
+		run := runs[i]
+		if run.Profile != want || !run.Finished.Equal(finished.Add(time.Duration(i)*time.Minute)) ||
+			run.PendingErrors != i || run.Stats.FilesChanged != int64(i+1) {
+			t.Errorf("run %d = %+v, want profile %s in order", i, run, want)
+		}
+	}
+}
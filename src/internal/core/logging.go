@@ -0,0 +1,195 @@
+package core
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// discardLogger is the logger of engines, copiers and watchers that were
+// given none.
+var discardLogger = slog.New(slog.DiscardHandler)
+
+// SetLogger makes the engine, and its copier and watcher, log to logger: runs
+// starting and finishing and errors collected at info and warn level, and
+// every change made to the destination at debug level. A nil logger turns
+// logging off.
+func (e *SyncEngine) SetLogger(logger *slog.Logger) {
+	if logger == nil {
+		logger = discardLogger
+	}
+
+	e.mu.Lock()
+	e.logger = logger
+	e.mu.Unlock()
+
+	e.copier.SetLogger(logger)
+	e.watcher.SetLogger(logger)
+	e.errorHandler.setLogger(logger)
+}
+
+// SetLogger makes the copier log each file it copies, and how, at debug
+// level. A nil logger turns logging off.
+func (fc *FileCopier) SetLogger(logger *slog.Logger) {
+	if logger == nil {
+		logger = discardLogger
+	}
+
+	fc.logger = logger
+}
+
+// SetLogger makes the watcher log the changes it sees at debug level, and
+// the ones it drops and the errors of the file system at warn level. A nil
+// logger turns logging off.
+func (fw *FileWatcher) SetLogger(logger *slog.Logger) {
+	if logger == nil {
+		logger = discardLogger
+	}
+
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	fw.logger = logger
+}
+
+// log returns the watcher's logger.
+func (fw *FileWatcher) log() *slog.Logger {
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+
+	return fw.logger
+}
+
+// setLogger makes AddError log each error at warn level.
+func (eh *ErrorHandler) setLogger(logger *slog.Logger) {
+	eh.mu.Lock()
+	defer eh.mu.Unlock()
+
+	eh.logger = logger
+}
+
+// LogFile is a log file that rotates itself: once a write would take it past
+// its maximum size, it is renamed to path.1, path.1 to path.2 and so on, the
+// oldest is deleted, and a new file is started. It is safe for concurrent use.
+type LogFile struct {
+	path    string
+	maxSize int64
+	backups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// OpenLogFile opens the log file at path for appending, creating it and its
+// directory if needed. It is rotated once it reaches maxSize bytes, keeping
+// the given number of rotated files; a maxSize of zero never rotates it.
+func OpenLogFile(path string, maxSize int64, backups int) (*LogFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	l := &LogFile{path: path, maxSize: maxSize, backups: backups}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Write appends p to the log file, rotating it first if p would take it past
+// its maximum size. A single write larger than that is never split.
+func (l *LogFile) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(p)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := l.file.Write(p)
+	l.size += int64(n)
+
+	return n, err
+}
+
+// Close closes the log file.
+func (l *LogFile) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+
+	err := l.file.Close()
+	l.file = nil
+
+	if err != nil {
+		return fmt.Errorf("failed to close log file %s: %w", l.path, err)
+	}
+
+	return nil
+}
+
+// open opens the log file for appending and notes its size.
+func (l *LogFile) open() error {
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	l.file, l.size = file, info.Size()
+
+	return nil
+}
+
+// rotate moves the current log file aside and starts a new one. The caller
+// must hold l.mu.
+func (l *LogFile) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file %s: %w", l.path, err)
+	}
+
+	l.file = nil
+
+	if l.backups < 1 {
+		if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate log file: %w", err)
+		}
+	} else {
+		_ = os.Remove(l.backupPath(l.backups))
+
+		for i := l.backups - 1; i >= 1; i-- {
+			if err := os.Rename(l.backupPath(i), l.backupPath(i+1)); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to rotate log file: %w", err)
+			}
+		}
+
+		if err := os.Rename(l.path, l.backupPath(1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate log file: %w", err)
+		}
+	}
+
+	return l.open()
+}
+
+// backupPath returns the path of the i-th most recent rotated log file.
+func (l *LogFile) backupPath(i int) string {
+	return l.path + "." + strconv.Itoa(i)
+}
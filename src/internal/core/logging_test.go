@@ -0,0 +1,100 @@
+package core
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogFileRotates(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "logs", "relay.log")
+
+	log, err := OpenLogFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("OpenLogFile failed: %v", err)
+	}
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := log.Write([]byte(line)); err != nil {
+			t.Fatalf("Write(%q) failed: %v", line, err)
+		}
+	}
+
+	if err := log.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Each line takes the file past 10 bytes, so each starts a new file and
+	// only the two most recent rotated files are kept.
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path, "fourth\n"},
+		{path + ".1", "third\n"},
+		{path + ".2", "second\n"},
+	}
+
+	for _, tt := range tests {
+		data, err := os.ReadFile(tt.path)
+		if err != nil || string(data) != tt.want {
+			t.Errorf("%s = %q (err %v), want %q", filepath.Base(tt.path), data, err, tt.want)
+		}
+	}
+
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("%s.3 exists (err %v), want only 2 rotated files", filepath.Base(path), err)
+	}
+
+	if _, err := log.Write([]byte("late\n")); err == nil {
+		t.Error("Write after Close succeeded, want an error")
+	}
+
+	// Reopening appends to the existing file.
+	log, err = OpenLogFile(path, 0, 2)
+	if err != nil {
+		t.Fatalf("OpenLogFile failed: %v", err)
+	}
+
+	if _, err := log.Write([]byte("fifth\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	_ = log.Close()
+
+	if data, _ := os.ReadFile(path); string(data) != "fourth\nfifth\n" {
+		t.Errorf("reopened log = %q, want it appended to", data)
+	}
+}
+
+func TestSyncEngineLogsRun(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	destDir := filepath.Join(tempDir, "dest")
+
+	writeTreeFile(t, filepath.Join(sourceDir, "new.txt"), "fresh", time.Now().Add(-time.Hour))
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	engine.SetLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	mirrorTree(t, engine, sourceDir, destDir)
+
+	logged := buf.String()
+	for _, want := range []string{`msg="run started"`, `msg="file copied"`, `msg=change type=create path=new.txt`, `msg="run finished"`} {
+		if !strings.Contains(logged, want) {
+			t.Errorf("log does not contain %s:\n%s", want, logged)
+		}
+	}
+}
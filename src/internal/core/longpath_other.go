@@ -0,0 +1,13 @@
+//go:build !windows
+
+package core
+
+// toExtendedPath returns path unchanged; only Windows limits path length.
+func toExtendedPath(path string) string {
+	return path
+}
+
+// fromExtendedPath returns path unchanged; only Windows limits path length.
+func fromExtendedPath(path string) string {
+	return path
+}
@@ -0,0 +1,42 @@
+//go:build windows
+
+package core
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+const (
+	extendedPathPrefix = `\\?\`
+	extendedUNCPrefix  = `\\?\UNC\`
+)
+
+// toExtendedPath adds the \\?\ prefix to absolute paths so Windows APIs
+// accept paths longer than MAX_PATH (260 characters). Relative and already
+// prefixed paths are returned unchanged.
+func toExtendedPath(path string) string {
+	if strings.HasPrefix(path, extendedPathPrefix) || !filepath.IsAbs(path) {
+		return path
+	}
+
+	// Extended paths are passed to the filesystem verbatim, so they must
+	// already be clean: no forward slashes, "." or ".." elements.
+	path = filepath.Clean(path)
+
+	if strings.HasPrefix(path, `\\`) {
+		return extendedUNCPrefix + path[2:]
+	}
+
+	return extendedPathPrefix + path
+}
+
+// fromExtendedPath strips the prefix added by toExtendedPath so paths shown
+// to users and compared with filepath.Rel keep their usual form.
+func fromExtendedPath(path string) string {
+	if rest, found := strings.CutPrefix(path, extendedUNCPrefix); found {
+		return `\\` + rest
+	}
+
+	return strings.TrimPrefix(path, extendedPathPrefix)
+}
@@ -0,0 +1,40 @@
+//go:build windows
+
+package core
+
+import "testing"
+
+func TestToExtendedPath(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{name: "drive path", path: `C:\data\project`, want: `\\?\C:\data\project`},
+		{name: "unclean path", path: `C:/data/./project/../src`, want: `\\?\C:\data\src`},
+		{name: "unc path", path: `\\server\share\dir`, want: `\\?\UNC\server\share\dir`},
+		{name: "already extended", path: `\\?\C:\data`, want: `\\?\C:\data`},
+		{name: "relative path", path: `data\project`, want: `data\project`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := toExtendedPath(tt.path)
+			if got != tt.want {
+				t.Errorf("toExtendedPath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+
+			if tt.path == tt.want {
+				return
+			}
+
+			if back := fromExtendedPath(got); toExtendedPath(back) != got {
+				t.Errorf("fromExtendedPath(%q) = %q does not round-trip", got, back)
+			}
+		})
+	}
+}
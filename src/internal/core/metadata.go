@@ -0,0 +1,88 @@
+package core
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"sync/atomic"
+)
+
+// contentMatches reports whether dest is known to hold the same bytes as
+// source: both are regular files of the same size whose checksums agree.
+// Empty files always match; files without checksums never do.
+func contentMatches(source, dest *FileInfo) bool {
+	if source.IsDir || dest.IsDir || source.Size != dest.Size {
+		return false
+	}
+
+	if source.Size == 0 {
+		return true
+	}
+
+	if source.Checksum == "" || dest.Checksum == "" {
+		return false
+	}
+
+	return !checksumsDiffer(source, dest)
+}
+
+// metadataDiffers reports whether the permissions or modification time of
+// dest, as far as opts preserves them, differ from those of source.
+func metadataDiffers(source, dest *FileInfo, opts SyncOptions) bool {
+	if opts.PreservePerms && fs.FileMode(source.Mode).Perm() != fs.FileMode(dest.Mode).Perm() {
+		return true
+	}
+
+	return opts.PreserveTimes && source.ModTime.Sub(dest.ModTime).Abs() > opts.ModifyWindow
+}
+
+// syncMetadata brings destFile, whose content already matches sourceFile, up
+// to date by setting its permissions and modification time instead of copying
+// it again, and counts it in stats. A failure is recorded and returned.
+func (e *SyncEngine) syncMetadata(relPath, destPath string, sourceFile, destFile *FileInfo, stats *SyncStats, opts SyncOptions) error {
+	if !metadataDiffers(sourceFile, destFile, opts) {
+		e.recordVerified(relPath, sourceFile)
+		return nil
+	}
+
+	if !opts.DryRun {
+		err := e.guard.check("update metadata of", destPath)
+		if err == nil {
+			err = updateMetadata(destPath, sourceFile, opts)
+		}
+
+		if err != nil {
+			e.errorHandler.AddError(ClassifySyncError("metadata", destPath, err))
+			return err
+		}
+
+		e.recordWritten(relPath, destPath, sourceFile)
+	}
+
+	e.recordVerified(relPath, sourceFile)
+	e.recordOperation(ChangeModify, relPath, 0)
+	e.audit(ChangeModify, destPath, "", destFile, destFile)
+	atomic.AddInt64(&stats.MetadataUpdated, 1)
+
+	return nil
+}
+
+// updateMetadata gives the file at destPath the permissions and modification
+// time of source that opts preserves.
+func updateMetadata(destPath string, source *FileInfo, opts SyncOptions) error {
+	path := toExtendedPath(destPath)
+
+	if opts.PreservePerms {
+		if err := os.Chmod(path, fs.FileMode(source.Mode)); err != nil {
+			return fmt.Errorf("failed to set file permissions: %w", err)
+		}
+	}
+
+	if opts.PreserveTimes {
+		if err := os.Chtimes(path, source.ModTime, source.ModTime); err != nil {
+			return fmt.Errorf("failed to set file times: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package core
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// metricsWindow is how far back Metrics looks for the current transfer rate.
+const metricsWindow = 5 * time.Second
+
+// Metrics is a point-in-time view of a run for dashboards and metrics
+// systems. Unlike SyncStats it includes rates and concurrency, and unlike
+// Progress it does not depend on a display polling it. All rates are zero
+// before any file has completed.
+type Metrics struct {
+	Running          bool          `json:"running"`
+	Elapsed          time.Duration `json:"elapsed"`
+	FilesDone        int64         `json:"filesDone"`  // files processed this run, whether copied or not
+	FilesTotal       int64         `json:"filesTotal"` // files to process, once the scan is done
+	FilesChanged     int64         `json:"filesChanged"`
+	BytesTransferred int64         `json:"bytesTransferred"`
+	// BytesPerSecond is the transfer rate over the last few seconds, and
+	// AvgBytesPerSecond the rate over the whole run.
+	BytesPerSecond    float64 `json:"bytesPerSecond"`
+	AvgBytesPerSecond float64 `json:"avgBytesPerSecond"`
+	FilesPerSecond    float64 `json:"filesPerSecond"` // files processed per second over the whole run
+	ActiveWorkers     int64   `json:"activeWorkers"`  // files being synced right now
+	ErrorsEncountered int64   `json:"errorsEncountered"`
+	// ErrorsByCategory counts the collected errors by category; categories
+	// without errors are omitted.
+	ErrorsByCategory map[ErrorCategory]int `json:"errorsByCategory"`
+}
+
+// rateSample records the bytes transferred by a point in a run.
+type rateSample struct {
+	at    time.Time
+	bytes int64
+}
+
+// Metrics returns a snapshot of the current or last run. It is safe to call
+// while the run is in progress, from any goroutine.
+func (e *SyncEngine) Metrics() *Metrics {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	stats := e.loadStats()
+	now := time.Now()
+
+	running := e.running.Load()
+
+	elapsed := stats.Duration
+	if running && !stats.StartTime.IsZero() {
+		elapsed = now.Sub(stats.StartTime)
+	}
+
+	metrics := &Metrics{
+		Running:           running,
+		Elapsed:           elapsed,
+		FilesDone:         atomic.LoadInt64(&e.progress.Current),
+		FilesTotal:        atomic.LoadInt64(&e.progress.Total),
+		FilesChanged:      stats.FilesChanged,
+		BytesTransferred:  stats.BytesTransferred,
+		ActiveWorkers:     atomic.LoadInt64(&e.activeFiles),
+		ErrorsEncountered: stats.ErrorsEncountered,
+		ErrorsByCategory:  e.errorHandler.GetSummary(),
+	}
+
+	if seconds := elapsed.Seconds(); seconds > 0 {
+		metrics.AvgBytesPerSecond = float64(stats.BytesTransferred) / seconds
+		metrics.FilesPerSecond = float64(metrics.FilesDone) / seconds
+	}
+
+	metrics.BytesPerSecond = metrics.AvgBytesPerSecond
+
+	if running {
+		metrics.BytesPerSecond = e.currentRate(now, stats.BytesTransferred)
+	}
+
+	return metrics
+}
+
+// currentRate returns the transfer rate over the last metricsWindow, or over
+// the whole run when it is younger than that. Bytes are counted as files
+// complete, and a sample is taken at each completion, so everything past the
+// last sample before the window was transferred within it. The caller must
+// hold the engine's mutex.
+func (e *SyncEngine) currentRate(now time.Time, bytes int64) float64 {
+	span := now.Sub(e.stats.StartTime)
+
+	if span > metricsWindow {
+		span = metricsWindow
+
+		if len(e.samples) > 0 && now.Sub(e.samples[0].at) >= metricsWindow {
+			bytes -= e.samples[0].bytes
+		}
+	}
+
+	if span <= 0 {
+		return 0
+	}
+
+	return float64(bytes) / span.Seconds()
+}
+
+// recordRate samples the bytes transferred so far. Of the samples older than
+// metricsWindow only the newest is kept. The caller must hold the engine's
+// mutex.
+func (e *SyncEngine) recordRate(now time.Time, bytes int64) {
+	drop := 0
+	for drop+1 < len(e.samples) && now.Sub(e.samples[drop+1].at) >= metricsWindow {
+		drop++
+	}
+
+	e.samples = append(e.samples[drop:], rateSample{at: now, bytes: bytes})
+}
@@ -0,0 +1,131 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSyncEngineMetrics(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	destDir := filepath.Join(tempDir, "dest")
+
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	for i := range 10 {
+		writeTreeFile(t, filepath.Join(sourceDir, fmt.Sprintf("file%d.txt", i)), "0123456789", modTime)
+	}
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	// Metrics may be read from another goroutine throughout the run.
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var wg sync.WaitGroup
+
+	wg.Go(func() {
+		for ctx.Err() == nil {
+			_ = engine.Metrics()
+		}
+	})
+
+	mirrorTree(t, engine, sourceDir, destDir)
+	cancel()
+	wg.Wait()
+
+	metrics := engine.Metrics()
+
+	if metrics.Running {
+		t.Error("Running = true after the run")
+	}
+
+	// The scan lists the source root along with its files.
+	if metrics.FilesDone != 11 || metrics.FilesTotal != 11 || metrics.FilesChanged != 10 {
+		t.Errorf("files done/total/changed = %d/%d/%d, want 11/11/10",
+			metrics.FilesDone, metrics.FilesTotal, metrics.FilesChanged)
+	}
+
+	if metrics.BytesTransferred != 100 {
+		t.Errorf("BytesTransferred = %d, want 100", metrics.BytesTransferred)
+	}
+
+	if metrics.AvgBytesPerSecond <= 0 || metrics.FilesPerSecond <= 0 {
+		t.Errorf("average rates = %g B/s, %g files/s, want both positive",
+			metrics.AvgBytesPerSecond, metrics.FilesPerSecond)
+	}
+
+	if metrics.ActiveWorkers != 0 {
+		t.Errorf("ActiveWorkers = %d after the run, want 0", metrics.ActiveWorkers)
+	}
+
+	if len(metrics.ErrorsByCategory) != 0 {
+		t.Errorf("ErrorsByCategory = %v, want none", metrics.ErrorsByCategory)
+	}
+}
+
+func TestSyncEngineCurrentRate(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		now     time.Duration // since start
+		samples map[time.Duration]int64
+		bytes   int64
+		want    float64
+	}{
+		{
+			name:  "run younger than the window",
+			now:   2 * time.Second,
+			bytes: 400,
+			want:  200,
+		},
+		{
+			name:    "only bytes within the window count",
+			now:     20 * time.Second,
+			samples: map[time.Duration]int64{5 * time.Second: 1000, 12 * time.Second: 3000, 17 * time.Second: 3500},
+			bytes:   4000,
+			want:    200,
+		},
+		{
+			name:    "idle during the window",
+			now:     time.Minute,
+			samples: map[time.Duration]int64{10 * time.Second: 1000},
+			bytes:   1000,
+			want:    0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			engine, err := NewSyncEngine()
+			if err != nil {
+				t.Fatalf("NewSyncEngine failed: %v", err)
+			}
+
+			engine.stats.StartTime = start
+
+			for offset := time.Duration(0); offset <= tt.now; offset += time.Second {
+				if bytes, ok := tt.samples[offset]; ok {
+					engine.recordRate(start.Add(offset), bytes)
+				}
+			}
+
+			if got := engine.currentRate(start.Add(tt.now), tt.bytes); got != tt.want {
+				t.Errorf("currentRate() = %g, want %g", got, tt.want)
+			}
+		})
+	}
+}
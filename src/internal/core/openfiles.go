@@ -0,0 +1,60 @@
+package core
+
+import (
+	"context"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// openFileLimitShare is the share of the process's file descriptor limit that
+// DefaultOpenFileLimit leaves to open files, keeping the rest for sockets,
+// directories being walked, the watcher and the runtime.
+const openFileLimitShare = 0.8
+
+// DefaultOpenFileLimit returns the bound on concurrently open files used when
+// none is configured: 80% of the soft RLIMIT_NOFILE, or 0 (no bound) where the
+// limit cannot be detected or is unlimited, as on Windows.
+func DefaultOpenFileLimit() int {
+	limit, ok := fileDescriptorLimit()
+	if !ok {
+		return 0
+	}
+
+	return max(1, int(float64(limit)*openFileLimitShare))
+}
+
+// openFileLimiter bounds how many files the scanner and copier hold open at
+// once across all their workers, so that a low ulimit -n does not end in
+// "too many open files" errors. A nil limiter imposes no bound.
+type openFileLimiter struct {
+	sem   *semaphore.Weighted
+	limit int64
+}
+
+// newOpenFileLimiter returns a limiter allowing limit open files, or nil when
+// limit is not positive.
+func newOpenFileLimiter(limit int) *openFileLimiter {
+	if limit <= 0 {
+		return nil
+	}
+
+	return &openFileLimiter{sem: semaphore.NewWeighted(int64(limit)), limit: int64(limit)}
+}
+
+// acquire waits until n more files may be opened and returns the function
+// that gives them back. Callers must acquire every file an operation needs at
+// once and release them before acquiring again, so workers never wait on each
+// other while holding descriptors. An operation needing more files than the
+// whole limit takes the whole limit.
+func (l *openFileLimiter) acquire(ctx context.Context, n int) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	weight := min(int64(n), l.limit)
+	if err := l.sem.Acquire(ctx, weight); err != nil {
+		return nil, err
+	}
+
+	return func() { l.sem.Release(weight) }, nil
+}
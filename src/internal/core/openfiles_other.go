@@ -0,0 +1,9 @@
+//go:build !unix
+
+package core
+
+// fileDescriptorLimit reports that there is no file descriptor limit to
+// detect; Windows handles are not bounded by an rlimit.
+func fileDescriptorLimit() (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,24 @@
+//go:build unix
+
+package core
+
+import (
+	"math"
+
+	"golang.org/x/sys/unix"
+)
+
+// fileDescriptorLimit returns the soft RLIMIT_NOFILE of the process, which the
+// Go runtime raises to the hard limit at startup.
+func fileDescriptorLimit() (uint64, bool) {
+	var rlimit unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_NOFILE, &rlimit); err != nil {
+		return 0, false
+	}
+
+	if uint64(rlimit.Cur) == uint64(unix.RLIM_INFINITY) || rlimit.Cur > math.MaxInt32 {
+		return 0, false
+	}
+
+	return uint64(rlimit.Cur), true
+}
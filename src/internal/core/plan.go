@@ -0,0 +1,151 @@
+package core
+
+import (
+	"path/filepath"
+	"slices"
+)
+
+// SyncPlan summarizes what a run is about to do. It is worked out after
+// scanning and before anything is changed, from the same comparison the copy
+// phase uses. Conflicts are resolved later, so files they skip still count as
+// copies, and deletions are candidates that are confirmed one by one.
+type SyncPlan struct {
+	FilesToCopy    int64 `json:"filesToCopy"`
+	BytesToCopy    int64 `json:"bytesToCopy"`
+	FilesUnchanged int64 `json:"filesUnchanged"`
+	MetadataOnly   int64 `json:"metadataOnly"` // content matches; only permissions or times are set
+	DirsToCreate   int64 `json:"dirsToCreate"`
+	DirsToMove     int64 `json:"dirsToMove"` // destination directories renamed instead of copied; their files count as unchanged
+	FilesToDelete  int64 `json:"filesToDelete"`
+	BytesToDelete  int64 `json:"bytesToDelete"`
+	// DestNewer lists, with WarnDestNewer or ProtectDestNewer, the relative
+	// paths of destination files modified after their source. They are
+	// counted in FilesToCopy unless KeepDestNewer is set.
+	DestNewer     []string `json:"destNewer,omitempty"`
+	KeepDestNewer bool     `json:"keepDestNewer,omitempty"`
+}
+
+// add adds the counts of other to p.
+func (p *SyncPlan) add(other *SyncPlan) {
+	p.FilesToCopy += other.FilesToCopy
+	p.BytesToCopy += other.BytesToCopy
+	p.FilesUnchanged += other.FilesUnchanged
+	p.MetadataOnly += other.MetadataOnly
+	p.DirsToCreate += other.DirsToCreate
+	p.DirsToMove += other.DirsToMove
+	p.FilesToDelete += other.FilesToDelete
+	p.BytesToDelete += other.BytesToDelete
+	p.DestNewer = append(p.DestNewer, other.DestNewer...)
+	p.KeepDestNewer = p.KeepDestNewer || other.KeepDestNewer
+}
+
+// SetPreflight registers fn to be called with the plan of each run once the
+// trees are scanned and before anything is copied or deleted. An error from
+// fn aborts the run with nothing changed and is returned by it, so fn can ask
+// the user to confirm the plan. A nil fn removes the hook.
+func (e *SyncEngine) SetPreflight(fn func(plan *SyncPlan) error) {
+	e.preflight = fn
+}
+
+// GetPlan returns the plan of the current or last run, or nil while the trees
+// are still being scanned.
+func (e *SyncEngine) GetPlan() *SyncPlan {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.plan == nil {
+		return nil
+	}
+
+	plan := *e.plan
+	plan.DestNewer = slices.Clone(plan.DestNewer)
+
+	return &plan
+}
+
+// runPreflight records plan and passes it to the preflight hook.
+func (e *SyncEngine) runPreflight(plan *SyncPlan) error {
+	e.mu.Lock()
+	e.plan = plan
+	e.mu.Unlock()
+
+	e.emit(Event{Type: EventPlan, Plan: plan})
+
+	if e.preflight == nil {
+		return nil
+	}
+
+	return e.preflight(plan)
+}
+
+// checkDestNewer reports whether destFile is newer than sourceFile and opts
+// ask to look out for that.
+func checkDestNewer(sourceFile, destFile *FileInfo, opts SyncOptions) bool {
+	if !opts.WarnDestNewer && !opts.ProtectDestNewer {
+		return false
+	}
+
+	return !sourceFile.IsDir && !destFile.IsDir && destinationNewer(sourceFile, destFile, opts.ModifyWindow)
+}
+
+// planSync works out which of sourceFiles need copying to destination and,
+// when opts.DeleteExtraneous is set, which destination entries are candidates
+// for deletion.
+func (e *SyncEngine) planSync(source string, sourceFiles []*FileInfo, destMap map[string]*FileInfo, opts SyncOptions) *SyncPlan {
+	plan := &SyncPlan{KeepDestNewer: opts.ProtectDestNewer}
+
+	for _, sourceFile := range sourceFiles {
+		relPath, err := filepath.Rel(source, sourceFile.Path)
+		if err != nil {
+			continue
+		}
+
+		destFile, exists := destMap[relPath]
+
+		switch {
+		case exists && contentMatches(sourceFile, destFile):
+			if metadataDiffers(sourceFile, destFile, opts) {
+				plan.MetadataOnly++
+			} else {
+				plan.FilesUnchanged++
+			}
+		case exists && !e.needsSync(sourceFile, destFile, opts):
+			if !sourceFile.IsDir {
+				plan.FilesUnchanged++
+			}
+		case sourceFile.IsDir:
+			if !exists {
+				plan.DirsToCreate++
+			}
+		case exists && checkDestNewer(sourceFile, destFile, opts):
+			plan.DestNewer = append(plan.DestNewer, relPath)
+
+			if !opts.ProtectDestNewer {
+				plan.FilesToCopy++
+				plan.BytesToCopy += sourceFile.Size
+			}
+		default:
+			plan.FilesToCopy++
+			plan.BytesToCopy += sourceFile.Size
+		}
+	}
+
+	if !opts.DeleteExtraneous {
+		return plan
+	}
+
+	inSource := relativePaths(source, sourceFiles)
+
+	for relPath, destFile := range destMap {
+		if _, exists := inSource[relPath]; exists || e.checksumFileOf(relPath, inSource) {
+			continue
+		}
+
+		plan.FilesToDelete++
+		if !destFile.IsDir {
+			plan.BytesToDelete += destFile.Size
+		}
+	}
+
+	return plan
+}
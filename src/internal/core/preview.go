@@ -0,0 +1,159 @@
+package core
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// sniffLen is how much of a file content-type detection looks at.
+const sniffLen = 512
+
+// ErrBinaryContent is returned by ReadPreview for files that are not UTF-8
+// text and would print as noise.
+var ErrBinaryContent = errors.New("binary content")
+
+// DetectContentType sniffs the MIME type of the file at path from its first
+// bytes, using the algorithm of net/http.DetectContentType.
+func DetectContentType(path string) (string, error) {
+	file, err := os.Open(toExtendedPath(path))
+	if err != nil {
+		return "", err
+	}
+
+	defer func() { _ = file.Close() }()
+
+	return detectContentType(file)
+}
+
+func detectContentType(r io.Reader) (string, error) {
+	head := make([]byte, sniffLen)
+
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", err
+	}
+
+	return http.DetectContentType(head[:n]), nil
+}
+
+// IsTextContentType reports whether contentType is UTF-8 text, the only
+// encoding previews print as-is.
+func IsTextContentType(contentType string) bool {
+	return strings.HasPrefix(contentType, "text/") && strings.HasSuffix(contentType, "charset=utf-8")
+}
+
+// ReadPreview returns up to maxLines lines from the start of the file at
+// path, each cut to at most width terminal columns, and whether the file has
+// more lines. Files that are not UTF-8 text are rejected with an error
+// wrapping ErrBinaryContent; stray invalid sequences in text files are shown
+// as U+FFFD.
+func ReadPreview(path string, maxLines, width int) ([]string, bool, error) {
+	file, err := os.Open(toExtendedPath(path))
+	if err != nil {
+		return nil, false, err
+	}
+
+	defer func() { _ = file.Close() }()
+
+	contentType, err := detectContentType(file)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	if !IsTextContentType(contentType) {
+		return nil, false, fmt.Errorf("%w (%s)", ErrBinaryContent, contentType)
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, false, fmt.Errorf("failed to rewind %s: %w", path, err)
+	}
+
+	var lines []string
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if len(lines) == maxLines {
+			return lines, true, nil
+		}
+
+		line := strings.ToValidUTF8(scanner.Text(), string(utf8.RuneError))
+		lines = append(lines, TruncateDisplay(line, width))
+	}
+
+	return lines, false, scanner.Err()
+}
+
+// TruncateDisplay shortens s to at most width terminal columns, ending it
+// with "..." when anything was cut. It never splits a multi-byte character,
+// and counts wide (East Asian and emoji) characters as two columns and
+// combining marks as none.
+func TruncateDisplay(s string, width int) string {
+	if displayWidth(s) <= width {
+		return s
+	}
+
+	const ellipsis = "..."
+
+	limit := width - len(ellipsis)
+	used := 0
+
+	for i, r := range s {
+		runeWidth := displayRuneWidth(r)
+		if used+runeWidth > limit {
+			return s[:i] + ellipsis
+		}
+
+		used += runeWidth
+	}
+
+	return s
+}
+
+// displayWidth returns how many terminal columns s occupies.
+func displayWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		width += displayRuneWidth(r)
+	}
+
+	return width
+}
+
+// wideRanges are the code point ranges terminals draw two columns wide.
+var wideRanges = []struct{ lo, hi rune }{
+	{0x1100, 0x115F},   // Hangul Jamo
+	{0x2E80, 0x303E},   // CJK radicals and punctuation
+	{0x3041, 0x33FF},   // Kana and CJK symbols
+	{0x3400, 0x4DBF},   // CJK extension A
+	{0x4E00, 0x9FFF},   // CJK unified ideographs
+	{0xA000, 0xA4CF},   // Yi
+	{0xAC00, 0xD7A3},   // Hangul syllables
+	{0xF900, 0xFAFF},   // CJK compatibility ideographs
+	{0xFE30, 0xFE4F},   // CJK compatibility forms
+	{0xFF00, 0xFF60},   // Fullwidth forms
+	{0xFFE0, 0xFFE6},   // Fullwidth signs
+	{0x1F300, 0x1F64F}, // Pictographs and emoticons
+	{0x1F900, 0x1F9FF}, // Supplemental pictographs
+	{0x20000, 0x3FFFD}, // CJK extensions B and beyond
+}
+
+func displayRuneWidth(r rune) int {
+	if unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Me, r) {
+		return 0
+	}
+
+	for _, wide := range wideRanges {
+		if r >= wide.lo && r <= wide.hi {
+			return 2
+		}
+	}
+
+	return 1
+}
@@ -0,0 +1,129 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateDisplay(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		width int
+		want  string
+	}{
+		{"short ascii", "hello", 10, "hello"},
+		{"exact ascii", "hello", 5, "hello"},
+		{"long ascii", "hello world", 8, "hello..."},
+		{"multi-byte kept whole", "héllo wörld", 8, "héllo..."},
+		{"wide characters", "日本語のテキスト", 9, "日本語..."},
+		{"combining marks take no columns", "e\u0301e\u0301e\u0301", 3, "e\u0301e\u0301e\u0301"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := TruncateDisplay(tt.input, tt.width)
+			if got != tt.want {
+				t.Errorf("TruncateDisplay(%q, %d) = %q, want %q", tt.input, tt.width, got, tt.want)
+			}
+
+			if !utf8.ValidString(got) {
+				t.Errorf("TruncateDisplay(%q, %d) produced invalid UTF-8", tt.input, tt.width)
+			}
+		})
+	}
+}
+
+func TestReadPreview(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		content       []byte
+		maxLines      int
+		wantLines     []string
+		wantTruncated bool
+		wantBinary    bool
+	}{
+		{
+			name:      "utf-8 text",
+			content:   []byte("première ligne\nzweite Zeile\n"),
+			maxLines:  10,
+			wantLines: []string{"première ligne", "zweite Zeile"},
+		},
+		{
+			name:          "more lines than requested",
+			content:       []byte("a\nb\nc\n"),
+			maxLines:      2,
+			wantLines:     []string{"a", "b"},
+			wantTruncated: true,
+		},
+		{
+			name:      "exactly the requested lines",
+			content:   []byte("a\nb\n"),
+			maxLines:  2,
+			wantLines: []string{"a", "b"},
+		},
+		{
+			name:      "long line cut by columns",
+			content:   []byte(strings.Repeat("ü", 30) + "\n"),
+			maxLines:  10,
+			wantLines: []string{strings.Repeat("ü", 17) + "..."},
+		},
+		{
+			name:      "invalid sequence replaced",
+			content:   []byte("caf\xe9\n"),
+			maxLines:  10,
+			wantLines: []string{"caf�"},
+		},
+		{
+			name:       "binary",
+			content:    []byte{0x89, 'P', 'N', 'G', 0x0d, 0x0a, 0x1a, 0x0a, 0, 0, 0, 0x0d},
+			maxLines:   10,
+			wantBinary: true,
+		},
+		{
+			name:       "nul bytes",
+			content:    []byte("text\x00with\x00nuls"),
+			maxLines:   10,
+			wantBinary: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "preview")
+			if err := os.WriteFile(path, tt.content, 0o600); err != nil {
+				t.Fatalf("Failed to write file: %v", err)
+			}
+
+			lines, truncated, err := ReadPreview(path, tt.maxLines, 20)
+			if tt.wantBinary {
+				if !errors.Is(err, ErrBinaryContent) {
+					t.Errorf("ReadPreview error = %v, want ErrBinaryContent", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ReadPreview failed: %v", err)
+			}
+
+			if !slices.Equal(lines, tt.wantLines) || truncated != tt.wantTruncated {
+				t.Errorf("ReadPreview = %q, %v; want %q, %v", lines, truncated, tt.wantLines, tt.wantTruncated)
+			}
+		})
+	}
+}
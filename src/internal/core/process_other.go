@@ -0,0 +1,22 @@
+//go:build !unix
+
+package core
+
+import "os"
+
+// processRunning reports whether a process with id pid exists. On Windows,
+// finding a process opens a handle to it, which fails once it has exited.
+func processRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+
+	_ = process.Release()
+
+	return true
+}
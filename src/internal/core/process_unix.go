@@ -0,0 +1,22 @@
+//go:build unix
+
+package core
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// processRunning reports whether a process with id pid exists. Signal 0
+// checks for it without disturbing it; EPERM means it exists but belongs to
+// another user.
+func processRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+
+	err := unix.Kill(pid, 0)
+
+	return err == nil || errors.Is(err, unix.EPERM)
+}
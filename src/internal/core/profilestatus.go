@@ -0,0 +1,205 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// profileStatusVersion is bumped whenever the status format changes, so stale
+// status files are ignored rather than misread.
+const profileStatusVersion = 1
+
+// ProfileStatus is the outcome of the last run of a profile, kept so that
+// relay status can report it later.
+type ProfileStatus struct {
+	Version       int        `json:"version"`
+	Config        string     `json:"config,omitempty"` // absolute path of the config file; empty for the built-in defaults
+	Profile       string     `json:"profile"`
+	Command       string     `json:"command"` // the command that ran it: mirror, sync, run or schedule
+	Finished      time.Time  `json:"finished"`
+	Source        string     `json:"source"`
+	Destination   string     `json:"destination"`
+	Error         string     `json:"error,omitempty"` // why the run failed, if it did
+	PendingErrors int        `json:"pendingErrors"`   // files the run could not sync
+	Stats         *SyncStats `json:"stats,omitempty"` // nil when the run failed before it started
+}
+
+// DaemonStatus describes a resident relay process, such as relay schedule,
+// that runs a profile.
+type DaemonStatus struct {
+	PID     int       `json:"pid"`
+	Command string    `json:"command"`
+	Started time.Time `json:"started"`
+}
+
+// DefaultStatusPath returns where the status of profile in the config file
+// at configPath is kept: a file in the user cache directory named after
+// both. An empty configPath stands for the built-in defaults.
+func DefaultStatusPath(configPath, profile string) (string, error) {
+	if configPath != "" {
+		absConfig, err := filepath.Abs(configPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve %s: %w", configPath, err)
+		}
+
+		configPath = absConfig
+	}
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+
+	sum := sha256.Sum256([]byte(configPath + "\x00" + profile))
+
+	return filepath.Join(cacheDir, "relay", "status", hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+// WriteProfileStatus replaces the status at path with status.
+func WriteProfileStatus(path string, status *ProfileStatus) error {
+	status.Version = profileStatusVersion
+
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode status: %w", err)
+	}
+
+	return replaceStatusFile(path, data)
+}
+
+// ReadProfileStatus returns the status at path, or nil when no run has been
+// recorded there.
+func ReadProfileStatus(path string) (*ProfileStatus, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read status: %w", err)
+	}
+
+	var status ProfileStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, fmt.Errorf("failed to parse status %s: %w", path, err)
+	}
+
+	if status.Version != profileStatusVersion {
+		return nil, nil
+	}
+
+	return &status, nil
+}
+
+// RegisterDaemon records that this process, running command, keeps the
+// profile whose status is at path in sync. The returned function removes
+// the record again; a record left behind by a process that died is ignored
+// by ReadDaemonStatus.
+func RegisterDaemon(path, command string) (func() error, error) {
+	data, err := json.Marshal(DaemonStatus{PID: os.Getpid(), Command: command, Started: time.Now()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode daemon status: %w", err)
+	}
+
+	daemonPath := daemonStatusPath(path)
+	if err := replaceStatusFile(daemonPath, data); err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		// Leave the record of a newer daemon that has since taken over.
+		if daemon := ReadDaemonStatus(path); daemon != nil && daemon.PID != os.Getpid() {
+			return nil
+		}
+
+		if err := os.Remove(daemonPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove daemon status: %w", err)
+		}
+
+		return nil
+	}, nil
+}
+
+// ReadDaemonStatus returns the daemon registered for the profile whose
+// status is at path, or nil when none is running.
+func ReadDaemonStatus(path string) *DaemonStatus {
+	data, err := os.ReadFile(daemonStatusPath(path))
+	if err != nil {
+		return nil
+	}
+
+	var daemon DaemonStatus
+	if err := json.Unmarshal(data, &daemon); err != nil || !processRunning(daemon.PID) {
+		return nil
+	}
+
+	return &daemon
+}
+
+// daemonStatusPath returns where the daemon record for the status at path is
+// kept, beside it.
+func daemonStatusPath(path string) string {
+	return strings.TrimSuffix(path, ".json") + ".daemon.json"
+}
+
+// replaceStatusFile atomically replaces the file at path with data, so that
+// readers never see a partly written file.
+func replaceStatusFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("failed to create status directory: %w", err)
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary status file: %w", err)
+	}
+
+	tempPath := tempFile.Name()
+
+	if _, err := tempFile.Write(data); err != nil {
+		_ = tempFile.Close()
+		_ = os.Remove(tempPath)
+
+		return fmt.Errorf("failed to write status: %w", err)
+	}
+
+	if err := tempFile.Close(); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to close status: %w", err)
+	}
+
+	if err := os.Rename(tempPath, path); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to replace status: %w", err)
+	}
+
+	return nil
+}
+
+// SumStats adds up the statistics of consecutive runs, such as the steps of
+// a pipeline, into one. The result spans from the first run's start to the
+// last run's end.
+func SumStats(stats ...*SyncStats) *SyncStats {
+	var total SyncStats
+
+	for i, run := range stats {
+		total.add(*run)
+		total.Duration += run.Duration
+		total.DryRun = total.DryRun || run.DryRun
+
+		if i == 0 {
+			total.StartTime = run.StartTime
+		}
+
+		total.EndTime = run.EndTime
+	}
+
+	return &total
+}
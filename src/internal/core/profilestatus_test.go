@@ -0,0 +1,93 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestProfileStatusRoundTrip(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "status", "nas.json")
+
+	if status, err := ReadProfileStatus(path); status != nil || err != nil {
+		t.Fatalf("ReadProfileStatus before any run = %v, %v; want nil, nil", status, err)
+	}
+
+	written := &ProfileStatus{
+		Profile:       "nas",
+		Command:       "mirror",
+		Finished:      time.Now().Truncate(time.Second),
+		PendingErrors: 2,
+		Stats:         &SyncStats{FilesChanged: 5},
+	}
+
+	if err := WriteProfileStatus(path, written); err != nil {
+		t.Fatalf("WriteProfileStatus failed: %v", err)
+	}
+
+	read, err := ReadProfileStatus(path)
+	if err != nil {
+		t.Fatalf("ReadProfileStatus failed: %v", err)
+	}
+
+	if read.Profile != "nas" || !read.Finished.Equal(written.Finished) || read.PendingErrors != 2 || read.Stats.FilesChanged != 5 {
+		t.Errorf("ReadProfileStatus = %+v, want %+v", read, written)
+	}
+
+	// A status written in another format is ignored rather than misread.
+	if err := os.WriteFile(path, []byte(`{"version": 99, "profile": "nas"}`), 0o600); err != nil {
+		t.Fatalf("Failed to write status: %v", err)
+	}
+
+	if status, err := ReadProfileStatus(path); status != nil || err != nil {
+		t.Errorf("ReadProfileStatus of another version = %v, %v; want nil, nil", status, err)
+	}
+}
+
+func TestRegisterDaemon(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "nas.json")
+
+	release, err := RegisterDaemon(path, "schedule")
+	if err != nil {
+		t.Fatalf("RegisterDaemon failed: %v", err)
+	}
+
+	if daemon := ReadDaemonStatus(path); daemon == nil || daemon.PID != os.Getpid() || daemon.Command != "schedule" {
+		t.Fatalf("ReadDaemonStatus = %+v, want this process running schedule", daemon)
+	}
+
+	if err := release(); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+
+	if daemon := ReadDaemonStatus(path); daemon != nil {
+		t.Fatalf("ReadDaemonStatus after release = %+v, want nil", daemon)
+	}
+
+	// The record of a process that has exited is ignored.
+	if err := os.WriteFile(daemonStatusPath(path), []byte(`{"pid": -1, "command": "schedule"}`), 0o600); err != nil {
+		t.Fatalf("Failed to write daemon status: %v", err)
+	}
+
+	if daemon := ReadDaemonStatus(path); daemon != nil {
+		t.Errorf("ReadDaemonStatus of an exited process = %+v, want nil", daemon)
+	}
+}
+
+func TestSumStats(t *testing.T) {
+	t.Parallel()
+
+	start := time.Now()
+	first := &SyncStats{FilesChanged: 2, ErrorsEncountered: 1, StartTime: start, EndTime: start.Add(time.Second), Duration: time.Second}
+	second := &SyncStats{FilesChanged: 3, StartTime: start.Add(2 * time.Second), EndTime: start.Add(4 * time.Second), Duration: 2 * time.Second}
+
+	total := SumStats(first, second)
+
+	if total.FilesChanged != 5 || total.ErrorsEncountered != 1 || total.Duration != 3*time.Second ||
+		!total.StartTime.Equal(first.StartTime) || !total.EndTime.Equal(second.EndTime) {
+		t.Errorf("SumStats = %+v", total)
+	}
+}
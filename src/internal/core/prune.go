@@ -0,0 +1,144 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// pruneEmptyDirs removes destination directories left empty by the deletion
+// pass, deepest first, along with parents emptied in turn. These are
+// directories the deletion pass keeps because they still exist in the source,
+// though not in the source listing, such as a directory whose contents are
+// all excluded. Only directories that lost entries are considered, and none
+// in the source listing, so empty directories mirrored from the source stay.
+// The destination root is never removed. Pruned directories are counted as
+// DirsPruned rather than as deletions.
+func (e *SyncEngine) pruneEmptyDirs(ctx context.Context, destination string, inSource map[string]struct{}, destMap map[string]*FileInfo, deleted []string, stats *SyncStats, opts SyncOptions) error {
+	// Entries directly in each destination directory, and directories that
+	// are in the source listing or hold something from it.
+	entries := make(map[string]int)
+	for relPath := range destMap {
+		if relPath != "." {
+			entries[filepath.Dir(relPath)]++
+		}
+	}
+
+	inUse := make(map[string]struct{})
+	for relPath := range inSource {
+		for dir := relPath; dir != "."; dir = filepath.Dir(dir) {
+			inUse[dir] = struct{}{}
+		}
+	}
+
+	// Directories that lost entries, by depth; a parent is one level up, so
+	// it is only looked at once all its children have been.
+	gone := make(map[string]struct{}, len(deleted))
+	candidates := make(map[int]map[string]struct{})
+	maxDepth := 0
+
+	removed := func(relPath string) {
+		gone[relPath] = struct{}{}
+
+		dir := filepath.Dir(relPath)
+		entries[dir]--
+
+		depth := pathDepth(dir)
+		if candidates[depth] == nil {
+			candidates[depth] = make(map[string]struct{})
+		}
+
+		candidates[depth][dir] = struct{}{}
+		maxDepth = max(maxDepth, depth)
+	}
+
+	for _, relPath := range deleted {
+		removed(relPath)
+	}
+
+	for depth := maxDepth; depth > 0; depth-- {
+		for _, dir := range slices.Sorted(maps.Keys(candidates[depth])) {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			if !prunable(dir, entries, inUse, destMap, gone) {
+				continue
+			}
+
+			if e.pruneDir(destination, dir, stats, opts) {
+				removed(dir)
+			}
+		}
+	}
+
+	return nil
+}
+
+// prunable reports whether the destination directory dir is empty now and
+// is not part of the source listing.
+func prunable(dir string, entries map[string]int, inUse map[string]struct{}, destMap map[string]*FileInfo, gone map[string]struct{}) bool {
+	if entries[dir] > 0 {
+		return false
+	}
+
+	if _, ok := inUse[dir]; ok {
+		return false
+	}
+
+	if _, ok := gone[dir]; ok {
+		return false
+	}
+
+	info, ok := destMap[dir]
+
+	return ok && info.IsDir
+}
+
+// pruneDir removes the empty destination directory dir, or counts it in a
+// dry run, and reports whether it is gone.
+func (e *SyncEngine) pruneDir(destination, dir string, stats *SyncStats, opts SyncOptions) bool {
+	destPath := filepath.Join(destination, dir)
+
+	if !opts.DryRun {
+		err := e.guard.check("prune", destPath)
+		if err == nil {
+			err = os.Remove(toExtendedPath(destPath))
+		}
+
+		switch {
+		case err == nil, errors.Is(err, fs.ErrNotExist):
+			e.recordRemoved(dir)
+		case isDirNotEmpty(err):
+			// Holds something the scan did not list, e.g. a file created
+			// since.
+			return false
+		default:
+			e.errorHandler.AddError(ClassifySyncError("prune", destPath, err))
+			atomic.AddInt64(&stats.ErrorsEncountered, 1)
+
+			return false
+		}
+	}
+
+	atomic.AddInt64(&stats.DirsPruned, 1)
+	e.recordOperation(ChangeDelete, dir, 0)
+	e.audit(ChangeDelete, destPath, "", nil, nil)
+
+	return true
+}
+
+// pathDepth returns how many elements the relative path has; "." has none.
+func pathDepth(relPath string) int {
+	if relPath == "." {
+		return 0
+	}
+
+	return strings.Count(relPath, string(filepath.Separator)) + 1
+}
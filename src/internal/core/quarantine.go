@@ -0,0 +1,64 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+// ErrSuspectedCorruption is returned when a source file reads back with a
+// different checksum although its size and modification time are unchanged,
+// which points at bit rot or a failing disk rather than an edit.
+var ErrSuspectedCorruption = errors.New("suspected source corruption")
+
+// recheckSource re-reads a source file just before it is copied and compares
+// its digests with those taken during the scan. A file whose size or
+// modification time changed in between was legitimately edited and passes;
+// so does a file without a checksum or one that can no longer be read, which
+// the copy itself will report.
+func (e *SyncEngine) recheckSource(file *FileInfo) error {
+	if file.IsDir || file.Checksum == "" {
+		return nil
+	}
+
+	stat, err := os.Stat(toExtendedPath(file.Path))
+	if err != nil || stat.Size() != file.Size || !stat.ModTime().Equal(file.ModTime) {
+		return nil
+	}
+
+	// Acquire cannot fail with a background context.
+	release, _ := e.openFiles.acquire(context.Background(), 1)
+	defer release()
+
+	reader, err := os.Open(toExtendedPath(file.Path))
+	if err != nil {
+		return nil
+	}
+
+	defer func() { _ = reader.Close() }()
+
+	// The checksum cache is keyed by size and modification time, which are
+	// unchanged here, so the file must be hashed again directly.
+	checksum, secondary, err := e.scanner.fileChecksum(reader, file.Size)
+	if err != nil {
+		return nil
+	}
+
+	if checksum != file.Checksum || (file.SecondaryChecksum != "" && secondary != file.SecondaryChecksum) {
+		return fmt.Errorf("%w: checksum changed from %s to %s between reads", ErrSuspectedCorruption,
+			file.Checksum, checksum)
+	}
+
+	return nil
+}
+
+// quarantineSource records a source file that failed recheckSource. It is
+// not copied, so a corrupted source never overwrites a good destination.
+func (e *SyncEngine) quarantineSource(file *FileInfo, err error) error {
+	e.errorHandler.AddError(NewCorruptionError("quarantine", file.Path, err))
+	atomic.AddInt64(&e.stats.FilesQuarantined, 1)
+
+	return fmt.Errorf("quarantined %s: %w", file.Path, err)
+}
@@ -0,0 +1,96 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/howmanysmall/relay/src/internal/config"
+)
+
+// scheduleCheckInterval is how often the limiter re-reads its schedule, so a
+// long-running sync picks up a new limit shortly after crossing a boundary.
+const scheduleCheckInterval = time.Second
+
+// bandwidthLimiter is a token bucket shared by every copy in a run. Its rate
+// follows a BandwidthSchedule and is re-evaluated on a timer.
+type bandwidthLimiter struct {
+	schedule  *config.BandwidthSchedule
+	now       func() time.Time
+	mu        sync.Mutex
+	rate      int64
+	tokens    float64
+	last      time.Time
+	nextCheck time.Time
+}
+
+func newBandwidthLimiter(schedule *config.BandwidthSchedule) *bandwidthLimiter {
+	return &bandwidthLimiter{
+		schedule: schedule,
+		now:      time.Now,
+	}
+}
+
+// wait blocks until n bytes may be transferred under the current limit.
+// Transfers larger than the bucket go into debt, delaying later callers.
+func (l *bandwidthLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+
+	now := l.now()
+	l.refreshRate(now)
+
+	if l.rate <= 0 {
+		l.mu.Unlock()
+		return nil
+	}
+
+	rate := float64(l.rate)
+	elapsed := now.Sub(l.last).Seconds()
+	l.last = now
+
+	// Allow at most one second of burst.
+	l.tokens = min(l.tokens+elapsed*rate, rate)
+	l.tokens -= float64(n)
+
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / rate * float64(time.Second))
+	}
+
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// refreshRate re-reads the schedule once per check interval. It must be
+// called with the mutex held.
+func (l *bandwidthLimiter) refreshRate(now time.Time) {
+	if now.Before(l.nextCheck) {
+		return
+	}
+
+	l.nextCheck = now.Add(scheduleCheckInterval)
+
+	rate := l.schedule.LimitAt(now)
+	if rate == l.rate {
+		return
+	}
+
+	// Start the new window with an empty bucket so a switch from unlimited
+	// to limited takes effect immediately.
+	l.rate = rate
+	l.tokens = 0
+	l.last = now
+}
@@ -0,0 +1,62 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/howmanysmall/relay/src/internal/config"
+)
+
+func TestBandwidthLimiterThrottles(t *testing.T) {
+	t.Parallel()
+
+	schedule, err := config.ParseBandwidthSchedule("10MB")
+	if err != nil {
+		t.Fatalf("ParseBandwidthSchedule failed: %v", err)
+	}
+
+	limiter := newBandwidthLimiter(schedule)
+	start := time.Now()
+
+	// The bucket starts empty, so 1MB at 10MB/s takes about 100ms.
+	if err := limiter.wait(context.Background(), 1<<20); err != nil {
+		t.Fatalf("wait failed: %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("wait returned after %v, want at least 90ms", elapsed)
+	}
+}
+
+func TestBandwidthLimiterFollowsSchedule(t *testing.T) {
+	t.Parallel()
+
+	schedule, err := config.ParseBandwidthSchedule("09:00-17:00:5MB,default:unlimited")
+	if err != nil {
+		t.Fatalf("ParseBandwidthSchedule failed: %v", err)
+	}
+
+	now := time.Date(2025, 1, 1, 16, 59, 0, 0, time.Local)
+	limiter := newBandwidthLimiter(schedule)
+	limiter.now = func() time.Time { return now }
+
+	limiter.mu.Lock()
+	limiter.refreshRate(now)
+	limiter.mu.Unlock()
+
+	if limiter.rate != 5<<20 {
+		t.Errorf("rate during business hours = %d, want %d", limiter.rate, 5<<20)
+	}
+
+	// Crossing the boundary is picked up on the next timer check.
+	now = now.Add(2 * time.Minute)
+
+	if err := limiter.wait(context.Background(), 100<<20); err != nil {
+		t.Fatalf("wait failed: %v", err)
+	}
+
+	if limiter.rate != 0 {
+		t.Errorf("rate after hours = %d, want unlimited", limiter.rate)
+	}
+}
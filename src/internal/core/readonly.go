@@ -0,0 +1,45 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrReadOnly is returned for any change to a destination attempted while
+// SyncOptions.ReadOnly is set, and by the run that attempted it.
+var ErrReadOnly = errors.New("destination is read-only")
+
+// writeGuard refuses every change to a destination in read-only mode and
+// counts the refusals, so a run that tried to write can fail afterwards. It
+// is shared by the engine and its copier and safe for concurrent use. A nil
+// guard allows everything.
+type writeGuard struct {
+	enabled atomic.Bool
+	refused atomic.Int64
+}
+
+// check returns ErrReadOnly, naming op and path, when writes are refused.
+func (g *writeGuard) check(op, path string) error {
+	if g == nil || !g.enabled.Load() {
+		return nil
+	}
+
+	g.refused.Add(1)
+
+	return fmt.Errorf("%w: refused to %s %s", ErrReadOnly, op, path)
+}
+
+// runError returns the error failing a run in which writes were refused, or
+// nil when none were.
+func (g *writeGuard) runError() error {
+	if g == nil {
+		return nil
+	}
+
+	if refused := g.refused.Load(); refused > 0 {
+		return fmt.Errorf("%w: refused %d attempted writes", ErrReadOnly, refused)
+	}
+
+	return nil
+}
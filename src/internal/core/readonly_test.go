@@ -0,0 +1,136 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/howmanysmall/relay/src/internal/config"
+)
+
+func TestSyncEngineReadOnly(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		dryRun  bool
+		wantErr bool
+	}{
+		{name: "dry run writes nothing", dryRun: true},
+		{name: "real run fails", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tempDir := t.TempDir()
+
+			sourceDir := filepath.Join(tempDir, "source")
+			destDir := filepath.Join(tempDir, "dest")
+
+			modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+			writeTreeFile(t, filepath.Join(sourceDir, "new.txt"), "new", modTime)
+			writeTreeFile(t, filepath.Join(sourceDir, "sub", "changed.txt"), "changed", modTime)
+			writeTreeFile(t, filepath.Join(sourceDir, "touched.txt"), "same", modTime)
+			writeTreeFile(t, filepath.Join(destDir, "sub", "changed.txt"), "stale", modTime.Add(-time.Hour))
+			writeTreeFile(t, filepath.Join(destDir, "touched.txt"), "same", modTime.Add(-time.Hour))
+			writeTreeFile(t, filepath.Join(destDir, "extra.txt"), "extra", modTime)
+
+			before := readTree(t, destDir)
+
+			engine, err := NewSyncEngine()
+			if err != nil {
+				t.Fatalf("NewSyncEngine failed: %v", err)
+			}
+
+			opts := engine.Options()
+			opts.DryRun = tt.dryRun
+			opts.DeleteExtraneous = true
+			opts.ReadOnly = true
+			engine.SetOptions(opts)
+
+			err = engine.Mirror(context.Background(), sourceDir, destDir)
+			if got := errors.Is(err, ErrReadOnly); got != tt.wantErr {
+				t.Fatalf("Mirror() error = %v, want ErrReadOnly %v", err, tt.wantErr)
+			}
+
+			if got := readTree(t, destDir); !maps.Equal(got, before) {
+				t.Errorf("destination = %v, want it unchanged at %v", got, before)
+			}
+
+			if !tt.wantErr {
+				return
+			}
+
+			// Every refused change is reported, and none is retried.
+			syncErrors := engine.GetErrors()
+			if len(syncErrors) == 0 {
+				t.Fatal("no errors recorded for the refused writes")
+			}
+
+			for _, syncErr := range syncErrors {
+				if !errors.Is(syncErr, ErrReadOnly) || syncErr.Category != ErrorCategoryConfiguration {
+					t.Errorf("error %v (category %v), want a configuration ErrReadOnly", syncErr, syncErr.Category)
+				}
+			}
+		})
+	}
+}
+
+func TestSyncEngineReadOnlyWatch(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	destDir := filepath.Join(tempDir, "dest")
+	profile := &config.Profile{Source: sourceDir, Destination: destDir}
+
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	writeTreeFile(t, filepath.Join(sourceDir, "new", "file.txt"), "data", modTime)
+	writeTreeFile(t, filepath.Join(destDir, "kept.txt"), "kept", modTime)
+
+	destInfo, err := os.Stat(destDir)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	opts := engine.Options()
+	opts.ReadOnly = true
+	engine.SetOptions(opts)
+
+	// Neither a new source directory nor the directory times watch mode
+	// restores afterwards may touch the destination.
+	dirs := make(dirTimes)
+	newDir := filepath.Join(sourceDir, "new")
+
+	engine.handleChangeEvent(context.Background(), ChangeEvent{
+		Type: ChangeCreate,
+		Path: newDir,
+		Info: &FileInfo{Path: newDir, IsDir: true},
+	}, profile, dirs)
+
+	dirs["."] = struct{}{}
+	engine.restoreDirTimes(dirs, sourceDir, destDir)
+
+	if got := readTree(t, destDir); !maps.Equal(got, map[string]string{"kept.txt": "kept"}) {
+		t.Errorf("destination = %v, want it unchanged", got)
+	}
+
+	info, err := os.Stat(destDir)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+
+	if !info.ModTime().Equal(destInfo.ModTime()) {
+		t.Errorf("destination modtime = %v, want it unchanged at %v", info.ModTime(), destInfo.ModTime())
+	}
+}
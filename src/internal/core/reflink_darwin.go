@@ -0,0 +1,11 @@
+//go:build darwin
+
+package core
+
+import "golang.org/x/sys/unix"
+
+// reflinkFile creates dst as a clone of src sharing its blocks, with
+// clonefile(2). Only APFS supports it.
+func reflinkFile(src, dst string) error {
+	return unix.Clonefile(src, dst, unix.CLONE_NOFOLLOW)
+}
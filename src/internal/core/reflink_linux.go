@@ -0,0 +1,34 @@
+//go:build linux
+
+package core
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// reflinkFile creates dst as a clone of src sharing its blocks, with the
+// FICLONE ioctl. File systems without reflinks, such as ext4, refuse it.
+func reflinkFile(src, dst string) error {
+	in, err := os.Open(toExtendedPath(src))
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = in.Close() }()
+
+	out, err := os.OpenFile(toExtendedPath(dst), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if err := unix.IoctlFileClone(int(out.Fd()), int(in.Fd())); err != nil {
+		_ = out.Close()
+		_ = os.Remove(toExtendedPath(dst))
+
+		return err
+	}
+
+	return out.Close()
+}
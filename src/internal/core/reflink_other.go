@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package core
+
+import "errors"
+
+// reflinkFile is only implemented on Linux and macOS.
+func reflinkFile(_, _ string) error {
+	return errors.ErrUnsupported
+}
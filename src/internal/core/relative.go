@@ -0,0 +1,65 @@
+package core
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// relativeMarker in a source path marks where the part kept by
+// RelativeDestination starts, as with rsync's --relative.
+const relativeMarker = "/./"
+
+// RelativeDestination returns the directory source is mirrored into when its
+// full path is kept under destination: /home/user/docs maps to
+// destination/home/user/docs, so same-named directories from different places
+// do not collide. A "/./" in source limits the kept part to what follows it,
+// so /home/./user/docs maps to destination/user/docs. On Windows the drive
+// letter is kept as the first directory.
+func RelativeDestination(source, destination string) (string, error) {
+	var kept string
+
+	// ToSlash only swaps single-byte separators, so indices carry over.
+	if i := strings.Index(filepath.ToSlash(source), relativeMarker); i >= 0 {
+		kept = source[i+len(relativeMarker):]
+	} else {
+		absSource, err := filepath.Abs(source)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve %s: %w", source, err)
+		}
+
+		volume := filepath.VolumeName(absSource)
+		kept = absSource[len(volume):]
+
+		if len(volume) == 2 && volume[1] == ':' {
+			kept = filepath.Join(volume[:1], kept)
+		}
+	}
+
+	kept = filepath.Clean(strings.TrimLeft(kept, "/"+string(filepath.Separator)))
+	if kept == ".." || strings.HasPrefix(kept, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("relative path of %s is outside the destination", source)
+	}
+
+	return filepath.Join(destination, kept), nil
+}
+
+// RsyncDestination returns the directory source is mirrored into under
+// rsync's trailing-slash rule: a source ending in a separator stands for its
+// contents, which fill destination, while one without names the directory
+// itself, which is created inside destination under its own name. Sources
+// with no name of their own, such as "." or a drive root, always stand for
+// their contents.
+func RsyncDestination(source, destination string) string {
+	trimmed := source[len(filepath.VolumeName(source)):]
+	if trimmed == "" || strings.HasSuffix(trimmed, "/") || strings.HasSuffix(trimmed, string(filepath.Separator)) {
+		return destination
+	}
+
+	name := filepath.Base(source)
+	if name == "." || name == ".." || name == string(filepath.Separator) {
+		return destination
+	}
+
+	return filepath.Join(destination, name)
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestRelativeDestination(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("expectations use Unix absolute paths")
+	}
+
+	tests := []struct {
+		name    string
+		source  string
+		want    string
+		wantErr bool
+	}{
+		{"absolute source", "/home/user/docs", "/backup/home/user/docs", false},
+		{"trailing separator", "/home/user/docs/", "/backup/home/user/docs", false},
+		{"marker limits kept part", "/home/./user/docs", "/backup/user/docs", false},
+		{"marker at end keeps nothing", "/home/user/./", "/backup", false},
+		{"root source", "/", "/backup", false},
+		{"marker cannot escape", "/home/./../etc", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := RelativeDestination(tt.source, "/backup")
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("RelativeDestination(%q) = %q, want error", tt.source, got)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("RelativeDestination(%q) failed: %v", tt.source, err)
+			}
+
+			if got != filepath.FromSlash(tt.want) {
+				t.Errorf("RelativeDestination(%q) = %q, want %q", tt.source, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRsyncDestination(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"directory itself", "src/photos", "/backup/photos"},
+		{"trailing separator means contents", "src/photos/", "/backup"},
+		{"absolute source", "/home/user/docs", "/backup/docs"},
+		{"current directory", ".", "/backup"},
+		{"current directory with marker", "src/.", "/backup"},
+		{"parent directory", "..", "/backup"},
+		{"root", "/", "/backup"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := RsyncDestination(filepath.FromSlash(tt.source), filepath.FromSlash("/backup")); got != filepath.FromSlash(tt.want) {
+				t.Errorf("RsyncDestination(%q) = %q, want %q", tt.source, got, tt.want)
+			}
+		})
+	}
+}
@@ -3,21 +3,33 @@ package core
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/howmanysmall/relay/src/internal/config"
 )
 
+// backupTimestampLayout is the time format embedded in backup file names,
+// followed by an underscore and the nanoseconds in those CreateBackup makes.
+const backupTimestampLayout = "20060102_150405"
+
+// DefaultBackupDir is where conflict backups are kept when the profile does
+// not set a backup directory.
+const DefaultBackupDir = ".relay-backups"
+
 // ConflictResolver handles file conflicts during synchronization.
 type ConflictResolver struct {
-	strategy    config.ConflictStrategy
-	backup      bool
-	backupDir   string
-	interactive bool
+	strategy     config.ConflictStrategy
+	keepVersions int // versions kept by keep-newest, counting the live file
+	backup       bool
+	backupDir    string
+	interactive  bool
 }
 
 // ConflictInfo contains information about a file conflict.
@@ -78,14 +90,22 @@ func NewConflictResolver(cfg *config.ConflictConfig) *ConflictResolver {
 
 	backupDir := cfg.BackupDir
 	if backupDir == "" {
-		backupDir = ".relay-backups"
+		backupDir = DefaultBackupDir
+	}
+
+	// An invalid strategy falls back to newest in ResolveConflict; the loader
+	// has already rejected it for configured profiles.
+	strategy, keepVersions, err := config.ParseConflictStrategy(cfg.Strategy)
+	if err != nil {
+		strategy = config.ConflictNewest
 	}
 
 	return &ConflictResolver{
-		strategy:    config.ConflictStrategy(cfg.Strategy),
-		backup:      cfg.Backup,
-		backupDir:   backupDir,
-		interactive: cfg.Interactive,
+		strategy:     strategy,
+		keepVersions: keepVersions,
+		backup:       cfg.Backup,
+		backupDir:    backupDir,
+		interactive:  cfg.Interactive,
 	}
 }
 
@@ -106,15 +126,15 @@ func (cr *ConflictResolver) ResolveConflict(_ context.Context, conflict *Conflic
 		return cr.resolveSmart(conflict), nil
 	case config.ConflictSkip:
 		return ResolutionSkip, nil
+	case config.ConflictKeepNewest:
+		return ResolutionBackupAndUseSource, nil
 	default:
 		return cr.resolveByNewest(conflict), nil
 	}
 }
 
 func (cr *ConflictResolver) resolveByNewest(conflict *ConflictInfo) ConflictResolution {
-	if conflict.SourceInfo.ModTime.After(conflict.DestInfo.ModTime) {
-		return ResolutionUseSource
-	} else if conflict.DestInfo.ModTime.After(conflict.SourceInfo.ModTime) {
+	if destinationNewer(conflict.SourceInfo, conflict.DestInfo, 0) {
 		return ResolutionUseDestination
 	}
 
@@ -122,6 +142,12 @@ func (cr *ConflictResolver) resolveByNewest(conflict *ConflictInfo) ConflictReso
 	return ResolutionUseSource
 }
 
+// destinationNewer reports whether dest was modified more than window after
+// source, which in a one-way mirror usually means it was edited in place.
+func destinationNewer(source, dest *FileInfo, window time.Duration) bool {
+	return dest.ModTime.Sub(source.ModTime) > window
+}
+
 func (cr *ConflictResolver) resolveSmart(conflict *ConflictInfo) ConflictResolution {
 	// Smart resolution logic
 	sizeDiff := conflict.SourceInfo.Size - conflict.DestInfo.Size
@@ -200,7 +226,7 @@ func (cr *ConflictResolver) resolveInteractively(conflict *ConflictInfo) (Confli
 			cr.showDiff(conflict)
 			continue
 		case "a", "all":
-			return cr.promptForDefaultStrategy()
+			return cr.promptForDefaultStrategy(conflict)
 		default:
 			fmt.Printf("Invalid choice. Please enter s, d, b, k, v, or a.\n")
 			continue
@@ -208,7 +234,9 @@ func (cr *ConflictResolver) resolveInteractively(conflict *ConflictInfo) (Confli
 	}
 }
 
-func (cr *ConflictResolver) promptForDefaultStrategy() (ConflictResolution, error) {
+// promptForDefaultStrategy asks for the strategy for the rest of the
+// conflicts and returns its resolution of conflict.
+func (cr *ConflictResolver) promptForDefaultStrategy(conflict *ConflictInfo) (ConflictResolution, error) {
 	fmt.Printf("\nChoose default strategy for remaining conflicts:\n")
 	fmt.Printf("  [s] Always use source\n")
 	fmt.Printf("  [d] Always use destination\n")
@@ -241,7 +269,7 @@ func (cr *ConflictResolver) promptForDefaultStrategy() (ConflictResolution, erro
 			cr.strategy = config.ConflictNewest
 			cr.interactive = false
 
-			return cr.resolveByNewest(&ConflictInfo{}), nil
+			return cr.resolveByNewest(conflict), nil
 		case "k", "skip":
 			cr.strategy = config.ConflictSkip
 			cr.interactive = false
@@ -275,49 +303,43 @@ func (cr *ConflictResolver) showDiff(conflict *ConflictInfo) {
 	}
 }
 
-func (cr *ConflictResolver) showFilePreview(path string, lines int) error {
-	file, err := os.Open(path)
-	if err != nil {
-		return err
+func (cr *ConflictResolver) showFilePreview(path string, maxLines int) error {
+	lines, truncated, err := ReadPreview(path, maxLines, 100)
+	if errors.Is(err, ErrBinaryContent) {
+		fmt.Printf("Not shown: %v\n", err)
+		return nil
 	}
 
-	defer func() {
-		if cerr := file.Close(); cerr != nil {
-			_ = cerr // ignore close error
-		}
-	}()
-
-	scanner := bufio.NewScanner(file)
-
-	lineCount := 0
-	for scanner.Scan() && lineCount < lines {
-		fmt.Printf("%d: %s\n", lineCount+1, scanner.Text())
-		lineCount++
+	for i, line := range lines {
+		fmt.Printf("%d: %s\n", i+1, line)
 	}
 
-	if lineCount == lines {
+	if truncated {
 		fmt.Printf("... (truncated)\n")
 	}
 
-	return scanner.Err()
+	return err
 }
 
-// CreateBackup creates a backup copy of the specified file if backups are enabled.
+// CreateBackup creates a backup copy of the specified file if backups are
+// enabled. Backups are kept in a directory below the backup directory that
+// mirrors the absolute path of the file's own directory, so files of the same
+// name elsewhere keep separate versions. Under keep-newest, backups of the
+// file beyond the retained version count are pruned afterwards.
 func (cr *ConflictResolver) CreateBackup(filePath string) (string, error) {
-	if !cr.backup {
+	if !cr.backup && cr.keepVersions == 0 {
 		return "", nil
 	}
 
+	dir := filepath.Join(cr.backupDir, backupKey(filePath))
+
 	// Create backup directory if it doesn't exist
-	if err := os.MkdirAll(cr.backupDir, 0o750); err != nil {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
 		return "", fmt.Errorf("failed to create backup directory: %w", err)
 	}
 
-	// Generate unique backup filename
 	fileName := filepath.Base(filePath)
-	timestamp := time.Now().Format("20060102_150405")
-	backupName := fmt.Sprintf("%s.%s.backup", fileName, timestamp)
-	backupPath := filepath.Join(cr.backupDir, backupName)
+	backupPath := uniqueBackupPath(dir, fileName, time.Now())
 
 	// Copy file to backup location
 	copier := NewFileCopier(0, false) // Use buffered copy for backups
@@ -325,9 +347,129 @@ func (cr *ConflictResolver) CreateBackup(filePath string) (string, error) {
 		return "", fmt.Errorf("failed to create backup: %w", err)
 	}
 
+	if cr.keepVersions > 0 {
+		// The live file is one of the kept versions.
+		if err := pruneBackups(dir, fileName, cr.keepVersions-1); err != nil {
+			return backupPath, err
+		}
+	}
+
 	return backupPath, nil
 }
 
+// backupKey returns the directory, relative to a backup directory, that
+// holds the backups of the file at path: the absolute path of the file's
+// directory, with the volume name reduced to a plain directory name.
+func backupKey(path string) string {
+	abs, err := filepath.Abs(fromExtendedPath(path))
+	if err != nil {
+		abs = path
+	}
+
+	dir := filepath.Dir(abs)
+	volume := filepath.VolumeName(dir)
+
+	volumeKey := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`:\/?`, r) {
+			return -1
+		}
+
+		return r
+	}, volume)
+
+	return filepath.Join(volumeKey, strings.TrimLeft(dir[len(volume):], `\/`))
+}
+
+// uniqueBackupPath returns a path in dir for a backup of fileName made at
+// now that no other backup has, even one made in the same nanosecond.
+func uniqueBackupPath(dir, fileName string, now time.Time) string {
+	for {
+		path := filepath.Join(dir, fmt.Sprintf("%s.%s.backup", fileName, backupStamp(now)))
+		if _, err := os.Lstat(path); errors.Is(err, os.ErrNotExist) {
+			return path
+		}
+
+		now = now.Add(time.Nanosecond)
+	}
+}
+
+// backupStamp returns the timestamp CreateBackup embeds in the name of a
+// backup made at t.
+func backupStamp(t time.Time) string {
+	return fmt.Sprintf("%s_%09d", t.Format(backupTimestampLayout), t.Nanosecond())
+}
+
+// parseBackupStamp parses the timestamp in a backup's name, with or without
+// the nanoseconds older backups lack, in local time.
+func parseBackupStamp(stamp string) (time.Time, bool) {
+	var nanos int
+
+	if fraction, ok := strings.CutPrefix(stamp[min(len(stamp), len(backupTimestampLayout)):], "_"); ok {
+		if len(fraction) != 9 || strings.Trim(fraction, "0123456789") != "" {
+			return time.Time{}, false
+		}
+
+		nanos, _ = strconv.Atoi(fraction)
+		stamp = stamp[:len(backupTimestampLayout)]
+	}
+
+	created, err := time.ParseInLocation(backupTimestampLayout, stamp, time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return created.Add(time.Duration(nanos)), true
+}
+
+// pruneBackups removes all but the newest keep backups of fileName in dir.
+func pruneBackups(dir, fileName string, keep int) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	var backups []string
+
+	for _, entry := range entries {
+		if isBackupOf(entry.Name(), fileName) {
+			backups = append(backups, entry.Name())
+		}
+	}
+
+	if len(backups) <= keep {
+		return nil
+	}
+
+	// Backup timestamps sort chronologically, so the oldest come first.
+	slices.Sort(backups)
+
+	for _, name := range backups[:len(backups)-keep] {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return fmt.Errorf("failed to prune backup %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// isBackupOf reports whether name is a backup CreateBackup made of fileName,
+// so a backup of "a.txt.old" is never mistaken for one of "a.txt".
+func isBackupOf(name, fileName string) bool {
+	stamp, ok := strings.CutPrefix(name, fileName+".")
+	if !ok {
+		return false
+	}
+
+	stamp, ok = strings.CutSuffix(stamp, ".backup")
+	if !ok {
+		return false
+	}
+
+	_, ok = parseBackupStamp(stamp)
+
+	return ok
+}
+
 // DetectConflict determines if a conflict exists between two file versions and returns details.
 func (cr *ConflictResolver) DetectConflict(source, dest *FileInfo) *ConflictInfo {
 	if source == nil || dest == nil {
@@ -346,7 +488,7 @@ func (cr *ConflictResolver) DetectConflict(source, dest *FileInfo) *ConflictInfo
 	case !source.ModTime.Equal(dest.ModTime):
 		conflictType = ConflictModTimesDiffer
 		hasConflict = true
-	case source.Checksum != "" && dest.Checksum != "" && source.Checksum != dest.Checksum:
+	case checksumsDiffer(source, dest):
 		conflictType = ConflictChecksumsDiffer
 		hasConflict = true
 	}
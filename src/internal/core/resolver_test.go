@@ -0,0 +1,121 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/howmanysmall/relay/src/internal/config"
+)
+
+func TestConflictResolverKeepNewest(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+	livePath := filepath.Join(tempDir, "doc.txt")
+	backupDir := filepath.Join(tempDir, "backups")
+	keyDir := filepath.Join(backupDir, backupKey(livePath))
+
+	if err := os.MkdirAll(keyDir, 0o755); err != nil {
+		t.Fatalf("Failed to create backup dir: %v", err)
+	}
+
+	existing := []string{
+		"doc.txt.20250101_000000.backup",
+		"doc.txt.20250102_000000.backup",
+		"doc.txt.old.20250101_000000.backup",
+		"other.txt.20250101_000000.backup",
+	}
+	for _, name := range existing {
+		if err := os.WriteFile(filepath.Join(keyDir, name), []byte("old"), 0o644); err != nil {
+			t.Fatalf("Failed to create backup: %v", err)
+		}
+	}
+
+	if err := os.WriteFile(livePath, []byte("current"), 0o644); err != nil {
+		t.Fatalf("Failed to create live file: %v", err)
+	}
+
+	resolver := NewConflictResolver(&config.ConflictConfig{
+		Strategy:  "keep-newest:2",
+		BackupDir: backupDir,
+	})
+
+	resolution, err := resolver.ResolveConflict(context.Background(), &ConflictInfo{})
+	if err != nil {
+		t.Fatalf("ResolveConflict failed: %v", err)
+	}
+
+	if resolution != ResolutionBackupAndUseSource {
+		t.Errorf("ResolveConflict() = %v, want ResolutionBackupAndUseSource", resolution)
+	}
+
+	backupPath, err := resolver.CreateBackup(livePath)
+	if err != nil {
+		t.Fatalf("CreateBackup failed: %v", err)
+	}
+
+	entries, err := os.ReadDir(keyDir)
+	if err != nil {
+		t.Fatalf("Failed to read backup dir: %v", err)
+	}
+
+	var remaining []string
+	for _, entry := range entries {
+		remaining = append(remaining, entry.Name())
+	}
+
+	// Two versions are kept: the live file and the backup just taken. Backups
+	// of other files are left alone.
+	want := []string{
+		filepath.Base(backupPath),
+		"doc.txt.old.20250101_000000.backup",
+		"other.txt.20250101_000000.backup",
+	}
+	slices.Sort(want)
+
+	if !slices.Equal(remaining, want) {
+		t.Errorf("Remaining backups = %v, want %v", remaining, want)
+	}
+}
+
+func TestConflictResolverKeepNewestPerPath(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+	backupDir := filepath.Join(tempDir, "backups")
+
+	pathA := filepath.Join(tempDir, "a", "index.html")
+	pathB := filepath.Join(tempDir, "b", "index.html")
+
+	for _, path := range []string{pathA, pathB} {
+		writeTreeFile(t, path, path, time.Now())
+	}
+
+	resolver := NewConflictResolver(&config.ConflictConfig{Strategy: "keep-newest:3", BackupDir: backupDir})
+
+	// Backed up in quick succession, within the same second, the backups
+	// of a/index.html must neither overwrite each other nor be pruned by
+	// those of b/index.html.
+	for _, path := range []string{pathA, pathA, pathB, pathB, pathB} {
+		if _, err := resolver.CreateBackup(path); err != nil {
+			t.Fatalf("CreateBackup(%s) failed: %v", path, err)
+		}
+	}
+
+	for _, tt := range []struct {
+		path string
+		want int
+	}{{pathA, 2}, {pathB, 2}} {
+		backups, err := BackupsOf(backupDir, tt.path)
+		if err != nil || len(backups) != tt.want {
+			t.Errorf("BackupsOf(%s) = %v, %v; want %d backups", tt.path, backups, err, tt.want)
+			continue
+		}
+
+		if content, _ := os.ReadFile(backups[0].Path); string(content) != tt.path {
+			t.Errorf("backup of %s holds %q", tt.path, content)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/howmanysmall/relay/src/internal/config"
+)
+
+// backupTimeLayouts are the layouts ParseBackupTime accepts, most precise
+// first.
+var backupTimeLayouts = []string{
+	time.RFC3339,
+	time.DateTime,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	time.DateOnly,
+}
+
+// ErrNoBackup is returned when no backup matches a restore request.
+var ErrNoBackup = errors.New("no backup found")
+
+// ErrBackupMatches is returned by RestoreBackup when the file already has the
+// content of the backup, so there is nothing to restore.
+var ErrBackupMatches = errors.New("file already matches the backup")
+
+// ParseBackupTime parses a point in time given to pick a backup, such as
+// "2024-05-01 14:30", "2024-05-01", an RFC 3339 time or the timestamp in a
+// backup's name. Times without a zone are local, as backup names are.
+func ParseBackupTime(value string) (time.Time, error) {
+	for _, layout := range backupTimeLayouts {
+		if parsed, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return parsed, nil
+		}
+	}
+
+	if parsed, ok := parseBackupStamp(value); ok {
+		return parsed, nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q: expected e.g. 2006-01-02 15:04:05 or 2006-01-02", value)
+}
+
+// BackupsOf returns the backups in dir of the file at path, oldest first.
+// Backups made before they were kept in a directory per file location, and
+// so directly in dir, only record a file's name and match any file of that
+// name.
+func BackupsOf(dir, path string) ([]Backup, error) {
+	backups, err := ListBackups(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	name := filepath.Base(path)
+	keyDir := filepath.Join(dir, backupKey(path))
+
+	var matching []Backup
+
+	for _, backup := range backups {
+		backupDir := filepath.Dir(backup.Path)
+		if backup.Original == name && (backupDir == keyDir || backupDir == filepath.Clean(dir)) {
+			matching = append(matching, backup)
+		}
+	}
+
+	return matching, nil
+}
+
+// BackupAt returns the newest of backups, sorted oldest first, made at or
+// before at, or the newest of all when at is zero.
+func BackupAt(backups []Backup, at time.Time) (Backup, error) {
+	for i := len(backups) - 1; i >= 0; i-- {
+		if at.IsZero() || !backups[i].Created.After(at) {
+			return backups[i], nil
+		}
+	}
+
+	if at.IsZero() {
+		return Backup{}, ErrNoBackup
+	}
+
+	return Backup{}, fmt.Errorf("%w made at or before %s", ErrNoBackup, at.Format(time.DateTime))
+}
+
+// RestoreBackup copies backup over the file at path. A file already at path
+// is first backed up into dir, so the restore can itself be undone; the
+// returned path is that backup's, or empty when path did not exist. The
+// error is ErrBackupMatches when path already holds the backup's content. The
+// backup is copied next to path before anything is replaced, so a failed
+// restore leaves path as it was.
+func RestoreBackup(dir string, backup Backup, path string) (string, error) {
+	if same, err := sameContent(backup.Path, path); err != nil {
+		return "", err
+	} else if same {
+		return "", ErrBackupMatches
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("failed to restore %s: %w", path, err)
+	}
+
+	_ = temp.Close()
+
+	defer func() { _ = os.Remove(temp.Name()) }()
+
+	if err := NewFileCopier(0, false).CopyFile(context.Background(), backup.Path, temp.Name()); err != nil {
+		return "", fmt.Errorf("failed to restore %s: %w", path, err)
+	}
+
+	var saved string
+
+	if _, err := os.Lstat(path); err == nil {
+		resolver := NewConflictResolver(&config.ConflictConfig{Backup: true, BackupDir: dir})
+
+		saved, err = resolver.CreateBackup(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to back up %s before restoring: %w", path, err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return saved, fmt.Errorf("failed to restore %s: %w", path, err)
+	}
+
+	return saved, nil
+}
+
+// sameContent reports whether the regular files at a and b have the same
+// content. A missing b differs from any a.
+func sameContent(a, b string) (bool, error) {
+	infoB, err := os.Lstat(b)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	infoA, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+
+	if !infoB.Mode().IsRegular() || infoA.Size() != infoB.Size() {
+		return false, nil
+	}
+
+	fileA, err := os.Open(a)
+	if err != nil {
+		return false, err
+	}
+
+	defer func() { _ = fileA.Close() }()
+
+	fileB, err := os.Open(b)
+	if err != nil {
+		return false, err
+	}
+
+	defer func() { _ = fileB.Close() }()
+
+	bufA := make([]byte, 64<<10)
+	bufB := make([]byte, 64<<10)
+
+	for {
+		n, errA := io.ReadFull(fileA, bufA)
+		_, errB := io.ReadFull(fileB, bufB[:n])
+
+		if errB != nil && n > 0 {
+			return false, errB
+		}
+
+		if !bytes.Equal(bufA[:n], bufB[:n]) {
+			return false, nil
+		}
+
+		switch {
+		case errors.Is(errA, io.EOF), errors.Is(errA, io.ErrUnexpectedEOF):
+			return true, nil
+		case errA != nil:
+			return false, errA
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBackupAt(t *testing.T) {
+	t.Parallel()
+
+	day := func(d int) time.Time { return time.Date(2024, 5, d, 12, 0, 0, 0, time.Local) }
+	backups := []Backup{{Path: "first", Created: day(1)}, {Path: "second", Created: day(3)}}
+
+	tests := []struct {
+		name    string
+		at      string
+		want    string
+		wantErr bool
+	}{
+		{name: "newest without a time", want: "second"},
+		{name: "exact time", at: "2024-05-03 12:00:00", want: "second"},
+		{name: "between backups", at: "2024-05-02", want: "first"},
+		{name: "backup timestamp", at: "20240501_120000", want: "first"},
+		{name: "before every backup", at: "2024-04-30T09:00:00", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var at time.Time
+
+			if tt.at != "" {
+				var err error
+
+				at, err = ParseBackupTime(tt.at)
+				if err != nil {
+					t.Fatalf("ParseBackupTime(%q) failed: %v", tt.at, err)
+				}
+			}
+
+			got, err := BackupAt(backups, at)
+			if tt.wantErr {
+				if !errors.Is(err, ErrNoBackup) {
+					t.Errorf("BackupAt error = %v, want ErrNoBackup", err)
+				}
+
+				return
+			}
+
+			if err != nil || got.Path != tt.want {
+				t.Errorf("BackupAt = %q, %v; want %q", got.Path, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestoreBackup(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	backupDir := filepath.Join(tempDir, "backups")
+	path := filepath.Join(tempDir, "dst", "notes.md")
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	writeTreeFile(t, filepath.Join(backupDir, "notes.md.20240101_100000.backup"), "old", modTime)
+	writeTreeFile(t, filepath.Join(backupDir, "other.md.20240301_100000.backup"), "other", modTime)
+	writeTreeFile(t, path, "current", modTime)
+
+	backups, err := BackupsOf(backupDir, path)
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("BackupsOf = %v, %v; want the one backup of notes.md", backups, err)
+	}
+
+	saved, err := RestoreBackup(backupDir, backups[0], path)
+	if err != nil {
+		t.Fatalf("RestoreBackup failed: %v", err)
+	}
+
+	if content, _ := os.ReadFile(path); string(content) != "old" {
+		t.Errorf("restored content = %q, want %q", content, "old")
+	}
+
+	if content, _ := os.ReadFile(saved); string(content) != "current" {
+		t.Errorf("replaced version saved as %q, want %q", content, "current")
+	}
+
+	if _, err := RestoreBackup(backupDir, backups[0], path); !errors.Is(err, ErrBackupMatches) {
+		t.Errorf("second RestoreBackup error = %v, want ErrBackupMatches", err)
+	}
+
+	missing := filepath.Join(tempDir, "gone", "notes.md")
+	if saved, err := RestoreBackup(backupDir, backups[0], missing); err != nil || saved != "" {
+		t.Errorf("RestoreBackup to a missing file = %q, %v; want no saved version", saved, err)
+	}
+}
@@ -5,6 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/howmanysmall/relay/src/internal/config"
@@ -20,6 +23,10 @@ type RetryableError struct {
 	Err       error
 	Retryable bool
 	Fatal     bool
+	// RetryAfter is a delay suggested by the failing operation, such as a
+	// server's Retry-After header. When positive it replaces the computed
+	// backoff for the next attempt.
+	RetryAfter time.Duration
 }
 
 func (re *RetryableError) Error() string {
@@ -77,6 +84,9 @@ func (rm *RetryManager) ExecuteWithRetry(ctx context.Context, operation func() e
 		}
 
 		delay := rm.calculateDelay(attempt)
+		if ok && retryableErr.RetryAfter > 0 {
+			delay = retryableErr.RetryAfter
+		}
 
 		select {
 		case <-ctx.Done():
@@ -120,6 +130,46 @@ func NewRetryableError(err error, retryable bool) *RetryableError {
 	}
 }
 
+// NewRetryAfterError wraps a retryable error that should not be retried
+// before the given delay has elapsed.
+func NewRetryAfterError(err error, retryAfter time.Duration) *RetryableError {
+	return &RetryableError{
+		Err:        err,
+		Retryable:  true,
+		Fatal:      false,
+		RetryAfter: retryAfter,
+	}
+}
+
+// ParseRetryAfter parses the value of an HTTP Retry-After header, which is
+// either a number of seconds or an HTTP date, into a delay relative to now.
+// It reports false when the value cannot be parsed.
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	when, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+
+	if delay := when.Sub(now); delay > 0 {
+		return delay, true
+	}
+
+	return 0, true
+}
+
 // NewFatalError wraps an error as fatal (non-retryable) and stops further retries.
 func NewFatalError(err error) *RetryableError {
 	return &RetryableError{
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/howmanysmall/relay/src/internal/config"
+)
+
+func TestRetryManagerHonorsRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	manager := NewRetryManager(&config.RetryConfig{
+		MaxAttempts:  2,
+		InitialDelay: time.Millisecond,
+		MaxDelay:     time.Millisecond,
+		Multiplier:   2.0,
+		Backoff:      string(config.BackoffFixed),
+	})
+
+	retryAfter := 50 * time.Millisecond
+	attempts := 0
+	start := time.Now()
+
+	err := manager.ExecuteWithRetry(context.Background(), func() error {
+		attempts++
+		if attempts == 1 {
+			return NewRetryAfterError(errors.New("throttled"), retryAfter)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ExecuteWithRetry failed: %v", err)
+	}
+
+	if attempts != 2 {
+		t.Errorf("attempts = %d, want 2", attempts)
+	}
+
+	if elapsed := time.Since(start); elapsed < retryAfter {
+		t.Errorf("retried after %v, want at least %v", elapsed, retryAfter)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{name: "seconds", value: "120", want: 2 * time.Minute, wantOK: true},
+		{name: "http date", value: "Wed, 01 Jan 2025 12:00:30 GMT", want: 30 * time.Second, wantOK: true},
+		{name: "date in the past", value: "Wed, 01 Jan 2025 11:00:00 GMT", want: 0, wantOK: true},
+		{name: "negative seconds", value: "-5", wantOK: false},
+		{name: "empty", value: "", wantOK: false},
+		{name: "garbage", value: "soon", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok := ParseRetryAfter(tt.value, now)
+			if ok != tt.wantOK {
+				t.Fatalf("ParseRetryAfter(%q) ok = %v, want %v", tt.value, ok, tt.wantOK)
+			}
+
+			if got != tt.want {
+				t.Errorf("ParseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,16 +1,23 @@
 package core
 
 import (
+	"bytes"
 	"context"
+	"crypto/md5"
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/zeebo/blake3"
 	"golang.org/x/sync/semaphore"
@@ -19,7 +26,14 @@ import (
 // FileScanner scans directories and computes file checksums with caching.
 type FileScanner struct {
 	maxConcurrency int64
+	checksumSem    *semaphore.Weighted // nil: hashing bounded only by maxConcurrency
+	openFiles      *openFileLimiter    // shared with the copier; nil: unbounded
 	checksumAlgo   string
+	secondaryAlgo  string
+	checksumKey    []byte
+	sampleSize     int64 // bytes hashed at each sample point; 0 hashes whole files
+	parallelSize   int64 // files at least this large are hashed on every CPU; 0 never
+	skipChecksums  bool
 	cache          *checksumCache
 }
 
@@ -29,9 +43,37 @@ type checksumCache struct {
 }
 
 type cacheEntry struct {
-	checksum string
-	modTime  int64
-	size     int64
+	checksum  string
+	secondary string
+	algo      string
+	modTime   int64
+	size      int64
+	resume    *resumableHash // set once the file has been seen to grow
+}
+
+// checksumSeedContext is the blake3 key-derivation context used to turn a
+// user-supplied seed into a 32-byte key. Changing it invalidates every keyed
+// digest ever produced, so it must stay fixed.
+const checksumSeedContext = "relay 2025-01-01 checksum seed v1"
+
+// ScanFailure records an entry that could not be read during a scan.
+type ScanFailure struct {
+	Path string
+	Err  error
+}
+
+// IncompleteScanError is returned alongside the files that were scanned when
+// some entries below the root could not be read. Callers that only need a
+// best-effort listing may use the files; callers that act on absence, such as
+// deletion, must treat the listing as incomplete.
+type IncompleteScanError struct {
+	Root     string
+	Failures []ScanFailure
+}
+
+func (e *IncompleteScanError) Error() string {
+	return fmt.Sprintf("scan of %s incomplete: %d entries could not be read (first: %s: %v)",
+		e.Root, len(e.Failures), e.Failures[0].Path, e.Failures[0].Err)
 }
 
 // NewFileScanner creates a new file scanner with the specified concurrency limit.
@@ -49,28 +91,192 @@ func NewFileScanner(maxConcurrency int) *FileScanner {
 	}
 }
 
-// SetChecksumAlgorithm sets the checksum algorithm to use (blake3, sha256).
+// ErrUnsupportedChecksum is returned when a checksum algorithm is not built
+// into relay.
+var ErrUnsupportedChecksum = errors.New("unsupported checksum algorithm")
+
+// checksumHashers creates a hasher for each supported checksum algorithm.
+// Keyed blake3 is set up separately by newHasher.
+var checksumHashers = map[string]func() hash.Hash{
+	"blake3": func() hash.Hash { return blake3.New() },
+	"md5":    md5.New,
+	"sha256": sha256.New,
+}
+
+// ChecksumAlgorithms returns the names of the supported checksum algorithms,
+// sorted.
+func ChecksumAlgorithms() []string {
+	names := make([]string, 0, len(checksumHashers))
+	for name := range checksumHashers {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	return names
+}
+
+// SetChecksumAlgorithm sets the checksum algorithm to use. It does not check
+// that algo is supported; see SyncEngine.SetChecksumAlgorithm.
 func (s *FileScanner) SetChecksumAlgorithm(algo string) {
 	s.checksumAlgo = algo
 }
 
+// SetMaxConcurrency sets how many files are examined at once. Non-positive
+// values are ignored.
+func (s *FileScanner) SetMaxConcurrency(maxConcurrency int) {
+	if maxConcurrency > 0 {
+		s.maxConcurrency = int64(maxConcurrency)
+	}
+}
+
+// SetChecksumConcurrency caps how many files are hashed at once,
+// independently of how many entries are walked and stat'ed concurrently.
+// Hashing is CPU-bound while walking is syscall-bound, so checksum-heavy jobs
+// can limit CPU use without slowing the walk. Non-positive values remove the
+// cap.
+func (s *FileScanner) SetChecksumConcurrency(concurrency int) {
+	if concurrency <= 0 {
+		s.checksumSem = nil
+		return
+	}
+
+	s.checksumSem = semaphore.NewWeighted(int64(concurrency))
+}
+
+// SetSecondaryChecksumAlgorithm enables a second, independent digest computed
+// in the same read pass as the primary one. Files are only considered equal
+// when both digests match. An empty algo disables the second digest.
+func (s *FileScanner) SetSecondaryChecksumAlgorithm(algo string) {
+	s.ClearCache()
+	s.secondaryAlgo = algo
+}
+
+// SetChecksumSeed enables blake3 keyed hashing with a key derived from seed.
+// Keyed digests are only comparable when every run uses the same seed. An
+// empty seed restores plain hashing. The seed is ignored by other algorithms.
+func (s *FileScanner) SetChecksumSeed(seed string) {
+	// Digests cached under a different key are no longer valid.
+	s.ClearCache()
+
+	if seed == "" {
+		s.checksumKey = nil
+		return
+	}
+
+	key := make([]byte, 32)
+	blake3.DeriveKey(checksumSeedContext, []byte(seed), key)
+	s.checksumKey = key
+}
+
+// SetSampleSize makes files larger than three samples be hashed from a
+// sample of size bytes at their start, middle and end, plus their size,
+// instead of in full. Such a digest detects most changes at a fraction of the
+// cost, but it is not an integrity check. A non-positive size restores full
+// hashing.
+func (s *FileScanner) SetSampleSize(size int64) {
+	s.ClearCache()
+	s.sampleSize = max(size, 0)
+}
+
+// SetParallelHashSize makes files of at least size bytes be hashed on every
+// CPU at once, in segments of the blake3 hash tree, instead of on one. The
+// digests are the same either way, so this only changes how fast they are
+// computed. Other algorithms, and double-checked files, which are hashed
+// with two algorithms in one pass, are always hashed on one CPU. A
+// non-positive size turns this off.
+func (s *FileScanner) SetParallelHashSize(size int64) {
+	s.parallelSize = max(size, 0)
+}
+
+// SetSkipChecksums makes scans list files without reading them, leaving
+// their digests empty, for comparisons by size and modification time alone.
+func (s *FileScanner) SetSkipChecksums(skip bool) {
+	s.skipChecksums = skip
+}
+
+// parallel reports whether a file of size bytes is hashed on every CPU.
+func (s *FileScanner) parallel(size int64) bool {
+	return s.parallelSize > 0 && size >= s.parallelSize && s.checksumAlgo == "blake3" &&
+		s.secondaryAlgo == "" && !s.sampled(size) && runtime.GOMAXPROCS(0) > 1
+}
+
+// checksumLabel returns the algorithm name recorded alongside each digest.
+func (s *FileScanner) checksumLabel() string {
+	if s.checksumAlgo == "blake3" && s.checksumKey != nil {
+		return "blake3-keyed"
+	}
+
+	return s.checksumAlgo
+}
+
+// sampled reports whether a file of size bytes is hashed from samples.
+func (s *FileScanner) sampled(size int64) bool {
+	return s.sampleSize > 0 && size > 3*s.sampleSize
+}
+
+// sampleLabel returns the suffix that marks sampled digests. It records the
+// sample size, since sampled digests only compare equal at the same one.
+func (s *FileScanner) sampleLabel() string {
+	if s.sampleSize == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("-sampled-%d", s.sampleSize)
+}
+
+// fileLabel returns the algorithm name recorded for the digest of a file of
+// size bytes, so a sampled digest is never taken for a full one.
+func (s *FileScanner) fileLabel(size int64) string {
+	if s.sampled(size) {
+		return s.checksumLabel() + s.sampleLabel()
+	}
+
+	return s.checksumLabel()
+}
+
 // Scan recursively scans a directory and returns file information.
 func (s *FileScanner) Scan(ctx context.Context, path string) ([]*FileInfo, error) {
 	return s.ScanWithFilter(ctx, path, nil)
 }
 
-// ScanWithFilter scans a directory with the given filter function.
+// ScanWithFilter scans a directory with the given filter function. The filter
+// runs before checksums are computed, so excluded files are never read and
+// info.Checksum is always empty inside the filter. If entries below the root
+// cannot be read, the files that were scanned are returned together with an
+// *IncompleteScanError.
 func (s *FileScanner) ScanWithFilter(ctx context.Context, path string, filter FilterFunc) ([]*FileInfo, error) {
 	var (
-		files []*FileInfo
-		mu    sync.Mutex
+		files    []*FileInfo
+		failures []ScanFailure
+		mu       sync.Mutex
 	)
 
+	recordFailure := func(filePath string, err error) {
+		mu.Lock()
+		failures = append(failures, ScanFailure{Path: filePath, Err: err})
+		mu.Unlock()
+	}
+
 	sem := semaphore.NewWeighted(s.maxConcurrency)
 
-	err := filepath.WalkDir(path, func(filePath string, d fs.DirEntry, err error) error {
+	root := toExtendedPath(path)
+
+	err := filepath.WalkDir(root, func(filePath string, d fs.DirEntry, err error) error {
 		if err != nil {
-			return err
+			// An unreadable root fails the scan outright; anything below it is
+			// recorded and skipped so the rest of the tree is still scanned.
+			if filePath == root {
+				return err
+			}
+
+			recordFailure(fromExtendedPath(filePath), err)
+
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+
+			return nil
 		}
 
 		select {
@@ -79,6 +285,8 @@ func (s *FileScanner) ScanWithFilter(ctx context.Context, path string, filter Fi
 		default:
 		}
 
+		filePath = fromExtendedPath(filePath)
+
 		if err := sem.Acquire(ctx, 1); err != nil {
 			return err
 		}
@@ -86,8 +294,9 @@ func (s *FileScanner) ScanWithFilter(ctx context.Context, path string, filter Fi
 		go func() {
 			defer sem.Release(1)
 
-			info, err := s.getFileInfo(filePath, d)
+			info, err := s.statFileInfo(filePath, d)
 			if err != nil {
+				recordFailure(filePath, err)
 				return
 			}
 
@@ -95,6 +304,8 @@ func (s *FileScanner) ScanWithFilter(ctx context.Context, path string, filter Fi
 				return
 			}
 
+			s.populateChecksum(info)
+
 			mu.Lock()
 
 			files = append(files, info)
@@ -114,51 +325,78 @@ func (s *FileScanner) ScanWithFilter(ctx context.Context, path string, filter Fi
 
 	sem.Release(s.maxConcurrency)
 
+	if len(failures) > 0 {
+		return files, &IncompleteScanError{Root: path, Failures: failures}
+	}
+
 	return files, nil
 }
 
-func (s *FileScanner) getFileInfo(path string, d fs.DirEntry) (*FileInfo, error) {
+func (s *FileScanner) statFileInfo(path string, d fs.DirEntry) (*FileInfo, error) {
 	stat, err := d.Info()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get file info for %s: %w", path, err)
 	}
 
-	info := &FileInfo{
+	return &FileInfo{
 		Path:    path,
 		Size:    stat.Size(),
 		ModTime: stat.ModTime(),
 		Mode:    uint32(stat.Mode()),
 		IsDir:   stat.IsDir(),
+	}, nil
+}
+
+// populateChecksum fills in the file's digests. Directories and empty files
+// are left without a checksum: every empty file has the same content, so
+// size and modification time fully describe them, and comparisons such as
+// needsSync and DetectConflict never reach their checksum branch for them.
+func (s *FileScanner) populateChecksum(info *FileInfo) {
+	if info.IsDir || info.Size == 0 || s.skipChecksums {
+		return
 	}
 
-	if !info.IsDir && info.Size > 0 {
-		checksum, err := s.getChecksum(path, info)
-		if err == nil {
-			info.Checksum = checksum
-			info.ChecksumAlgo = s.checksumAlgo
+	checksum, secondary, err := s.getChecksum(info.Path, info)
+	if err == nil {
+		info.Checksum = checksum
+		info.ChecksumAlgo = s.fileLabel(info.Size)
+
+		if s.secondaryAlgo != "" {
+			info.SecondaryChecksum = secondary
+			info.SecondaryChecksumAlgo = s.secondaryAlgo
 		}
 	}
-
-	return info, nil
 }
 
-func (s *FileScanner) getChecksum(path string, info *FileInfo) (string, error) {
+func (s *FileScanner) getChecksum(path string, info *FileInfo) (string, string, error) {
 	cacheKey := path
+	label := s.fileLabel(info.Size) + "+" + s.secondaryAlgo
 
 	s.cache.mu.RLock()
+	entry, exists := s.cache.cache[cacheKey]
+	s.cache.mu.RUnlock()
 
-	if entry, exists := s.cache.cache[cacheKey]; exists {
-		if entry.modTime == info.ModTime.Unix() && entry.size == info.Size {
-			s.cache.mu.RUnlock()
-			return entry.checksum, nil
-		}
+	if exists && entry.algo == label && entry.modTime == info.ModTime.Unix() && entry.size == info.Size {
+		return entry.checksum, entry.secondary, nil
 	}
 
-	s.cache.mu.RUnlock()
+	// A file seen before that has only grown, such as a log, is hashed from
+	// where its previous digest left off.
+	grown := exists && entry.algo == label && entry.size < info.Size && s.incremental(info.Size)
+
+	if s.checksumSem != nil {
+		// Acquire cannot fail with a background context.
+		_ = s.checksumSem.Acquire(context.Background(), 1)
+		defer s.checksumSem.Release(1)
+	}
 
-	file, err := os.Open(path)
+	// Acquire cannot fail with a background context.
+	release, _ := s.openFiles.acquire(context.Background(), 1)
+	defer release()
+
+	file, err := os.Open(toExtendedPath(path))
 	if err != nil {
-		return "", fmt.Errorf("failed to open file %s: %w", path, err)
+		return "", "", fmt.Errorf("failed to open file %s: %w", path, err)
 	}
 
 	defer func() {
@@ -167,51 +405,134 @@ func (s *FileScanner) getChecksum(path string, info *FileInfo) (string, error) {
 		}
 	}()
 
-	checksum, err := s.calculateChecksum(file)
+	var (
+		checksum, secondary string
+		resume              *resumableHash
+	)
+
+	if grown {
+		checksum, resume, err = s.appendChecksum(file, info.Size, entry.resume)
+	} else {
+		checksum, secondary, err = s.fileChecksum(file, info.Size)
+	}
+
 	if err != nil {
-		return "", fmt.Errorf("failed to calculate checksum for %s: %w", path, err)
+		return "", "", fmt.Errorf("failed to calculate checksum for %s: %w", path, err)
 	}
 
 	s.cache.mu.Lock()
 	s.cache.cache[cacheKey] = cacheEntry{
-		checksum: checksum,
-		modTime:  info.ModTime.Unix(),
-		size:     info.Size,
+		checksum:  checksum,
+		secondary: secondary,
+		algo:      label,
+		modTime:   info.ModTime.Unix(),
+		size:      info.Size,
+		resume:    resume,
 	}
 	s.cache.mu.Unlock()
 
-	return checksum, nil
+	return checksum, secondary, nil
+}
+
+// seedChecksum records the digests of the file at path, known from an
+// earlier run, so that it is not hashed again while its size and
+// modification time are unchanged.
+func (s *FileScanner) seedChecksum(path string, size int64, modTime time.Time, checksum, secondary string) {
+	s.cache.mu.Lock()
+	defer s.cache.mu.Unlock()
+
+	s.cache.cache[path] = cacheEntry{
+		checksum:  checksum,
+		secondary: secondary,
+		algo:      s.fileLabel(size) + "+" + s.secondaryAlgo,
+		modTime:   modTime.Unix(),
+		size:      size,
+	}
 }
 
-func (s *FileScanner) calculateChecksum(reader io.Reader) (string, error) {
-	switch s.checksumAlgo {
-	case "blake3":
-		hasher := blake3.New()
-		if _, err := io.Copy(hasher, reader); err != nil {
-			return "", err
+// fileChecksum returns the digests of file, which is size bytes long, hashed
+// in full or from samples as configured.
+func (s *FileScanner) fileChecksum(file *os.File, size int64) (string, string, error) {
+	if s.parallel(size) {
+		digest, err := newBlake3TreeHasher(s.checksumKey).hashParallel(file, size, runtime.GOMAXPROCS(0))
+		if err != nil {
+			return "", "", err
 		}
 
-		return hex.EncodeToString(hasher.Sum(nil)), nil
+		return hex.EncodeToString(digest), "", nil
+	}
+
+	if !s.sampled(size) {
+		return s.calculateChecksum(file)
+	}
+
+	n := s.sampleSize
+
+	var header [8]byte
+	binary.LittleEndian.PutUint64(header[:], uint64(size))
 
-	case "sha256":
-		hasher := sha256.New()
-		if _, err := io.Copy(hasher, reader); err != nil {
-			return "", err
+	return s.calculateChecksum(io.MultiReader(
+		bytes.NewReader(header[:]),
+		io.NewSectionReader(file, 0, n),
+		io.NewSectionReader(file, (size-n)/2, n),
+		io.NewSectionReader(file, size-n, n),
+	))
+}
+
+// calculateChecksum returns the primary digest and, when a secondary
+// algorithm is set, the secondary digest of reader.
+func (s *FileScanner) calculateChecksum(reader io.Reader) (string, string, error) {
+	primary, err := s.newHasher(s.checksumAlgo)
+	if err != nil {
+		return "", "", err
+	}
+
+	if s.secondaryAlgo == "" {
+		if _, err := io.Copy(primary, reader); err != nil {
+			return "", "", err
 		}
 
-		return hex.EncodeToString(hasher.Sum(nil)), nil
+		return hex.EncodeToString(primary.Sum(nil)), "", nil
+	}
 
-	case "md5":
-		hasher := sha256.New()
-		if _, err := io.Copy(hasher, reader); err != nil {
-			return "", err
+	secondary, err := s.newHasher(s.secondaryAlgo)
+	if err != nil {
+		return "", "", err
+	}
+
+	if _, err := io.Copy(io.MultiWriter(primary, secondary), reader); err != nil {
+		return "", "", err
+	}
+
+	return hex.EncodeToString(primary.Sum(nil)), hex.EncodeToString(secondary.Sum(nil)), nil
+}
+
+func (s *FileScanner) newHasher(algo string) (hash.Hash, error) {
+	if algo == "blake3" && s.checksumKey != nil {
+		keyed, err := blake3.NewKeyed(s.checksumKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create keyed hasher: %w", err)
 		}
 
-		return hex.EncodeToString(hasher.Sum(nil)), nil
+		return keyed, nil
+	}
+
+	newHash, ok := checksumHashers[algo]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedChecksum, algo)
+	}
 
-	default:
-		return "", fmt.Errorf("unsupported checksum algorithm: %s", s.checksumAlgo)
+	return newHash(), nil
+}
+
+// checksumsDiffer reports whether two files have different content according
+// to the digests both of them carry. Missing digests are not compared.
+func checksumsDiffer(a, b *FileInfo) bool {
+	if a.Checksum != "" && b.Checksum != "" && a.Checksum != b.Checksum {
+		return true
 	}
+
+	return a.SecondaryChecksum != "" && b.SecondaryChecksum != "" && a.SecondaryChecksum != b.SecondaryChecksum
 }
 
 // ClearCache clears the checksum cache.
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+const (
+	benchHashFiles    = 32
+	benchHashFileSize = 4 << 20 // 4 MiB
+)
+
+// BenchmarkFileScannerChecksumConcurrency shows how hashing throughput scales
+// with SetChecksumConcurrency while walk concurrency stays fixed.
+func BenchmarkFileScannerChecksumConcurrency(b *testing.B) {
+	sourceDir := b.TempDir()
+
+	for i := range benchHashFiles {
+		writeBenchFile(b, filepath.Join(sourceDir, fmt.Sprintf("file%02d.bin", i)), benchHashFileSize)
+	}
+
+	levels := []int{1, 2, 4, runtime.GOMAXPROCS(0)}
+	slices.Sort(levels)
+	levels = slices.Compact(levels)
+
+	for _, concurrency := range levels {
+		b.Run(fmt.Sprintf("checksum-%d", concurrency), func(b *testing.B) {
+			scanner := NewFileScanner(benchHashFiles)
+			scanner.SetChecksumConcurrency(concurrency)
+
+			b.SetBytes(benchHashFiles * benchHashFileSize)
+
+			for b.Loop() {
+				scanner.ClearCache()
+
+				if _, err := scanner.Scan(context.Background(), sourceDir); err != nil {
+					b.Fatalf("Scan failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkFileScannerParallelHash compares hashing one large file on one CPU
+// with SetParallelHashSize. It only speeds up with GOMAXPROCS above one.
+func BenchmarkFileScannerParallelHash(b *testing.B) {
+	const size = 256 << 20
+
+	path := filepath.Join(b.TempDir(), "large.bin")
+	writeBenchFile(b, path, size)
+
+	for _, parallelSize := range []int64{0, 1} {
+		b.Run(fmt.Sprintf("parallel-%t", parallelSize > 0), func(b *testing.B) {
+			scanner := NewFileScanner(1)
+			scanner.SetParallelHashSize(parallelSize)
+
+			b.SetBytes(size)
+
+			for b.Loop() {
+				scanner.ClearCache()
+
+				if _, err := scanner.Scan(context.Background(), path); err != nil {
+					b.Fatalf("Scan failed: %v", err)
+				}
+			}
+		})
+	}
+}
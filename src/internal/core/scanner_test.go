@@ -1,7 +1,10 @@
 package core
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"os"
@@ -10,6 +13,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/zeebo/blake3"
 )
 
 func TestFileScannerNewFileScanner(t *testing.T) {
@@ -185,6 +190,329 @@ func TestFileScannerScanWithFilter(t *testing.T) {
 	}
 }
 
+func TestFileScannerChecksumSeed(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	testFile := filepath.Join(tempDir, "test.txt")
+	if err := os.WriteFile(testFile, []byte("seeded content"), 0o644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	checksumWithSeed := func(seed string) *FileInfo {
+		scanner := NewFileScanner(1)
+		scanner.SetChecksumSeed(seed)
+
+		files, err := scanner.Scan(context.Background(), testFile)
+		if err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+
+		if len(files) != 1 {
+			t.Fatalf("Expected 1 file, got %d", len(files))
+		}
+
+		return files[0]
+	}
+
+	plain := checksumWithSeed("")
+	keyedA := checksumWithSeed("alpha")
+	keyedAgain := checksumWithSeed("alpha")
+	keyedB := checksumWithSeed("beta")
+
+	if plain.ChecksumAlgo != "blake3" {
+		t.Errorf("Plain ChecksumAlgo = %s, want blake3", plain.ChecksumAlgo)
+	}
+
+	if keyedA.ChecksumAlgo != "blake3-keyed" {
+		t.Errorf("Keyed ChecksumAlgo = %s, want blake3-keyed", keyedA.ChecksumAlgo)
+	}
+
+	if keyedA.Checksum == plain.Checksum {
+		t.Errorf("Keyed checksum should differ from plain checksum")
+	}
+
+	if keyedA.Checksum != keyedAgain.Checksum {
+		t.Errorf("Same seed produced different checksums: %s vs %s", keyedA.Checksum, keyedAgain.Checksum)
+	}
+
+	if keyedA.Checksum == keyedB.Checksum {
+		t.Errorf("Different seeds produced the same checksum")
+	}
+}
+
+func TestFileScannerSecondaryChecksum(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	testFile := filepath.Join(tempDir, "test.txt")
+	if err := os.WriteFile(testFile, []byte("audited content"), 0o644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	scanner := NewFileScanner(1)
+
+	files, err := scanner.Scan(context.Background(), testFile)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	if files[0].SecondaryChecksum != "" {
+		t.Errorf("SecondaryChecksum should be empty by default, got %s", files[0].SecondaryChecksum)
+	}
+
+	// Enabling the second digest must not be served from the cache above.
+	scanner.SetSecondaryChecksumAlgorithm("sha256")
+
+	files, err = scanner.Scan(context.Background(), testFile)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	sum := sha256.Sum256([]byte("audited content"))
+	if want := hex.EncodeToString(sum[:]); files[0].SecondaryChecksum != want {
+		t.Errorf("SecondaryChecksum = %s, want %s", files[0].SecondaryChecksum, want)
+	}
+
+	if files[0].ChecksumAlgo != "blake3" || files[0].SecondaryChecksumAlgo != "sha256" {
+		t.Errorf("Algorithms = %s/%s, want blake3/sha256", files[0].ChecksumAlgo, files[0].SecondaryChecksumAlgo)
+	}
+}
+
+func TestFileScannerSampleChecksum(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	// 64 bytes: with 8-byte samples, bytes 28-35 are the middle sample and
+	// bytes 8-27 and 36-55 are not sampled.
+	content := []byte(strings.Repeat("0123456789abcdef", 4))
+
+	checksumOf := func(name string, data []byte, sampleSize int64) *FileInfo {
+		path := filepath.Join(tempDir, name)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+
+		scanner := NewFileScanner(1)
+		scanner.SetSampleSize(sampleSize)
+
+		files, err := scanner.Scan(context.Background(), path)
+		if err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+
+		return files[0]
+	}
+
+	withByte := func(offset int, b byte) []byte {
+		data := []byte(string(content))
+		data[offset] = b
+
+		return data
+	}
+
+	full := checksumOf("full", content, 0)
+	sampled := checksumOf("sampled", content, 8)
+
+	if sampled.ChecksumAlgo != "blake3-sampled-8" {
+		t.Errorf("sampled ChecksumAlgo = %s, want blake3-sampled-8", sampled.ChecksumAlgo)
+	}
+
+	if sampled.Checksum == full.Checksum {
+		t.Error("sampled checksum equals the full checksum")
+	}
+
+	if got := checksumOf("unsampled-edit", withByte(12, 'x'), 8); got.Checksum != sampled.Checksum {
+		t.Error("an edit outside the samples changed the sampled checksum")
+	}
+
+	for _, offset := range []int{0, 30, 63} {
+		if got := checksumOf(fmt.Sprintf("edit-%d", offset), withByte(offset, 'x'), 8); got.Checksum == sampled.Checksum {
+			t.Errorf("an edit at byte %d did not change the sampled checksum", offset)
+		}
+	}
+
+	if got := checksumOf("grown", append(content, '!'), 8); got.Checksum == sampled.Checksum {
+		t.Error("a size change did not change the sampled checksum")
+	}
+
+	// Files no larger than three samples are hashed in full.
+	if small := checksumOf("small", content, 32); small.Checksum != full.Checksum || small.ChecksumAlgo != "blake3" {
+		t.Errorf("small file = %s (%s), want the full checksum %s", small.Checksum, small.ChecksumAlgo, full.Checksum)
+	}
+}
+
+func TestChecksumsDiffer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		a    *FileInfo
+		b    *FileInfo
+		want bool
+	}{
+		{name: "no digests", a: &FileInfo{}, b: &FileInfo{}, want: false},
+		{name: "primary match", a: &FileInfo{Checksum: "aa"}, b: &FileInfo{Checksum: "aa"}, want: false},
+		{name: "primary differs", a: &FileInfo{Checksum: "aa"}, b: &FileInfo{Checksum: "bb"}, want: true},
+		{
+			name: "secondary differs",
+			a:    &FileInfo{Checksum: "aa", SecondaryChecksum: "11"},
+			b:    &FileInfo{Checksum: "aa", SecondaryChecksum: "22"},
+			want: true,
+		},
+		{
+			name: "secondary missing on one side",
+			a:    &FileInfo{Checksum: "aa", SecondaryChecksum: "11"},
+			b:    &FileInfo{Checksum: "aa"},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := checksumsDiffer(tt.a, tt.b); got != tt.want {
+				t.Errorf("checksumsDiffer() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileScannerChecksumConcurrency(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	for i := range 8 {
+		path := filepath.Join(tempDir, fmt.Sprintf("file%d.txt", i))
+		if err := os.WriteFile(path, []byte(fmt.Sprintf("content %d", i)), 0o644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	unlimited, err := NewFileScanner(8).Scan(context.Background(), tempDir)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	capped := NewFileScanner(8)
+	capped.SetChecksumConcurrency(1)
+
+	files, err := capped.Scan(context.Background(), tempDir)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	want := make(map[string]string, len(unlimited))
+	for _, file := range unlimited {
+		want[file.Path] = file.Checksum
+	}
+
+	for _, file := range files {
+		if file.Checksum != want[file.Path] {
+			t.Errorf("Checksum of %s = %q with a cap, want %q", file.Path, file.Checksum, want[file.Path])
+		}
+	}
+}
+
+func TestFileScannerIncrementalChecksum(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	content := bytes.Repeat([]byte("log line\n"), incrementalMinSize/9+1)
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	scanner := NewFileScanner(1)
+
+	// scan writes content to the log, a second later than the last version,
+	// and returns its checksum.
+	scan := func() string {
+		t.Helper()
+
+		modTime = modTime.Add(time.Second)
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("Failed to set times: %v", err)
+		}
+
+		files, err := scanner.Scan(context.Background(), path)
+		if err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+
+		return files[0].Checksum
+	}
+
+	full := func() string {
+		sum := blake3.Sum256(content)
+		return hex.EncodeToString(sum[:])
+	}
+
+	for i := range 3 {
+		if got := scan(); got != full() {
+			t.Fatalf("checksum after %d appends = %s, want %s", i, got, full())
+		}
+
+		content = append(content, fmt.Sprintf("appended %d\n", i)...)
+	}
+
+	// Only the appended bytes are read once the file has been seen to grow,
+	// so a change in the middle of the earlier content goes unnoticed...
+	content[len(content)/2] ^= 0xff
+	content = append(content, "more\n"...)
+
+	if got := scan(); got == full() {
+		t.Error("grown file was hashed in full, not from the previous state")
+	}
+
+	// ...while a rewrite that changes its start is hashed in full.
+	content[0] ^= 0xff
+	content = append(content, "rotated\n"...)
+
+	if got := scan(); got != full() {
+		t.Errorf("checksum of a rewritten file = %s, want %s", got, full())
+	}
+}
+
+func TestFileScannerEmptyFilesHaveNoChecksum(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	modTime := time.Now().Add(-time.Minute).Truncate(time.Second)
+
+	var infos []*FileInfo
+
+	for _, name := range []string{"a.empty", "b.empty"} {
+		path := filepath.Join(tempDir, name)
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatalf("Failed to create empty file: %v", err)
+		}
+
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("Failed to set times: %v", err)
+		}
+
+		files, err := NewFileScanner(1).Scan(context.Background(), path)
+		if err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+
+		if files[0].Checksum != "" || files[0].ChecksumAlgo != "" {
+			t.Errorf("Empty file %s has checksum %q (%s), want none", name, files[0].Checksum, files[0].ChecksumAlgo)
+		}
+
+		infos = append(infos, files[0])
+	}
+
+	// Two unrelated empty files with the same modtime are equal.
+	if conflict := NewConflictResolver(nil).DetectConflict(infos[0], infos[1]); conflict != nil {
+		t.Errorf("DetectConflict() = %+v, want nil for identical empty files", conflict)
+	}
+}
+
 func TestFileScannerCacheStats(t *testing.T) {
 	t.Parallel()
 	tempDir := t.TempDir()
@@ -266,6 +594,58 @@ func TestFileScannerNonExistentDirectory(t *testing.T) {
 	}
 }
 
+func TestFileScannerIncompleteScan(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("directory permissions are not enforced")
+	}
+
+	tempDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(tempDir, "readable.txt"), []byte("ok"), 0o644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	lockedDir := filepath.Join(tempDir, "locked")
+	if err := os.Mkdir(lockedDir, 0o755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(lockedDir, "hidden.txt"), []byte("hidden"), 0o644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	if err := os.Chmod(lockedDir, 0o000); err != nil {
+		t.Fatalf("Failed to lock directory: %v", err)
+	}
+
+	t.Cleanup(func() { _ = os.Chmod(lockedDir, 0o755) })
+
+	files, err := NewFileScanner(1).Scan(context.Background(), tempDir)
+
+	var incomplete *IncompleteScanError
+	if !errors.As(err, &incomplete) {
+		t.Fatalf("Scan() error = %v, want *IncompleteScanError", err)
+	}
+
+	if len(incomplete.Failures) != 1 || incomplete.Failures[0].Path != lockedDir {
+		t.Errorf("Failures = %v, want one failure for %s", incomplete.Failures, lockedDir)
+	}
+
+	found := false
+
+	for _, file := range files {
+		if filepath.Base(file.Path) == "readable.txt" {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Errorf("Expected partial results to include readable.txt")
+	}
+}
+
 // Helper function to get default concurrency
 func defaultConcurrency() int {
 	// This should match the logic in NewFileScanner
@@ -0,0 +1,167 @@
+package core
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// ServiceSpec describes a relay command to be started by the system's
+// service manager.
+type ServiceSpec struct {
+	Name        string   // systemd unit, launchd label or scheduled task name
+	Description string   // one line saying what the service does
+	Command     []string // the executable followed by its arguments
+	WorkingDir  string
+	System      bool   // start at boot for the whole system rather than at login
+	LogPath     string // launchd: file the service's output is appended to
+}
+
+// SystemdUnit returns the systemd unit that runs spec and restarts it when
+// it fails.
+func SystemdUnit(spec ServiceSpec) string {
+	var unit strings.Builder
+
+	target := "default.target"
+
+	fmt.Fprintf(&unit, "[Unit]\nDescription=%s\n", spec.Description)
+
+	if spec.System {
+		target = "multi-user.target"
+
+		unit.WriteString("Wants=network-online.target\nAfter=network-online.target\n")
+	}
+
+	unit.WriteString("\n[Service]\nType=simple\n")
+	fmt.Fprintf(&unit, "ExecStart=%s\n", systemdCommandLine(spec.Command))
+
+	// WorkingDirectory= takes the path as it is, without unquoting it, so
+	// only specifiers are escaped.
+	if spec.WorkingDir != "" {
+		fmt.Fprintf(&unit, "WorkingDirectory=%s\n", strings.ReplaceAll(spec.WorkingDir, "%", "%%"))
+	}
+
+	unit.WriteString("Restart=on-failure\nRestartSec=10\n")
+	fmt.Fprintf(&unit, "\n[Install]\nWantedBy=%s\n", target)
+
+	return unit.String()
+}
+
+// systemdCommandLine quotes args for an ExecStart line.
+func systemdCommandLine(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = systemdQuote(arg)
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// systemdQuote quotes arg for a unit file when it needs it, and escapes the
+// % that starts a systemd specifier.
+func systemdQuote(arg string) string {
+	arg = strings.ReplaceAll(arg, "%", "%%")
+
+	if arg != "" && !strings.ContainsAny(arg, " \t\"'\\;$") {
+		return arg
+	}
+
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", "$$")
+
+	return `"` + replacer.Replace(arg) + `"`
+}
+
+// LaunchdPlist returns the launchd property list that runs spec when it is
+// loaded, at login or boot, and restarts it when it fails.
+func LaunchdPlist(spec ServiceSpec) string {
+	var plist bytes.Buffer
+
+	plist.WriteString(xml.Header)
+	plist.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	plist.WriteString("<plist version=\"1.0\">\n<dict>\n")
+
+	plistString(&plist, "Label", spec.Name)
+
+	plist.WriteString("\t<key>ProgramArguments</key>\n\t<array>\n")
+
+	for _, arg := range spec.Command {
+		plist.WriteString("\t\t<string>" + xmlEscape(arg) + "</string>\n")
+	}
+
+	plist.WriteString("\t</array>\n")
+
+	if spec.WorkingDir != "" {
+		plistString(&plist, "WorkingDirectory", spec.WorkingDir)
+	}
+
+	plist.WriteString("\t<key>RunAtLoad</key>\n\t<true/>\n")
+	plist.WriteString("\t<key>KeepAlive</key>\n\t<dict>\n\t\t<key>SuccessfulExit</key>\n\t\t<false/>\n\t</dict>\n")
+
+	if spec.LogPath != "" {
+		plistString(&plist, "StandardOutPath", spec.LogPath)
+		plistString(&plist, "StandardErrorPath", spec.LogPath)
+	}
+
+	plist.WriteString("</dict>\n</plist>\n")
+
+	return plist.String()
+}
+
+// plistString writes a string entry of a property list dictionary.
+func plistString(plist *bytes.Buffer, key, value string) {
+	plist.WriteString("\t<key>" + xmlEscape(key) + "</key>\n\t<string>" + xmlEscape(value) + "</string>\n")
+}
+
+// xmlEscape escapes text for an XML element.
+func xmlEscape(text string) string {
+	var escaped bytes.Buffer
+
+	_ = xml.EscapeText(&escaped, []byte(text))
+
+	return escaped.String()
+}
+
+// WindowsCommandLine joins args into a command line that Windows programs
+// split back into args, quoting those with spaces or quotes.
+func WindowsCommandLine(args []string) string {
+	quoted := make([]string, len(args))
+
+	for i, arg := range args {
+		if arg != "" && !strings.ContainsAny(arg, " \t\"") {
+			quoted[i] = arg
+			continue
+		}
+
+		var b strings.Builder
+
+		b.WriteByte('"')
+
+		backslashes := 0
+
+		for _, r := range arg {
+			switch r {
+			case '\\':
+				backslashes++
+				continue
+			case '"':
+				// Backslashes before a quote are doubled, and the quote escaped.
+				b.WriteString(strings.Repeat(`\`, backslashes*2+1))
+			default:
+				b.WriteString(strings.Repeat(`\`, backslashes))
+			}
+
+			backslashes = 0
+
+			b.WriteRune(r)
+		}
+
+		// Backslashes before the closing quote are doubled too.
+		b.WriteString(strings.Repeat(`\`, backslashes*2))
+		b.WriteByte('"')
+
+		quoted[i] = b.String()
+	}
+
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,121 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSystemdUnit(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		spec   ServiceSpec
+		want   []string
+		reject []string
+	}{
+		{
+			name: "user unit",
+			spec: ServiceSpec{
+				Description: "relay daemon",
+				Command:     []string{"/usr/bin/relay", "daemon", "--config", "/home/me/relay.json"},
+				WorkingDir:  "/home/me",
+			},
+			want: []string{
+				"ExecStart=/usr/bin/relay daemon --config /home/me/relay.json\n",
+				"WorkingDirectory=/home/me\n",
+				"Restart=on-failure\n",
+				"WantedBy=default.target\n",
+			},
+			reject: []string{"network-online.target"},
+		},
+		{
+			name: "system unit",
+			spec: ServiceSpec{Command: []string{"/usr/bin/relay", "daemon"}, System: true},
+			want: []string{"After=network-online.target\n", "WantedBy=multi-user.target\n"},
+		},
+		{
+			name: "quoting",
+			spec: ServiceSpec{Command: []string{"/opt/my tools/relay", "daemon", "--config", `C:\50% "off"$HOME`}},
+			want: []string{`ExecStart="/opt/my tools/relay" daemon --config "C:\\50%% \"off\"$$HOME"` + "\n"},
+		},
+		{
+			name: "working directory with spaces",
+			spec: ServiceSpec{Command: []string{"/usr/bin/relay", "daemon"}, WorkingDir: `/home/me/My Backups 100% "done"`},
+			want: []string{`WorkingDirectory=/home/me/My Backups 100%% "done"` + "\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			unit := SystemdUnit(tt.spec)
+
+			for _, want := range tt.want {
+				if !strings.Contains(unit, want) {
+					t.Errorf("SystemdUnit() missing %q in:\n%s", want, unit)
+				}
+			}
+
+			for _, reject := range tt.reject {
+				if strings.Contains(unit, reject) {
+					t.Errorf("SystemdUnit() contains %q in:\n%s", reject, unit)
+				}
+			}
+		})
+	}
+}
+
+func TestLaunchdPlist(t *testing.T) {
+	t.Parallel()
+
+	plist := LaunchdPlist(ServiceSpec{
+		Name:    "com.github.howmanysmall.relay",
+		Command: []string{"/usr/local/bin/relay", "daemon", "--config", "/Users/me/Backups & <Photos>/relay.json"},
+		LogPath: "/Users/me/Library/Logs/relay.log",
+	})
+
+	for _, want := range []string{
+		"<key>Label</key>\n\t<string>com.github.howmanysmall.relay</string>",
+		"<string>/Users/me/Backups &amp; &lt;Photos&gt;/relay.json</string>",
+		"<key>RunAtLoad</key>\n\t<true/>",
+		"<key>SuccessfulExit</key>\n\t\t<false/>",
+		"<key>StandardErrorPath</key>\n\t<string>/Users/me/Library/Logs/relay.log</string>",
+	} {
+		if !strings.Contains(plist, want) {
+			t.Errorf("LaunchdPlist() missing %q in:\n%s", want, plist)
+		}
+	}
+
+	if strings.Contains(plist, "WorkingDirectory") {
+		t.Errorf("LaunchdPlist() sets WorkingDirectory without one in the spec:\n%s", plist)
+	}
+}
+
+func TestWindowsCommandLine(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"plain", []string{`C:\relay.exe`, "daemon"}, `C:\relay.exe daemon`},
+		{"spaces", []string{`C:\Program Files\relay.exe`, "--config", `D:\my sync\relay.json`}, `"C:\Program Files\relay.exe" --config "D:\my sync\relay.json"`},
+		{"empty", []string{"relay", ""}, `relay ""`},
+		{"quotes", []string{"relay", `say "hi"`}, `relay "say \"hi\""`},
+		{"trailing backslash", []string{"relay", `C:\my dir\`}, `relay "C:\my dir\\"`},
+		{"backslash before quote", []string{"relay", `a\"b`}, `relay "a\\\"b"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := WindowsCommandLine(tt.args); got != tt.want {
+				t.Errorf("WindowsCommandLine(%q) = %s, want %s", tt.args, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package core
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// SmartPreset is a set of exclusions for the build output, caches and other
+// clutter of one kind of project.
+type SmartPreset struct {
+	Name     string
+	Markers  []string // globs matching names at the source root that identify the project; none for presets that always apply
+	Excludes []string // glob patterns in the syntax of IgnoreFileName files
+}
+
+// SmartPresets are the presets FileFilter.SetSmart chooses from. Output
+// directories with generic names, such as build or target, are only excluded
+// at the source root, where the project's tools put them.
+var SmartPresets = []SmartPreset{
+	{
+		Name:     "common",
+		Excludes: []string{".git/", ".hg/", ".svn/", ".DS_Store", "._*", "Thumbs.db", "desktop.ini", "*.swp", "*~"},
+	},
+	{
+		Name:     "node",
+		Markers:  []string{"package.json"},
+		Excludes: []string{"node_modules/", ".next/", ".nuxt/", ".svelte-kit/", ".turbo/", ".parcel-cache/", "/dist/", "/coverage/"},
+	},
+	{
+		Name:     "python",
+		Markers:  []string{"pyproject.toml", "setup.py", "setup.cfg", "requirements.txt", "Pipfile"},
+		Excludes: []string{"__pycache__/", "*.py[co]", ".venv/", ".pytest_cache/", ".mypy_cache/", ".ruff_cache/", ".tox/", "*.egg-info/", "/build/", "/dist/"},
+	},
+	{
+		Name:     "rust",
+		Markers:  []string{"Cargo.toml"},
+		Excludes: []string{"/target/"},
+	},
+	{
+		Name:     "go",
+		Markers:  []string{"go.mod"},
+		Excludes: []string{"*.test", "*.prof"},
+	},
+	{
+		Name:     "maven",
+		Markers:  []string{"pom.xml"},
+		Excludes: []string{"/target/"},
+	},
+	{
+		Name:     "gradle",
+		Markers:  []string{"build.gradle", "build.gradle.kts", "settings.gradle", "settings.gradle.kts"},
+		Excludes: []string{".gradle/", "/build/"},
+	},
+	{
+		Name:     "dotnet",
+		Markers:  []string{"*.sln", "*.csproj", "*.fsproj", "*.vbproj"},
+		Excludes: []string{"bin/", "obj/"},
+	},
+	{
+		Name:     "c",
+		Markers:  []string{"Makefile", "GNUmakefile", "CMakeLists.txt", "meson.build", "configure.ac"},
+		Excludes: []string{"*.o", "*.obj", "CMakeFiles/", "CMakeCache.txt", "/build/"},
+	},
+	{
+		Name:     "swift",
+		Markers:  []string{"Package.swift", "*.xcodeproj", "*.xcworkspace"},
+		Excludes: []string{".build/", "DerivedData/", "xcuserdata/"},
+	},
+	{
+		Name:     "elixir",
+		Markers:  []string{"mix.exs"},
+		Excludes: []string{"/_build/", "/deps/"},
+	},
+	{
+		Name:     "dart",
+		Markers:  []string{"pubspec.yaml"},
+		Excludes: []string{".dart_tool/", "/build/"},
+	},
+	{
+		Name:     "zig",
+		Markers:  []string{"build.zig"},
+		Excludes: []string{".zig-cache/", "zig-cache/", "zig-out/"},
+	},
+}
+
+// DetectSmartPresets returns the presets that apply to the source tree at
+// root: those without markers, and those with a marker among the names at
+// the top of root. Only the former apply when root cannot be listed.
+func DetectSmartPresets(root string) []SmartPreset {
+	var names []string
+
+	if entries, err := os.ReadDir(toExtendedPath(root)); err == nil {
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+	}
+
+	var presets []SmartPreset
+
+	for _, preset := range SmartPresets {
+		if len(preset.Markers) == 0 || hasMarker(names, preset.Markers) {
+			presets = append(presets, preset)
+		}
+	}
+
+	return presets
+}
+
+func hasMarker(names, markers []string) bool {
+	for _, name := range names {
+		for _, marker := range markers {
+			if matched, _ := path.Match(marker, name); matched {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// smartExcludes holds the exclusions of the smart presets detected at the
+// root of a source tree.
+type smartExcludes struct {
+	patterns   []ignorePattern
+	ignoreCase bool
+}
+
+// SmartPresets returns the presets the filter applies to the source tree at
+// root, or nil when smart exclusions are off.
+func (f *FileFilter) SmartPresets(root string) []SmartPreset {
+	if !f.smart {
+		return nil
+	}
+
+	return DetectSmartPresets(root)
+}
+
+// SmartPresets returns the presets the engine's filter applies to the source
+// tree at source, or nil when smart exclusions are off.
+func (e *SyncEngine) SmartPresets(source string) []SmartPreset {
+	return e.filter.SmartPresets(source)
+}
+
+// smartExcludesIn returns the exclusions of the presets detected at root, or
+// nil when smart exclusions are off.
+func (f *FileFilter) smartExcludesIn(root string) *smartExcludes {
+	presets := f.SmartPresets(root)
+	if presets == nil {
+		return nil
+	}
+
+	s := &smartExcludes{ignoreCase: f.ignoreCase}
+
+	for _, preset := range presets {
+		for _, exclude := range preset.Excludes {
+			if pattern, err := compileIgnorePattern(exclude, f.ignoreCase); err == nil {
+				s.patterns = append(s.patterns, pattern)
+			}
+		}
+	}
+
+	return s
+}
+
+// excluded reports whether the entry at relPath, or any directory above it,
+// is matched by an exclusion.
+func (s *smartExcludes) excluded(relPath string, isDir bool) bool {
+	slashPath := filepath.ToSlash(relPath)
+	if slashPath == "." {
+		return false
+	}
+
+	if s.ignoreCase {
+		slashPath = strings.ToLower(slashPath)
+	}
+
+	return matchesGlob(s.patterns, strings.Split(slashPath, "/"), isDir)
+}
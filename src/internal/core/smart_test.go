@@ -0,0 +1,127 @@
+package core
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDetectSmartPresets(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		files []string
+		want  []string
+	}{
+		{
+			name:  "no markers",
+			files: []string{"notes.txt", "src/package.json"},
+			want:  []string{"common"},
+		},
+		{
+			name:  "single marker",
+			files: []string{"Cargo.toml", "src/main.rs"},
+			want:  []string{"common", "rust"},
+		},
+		{
+			name:  "glob marker",
+			files: []string{"App.csproj"},
+			want:  []string{"common", "dotnet"},
+		},
+		{
+			name:  "several project types",
+			files: []string{"package.json", "pyproject.toml", "Makefile"},
+			want:  []string{"common", "node", "python", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			root := t.TempDir()
+
+			for _, file := range tt.files {
+				writeTreeFile(t, filepath.Join(root, file), "", time.Now())
+			}
+
+			var got []string
+			for _, preset := range DetectSmartPresets(root) {
+				got = append(got, preset.Name)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("DetectSmartPresets = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	missing := DetectSmartPresets(filepath.Join(t.TempDir(), "missing"))
+	if len(missing) != 1 || missing[0].Name != "common" {
+		t.Errorf("DetectSmartPresets of a missing root = %v, want only common", missing)
+	}
+}
+
+func TestSyncEngineSmartExclusions(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	sourceDir := filepath.Join(tempDir, "source")
+	destDir := filepath.Join(tempDir, "dest")
+
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	writeTreeFile(t, filepath.Join(sourceDir, "package.json"), "{}", modTime)
+	writeTreeFile(t, filepath.Join(sourceDir, "index.js"), "main", modTime)
+	writeTreeFile(t, filepath.Join(sourceDir, ".DS_Store"), "junk", modTime)
+	writeTreeFile(t, filepath.Join(sourceDir, ".git", "HEAD"), "ref", modTime)
+	writeTreeFile(t, filepath.Join(sourceDir, "node_modules", "pkg", "index.js"), "dep", modTime)
+	writeTreeFile(t, filepath.Join(sourceDir, "dist", "bundle.js"), "bundle", modTime)
+	writeTreeFile(t, filepath.Join(sourceDir, "src", "dist", "notes.txt"), "notes", modTime)
+	writeTreeFile(t, filepath.Join(sourceDir, "target", "debug.txt"), "kept", modTime)
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	filter := NewFileFilter()
+	filter.SetSmart(true)
+	engine.SetFilter(filter)
+
+	mirrorTree(t, engine, sourceDir, destDir)
+
+	// Only dist at the root is build output, and target is only excluded
+	// in Rust and Maven projects.
+	want := map[string]string{
+		"package.json":       "{}",
+		"index.js":           "main",
+		"src/":               "",
+		"src/dist/":          "",
+		"src/dist/notes.txt": "notes",
+		"target/":            "",
+		"target/debug.txt":   "kept",
+	}
+	if got := readTree(t, destDir); !maps.Equal(got, want) {
+		t.Errorf("destination = %v, want %v", got, want)
+	}
+
+	if stats := engine.GetStats(); stats.ExcludedByPattern == 0 {
+		t.Error("ExcludedByPattern = 0, want the smart exclusions counted")
+	}
+
+	// Without --smart, the same tree is mirrored in full.
+	engine.SetFilter(NewFileFilter())
+
+	if presets := engine.SmartPresets(sourceDir); presets != nil {
+		t.Errorf("SmartPresets = %v with smart exclusions off, want nil", presets)
+	}
+
+	mirrorTree(t, engine, sourceDir, destDir)
+
+	if _, err := os.Stat(filepath.Join(destDir, "node_modules", "pkg", "index.js")); err != nil {
+		t.Errorf("excluded file not copied with smart exclusions off: %v", err)
+	}
+}
@@ -0,0 +1,192 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshotVersion is bumped whenever the snapshot format changes, so stale
+// snapshots are ignored rather than misread.
+const snapshotVersion = 1
+
+// DestinationSnapshot is a persisted listing of a destination directory,
+// written after a successful sync so the next sync can skip rescanning it.
+type DestinationSnapshot struct {
+	Version      int                  `json:"version"`
+	Root         string               `json:"root"`
+	RootModTime  time.Time            `json:"rootModTime"`
+	ChecksumAlgo string               `json:"checksumAlgo"`
+	Files        map[string]*FileInfo `json:"files"` // keyed by path relative to Root
+}
+
+// DefaultSnapshotPath returns where the snapshot for destination is kept: a
+// file in the user cache directory named after the absolute destination path.
+func DefaultSnapshotPath(destination string) (string, error) {
+	absDest, err := filepath.Abs(destination)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", destination, err)
+	}
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+
+	sum := sha256.Sum256([]byte(absDest))
+
+	return filepath.Join(cacheDir, "relay", "snapshots", hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+// SetDestinationSnapshot persists the destination listing to path after each
+// successful sync and, unless rescan is set, uses it instead of scanning the
+// destination while the destination root's modification time is unchanged.
+// That check only notices entries added or removed directly under the root,
+// so changes made deeper in the destination by other tools go unseen until a
+// rescan. An empty path disables snapshots.
+func (e *SyncEngine) SetDestinationSnapshot(path string, rescan bool) {
+	e.snapshotPath = path
+	e.rescanDest = rescan
+}
+
+// snapshotLabel identifies the digests stored in a snapshot, which are only
+// reusable with the same checksum configuration.
+func (e *SyncEngine) snapshotLabel() string {
+	return e.scanner.checksumLabel() + e.scanner.sampleLabel() + "+" + e.scanner.secondaryAlgo
+}
+
+// loadSnapshot returns the destination listing from the snapshot, or false
+// when there is no usable snapshot for destination.
+func (e *SyncEngine) loadSnapshot(destination string) (map[string]*FileInfo, bool) {
+	if e.snapshotPath == "" || e.rescanDest {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(e.snapshotPath)
+	if err != nil {
+		return nil, false
+	}
+
+	var snapshot DestinationSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, false
+	}
+
+	absDest, err := filepath.Abs(destination)
+	if err != nil || snapshot.Version != snapshotVersion || snapshot.Root != absDest ||
+		snapshot.ChecksumAlgo != e.snapshotLabel() {
+		return nil, false
+	}
+
+	rootInfo, err := os.Stat(toExtendedPath(destination))
+	if err != nil || !rootInfo.ModTime().Equal(snapshot.RootModTime) {
+		return nil, false
+	}
+
+	for relPath, info := range snapshot.Files {
+		info.Path = filepath.Join(destination, relPath)
+	}
+
+	return snapshot.Files, true
+}
+
+// recordWritten notes that destPath now holds a copy of source, so the saved
+// snapshot reflects this sync without rescanning.
+func (e *SyncEngine) recordWritten(relPath, destPath string, source *FileInfo) {
+	if e.snapshotPath == "" {
+		return
+	}
+
+	written := *source
+	written.Path = destPath
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.destChanges[relPath] = &written
+}
+
+// recordRemoved notes that relPath was deleted from the destination.
+func (e *SyncEngine) recordRemoved(relPath string) {
+	if e.snapshotPath == "" {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.destChanges[relPath] = nil
+}
+
+// saveSnapshot applies the changes made during this sync to the destination
+// listing and atomically replaces the snapshot file with it.
+func (e *SyncEngine) saveSnapshot(destination string, destMap map[string]*FileInfo) error {
+	absDest, err := filepath.Abs(destination)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", destination, err)
+	}
+
+	rootInfo, err := os.Stat(toExtendedPath(destination))
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", destination, err)
+	}
+
+	files := make(map[string]*FileInfo, len(destMap)+len(e.destChanges))
+	for relPath, info := range destMap {
+		files[relPath] = info
+	}
+
+	for relPath, info := range e.destChanges {
+		if info == nil {
+			delete(files, relPath)
+			continue
+		}
+
+		files[relPath] = info
+	}
+
+	data, err := json.Marshal(DestinationSnapshot{
+		Version:      snapshotVersion,
+		Root:         absDest,
+		RootModTime:  rootInfo.ModTime(),
+		ChecksumAlgo: e.snapshotLabel(),
+		Files:        files,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(e.snapshotPath), 0o750); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(e.snapshotPath), filepath.Base(e.snapshotPath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary snapshot: %w", err)
+	}
+
+	tempPath := tempFile.Name()
+
+	if _, err := tempFile.Write(data); err != nil {
+		_ = tempFile.Close()
+		_ = os.Remove(tempPath)
+
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	if err := tempFile.Close(); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to close snapshot: %w", err)
+	}
+
+	if err := os.Rename(tempPath, e.snapshotPath); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to replace snapshot: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,603 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// Ways a snapshot shares unchanged files with the one before it.
+const (
+	SnapshotAuto     = "auto"     // reflink where the store supports it, else hardlink
+	SnapshotHardlink = "hardlink" // one file in every snapshot; cheapest, but shared
+	SnapshotReflink  = "reflink"  // independent files sharing their blocks
+)
+
+// snapshotNameLayout names snapshot directories after their creation time,
+// in local time, so that they sort chronologically.
+const snapshotNameLayout = "2006-01-02T15-04-05"
+
+// snapshotMetaDir is the directory of a snapshot store that holds a
+// description of each snapshot, beside the snapshots themselves.
+const snapshotMetaDir = ".relay-snapshots"
+
+// ErrSnapshotInsideSource is returned when a snapshot store is within the
+// source it snapshots, which would copy every snapshot into the next.
+var ErrSnapshotInsideSource = errors.New("snapshot store is inside the source")
+
+// ErrSnapshotExists is returned when a snapshot with the same name, taken in
+// the same second, is already in the store.
+var ErrSnapshotExists = errors.New("snapshot already exists")
+
+// Snapshot is a point-in-time copy of a source directory in a snapshot store.
+type Snapshot struct {
+	Name        string    `json:"name"`
+	Path        string    `json:"path"`
+	Created     time.Time `json:"created"`
+	Source      string    `json:"source,omitempty"`
+	Method      string    `json:"method,omitempty"` // how unchanged files were shared
+	Files       int64     `json:"files"`
+	Dirs        int64     `json:"dirs"`
+	Size        int64     `json:"size"`        // of all its files
+	Shared      int64     `json:"shared"`      // files shared with the previous snapshot
+	SharedBytes int64     `json:"sharedBytes"` // size of the shared files, which cost no space
+	Copied      int64     `json:"copied"`      // files copied from the source
+	CopiedBytes int64     `json:"copiedBytes"`
+	Unreadable  int       `json:"unreadable,omitempty"` // source entries that could not be read, and are left out
+}
+
+// SnapshotOptions configure CreateSnapshot.
+type SnapshotOptions struct {
+	Method string      // SnapshotAuto when empty
+	Filter *FileFilter // nil: every file
+	Time   time.Time   // the snapshot's creation time; now when zero
+}
+
+// RetentionPolicy says which snapshots PruneSnapshots keeps. The newest
+// snapshot of each of the last Daily days that have one is kept, and so on
+// for the other periods; a snapshot kept for any reason is kept. The newest
+// snapshot is always kept.
+type RetentionPolicy struct {
+	Last    int
+	Hourly  int
+	Daily   int
+	Weekly  int
+	Monthly int
+	Yearly  int
+}
+
+// Empty reports whether the policy keeps nothing but the newest snapshot.
+func (p RetentionPolicy) Empty() bool {
+	return p == RetentionPolicy{}
+}
+
+// CreateSnapshot copies source into a new snapshot directory in store, named
+// after its creation time. Files whose size, modification time and
+// permissions match the newest snapshot already in the store are shared with
+// it rather than copied, by hard link or reflink as opts.Method says, so they
+// cost no space; hard-linked files are one file in both snapshots, so a
+// snapshot must never be edited in place. A reflink or hard link that fails,
+// for instance on a file system without reflinks, falls back to a copy.
+//
+// The snapshot is written under a hidden name and renamed into place once
+// complete, so an interrupted snapshot is never listed; the next snapshot
+// removes it. Source entries that cannot be read are counted in Unreadable
+// and left out.
+func CreateSnapshot(ctx context.Context, source, store string, opts SnapshotOptions) (*Snapshot, error) {
+	created := opts.Time
+	if created.IsZero() {
+		created = time.Now()
+	}
+
+	source, err := filepath.Abs(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", source, err)
+	}
+
+	if info, err := os.Stat(toExtendedPath(source)); err != nil {
+		return nil, fmt.Errorf("failed to stat source: %w", err)
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("source %s is not a directory", source)
+	}
+
+	if err := checkStoreOutside(source, store); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(toExtendedPath(store), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot store: %w", err)
+	}
+
+	removePartialSnapshots(store)
+
+	snapshots, err := ListSnapshots(store)
+	if err != nil {
+		return nil, err
+	}
+
+	name := created.Format(snapshotNameLayout)
+	final := filepath.Join(store, name)
+
+	if _, err := os.Lstat(toExtendedPath(final)); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrSnapshotExists, final)
+	}
+
+	snapshot := &Snapshot{Name: name, Path: final, Created: created, Source: source}
+
+	var previous string
+	if len(snapshots) > 0 {
+		previous = snapshots[len(snapshots)-1].Path
+		snapshot.Method = resolveSnapshotMethod(opts.Method, store)
+	}
+
+	entries, unreadable, err := scanSnapshotSource(ctx, source, opts.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot.Unreadable = unreadable
+
+	partial := filepath.Join(store, "."+name+".partial")
+
+	if err := writeSnapshot(ctx, source, partial, previous, entries, snapshot); err != nil {
+		_ = removeSnapshotDir(partial)
+		return nil, err
+	}
+
+	if err := writeSnapshotMeta(store, snapshot); err != nil {
+		_ = removeSnapshotDir(partial)
+		return nil, err
+	}
+
+	if err := os.Rename(toExtendedPath(partial), toExtendedPath(final)); err != nil {
+		_ = removeSnapshotDir(partial)
+		_ = os.Remove(snapshotMetaPath(store, name))
+
+		return nil, fmt.Errorf("failed to complete snapshot: %w", err)
+	}
+
+	return snapshot, nil
+}
+
+// checkStoreOutside returns ErrSnapshotInsideSource when store is source or
+// within it.
+func checkStoreOutside(source, store string) error {
+	resolvedSource, err := resolvePath(source)
+	if err != nil {
+		return fmt.Errorf("failed to resolve source path: %w", err)
+	}
+
+	resolvedStore, err := resolvePath(store)
+	if err != nil {
+		return fmt.Errorf("failed to resolve snapshot store path: %w", err)
+	}
+
+	if resolvedStore == resolvedSource || strings.HasPrefix(resolvedStore, resolvedSource+string(filepath.Separator)) {
+		return fmt.Errorf("%w: %s", ErrSnapshotInsideSource, store)
+	}
+
+	return nil
+}
+
+// resolveSnapshotMethod turns SnapshotAuto into the method the store
+// supports.
+func resolveSnapshotMethod(method, store string) string {
+	if method != "" && method != SnapshotAuto {
+		return method
+	}
+
+	if support, err := ProbeCopySupport(store); err == nil && support.Reflink {
+		return SnapshotReflink
+	}
+
+	return SnapshotHardlink
+}
+
+// removePartialSnapshots deletes the snapshots in store that were never
+// completed.
+func removePartialSnapshots(store string) {
+	entries, err := os.ReadDir(toExtendedPath(store))
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() && strings.HasPrefix(name, ".") && strings.HasSuffix(name, ".partial") {
+			_ = removeSnapshotDir(filepath.Join(store, name))
+		}
+	}
+}
+
+// scanSnapshotSource lists source without reading any file, applying filter,
+// the ignore files in source and its smart exclusions when filter is set. The entries are sorted
+// by path, so each directory comes before what it holds.
+func scanSnapshotSource(ctx context.Context, source string, filter *FileFilter) ([]*FileInfo, int, error) {
+	var (
+		ignores    *ignoreFiles
+		smart      *smartExcludes
+		unreadable atomic.Int64
+	)
+
+	if filter != nil {
+		ignores = filter.ignoreFilesIn(source, func(string, error) { unreadable.Add(1) })
+		smart = filter.smartExcludesIn(source)
+	}
+
+	scanner := NewFileScanner(0)
+	scanner.SetSkipChecksums(true)
+
+	entries, err := scanner.ScanWithFilter(ctx, source, func(path string, info *FileInfo) bool {
+		if filter == nil {
+			return true
+		}
+
+		relPath, err := filepath.Rel(source, path)
+		if err != nil {
+			return true
+		}
+
+		return filter.Evaluate(relPath, info) == FilterInclude &&
+			(ignores == nil || !ignores.excluded(relPath, info.IsDir)) &&
+			(smart == nil || !smart.excluded(relPath, info.IsDir))
+	})
+
+	var incomplete *IncompleteScanError
+
+	switch {
+	case errors.As(err, &incomplete):
+		unreadable.Add(int64(len(incomplete.Failures)))
+	case err != nil:
+		return nil, 0, err
+	}
+
+	slices.SortFunc(entries, func(a, b *FileInfo) int { return strings.Compare(a.Path, b.Path) })
+
+	return entries, int(unreadable.Load()), nil
+}
+
+// writeSnapshot creates dir holding entries of source, sharing unchanged
+// files with the previous snapshot when there is one, and counts them in
+// snapshot.
+func writeSnapshot(ctx context.Context, source, dir, previous string, entries []*FileInfo, snapshot *Snapshot) error {
+	if err := os.Mkdir(toExtendedPath(dir), 0o755); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	copier := NewFileCopier(0, true)
+	sem := semaphore.NewWeighted(int64(runtime.GOMAXPROCS(0) * 2))
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		dirs     []*FileInfo
+	)
+
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	for _, entry := range entries {
+		relPath, err := filepath.Rel(source, entry.Path)
+		if err != nil || relPath == "." {
+			continue
+		}
+
+		target := filepath.Join(dir, relPath)
+		mode := fs.FileMode(entry.Mode)
+
+		switch {
+		case entry.IsDir:
+			// Written with owner access until its files are in place.
+			if err := os.Mkdir(toExtendedPath(target), 0o700); err != nil {
+				wg.Wait()
+				return fmt.Errorf("failed to create %s: %w", target, err)
+			}
+
+			dirs = append(dirs, entry)
+			snapshot.Dirs++
+
+			continue
+		case mode&fs.ModeSymlink != 0:
+			link, err := os.Readlink(toExtendedPath(entry.Path))
+			if err == nil {
+				err = os.Symlink(link, toExtendedPath(target))
+			}
+
+			if err != nil {
+				wg.Wait()
+				return fmt.Errorf("failed to copy symlink %s: %w", relPath, err)
+			}
+
+			continue
+		case !mode.IsRegular():
+			continue
+		}
+
+		if err := sem.Acquire(ctx, 1); err != nil {
+			wg.Wait()
+			return err
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			defer sem.Release(1)
+
+			shared, err := snapshotFile(ctx, copier, entry, target, previous, relPath, snapshot.Method)
+			if err != nil {
+				fail(err)
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			snapshot.Files++
+			snapshot.Size += entry.Size
+
+			if shared {
+				snapshot.Shared++
+				snapshot.SharedBytes += entry.Size
+			} else {
+				snapshot.Copied++
+				snapshot.CopiedBytes += entry.Size
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Deepest first, so setting a directory's time is not undone by changes
+	// to its children.
+	for _, entry := range slices.Backward(dirs) {
+		relPath, _ := filepath.Rel(source, entry.Path)
+		target := toExtendedPath(filepath.Join(dir, relPath))
+
+		if err := os.Chmod(target, fs.FileMode(entry.Mode).Perm()); err != nil {
+			return fmt.Errorf("failed to set permissions of %s: %w", relPath, err)
+		}
+
+		if err := os.Chtimes(target, entry.ModTime, entry.ModTime); err != nil {
+			return fmt.Errorf("failed to set times of %s: %w", relPath, err)
+		}
+	}
+
+	return nil
+}
+
+// snapshotFile writes target as a copy of the source file entry, or shares it
+// with the previous snapshot's copy by method when that is unchanged, and
+// reports whether it was shared.
+func snapshotFile(ctx context.Context, copier *FileCopier, entry *FileInfo, target, previous, relPath, method string) (bool, error) {
+	if previous != "" {
+		prior := filepath.Join(previous, relPath)
+
+		info, err := os.Lstat(toExtendedPath(prior))
+		if err == nil && info.Mode().IsRegular() && info.Size() == entry.Size &&
+			info.ModTime().Equal(entry.ModTime) && info.Mode() == fs.FileMode(entry.Mode) {
+			if shareSnapshotFile(prior, target, info, method) == nil {
+				return true, nil
+			}
+		}
+	}
+
+	if err := copier.CopyFile(ctx, entry.Path, target); err != nil {
+		return false, fmt.Errorf("failed to copy %s: %w", relPath, err)
+	}
+
+	return false, nil
+}
+
+// shareSnapshotFile makes target share prior's data by method.
+func shareSnapshotFile(prior, target string, info os.FileInfo, method string) error {
+	if method == SnapshotHardlink {
+		return os.Link(toExtendedPath(prior), toExtendedPath(target))
+	}
+
+	if err := reflinkFile(prior, target); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(toExtendedPath(target), info.Mode()); err != nil {
+		_ = os.Remove(toExtendedPath(target))
+		return err
+	}
+
+	if err := os.Chtimes(toExtendedPath(target), info.ModTime(), info.ModTime()); err != nil {
+		_ = os.Remove(toExtendedPath(target))
+		return err
+	}
+
+	return nil
+}
+
+// snapshotMetaPath returns where the description of the snapshot name in
+// store is kept.
+func snapshotMetaPath(store, name string) string {
+	return filepath.Join(store, snapshotMetaDir, name+".json")
+}
+
+// writeSnapshotMeta saves the description of snapshot in store.
+func writeSnapshotMeta(store string, snapshot *Snapshot) error {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(store, snapshotMetaDir), 0o755); err != nil {
+		return fmt.Errorf("failed to create snapshot metadata directory: %w", err)
+	}
+
+	if err := os.WriteFile(snapshotMetaPath(store, snapshot.Name), data, 0o644); err != nil {
+		return fmt.Errorf("failed to write snapshot metadata: %w", err)
+	}
+
+	return nil
+}
+
+// ListSnapshots returns the snapshots in store, oldest first. Directories
+// not named like a snapshot are ignored. A snapshot without a description,
+// such as one copied in from elsewhere, is listed with only its name, path
+// and creation time. A store that does not exist holds no snapshots.
+func ListSnapshots(store string) ([]Snapshot, error) {
+	entries, err := os.ReadDir(toExtendedPath(store))
+	if errors.Is(err, fs.ErrNotExist) {
+		return []Snapshot{}, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot store: %w", err)
+	}
+
+	snapshots := []Snapshot{}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		created, err := time.ParseInLocation(snapshotNameLayout, entry.Name(), time.Local)
+		if err != nil {
+			continue
+		}
+
+		snapshot := Snapshot{Name: entry.Name(), Created: created}
+
+		if data, err := os.ReadFile(snapshotMetaPath(store, entry.Name())); err == nil {
+			_ = json.Unmarshal(data, &snapshot)
+		}
+
+		snapshot.Name = entry.Name()
+		snapshot.Path = filepath.Join(store, entry.Name())
+		snapshots = append(snapshots, snapshot)
+	}
+
+	slices.SortFunc(snapshots, func(a, b Snapshot) int { return strings.Compare(a.Name, b.Name) })
+
+	return snapshots, nil
+}
+
+// SelectSnapshots splits snapshots, which are oldest first, into those
+// policy keeps and those it does not, both oldest first.
+func SelectSnapshots(snapshots []Snapshot, policy RetentionPolicy) (keep, remove []Snapshot) {
+	kept := make(map[string]bool)
+
+	if len(snapshots) > 0 {
+		kept[snapshots[len(snapshots)-1].Name] = true
+	}
+
+	buckets := []struct {
+		count  int
+		period func(time.Time) string
+	}{
+		{policy.Last, func(t time.Time) string { return t.Format(time.RFC3339Nano) }},
+		{policy.Hourly, func(t time.Time) string { return t.Format("2006-01-02T15") }},
+		{policy.Daily, func(t time.Time) string { return t.Format(time.DateOnly) }},
+		{policy.Weekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{policy.Monthly, func(t time.Time) string { return t.Format("2006-01") }},
+		{policy.Yearly, func(t time.Time) string { return t.Format("2006") }},
+	}
+
+	for _, bucket := range buckets {
+		seen := make(map[string]bool)
+
+		// Newest first, so each period keeps its newest snapshot.
+		for _, snapshot := range slices.Backward(snapshots) {
+			if len(seen) >= bucket.count {
+				break
+			}
+
+			period := bucket.period(snapshot.Created)
+			if !seen[period] {
+				seen[period] = true
+				kept[snapshot.Name] = true
+			}
+		}
+	}
+
+	for _, snapshot := range snapshots {
+		if kept[snapshot.Name] {
+			keep = append(keep, snapshot)
+		} else {
+			remove = append(remove, snapshot)
+		}
+	}
+
+	return keep, remove
+}
+
+// PruneSnapshots deletes the snapshots in store that policy does not keep,
+// or only returns them when dryRun is set. Snapshots are deleted oldest
+// first; the first that cannot be deleted stops the pruning, and the
+// snapshots deleted so far are returned with the error.
+func PruneSnapshots(store string, policy RetentionPolicy, dryRun bool) ([]Snapshot, error) {
+	snapshots, err := ListSnapshots(store)
+	if err != nil {
+		return nil, err
+	}
+
+	_, remove := SelectSnapshots(snapshots, policy)
+	if dryRun {
+		return remove, nil
+	}
+
+	removed := make([]Snapshot, 0, len(remove))
+
+	for _, snapshot := range remove {
+		if err := removeSnapshotDir(snapshot.Path); err != nil {
+			return removed, fmt.Errorf("failed to delete snapshot %s: %w", snapshot.Name, err)
+		}
+
+		_ = os.Remove(snapshotMetaPath(store, snapshot.Name))
+		removed = append(removed, snapshot)
+	}
+
+	return removed, nil
+}
+
+// removeSnapshotDir deletes a snapshot, first making its directories
+// writable, since a snapshot keeps the source's permissions.
+func removeSnapshotDir(path string) error {
+	_ = filepath.WalkDir(toExtendedPath(path), func(dir string, entry fs.DirEntry, err error) error {
+		if err == nil && entry.IsDir() {
+			if info, err := entry.Info(); err == nil && info.Mode().Perm()&0o700 != 0o700 {
+				_ = os.Chmod(dir, info.Mode().Perm()|0o700)
+			}
+		}
+
+		return nil
+	})
+
+	return os.RemoveAll(toExtendedPath(path))
+}
@@ -0,0 +1,209 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCreateSnapshot(t *testing.T) {
+	t.Parallel()
+	source := t.TempDir()
+	store := filepath.Join(t.TempDir(), "snapshots")
+
+	writeFile := func(relPath, content string) {
+		path := filepath.Join(source, filepath.FromSlash(relPath))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	writeFile("unchanged.txt", "same in both")
+	writeFile("dir/changed.txt", "first version")
+	writeFile("removed.txt", "only in the first")
+
+	first := time.Date(2026, 3, 1, 9, 0, 0, 0, time.Local)
+
+	snapshot, err := CreateSnapshot(context.Background(), source, store, SnapshotOptions{Method: SnapshotHardlink, Time: first})
+	if err != nil {
+		t.Fatalf("CreateSnapshot failed: %v", err)
+	}
+
+	if snapshot.Name != "2026-03-01T09-00-00" || snapshot.Files != 3 || snapshot.Dirs != 1 || snapshot.Copied != 3 {
+		t.Errorf("first snapshot = %+v, want 3 files and 1 directory copied", snapshot)
+	}
+
+	writeFile("dir/changed.txt", "second version, longer")
+	writeFile("added.txt", "new")
+
+	if err := os.Remove(filepath.Join(source, "removed.txt")); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+
+	second, err := CreateSnapshot(context.Background(), source, store, SnapshotOptions{Method: SnapshotHardlink, Time: first.Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("CreateSnapshot failed: %v", err)
+	}
+
+	if second.Shared != 1 || second.Copied != 2 || second.Method != SnapshotHardlink {
+		t.Errorf("second snapshot = %+v, want 1 file shared and 2 copied", second)
+	}
+
+	sameFile := func(relPath string) bool {
+		a, errA := os.Stat(filepath.Join(snapshot.Path, relPath))
+		b, errB := os.Stat(filepath.Join(second.Path, relPath))
+
+		return errA == nil && errB == nil && os.SameFile(a, b)
+	}
+
+	if !sameFile("unchanged.txt") {
+		t.Error("unchanged.txt is not shared between the snapshots")
+	}
+
+	if sameFile(filepath.Join("dir", "changed.txt")) {
+		t.Error("changed.txt is shared between the snapshots")
+	}
+
+	if data, err := os.ReadFile(filepath.Join(snapshot.Path, "dir", "changed.txt")); err != nil || string(data) != "first version" {
+		t.Errorf("first snapshot holds %q (%v), want the first version", data, err)
+	}
+
+	if _, err := os.Stat(filepath.Join(second.Path, "removed.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("removed.txt is in the second snapshot (%v)", err)
+	}
+
+	snapshots, err := ListSnapshots(store)
+	if err != nil {
+		t.Fatalf("ListSnapshots failed: %v", err)
+	}
+
+	if len(snapshots) != 2 || snapshots[0].Name != snapshot.Name || snapshots[1].Shared != 1 {
+		t.Errorf("ListSnapshots = %+v, want both snapshots, oldest first", snapshots)
+	}
+
+	if _, err := CreateSnapshot(context.Background(), source, store, SnapshotOptions{Time: first}); !errors.Is(err, ErrSnapshotExists) {
+		t.Errorf("same time again: err = %v, want ErrSnapshotExists", err)
+	}
+
+	if _, err := CreateSnapshot(context.Background(), source, filepath.Join(source, "snapshots"), SnapshotOptions{}); !errors.Is(err, ErrSnapshotInsideSource) {
+		t.Errorf("store inside source: err = %v, want ErrSnapshotInsideSource", err)
+	}
+}
+
+func TestSelectSnapshots(t *testing.T) {
+	t.Parallel()
+
+	at := func(value string) Snapshot {
+		created, err := time.ParseInLocation(snapshotNameLayout, value, time.Local)
+		if err != nil {
+			t.Fatalf("bad time %q: %v", value, err)
+		}
+
+		return Snapshot{Name: value, Created: created}
+	}
+
+	snapshots := []Snapshot{
+		at("2026-01-15T10-00-00"),
+		at("2026-02-20T10-00-00"),
+		at("2026-03-01T08-00-00"),
+		at("2026-03-01T20-00-00"),
+		at("2026-03-02T08-00-00"),
+		at("2026-03-03T08-00-00"),
+	}
+
+	tests := []struct {
+		name   string
+		policy RetentionPolicy
+		want   []string
+	}{
+		{"empty keeps the newest", RetentionPolicy{}, []string{"2026-03-03T08-00-00"}},
+		{"last", RetentionPolicy{Last: 2}, []string{"2026-03-02T08-00-00", "2026-03-03T08-00-00"}},
+		{
+			"daily keeps each day's newest", RetentionPolicy{Daily: 3},
+			[]string{"2026-03-01T20-00-00", "2026-03-02T08-00-00", "2026-03-03T08-00-00"},
+		},
+		{
+			"monthly", RetentionPolicy{Monthly: 3},
+			[]string{"2026-01-15T10-00-00", "2026-02-20T10-00-00", "2026-03-03T08-00-00"},
+		},
+		{
+			"combined", RetentionPolicy{Last: 1, Monthly: 2},
+			[]string{"2026-02-20T10-00-00", "2026-03-03T08-00-00"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			keep, remove := SelectSnapshots(snapshots, tt.policy)
+
+			var names []string
+			for _, snapshot := range keep {
+				names = append(names, snapshot.Name)
+			}
+
+			if !slices.Equal(names, tt.want) {
+				t.Errorf("kept %v, want %v", names, tt.want)
+			}
+
+			if len(keep)+len(remove) != len(snapshots) {
+				t.Errorf("kept %d and removed %d of %d snapshots", len(keep), len(remove), len(snapshots))
+			}
+		})
+	}
+}
+
+func TestPruneSnapshots(t *testing.T) {
+	t.Parallel()
+	source := t.TempDir()
+	store := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(source, "file.txt"), []byte("data"), 0o644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	// A read-only directory must not stop a snapshot from being deleted.
+	if err := os.Mkdir(filepath.Join(source, "locked"), 0o555); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.Local)
+
+	for i := range 3 {
+		if _, err := CreateSnapshot(context.Background(), source, store, SnapshotOptions{Time: start.Add(time.Duration(i) * time.Hour)}); err != nil {
+			t.Fatalf("CreateSnapshot failed: %v", err)
+		}
+	}
+
+	removed, err := PruneSnapshots(store, RetentionPolicy{Last: 1}, true)
+	if err != nil || len(removed) != 2 {
+		t.Fatalf("dry run: removed %d (%v), want 2", len(removed), err)
+	}
+
+	if snapshots, _ := ListSnapshots(store); len(snapshots) != 3 {
+		t.Fatalf("dry run deleted snapshots: %d left", len(snapshots))
+	}
+
+	removed, err = PruneSnapshots(store, RetentionPolicy{Last: 1}, false)
+	if err != nil || len(removed) != 2 {
+		t.Fatalf("removed %d (%v), want 2", len(removed), err)
+	}
+
+	snapshots, err := ListSnapshots(store)
+	if err != nil || len(snapshots) != 1 || snapshots[0].Name != "2026-03-01T11-00-00" {
+		t.Errorf("ListSnapshots = %+v (%v), want only the newest", snapshots, err)
+	}
+
+	if _, err := os.Stat(snapshotMetaPath(store, "2026-03-01T09-00-00")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("metadata of a pruned snapshot is left (%v)", err)
+	}
+}
@@ -0,0 +1,153 @@
+package core
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrRunInProgress is returned when a sync or retry is started on an engine
+// whose previous run has not finished.
+var ErrRunInProgress = errors.New("a sync is already running on this engine")
+
+// The int64 counters in SyncStats and Progress are updated concurrently by
+// sync workers and must only be accessed through sync/atomic. The remaining
+// fields (times, flags, derived rates and the current file) are only written
+// under the engine's mutex. GetStats and GetProgress combine both into a
+// consistent snapshot; nothing outside the engine reads the live structs.
+
+// load returns a copy of s with every counter read atomically. The caller
+// must hold the engine's mutex for the non-counter fields.
+func (s *SyncStats) load() SyncStats {
+	return SyncStats{
+		FilesScanned:      atomic.LoadInt64(&s.FilesScanned),
+		FilesChanged:      atomic.LoadInt64(&s.FilesChanged),
+		FilesCreated:      atomic.LoadInt64(&s.FilesCreated),
+		FilesModified:     atomic.LoadInt64(&s.FilesModified),
+		FilesDeleted:      atomic.LoadInt64(&s.FilesDeleted),
+		BytesDeleted:      atomic.LoadInt64(&s.BytesDeleted),
+		NetBytesChange:    atomic.LoadInt64(&s.NetBytesChange),
+		BytesTransferred:  atomic.LoadInt64(&s.BytesTransferred),
+		ConflictsFound:    atomic.LoadInt64(&s.ConflictsFound),
+		ConflictsResolved: atomic.LoadInt64(&s.ConflictsResolved),
+		ErrorsEncountered: atomic.LoadInt64(&s.ErrorsEncountered),
+		ExcludedBySize:    atomic.LoadInt64(&s.ExcludedBySize),
+		ExcludedByPattern: atomic.LoadInt64(&s.ExcludedByPattern),
+		ExcludedByTime:    atomic.LoadInt64(&s.ExcludedByTime),
+		FilesVanished:     atomic.LoadInt64(&s.FilesVanished),
+		FilesQuarantined:  atomic.LoadInt64(&s.FilesQuarantined),
+		FilesDeferred:     atomic.LoadInt64(&s.FilesDeferred),
+		MetadataUpdated:   atomic.LoadInt64(&s.MetadataUpdated),
+		DirsPruned:        atomic.LoadInt64(&s.DirsPruned),
+		DestNewerKept:     atomic.LoadInt64(&s.DestNewerKept),
+		DirsMoved:         atomic.LoadInt64(&s.DirsMoved),
+		FilesReverified:   atomic.LoadInt64(&s.FilesReverified),
+		VerifyMismatches:  atomic.LoadInt64(&s.VerifyMismatches),
+		DryRun:            s.DryRun,
+		StartTime:         s.StartTime,
+		EndTime:           s.EndTime,
+		Duration:          s.Duration,
+	}
+}
+
+// add adds the counters of other to s. Both must be snapshots from load.
+func (s *SyncStats) add(other SyncStats) {
+	s.FilesScanned += other.FilesScanned
+	s.FilesChanged += other.FilesChanged
+	s.FilesCreated += other.FilesCreated
+	s.FilesModified += other.FilesModified
+	s.FilesDeleted += other.FilesDeleted
+	s.BytesDeleted += other.BytesDeleted
+	s.NetBytesChange += other.NetBytesChange
+	s.BytesTransferred += other.BytesTransferred
+	s.ConflictsFound += other.ConflictsFound
+	s.ConflictsResolved += other.ConflictsResolved
+	s.ErrorsEncountered += other.ErrorsEncountered
+	s.ExcludedBySize += other.ExcludedBySize
+	s.ExcludedByPattern += other.ExcludedByPattern
+	s.ExcludedByTime += other.ExcludedByTime
+	s.FilesVanished += other.FilesVanished
+	s.FilesQuarantined += other.FilesQuarantined
+	s.FilesDeferred += other.FilesDeferred
+	s.MetadataUpdated += other.MetadataUpdated
+	s.DirsPruned += other.DirsPruned
+	s.DestNewerKept += other.DestNewerKept
+	s.DirsMoved += other.DirsMoved
+	s.FilesReverified += other.FilesReverified
+	s.VerifyMismatches += other.VerifyMismatches
+}
+
+// loadStats returns a snapshot of the run's statistics. A fan-out mirror
+// counts each destination separately; their counters are added to the
+// source-side ones. The caller must hold the engine's mutex.
+func (e *SyncEngine) loadStats() SyncStats {
+	stats := e.stats.load()
+
+	for _, target := range e.fanOut {
+		stats.add(target.stats.load())
+	}
+
+	return stats
+}
+
+// load returns a copy of p with every counter read atomically. The caller
+// must hold the engine's mutex for the non-counter fields.
+func (p *Progress) load() Progress {
+	return Progress{
+		Current:     atomic.LoadInt64(&p.Current),
+		Total:       atomic.LoadInt64(&p.Total),
+		Percentage:  p.Percentage,
+		Speed:       p.Speed,
+		ETA:         p.ETA,
+		CurrentFile: p.CurrentFile,
+		Scanned:     atomic.LoadInt64(&p.Scanned),
+	}
+}
+
+// startRun claims the engine for a new run, resets the statistics and records
+// when the run began. Runs never overlap, so the reset cannot race with
+// workers of an earlier run; the caller must call endRun when done.
+func (e *SyncEngine) startRun(dryRun bool) error {
+	if !e.running.CompareAndSwap(false, true) {
+		return ErrRunInProgress
+	}
+
+	e.resetStats()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.stats.StartTime = time.Now()
+	e.stats.DryRun = dryRun
+
+	e.events.Send(Event{Type: EventScanStarted, Time: e.stats.StartTime, DryRun: dryRun})
+	e.logger.Info("run started", "dry_run", dryRun)
+
+	return nil
+}
+
+// endRun releases the engine for the next run.
+func (e *SyncEngine) endRun() {
+	stats := e.GetStats()
+
+	e.mu.RLock()
+	logger := e.logger
+	e.mu.RUnlock()
+
+	logger.Info("run finished", "dry_run", stats.DryRun, "scanned", stats.FilesScanned, "created", stats.FilesCreated,
+		"modified", stats.FilesModified, "deleted", stats.FilesDeleted, "bytes", stats.BytesTransferred,
+		"errors", stats.ErrorsEncountered, "duration", stats.Duration)
+
+	e.emit(Event{Type: EventCompleted, Stats: stats})
+	e.running.Store(false)
+}
+
+// finishRun records when the run ended and returns the final statistics.
+func (e *SyncEngine) finishRun() *SyncStats {
+	e.mu.Lock()
+	e.stats.EndTime = time.Now()
+	e.stats.Duration = e.stats.EndTime.Sub(e.stats.StartTime)
+	e.mu.Unlock()
+
+	return e.GetStats()
+}
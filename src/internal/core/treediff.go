@@ -0,0 +1,171 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+)
+
+// DiffKind says how a path differs between the two trees compared by
+// DiffTrees.
+type DiffKind string
+
+// Kinds of difference between two trees.
+const (
+	DiffOnlyInA DiffKind = "only-in-a"
+	DiffOnlyInB DiffKind = "only-in-b"
+	DiffContent DiffKind = "content" // files in both trees whose content differs
+	DiffType    DiffKind = "type"    // a file in one tree and a directory in the other
+)
+
+// TreeDifference is one path that differs between two trees.
+type TreeDifference struct {
+	Path string    `json:"path"` // relative to both roots
+	Kind DiffKind  `json:"kind"`
+	A    *FileInfo `json:"a,omitempty"` // nil when the path is only in B
+	B    *FileInfo `json:"b,omitempty"` // nil when the path is only in A
+}
+
+// TreeDiff is the result of DiffTrees.
+type TreeDiff struct {
+	A           string           `json:"a"`
+	B           string           `json:"b"`
+	Checksums   bool             `json:"checksums"` // files were compared by content rather than size and modification time
+	Identical   int64            `json:"identical"` // files in both trees that match
+	Differences []TreeDifference `json:"differences"`
+}
+
+// DiffTrees scans a and b and lists the paths that differ between them,
+// sorted, without changing either. The engine's filters apply to both trees.
+// With opts.ChecksumVerify files are compared by size and checksum;
+// otherwise they are compared by size and modification time, within
+// opts.ModifyWindow, and are never read. A directory only in one tree is
+// listed without its contents.
+//
+// Entries that cannot be read are recorded as errors and left out of the
+// comparison, so they may show up as only in the other tree.
+func (e *SyncEngine) DiffTrees(ctx context.Context, a, b string) (*TreeDiff, error) {
+	opts := e.options
+
+	if err := e.startRun(true); err != nil {
+		return nil, err
+	}
+
+	defer e.endRun()
+
+	e.scanner.SetSkipChecksums(!opts.ChecksumVerify)
+	defer e.scanner.SetSkipChecksums(false)
+
+	aFiles, err := e.scanDiffSide(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+
+	bFiles, err := e.scanDiffSide(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &TreeDiff{A: a, B: b, Checksums: opts.ChecksumVerify, Differences: []TreeDifference{}}
+
+	paths := make([]string, 0, len(aFiles)+len(bFiles))
+
+	for relPath := range aFiles {
+		paths = append(paths, relPath)
+	}
+
+	for relPath := range bFiles {
+		if _, ok := aFiles[relPath]; !ok {
+			paths = append(paths, relPath)
+		}
+	}
+
+	slices.Sort(paths)
+
+	// Directories listed as only in one tree, or as a type change; their
+	// contents are not listed separately.
+	listed := make(map[string]bool)
+
+	for _, relPath := range paths {
+		if withinListed(relPath, listed) {
+			continue
+		}
+
+		aFile, bFile := aFiles[relPath], bFiles[relPath]
+
+		var kind DiffKind
+
+		switch {
+		case bFile == nil:
+			kind = DiffOnlyInA
+		case aFile == nil:
+			kind = DiffOnlyInB
+		case aFile.IsDir != bFile.IsDir:
+			kind = DiffType
+		case aFile.IsDir:
+			continue
+		case filesDiffer(aFile, bFile, opts):
+			kind = DiffContent
+		default:
+			diff.Identical++
+			continue
+		}
+
+		if (kind == DiffOnlyInA && aFile.IsDir) || (kind == DiffOnlyInB && bFile.IsDir) || kind == DiffType {
+			listed[relPath] = true
+		}
+
+		diff.Differences = append(diff.Differences, TreeDifference{Path: relPath, Kind: kind, A: aFile, B: bFile})
+	}
+
+	e.finishRun()
+
+	return diff, ctx.Err()
+}
+
+// withinListed reports whether relPath is inside one of the listed
+// directories.
+func withinListed(relPath string, listed map[string]bool) bool {
+	for dir := filepath.Dir(relPath); dir != "."; dir = filepath.Dir(dir) {
+		if listed[dir] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// scanDiffSide lists root, filtered, keyed by relative path. Unreadable
+// entries are recorded as errors; a missing root is an error.
+func (e *SyncEngine) scanDiffSide(ctx context.Context, root string) (map[string]*FileInfo, error) {
+	files, err := e.scanner.ScanWithFilter(ctx, root, e.sourceFilter(root))
+	if err != nil && !e.recordIncompleteScan(err) {
+		return nil, fmt.Errorf("failed to scan %s: %w", root, err)
+	}
+
+	byPath := make(map[string]*FileInfo, len(files))
+
+	for _, file := range files {
+		relPath, err := filepath.Rel(root, file.Path)
+		if err == nil && relPath != "." {
+			byPath[relPath] = file
+		}
+	}
+
+	return byPath, nil
+}
+
+// filesDiffer reports whether two files differ: by size and checksum when
+// opts.ChecksumVerify is set, and by size and modification time otherwise.
+func filesDiffer(a, b *FileInfo, opts SyncOptions) bool {
+	if a.Size != b.Size {
+		return true
+	}
+
+	if opts.ChecksumVerify {
+		return checksumsDiffer(a, b)
+	}
+
+	return a.ModTime.Sub(b.ModTime).Abs() > opts.ModifyWindow
+}
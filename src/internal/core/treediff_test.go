@@ -0,0 +1,91 @@
+package core
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSyncEngineDiffTrees(t *testing.T) {
+	t.Parallel()
+
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	tests := []struct {
+		name      string
+		checksum  bool
+		want      []string // kind:path, sorted by path
+		identical int64
+	}{
+		{
+			name:      "by size and modification time",
+			want:      []string{"only-in-a:gone", "only-in-b:new.txt", "content:size", "type:swapped", "content:touched"},
+			identical: 3,
+		},
+		{
+			name:      "by checksum",
+			checksum:  true,
+			want:      []string{"content:edited", "only-in-a:gone", "only-in-b:new.txt", "content:size", "type:swapped"},
+			identical: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tempDir := t.TempDir()
+
+			a := filepath.Join(tempDir, "a")
+			b := filepath.Join(tempDir, "b")
+
+			for _, root := range []string{a, b} {
+				writeTreeFile(t, filepath.Join(root, "same"), "same", modTime)
+				writeTreeFile(t, filepath.Join(root, "docs", "same"), "same", modTime)
+			}
+
+			writeTreeFile(t, filepath.Join(a, "size"), "short", modTime)
+			writeTreeFile(t, filepath.Join(b, "size"), "longer", modTime)
+			writeTreeFile(t, filepath.Join(a, "touched"), "same", modTime)
+			writeTreeFile(t, filepath.Join(b, "touched"), "same", modTime.Add(time.Minute))
+			writeTreeFile(t, filepath.Join(a, "edited"), "alpha", modTime)
+			writeTreeFile(t, filepath.Join(b, "edited"), "bravo", modTime)
+			writeTreeFile(t, filepath.Join(a, "gone", "nested", "file"), "gone", modTime)
+			writeTreeFile(t, filepath.Join(b, "new.txt"), "new", modTime)
+			writeTreeFile(t, filepath.Join(a, "swapped"), "file", modTime)
+			writeTreeFile(t, filepath.Join(b, "swapped", "file"), "file", modTime)
+
+			engine, err := NewSyncEngine()
+			if err != nil {
+				t.Fatalf("NewSyncEngine failed: %v", err)
+			}
+
+			opts := engine.Options()
+			opts.ChecksumVerify = tt.checksum
+			engine.SetOptions(opts)
+
+			diff, err := engine.DiffTrees(context.Background(), a, b)
+			if err != nil {
+				t.Fatalf("DiffTrees failed: %v", err)
+			}
+
+			var got []string
+			for _, difference := range diff.Differences {
+				got = append(got, string(difference.Kind)+":"+difference.Path)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("differences = %v, want %v", got, tt.want)
+			}
+
+			if diff.Identical != tt.identical {
+				t.Errorf("Identical = %d, want %d", diff.Identical, tt.identical)
+			}
+
+			if tree := readTree(t, a); tree["size"] != "short" || tree["gone/nested/file"] != "gone" {
+				t.Errorf("DiffTrees changed a: %v", tree)
+			}
+		})
+	}
+}
@@ -0,0 +1,653 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// twoWayStateVersion is bumped whenever the two-way state format changes, so
+// stale state is ignored rather than misread.
+const twoWayStateVersion = 1
+
+// ErrTypeChanged is recorded for a path that is a file on one side of a
+// two-way sync and a directory on the other. Such paths are left alone.
+var ErrTypeChanged = errors.New("path is a file on one side and a directory on the other")
+
+// twoWayState is the listing of both sides of a two-way sync as the last run
+// left them. Comparing each side with it tells a file deleted on one side
+// from one created on the other.
+type twoWayState struct {
+	Version int                    `json:"version"`
+	Local   string                 `json:"local"`
+	Remote  string                 `json:"remote"`
+	Files   map[string]twoWayEntry `json:"files"` // keyed by relative path
+}
+
+// twoWayEntry is one path of a twoWayState, as it was on each side.
+type twoWayEntry struct {
+	Local  *FileInfo `json:"local"`
+	Remote *FileInfo `json:"remote"`
+}
+
+// twoWayChange carries one path from one side of a two-way sync to the
+// other.
+type twoWayChange struct {
+	relPath  string
+	from     *FileInfo // nil when the path was deleted on the side it comes from
+	to       *FileInfo // the other side's entry, nil when it has none
+	fromRoot string
+	toRoot   string
+	toRemote bool
+	base     twoWayEntry // kept when the change is not made
+	synced   bool        // base is from an earlier run
+}
+
+// DefaultTwoWayStatePath returns where the state of two-way syncs between
+// local and remote is kept: a file in the user cache directory named after
+// both absolute paths, in order.
+func DefaultTwoWayStatePath(local, remote string) (string, error) {
+	absLocal, err := filepath.Abs(local)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", local, err)
+	}
+
+	absRemote, err := filepath.Abs(remote)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", remote, err)
+	}
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+
+	sum := sha256.Sum256([]byte(absLocal + "\x00" + absRemote))
+
+	return filepath.Join(cacheDir, "relay", "sync-state", hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+// SetTwoWayState keeps the state of two-way syncs in path, so SyncTwoWay can
+// carry deletions across. Without it every run is treated as the first:
+// files missing on one side are copied back from the other, and nothing is
+// ever deleted. An empty path disables the state.
+func (e *SyncEngine) SetTwoWayState(path string) {
+	e.syncState = path
+}
+
+// SyncTwoWay synchronizes local and remote in both directions with the
+// engine's options. A path changed on one side since the last run is copied
+// to, or deleted from, the other. A file changed on both sides is a conflict
+// for the conflict resolver, which picks local as the source and remote as
+// the destination; a file deleted on one side and changed on the other is
+// copied back. With backups enabled in the conflict settings, every file is
+// backed up before it is overwritten.
+func (e *SyncEngine) SyncTwoWay(ctx context.Context, local, remote string) error {
+	opts := e.options
+
+	if err := e.startRun(opts.DryRun); err != nil {
+		return err
+	}
+
+	defer e.endRun()
+
+	e.applyCopyOptions(opts)
+
+	if err := checkDistinctPaths(local, remote); err != nil {
+		return err
+	}
+
+	state := e.loadTwoWayState(local, remote)
+	synced := len(state.Files) > 0
+
+	localFiles, localComplete, err := e.scanTwoWaySide(ctx, local, synced)
+	if err != nil {
+		return err
+	}
+
+	remoteFiles, remoteComplete, err := e.scanTwoWaySide(ctx, remote, synced)
+	if err != nil {
+		return err
+	}
+
+	changes, next, err := e.planTwoWay(ctx, local, remote, localFiles, remoteFiles, state,
+		localComplete && remoteComplete, opts)
+	if err != nil {
+		return err
+	}
+
+	applied := e.applyTwoWay(ctx, changes, opts)
+
+	for i, change := range changes {
+		if entry, ok := applied[i]; ok {
+			next[change.relPath] = entry
+		}
+	}
+
+	if e.syncState != "" && !opts.DryRun {
+		if err := e.saveTwoWayState(local, remote, next); err != nil {
+			e.errorHandler.AddError(ClassifySyncError("sync-state", e.syncState, err))
+			atomic.AddInt64(&e.stats.ErrorsEncountered, 1)
+		}
+	}
+
+	e.finishRun()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return e.guard.runError()
+}
+
+// scanTwoWaySide lists root keyed by relative path, reporting whether the
+// scan was complete. A missing root is empty on the first run, but an error
+// once it has been synced, since it is more likely unmounted than emptied.
+func (e *SyncEngine) scanTwoWaySide(ctx context.Context, root string, synced bool) (map[string]*FileInfo, bool, error) {
+	complete := true
+
+	files, err := e.scanner.ScanWithFilter(ctx, root, e.sourceFilter(root))
+	if err != nil {
+		switch {
+		case errors.Is(err, fs.ErrNotExist) && !synced:
+			files = nil
+		case errors.Is(err, fs.ErrNotExist):
+			return nil, false, fmt.Errorf("%s is missing but was synced before: %w", root, err)
+		case e.recordIncompleteScan(err):
+			complete = false
+		default:
+			return nil, false, fmt.Errorf("failed to scan %s: %w", root, err)
+		}
+	}
+
+	byPath := make(map[string]*FileInfo, len(files))
+
+	for _, file := range files {
+		relPath, err := filepath.Rel(root, file.Path)
+		if err == nil && relPath != "." {
+			byPath[relPath] = file
+		}
+	}
+
+	return byPath, complete, nil
+}
+
+// planTwoWay compares both sides with the state of the last run. It returns
+// the changes to make and, for every path that needs none, the entry to keep
+// in the next state. Conflicts are resolved here, one at a time.
+func (e *SyncEngine) planTwoWay(ctx context.Context, local, remote string, localFiles, remoteFiles map[string]*FileInfo,
+	state *twoWayState, complete bool, opts SyncOptions,
+) ([]twoWayChange, map[string]twoWayEntry, error) {
+	paths := make([]string, 0, len(localFiles)+len(remoteFiles))
+
+	for relPath := range localFiles {
+		paths = append(paths, relPath)
+	}
+
+	for relPath := range remoteFiles {
+		if _, ok := localFiles[relPath]; !ok {
+			paths = append(paths, relPath)
+		}
+	}
+
+	for relPath := range state.Files {
+		if localFiles[relPath] == nil && remoteFiles[relPath] == nil {
+			paths = append(paths, relPath)
+		}
+	}
+
+	slices.Sort(paths)
+	atomic.StoreInt64(&e.stats.FilesScanned, int64(len(paths)))
+
+	var changes []twoWayChange
+
+	next := make(map[string]twoWayEntry, len(paths))
+
+	for _, relPath := range paths {
+		l, r := localFiles[relPath], remoteFiles[relPath]
+		base, synced := state.Files[relPath]
+
+		toRemote := twoWayChange{relPath: relPath, from: l, to: r, fromRoot: local, toRoot: remote, toRemote: true, base: base, synced: synced}
+		toLocal := twoWayChange{relPath: relPath, from: r, to: l, fromRoot: remote, toRoot: local, base: base, synced: synced}
+
+		// Without a complete listing, a missing file may just be unreadable.
+		if !complete && synced && (l == nil || r == nil) {
+			next[relPath] = base
+			continue
+		}
+
+		localChanged := changedSince(l, base.Local)
+		remoteChanged := changedSince(r, base.Remote)
+
+		switch {
+		case !localChanged && !remoteChanged:
+			if l != nil && r != nil {
+				next[relPath] = twoWayEntry{Local: l, Remote: r}
+			}
+		case !remoteChanged:
+			changes = append(changes, toRemote)
+		case !localChanged:
+			changes = append(changes, toLocal)
+		case l == nil && r == nil:
+			// Deleted on both sides.
+		case l == nil:
+			changes = append(changes, toLocal)
+		case r == nil:
+			changes = append(changes, toRemote)
+		case l.IsDir && r.IsDir, !l.IsDir && !r.IsDir && (contentMatches(l, r) || !e.needsSync(l, r, opts)):
+			next[relPath] = twoWayEntry{Local: l, Remote: r}
+		case l.IsDir != r.IsDir:
+			e.errorHandler.AddError(ClassifySyncError("sync", filepath.Join(remote, relPath), ErrTypeChanged))
+			atomic.AddInt64(&e.stats.ErrorsEncountered, 1)
+
+			if synced {
+				next[relPath] = base
+			}
+		default:
+			resolution, err := e.resolveTwoWayConflict(ctx, relPath, l, r, synced)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			switch resolution {
+			case ResolutionUseSource, ResolutionBackupAndUseSource:
+				changes = append(changes, toRemote)
+			case ResolutionUseDestination:
+				changes = append(changes, toLocal)
+			default:
+				if synced {
+					next[relPath] = base
+				}
+			}
+		}
+	}
+
+	return changes, next, nil
+}
+
+// resolveTwoWayConflict asks the conflict resolver which of l and r, the
+// local and remote versions of relPath, wins, and counts the conflict.
+func (e *SyncEngine) resolveTwoWayConflict(ctx context.Context, relPath string, l, r *FileInfo, synced bool) (ConflictResolution, error) {
+	conflict := e.resolver.DetectConflict(l, r)
+	if conflict == nil {
+		conflict = &ConflictInfo{Path: l.Path, SourceInfo: l, DestInfo: r, Conflict: ConflictChecksumsDiffer}
+	}
+
+	if synced {
+		conflict.Conflict = ConflictBothModified
+	}
+
+	atomic.AddInt64(&e.stats.ConflictsFound, 1)
+	e.emit(Event{Type: EventConflictDetected, Path: relPath, Conflict: conflict.Conflict.String()})
+
+	resolution, err := e.resolver.ResolveConflict(ctx, conflict)
+	if err != nil {
+		return ResolutionSkip, fmt.Errorf("failed to resolve conflict for %s: %w", relPath, err)
+	}
+
+	if resolution != ResolutionSkip && resolution != ResolutionMerge {
+		atomic.AddInt64(&e.stats.ConflictsResolved, 1)
+	}
+
+	return resolution, nil
+}
+
+// changedSince reports whether a side's current entry for a path differs
+// from the entry the last run left, base. Either may be nil. A directory's
+// own times do not count as a change.
+func changedSince(current, base *FileInfo) bool {
+	if current == nil || base == nil {
+		return (current == nil) != (base == nil)
+	}
+
+	if current.IsDir || base.IsDir {
+		return current.IsDir != base.IsDir
+	}
+
+	return current.Size != base.Size || !current.ModTime.Equal(base.ModTime) || checksumsDiffer(current, base)
+}
+
+// applyTwoWay makes changes: directories first, in order, then files with
+// the configured number of workers, then deletions, deepest first. It
+// returns the state entry of each change made, by index; changes that failed
+// or were not reached keep their earlier entry.
+func (e *SyncEngine) applyTwoWay(ctx context.Context, changes []twoWayChange, opts SyncOptions) map[int]twoWayEntry {
+	atomic.StoreInt64(&e.progress.Total, int64(len(changes)))
+
+	var (
+		dirs, files, deletions []int
+		mu                     sync.Mutex
+		wg                     sync.WaitGroup
+	)
+
+	applied := make(map[int]twoWayEntry, len(changes))
+
+	for i, change := range changes {
+		// Changes that are never made keep the entry of the last run.
+		if change.synced {
+			applied[i] = change.base
+		}
+
+		switch {
+		case change.from == nil:
+			deletions = append(deletions, i)
+		case change.from.IsDir:
+			dirs = append(dirs, i)
+		default:
+			files = append(files, i)
+		}
+	}
+
+	sort.SliceStable(deletions, func(i, j int) bool {
+		return len(changes[deletions[i]].relPath) > len(changes[deletions[j]].relPath)
+	})
+
+	apply := func(i int) {
+		defer func() {
+			atomic.AddInt64(&e.progress.Current, 1)
+			e.updateProgress(changes[i].relPath)
+		}()
+
+		entry, ok := e.applyTwoWayChange(ctx, changes[i], opts)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case !ok:
+		case entry.Local == nil && entry.Remote == nil:
+			delete(applied, i)
+		default:
+			applied[i] = entry
+		}
+	}
+
+	for _, i := range dirs {
+		if ctx.Err() != nil {
+			return applied
+		}
+
+		apply(i)
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = e.copier.workers
+	}
+
+	semaphore := make(chan struct{}, workers)
+
+	for _, i := range files {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return applied
+		case semaphore <- struct{}{}:
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+
+			apply(i)
+		}()
+	}
+
+	wg.Wait()
+
+	for _, i := range deletions {
+		if ctx.Err() != nil {
+			return applied
+		}
+
+		apply(i)
+	}
+
+	return applied
+}
+
+// applyTwoWayChange makes one change, recording its outcome. It returns the
+// state entry for the path afterwards, empty once the path is gone from both
+// sides, and false when nothing was changed.
+func (e *SyncEngine) applyTwoWayChange(ctx context.Context, change twoWayChange, opts SyncOptions) (twoWayEntry, bool) {
+	destPath := filepath.Join(change.toRoot, change.relPath)
+
+	if change.from == nil {
+		return e.deleteTwoWay(change, destPath, opts)
+	}
+
+	if change.to != nil && change.to.IsDir != change.from.IsDir {
+		e.errorHandler.AddError(ClassifySyncError("sync", destPath, ErrTypeChanged))
+		atomic.AddInt64(&e.stats.ErrorsEncountered, 1)
+
+		return twoWayEntry{}, false
+	}
+
+	changeType := ChangeCreate
+	if change.to != nil {
+		changeType = ChangeModify
+	}
+
+	if opts.DryRun {
+		if change.from.IsDir || change.to == nil {
+			atomic.AddInt64(&e.stats.FilesCreated, 1)
+		} else {
+			atomic.AddInt64(&e.stats.FilesModified, 1)
+		}
+
+		e.recordOperation(changeType, change.relPath, change.from.Size)
+
+		return twoWayEntry{}, false
+	}
+
+	if err := e.copyTwoWay(ctx, change, destPath); err != nil {
+		return twoWayEntry{}, false
+	}
+
+	if change.from.IsDir {
+		e.recordOperation(changeType, change.relPath, 0)
+		atomic.AddInt64(&e.stats.FilesCreated, 1)
+	} else {
+		e.recordOperation(changeType, change.relPath, change.from.Size)
+		atomic.AddInt64(&e.stats.BytesTransferred, change.from.Size)
+		atomic.AddInt64(&e.stats.FilesChanged, 1)
+
+		if change.to != nil {
+			atomic.AddInt64(&e.stats.FilesModified, 1)
+		} else {
+			atomic.AddInt64(&e.stats.FilesCreated, 1)
+		}
+	}
+
+	e.audit(changeType, destPath, "", change.to, change.from)
+
+	copied, err := statCopy(destPath, change.from)
+	if err != nil {
+		// The next run finds both sides changed and matching.
+		return twoWayEntry{}, false
+	}
+
+	if change.toRemote {
+		return twoWayEntry{Local: change.from, Remote: copied}, true
+	}
+
+	return twoWayEntry{Local: copied, Remote: change.from}, true
+}
+
+// copyTwoWay copies change.from to destPath, or creates it when it is a
+// directory. A file about to be overwritten is backed up first when backups
+// are enabled. A failure is recorded and returned.
+func (e *SyncEngine) copyTwoWay(ctx context.Context, change twoWayChange, destPath string) error {
+	if change.from.IsDir {
+		err := e.guard.check("create directory", destPath)
+		if err == nil {
+			err = os.MkdirAll(toExtendedPath(destPath), os.FileMode(change.from.Mode))
+		}
+
+		if err != nil {
+			e.errorHandler.AddError(ClassifySyncError("mkdir", destPath, err))
+			atomic.AddInt64(&e.stats.ErrorsEncountered, 1)
+		}
+
+		return err
+	}
+
+	if change.to != nil {
+		err := e.guard.check("back up", destPath)
+		if err == nil {
+			_, err = e.resolver.CreateBackup(destPath)
+		}
+
+		if err != nil {
+			e.errorHandler.AddError(ClassifySyncError("backup", destPath, err))
+			atomic.AddInt64(&e.stats.ErrorsEncountered, 1)
+
+			return err
+		}
+	}
+
+	return e.copyWithRetry(ctx, change.from.Path, destPath)
+}
+
+// deleteTwoWay removes destPath, the counterpart of a path deleted on the
+// other side. The deletion is first confirmed with a direct lstat, so a path
+// that is only filtered out or unreadable is never deleted; it drops out of
+// the state instead. Directories that still hold entries are left in place.
+func (e *SyncEngine) deleteTwoWay(change twoWayChange, destPath string, opts SyncOptions) (twoWayEntry, bool) {
+	fromPath := filepath.Join(change.fromRoot, change.relPath)
+	if _, err := os.Lstat(toExtendedPath(fromPath)); !errors.Is(err, fs.ErrNotExist) {
+		if err != nil {
+			e.errorHandler.AddError(ClassifySyncError("verify-delete", fromPath, err))
+			atomic.AddInt64(&e.stats.ErrorsEncountered, 1)
+
+			return twoWayEntry{}, false
+		}
+
+		return twoWayEntry{}, true
+	}
+
+	if !opts.DryRun {
+		err := e.guard.check("delete", destPath)
+		if err == nil {
+			err = os.Remove(toExtendedPath(destPath))
+		}
+
+		switch {
+		case err == nil, errors.Is(err, fs.ErrNotExist):
+		case change.to.IsDir && isDirNotEmpty(err):
+			return twoWayEntry{}, false
+		default:
+			e.errorHandler.AddError(ClassifySyncError("delete", destPath, err))
+			atomic.AddInt64(&e.stats.ErrorsEncountered, 1)
+
+			return twoWayEntry{}, false
+		}
+	}
+
+	atomic.AddInt64(&e.stats.FilesDeleted, 1)
+	e.audit(ChangeDelete, destPath, "", change.to, nil)
+
+	if change.to.IsDir {
+		e.recordOperation(ChangeDelete, change.relPath, 0)
+	} else {
+		atomic.AddInt64(&e.stats.BytesDeleted, change.to.Size)
+		e.recordOperation(ChangeDelete, change.relPath, change.to.Size)
+	}
+
+	return twoWayEntry{}, true
+}
+
+// statCopy returns the entry for destPath, just copied from source, whose
+// digests it shares.
+func statCopy(destPath string, source *FileInfo) (*FileInfo, error) {
+	stat, err := os.Lstat(toExtendedPath(destPath))
+	if err != nil {
+		return nil, err
+	}
+
+	copied := *source
+	copied.Path = destPath
+	copied.Size = stat.Size()
+	copied.ModTime = stat.ModTime()
+	copied.Mode = uint32(stat.Mode())
+	copied.IsDir = stat.IsDir()
+
+	return &copied, nil
+}
+
+// loadTwoWayState returns the state of the last two-way sync between local
+// and remote, or an empty state when there is none.
+func (e *SyncEngine) loadTwoWayState(local, remote string) *twoWayState {
+	empty := &twoWayState{Files: map[string]twoWayEntry{}}
+	if e.syncState == "" {
+		return empty
+	}
+
+	data, err := os.ReadFile(e.syncState)
+	if err != nil {
+		return empty
+	}
+
+	var state twoWayState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return empty
+	}
+
+	absLocal, localErr := filepath.Abs(local)
+	absRemote, remoteErr := filepath.Abs(remote)
+
+	if localErr != nil || remoteErr != nil || state.Version != twoWayStateVersion ||
+		state.Local != absLocal || state.Remote != absRemote || state.Files == nil {
+		return empty
+	}
+
+	return &state
+}
+
+// saveTwoWayState writes files as the state of two-way syncs between local
+// and remote.
+func (e *SyncEngine) saveTwoWayState(local, remote string, files map[string]twoWayEntry) error {
+	absLocal, err := filepath.Abs(local)
+	if err != nil {
+		return err
+	}
+
+	absRemote, err := filepath.Abs(remote)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(twoWayState{Version: twoWayStateVersion, Local: absLocal, Remote: absRemote, Files: files})
+	if err != nil {
+		return fmt.Errorf("failed to encode sync state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(e.syncState), 0o750); err != nil {
+		return fmt.Errorf("failed to create sync state directory: %w", err)
+	}
+
+	temp := e.syncState + ".tmp"
+	if err := os.WriteFile(temp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write sync state: %w", err)
+	}
+
+	if err := os.Rename(temp, e.syncState); err != nil {
+		return fmt.Errorf("failed to replace sync state: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package core
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/howmanysmall/relay/src/internal/config"
+)
+
+func TestSyncEngineSyncTwoWay(t *testing.T) {
+	t.Parallel()
+
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	later := modTime.Add(30 * time.Minute)
+
+	tests := []struct {
+		name     string
+		strategy config.ConflictStrategy
+		noState  bool
+		change   func(t *testing.T, local, remote string)
+		want     map[string]string
+	}{
+		{
+			name:   "first sync merges both sides",
+			change: func(*testing.T, string, string) {},
+			want:   map[string]string{"a.txt": "alpha", "b.txt": "bravo", "docs/": "", "docs/c.txt": "charlie"},
+		},
+		{
+			name: "changes in either side are copied to the other",
+			change: func(t *testing.T, local, remote string) {
+				writeTreeFile(t, filepath.Join(local, "a.txt"), "alpha 2", later)
+				writeTreeFile(t, filepath.Join(remote, "docs", "d.txt"), "delta", later)
+			},
+			want: map[string]string{"a.txt": "alpha 2", "b.txt": "bravo", "docs/": "", "docs/c.txt": "charlie", "docs/d.txt": "delta"},
+		},
+		{
+			name: "deletions are carried across",
+			change: func(t *testing.T, local, remote string) {
+				removeTreePath(t, filepath.Join(local, "a.txt"))
+				removeTreePath(t, filepath.Join(remote, "docs"))
+			},
+			want: map[string]string{"b.txt": "bravo"},
+		},
+		{
+			name:    "without state deletions are restored",
+			noState: true,
+			change: func(t *testing.T, local, _ string) {
+				removeTreePath(t, filepath.Join(local, "a.txt"))
+			},
+			want: map[string]string{"a.txt": "alpha", "b.txt": "bravo", "docs/": "", "docs/c.txt": "charlie"},
+		},
+		{
+			name: "a file changed on one side survives its deletion on the other",
+			change: func(t *testing.T, local, remote string) {
+				removeTreePath(t, filepath.Join(local, "b.txt"))
+				writeTreeFile(t, filepath.Join(remote, "b.txt"), "bravo 2", later)
+			},
+			want: map[string]string{"a.txt": "alpha", "b.txt": "bravo 2", "docs/": "", "docs/c.txt": "charlie"},
+		},
+		{
+			name:     "conflicts resolved for local",
+			strategy: config.ConflictSource,
+			change: func(t *testing.T, local, remote string) {
+				writeTreeFile(t, filepath.Join(local, "a.txt"), "local", later)
+				writeTreeFile(t, filepath.Join(remote, "a.txt"), "remote", later.Add(time.Minute))
+			},
+			want: map[string]string{"a.txt": "local", "b.txt": "bravo", "docs/": "", "docs/c.txt": "charlie"},
+		},
+		{
+			name:     "conflicts resolved for remote",
+			strategy: config.ConflictDestination,
+			change: func(t *testing.T, local, remote string) {
+				writeTreeFile(t, filepath.Join(local, "a.txt"), "local", later.Add(time.Minute))
+				writeTreeFile(t, filepath.Join(remote, "a.txt"), "remote", later)
+			},
+			want: map[string]string{"a.txt": "remote", "b.txt": "bravo", "docs/": "", "docs/c.txt": "charlie"},
+		},
+		{
+			name:     "skipped conflicts stay conflicting",
+			strategy: config.ConflictSkip,
+			change: func(t *testing.T, local, remote string) {
+				writeTreeFile(t, filepath.Join(local, "a.txt"), "local", later)
+				writeTreeFile(t, filepath.Join(remote, "a.txt"), "remote", later)
+			},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tempDir := t.TempDir()
+
+			local := filepath.Join(tempDir, "local")
+			remote := filepath.Join(tempDir, "remote")
+
+			writeTreeFile(t, filepath.Join(local, "a.txt"), "alpha", modTime)
+			writeTreeFile(t, filepath.Join(remote, "b.txt"), "bravo", modTime)
+			writeTreeFile(t, filepath.Join(remote, "docs", "c.txt"), "charlie", modTime)
+
+			engine, err := NewSyncEngine()
+			if err != nil {
+				t.Fatalf("NewSyncEngine failed: %v", err)
+			}
+
+			if !tt.noState {
+				engine.SetTwoWayState(filepath.Join(tempDir, "state.json"))
+			}
+
+			engine.SetConflictConfig(&config.ConflictConfig{Strategy: string(cmp.Or(tt.strategy, config.ConflictNewest))})
+
+			syncTwoWay(t, engine, local, remote)
+			tt.change(t, local, remote)
+			syncTwoWay(t, engine, local, remote)
+
+			localTree, remoteTree := readTree(t, local), readTree(t, remote)
+
+			if tt.want == nil {
+				if localTree["a.txt"] != "local" || remoteTree["a.txt"] != "remote" {
+					t.Fatalf("a.txt is %q locally and %q remotely, want both left alone", localTree["a.txt"], remoteTree["a.txt"])
+				}
+
+				if found := engine.GetStats().ConflictsFound; found != 1 {
+					t.Fatalf("ConflictsFound = %d, want 1", found)
+				}
+
+				return
+			}
+
+			if !maps.Equal(localTree, tt.want) {
+				t.Errorf("local = %v, want %v", localTree, tt.want)
+			}
+
+			if !maps.Equal(remoteTree, tt.want) {
+				t.Errorf("remote = %v, want %v", remoteTree, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyncEngineSyncTwoWayMissingSide(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	local := filepath.Join(tempDir, "local")
+	remote := filepath.Join(tempDir, "remote")
+
+	writeTreeFile(t, filepath.Join(local, "a.txt"), "alpha", time.Now().Add(-time.Hour))
+
+	engine, err := NewSyncEngine()
+	if err != nil {
+		t.Fatalf("NewSyncEngine failed: %v", err)
+	}
+
+	engine.SetTwoWayState(filepath.Join(tempDir, "state.json"))
+
+	// A missing side is created on the first run...
+	syncTwoWay(t, engine, local, remote)
+
+	// ...but once synced, its absence must not empty the other side.
+	removeTreePath(t, remote)
+
+	if err := engine.SyncTwoWay(context.Background(), local, remote); err == nil {
+		t.Fatal("SyncTwoWay succeeded with the remote side missing")
+	}
+
+	if tree := readTree(t, local); tree["a.txt"] != "alpha" {
+		t.Fatalf("local = %v, want a.txt kept", tree)
+	}
+}
+
+func syncTwoWay(t *testing.T, engine *SyncEngine, local, remote string) {
+	t.Helper()
+
+	if err := engine.SyncTwoWay(context.Background(), local, remote); err != nil {
+		t.Fatalf("SyncTwoWay failed: %v", err)
+	}
+
+	if stats := engine.GetStats(); stats.ErrorsEncountered != 0 {
+		t.Fatalf("SyncTwoWay encountered %d errors: %v", stats.ErrorsEncountered, engine.GetErrors())
+	}
+}
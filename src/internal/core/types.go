@@ -2,18 +2,21 @@ package core
 
 import (
 	"context"
+	"fmt"
 	"time"
 )
 
 // FileInfo contains metadata about a file or directory.
 type FileInfo struct {
-	Path         string    `json:"path"`
-	Size         int64     `json:"size"`
-	ModTime      time.Time `json:"modTime"`
-	Mode         uint32    `json:"mode"`
-	IsDir        bool      `json:"isDir"`
-	Checksum     string    `json:"checksum,omitempty"`
-	ChecksumAlgo string    `json:"checksumAlgo,omitempty"`
+	Path                  string    `json:"path"`
+	Size                  int64     `json:"size"`
+	ModTime               time.Time `json:"modTime"`
+	Mode                  uint32    `json:"mode"`
+	IsDir                 bool      `json:"isDir"`
+	Checksum              string    `json:"checksum,omitempty"`
+	ChecksumAlgo          string    `json:"checksumAlgo,omitempty"`
+	SecondaryChecksum     string    `json:"secondaryChecksum,omitempty"`
+	SecondaryChecksumAlgo string    `json:"secondaryChecksumAlgo,omitempty"`
 }
 
 // ChangeEvent represents a file system change event.
@@ -51,6 +54,23 @@ func (ct ChangeType) String() string {
 	}
 }
 
+// MarshalText encodes the change type by name, so JSON output is readable.
+func (ct ChangeType) MarshalText() ([]byte, error) {
+	return []byte(ct.String()), nil
+}
+
+// UnmarshalText decodes a change type encoded by MarshalText.
+func (ct *ChangeType) UnmarshalText(text []byte) error {
+	for candidate := ChangeCreate; candidate <= ChangeRename; candidate++ {
+		if candidate.String() == string(text) {
+			*ct = candidate
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown change type %q", text)
+}
+
 // SyncStats contains statistics about a synchronization operation.
 type SyncStats struct {
 	FilesScanned      int64         `json:"filesScanned"`
@@ -58,10 +78,25 @@ type SyncStats struct {
 	FilesCreated      int64         `json:"filesCreated"`
 	FilesModified     int64         `json:"filesModified"`
 	FilesDeleted      int64         `json:"filesDeleted"`
+	BytesDeleted      int64         `json:"bytesDeleted"`
+	NetBytesChange    int64         `json:"netBytesChange"` // growth of the destination; negative when space is freed
 	BytesTransferred  int64         `json:"bytesTransferred"`
 	ConflictsFound    int64         `json:"conflictsFound"`
 	ConflictsResolved int64         `json:"conflictsResolved"`
 	ErrorsEncountered int64         `json:"errorsEncountered"`
+	ExcludedBySize    int64         `json:"excludedBySize"`
+	ExcludedByPattern int64         `json:"excludedByPattern"`
+	ExcludedByTime    int64         `json:"excludedByTime"` // last modified before the FileFilter's SetModifiedSince cutoff
+	FilesVanished     int64         `json:"filesVanished"`
+	FilesQuarantined  int64         `json:"filesQuarantined"`
+	FilesDeferred     int64         `json:"filesDeferred"`   // left for a later run by MaxFiles
+	MetadataUpdated   int64         `json:"metadataUpdated"` // content already matched; only permissions or times were set
+	DirsPruned        int64         `json:"dirsPruned"`      // directories left empty by deletions and removed
+	DestNewerKept     int64         `json:"destNewerKept"`   // destination files newer than their source, left alone by ProtectDestNewer
+	DirsMoved         int64         `json:"dirsMoved"`       // destination directories renamed to follow a renamed source directory
+	FilesReverified   int64         `json:"filesReverified"` // destination files rehashed by VerifyAfter
+	VerifyMismatches  int64         `json:"verifyMismatches"`
+	DryRun            bool          `json:"dryRun"`
 	StartTime         time.Time     `json:"startTime"`
 	EndTime           time.Time     `json:"endTime,omitempty"`
 	Duration          time.Duration `json:"duration"`
@@ -75,6 +110,7 @@ type Progress struct {
 	Speed       int64         `json:"speed"`
 	ETA         time.Duration `json:"eta"`
 	CurrentFile string        `json:"currentFile"`
+	Scanned     int64         `json:"scanned"`
 }
 
 // SyncOptions configures synchronization behavior.
@@ -84,11 +120,33 @@ type SyncOptions struct {
 	Recursive        bool          `json:"recursive"`
 	PreservePerms    bool          `json:"preservePerms"`
 	PreserveTimes    bool          `json:"preserveTimes"`
+	PreserveCrtimes  bool          `json:"preserveCrtimes"` // creation times, where CanPreserveCreationTimes
 	DeleteExtraneous bool          `json:"deleteExtraneous"`
 	ChecksumVerify   bool          `json:"checksumVerify"`
 	Workers          int           `json:"workers"`
 	BufferSize       int64         `json:"bufferSize"`
-	Timeout          time.Duration `json:"timeout"`
+	Timeout          time.Duration `json:"timeout"` // limit on copying any one file, retries included; 0 means none
+	ModifyWindow     time.Duration `json:"modifyWindow"`
+	AppendOnly       bool          `json:"appendOnly"`
+	Quarantine       bool          `json:"quarantine"`     // skip sources whose checksum changes between reads
+	MaxFiles         int64         `json:"maxFiles"`       // copy at most this many files per run; 0 means no limit
+	ReadOnly         bool          `json:"readOnly"`       // refuse every change to the destination and fail the run
+	PruneEmptyDirs   bool          `json:"pruneEmptyDirs"` // with DeleteExtraneous, remove directories it leaves empty
+	DeleteOrder      DeleteOrder   `json:"deleteOrder"`    // with DeleteExtraneous, when deletions run relative to copying
+	DetectMoves      bool          `json:"detectMoves"`    // with DeleteExtraneous, rename destination directories to follow renamed source ones
+	VerifyAfter      bool          `json:"verifyAfter"`    // rehash every destination file once the run is done; see ErrVerifyMismatch
+	// ChecksumBlockReport, with VerifyAfter, compares a mismatched file with
+	// its source in blocks of this many bytes to report which byte ranges
+	// differ; see BlockMismatchError. 0 turns the report off.
+	ChecksumBlockReport int64 `json:"checksumBlockReport"`
+	// WarnDestNewer lists in the plan the destination files that are newer
+	// than their source and would be overwritten; ProtectDestNewer lists them
+	// too and leaves them alone.
+	WarnDestNewer    bool `json:"warnDestNewer"`
+	ProtectDestNewer bool `json:"protectDestNewer"`
+	// FileList, when set, limits the sync to these source-relative paths,
+	// processed one at a time in the given order without scanning the tree.
+	FileList []string `json:"fileList,omitempty"`
 }
 
 // Watcher interface for monitoring file system changes.
@@ -0,0 +1,149 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// ErrVerifyMismatch is returned by a VerifyAfter run when destination files
+// do not read back as what was written. Each file is reported to the error
+// handler as a corruption error.
+var ErrVerifyMismatch = errors.New("destination does not match the source after sync")
+
+// verifyDestination rehashes every regular file the run left matching its
+// source, copied or already up to date, and compares it with the source's
+// size and digests. It runs only with VerifyAfter outside dry runs and
+// returns an error wrapping ErrVerifyMismatch when any file differs.
+func (e *SyncEngine) verifyDestination(ctx context.Context, destination string) error {
+	if !e.verifyAfter {
+		return nil
+	}
+
+	e.mu.RLock()
+	files := maps.Clone(e.verified)
+	e.mu.RUnlock()
+
+	var group errgroup.Group
+	group.SetLimit(max(e.copier.workers, 1))
+
+	for _, relPath := range slices.Sorted(maps.Keys(files)) {
+		file := files[relPath]
+		if !fs.FileMode(file.Mode).IsRegular() {
+			continue
+		}
+
+		group.Go(func() error {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			destPath := filepath.Join(destination, relPath)
+
+			if err := e.verifyFile(ctx, destPath, file); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+
+				e.errorHandler.AddError(verifyCorruptionError(destPath, err))
+				atomic.AddInt64(&e.stats.VerifyMismatches, 1)
+				atomic.AddInt64(&e.stats.ErrorsEncountered, 1)
+			}
+
+			atomic.AddInt64(&e.stats.FilesReverified, 1)
+
+			return nil
+		})
+	}
+
+	_ = group.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if mismatches := atomic.LoadInt64(&e.stats.VerifyMismatches); mismatches > 0 {
+		return fmt.Errorf("%w: %d of %d files", ErrVerifyMismatch, mismatches, atomic.LoadInt64(&e.stats.FilesReverified))
+	}
+
+	return nil
+}
+
+// verifyCorruptionError reports the copy at destPath as corrupt, with the
+// damaged ranges when err locates them.
+func verifyCorruptionError(destPath string, err error) *SyncError {
+	syncErr := NewCorruptionError("verify", destPath, err)
+
+	var blocks *BlockMismatchError
+	if errors.As(err, &blocks) {
+		syncErr.Ranges = blocks.Ranges
+	}
+
+	return syncErr
+}
+
+// verifyFile reads back the destination copy of source at destPath. The
+// checksum cache could answer from size and modification time alone, so the
+// file is hashed again directly. Sampled checksums only cover their samples.
+// A checksum mismatch is located with reportBlocks.
+func (e *SyncEngine) verifyFile(ctx context.Context, destPath string, source *FileInfo) error {
+	stat, err := os.Lstat(toExtendedPath(destPath))
+	if err != nil {
+		return err
+	}
+
+	if !stat.Mode().IsRegular() {
+		return fmt.Errorf("expected a regular file, found %v", stat.Mode().Type())
+	}
+
+	if stat.Size() != source.Size {
+		return fmt.Errorf("size is %d bytes, expected %d", stat.Size(), source.Size)
+	}
+
+	if source.Checksum == "" {
+		return nil
+	}
+
+	// The block report opens the source as well.
+	files := 1
+	if e.blockReport > 0 {
+		files = 2
+	}
+
+	release, err := e.openFiles.acquire(ctx, files)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	reader, err := os.Open(toExtendedPath(destPath))
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = reader.Close() }()
+
+	checksum, secondary, err := e.scanner.fileChecksum(reader, source.Size)
+	if err != nil {
+		return fmt.Errorf("failed to hash: %w", err)
+	}
+
+	if checksum != source.Checksum {
+		return e.reportBlocks(ctx, source, reader, fmt.Errorf("checksum is %s, expected %s", checksum, source.Checksum))
+	}
+
+	if source.SecondaryChecksum != "" && secondary != source.SecondaryChecksum {
+		return e.reportBlocks(ctx, source, reader,
+			fmt.Errorf("%s checksum is %s, expected %s", source.SecondaryChecksumAlgo, secondary, source.SecondaryChecksum))
+	}
+
+	return nil
+}
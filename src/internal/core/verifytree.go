@@ -0,0 +1,203 @@
+package core
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// VerifyFailure is a destination file VerifyTree found missing or different
+// from its source.
+type VerifyFailure struct {
+	Path     string `json:"path"` // relative to both roots
+	Missing  bool   `json:"missing,omitempty"`
+	Reason   string `json:"reason"`
+	Repaired bool   `json:"repaired,omitempty"` // copied again and verified
+}
+
+// VerifyReport is the result of VerifyTree.
+type VerifyReport struct {
+	Source      string          `json:"source"`
+	Destination string          `json:"destination"`
+	Verified    int64           `json:"verified"` // source files whose copy was checked
+	Failures    []VerifyFailure `json:"failures"`
+}
+
+// VerifyTree checks that destination holds an intact copy of every regular
+// file of source that the engine's filters include: each copy is hashed
+// again and compared with its source's size and digests, as VerifyAfter
+// does. Files only in the destination are not reported.
+//
+// With repair, every missing or different file is copied again and the new
+// copy verified; dry runs only count the copies they would make. The error
+// wraps ErrVerifyMismatch when any file is still missing or different, and
+// each of them is reported to the error handler.
+func (e *SyncEngine) VerifyTree(ctx context.Context, source, destination string, repair bool) (*VerifyReport, error) {
+	opts := e.options
+
+	if err := e.startRun(opts.DryRun); err != nil {
+		return nil, err
+	}
+
+	defer e.endRun()
+
+	e.applyCopyOptions(opts)
+
+	if err := checkDistinctPaths(source, destination); err != nil {
+		return nil, err
+	}
+
+	sourceFiles, err := e.scanner.ScanWithFilter(ctx, source, e.sourceFilter(source))
+	if err != nil && !e.recordIncompleteScan(err) {
+		return nil, fmt.Errorf("failed to scan source directory: %w", err)
+	}
+
+	report := &VerifyReport{Source: source, Destination: destination, Failures: []VerifyFailure{}}
+
+	var (
+		group errgroup.Group
+		mu    sync.Mutex
+	)
+
+	group.SetLimit(max(e.copier.workers, 1))
+
+	for _, file := range sourceFiles {
+		if !fs.FileMode(file.Mode).IsRegular() {
+			continue
+		}
+
+		group.Go(func() error {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			relPath, err := filepath.Rel(source, file.Path)
+			if err != nil {
+				return nil
+			}
+
+			destPath := filepath.Join(destination, relPath)
+			verifyErr := e.verifyFile(ctx, destPath, file)
+
+			atomic.AddInt64(&e.stats.FilesReverified, 1)
+
+			if verifyErr == nil || ctx.Err() != nil {
+				return nil
+			}
+
+			atomic.AddInt64(&e.stats.VerifyMismatches, 1)
+
+			failure := VerifyFailure{Path: relPath, Missing: errors.Is(verifyErr, fs.ErrNotExist), Reason: verifyErr.Error()}
+			if failure.Missing {
+				failure.Reason = "missing from the destination"
+			}
+
+			if repair {
+				failure.Repaired = e.repairFile(ctx, relPath, destPath, file, failure.Missing, opts)
+			}
+
+			if !failure.Repaired {
+				e.errorHandler.AddError(verifyError(destPath, verifyErr))
+				atomic.AddInt64(&e.stats.ErrorsEncountered, 1)
+			}
+
+			mu.Lock()
+			report.Failures = append(report.Failures, failure)
+			mu.Unlock()
+
+			return nil
+		})
+	}
+
+	_ = group.Wait()
+
+	slices.SortFunc(report.Failures, func(a, b VerifyFailure) int { return cmp.Compare(a.Path, b.Path) })
+	report.Verified = atomic.LoadInt64(&e.stats.FilesReverified)
+
+	e.finishRun()
+
+	if err := ctx.Err(); err != nil {
+		return report, err
+	}
+
+	if err := e.guard.runError(); err != nil {
+		return report, err
+	}
+
+	var failed int64
+
+	for _, failure := range report.Failures {
+		if !failure.Repaired {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return report, fmt.Errorf("%w: %d of %d files", ErrVerifyMismatch, failed, report.Verified)
+	}
+
+	return report, nil
+}
+
+// verifyError classifies the failure to verify the copy at destPath: a
+// missing copy by its cause, and a different one as corruption.
+func verifyError(destPath string, err error) *SyncError {
+	if errors.Is(err, fs.ErrNotExist) {
+		return ClassifySyncError("verify", destPath, err)
+	}
+
+	return verifyCorruptionError(destPath, err)
+}
+
+// repairFile copies source over its missing or different copy at destPath
+// and verifies the new copy, reporting whether it now matches. A dry run
+// only counts the copy.
+func (e *SyncEngine) repairFile(ctx context.Context, relPath, destPath string, source *FileInfo, missing bool, opts SyncOptions) bool {
+	changeType := ChangeModify
+	if missing {
+		changeType = ChangeCreate
+	}
+
+	if opts.DryRun {
+		e.countRepair(changeType, relPath, source.Size)
+		return false
+	}
+
+	if err := e.guard.check("repair", destPath); err != nil {
+		return false
+	}
+
+	if err := e.copyWithRetry(ctx, source.Path, destPath); err != nil {
+		return false
+	}
+
+	if err := e.verifyFile(ctx, destPath, source); err != nil {
+		return false
+	}
+
+	e.countRepair(changeType, relPath, source.Size)
+	e.audit(changeType, destPath, "", nil, source)
+	atomic.AddInt64(&e.stats.BytesTransferred, source.Size)
+
+	return true
+}
+
+// countRepair records a file copied again by repairFile.
+func (e *SyncEngine) countRepair(changeType ChangeType, relPath string, size int64) {
+	e.recordOperation(changeType, relPath, size)
+	atomic.AddInt64(&e.stats.FilesChanged, 1)
+
+	if changeType == ChangeCreate {
+		atomic.AddInt64(&e.stats.FilesCreated, 1)
+	} else {
+		atomic.AddInt64(&e.stats.FilesModified, 1)
+	}
+}
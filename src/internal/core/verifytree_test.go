@@ -0,0 +1,95 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSyncEngineVerifyTree(t *testing.T) {
+	t.Parallel()
+
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	tests := []struct {
+		name     string
+		repair   bool
+		wantErr  bool
+		repaired bool
+	}{
+		{name: "report only", wantErr: true},
+		{name: "repair", repair: true, repaired: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tempDir := t.TempDir()
+
+			source := filepath.Join(tempDir, "source")
+			dest := filepath.Join(tempDir, "dest")
+
+			writeTreeFile(t, filepath.Join(source, "intact"), "intact", modTime)
+			writeTreeFile(t, filepath.Join(source, "docs", "corrupt"), "original", modTime)
+			writeTreeFile(t, filepath.Join(source, "docs", "missing"), "missing", modTime)
+
+			engine, err := NewSyncEngine()
+			if err != nil {
+				t.Fatalf("NewSyncEngine failed: %v", err)
+			}
+
+			mirrorTree(t, engine, source, dest)
+
+			// Same size and time, so only hashing can find the damage.
+			writeTreeFile(t, filepath.Join(dest, "docs", "corrupt"), "0riginal", modTime)
+			removeTreePath(t, filepath.Join(dest, "docs", "missing"))
+			writeTreeFile(t, filepath.Join(dest, "extra"), "extra", modTime)
+
+			report, err := engine.VerifyTree(context.Background(), source, dest, tt.repair)
+			if tt.wantErr != errors.Is(err, ErrVerifyMismatch) {
+				t.Fatalf("VerifyTree error = %v, want ErrVerifyMismatch: %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && err != nil {
+				t.Fatalf("VerifyTree failed: %v", err)
+			}
+
+			if report.Verified != 3 {
+				t.Errorf("Verified = %d, want 3", report.Verified)
+			}
+
+			var got []string
+			for _, failure := range report.Failures {
+				if failure.Repaired != tt.repaired {
+					t.Errorf("%s: Repaired = %v, want %v", failure.Path, failure.Repaired, tt.repaired)
+				}
+
+				got = append(got, filepath.ToSlash(failure.Path))
+			}
+
+			if want := []string{"docs/corrupt", "docs/missing"}; !slices.Equal(got, want) {
+				t.Errorf("failures = %v, want %v", got, want)
+			}
+
+			if len(report.Failures) == 2 && !report.Failures[1].Missing {
+				t.Errorf("docs/missing was not reported missing")
+			}
+
+			tree := readTree(t, dest)
+			if tt.repair && (tree["docs/corrupt"] != "original" || tree["docs/missing"] != "missing") {
+				t.Errorf("repair left destination %v", tree)
+			}
+
+			if !tt.repair && tree["docs/corrupt"] != "0riginal" {
+				t.Errorf("VerifyTree changed the destination without repair: %v", tree)
+			}
+
+			if tree["extra"] != "extra" {
+				t.Errorf("VerifyTree touched a file only in the destination: %v", tree)
+			}
+		})
+	}
+}
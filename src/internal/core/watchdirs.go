@@ -0,0 +1,105 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// watchDirTimesInterval is how often watch mode restores the modification
+// times of destination directories that its own writes have disturbed.
+const watchDirTimesInterval = 30 * time.Second
+
+// dirTimes is the set of directories, relative to the source root, whose
+// destination modification time may no longer match the source. Creating or
+// removing an entry updates its parent's modification time, so every write
+// in watch mode marks the parent here.
+type dirTimes map[string]struct{}
+
+// touch marks the directory containing relPath.
+func (d dirTimes) touch(relPath string) {
+	d[filepath.Dir(relPath)] = struct{}{}
+}
+
+// restoreDirTimes copies each directory marked in dirs' source modification
+// time to its destination counterpart, unless the destination is read-only,
+// and clears the set. Directories that no longer exist on either side are
+// skipped.
+func (e *SyncEngine) restoreDirTimes(dirs dirTimes, source, destination string) {
+	for relDir := range dirs {
+		delete(dirs, relDir)
+
+		srcInfo, err := os.Stat(toExtendedPath(filepath.Join(source, relDir)))
+		if err != nil || !srcInfo.IsDir() {
+			continue
+		}
+
+		destDir := filepath.Join(destination, relDir)
+
+		err = e.guard.check("update metadata of", destDir)
+		if err == nil {
+			err = os.Chtimes(toExtendedPath(destDir), srcInfo.ModTime(), srcInfo.ModTime())
+		}
+
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			e.watchError("Failed to set directory times on", destDir, err)
+		}
+	}
+}
+
+// mirrorNewDirectory creates the destination for a directory that appeared in
+// the source, with the source permissions when those are preserved, and starts
+// watching it. A read-only destination is left alone. Anything already inside is copied too, since entries created
+// before the watch was added produce no events.
+func (e *SyncEngine) mirrorNewDirectory(ctx context.Context, srcDir, source, destination string, dirs dirTimes) error {
+	err := filepath.WalkDir(srcDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(source, path)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", path, err)
+		}
+
+		destPath := filepath.Join(destination, relPath)
+
+		if !entry.IsDir() {
+			dirs.touch(relPath)
+			return e.copier.CopyFile(ctx, path, destPath)
+		}
+
+		if err := e.guard.check("create directory", destPath); err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(toExtendedPath(destPath), 0o750); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", destPath, err)
+		}
+
+		if e.copier.preservePerms {
+			info, err := entry.Info()
+			if err != nil {
+				return fmt.Errorf("failed to stat directory %s: %w", path, err)
+			}
+
+			if err := os.Chmod(toExtendedPath(destPath), info.Mode().Perm()); err != nil {
+				return fmt.Errorf("failed to set directory permissions on %s: %w", destPath, err)
+			}
+		}
+
+		dirs.touch(relPath)
+		dirs[relPath] = struct{}{}
+
+		return e.watcher.Add(path)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mirror directory %s: %w", srcDir, err)
+	}
+
+	return nil
+}
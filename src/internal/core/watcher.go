@@ -3,6 +3,7 @@ package core
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"sync"
@@ -20,6 +21,7 @@ type FileWatcher struct {
 	mu        sync.RWMutex
 	watching  map[string]bool
 	running   bool
+	logger    *slog.Logger
 }
 
 type eventDebouncer struct {
@@ -44,6 +46,7 @@ func NewFileWatcher(debounceDelay time.Duration) (*FileWatcher, error) {
 		events:   make(chan ChangeEvent, 1000),
 		errors:   make(chan error, 100),
 		watching: make(map[string]bool),
+		logger:   discardLogger,
 		debouncer: &eventDebouncer{
 			delay:   debounceDelay,
 			pending: make(map[string]*time.Timer),
@@ -172,9 +175,12 @@ func (fw *FileWatcher) eventLoop(ctx context.Context) {
 				return
 			}
 
+			fw.log().Warn("file watcher error", "error", err)
+
 			select {
 			case fw.errors <- err:
 			default:
+				fw.log().Warn("file watcher error dropped, too many pending")
 			}
 		}
 	}
@@ -195,9 +201,13 @@ func (fw *FileWatcher) handleEvent(event fsnotify.Event) {
 			changeEvent.Info = info
 		}
 
+		logger := fw.log()
+		logger.Debug("file change seen", "type", changeEvent.Type.String(), "path", changeEvent.Path)
+
 		select {
 		case fw.events <- changeEvent:
 		default:
+			logger.Warn("file change dropped, too many pending", "type", changeEvent.Type.String(), "path", changeEvent.Path)
 		}
 	})
 }
@@ -247,12 +257,7 @@ func (s *FileScanner) getFileInfoFromPath(path string) (*FileInfo, error) {
 		IsDir:   stat.IsDir(),
 	}
 
-	if !info.IsDir && info.Size > 0 {
-		if checksum, err := s.getChecksum(path, info); err == nil {
-			info.Checksum = checksum
-			info.ChecksumAlgo = s.checksumAlgo
-		}
-	}
+	s.populateChecksum(info)
 
 	return info, nil
 }
@@ -0,0 +1,53 @@
+package display
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/howmanysmall/relay/src/internal/core"
+)
+
+// RenderBufferBenchmarks lists, for each buffer size measured, how fast a
+// file was written and read through it, marking the size advised.
+func RenderBufferBenchmarks(results []core.BufferBenchmark, advised int64, colorEnabled bool) string {
+	lines := []string{fmt.Sprintf("  %-10s %12s %12s", "Buffer", "Write", "Read")}
+
+	for _, result := range results {
+		line := fmt.Sprintf("  %-10s %12s %12s", formatBytes(result.BufferSize),
+			formatSpeed(int64(result.Write)), formatSpeed(int64(result.Read)))
+		if result.BufferSize == advised {
+			line = colorize(line+"  ←", color.FgGreen, colorEnabled)
+		}
+
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// RenderCopyBenchmarks lists how fast each copy method copied a file.
+func RenderCopyBenchmarks(results []core.CopyBenchmark) string {
+	lines := make([]string, len(results))
+
+	for i, result := range results {
+		lines[i] = fmt.Sprintf("  %-16s %12s", result.Method, formatSpeed(int64(result.Rate)))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// RenderChecksumBenchmarks lists how fast each checksum algorithm hashed,
+// marking the one advised.
+func RenderChecksumBenchmarks(results []core.ChecksumBenchmark, advised string, colorEnabled bool) string {
+	lines := make([]string, len(results))
+
+	for i, result := range results {
+		lines[i] = fmt.Sprintf("  %-16s %12s", result.Algo, formatSpeed(int64(result.Rate)))
+		if result.Algo == advised {
+			lines[i] = colorize(lines[i]+"  ←", color.FgGreen, colorEnabled)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,38 @@
+package display
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/howmanysmall/relay/src/internal/core"
+)
+
+// RenderCheckpoints describes each interrupted mirror in a few lines: its
+// paths, how far it got and when, and the command relay resume runs to carry
+// it on. Times are shown relative to now.
+func RenderCheckpoints(checkpoints []*core.Checkpoint, now time.Time, colorEnabled bool) string {
+	if len(checkpoints) == 0 {
+		return "No interrupted mirrors"
+	}
+
+	blocks := make([]string, len(checkpoints))
+
+	for i, checkpoint := range checkpoints {
+		lines := []string{
+			colorize(checkpoint.Destination, color.FgCyan, colorEnabled) + "  ← " + checkpoint.Source,
+			"  Done:      " + countOf(int64(checkpoint.Completed), "file", "files"),
+			"  Started:   " + timeSince(checkpoint.Started, now),
+			"  Stopped:   " + timeSince(checkpoint.Updated, now),
+		}
+
+		if len(checkpoint.Command) > 0 {
+			lines = append(lines, fmt.Sprintf("  Command:   relay %s (in %s)", strings.Join(checkpoint.Command, " "), checkpoint.Dir))
+		}
+
+		blocks[i] = strings.Join(lines, "\n")
+	}
+
+	return strings.Join(blocks, "\n\n")
+}
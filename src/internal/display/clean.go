@@ -0,0 +1,44 @@
+package display
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/howmanysmall/relay/src/internal/core"
+)
+
+// RenderBackups lists backups one per line with when each was made and its
+// size, followed by their count and total size. Backups made before cutoff
+// are marked as expired; a zero cutoff marks none.
+func RenderBackups(backups []core.Backup, cutoff, now time.Time, colorEnabled bool) string {
+	lines := make([]string, 0, len(backups)+2)
+
+	var (
+		total   int64
+		expired int64
+	)
+
+	for _, backup := range backups {
+		total += backup.Size
+
+		marker := "         "
+		if !cutoff.IsZero() && backup.Created.Before(cutoff) {
+			expired++
+			marker = colorize("expired  ", color.FgRed, colorEnabled)
+		}
+
+		lines = append(lines, fmt.Sprintf("%s%-32s %9s  %s", marker, timeSince(backup.Created, now),
+			formatBytes(backup.Size), filepath.Base(backup.Path)))
+	}
+
+	lines = append(lines, "", fmt.Sprintf("%s, %s", countOf(int64(len(backups)), "backup", "backups"), formatBytes(total)))
+
+	if !cutoff.IsZero() {
+		lines[len(lines)-1] += fmt.Sprintf("; %d expired", expired)
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,301 @@
+package display
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/howmanysmall/relay/src/internal/config"
+)
+
+// clearValue is the answer that empties a field.
+const clearValue = "-"
+
+// formField is one profile setting edited by ConfigForm.
+type formField struct {
+	label string
+	hint  string
+	list  bool
+	get   func(profile *config.Profile) []string
+	set   func(profile *config.Profile, values []string)
+}
+
+// profileFields lists the settings ConfigForm asks for, in order.
+var profileFields = []formField{
+	{
+		label: "Source",
+		hint:  "directory to mirror from",
+		get:   func(p *config.Profile) []string { return nonEmpty(p.Source) },
+		set:   func(p *config.Profile, v []string) { p.Source = first(v) },
+	},
+	{
+		label: "Destination",
+		hint:  "directory to mirror to",
+		get:   func(p *config.Profile) []string { return nonEmpty(p.Destination) },
+		set:   func(p *config.Profile, v []string) { p.Destination = first(v) },
+	},
+	{
+		label: "Mode",
+		hint:  "mirror, sync or watch",
+		get:   func(p *config.Profile) []string { return nonEmpty(p.Mode) },
+		set:   func(p *config.Profile, v []string) { p.Mode = first(v) },
+	},
+	{
+		label: "Conflict strategy",
+		hint:  "newest, source, destination, interactive, smart, skip or keep-newest:N",
+		get: func(p *config.Profile) []string {
+			if p.Conflict == nil {
+				return nil
+			}
+
+			return nonEmpty(p.Conflict.Strategy)
+		},
+		set: func(p *config.Profile, v []string) {
+			if p.Conflict == nil {
+				p.Conflict = &config.ConflictConfig{}
+			}
+
+			p.Conflict.Strategy = first(v)
+		},
+	},
+	{
+		label: "Include regex",
+		hint:  "only copy paths matching one of these",
+		list:  true,
+		get:   func(p *config.Profile) []string { return currentFilters(p).IncludeRegex },
+		set:   func(p *config.Profile, v []string) { profileFilters(p).IncludeRegex = v },
+	},
+	{
+		label: "Exclude regex",
+		hint:  "skip paths matching any of these",
+		list:  true,
+		get:   func(p *config.Profile) []string { return currentFilters(p).ExcludeRegex },
+		set:   func(p *config.Profile, v []string) { profileFilters(p).ExcludeRegex = v },
+	},
+	{
+		label: "Max file size",
+		hint:  "e.g. 1GB; larger files are skipped",
+		get:   func(p *config.Profile) []string { return nonEmpty(currentFilters(p).MaxFileSize) },
+		set:   func(p *config.Profile, v []string) { profileFilters(p).MaxFileSize = first(v) },
+	},
+	{
+		label: "Min file size",
+		hint:  "e.g. 1KB; smaller files are skipped",
+		get:   func(p *config.Profile) []string { return nonEmpty(currentFilters(p).MinFileSize) },
+		set:   func(p *config.Profile, v []string) { profileFilters(p).MinFileSize = first(v) },
+	},
+}
+
+// ConfigForm prompts for the main settings of a profile, one field at a time.
+type ConfigForm struct {
+	colorEnabled bool
+	reader       *bufio.Reader
+	validate     func(profile *config.Profile) error
+}
+
+// NewConfigForm creates a form that checks every answer with validate.
+func NewConfigForm(colorEnabled bool, validate func(profile *config.Profile) error) *ConfigForm {
+	return &ConfigForm{
+		colorEnabled: colorEnabled,
+		reader:       bufio.NewReader(os.Stdin),
+		validate:     validate,
+	}
+}
+
+// Edit walks through the fields of profile, showing each current value.
+// Pressing Enter keeps a value and "-" clears it. An answer that fails
+// validation is rejected and asked for again. Edit returns whether the user
+// chose to save the result; profile is modified either way.
+func (f *ConfigForm) Edit(name string, profile *config.Profile) (bool, error) {
+	if err := f.Fill(name, profile); err != nil {
+		return false, err
+	}
+
+	fmt.Println(f.formatMessage("Summary", color.FgMagenta))
+	fmt.Println(strings.Repeat("─", 50))
+
+	for _, field := range profileFields {
+		fmt.Printf("  %-20s %s\n", field.label+":", formatValues(field.get(profile)))
+	}
+
+	fmt.Println()
+
+	return f.Confirm("Save changes?")
+}
+
+// Fill walks through the fields of profile as Edit does, without asking
+// whether to save.
+func (f *ConfigForm) Fill(name string, profile *config.Profile) error {
+	fmt.Println(f.formatMessage(fmt.Sprintf("📝 Editing profile %s", name), color.FgCyan))
+	fmt.Println(f.formatMessage(strings.Repeat("═", 50), color.FgBlue))
+	fmt.Println(f.formatMessage("Press Enter to keep a value, or enter - to clear it.", FgWhite))
+	fmt.Println()
+
+	for _, field := range profileFields {
+		if err := f.editField(profile, field); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Confirm asks question until the answer is yes or no.
+func (f *ConfigForm) Confirm(question string) (bool, error) {
+	for {
+		fmt.Print(f.formatMessage(question+" [y/n]: ", color.FgCyan))
+
+		input, err := f.reader.ReadString('\n')
+		if err != nil {
+			return false, fmt.Errorf("failed to read input: %w", err)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(input)) {
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		default:
+			fmt.Println(f.formatMessage("Invalid choice. Please enter y or n.", color.FgRed))
+		}
+	}
+}
+
+// Ask prompts for a single value, which is empty when the user just presses
+// Enter.
+func (f *ConfigForm) Ask(label, hint string) (string, error) {
+	fmt.Println(f.formatMessage(label, color.FgYellow) + " (" + hint + ")")
+	fmt.Print(f.formatMessage("  Value: ", color.FgCyan))
+
+	input, err := f.reader.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read input: %w", err)
+	}
+
+	fmt.Println()
+
+	return strings.TrimSpace(input), nil
+}
+
+// editField prompts for one field until the answer passes validation.
+func (f *ConfigForm) editField(profile *config.Profile, field formField) error {
+	for {
+		fmt.Println(f.formatMessage(field.label, color.FgYellow) + " (" + field.hint + ")")
+		fmt.Println("  Current: " + formatValues(field.get(profile)))
+
+		values, keep, err := f.readValues(field.list)
+		if err != nil {
+			return err
+		}
+
+		if keep {
+			fmt.Println()
+			return nil
+		}
+
+		previous := field.get(profile)
+		field.set(profile, values)
+
+		err = f.validate(profile)
+		if err == nil {
+			fmt.Println()
+			return nil
+		}
+
+		field.set(profile, previous)
+		fmt.Println(f.formatMessage("Invalid value: "+err.Error(), color.FgRed))
+	}
+}
+
+// readValues reads the answer for a field. List fields take one value per
+// line up to an empty line. keep reports that the first line was empty.
+func (f *ConfigForm) readValues(list bool) ([]string, bool, error) {
+	var values []string
+
+	prompt := "  New value: "
+	if list {
+		prompt = "  New values, one per line, empty line to finish: "
+	}
+
+	for {
+		fmt.Print(f.formatMessage(prompt, color.FgCyan))
+
+		input, err := f.reader.ReadString('\n')
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to read input: %w", err)
+		}
+
+		value := strings.TrimSpace(input)
+
+		switch {
+		case value == "" && values == nil:
+			return nil, true, nil
+		case value == clearValue && values == nil:
+			return nil, false, nil
+		case value == "":
+			return values, false, nil
+		}
+
+		values = append(values, value)
+		if !list {
+			return values, false, nil
+		}
+
+		prompt = "  ... "
+	}
+}
+
+// formatMessage applies color formatting if enabled.
+func (f *ConfigForm) formatMessage(text string, colorAttr color.Attribute) string {
+	if !f.colorEnabled {
+		return text
+	}
+
+	return color.New(colorAttr).Sprint(text)
+}
+
+// currentFilters returns profile's filter rules, or empty rules if it has
+// none, without adding them to profile.
+func currentFilters(profile *config.Profile) *config.FilterRules {
+	if profile.Filters == nil {
+		return &config.FilterRules{}
+	}
+
+	return profile.Filters
+}
+
+// profileFilters returns profile's filter rules, creating them if needed.
+func profileFilters(profile *config.Profile) *config.FilterRules {
+	if profile.Filters == nil {
+		// Empty rather than nil lists, so JSON files get [] instead of null.
+		profile.Filters = &config.FilterRules{Include: []string{}, Exclude: []string{}}
+	}
+
+	return profile.Filters
+}
+
+func nonEmpty(value string) []string {
+	if value == "" {
+		return nil
+	}
+
+	return []string{value}
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
+func formatValues(values []string) string {
+	if len(values) == 0 {
+		return "(not set)"
+	}
+
+	return strings.Join(values, ", ")
+}
@@ -2,6 +2,7 @@ package display
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -126,7 +127,7 @@ func (cui *ConflictUI) printFileComparison(conflict *core.ConflictInfo) {
 		conflict.SourceInfo.Path)
 	fmt.Printf("%-15s %s\n",
 		"Size:",
-		cui.formatBytes(conflict.SourceInfo.Size))
+		formatBytes(conflict.SourceInfo.Size))
 	fmt.Printf("%-15s %s\n",
 		"Modified:",
 		conflict.SourceInfo.ModTime.Format("2006-01-02 15:04:05"))
@@ -145,7 +146,7 @@ func (cui *ConflictUI) printFileComparison(conflict *core.ConflictInfo) {
 		conflict.DestInfo.Path)
 	fmt.Printf("%-15s %s\n",
 		"Size:",
-		cui.formatBytes(conflict.DestInfo.Size))
+		formatBytes(conflict.DestInfo.Size))
 	fmt.Printf("%-15s %s\n",
 		"Modified:",
 		conflict.DestInfo.ModTime.Format("2006-01-02 15:04:05"))
@@ -265,38 +266,24 @@ func (cui *ConflictUI) showFilePreview(conflict *core.ConflictInfo) {
 	cui.printResolutionOptions()
 }
 
-// showFileLines displays the first N lines of a file.
+// showFileLines displays the first N lines of a text file. Binary files are
+// not displayed.
 func (cui *ConflictUI) showFileLines(path string, maxLines int) error {
-	file, err := os.Open(path)
-	if err != nil {
-		return err
+	lines, truncated, err := core.ReadPreview(path, maxLines, 100)
+	if errors.Is(err, core.ErrBinaryContent) {
+		fmt.Println(cui.formatMessage(fmt.Sprintf("Not shown: %v", err), color.FgYellow))
+		return nil
 	}
 
-	defer func() {
-		if cerr := file.Close(); cerr != nil {
-			_ = cerr // intentionally ignore close error
-		}
-	}()
-
-	scanner := bufio.NewScanner(file)
-	lineNum := 1
-
-	for scanner.Scan() && lineNum <= maxLines {
-		line := scanner.Text()
-		// Truncate very long lines
-		if len(line) > 100 {
-			line = line[:97] + "..."
-		}
-
-		fmt.Printf("%3d: %s\n", lineNum, line)
-		lineNum++
+	for i, line := range lines {
+		fmt.Printf("%3d: %s\n", i+1, line)
 	}
 
-	if lineNum > maxLines {
+	if truncated {
 		fmt.Println(cui.formatMessage("... (truncated)", color.FgYellow))
 	}
 
-	return scanner.Err()
+	return err
 }
 
 // clearScreen clears the terminal screen.
@@ -317,25 +304,3 @@ func (cui *ConflictUI) formatMessage(text string, colorAttr color.Attribute) str
 
 	return color.New(colorAttr).Sprint(text)
 }
-
-// formatBytes formats byte count in human-readable format.
-func (cui *ConflictUI) formatBytes(bytes int64) string {
-	if bytes == 0 {
-		return "0 B"
-	}
-
-	units := []string{"B", "KB", "MB", "GB"}
-	size := float64(bytes)
-	unitIndex := 0
-
-	for size >= 1024 && unitIndex < len(units)-1 {
-		size /= 1024
-		unitIndex++
-	}
-
-	if unitIndex == 0 {
-		return fmt.Sprintf("%.0f %s", size, units[unitIndex])
-	}
-
-	return fmt.Sprintf("%.1f %s", size, units[unitIndex])
-}
@@ -0,0 +1,77 @@
+package display
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/howmanysmall/relay/src/internal/core"
+)
+
+// RenderDaemonState describes a running daemon and, in a few lines each,
+// the profiles it keeps in sync: what triggers them, the run going now with
+// its progress, and the last run it finished. Times are shown relative to
+// now.
+func RenderDaemonState(state *core.DaemonState, now time.Time, colorEnabled bool) string {
+	blocks := []string{fmt.Sprintf("Daemon (pid %d) for %s, running since %s", state.PID, orNotSet(state.Config),
+		timeSince(state.Started, now))}
+
+	for _, profile := range state.Profiles {
+		trigger := "on demand"
+
+		switch profile.Trigger {
+		case "schedule":
+			trigger = fmt.Sprintf("schedule %q, next run %s", profile.Schedule, profile.Next.Format(time.DateTime))
+		case "watch":
+			trigger = "changes to the source"
+		}
+
+		lines := []string{
+			colorize(profile.Name, color.FgCyan, colorEnabled) + fmt.Sprintf("  %s → %s", profile.Source, profile.Destination),
+			"  Trigger:   " + trigger,
+			"  Now:       " + currentRun(profile, now, colorEnabled) + paused(profile, colorEnabled),
+			"  Last run:  " + lastRun(profile.Last, now, colorEnabled),
+		}
+
+		blocks = append(blocks, strings.Join(lines, "\n"))
+	}
+
+	return strings.Join(blocks, "\n\n")
+}
+
+// paused notes that profile is paused, after what currentRun says.
+func paused(profile core.DaemonProfileState, colorEnabled bool) string {
+	if !profile.Paused {
+		return ""
+	}
+
+	return colorize(" (paused)", color.FgYellow, colorEnabled)
+}
+
+// currentRun summarizes the run of profile going now, if any.
+func currentRun(profile core.DaemonProfileState, now time.Time, colorEnabled bool) string {
+	if !profile.Running {
+		return "idle"
+	}
+
+	summary := colorize("mirroring since "+timeSince(profile.RunStarted, now), color.FgGreen, colorEnabled)
+
+	progress := profile.Progress
+	if progress == nil {
+		return summary
+	}
+
+	if progress.Total > 0 {
+		summary += fmt.Sprintf(", %.1f%% (%s of %s)", progress.Percentage, formatCount(progress.Current),
+			countOf(progress.Total, "file", "files"))
+	} else {
+		summary += fmt.Sprintf(", scanning (%s)", countOf(progress.Scanned, "file", "files"))
+	}
+
+	if progress.CurrentFile != "" {
+		summary += ", " + progress.CurrentFile
+	}
+
+	return summary
+}
@@ -21,6 +21,7 @@ type Dashboard struct {
 	termWidth    int
 	termHeight   int
 	lastLines    int
+	fullScreen   bool
 }
 
 // NewDashboard creates a new dashboard for the sync engine.
@@ -47,10 +48,15 @@ func (d *Dashboard) Run(ctx context.Context) {
 	ticker := time.NewTicker(d.refreshRate)
 	defer ticker.Stop()
 
+	if d.fullScreen && d.colorEnabled {
+		d.runFullScreen(ctx, ticker)
+		return
+	}
+
 	// Hide cursor
 	if d.colorEnabled {
-		fmt.Print("\033[?25l")
-		defer fmt.Print("\033[?25h")
+		fmt.Print(hideCursor)
+		defer fmt.Print(showCursor)
 	}
 
 	for {
@@ -82,6 +88,12 @@ func (d *Dashboard) update() {
 		lines = append(lines, "")
 	}
 
+	// Plan, once the trees are scanned
+	if planLine := d.renderer.RenderPlan(d.engine.GetPlan()); planLine != "" {
+		lines = append(lines, planLine)
+		lines = append(lines, "")
+	}
+
 	// Progress section
 	progressLines := d.renderer.RenderProgress(progress, stats)
 	if progressLines != "" {
@@ -197,6 +209,33 @@ func PrintSimpleProgress(engine *core.SyncEngine, colorEnabled bool) {
 	fmt.Println(progressLine)
 }
 
+// PrintScanProgress prints a "scanned N files" line every interval while the
+// engine is still scanning, for output that is not a live dashboard. It
+// returns once scanning finishes or ctx is cancelled.
+func PrintScanProgress(ctx context.Context, engine *core.SyncEngine, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastScanned int64
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			progress := engine.GetProgress()
+			if progress.Total > 0 {
+				return
+			}
+
+			if progress.Scanned != lastScanned {
+				fmt.Printf("Scanned %d files...\n", progress.Scanned)
+				lastScanned = progress.Scanned
+			}
+		}
+	}
+}
+
 // PrintSimpleStats prints final statistics without dashboard.
 func PrintSimpleStats(engine *core.SyncEngine, colorEnabled bool) {
 	renderer := NewProgressRenderer(colorEnabled, 80)
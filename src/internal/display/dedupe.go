@@ -0,0 +1,61 @@
+package display
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/howmanysmall/relay/src/internal/core"
+)
+
+// RenderDuplicates lists the groups of identical files in report, most
+// wasted space first, with paths relative to its root, then the total space
+// linking them would free. At most top groups are listed when top is
+// positive.
+func RenderDuplicates(report *core.DuplicateReport, top int, colorEnabled bool) string {
+	var lines []string
+
+	groups := report.Groups
+	if top > 0 && len(groups) > top {
+		groups = groups[:top]
+	}
+
+	for _, group := range groups {
+		lines = append(lines, fmt.Sprintf("%s × %d  %s wasted",
+			formatBytes(group.Size), group.Copies, colorize(formatBytes(group.Wasted()), color.FgYellow, colorEnabled)))
+
+		for _, path := range group.Paths {
+			if rel, err := filepath.Rel(report.Root, path); err == nil {
+				path = rel
+			}
+
+			lines = append(lines, "  "+path)
+		}
+
+		lines = append(lines, "")
+	}
+
+	if len(groups) < len(report.Groups) {
+		lines = append(lines, fmt.Sprintf("… and %s more", countOf(int64(len(report.Groups)-len(groups)), "group", "groups")), "")
+	}
+
+	summary := fmt.Sprintf("%s  %s of duplicates among %s, wasting %s",
+		colorize(report.Root, color.FgCyan, colorEnabled),
+		countOf(int64(len(report.Groups)), "group", "groups"),
+		countOf(report.Files, "file", "files"),
+		formatBytes(report.Wasted()))
+	if len(report.Groups) == 0 {
+		summary = fmt.Sprintf("%s  no duplicates among %s",
+			colorize(report.Root, color.FgCyan, colorEnabled), countOf(report.Files, "file", "files"))
+	}
+
+	lines = append(lines, summary)
+
+	if report.Unreadable > 0 {
+		lines = append(lines, colorize(countOf(int64(report.Unreadable), "entry", "entries")+
+			" could not be read and are not compared", color.FgYellow, colorEnabled))
+	}
+
+	return strings.Join(lines, "\n")
+}
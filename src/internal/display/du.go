@@ -0,0 +1,64 @@
+package display
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/howmanysmall/relay/src/internal/core"
+)
+
+// RenderDiskUsage summarizes usage: its totals, then its largest files,
+// directories and extensions, each with its share of the total size.
+func RenderDiskUsage(usage *core.DiskUsage, colorEnabled bool) string {
+	lines := []string{fmt.Sprintf("%s  %s in %s and %s",
+		colorize(usage.Root, color.FgCyan, colorEnabled), formatBytes(usage.Size),
+		countOf(usage.Files, "file", "files"), countOf(usage.Dirs, "directory", "directories"))}
+
+	if usage.Unreadable > 0 {
+		lines = append(lines, colorize(countOf(int64(usage.Unreadable), "entry", "entries")+
+			" could not be read and are not counted", color.FgYellow, colorEnabled))
+	}
+
+	share := func(size int64) string {
+		if usage.Size == 0 {
+			return ""
+		}
+
+		return fmt.Sprintf("%5.1f%%", float64(size)*100/float64(usage.Size))
+	}
+
+	if len(usage.LargestFiles) > 0 {
+		lines = append(lines, "", "Largest files:")
+
+		for _, file := range usage.LargestFiles {
+			lines = append(lines, fmt.Sprintf("  %10s %6s  %s", formatBytes(file.Size), share(file.Size), file.Path))
+		}
+	}
+
+	if len(usage.LargestDirs) > 0 {
+		lines = append(lines, "", "Largest directories:")
+
+		for _, dir := range usage.LargestDirs {
+			lines = append(lines, fmt.Sprintf("  %10s %6s  %s (%s)", formatBytes(dir.Size), share(dir.Size),
+				dir.Path+string(filepath.Separator), countOf(dir.Files, "file", "files")))
+		}
+	}
+
+	if len(usage.Extensions) > 0 {
+		lines = append(lines, "", "By extension:")
+
+		for _, ext := range usage.Extensions {
+			name := ext.Extension
+			if name == "" {
+				name = "(none)"
+			}
+
+			lines = append(lines, fmt.Sprintf("  %10s %6s  %s (%s)", formatBytes(ext.Size), share(ext.Size),
+				name, countOf(ext.Files, "file", "files")))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
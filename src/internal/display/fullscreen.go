@@ -0,0 +1,118 @@
+package display
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/howmanysmall/relay/src/internal/core"
+	"golang.org/x/term"
+)
+
+// Terminal control sequences used by the full-screen dashboard.
+const (
+	enterAltScreen = "\033[?1049h"
+	exitAltScreen  = "\033[?1049l"
+	hideCursor     = "\033[?25l"
+	showCursor     = "\033[?25h"
+	cursorHome     = "\033[H"
+	clearLine      = "\033[K"
+	clearBelow     = "\033[J"
+)
+
+// SetFullScreen makes Run take over the terminal's alternate screen, showing
+// a scrolling log of recent file operations above a pinned progress and
+// statistics footer. The normal screen is restored when Run returns. It has
+// no effect when output is not a terminal.
+func (d *Dashboard) SetFullScreen(enabled bool) {
+	d.fullScreen = enabled
+}
+
+// runFullScreen redraws the full-screen view every tick until ctx is done.
+func (d *Dashboard) runFullScreen(ctx context.Context, ticker *time.Ticker) {
+	fmt.Print(enterAltScreen + hideCursor)
+	defer fmt.Print(showCursor + exitAltScreen)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.updateFullScreen()
+		}
+	}
+}
+
+// updateFullScreen redraws the whole screen from the top, sizing the
+// operation log to whatever the footer leaves free.
+func (d *Dashboard) updateFullScreen() {
+	if width, height, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+		d.termWidth, d.termHeight = width, height
+		d.renderer.width = width
+	}
+
+	stats := d.engine.GetStats()
+
+	footer := []string{d.formatMessage(strings.Repeat("─", d.termWidth-1), color.FgBlue)}
+	footer = append(footer, strings.Split(d.renderer.RenderProgress(d.engine.GetProgress(), stats), "\n")...)
+
+	if statsLines := d.renderer.RenderStats(stats); statsLines != "" {
+		footer = append(footer, "")
+		footer = append(footer, strings.Split(statsLines, "\n")...)
+	}
+
+	if errorLines := d.renderer.RenderErrors(d.engine.GetErrorSummary()); errorLines != "" {
+		footer = append(footer, "")
+		footer = append(footer, strings.Split(errorLines, "\n")...)
+	}
+
+	header := strings.Split(d.formatHeader(), "\n")
+	logHeight := max(d.termHeight-len(header)-len(footer), 0)
+
+	operations := d.engine.RecentOperations()
+	if len(operations) > logHeight {
+		operations = operations[len(operations)-logHeight:]
+	}
+
+	lines := make([]string, 0, d.termHeight)
+	lines = append(lines, header...)
+
+	// Pad above the log so the newest entry sits directly over the footer.
+	for range logHeight - len(operations) {
+		lines = append(lines, "")
+	}
+
+	for _, operation := range operations {
+		lines = append(lines, d.formatOperation(operation))
+	}
+
+	lines = append(lines, footer...)
+
+	fmt.Print(cursorHome + strings.Join(lines, clearLine+"\n") + clearLine + clearBelow)
+}
+
+// formatOperation renders one log line, trimming the path from the left so
+// the file name stays visible on narrow terminals.
+func (d *Dashboard) formatOperation(operation core.FileOperation) string {
+	symbol, attr := "~", color.FgYellow
+
+	switch operation.Type {
+	case core.ChangeCreate:
+		symbol, attr = "+", color.FgGreen
+	case core.ChangeDelete:
+		symbol, attr = "-", color.FgRed
+	}
+
+	size := formatBytes(operation.Size)
+	timestamp := operation.Time.Format("15:04:05")
+
+	path := []rune(operation.Path)
+	if room := d.termWidth - len(timestamp) - len(size) - 8; len(path) > room {
+		path = append([]rune("..."), path[len(path)-max(room-3, 0):]...)
+	}
+
+	return fmt.Sprintf("%s %s %s (%s)", timestamp, d.formatMessage(symbol, attr), string(path), size)
+}
@@ -0,0 +1,39 @@
+package display
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/howmanysmall/relay/src/internal/core"
+)
+
+// RenderHistory lists runs one per line in the order given: the profile,
+// when the run finished and which command ran it, what it changed and how
+// many files it could not sync. Times are shown relative to now.
+func RenderHistory(runs []core.ProfileStatus, now time.Time, colorEnabled bool) string {
+	if len(runs) == 0 {
+		return "No runs recorded"
+	}
+
+	width := 0
+	for _, run := range runs {
+		width = max(width, len(run.Profile))
+	}
+
+	lines := make([]string, len(runs))
+
+	for i := range runs {
+		run := &runs[i]
+
+		line := colorize(fmt.Sprintf("%-*s", width, run.Profile), color.FgCyan, colorEnabled) + "  " + lastRun(run, now, colorEnabled)
+		if run.PendingErrors > 0 {
+			line += ", " + colorize(countOf(int64(run.PendingErrors), "file", "files")+" not synced", color.FgYellow, colorEnabled)
+		}
+
+		lines[i] = line
+	}
+
+	return strings.Join(lines, "\n")
+}
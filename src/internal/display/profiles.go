@@ -0,0 +1,111 @@
+package display
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/howmanysmall/relay/src/internal/config"
+)
+
+// defaultProfileName is the profile used when --profile is not given.
+const defaultProfileName = "default"
+
+// RenderProfiles lists the profiles of cfg, one per line: the default first,
+// then the others by name, each with its mode and source → destination. The
+// default profile, used when --profile is not given, is marked with *.
+func RenderProfiles(cfg *config.Config, colorEnabled bool) string {
+	profiles := configProfiles(cfg)
+
+	if len(profiles) == 0 {
+		return "No profiles defined"
+	}
+
+	names := ProfileNames(cfg)
+
+	nameWidth := len(slices.MaxFunc(names, func(a, b string) int { return len(a) - len(b) }))
+
+	lines := make([]string, len(names))
+
+	for i, name := range names {
+		profile := profiles[name]
+
+		marker := " "
+		if name == defaultProfileName {
+			marker = colorize("*", color.FgGreen, colorEnabled)
+		}
+
+		mode := profile.Mode
+		if mode == "" {
+			mode = "-"
+		}
+
+		line := fmt.Sprintf("%s %s  %-6s  %s → %s", marker,
+			colorize(fmt.Sprintf("%-*s", nameWidth, name), color.FgCyan, colorEnabled),
+			mode, orNotSet(profile.Source), orNotSet(profile.Destination))
+
+		if profile.Extends != "" {
+			line += fmt.Sprintf("  (extends %s)", profile.Extends)
+		}
+
+		if steps := len(profile.Pipeline); steps > 0 {
+			line += fmt.Sprintf("  (pipeline of %s)", countOf(int64(steps), "step", "steps"))
+		}
+
+		lines[i] = line
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// ProfileNames returns the names of the profiles of cfg, the default first
+// and the others sorted.
+func ProfileNames(cfg *config.Config) []string {
+	names := slices.Collect(maps.Keys(configProfiles(cfg)))
+
+	slices.SortFunc(names, func(a, b string) int {
+		switch {
+		case a == defaultProfileName:
+			return -1
+		case b == defaultProfileName:
+			return 1
+		default:
+			return strings.Compare(a, b)
+		}
+	})
+
+	return names
+}
+
+// configProfiles returns the profiles of cfg by name.
+func configProfiles(cfg *config.Config) map[string]*config.Profile {
+	profiles := make(map[string]*config.Profile, len(cfg.Profiles)+1)
+	for name, profile := range cfg.Profiles {
+		profiles[name] = profile
+	}
+
+	// A top-level default takes precedence over a profile named "default".
+	if cfg.Default != nil {
+		profiles[defaultProfileName] = cfg.Default
+	}
+
+	return profiles
+}
+
+func orNotSet(value string) string {
+	if value == "" {
+		return "(not set)"
+	}
+
+	return value
+}
+
+func colorize(text string, colorAttr color.Attribute, colorEnabled bool) string {
+	if !colorEnabled {
+		return text
+	}
+
+	return color.New(colorAttr).Sprint(text)
+}
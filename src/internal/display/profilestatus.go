@@ -0,0 +1,97 @@
+package display
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/howmanysmall/relay/src/internal/config"
+	"github.com/howmanysmall/relay/src/internal/core"
+)
+
+// ProfileStatusEntry is what relay status knows about one profile.
+type ProfileStatusEntry struct {
+	Name    string
+	Profile *config.Profile
+	Last    *core.ProfileStatus // nil when the profile has never run
+	Daemon  *core.DaemonStatus  // nil when no daemon runs the profile
+}
+
+// RenderProfileStatus describes each entry in a few lines: the profile's
+// paths, when it last ran and what that run did, the files it left unsynced
+// and the daemon running it, if any. Times are shown relative to now.
+func RenderProfileStatus(entries []ProfileStatusEntry, now time.Time, colorEnabled bool) string {
+	if len(entries) == 0 {
+		return "No profiles defined"
+	}
+
+	blocks := make([]string, len(entries))
+
+	for i, entry := range entries {
+		paths := fmt.Sprintf("%s → %s", orNotSet(entry.Profile.Source), orNotSet(entry.Profile.Destination))
+		if steps := len(entry.Profile.Pipeline); steps > 0 {
+			paths = "pipeline of " + countOf(int64(steps), "step", "steps")
+		}
+
+		lines := []string{
+			colorize(entry.Name, color.FgCyan, colorEnabled) + "  " + paths,
+			"  Last run:  " + lastRun(entry.Last, now, colorEnabled),
+		}
+
+		if entry.Last != nil && entry.Last.PendingErrors > 0 {
+			lines = append(lines, "  Errors:    "+colorize(
+				countOf(int64(entry.Last.PendingErrors), "file", "files")+" could not be synced",
+				color.FgYellow, colorEnabled))
+		}
+
+		daemon := "not running"
+		if entry.Daemon != nil {
+			daemon = colorize(fmt.Sprintf("relay %s running (pid %d) since %s", entry.Daemon.Command,
+				entry.Daemon.PID, timeSince(entry.Daemon.Started, now)), color.FgGreen, colorEnabled)
+		}
+
+		lines = append(lines, "  Daemon:    "+daemon)
+		blocks[i] = strings.Join(lines, "\n")
+	}
+
+	return strings.Join(blocks, "\n\n")
+}
+
+// lastRun summarizes the run recorded by last.
+func lastRun(last *core.ProfileStatus, now time.Time, colorEnabled bool) string {
+	if last == nil {
+		return "never"
+	}
+
+	summary := fmt.Sprintf("%s by relay %s", timeSince(last.Finished, now), last.Command)
+
+	switch {
+	case last.Error != "":
+		return summary + ", " + colorize("failed: "+last.Error, color.FgRed, colorEnabled)
+	case last.Stats == nil:
+		return summary
+	}
+
+	return summary + fmt.Sprintf(", %s changed in %v", countOf(last.Stats.FilesChanged, "file", "files"),
+		last.Stats.Duration.Round(time.Millisecond))
+}
+
+// timeSince formats then as a date and time followed by how long before now
+// it was, e.g. "2026-01-02 15:04:05 (3h ago)".
+func timeSince(then, now time.Time) string {
+	var ago string
+
+	switch elapsed := now.Sub(then); {
+	case elapsed < time.Minute:
+		ago = "just now"
+	case elapsed < time.Hour:
+		ago = fmt.Sprintf("%dm ago", int(elapsed.Minutes()))
+	case elapsed < 48*time.Hour:
+		ago = fmt.Sprintf("%dh ago", int(elapsed.Hours()))
+	default:
+		ago = fmt.Sprintf("%dd ago", int(elapsed.Hours()/24))
+	}
+
+	return fmt.Sprintf("%s (%s)", then.Format(time.DateTime), ago)
+}
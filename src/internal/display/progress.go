@@ -31,7 +31,7 @@ func NewProgressRenderer(colorEnabled bool, terminalWidth int) *ProgressRenderer
 // RenderProgress renders a progress bar with statistics.
 func (pr *ProgressRenderer) RenderProgress(progress *core.Progress, _ *core.SyncStats) string {
 	if progress.Total == 0 {
-		return pr.formatMessage("🔍 Scanning files...", color.FgCyan)
+		return pr.renderScanning(progress.Scanned)
 	}
 
 	percentage := progress.Percentage
@@ -54,7 +54,7 @@ func (pr *ProgressRenderer) RenderProgress(progress *core.Progress, _ *core.Sync
 	bar := strings.Repeat("█", filled) + strings.Repeat("░", barWidth-filled)
 
 	// Format speed
-	speed := pr.formatSpeed(progress.Speed)
+	speed := formatSpeed(progress.Speed)
 
 	// Format ETA
 	eta := pr.formatDuration(progress.ETA)
@@ -80,6 +80,22 @@ func (pr *ProgressRenderer) RenderProgress(progress *core.Progress, _ *core.Sync
 	return progressLine + "\n" + statusLine
 }
 
+// spinnerFrames animate the scanning line; one frame per 100ms.
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// renderScanning renders the scan phase, whose length is unknown up front.
+// Without color (not a terminal) the line is static so it can be logged.
+func (pr *ProgressRenderer) renderScanning(scanned int64) string {
+	message := fmt.Sprintf("🔍 Scanning files... %d found", scanned)
+	if !pr.colorEnabled {
+		return message
+	}
+
+	frame := spinnerFrames[time.Now().UnixMilli()/100%int64(len(spinnerFrames))]
+
+	return pr.formatMessage(frame+" "+message, color.FgCyan)
+}
+
 // RenderStats renders synchronization statistics.
 func (pr *ProgressRenderer) RenderStats(stats *core.SyncStats) string {
 	if stats.StartTime.IsZero() {
@@ -99,7 +115,7 @@ func (pr *ProgressRenderer) RenderStats(stats *core.SyncStats) string {
 
 	// Transfer stats
 	if stats.BytesTransferred > 0 {
-		transferred := pr.formatBytes(stats.BytesTransferred)
+		transferred := formatBytes(stats.BytesTransferred)
 
 		var duration time.Duration
 		if !stats.EndTime.IsZero() {
@@ -109,7 +125,7 @@ func (pr *ProgressRenderer) RenderStats(stats *core.SyncStats) string {
 		}
 
 		avgSpeed := float64(stats.BytesTransferred) / duration.Seconds()
-		speed := pr.formatSpeed(int64(avgSpeed))
+		speed := formatSpeed(int64(avgSpeed))
 
 		transferLine := fmt.Sprintf("📊 Transferred: %s in %s (avg: %s)",
 			pr.formatMessage(transferred, color.FgCyan),
@@ -119,6 +135,129 @@ func (pr *ProgressRenderer) RenderStats(stats *core.SyncStats) string {
 		lines = append(lines, transferLine)
 	}
 
+	// Size exclusions
+	if stats.ExcludedBySize > 0 {
+		excludedLine := fmt.Sprintf("🚫 Excluded by size: %s",
+			pr.formatMessage(fmt.Sprintf("%d files", stats.ExcludedBySize), color.FgYellow),
+		)
+		lines = append(lines, excludedLine)
+	}
+
+	// Pattern exclusions
+	if stats.ExcludedByPattern > 0 {
+		excludedLine := fmt.Sprintf("🚫 Excluded by pattern: %s",
+			pr.formatMessage(fmt.Sprintf("%d files", stats.ExcludedByPattern), color.FgYellow),
+		)
+		lines = append(lines, excludedLine)
+	}
+
+	// Modification time exclusions
+	if stats.ExcludedByTime > 0 {
+		excludedLine := fmt.Sprintf("🚫 Not modified since cutoff: %s",
+			pr.formatMessage(fmt.Sprintf("%d files", stats.ExcludedByTime), color.FgYellow),
+		)
+		lines = append(lines, excludedLine)
+	}
+
+	// Deletions
+	if stats.FilesDeleted > 0 {
+		deleted := fmt.Sprintf("%d files", stats.FilesDeleted)
+		if stats.BytesDeleted > 0 {
+			deleted += fmt.Sprintf(" (%s)", formatBytes(stats.BytesDeleted))
+		}
+
+		verb := "Deleted"
+		if stats.DryRun {
+			verb = "Would delete"
+		}
+
+		deletedLine := fmt.Sprintf("🗑️  %s: %s", verb, pr.formatMessage(deleted, color.FgRed))
+		lines = append(lines, deletedLine)
+	}
+
+	// Directories the deletions left empty
+	if stats.DirsPruned > 0 {
+		verb := "Pruned"
+		if stats.DryRun {
+			verb = "Would prune"
+		}
+
+		prunedLine := fmt.Sprintf("🧹 %s: %s left empty", verb,
+			pr.formatMessage(fmt.Sprintf("%d directories", stats.DirsPruned), color.FgRed),
+		)
+		lines = append(lines, prunedLine)
+	}
+
+	// Directories renamed instead of copied
+	if stats.DirsMoved > 0 {
+		verb := "Moved"
+		if stats.DryRun {
+			verb = "Would move"
+		}
+
+		lines = append(lines, fmt.Sprintf("📦 %s: %d directories renamed in the source", verb, stats.DirsMoved))
+	}
+
+	// Destination files newer than their source
+	if stats.DestNewerKept > 0 {
+		keptLine := fmt.Sprintf("🛡️  Kept: %s newer than source",
+			pr.formatMessage(fmt.Sprintf("%d destination files", stats.DestNewerKept), color.FgYellow),
+		)
+		lines = append(lines, keptLine)
+	}
+
+	// Net space change at the destination
+	if stats.NetBytesChange != 0 || stats.FilesDeleted > 0 {
+		change := "+" + formatBytes(stats.NetBytesChange)
+		if stats.NetBytesChange < 0 {
+			change = "-" + formatBytes(-stats.NetBytesChange)
+		}
+
+		netLine := fmt.Sprintf("💾 Destination size change: %s", pr.formatMessage(change, color.FgCyan))
+		lines = append(lines, netLine)
+	}
+
+	// Files whose content already matched but whose metadata did not
+	if stats.MetadataUpdated > 0 {
+		verb := "Metadata updated"
+		if stats.DryRun {
+			verb = "Would update metadata"
+		}
+
+		metadataLine := fmt.Sprintf("🏷️  %s: %s (content already matched, not copied)", verb,
+			pr.formatMessage(fmt.Sprintf("%d files", stats.MetadataUpdated), color.FgCyan),
+		)
+		lines = append(lines, metadataLine)
+	}
+
+	// Files left for a later run by --max-files
+	if stats.FilesDeferred > 0 {
+		deferredLine := fmt.Sprintf("⏸️  File limit reached: %s left for the next run",
+			pr.formatMessage(fmt.Sprintf("%d files", stats.FilesDeferred), color.FgYellow),
+		)
+		lines = append(lines, deferredLine)
+	}
+
+	// Quarantined sources
+	if stats.FilesQuarantined > 0 {
+		quarantineLine := fmt.Sprintf("☣️  Quarantined (suspected source corruption): %s",
+			pr.formatMessage(fmt.Sprintf("%d files", stats.FilesQuarantined), color.FgRed),
+		)
+		lines = append(lines, quarantineLine)
+	}
+
+	// Destination files read back by --verify-after
+	if stats.FilesReverified > 0 {
+		verifyLine := fmt.Sprintf("🔍 Verified: %s match their source",
+			pr.formatMessage(fmt.Sprintf("%d of %d files", stats.FilesReverified-stats.VerifyMismatches, stats.FilesReverified), color.FgGreen),
+		)
+		if stats.VerifyMismatches > 0 {
+			verifyLine += ", " + pr.formatMessage(fmt.Sprintf("%d differ", stats.VerifyMismatches), color.FgRed)
+		}
+
+		lines = append(lines, verifyLine)
+	}
+
 	// Conflicts
 	if stats.ConflictsFound > 0 {
 		conflictLine := fmt.Sprintf("⚔️  Conflicts: %s found, %s resolved",
@@ -131,6 +270,52 @@ func (pr *ProgressRenderer) RenderStats(stats *core.SyncStats) string {
 	return strings.Join(lines, "\n")
 }
 
+// RenderPlan renders the plan of a run, worked out before copying starts.
+func (pr *ProgressRenderer) RenderPlan(plan *core.SyncPlan) string {
+	if plan == nil {
+		return ""
+	}
+
+	parts := []string{
+		"copy " + pr.formatMessage(fmt.Sprintf("%s (%s)", countOf(plan.FilesToCopy, "file", "files"), formatBytes(plan.BytesToCopy)), color.FgGreen),
+	}
+
+	if plan.DirsToCreate > 0 {
+		parts = append(parts, "create "+countOf(plan.DirsToCreate, "directory", "directories"))
+	}
+
+	if plan.DirsToMove > 0 {
+		parts = append(parts, "move "+countOf(plan.DirsToMove, "directory", "directories"))
+	}
+
+	if plan.MetadataOnly > 0 {
+		parts = append(parts, "update metadata of "+countOf(plan.MetadataOnly, "file", "files"))
+	}
+
+	parts = append(parts, fmt.Sprintf("skip %s unchanged", formatCount(plan.FilesUnchanged)))
+
+	if newer := int64(len(plan.DestNewer)); newer > 0 {
+		// Without protection these are among the files to copy.
+		verb := "overwrite "
+		if plan.KeepDestNewer {
+			verb = "keep "
+		}
+
+		parts = append(parts, verb+pr.formatMessage(countOf(newer, "file", "files")+" newer at destination", color.FgYellow))
+	}
+
+	if plan.FilesToDelete > 0 {
+		deleted := formatCount(plan.FilesToDelete) + " extraneous"
+		if plan.BytesToDelete > 0 {
+			deleted += fmt.Sprintf(" (%s)", formatBytes(plan.BytesToDelete))
+		}
+
+		parts = append(parts, "delete "+pr.formatMessage(deleted, color.FgRed))
+	}
+
+	return "📋 Plan: " + strings.Join(parts, ", ")
+}
+
 // RenderErrors renders error information.
 func (pr *ProgressRenderer) RenderErrors(errorSummary map[core.ErrorCategory]int) string {
 	if len(errorSummary) == 0 {
@@ -166,50 +351,6 @@ func (pr *ProgressRenderer) formatMessage(text string, colorAttr color.Attribute
 	return color.New(colorAttr).Sprint(text)
 }
 
-// formatSpeed formats transfer speed in human-readable format.
-func (pr *ProgressRenderer) formatSpeed(bytesPerSecond int64) string {
-	if bytesPerSecond == 0 {
-		return "-- B/s"
-	}
-
-	units := []string{"B/s", "KB/s", "MB/s", "GB/s"}
-	size := float64(bytesPerSecond)
-	unitIndex := 0
-
-	for size >= 1024 && unitIndex < len(units)-1 {
-		size /= 1024
-		unitIndex++
-	}
-
-	if unitIndex == 0 {
-		return fmt.Sprintf("%.0f %s", size, units[unitIndex])
-	}
-
-	return fmt.Sprintf("%.1f %s", size, units[unitIndex])
-}
-
-// formatBytes formats byte count in human-readable format.
-func (pr *ProgressRenderer) formatBytes(bytes int64) string {
-	if bytes == 0 {
-		return "0 B"
-	}
-
-	units := []string{"B", "KB", "MB", "GB", "TB"}
-	size := float64(bytes)
-	unitIndex := 0
-
-	for size >= 1024 && unitIndex < len(units)-1 {
-		size /= 1024
-		unitIndex++
-	}
-
-	if unitIndex == 0 {
-		return fmt.Sprintf("%.0f %s", size, units[unitIndex])
-	}
-
-	return fmt.Sprintf("%.1f %s", size, units[unitIndex])
-}
-
 // formatDuration formats duration in human-readable format.
 func (pr *ProgressRenderer) formatDuration(d time.Duration) string {
 	if d <= 0 {
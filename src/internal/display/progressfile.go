@@ -0,0 +1,91 @@
+package display
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/howmanysmall/relay/src/internal/core"
+)
+
+// ProgressFile periodically writes the engine's progress as JSON so that
+// external tools can poll it without parsing terminal output.
+type ProgressFile struct {
+	engine      *core.SyncEngine
+	path        string
+	refreshRate time.Duration
+}
+
+// NewProgressFile creates a progress file writer for the sync engine.
+func NewProgressFile(engine *core.SyncEngine, path string, refreshRate time.Duration) *ProgressFile {
+	return &ProgressFile{
+		engine:      engine,
+		path:        path,
+		refreshRate: refreshRate,
+	}
+}
+
+// Run writes the progress file on every tick until the context is cancelled,
+// then writes a final snapshot.
+func (pf *ProgressFile) Run(ctx context.Context) {
+	ticker := time.NewTicker(pf.refreshRate)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			_ = pf.Write()
+			return
+		case <-ticker.C:
+			_ = pf.Write()
+		}
+	}
+}
+
+// Write atomically replaces the progress file with the current progress.
+func (pf *ProgressFile) Write() error {
+	data, err := json.Marshal(pf.engine.GetProgress())
+	if err != nil {
+		return fmt.Errorf("failed to encode progress: %w", err)
+	}
+
+	// Write to a temporary file in the same directory and rename it into
+	// place so readers never observe a partially written file.
+	tempFile, err := os.CreateTemp(filepath.Dir(pf.path), filepath.Base(pf.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary progress file: %w", err)
+	}
+
+	tempPath := tempFile.Name()
+
+	if _, err := tempFile.Write(data); err != nil {
+		_ = tempFile.Close()
+		_ = os.Remove(tempPath)
+
+		return fmt.Errorf("failed to write progress file: %w", err)
+	}
+
+	if err := tempFile.Close(); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to close progress file: %w", err)
+	}
+
+	if err := os.Rename(tempPath, pf.path); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to replace progress file: %w", err)
+	}
+
+	return nil
+}
+
+// Remove deletes the progress file.
+func (pf *ProgressFile) Remove() error {
+	if err := os.Remove(pf.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove progress file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package display
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/howmanysmall/relay/src/internal/core"
+)
+
+// RenderSnapshots lists the snapshots of store, oldest first, one line each:
+// its name, which is when it was taken, its size, and how much of it was new
+// rather than shared with the snapshot before it. Snapshots without a
+// description show only their name.
+func RenderSnapshots(store string, snapshots []core.Snapshot, colorEnabled bool) string {
+	if len(snapshots) == 0 {
+		return "No snapshots in " + store
+	}
+
+	lines := []string{colorize(store, color.FgCyan, colorEnabled) + "  " +
+		countOf(int64(len(snapshots)), "snapshot", "snapshots")}
+
+	for _, snapshot := range snapshots {
+		line := "  " + snapshot.Name
+
+		if snapshot.Files > 0 || snapshot.Dirs > 0 {
+			line += fmt.Sprintf("  %10s in %s, %s new", formatBytes(snapshot.Size),
+				countOf(snapshot.Files, "file", "files"), formatBytes(snapshot.CopiedBytes))
+		}
+
+		if snapshot.Unreadable > 0 {
+			line += colorize(fmt.Sprintf(" (%s unreadable)", countOf(int64(snapshot.Unreadable), "entry", "entries")),
+				color.FgYellow, colorEnabled)
+		}
+
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
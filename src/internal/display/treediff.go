@@ -0,0 +1,78 @@
+package display
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/howmanysmall/relay/src/internal/core"
+)
+
+// RenderTreeDiff lists the differences in diff, one per line, followed by a
+// count of the differences and of the identical files. Directories end with
+// a path separator.
+func RenderTreeDiff(diff *core.TreeDiff, colorEnabled bool) string {
+	lines := make([]string, 0, len(diff.Differences)+2)
+
+	for _, difference := range diff.Differences {
+		switch difference.Kind {
+		case core.DiffOnlyInA:
+			lines = append(lines, colorize("only in A  ", color.FgRed, colorEnabled)+diffPath(difference.Path, difference.A))
+		case core.DiffOnlyInB:
+			lines = append(lines, colorize("only in B  ", color.FgGreen, colorEnabled)+diffPath(difference.Path, difference.B))
+		case core.DiffType:
+			lines = append(lines, colorize("type       ", color.FgMagenta, colorEnabled)+
+				fmt.Sprintf("%s (%s in A, %s in B)", difference.Path, entryType(difference.A), entryType(difference.B)))
+		case core.DiffContent:
+			lines = append(lines, colorize("differs    ", color.FgYellow, colorEnabled)+
+				fmt.Sprintf("%s (%s)", difference.Path, contentDifference(difference.A, difference.B, diff.Checksums)))
+		}
+	}
+
+	if len(lines) > 0 {
+		lines = append(lines, "")
+	}
+
+	if len(diff.Differences) == 0 {
+		lines = append(lines, fmt.Sprintf("No differences; %s identical", countOf(diff.Identical, "file", "files")))
+	} else {
+		lines = append(lines, fmt.Sprintf("%s, %s identical",
+			countOf(int64(len(diff.Differences)), "difference", "differences"),
+			countOf(diff.Identical, "file", "files")))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// diffPath returns path, with a trailing separator when info is a directory.
+func diffPath(path string, info *core.FileInfo) string {
+	if info.IsDir {
+		return path + string(filepath.Separator)
+	}
+
+	return path
+}
+
+func entryType(info *core.FileInfo) string {
+	if info.IsDir {
+		return "directory"
+	}
+
+	return "file"
+}
+
+// contentDifference describes how files a and b differ.
+func contentDifference(a, b *core.FileInfo, checksums bool) string {
+	switch {
+	case a.Size != b.Size:
+		return fmt.Sprintf("%s vs %s", formatBytes(a.Size), formatBytes(b.Size))
+	case checksums:
+		return "same size, different checksum"
+	case a.ModTime.After(b.ModTime):
+		return "newer in A, " + a.ModTime.Format(time.DateTime)
+	default:
+		return "newer in B, " + b.ModTime.Format(time.DateTime)
+	}
+}
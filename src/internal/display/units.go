@@ -0,0 +1,127 @@
+package display
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ByteUnits selects how byte counts and transfer speeds are printed.
+type ByteUnits int
+
+// Byte unit styles
+const (
+	UnitsIEC ByteUnits = iota // powers of 1024: KiB, MiB, GiB
+	UnitsSI                   // powers of 1000: kB, MB, GB
+	UnitsRaw                  // exact byte counts, for scripts
+)
+
+var (
+	iecUnits = []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	siUnits  = []string{"B", "kB", "MB", "GB", "TB"}
+)
+
+// byteUnits is the style used by every renderer in the process.
+var byteUnits = UnitsIEC
+
+// SetByteUnits sets how byte counts are printed.
+func SetByteUnits(units ByteUnits) {
+	byteUnits = units
+}
+
+// ParseByteUnits parses a --units value ("iec" or "si").
+func ParseByteUnits(value string) (ByteUnits, error) {
+	switch strings.ToLower(value) {
+	case "iec", "":
+		return UnitsIEC, nil
+	case "si":
+		return UnitsSI, nil
+	default:
+		return UnitsIEC, fmt.Errorf("invalid units %q: expected si or iec", value)
+	}
+}
+
+// FormatBytes formats a byte count in the units chosen with SetByteUnits.
+func FormatBytes(bytes int64) string {
+	return formatBytes(bytes)
+}
+
+// FormatSpeed formats a rate in bytes per second in the units chosen with
+// SetByteUnits.
+func FormatSpeed(bytesPerSecond int64) string {
+	return formatSpeed(bytesPerSecond)
+}
+
+// CountOf formats n followed by the singular or plural noun, e.g. "1 file".
+func CountOf(n int64, singular, plural string) string {
+	return countOf(n, singular, plural)
+}
+
+// formatBytes formats a byte count in the configured units.
+func formatBytes(bytes int64) string {
+	return formatUnits(bytes, "")
+}
+
+// formatCount formats n with thousands separators, e.g. 1,203.
+func formatCount(n int64) string {
+	if n < 0 {
+		return "-" + formatCount(-n)
+	}
+
+	digits := strconv.FormatInt(n, 10)
+
+	var formatted strings.Builder
+
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			formatted.WriteByte(',')
+		}
+
+		formatted.WriteRune(digit)
+	}
+
+	return formatted.String()
+}
+
+// countOf formats n followed by the singular or plural noun, e.g. "1 file".
+func countOf(n int64, singular, plural string) string {
+	if n == 1 {
+		return "1 " + singular
+	}
+
+	return formatCount(n) + " " + plural
+}
+
+// formatSpeed formats a transfer speed in the configured units.
+func formatSpeed(bytesPerSecond int64) string {
+	if bytesPerSecond == 0 {
+		return "-- B/s"
+	}
+
+	return formatUnits(bytesPerSecond, "/s")
+}
+
+func formatUnits(bytes int64, suffix string) string {
+	if byteUnits == UnitsRaw {
+		return fmt.Sprintf("%d B%s", bytes, suffix)
+	}
+
+	units, base := iecUnits, 1024.0
+	if byteUnits == UnitsSI {
+		units, base = siUnits, 1000.0
+	}
+
+	size := float64(bytes)
+	unitIndex := 0
+
+	for size >= base && unitIndex < len(units)-1 {
+		size /= base
+		unitIndex++
+	}
+
+	if unitIndex == 0 {
+		return fmt.Sprintf("%.0f %s%s", size, units[unitIndex], suffix)
+	}
+
+	return fmt.Sprintf("%.1f %s%s", size, units[unitIndex], suffix)
+}
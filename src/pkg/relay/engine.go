@@ -0,0 +1,41 @@
+package relay
+
+import (
+	"github.com/howmanysmall/relay/src/internal/core"
+)
+
+// The sync engine and the types it reports are re-exported so that programs
+// embedding relay can run syncs and observe them.
+type (
+	// Engine re-exports core.SyncEngine for public API consumers.
+	Engine = core.SyncEngine
+	// SyncOptions re-exports core.SyncOptions for public API consumers.
+	SyncOptions = core.SyncOptions
+	// SyncStats re-exports core.SyncStats for public API consumers.
+	SyncStats = core.SyncStats
+	// Progress re-exports core.Progress for public API consumers.
+	Progress = core.Progress
+	// Metrics re-exports core.Metrics, the snapshot returned by
+	// Engine.Metrics for dashboards and metrics systems.
+	Metrics = core.Metrics
+	// SyncError re-exports core.SyncError for public API consumers.
+	SyncError = core.SyncError
+	// ErrorCategory re-exports core.ErrorCategory.
+	ErrorCategory = core.ErrorCategory
+)
+
+// Re-export error categories
+const (
+	ErrorCategoryUnknown       = core.ErrorCategoryUnknown
+	ErrorCategoryNetwork       = core.ErrorCategoryNetwork
+	ErrorCategoryPermission    = core.ErrorCategoryPermission
+	ErrorCategoryDisk          = core.ErrorCategoryDisk
+	ErrorCategoryCorruption    = core.ErrorCategoryCorruption
+	ErrorCategoryConfiguration = core.ErrorCategoryConfiguration
+	ErrorCategoryCancellation  = core.ErrorCategoryCancellation
+)
+
+// NewEngine creates a sync engine with default settings.
+func NewEngine() (*Engine, error) {
+	return core.NewSyncEngine()
+}